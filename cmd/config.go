@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	configFileName = "config.json"
+
+	logLevelInfo  = "info"
+	logLevelDebug = "debug"
+)
+
+// fileConfig holds the settings read from the optional config file
+// Flags and environment variables override every field
+type fileConfig struct {
+	DB              string `json:"db"`               // Database path, resolved against the config file's folder
+	LogLevel        string `json:"log_level"`        // "info" or "debug"
+	DefaultCurrency string `json:"default_currency"` // target_currency used when a tool call has none
+}
+
+// defaultConfigPath returns ~/.moneywiz-mcp/config.json, or "" without a home directory
+func defaultConfigPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".moneywiz-mcp", configFileName)
+}
+
+// loadConfig reads the config file at path
+// A missing file is only an error when the path was given explicitly (-config or
+// MONEYWIZ_CONFIG); the default location is optional
+func loadConfig(path string, explicit bool) (fileConfig, error) {
+	var cfg fileConfig
+	if path == "" {
+		return cfg, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) && !explicit {
+			return cfg, nil
+		}
+		return cfg, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&cfg); err != nil {
+		return cfg, fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+
+	cfg.DB = strings.TrimSpace(cfg.DB)
+	if cfg.DB != "" && cfg.DB != latestSentinel && !filepath.IsAbs(cfg.DB) {
+		cfg.DB = filepath.Join(filepath.Dir(path), cfg.DB)
+	}
+	if cfg.LogLevel != "" {
+		if cfg.LogLevel, err = parseLogLevel(cfg.LogLevel); err != nil {
+			return cfg, fmt.Errorf("invalid config file %s: %w", path, err)
+		}
+	}
+	return cfg, nil
+}
+
+// parseLogLevel accepts "info" or "debug" in any case
+func parseLogLevel(value string) (string, error) {
+	switch level := strings.ToLower(strings.TrimSpace(value)); level {
+	case logLevelInfo, logLevelDebug:
+		return level, nil
+	default:
+		return "", fmt.Errorf("log level %q must be info or debug", value)
+	}
+}
+
+// firstNonEmpty returns the first value that is not blank, trimmed, implementing the
+// flag > environment > config file > default precedence
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value = strings.TrimSpace(value); value != "" {
+			return value
+		}
+	}
+	return ""
+}
@@ -1,14 +1,19 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"regexp"
 	"sort"
 	"strings"
+	"syscall"
 	"time"
 
 	mcpserver "github.com/mark3labs/mcp-go/server"
@@ -19,8 +24,65 @@ import (
 const (
 	defaultSQLiteName = "ipadMoneyWiz.sqlite"
 	latestSentinel    = "latest"
+
+	// shutdownTimeout bounds how long in-flight tool calls may run after a shutdown signal
+	shutdownTimeout = 5 * time.Second
 )
 
+// dbFlag collects repeated -db values
+type dbFlag []string
+
+func (f *dbFlag) String() string {
+	return strings.Join(*f, ", ")
+}
+
+func (f *dbFlag) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
+
+// dbSpec is one database to serve: its name and the unresolved -db path
+type dbSpec struct {
+	name string
+	path string
+}
+
+// dbNamePattern limits names so "name=path" is not confused with a path containing "="
+var dbNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// parseDBSpecs splits -db values of the form "path" or "name=path"
+// With no values, the default database is resolved from the environment or auto-discovery;
+// a single unnamed value is the default database, and several values must all be named
+func parseDBSpecs(values []string) ([]dbSpec, error) {
+	if len(values) == 0 {
+		return []dbSpec{{name: server.DefaultDatabaseName}}, nil
+	}
+
+	specs := make([]dbSpec, 0, len(values))
+	seen := make(map[string]struct{}, len(values))
+	for _, value := range values {
+		spec := dbSpec{path: value}
+		if name, path, found := strings.Cut(value, "="); found && dbNamePattern.MatchString(name) {
+			spec = dbSpec{name: name, path: path}
+		}
+		if spec.name == "" {
+			if len(values) > 1 {
+				return nil, fmt.Errorf("%q has no name; use name=path when passing several -db values", value)
+			}
+			spec.name = server.DefaultDatabaseName
+		}
+		if strings.TrimSpace(spec.path) == "" {
+			return nil, fmt.Errorf("database %q has an empty path", spec.name)
+		}
+		if _, exists := seen[spec.name]; exists {
+			return nil, fmt.Errorf("database name %q is used more than once", spec.name)
+		}
+		seen[spec.name] = struct{}{}
+		specs = append(specs, spec)
+	}
+	return specs, nil
+}
+
 type candidateDB struct {
 	path    string
 	modTime time.Time
@@ -28,37 +90,134 @@ type candidateDB struct {
 
 func main() {
 	// Parse command line arguments
-	dbPath := flag.String("db", "", "Path to MoneyWiz DB (sqlite file or export folder). Use 'latest' to auto-pick newest export.")
+	var dbValues dbFlag
+	flag.Var(&dbValues, "db", "Path to MoneyWiz DB (sqlite file or export folder). Use 'latest' to auto-pick newest export. Repeat as name=path to serve several databases; the first is the default.")
+	readWrite := flag.Bool("read-write", false, "Open the database read-write instead of read-only (no tool writes today)")
+	maxOpenConns := flag.Int("max-open-conns", 0, fmt.Sprintf("Maximum database connections open at once per database (0 = %d read-only, 1 with -read-write)", database.DefaultMaxOpenConns))
+	busyTimeout := flag.Duration("busy-timeout", database.DefaultBusyTimeout, "How long a query waits while MoneyWiz has the database locked")
+	queryTimeout := flag.Duration("query-timeout", server.DefaultQueryTimeout, "Maximum time one tool call may spend querying the database (0 = no limit)")
+	slowQuery := flag.Duration("slow-query", database.DefaultSlowQueryThreshold, "Log database queries that take at least this long (0 = never)")
+	fiscalYearStart := flag.Int("fiscal-year-start", 1, "Month (1-12) the year begins in when analyses group by year; other than 1 labels years FY<end year>")
+	uncategorizedLabel := flag.String("uncategorized-label", database.DefaultUncategorizedLabel, "Category name given to transactions without a category")
+	configPath := flag.String("config", "", "Path to a JSON config file with db, log_level, and default_currency (default ~/.moneywiz-mcp/config.json when present)")
+	logLevel := flag.String("log-level", "", "Log level: info or debug (default info)")
+	defaultCurrency := flag.String("default-currency", "", "Currency code tools convert into when a call passes exchange_rates but no target_currency, also given to accounts without a currency")
+	debug := flag.Bool("debug", false, "Log the elapsed time of every database query (same as -log-level debug)")
 	flag.Parse()
 
-	resolvedDBPath, err := resolveDBPath(*dbPath)
+	// Settings come from flags, then environment variables, then the config file
+	configFile := firstNonEmpty(*configPath, os.Getenv("MONEYWIZ_CONFIG"))
+	cfg, err := loadConfig(firstNonEmpty(configFile, defaultConfigPath()), configFile != "")
 	if err != nil {
-		log.Fatalf("Failed to resolve database path: %v", err)
+		log.Fatalf("Failed to load config: %v", err)
+	}
+	level, err := parseLogLevel(firstNonEmpty(*logLevel, os.Getenv("MONEYWIZ_LOG_LEVEL"), cfg.LogLevel, logLevelInfo))
+	if err != nil {
+		log.Fatalf("Invalid log level: %v", err)
+	}
+	if *debug {
+		level = logLevelDebug
 	}
-	log.Printf("Using database: %s", resolvedDBPath)
 
-	// Initialize database connection
-	db, err := database.NewDB(resolvedDBPath)
+	// Accounts without a currency are counted in the default currency when one is configured
+	currency := firstNonEmpty(*defaultCurrency, os.Getenv("MONEYWIZ_DEFAULT_CURRENCY"), cfg.DefaultCurrency)
+
+	specs, err := parseDBSpecs(dbValues)
 	if err != nil {
-		log.Fatalf("Failed to connect to database: %v", err)
+		log.Fatalf("Invalid -db value: %v", err)
+	}
+
+	// Initialize database connections
+	var databases []server.NamedDB
+	for _, spec := range specs {
+		resolvedDBPath, err := resolveDBPath(spec.path, cfg.DB)
+		if err != nil {
+			log.Fatalf("Failed to resolve database path: %v", err)
+		}
+		log.Printf("Using database %q: %s", spec.name, resolvedDBPath)
+
+		db, err := database.NewDB(resolvedDBPath, database.Options{
+			ReadWrite:          *readWrite,
+			BusyTimeout:        *busyTimeout,
+			FiscalYearStart:    time.Month(*fiscalYearStart),
+			UncategorizedLabel: *uncategorizedLabel,
+			UnknownCurrency:    currency,
+			MaxOpenConns:       *maxOpenConns,
+			SlowQueryThreshold: *slowQuery,
+			LogQueries:         level == logLevelDebug,
+		})
+		if err != nil {
+			log.Fatalf("Failed to open database %q: %v", spec.name, err)
+		}
+		databases = append(databases, server.NamedDB{Name: spec.name, DB: db})
 	}
-	defer db.Close()
 
 	// Create MCP server
-	mcpServer := mcpserver.NewMCPServer("moneywiz-mcp", "1.0.0")
+	limits := server.QueryLimits{Timeout: *queryTimeout}
+	mcpServer := mcpserver.NewMCPServer("moneywiz-mcp", "1.0.0", mcpserver.WithToolHandlerMiddleware(limits.Middleware()))
 
 	// Create our server instance and register handlers
-	srv := server.NewServer(db)
+	srv, err := server.NewServer(databases...)
+	if err != nil {
+		log.Fatalf("Failed to create server: %v", err)
+	}
+	srv.SetDefaultCurrency(currency)
 	srv.RegisterHandlers(mcpServer)
 
+	// Cancel the root context on SIGINT/SIGTERM so the stdio loop and in-flight handlers stop
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	// Start the stdio server
 	log.Println("Starting MoneyWiz MCP server...")
-	if err := mcpserver.ServeStdio(mcpServer); err != nil {
-		log.Fatalf("Server error: %v", err)
+	serveErr := serveStdio(ctx, mcpServer, os.Stdin, os.Stdout)
+
+	// Close explicitly (not via defer) so the connections are released even when exiting with an error
+	for _, d := range databases {
+		if err := d.DB.Close(); err != nil {
+			log.Printf("Failed to close database %q: %v", d.Name, err)
+		}
 	}
+	if serveErr != nil {
+		log.Fatalf("Server error: %v", serveErr)
+	}
+	log.Println("MoneyWiz MCP server stopped")
 }
 
-func resolveDBPath(arg string) (string, error) {
+// serveStdio runs the stdio transport until the client disconnects or ctx is cancelled.
+// After cancellation it waits up to shutdownTimeout for in-flight tool calls to finish.
+func serveStdio(ctx context.Context, mcpServer *mcpserver.MCPServer, stdin io.Reader, stdout io.Writer) error {
+	stdioServer := mcpserver.NewStdioServer(mcpServer)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- stdioServer.Listen(ctx, stdin, stdout)
+	}()
+
+	select {
+	case err := <-done:
+		return ignoreCanceled(err)
+	case <-ctx.Done():
+		log.Println("Shutdown signal received, waiting for in-flight requests...")
+	}
+
+	select {
+	case err := <-done:
+		return ignoreCanceled(err)
+	case <-time.After(shutdownTimeout):
+		return fmt.Errorf("in-flight requests did not finish within %s", shutdownTimeout)
+	}
+}
+
+func ignoreCanceled(err error) error {
+	if errors.Is(err, context.Canceled) {
+		return nil
+	}
+	return err
+}
+
+// resolveDBPath finds the database for a -db value; configured is the config file's db setting
+func resolveDBPath(arg, configured string) (string, error) {
 	// Highest priority: explicit CLI argument.
 	if strings.TrimSpace(arg) != "" {
 		if arg == latestSentinel {
@@ -75,6 +234,14 @@ func resolveDBPath(arg string) (string, error) {
 		return normalizeDBPath(env)
 	}
 
+	// Next priority: config file.
+	if configured != "" {
+		if configured == latestSentinel {
+			return findLatestExportDBPath()
+		}
+		return normalizeDBPath(configured)
+	}
+
 	// Next priority: canonical local managed path.
 	if home, err := os.UserHomeDir(); err == nil {
 		canonical := filepath.Join(home, ".moneywiz-mcp", defaultSQLiteName)
@@ -90,7 +257,7 @@ func resolveDBPath(arg string) (string, error) {
 	}
 
 	return "", errors.New(
-		"database not found. Provide --db <path>, set MONEYWIZ_DB_PATH, or run ./scripts/import_db.sh /path/to/iMoneyWiz-Data-Backup-*",
+		"database not found. Provide --db <path>, set MONEYWIZ_DB_PATH, add db to ~/.moneywiz-mcp/config.json, or run ./scripts/import_db.sh /path/to/iMoneyWiz-Data-Backup-*",
 	)
 }
 
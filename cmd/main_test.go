@@ -1,10 +1,15 @@
 package main
 
 import (
+	"context"
+	"io"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
+
+	mcpserver "github.com/mark3labs/mcp-go/server"
 )
 
 func TestNormalizeDBPathAcceptsFolderAndFile(t *testing.T) {
@@ -36,6 +41,36 @@ func TestNormalizeDBPathAcceptsFolderAndFile(t *testing.T) {
 	}
 }
 
+func TestParseDBSpecsNamesDatabases(t *testing.T) {
+	specs, err := parseDBSpecs(nil)
+	if err != nil || len(specs) != 1 || specs[0].name != "default" || specs[0].path != "" {
+		t.Fatalf("parse no values = %+v, %v; want one unnamed default", specs, err)
+	}
+
+	specs, err = parseDBSpecs([]string{"/data/a=b/ipadMoneyWiz.sqlite"})
+	if err != nil || len(specs) != 1 || specs[0].name != "default" || specs[0].path != "/data/a=b/ipadMoneyWiz.sqlite" {
+		t.Fatalf("parse single path = %+v, %v; want the whole value as the default path", specs, err)
+	}
+
+	specs, err = parseDBSpecs([]string{"personal=/data/personal", "business=latest"})
+	if err != nil {
+		t.Fatalf("parse named values: %v", err)
+	}
+	if len(specs) != 2 || specs[0] != (dbSpec{name: "personal", path: "/data/personal"}) || specs[1] != (dbSpec{name: "business", path: "latest"}) {
+		t.Fatalf("parse named values = %+v", specs)
+	}
+
+	for _, values := range [][]string{
+		{"personal=/data/personal", "/data/business"},
+		{"personal=/data/a", "personal=/data/b"},
+		{"personal="},
+	} {
+		if _, err := parseDBSpecs(values); err == nil {
+			t.Fatalf("expected error for %q", values)
+		}
+	}
+}
+
 func TestResolveDBPathPrefersExplicitArgument(t *testing.T) {
 	env := setupResolutionEnv(t)
 
@@ -43,7 +78,7 @@ func TestResolveDBPathPrefersExplicitArgument(t *testing.T) {
 	envExport := mustCreateExportDB(t, env.homeDir, "iMoneyWiz-Data-Backup-2026_05_16-10_26", time.Now().Add(time.Hour))
 	t.Setenv("MONEYWIZ_DB_PATH", envExport)
 
-	got, err := resolveDBPath(argExport)
+	got, err := resolveDBPath(argExport, "")
 	if err != nil {
 		t.Fatalf("resolve explicit arg: %v", err)
 	}
@@ -58,7 +93,7 @@ func TestResolveDBPathUsesEnvWhenArgumentMissing(t *testing.T) {
 	envExport := mustCreateExportDB(t, env.homeDir, "iMoneyWiz-Data-Backup-2026_05_15-10_26", time.Now())
 	t.Setenv("MONEYWIZ_DB_PATH", envExport)
 
-	got, err := resolveDBPath("")
+	got, err := resolveDBPath("", "")
 	if err != nil {
 		t.Fatalf("resolve env path: %v", err)
 	}
@@ -81,7 +116,7 @@ func TestResolveDBPathUsesCanonicalBeforeExportDiscovery(t *testing.T) {
 
 	mustCreateExportDB(t, env.baseDir, "iMoneyWiz-Data-Backup-2026_05_15-10_26", time.Now().Add(time.Hour))
 
-	got, err := resolveDBPath("")
+	got, err := resolveDBPath("", "")
 	if err != nil {
 		t.Fatalf("resolve canonical path: %v", err)
 	}
@@ -96,7 +131,7 @@ func TestResolveDBPathLatestSelectsNewestExport(t *testing.T) {
 	older := mustCreateExportDB(t, env.baseDir, "iMoneyWiz-Data-Backup-2026_05_15-10_26", time.Now().Add(-time.Hour))
 	newer := mustCreateExportDB(t, env.baseDir, "iMoneyWiz-Data-Backup-2026_05_16-10_26", time.Now())
 
-	got, err := resolveDBPath(latestSentinel)
+	got, err := resolveDBPath(latestSentinel, "")
 	if err != nil {
 		t.Fatalf("resolve latest sentinel: %v", err)
 	}
@@ -108,7 +143,7 @@ func TestResolveDBPathLatestSelectsNewestExport(t *testing.T) {
 func TestResolveDBPathReturnsHelpfulErrorWhenNothingExists(t *testing.T) {
 	_ = setupResolutionEnv(t)
 
-	_, err := resolveDBPath("")
+	_, err := resolveDBPath("", "")
 	if err == nil {
 		t.Fatal("resolve empty path unexpectedly succeeded")
 	}
@@ -118,6 +153,72 @@ func TestResolveDBPathReturnsHelpfulErrorWhenNothingExists(t *testing.T) {
 	}
 }
 
+func TestResolveDBPathUsesConfigAfterEnv(t *testing.T) {
+	env := setupResolutionEnv(t)
+
+	configExport := mustCreateExportDB(t, env.baseDir, "iMoneyWiz-Data-Backup-2026_05_15-10_26", time.Now())
+	got, err := resolveDBPath("", configExport)
+	if err != nil {
+		t.Fatalf("resolve config path: %v", err)
+	}
+	if got != configExport {
+		t.Fatalf("resolve config path = %q, want %q", got, configExport)
+	}
+
+	envExport := mustCreateExportDB(t, env.homeDir, "iMoneyWiz-Data-Backup-2026_05_16-10_26", time.Now())
+	t.Setenv("MONEYWIZ_DB_PATH", envExport)
+	got, err = resolveDBPath("", configExport)
+	if err != nil {
+		t.Fatalf("resolve env over config: %v", err)
+	}
+	if got != envExport {
+		t.Fatalf("resolve env over config = %q, want %q", got, envExport)
+	}
+}
+
+func TestLoadConfigReadsSettings(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, configFileName)
+	if err := os.WriteFile(path, []byte(`{"db": "exports/latest.sqlite", "log_level": "DEBUG", "default_currency": "EUR"}`), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	cfg, err := loadConfig(path, true)
+	if err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+	want := fileConfig{DB: filepath.Join(dir, "exports", "latest.sqlite"), LogLevel: logLevelDebug, DefaultCurrency: "EUR"}
+	if cfg != want {
+		t.Fatalf("load config = %+v, want %+v", cfg, want)
+	}
+
+	missing := filepath.Join(dir, "missing.json")
+	if cfg, err := loadConfig(missing, false); err != nil || cfg != (fileConfig{}) {
+		t.Fatalf("load missing default config = %+v, %v; want empty config", cfg, err)
+	}
+	if _, err := loadConfig(missing, true); err == nil {
+		t.Fatal("expected error for a missing explicit config file")
+	}
+
+	for _, content := range []string{`{"log_level": "verbose"}`, `{"database": "x"}`, `not json`} {
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("write config: %v", err)
+		}
+		if _, err := loadConfig(path, true); err == nil {
+			t.Fatalf("expected error for config %s", content)
+		}
+	}
+}
+
+func TestFirstNonEmptyFollowsPrecedence(t *testing.T) {
+	if got := firstNonEmpty("", "  ", "env", "config"); got != "env" {
+		t.Fatalf("firstNonEmpty = %q, want env", got)
+	}
+	if got := firstNonEmpty("", ""); got != "" {
+		t.Fatalf("firstNonEmpty of blanks = %q, want empty", got)
+	}
+}
+
 type resolutionEnv struct {
 	baseDir string
 	homeDir string
@@ -187,3 +288,36 @@ func canonicalTestPath(t *testing.T, path string) string {
 	}
 	return filepath.Clean(path)
 }
+
+func TestServeStdioReturnsOnClientDisconnect(t *testing.T) {
+	mcpServer := mcpserver.NewMCPServer("moneywiz-mcp-test", "test")
+
+	if err := serveStdio(context.Background(), mcpServer, strings.NewReader(""), io.Discard); err != nil {
+		t.Fatalf("serveStdio on EOF: %v", err)
+	}
+}
+
+func TestServeStdioStopsWhenContextCancelled(t *testing.T) {
+	mcpServer := mcpserver.NewMCPServer("moneywiz-mcp-test", "test")
+
+	// A pipe that is never written to keeps the read loop blocked until cancellation
+	stdin, stdinWriter := io.Pipe()
+	defer stdinWriter.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- serveStdio(ctx, mcpServer, stdin, io.Discard)
+	}()
+
+	cancel()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("serveStdio after cancel: %v", err)
+		}
+	case <-time.After(shutdownTimeout + time.Second):
+		t.Fatal("serveStdio did not return after context cancellation")
+	}
+}
@@ -1,17 +1,62 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"math"
+	"strings"
 )
 
 // Account represents a MoneyWiz account
 type Account struct {
-	ID          int64   `json:"id"`
-	Name        string  `json:"name"`
-	Balance     float64 `json:"balance"`
-	Currency    string  `json:"currency"`
-	AccountType string  `json:"account_type"`
+	ID                  int64    `json:"id"`
+	Name                string   `json:"name"`
+	Balance             float64  `json:"balance" money:"amount"`         // Calculated from the opening balance and transactions
+	OpeningBalance      float64  `json:"opening_balance" money:"amount"` // ZOPENINGBALANCE
+	StoredBalance       *float64 `json:"stored_balance" money:"amount"`  // ZBALLANCE as cached by MoneyWiz; null when not stored
+	BalanceMismatch     bool     `json:"balance_mismatch"`               // A non-zero stored balance differs from Balance by more than a cent
+	Currency            string   `json:"currency"`
+	AccountType         string   `json:"account_type"`
+	Group               string   `json:"group,omitempty"`                 // Name of the account group, when the database stores groups
+	TransactionCount    int      `json:"transaction_count"`               // Transactions moving the account, including transfers in and out
+	LastTransactionDate string   `json:"last_transaction_date,omitempty"` // Latest of those transactions; empty when there are none
+}
+
+// balanceMismatchTolerance is the largest difference between the stored and calculated
+// balances that is still treated as rounding
+const balanceMismatchTolerance = 0.01
+
+const (
+	AccountClassificationAsset     = "asset"
+	AccountClassificationLiability = "liability"
+)
+
+// Account type keywords, matched against the lowercased ZTYPE value
+var (
+	liabilityAccountTypeKeywords = []string{"credit", "loan", "mortgage", "liability", "debt"}
+	assetAccountTypeKeywords     = []string{"bank", "check", "cheque", "saving", "deposit", "cash", "invest", "brokerage", "forex"}
+)
+
+// classifyAccount decides whether an account is an asset or a liability from its type
+// Credit card and loan accounts are liabilities whatever the sign of their balance;
+// accounts of an unrecognized type fall back to the sign of the balance
+func classifyAccount(accountType string, balance float64) string {
+	normalized := strings.ToLower(accountType)
+	for _, keyword := range liabilityAccountTypeKeywords {
+		if strings.Contains(normalized, keyword) {
+			return AccountClassificationLiability
+		}
+	}
+	for _, keyword := range assetAccountTypeKeywords {
+		if strings.Contains(normalized, keyword) {
+			return AccountClassificationAsset
+		}
+	}
+	if balance < 0 {
+		return AccountClassificationLiability
+	}
+	return AccountClassificationAsset
 }
 
 // GetAccounts retrieves all accounts from the database
@@ -23,151 +68,209 @@ type Account struct {
 // - Entity 15: Investment accounts
 // - Entity 16: Regular accounts
 // Note: Balance is stored in ZBALLANCE (double L), not ZBALANCE
-// If balance is 0 or NULL, we calculate it from transactions + opening balance
-func (db *DB) GetAccounts() ([]Account, error) {
-	query := `
-		SELECT Z_PK, ZNAME, ZBALLANCE, ZOPENINGBALANCE, ZCURRENCYNAME, ZTYPE
-		FROM ZSYNCOBJECT
-		WHERE Z_ENT IN (10, 11, 12, 13, 15, 16) AND ZNAME IS NOT NULL
-		ORDER BY ZNAME
-	`
-
-	rows, err := db.conn.Query(query)
+// Balances are calculated from the opening balance + transactions; see accountsQuery
+func (db *DB) GetAccounts(ctx context.Context) ([]Account, error) {
+	query, err := db.accountsQuery(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to query accounts: %w", err)
+		return nil, err
 	}
-	defer rows.Close()
+	query += " AND a.ZNAME IS NOT NULL ORDER BY a.ZNAME"
 
 	var accounts []Account
-	for rows.Next() {
-		var acc Account
-		var name sql.NullString
-		var accountType sql.NullString
-		var balance sql.NullFloat64
-		var openingBalance sql.NullFloat64
-		var currency sql.NullString
-		err := rows.Scan(&acc.ID, &name, &balance, &openingBalance, &currency, &accountType)
+	err = db.queryEach(ctx, "GetAccounts", query, nil, func(rows *sql.Rows) error {
+		acc, err := db.scanAccount(rows)
 		if err != nil {
-			return nil, fmt.Errorf("failed to scan account: %w", err)
-		}
-		if name.Valid {
-			acc.Name = name.String
-		}
-
-		// Calculate balance from opening balance + transactions (exactly as Python implementation)
-		// Python code: current_balance = opening_balance + transaction_total
-		calculatedBalance, err := db.calculateAccountBalance(acc.ID, openingBalance)
-		if err == nil {
-			acc.Balance = calculatedBalance
-		} else {
-			// Fallback to opening balance or stored balance
-			if openingBalance.Valid {
-				acc.Balance = openingBalance.Float64
-			} else if balance.Valid {
-				acc.Balance = balance.Float64
-			} else {
-				acc.Balance = 0.0
-			}
-		}
-
-		if currency.Valid {
-			acc.Currency = currency.String
-		}
-		if accountType.Valid {
-			acc.AccountType = accountType.String
+			return fmt.Errorf("failed to scan account: %w", err)
 		}
 		accounts = append(accounts, acc)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query accounts: %w", err)
 	}
 
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating accounts: %w", err)
+	if err := db.assignAccountGroups(ctx, accounts); err != nil {
+		return nil, err
 	}
 
 	return accounts, nil
 }
 
-// calculateAccountBalance calculates the account balance from opening balance + transactions
-// Transactions are entity types 37, 45, 46, 47 (regular transactions) and 43 (transfers)
-// They link to accounts via ZACCOUNT2 (and ZACCOUNT for transfers) and use ZAMOUNT1 for the amount
-func (db *DB) calculateAccountBalance(accountID int64, openingBalance sql.NullFloat64) (float64, error) {
-	var opening float64
-	if openingBalance.Valid {
-		opening = openingBalance.Float64
-	}
-
-	// Include entity 43 (transfers) and check both ZACCOUNT2 and ZACCOUNT
-	query := `
-		SELECT COALESCE(SUM(ZAMOUNT1), 0)
-		FROM ZSYNCOBJECT
-		WHERE Z_ENT IN (37, 45, 46, 47, 43) 
-		AND (ZACCOUNT2 = ? OR ZACCOUNT = ?)
-		AND ZAMOUNT1 IS NOT NULL
-	`
-
-	var transactionSum sql.NullFloat64
-	err := db.conn.QueryRow(query, accountID, accountID).Scan(&transactionSum)
+// GetFilteredAccounts returns the accounts the filter selects, in GetAccounts order (the zero
+// AccountFilter returns them all)
+// A currency or type that no account has is rejected with the ones that exist
+func (db *DB) GetFilteredAccounts(ctx context.Context, filter AccountFilter) ([]Account, error) {
+	accounts, err := db.GetAccounts(ctx)
 	if err != nil {
-		return opening, err
+		return nil, err
 	}
+	return filter.apply(accounts)
+}
 
-	var sum float64
-	if transactionSum.Valid {
-		sum = transactionSum.Float64
+// accountsQuery returns a SELECT of accounts with the total, count and latest date of their
+// movements joined in, so every balance comes from one grouped query rather than one query per
+// account
+// Callers append further conditions with AND
+func (db *DB) accountsQuery(ctx context.Context) (string, error) {
+	movements, err := db.accountMovementsQuery(ctx)
+	if err != nil {
+		return "", err
 	}
 
-	return opening + sum, nil
+	return `
+		SELECT a.Z_PK, a.ZNAME, a.ZBALLANCE, a.ZOPENINGBALANCE, a.ZCURRENCYNAME, a.ZTYPE, m.total, m.transactions, m.last_date
+		FROM ZSYNCOBJECT a
+		LEFT JOIN (
+			SELECT account_id, SUM(amount) AS total, COUNT(DISTINCT transaction_id) AS transactions, MAX(date) AS last_date
+			FROM (` + movements + `)
+			GROUP BY account_id
+		) m ON m.account_id = a.Z_PK
+		WHERE a.Z_ENT IN (10, 11, 12, 13, 15, 16)`, nil
 }
 
-// GetAccountBalance retrieves the balance for a specific account
-// Note: Balance is stored in ZBALLANCE (double L), not ZBALANCE
-// If balance is 0 or NULL, we calculate it from transactions + opening balance
-func (db *DB) GetAccountBalance(accountID int64) (*Account, error) {
-	query := `
-		SELECT Z_PK, ZNAME, ZBALLANCE, ZOPENINGBALANCE, ZCURRENCYNAME, ZTYPE
-		FROM ZSYNCOBJECT
-		WHERE Z_ENT IN (10, 11, 12, 13, 15, 16) AND Z_PK = ?
-	`
-
+// scanAccount reads one row of accountsQuery
+func (db *DB) scanAccount(row interface{ Scan(dest ...any) error }) (Account, error) {
 	var acc Account
 	var name sql.NullString
 	var accountType sql.NullString
 	var balance sql.NullFloat64
 	var openingBalance sql.NullFloat64
 	var currency sql.NullString
-	err := db.conn.QueryRow(query, accountID).Scan(&acc.ID, &name, &balance, &openingBalance, &currency, &accountType)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("account with ID %d not found", accountID)
-		}
-		return nil, fmt.Errorf("failed to query account: %w", err)
+	var transactionTotal sql.NullFloat64
+	var transactionCount sql.NullInt64
+	var lastDate sql.NullFloat64
+	if err := row.Scan(&acc.ID, &name, &balance, &openingBalance, &currency, &accountType, &transactionTotal, &transactionCount, &lastDate); err != nil {
+		return acc, err
+	}
+
+	acc.Name = name.String
+	acc.Currency = db.accountCurrency(currency)
+	acc.AccountType = accountType.String
+	acc.TransactionCount = int(transactionCount.Int64)
+	if lastDate.Valid {
+		acc.LastTransactionDate = coreDataToTime(lastDate.Float64).Format(dateTimeLayout)
+	}
+	acc.setBalances(transactionTotal, balance, openingBalance)
+	return acc, nil
+}
+
+// accountCurrency returns the currency code read from ZCURRENCYNAME, or the unknown currency when
+// it is NULL or blank, so no account or transaction drops out of the per-currency totals
+func (db *DB) accountCurrency(currency sql.NullString) string {
+	if strings.TrimSpace(currency.String) == "" {
+		return db.unknownCurrency
 	}
+	return currency.String
+}
 
-	if name.Valid {
-		acc.Name = name.String
+// primaryAccountCurrency returns the currency used by the most accounts, preferring the
+// alphabetically first on a tie; empty when no account has a currency
+func primaryAccountCurrency(accounts []Account) string {
+	counts := make(map[string]int)
+	for _, acc := range accounts {
+		if acc.Currency != "" {
+			counts[acc.Currency]++
+		}
 	}
 
-	// Calculate balance from opening balance + transactions (exactly as Python implementation)
-	// Python code: current_balance = opening_balance + transaction_total
-	calculatedBalance, err := db.calculateAccountBalance(accountID, openingBalance)
-	if err == nil {
-		acc.Balance = calculatedBalance
-	} else {
-		// Fallback to opening balance or stored balance
-		if openingBalance.Valid {
-			acc.Balance = openingBalance.Float64
-		} else if balance.Valid {
-			acc.Balance = balance.Float64
-		} else {
-			acc.Balance = 0.0
+	primary := ""
+	for _, currency := range sortedCurrencyKeys(counts) {
+		if counts[currency] > counts[primary] {
+			primary = currency
 		}
 	}
+	return primary
+}
+
+// setBalances fills the balance fields of an account: the opening balance plus the total of its
+// transactions (exactly as the Python implementation: current_balance = opening_balance + transaction_total)
+// A stored balance of 0 usually means MoneyWiz has not cached one, so it is never flagged
+func (acc *Account) setBalances(transactionTotal, stored, opening sql.NullFloat64) {
+	if opening.Valid {
+		acc.OpeningBalance = opening.Float64
+	}
+	if stored.Valid {
+		acc.StoredBalance = &stored.Float64
+	}
+
+	acc.Balance = acc.OpeningBalance + transactionTotal.Float64
+	acc.BalanceMismatch = stored.Valid && stored.Float64 != 0 && math.Abs(acc.Balance-stored.Float64) > balanceMismatchTolerance
+}
 
-	if currency.Valid {
-		acc.Currency = currency.String
+// transferRecipientAmountCandidates are the columns that may hold the amount a transfer
+// credits to the receiving account when it differs from ZAMOUNT1 (e.g. across currencies)
+var transferRecipientAmountCandidates = []string{"ZRECIPIENTAMOUNT1", "ZRECIPIENTAMOUNT"}
+
+// accountMovementsQuery returns a SELECT of (account_id, amount, date, transaction_id) rows, one per
+// effect a transaction has on an account balance. Every transaction row moves its own account
+// (ZACCOUNT2) by ZAMOUNT1. MoneyWiz usually stores a transfer (entity 43) as a pair of rows, one
+// per side, each naming the other account in ZACCOUNT; counting both rows against both accounts
+// would cancel the transfer out. The counterparty (ZACCOUNT) is therefore only moved by a transfer
+// row that has no mirrored row on the other side, using the recipient amount when one is stored
+// and the opposite of ZAMOUNT1 otherwise
+func (db *DB) accountMovementsQuery(ctx context.Context) (string, error) {
+	recipientColumn, err := db.firstExistingColumn(ctx, "ZSYNCOBJECT", transferRecipientAmountCandidates...)
+	if err != nil {
+		return "", err
 	}
-	if accountType.Valid {
-		acc.AccountType = accountType.String
+	counterpartyAmount := "-t.ZAMOUNT1"
+	if recipientColumn != "" {
+		counterpartyAmount = fmt.Sprintf("CASE WHEN t.%[1]s IS NULL THEN -t.ZAMOUNT1 WHEN t.ZAMOUNT1 < 0 THEN ABS(t.%[1]s) ELSE -ABS(t.%[1]s) END", recipientColumn)
+	}
+
+	return fmt.Sprintf(`
+		SELECT t.ZACCOUNT2 AS account_id, t.ZAMOUNT1 AS amount, t.ZDATE1 AS date, t.Z_PK AS transaction_id
+		FROM ZSYNCOBJECT t
+		WHERE t.Z_ENT IN (37, 45, 46, 47, 43)
+		AND t.ZAMOUNT1 IS NOT NULL
+		AND t.ZACCOUNT2 IS NOT NULL
+		UNION ALL
+		SELECT t.ZACCOUNT, %s, t.ZDATE1, t.Z_PK
+		FROM ZSYNCOBJECT t
+		WHERE t.Z_ENT = 43
+		AND t.ZAMOUNT1 IS NOT NULL
+		AND t.ZACCOUNT IS NOT NULL
+		AND t.ZACCOUNT <> COALESCE(t.ZACCOUNT2, 0)
+		AND NOT EXISTS (
+			SELECT 1 FROM ZSYNCOBJECT m
+			WHERE m.Z_ENT = 43
+			AND m.Z_PK <> t.Z_PK
+			AND m.ZACCOUNT2 = t.ZACCOUNT
+			AND m.ZACCOUNT = t.ZACCOUNT2
+			AND (m.ZAMOUNT1 < 0) <> (t.ZAMOUNT1 < 0)
+			AND ABS(COALESCE(m.ZDATE1, 0) - COALESCE(t.ZDATE1, 0)) < 86400
+		)
+	`, counterpartyAmount), nil
+}
+
+// GetAccountBalance retrieves the balance for a specific account
+// Note: Balance is stored in ZBALLANCE (double L), not ZBALANCE
+// The balance is calculated from the opening balance + transactions, as in GetAccounts
+func (db *DB) GetAccountBalance(ctx context.Context, accountID int64) (*Account, error) {
+	query, err := db.accountsQuery(ctx)
+	if err != nil {
+		return nil, err
+	}
+	query += " AND a.Z_PK = ?"
+
+	var acc Account
+	err = db.runQuery(ctx, "GetAccountBalance", func() error {
+		var err error
+		acc, err = db.scanAccount(db.reader().QueryRowContext(ctx, query, accountID))
+		return err
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, notFoundf("account with ID %d not found", accountID)
+		}
+		return nil, fmt.Errorf("failed to query account: %w", err)
+	}
+
+	names, err := db.accountGroupNames(ctx)
+	if err != nil {
+		return nil, err
 	}
+	acc.Group = names[acc.ID]
 
 	return &acc, nil
 }
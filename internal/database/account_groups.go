@@ -0,0 +1,226 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const ungroupedAccounts = "Ungrouped"
+
+// Account group sources reported with a net worth breakdown
+const (
+	AccountGroupSourceDatabase = "database"
+	AccountGroupSourceClient   = "client"
+)
+
+// MoneyWiz versions that let accounts be grouped register a group entity in Core Data's
+// Z_PRIMARYKEY table and point each account at its group. The column names are detected because
+// schema versions differ
+var (
+	accountGroupColumnCandidates     = []string{"ZGROUPID", "ZACCOUNTGROUP", "ZGROUP"}
+	accountGroupNameColumnCandidates = []string{"ZNAME", "ZNAME2"}
+)
+
+// AccountGroup represents a named group of accounts
+type AccountGroup struct {
+	ID           int64   `json:"id,omitempty"`
+	Name         string  `json:"name"`
+	AccountCount int     `json:"account_count"`
+	AccountIDs   []int64 `json:"account_ids"`
+}
+
+// AccountGroupsReport represents the account groups found in the database
+type AccountGroupsReport struct {
+	GroupEntity string         `json:"group_entity,omitempty"` // Core Data entity the groups were read from
+	GroupColumn string         `json:"group_column,omitempty"` // Account column that links an account to its group
+	Note        string         `json:"note,omitempty"`
+	Groups      []AccountGroup `json:"groups"`
+}
+
+// GroupNetWorth represents the combined balances of one account group
+type GroupNetWorth struct {
+	Group        string             `json:"group"`
+	NetWorth     float64            `json:"net_worth" money:"amount"` // In the target currency when the net worth was converted
+	AccountCount int                `json:"account_count"`
+	ByCurrency   map[string]float64 `json:"by_currency" money:"by_currency"`
+	AccountIDs   []int64            `json:"account_ids"`
+}
+
+// GetAccountGroups lists the account groups stored in the database with their accounts, sorted
+// by name; accounts outside every group are left out
+// Databases without account groups return an empty list with a note rather than an error
+func (db *DB) GetAccountGroups(ctx context.Context) (*AccountGroupsReport, error) {
+	report := &AccountGroupsReport{Groups: []AccountGroup{}}
+
+	entity, entityName, err := db.entityLike(ctx, "%AccountGroup%")
+	if err != nil {
+		return nil, err
+	}
+	if entityName == "" {
+		report.Note = "This database does not store account groups; pass account_groups to calculate_net_worth to group accounts yourself."
+		return report, nil
+	}
+	report.GroupEntity = entityName
+
+	columns, err := db.tableColumns(ctx, "ZSYNCOBJECT")
+	if err != nil {
+		return nil, err
+	}
+	groupColumn := firstColumn(columns, accountGroupColumnCandidates...)
+	if groupColumn == "" {
+		report.Note = fmt.Sprintf("Accounts have no column linking them to the %s entity, so their groups cannot be read.", entityName)
+		return report, nil
+	}
+	report.GroupColumn = groupColumn
+	nameExpr := "NULL"
+	if column := firstColumn(columns, accountGroupNameColumnCandidates...); column != "" {
+		nameExpr = "g." + column
+	}
+
+	query := fmt.Sprintf(`
+		SELECT g.Z_PK, %s, a.Z_PK
+		FROM ZSYNCOBJECT g
+		LEFT JOIN ZSYNCOBJECT a ON a.%s = g.Z_PK AND a.Z_ENT IN (10, 11, 12, 13, 15, 16)
+		WHERE g.Z_ENT = ?
+		ORDER BY g.Z_PK, a.Z_PK
+	`, nameExpr, groupColumn)
+
+	groups := make(map[int64]*AccountGroup)
+	err = db.queryEach(ctx, "GetAccountGroups", query, []any{entity}, func(rows *sql.Rows) error {
+		var groupID int64
+		var name sql.NullString
+		var accountID sql.NullInt64
+		if err := rows.Scan(&groupID, &name, &accountID); err != nil {
+			return fmt.Errorf("failed to scan account group: %w", err)
+		}
+		group := groups[groupID]
+		if group == nil {
+			group = &AccountGroup{ID: groupID, Name: name.String, AccountIDs: []int64{}}
+			if group.Name == "" {
+				group.Name = fmt.Sprintf("Group %d", groupID)
+			}
+			groups[groupID] = group
+		}
+		if accountID.Valid {
+			group.AccountIDs = append(group.AccountIDs, accountID.Int64)
+			group.AccountCount++
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query account groups: %w", err)
+	}
+
+	for _, group := range groups {
+		report.Groups = append(report.Groups, *group)
+	}
+	sort.Slice(report.Groups, func(i, j int) bool {
+		if report.Groups[i].Name != report.Groups[j].Name {
+			return report.Groups[i].Name < report.Groups[j].Name
+		}
+		return report.Groups[i].ID < report.Groups[j].ID
+	})
+
+	return report, nil
+}
+
+// accountGroupNames maps account IDs to the name of their stored group
+func (db *DB) accountGroupNames(ctx context.Context) (map[int64]string, error) {
+	report, err := db.GetAccountGroups(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make(map[int64]string)
+	for _, group := range report.Groups {
+		for _, id := range group.AccountIDs {
+			names[id] = group.Name
+		}
+	}
+	return names, nil
+}
+
+// assignAccountGroups fills the Group field of accounts from the stored groups
+func (db *DB) assignAccountGroups(ctx context.Context, accounts []Account) error {
+	names, err := db.accountGroupNames(ctx)
+	if err != nil {
+		return err
+	}
+	for i := range accounts {
+		accounts[i].Group = names[accounts[i].ID]
+	}
+	return nil
+}
+
+// BreakDownNetWorthByGroup splits a calculated net worth by account group into ByGroup
+// grouping maps group names to account IDs and, when not empty, replaces the stored groups; an
+// account may belong to only one group. Accounts outside every group are reported as "Ungrouped"
+// A converted net worth is broken down with the exchange rates it was converted at
+func (db *DB) BreakDownNetWorthByGroup(ctx context.Context, netWorth *NetWorth, grouping map[string][]int64) error {
+	groupOf := make(map[int64]string)
+	if len(grouping) > 0 {
+		netWorth.GroupSource = AccountGroupSourceClient
+		for name, ids := range grouping {
+			name = strings.TrimSpace(name)
+			if name == "" {
+				return invalidArgumentf("account group names must not be empty")
+			}
+			for _, id := range ids {
+				if other, ok := groupOf[id]; ok && other != name {
+					return invalidArgumentf("account %d is in both the %q and %q groups", id, other, name)
+				}
+				groupOf[id] = name
+			}
+		}
+	} else {
+		netWorth.GroupSource = AccountGroupSourceDatabase
+		names, err := db.accountGroupNames(ctx)
+		if err != nil {
+			return err
+		}
+		if len(names) == 0 {
+			netWorth.Warnings = append(netWorth.Warnings, "This database does not store account groups; pass account_groups to group accounts yourself.")
+		}
+		groupOf = names
+	}
+
+	groups := make(map[string]*GroupNetWorth)
+	for _, acc := range netWorth.Accounts {
+		name := groupOf[acc.ID]
+		if name == "" {
+			name = ungroupedAccounts
+		}
+		if groups[name] == nil {
+			groups[name] = &GroupNetWorth{
+				Group:      name,
+				ByCurrency: make(map[string]float64),
+				AccountIDs: []int64{},
+			}
+		}
+
+		group := groups[name]
+		balance := acc.Balance
+		if rate, ok := netWorth.ExchangeRates[strings.ToUpper(acc.Currency)]; ok {
+			balance *= rate
+		}
+		group.NetWorth += balance
+		group.AccountCount++
+		group.AccountIDs = append(group.AccountIDs, acc.ID)
+		if acc.Currency != "" {
+			group.ByCurrency[acc.Currency] += acc.Balance
+		}
+	}
+
+	netWorth.ByGroup = make([]GroupNetWorth, 0, len(groups))
+	for _, group := range groups {
+		netWorth.ByGroup = append(netWorth.ByGroup, *group)
+	}
+	sort.Slice(netWorth.ByGroup, func(i, j int) bool {
+		return netWorth.ByGroup[i].Group < netWorth.ByGroup[j].Group
+	})
+
+	return nil
+}
@@ -0,0 +1,180 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+)
+
+const (
+	defaultAnomalyStdDevThreshold   = 2.0
+	defaultAnomalyTrailingThreshold = 50.0
+	// anomalyTrailingMonths is the number of preceding months averaged for the trailing baseline
+	anomalyTrailingMonths = 3
+	// minAnomalyBaselineMonths is the number of other months a category needs before its
+	// standard deviation is trusted
+	minAnomalyBaselineMonths = 3
+)
+
+const (
+	AnomalyReasonStdDev   = "std_dev"
+	AnomalyReasonTrailing = "trailing_average"
+)
+
+// SpendingAnomaly represents a month in which a category's spending was unusually high
+type SpendingAnomaly struct {
+	CategoryName     string   `json:"category_name"`
+	Period           string   `json:"period"`                   // YYYY-MM
+	Expected         float64  `json:"expected" money:"amount"`  // Mean monthly spending of the category in the other months
+	Actual           float64  `json:"actual" money:"amount"`    // Spending in the period
+	Deviation        float64  `json:"deviation" money:"amount"` // Actual minus expected
+	DeviationPercent float64  `json:"deviation_percent"`        // Relative to expected (0 when expected is 0)
+	StdDevs          *float64 `json:"std_devs,omitempty"`       // Standard deviations above expected, when the baseline varies
+	TrailingAverage  *float64 `json:"trailing_average,omitempty" money:"amount"`
+	Reasons          []string `json:"reasons"` // "std_dev" and/or "trailing_average"
+}
+
+// SpendingAnomalyReport represents the result of a spending anomaly scan
+type SpendingAnomalyReport struct {
+	Months               int               `json:"months"`
+	Window               string            `json:"window,omitempty"` // "YYYY-MM to YYYY-MM"
+	StdDevThreshold      float64           `json:"std_dev_threshold"`
+	TrailingThresholdPct float64           `json:"trailing_threshold_pct"`
+	AnomalyCount         int               `json:"anomaly_count"`
+	Anomalies            []SpendingAnomaly `json:"anomalies"`
+}
+
+// DetectSpendingAnomalies flags category-months whose spending is more than stdDevThreshold
+// standard deviations above the category's mean over the other months, or more than
+// trailingThresholdPct percent above its average over the preceding 3 months
+// months: number of months to scan (0 = all historical data); months without spending count as 0
+func (db *DB) DetectSpendingAnomalies(ctx context.Context, months int, stdDevThreshold float64, trailingThresholdPct float64) (*SpendingAnomalyReport, error) {
+	if stdDevThreshold <= 0 {
+		stdDevThreshold = defaultAnomalyStdDevThreshold
+	}
+	if trailingThresholdPct <= 0 {
+		trailingThresholdPct = defaultAnomalyTrailingThreshold
+	}
+
+	spendingData, err := db.GetSpendingData(ctx, months, false, nil, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get spending data: %w", err)
+	}
+
+	report := &SpendingAnomalyReport{
+		Months:               months,
+		StdDevThreshold:      stdDevThreshold,
+		TrailingThresholdPct: trailingThresholdPct,
+		Anomalies:            []SpendingAnomaly{},
+	}
+
+	var firstMonth, lastMonth string
+	monthly := make(map[string]map[string]float64) // category -> month -> total
+	for _, s := range spendingData {
+		if s.Month == "" {
+			continue
+		}
+		if firstMonth == "" || s.Month < firstMonth {
+			firstMonth = s.Month
+		}
+		if lastMonth == "" || s.Month > lastMonth {
+			lastMonth = s.Month
+		}
+		if monthly[s.CategoryName] == nil {
+			monthly[s.CategoryName] = make(map[string]float64)
+		}
+		monthly[s.CategoryName][s.Month] += s.Amount
+	}
+
+	window := monthSpan(firstMonth, lastMonth)
+	if len(window) == 0 {
+		return report, nil
+	}
+	report.Window = fmt.Sprintf("%s to %s", window[0], window[len(window)-1])
+
+	for _, category := range sortedCurrencyKeys(monthly) {
+		totals := make([]float64, len(window))
+		sum := 0.0
+		for i, month := range window {
+			totals[i] = monthly[category][month]
+			sum += totals[i]
+		}
+
+		for i, actual := range totals {
+			if actual <= 0 {
+				continue
+			}
+
+			// Baseline over the other months so a spike does not hide itself
+			var stdDevs *float64
+			expected := 0.0
+			others := len(totals) - 1
+			if others > 0 {
+				expected = (sum - actual) / float64(others)
+			}
+			if others >= minAnomalyBaselineMonths {
+				variance := 0.0
+				for j, total := range totals {
+					if j != i {
+						variance += (total - expected) * (total - expected)
+					}
+				}
+				stdDev := math.Sqrt(variance / float64(others))
+				if stdDev > 0 {
+					deviations := (actual - expected) / stdDev
+					stdDevs = &deviations
+				}
+			}
+
+			var trailingAverage *float64
+			if i >= anomalyTrailingMonths {
+				trailing := 0.0
+				for _, total := range totals[i-anomalyTrailingMonths : i] {
+					trailing += total
+				}
+				trailing /= anomalyTrailingMonths
+				trailingAverage = &trailing
+			}
+
+			var reasons []string
+			if stdDevs != nil && *stdDevs > stdDevThreshold {
+				reasons = append(reasons, AnomalyReasonStdDev)
+			}
+			if trailingAverage != nil && actual > *trailingAverage*(1+trailingThresholdPct/100) {
+				reasons = append(reasons, AnomalyReasonTrailing)
+			}
+			if len(reasons) == 0 {
+				continue
+			}
+
+			anomaly := SpendingAnomaly{
+				CategoryName:    category,
+				Period:          window[i],
+				Expected:        expected,
+				Actual:          actual,
+				Deviation:       actual - expected,
+				StdDevs:         stdDevs,
+				TrailingAverage: trailingAverage,
+				Reasons:         reasons,
+			}
+			if expected > 0 {
+				anomaly.DeviationPercent = (anomaly.Deviation / expected) * 100
+			}
+			report.Anomalies = append(report.Anomalies, anomaly)
+		}
+	}
+
+	sort.Slice(report.Anomalies, func(i, j int) bool {
+		if report.Anomalies[i].Deviation != report.Anomalies[j].Deviation {
+			return report.Anomalies[i].Deviation > report.Anomalies[j].Deviation
+		}
+		if report.Anomalies[i].Period != report.Anomalies[j].Period {
+			return report.Anomalies[i].Period < report.Anomalies[j].Period
+		}
+		return report.Anomalies[i].CategoryName < report.Anomalies[j].CategoryName
+	})
+	report.AnomalyCount = len(report.Anomalies)
+
+	return report, nil
+}
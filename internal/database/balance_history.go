@@ -0,0 +1,197 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// BalancePoint represents an account balance at the end of a month
+type BalancePoint struct {
+	Month   string  `json:"month"`                  // YYYY-MM format
+	Balance float64 `json:"balance" money:"amount"` // Running balance at the end of the month
+	Change  float64 `json:"change" money:"amount"`  // Net change during the month
+}
+
+// AccountBalanceHistory represents the month-end balances of one account
+type AccountBalanceHistory struct {
+	AccountID      int64          `json:"account_id"`
+	AccountName    string         `json:"account_name"`
+	Currency       string         `json:"currency"`
+	OpeningBalance float64        `json:"opening_balance" money:"amount"`
+	CurrentBalance float64        `json:"current_balance" money:"amount"`
+	Months         int            `json:"months"`
+	Points         []BalancePoint `json:"points"`
+}
+
+// GetAccountBalanceHistory walks an account's transactions (including transfers) in date order
+// from its opening balance and returns the running balance at each month-end
+// months: number of month-ends to return, ending at the month of the account's latest
+// transaction (0 = every month since the first transaction); months without activity carry
+// the previous balance forward
+func (db *DB) GetAccountBalanceHistory(ctx context.Context, accountID int64, months int) (*AccountBalanceHistory, error) {
+	var name sql.NullString
+	var openingBalance sql.NullFloat64
+	var currency sql.NullString
+	err := db.runQuery(ctx, "GetAccountBalanceHistory account", func() error {
+		return db.reader().QueryRowContext(ctx, `
+			SELECT ZNAME, ZOPENINGBALANCE, ZCURRENCYNAME
+			FROM ZSYNCOBJECT
+			WHERE Z_ENT IN (10, 11, 12, 13, 15, 16) AND Z_PK = ?
+		`, accountID).Scan(&name, &openingBalance, &currency)
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, notFoundf("account with ID %d not found", accountID)
+		}
+		return nil, fmt.Errorf("failed to query account: %w", err)
+	}
+
+	history := &AccountBalanceHistory{
+		AccountID:   accountID,
+		AccountName: name.String,
+		Currency:    db.accountCurrency(currency),
+		Months:      months,
+		Points:      []BalancePoint{},
+	}
+	if openingBalance.Valid {
+		history.OpeningBalance = openingBalance.Float64
+	}
+
+	movementsQuery, err := db.accountMovementsQuery(ctx)
+	if err != nil {
+		return nil, err
+	}
+	query := `SELECT amount, date FROM (` + movementsQuery + `) WHERE account_id = ? AND date IS NOT NULL ORDER BY date, transaction_id`
+
+	// Per-month net change, keyed by YYYY-MM
+	changes := make(map[string]float64)
+	var first, last time.Time
+	err = db.queryEach(ctx, "GetAccountBalanceHistory movements", query, []any{accountID}, func(rows *sql.Rows) error {
+		var amount float64
+		var date float64
+		if err := rows.Scan(&amount, &date); err != nil {
+			return fmt.Errorf("failed to scan account transaction: %w", err)
+		}
+		ts := coreDataToTime(date)
+		if first.IsZero() {
+			first = ts
+		}
+		last = ts
+		changes[ts.Format(monthLayout)] += amount
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query account transactions: %w", err)
+	}
+
+	balance := history.OpeningBalance
+	if !first.IsZero() {
+		startMonth := time.Date(first.Year(), first.Month(), 1, 0, 0, 0, 0, time.UTC)
+		endMonth := time.Date(last.Year(), last.Month(), 1, 0, 0, 0, 0, time.UTC)
+		for month := startMonth; !month.After(endMonth); month = month.AddDate(0, 1, 0) {
+			key := month.Format(monthLayout)
+			balance += changes[key]
+			history.Points = append(history.Points, BalancePoint{
+				Month:   key,
+				Balance: balance,
+				Change:  changes[key],
+			})
+		}
+	}
+	history.CurrentBalance = balance
+
+	if months > 0 && len(history.Points) > months {
+		history.Points = history.Points[len(history.Points)-months:]
+	}
+
+	return history, nil
+}
+
+// AccountBalanceAsOf represents the balance of one account at the end of a day, for reconciling
+// against a bank statement
+type AccountBalanceAsOf struct {
+	AccountID           int64   `json:"account_id"`
+	AccountName         string  `json:"account_name"`
+	Currency            string  `json:"currency"`
+	Date                string  `json:"date"` // YYYY-MM-DD; transactions on this day are included
+	OpeningBalance      float64 `json:"opening_balance" money:"amount"`
+	Balance             float64 `json:"balance" money:"amount"` // Opening balance plus every transaction up to the end of Date
+	TransactionCount    int     `json:"transaction_count"`      // Transactions up to the end of Date, including transfers in and out
+	LastTransactionDate string  `json:"last_transaction_date,omitempty"`
+	CurrentBalance      float64 `json:"current_balance" money:"amount"`
+	ChangeSince         float64 `json:"change_since" money:"amount"` // Current balance - balance
+}
+
+// GetAccountBalanceAsOf returns the balance of an account at the end of date: its opening balance
+// plus every transaction (including transfers) dated on or before it
+// date: YYYY-MM-DD
+func (db *DB) GetAccountBalanceAsOf(ctx context.Context, accountID int64, date string) (*AccountBalanceAsOf, error) {
+	if date == "" {
+		return nil, invalidArgumentf("date is required: expected YYYY-MM-DD")
+	}
+	day, err := parseAsOf("date", date)
+	if err != nil {
+		return nil, err
+	}
+
+	var name sql.NullString
+	var openingBalance sql.NullFloat64
+	var currency sql.NullString
+	err = db.runQuery(ctx, "GetAccountBalanceAsOf account", func() error {
+		return db.reader().QueryRowContext(ctx, `
+			SELECT ZNAME, ZOPENINGBALANCE, ZCURRENCYNAME
+			FROM ZSYNCOBJECT
+			WHERE Z_ENT IN (10, 11, 12, 13, 15, 16) AND Z_PK = ?
+		`, accountID).Scan(&name, &openingBalance, &currency)
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, notFoundf("account with ID %d not found", accountID)
+		}
+		return nil, fmt.Errorf("failed to query account: %w", err)
+	}
+
+	movementsQuery, err := db.accountMovementsQuery(ctx)
+	if err != nil {
+		return nil, err
+	}
+	query := `
+		SELECT
+			SUM(CASE WHEN date < ? THEN amount END),
+			COUNT(DISTINCT CASE WHEN date < ? THEN transaction_id END),
+			MAX(CASE WHEN date < ? THEN date END),
+			SUM(amount)
+		FROM (` + movementsQuery + `)
+		WHERE account_id = ?`
+	end := timeToCoreData(day.AddDate(0, 0, 1))
+
+	var totalAsOf, lastDate, total sql.NullFloat64
+	var count int
+	err = db.runQuery(ctx, "GetAccountBalanceAsOf movements", func() error {
+		return db.reader().QueryRowContext(ctx, query, end, end, end, accountID).Scan(&totalAsOf, &count, &lastDate, &total)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query account transactions: %w", err)
+	}
+
+	asOf := &AccountBalanceAsOf{
+		AccountID:        accountID,
+		AccountName:      name.String,
+		Currency:         db.accountCurrency(currency),
+		Date:             day.Format(dayLayout),
+		TransactionCount: count,
+	}
+	if openingBalance.Valid {
+		asOf.OpeningBalance = openingBalance.Float64
+	}
+	if lastDate.Valid {
+		asOf.LastTransactionDate = coreDataToTime(lastDate.Float64).Format(dateTimeLayout)
+	}
+	asOf.Balance = asOf.OpeningBalance + totalAsOf.Float64
+	asOf.CurrentBalance = asOf.OpeningBalance + total.Float64
+	asOf.ChangeSince = asOf.CurrentBalance - asOf.Balance
+
+	return asOf, nil
+}
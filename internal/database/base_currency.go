@@ -0,0 +1,135 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// Where a base currency was found
+const (
+	BaseCurrencySourceSettings = "settings" // The base currency setting of the MoneyWiz file
+	BaseCurrencySourceAccounts = "accounts" // Guessed as the currency used by the most accounts
+)
+
+// MoneyWiz keeps the base currency on its settings entity; the entity and column names differ
+// between versions, so both are detected
+var (
+	settingsEntityPatterns       = []string{"%Setting%", "%Preference%"}
+	baseCurrencyColumnCandidates = []string{"ZBASECURRENCY", "ZBASECURRENCYNAME", "ZMAINCURRENCY", "ZMAINCURRENCYNAME", "ZDEFAULTCURRENCY"}
+)
+
+// Info describes the contents of a MoneyWiz database
+type Info struct {
+	Path                 string   `json:"path"`
+	BaseCurrency         string   `json:"base_currency,omitempty"`
+	BaseCurrencySource   string   `json:"base_currency_source,omitempty"` // "settings" or "accounts"
+	Currencies           []string `json:"currencies"`                     // Currencies of the accounts
+	AccountCount         int      `json:"account_count"`
+	TransactionCount     int      `json:"transaction_count"`
+	FirstTransactionDate string   `json:"first_transaction_date,omitempty"`
+	LastTransactionDate  string   `json:"last_transaction_date,omitempty"`
+}
+
+// SettingsBaseCurrency returns the base currency stored in the settings of the MoneyWiz file, or
+// "" when the database does not store one
+func (db *DB) SettingsBaseCurrency(ctx context.Context) (string, error) {
+	column, err := db.firstExistingColumn(ctx, "ZSYNCOBJECT", baseCurrencyColumnCandidates...)
+	if err != nil || column == "" {
+		return "", err
+	}
+
+	for _, pattern := range settingsEntityPatterns {
+		entity, name, err := db.entityLike(ctx, pattern)
+		if err != nil {
+			return "", err
+		}
+		if name == "" {
+			continue
+		}
+
+		var currency string
+		query := fmt.Sprintf(`
+			SELECT UPPER(TRIM(%[1]s)) FROM ZSYNCOBJECT
+			WHERE Z_ENT = ? AND TRIM(COALESCE(%[1]s, '')) != ''
+			ORDER BY Z_PK LIMIT 1`, column)
+		err = db.runQuery(ctx, "SettingsBaseCurrency", func() error {
+			return db.reader().QueryRowContext(ctx, query, entity).Scan(&currency)
+		})
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to query base currency: %w", err)
+		}
+		return currency, nil
+	}
+	return "", nil
+}
+
+// baseCurrency returns the base currency from the settings, or else the currency used by the most
+// accounts, with where it came from; both are empty when neither is known
+func (db *DB) baseCurrency(ctx context.Context, accounts []Account) (string, string, error) {
+	currency, err := db.SettingsBaseCurrency(ctx)
+	if err != nil {
+		return "", "", err
+	}
+	if currency != "" {
+		return currency, BaseCurrencySourceSettings, nil
+	}
+	if currency = primaryAccountCurrency(accounts); currency != "" {
+		return currency, BaseCurrencySourceAccounts, nil
+	}
+	return "", "", nil
+}
+
+// GetInfo describes the database: its base currency, account currencies, and the number and date
+// range of its transactions
+func (db *DB) GetInfo(ctx context.Context) (*Info, error) {
+	return inReadSnapshot(ctx, db, func(snap *DB) (*Info, error) {
+		return snap.getInfo(ctx)
+	})
+}
+
+// getInfo is GetInfo without the read snapshot
+func (db *DB) getInfo(ctx context.Context) (*Info, error) {
+	accounts, err := db.GetAccounts(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get accounts: %w", err)
+	}
+
+	info := &Info{
+		Path:         db.path,
+		AccountCount: len(accounts),
+	}
+	if info.BaseCurrency, info.BaseCurrencySource, err = db.baseCurrency(ctx, accounts); err != nil {
+		return nil, err
+	}
+	currencySet := make(map[string]struct{})
+	for _, acc := range accounts {
+		if acc.Currency != "" {
+			currencySet[acc.Currency] = struct{}{}
+		}
+	}
+	info.Currencies = sortedCurrencyKeys(currencySet)
+
+	var first, last sql.NullFloat64
+	err = db.runQuery(ctx, "getInfo", func() error {
+		return db.reader().QueryRowContext(ctx, `
+			SELECT COUNT(*), MIN(ZDATE1), MAX(ZDATE1) FROM ZSYNCOBJECT
+			WHERE Z_ENT IN (37, 45, 46, 47, 43) AND ZAMOUNT1 IS NOT NULL
+		`).Scan(&info.TransactionCount, &first, &last)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query transaction range: %w", err)
+	}
+	if first.Valid {
+		info.FirstTransactionDate = coreDataToTime(first.Float64).Format(dayLayout)
+	}
+	if last.Valid {
+		info.LastTransactionDate = coreDataToTime(last.Float64).Format(dayLayout)
+	}
+
+	return info, nil
+}
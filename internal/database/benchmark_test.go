@@ -0,0 +1,57 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+)
+
+// benchmarkTransactionRows is how many extra transactions the benchmark database holds, so each
+// list_transactions page is read from a realistically sized table
+const benchmarkTransactionRows = 5000
+
+// BenchmarkGetTransactionsConcurrent runs list_transactions pages from parallel callers against
+// pools of different sizes; compare ns/op across the sub-benchmarks, e.g. with
+// go test -bench GetTransactionsConcurrent -cpu 8 ./internal/database
+func BenchmarkGetTransactionsConcurrent(b *testing.B) {
+	seed := newFixtureDBWithExtraRows(b, func(conn *sql.DB) {
+		mustExecSQL(b, conn, `
+			WITH RECURSIVE n(i) AS (SELECT 1 UNION ALL SELECT i + 1 FROM n WHERE i < ?)
+			INSERT INTO ZSYNCOBJECT (Z_PK, Z_ENT, ZAMOUNT1, ZDATE1, ZDESC2, ZACCOUNT2, ZACCOUNT)
+			SELECT 10000 + i, 37, -1 - (i % 200), ? + i * 3600, 'Purchase ' || (i % 50), 1, 0 FROM n;
+		`, benchmarkTransactionRows, coreDataSeconds(b, "2022-01-01"))
+		mustExecSQL(b, conn, `
+			INSERT INTO ZCATEGORYASSIGMENT (ZTRANSACTION, ZCATEGORY)
+			SELECT Z_PK, 102 FROM ZSYNCOBJECT WHERE Z_PK > 10000;
+		`)
+	})
+	path := seed.Path()
+	if err := seed.Close(); err != nil {
+		b.Fatalf("close seed db: %v", err)
+	}
+
+	for _, maxOpen := range []int{1, 2, DefaultMaxOpenConns, 16} {
+		b.Run(fmt.Sprintf("max_open_conns=%d", maxOpen), func(b *testing.B) {
+			db, err := NewDB(path, Options{MaxOpenConns: maxOpen})
+			if err != nil {
+				b.Fatalf("NewDB: %v", err)
+			}
+			defer db.Close()
+			ctx := context.Background()
+
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				page := 0
+				for pb.Next() {
+					filter := TransactionFilter{Limit: 100, Offset: (page % 20) * 100}
+					if _, err := db.GetTransactions(ctx, filter); err != nil {
+						b.Errorf("GetTransactions: %v", err)
+						return
+					}
+					page++
+				}
+			})
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+const defaultBurnRateTrailingDays = 30
+
+// BurnRate represents the average daily spend and where the current month is heading
+type BurnRate struct {
+	AsOf                   string             `json:"as_of"` // YYYY-MM-DD; the day counts as elapsed
+	TrailingDays           int                `json:"trailing_days"`
+	TrailingStart          string             `json:"trailing_start"` // First day of the trailing window
+	TrailingSpending       float64            `json:"trailing_spending" money:"amount"`
+	AverageDailySpend      float64            `json:"average_daily_spend" money:"amount"` // Trailing spending / trailing days
+	Month                  string             `json:"month"`                              // YYYY-MM containing as_of
+	MonthToDateSpending    float64            `json:"month_to_date_spending" money:"amount"`
+	DaysInMonth            int                `json:"days_in_month"`
+	DaysElapsed            int                `json:"days_elapsed"`
+	DaysRemaining          int                `json:"days_remaining"`
+	MonthToDateDailySpend  float64            `json:"month_to_date_daily_spend" money:"amount"` // Month-to-date spending / days elapsed
+	ProjectedMonthEnd      float64            `json:"projected_month_end" money:"amount"`       // Month to date + average daily spend * days remaining
+	ProjectedAtCurrentPace float64            `json:"projected_at_current_pace" money:"amount"` // Month-to-date daily spend * days in month
+	MonthToDateByCurrency  map[string]float64 `json:"month_to_date_by_currency" money:"by_currency"`
+	MixedCurrencies        bool               `json:"mixed_currencies"`
+	Currencies             []string           `json:"currencies"`
+	CurrencyWarning        string             `json:"currency_warning,omitempty"`
+}
+
+// GetBurnRate averages daily spending over the trailingDays days up to and including asOf and
+// projects the month containing asOf to its end: the spending so far plus the trailing average
+// for every day left
+// trailingDays: days in the trailing window (0 = 30)
+// asOfDate: the YYYY-MM-DD day to measure from ("" = today); later transactions are ignored
+// Internal transfers and future-dated scheduled transactions are left out, as in the analyses
+func (db *DB) GetBurnRate(ctx context.Context, trailingDays int, asOfDate string) (*BurnRate, error) {
+	if trailingDays < 0 {
+		return nil, invalidArgumentf("trailing days must not be negative, got %d", trailingDays)
+	}
+	if trailingDays == 0 {
+		trailingDays = defaultBurnRateTrailingDays
+	}
+	asOf, err := parseAsOf("as_of date", asOfDate)
+	if err != nil {
+		return nil, err
+	}
+
+	monthStart := time.Date(asOf.Year(), asOf.Month(), 1, 0, 0, 0, 0, time.UTC)
+	trailingStart := asOf.AddDate(0, 0, -(trailingDays - 1))
+	daysInMonth := monthStart.AddDate(0, 1, -1).Day()
+
+	spending, err := db.GetSpendingData(ctx, 0, false, nil, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get spending data: %w", err)
+	}
+
+	rate := &BurnRate{
+		AsOf:                  asOf.Format(dayLayout),
+		TrailingDays:          trailingDays,
+		TrailingStart:         trailingStart.Format(dayLayout),
+		Month:                 asOf.Format(monthLayout),
+		DaysInMonth:           daysInMonth,
+		DaysElapsed:           asOf.Day(),
+		DaysRemaining:         daysInMonth - asOf.Day(),
+		MonthToDateByCurrency: make(map[string]float64),
+	}
+
+	// Dates compare as YYYY-MM-DD strings, so a transaction on as_of counts at any time of day
+	for _, s := range spending {
+		if len(s.Date) < len(dayLayout) {
+			continue
+		}
+		day := s.Date[:len(dayLayout)]
+		if day > rate.AsOf {
+			continue
+		}
+		if day >= rate.TrailingStart {
+			rate.TrailingSpending += s.Amount
+		}
+		if s.Month == rate.Month {
+			rate.MonthToDateSpending += s.Amount
+			if s.Currency != "" {
+				rate.MonthToDateByCurrency[s.Currency] += s.Amount
+			}
+		}
+	}
+
+	rate.AverageDailySpend = rate.TrailingSpending / float64(trailingDays)
+	rate.MonthToDateDailySpend = rate.MonthToDateSpending / float64(rate.DaysElapsed)
+	rate.ProjectedMonthEnd = rate.MonthToDateSpending + rate.AverageDailySpend*float64(rate.DaysRemaining)
+	rate.ProjectedAtCurrentPace = rate.MonthToDateDailySpend * float64(daysInMonth)
+
+	rate.Currencies = sortedCurrencyKeys(rate.MonthToDateByCurrency)
+	rate.MixedCurrencies = len(rate.Currencies) > 1
+	if rate.MixedCurrencies {
+		rate.CurrencyWarning = "Totals combine multiple currencies; month_to_date_by_currency splits the month so far by currency."
+	}
+
+	return rate, nil
+}
@@ -1,43 +1,390 @@
 package database
 
 import (
+	"context"
+	"database/sql"
 	"fmt"
 )
 
+const (
+	CategoryTypeIncome  = "income"
+	CategoryTypeExpense = "expense"
+	CategoryTypeBoth    = "both"
+)
+
+// categoryTypeColumn holds the MoneyWiz category kind (1 = expense, 2 = income)
+const categoryTypeColumn = "ZTYPE2"
+
+// categoryPathSeparator joins category names from the top-level parent down
+const categoryPathSeparator = " > "
+
+// categoryParentColumnCandidates lists the columns MoneyWiz versions use to link a subcategory
+// to its parent category
+var categoryParentColumnCandidates = []string{"ZPARENTCATEGORY", "ZPARENT"}
+
 // Category represents a MoneyWiz category
 type Category struct {
-	ID   int64  `json:"id"`
-	Name string `json:"name"`
+	ID       int64  `json:"id"`
+	Name     string `json:"name"`
+	Type     string `json:"type"`                // "income", "expense", or "both"
+	ParentID int64  `json:"parent_id,omitempty"` // 0 for top-level categories
+	FullPath string `json:"full_path"`           // e.g. "Food > Groceries"
 }
 
-// GetCategories retrieves all categories from the database
-func (db *DB) GetCategories() ([]Category, error) {
-	query := `
-		SELECT Z_PK, ZNAME2
-		FROM ZSYNCOBJECT
-		WHERE Z_ENT = 19 AND ZNAME2 IS NOT NULL
-		ORDER BY ZNAME2
-	`
+// categoryNode is a category's place in the hierarchy
+type categoryNode struct {
+	name     string
+	parentID int64
+}
+
+// categoryTree maps category IDs to their name and parent
+type categoryTree map[int64]categoryNode
+
+// loadCategoryTree reads the name and parent of every category; parents are all 0 when the
+// database has no parent column
+func (db *DB) loadCategoryTree(ctx context.Context) (categoryTree, error) {
+	column, err := db.firstExistingColumn(ctx, "ZSYNCOBJECT", categoryParentColumnCandidates...)
+	if err != nil {
+		return nil, err
+	}
+	parentExpr := "NULL"
+	if column != "" {
+		parentExpr = column
+	}
+
+	tree := make(categoryTree)
+	err = db.queryEach(ctx, "loadCategoryTree", fmt.Sprintf(`
+		SELECT Z_PK, ZNAME2, %s FROM ZSYNCOBJECT WHERE Z_ENT = 19 AND ZNAME2 IS NOT NULL
+	`, parentExpr), nil, func(rows *sql.Rows) error {
+		var id int64
+		var node categoryNode
+		var parentID sql.NullInt64
+		if err := rows.Scan(&id, &node.name, &parentID); err != nil {
+			return fmt.Errorf("failed to scan category hierarchy: %w", err)
+		}
+		node.parentID = parentID.Int64
+		tree[id] = node
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query category hierarchy: %w", err)
+	}
+
+	return tree, nil
+}
+
+// ancestors returns the IDs from the top-level category down to id
+// A parent that is missing from the tree ends the chain, and cycles are broken
+func (tree categoryTree) ancestors(id int64) []int64 {
+	var chain []int64
+	seen := make(map[int64]bool)
+	for current := id; current != 0 && !seen[current]; current = tree[current].parentID {
+		if _, ok := tree[current]; !ok {
+			break
+		}
+		seen[current] = true
+		chain = append([]int64{current}, chain...)
+	}
+	return chain
+}
+
+// fullPath returns the names from the top-level category down to id joined with " > "
+func (tree categoryTree) fullPath(id int64) string {
+	path := ""
+	for i, ancestor := range tree.ancestors(id) {
+		if i > 0 {
+			path += categoryPathSeparator
+		}
+		path += tree[ancestor].name
+	}
+	return path
+}
+
+// labels returns a display name for every category that no other category shares: its name,
+// or its full path when several categories have that name (common after an import or merge),
+// or the full path and ID when even the paths are the same
+// Analyses group by these names, so same-named categories stay separate
+func (tree categoryTree) labels() map[int64]string {
+	nameCount := make(map[string]int, len(tree))
+	pathCount := make(map[string]int, len(tree))
+	paths := make(map[int64]string, len(tree))
+	for id, node := range tree {
+		nameCount[node.name]++
+		paths[id] = tree.fullPath(id)
+		pathCount[paths[id]]++
+	}
+
+	labels := make(map[int64]string, len(tree))
+	for id, node := range tree {
+		switch {
+		case nameCount[node.name] == 1:
+			labels[id] = node.name
+		case pathCount[paths[id]] == 1:
+			labels[id] = paths[id]
+		default:
+			labels[id] = fmt.Sprintf("%s (ID %d)", paths[id], id)
+		}
+	}
+	return labels
+}
+
+// categoryLabels returns the labels of every category in the database
+func (db *DB) categoryLabels(ctx context.Context) (map[int64]string, error) {
+	tree, err := db.loadCategoryTree(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return tree.labels(), nil
+}
 
-	rows, err := db.conn.Query(query)
+// rollUpCategories reassigns spending rows of subcategories to their top-level category
+func (db *DB) rollUpCategories(ctx context.Context, spending []SpendingData) error {
+	tree, err := db.loadCategoryTree(ctx)
+	if err != nil {
+		return err
+	}
+	labels := tree.labels()
+	for i := range spending {
+		chain := tree.ancestors(spending[i].CategoryID)
+		if len(chain) > 1 {
+			spending[i].CategoryID = chain[0]
+			spending[i].CategoryName = labels[chain[0]]
+		}
+	}
+	return nil
+}
+
+// categoryActivity is a category with its stored MoneyWiz type and how many income and expense
+// transactions are assigned to it, from which categoryTypeFor and holdsExpenses classify it
+type categoryActivity struct {
+	id         int64
+	name       sql.NullString
+	storedType sql.NullInt64
+	positive   int
+	negative   int
+}
+
+// loadCategoryActivity reads every category, ordered by name, with its stored type (NULL when
+// the database has no ZTYPE2) and the signs of its assigned transactions
+func (db *DB) loadCategoryActivity(ctx context.Context) ([]categoryActivity, error) {
+	column, err := db.firstExistingColumn(ctx, "ZSYNCOBJECT", categoryTypeColumn)
+	if err != nil {
+		return nil, err
+	}
+	typeExpr := "NULL"
+	if column != "" {
+		typeExpr = "c." + column
+	}
+
+	query := fmt.Sprintf(`
+		SELECT c.Z_PK, c.ZNAME2, %s,
+			COALESCE(SUM(CASE WHEN t.ZAMOUNT1 > 0 THEN 1 ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN t.ZAMOUNT1 < 0 THEN 1 ELSE 0 END), 0)
+		FROM ZSYNCOBJECT c
+		LEFT JOIN ZCATEGORYASSIGMENT ca ON ca.ZCATEGORY = c.Z_PK
+		LEFT JOIN ZSYNCOBJECT t ON t.Z_PK = ca.ZTRANSACTION AND t.Z_ENT IN (37, 45, 46, 47)
+		WHERE c.Z_ENT = 19
+		GROUP BY c.Z_PK
+		ORDER BY c.ZNAME2
+	`, typeExpr)
+
+	var activity []categoryActivity
+	err = db.queryEach(ctx, "loadCategoryActivity", query, nil, func(rows *sql.Rows) error {
+		var a categoryActivity
+		if err := rows.Scan(&a.id, &a.name, &a.storedType, &a.positive, &a.negative); err != nil {
+			return fmt.Errorf("failed to scan category: %w", err)
+		}
+		activity = append(activity, a)
+		return nil
+	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to query categories: %w", err)
 	}
-	defer rows.Close()
+
+	return activity, nil
+}
+
+// GetCategories retrieves all categories from the database
+// categoryType: "income" or "expense" keeps categories of that type plus those used for both;
+// "" or "both" returns every category
+// The type comes from ZTYPE2 when present; otherwise it is inferred from the sign of the
+// transactions assigned to the category ("both" when mixed or unused)
+func (db *DB) GetCategories(ctx context.Context, categoryType string) ([]Category, error) {
+	switch categoryType {
+	case "", CategoryTypeBoth, CategoryTypeIncome, CategoryTypeExpense:
+	default:
+		return nil, invalidArgumentf("invalid category type %q: expected income, expense, or both", categoryType)
+	}
+
+	activity, err := db.loadCategoryActivity(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tree, err := db.loadCategoryTree(ctx)
+	if err != nil {
+		return nil, err
+	}
 
 	var categories []Category
-	for rows.Next() {
-		var cat Category
-		err := rows.Scan(&cat.ID, &cat.Name)
-		if err != nil {
-			return nil, fmt.Errorf("failed to scan category: %w", err)
+	for _, a := range activity {
+		if !a.name.Valid {
+			continue
+		}
+		cat := Category{
+			ID:       a.id,
+			Name:     a.name.String,
+			Type:     categoryTypeFor(a.storedType, a.positive, a.negative),
+			ParentID: tree[a.id].parentID,
+			FullPath: tree.fullPath(a.id),
+		}
+
+		if categoryType == CategoryTypeIncome || categoryType == CategoryTypeExpense {
+			if cat.Type != categoryType && cat.Type != CategoryTypeBoth {
+				continue
+			}
 		}
 		categories = append(categories, cat)
 	}
 
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating categories: %w", err)
+	return categories, nil
+}
+
+// expenseCategoryIDs returns the categories that hold expenses, as holdsExpenses decides
+func (db *DB) expenseCategoryIDs(ctx context.Context) (map[int64]bool, error) {
+	activity, err := db.loadCategoryActivity(ctx)
+	if err != nil {
+		return nil, err
 	}
 
-	return categories, nil
+	expense := make(map[int64]bool, len(activity))
+	for _, a := range activity {
+		expense[a.id] = holdsExpenses(a.storedType, a.positive, a.negative)
+	}
+	return expense, nil
+}
+
+// categoryTypeFor maps the stored MoneyWiz type, falling back to the sign of assigned amounts
+func categoryTypeFor(storedType sql.NullInt64, positive, negative int) string {
+	if storedType.Valid {
+		switch storedType.Int64 {
+		case 1:
+			return CategoryTypeExpense
+		case 2:
+			return CategoryTypeIncome
+		}
+	}
+
+	switch {
+	case positive > 0 && negative == 0:
+		return CategoryTypeIncome
+	case negative > 0 && positive == 0:
+		return CategoryTypeExpense
+	default:
+		return CategoryTypeBoth
+	}
+}
+
+// holdsExpenses reports whether a category holds expenses, from the same inputs as
+// categoryTypeFor: the stored MoneyWiz type, or without one at least as many expenses as
+// positive amounts. Unlike categoryTypeFor, a few refunds do not turn an expense category into
+// "both", so money back in it can be told apart from income
+func holdsExpenses(storedType sql.NullInt64, positive, negative int) bool {
+	if storedType.Valid {
+		switch storedType.Int64 {
+		case 1:
+			return true
+		case 2:
+			return false
+		}
+	}
+	return negative > 0 && negative >= positive
+}
+
+// CategoryTotals represents a category with its activity over a period
+type CategoryTotals struct {
+	Category
+	TotalSpending    float64 `json:"total_spending" money:"amount"`
+	TotalIncome      float64 `json:"total_income" money:"amount"`
+	TransactionCount int     `json:"transaction_count"`
+	LastUsed         string  `json:"last_used,omitempty"` // Date of the latest transaction in the period
+}
+
+// CategoriesWithTotals represents every category with its spending and income totals
+type CategoriesWithTotals struct {
+	Months          int              `json:"months"`
+	CategoryCount   int              `json:"category_count"`
+	UnusedCount     int              `json:"unused_count"` // Categories without transactions in the period
+	MixedCurrencies bool             `json:"mixed_currencies"`
+	Currencies      []string         `json:"currencies"`
+	CurrencyWarning string           `json:"currency_warning,omitempty"`
+	Categories      []CategoryTotals `json:"categories"`
+}
+
+// GetCategoriesWithTotals returns the categories of GetCategories, each with its spending,
+// income, transaction count and last-used date over the last months (0 = all historical data)
+// Unused categories are kept with zero totals so they can be found and cleaned up
+// Internal transfers and future-dated scheduled transactions are left out, as in the analyses
+func (db *DB) GetCategoriesWithTotals(ctx context.Context, months int, categoryType string) (*CategoriesWithTotals, error) {
+	categories, err := db.GetCategories(ctx, categoryType)
+	if err != nil {
+		return nil, err
+	}
+	spending, err := db.GetSpendingData(ctx, months, false, nil, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get spending data: %w", err)
+	}
+	income, err := db.GetIncomeData(ctx, months, false, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get income data: %w", err)
+	}
+
+	byID := make(map[int64]*CategoryTotals, len(categories))
+	result := &CategoriesWithTotals{
+		Months:     months,
+		Categories: make([]CategoryTotals, len(categories)),
+	}
+	for i, cat := range categories {
+		result.Categories[i] = CategoryTotals{Category: cat}
+		byID[cat.ID] = &result.Categories[i]
+	}
+
+	currencySet := make(map[string]struct{})
+	record := func(categoryID int64, date, currency string) *CategoryTotals {
+		totals := byID[categoryID]
+		if totals == nil {
+			return nil
+		}
+		totals.TransactionCount++
+		if date > totals.LastUsed {
+			totals.LastUsed = date
+		}
+		if currency != "" {
+			currencySet[currency] = struct{}{}
+		}
+		return totals
+	}
+	for _, s := range spending {
+		if totals := record(s.CategoryID, s.Date, s.Currency); totals != nil {
+			totals.TotalSpending += s.Amount
+		}
+	}
+	for _, inc := range income {
+		if totals := record(inc.CategoryID, inc.Date, inc.Currency); totals != nil {
+			totals.TotalIncome += inc.Amount
+		}
+	}
+
+	result.CategoryCount = len(result.Categories)
+	for _, totals := range result.Categories {
+		if totals.TransactionCount == 0 {
+			result.UnusedCount++
+		}
+	}
+	result.Currencies = sortedCurrencyKeys(currencySet)
+	result.MixedCurrencies = len(result.Currencies) > 1
+	if result.MixedCurrencies {
+		result.CurrencyWarning = "Totals combine multiple currencies; each category's totals may mix currencies."
+	}
+
+	return result, nil
 }
@@ -0,0 +1,59 @@
+package database
+
+import (
+	"strconv"
+	"strings"
+)
+
+// CategoryAliases maps categories to the display name their spending is reported under, so
+// several categories can be merged in analysis output without changing the database
+// Keys are category IDs written as numbers (e.g. "104") or category names, matched without regard
+// to case; an ID key wins over a name key for the same category
+type CategoryAliases map[string]string
+
+// validate rejects blank keys and display names
+func (aliases CategoryAliases) validate() error {
+	for key, name := range aliases {
+		if strings.TrimSpace(key) == "" {
+			return invalidArgumentf("category alias keys must not be empty")
+		}
+		if strings.TrimSpace(name) == "" {
+			return invalidArgumentf("category alias for %q must not be empty", key)
+		}
+	}
+	return nil
+}
+
+// apply renames the category of every spending row an alias matches
+// With rollup it runs after the rollup, so aliases match top-level categories
+func (aliases CategoryAliases) apply(spending []SpendingData) error {
+	if len(aliases) == 0 {
+		return nil
+	}
+	if err := aliases.validate(); err != nil {
+		return err
+	}
+
+	byID := make(map[int64]string)
+	byName := make(map[string]string)
+	for key, name := range aliases {
+		key = strings.TrimSpace(key)
+		name = strings.TrimSpace(name)
+		if id, err := strconv.ParseInt(key, 10, 64); err == nil {
+			byID[id] = name
+			continue
+		}
+		byName[strings.ToLower(key)] = name
+	}
+
+	for i := range spending {
+		if name, ok := byID[spending[i].CategoryID]; ok && spending[i].CategoryID != 0 {
+			spending[i].CategoryName = name
+			continue
+		}
+		if name, ok := byName[strings.ToLower(strings.TrimSpace(spending[i].CategoryName))]; ok {
+			spending[i].CategoryName = name
+		}
+	}
+	return nil
+}
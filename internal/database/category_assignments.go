@@ -0,0 +1,148 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+)
+
+// maxDiagnosticExamples caps the transaction IDs listed for each problem a diagnostic finds
+const maxDiagnosticExamples = 20
+
+// CategoryAssignmentDiagnostics counts how income and expense transactions are linked to
+// categories through ZCATEGORYASSIGMENT, to tell genuinely uncategorized transactions apart from
+// assignments the category joins cannot follow
+type CategoryAssignmentDiagnostics struct {
+	Months                      int      `json:"months"`
+	TransactionCount            int      `json:"transaction_count"`
+	Uncategorized               int      `json:"uncategorized"`           // No assignment to an existing category
+	UncategorizedMovements      int      `json:"uncategorized_movements"` // Of those, transfers and cash withdrawals, which analyses label by movement type
+	SingleCategory              int      `json:"single_category"`
+	MultipleCategories          int      `json:"multiple_categories"` // Split transactions
+	MaxCategories               int      `json:"max_categories"`      // Most categories assigned to one transaction
+	BrokenAssignments           int      `json:"broken_assignments"`  // Transactions whose assignments all point at rows that are not categories; they show as uncategorized
+	BrokenTransactionIDs        []int64  `json:"broken_transaction_ids"`
+	OrphanAssignments           int      `json:"orphan_assignments"`            // Assignment rows whose transaction does not exist
+	SplitAmountColumn           string   `json:"split_amount_column,omitempty"` // Column holding split portions; empty when the schema has none
+	SplitAmountMismatches       int      `json:"split_amount_mismatches"`       // Split transactions whose portions, as the analyses count them, do not add up to the amount
+	SplitMismatchTransactionIDs []int64  `json:"split_mismatch_transaction_ids"`
+	Notes                       []string `json:"notes"`
+}
+
+// DiagnoseCategoryAssignments counts the income and expense transactions with zero, one and
+// several category assignments, and finds assignments the analyses cannot use: ones pointing at
+// rows that are not categories, ones whose transaction is gone, and splits whose portions do not
+// add up to the transaction amount
+// months: number of months to look back (0 = all data); scheduled transactions dated in the
+// future are left out, as in the analyses
+func (db *DB) DiagnoseCategoryAssignments(ctx context.Context, months int) (*CategoryAssignmentDiagnostics, error) {
+	diagnostics := &CategoryAssignmentDiagnostics{
+		Months:                      months,
+		BrokenTransactionIDs:        []int64{},
+		SplitMismatchTransactionIDs: []int64{},
+		Notes:                       []string{},
+	}
+
+	columns, err := db.tableColumns(ctx, "ZCATEGORYASSIGMENT")
+	if err != nil {
+		return nil, err
+	}
+	if !columns["ZTRANSACTION"] || !columns["ZCATEGORY"] {
+		diagnostics.Notes = append(diagnostics.Notes, "The database has no ZCATEGORYASSIGMENT table with ZTRANSACTION and ZCATEGORY columns, so every transaction shows as uncategorized.")
+		return diagnostics, nil
+	}
+	diagnostics.SplitAmountColumn = firstColumn(columns, categoryAssignmentAmountColumnCandidates...)
+
+	cutoff, err := db.monthsCutoff(ctx, months, false)
+	if err != nil {
+		return nil, err
+	}
+
+	portions := "NULL"
+	if diagnostics.SplitAmountColumn != "" {
+		// The portion each category is credited with, as in categoryAssignmentSelect
+		portions = fmt.Sprintf("SUM(CASE WHEN c.Z_PK IS NULL THEN NULL WHEN ca.%[1]s IS NOT NULL AND ca.%[1]s != 0 THEN ca.%[1]s ELSE t.ZAMOUNT1 END)", diagnostics.SplitAmountColumn)
+	}
+	query := fmt.Sprintf(`
+		SELECT t.Z_PK, t.ZAMOUNT1, t.ZDESC2, COUNT(ca.ZTRANSACTION), COUNT(DISTINCT c.Z_PK), %s
+		FROM ZSYNCOBJECT t
+		LEFT JOIN ZCATEGORYASSIGMENT ca ON ca.ZTRANSACTION = t.Z_PK
+		LEFT JOIN ZSYNCOBJECT c ON c.Z_PK = ca.ZCATEGORY AND c.Z_ENT = 19
+		WHERE t.Z_ENT IN (%s)
+		AND t.ZAMOUNT1 IS NOT NULL
+		AND t.ZDATE1 IS NOT NULL
+		AND t.ZDATE1 >= ?
+		AND t.ZDATE1 <= ?
+		GROUP BY t.Z_PK
+		ORDER BY t.Z_PK
+	`, portions, transactionEntities(false))
+
+	err = db.queryEach(ctx, "DiagnoseCategoryAssignments", query, []any{cutoff, scheduledCutoff(false)}, func(rows *sql.Rows) error {
+		var id int64
+		var amount float64
+		var description sql.NullString
+		var assignments, categories int
+		var portionTotal sql.NullFloat64
+		if err := rows.Scan(&id, &amount, &description, &assignments, &categories, &portionTotal); err != nil {
+			return fmt.Errorf("failed to scan category assignment: %w", err)
+		}
+
+		diagnostics.TransactionCount++
+		if categories > diagnostics.MaxCategories {
+			diagnostics.MaxCategories = categories
+		}
+		switch {
+		case categories == 0:
+			diagnostics.Uncategorized++
+			if isInternalMovement(detectMovementType(description.String)) {
+				diagnostics.UncategorizedMovements++
+			}
+			if assignments > 0 {
+				diagnostics.BrokenAssignments++
+				if len(diagnostics.BrokenTransactionIDs) < maxDiagnosticExamples {
+					diagnostics.BrokenTransactionIDs = append(diagnostics.BrokenTransactionIDs, id)
+				}
+			}
+		case categories == 1:
+			diagnostics.SingleCategory++
+		default:
+			diagnostics.MultipleCategories++
+			if portionTotal.Valid && math.Abs(portionTotal.Float64-amount) > balanceMismatchTolerance {
+				diagnostics.SplitAmountMismatches++
+				if len(diagnostics.SplitMismatchTransactionIDs) < maxDiagnosticExamples {
+					diagnostics.SplitMismatchTransactionIDs = append(diagnostics.SplitMismatchTransactionIDs, id)
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query category assignments: %w", err)
+	}
+
+	err = db.runQuery(ctx, "DiagnoseCategoryAssignments orphans", func() error {
+		return db.reader().QueryRowContext(ctx, `
+			SELECT COUNT(*) FROM ZCATEGORYASSIGMENT ca
+			WHERE NOT EXISTS (SELECT 1 FROM ZSYNCOBJECT t WHERE t.Z_PK = ca.ZTRANSACTION)
+		`).Scan(&diagnostics.OrphanAssignments)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to count orphan category assignments: %w", err)
+	}
+
+	if diagnostics.BrokenAssignments > 0 {
+		diagnostics.Notes = append(diagnostics.Notes, fmt.Sprintf("%d transactions have category assignments pointing at rows that are not categories; reports show them as uncategorized.", diagnostics.BrokenAssignments))
+	}
+	if diagnostics.MultipleCategories > 0 && diagnostics.SplitAmountColumn == "" {
+		diagnostics.Notes = append(diagnostics.Notes, fmt.Sprintf("%d transactions have several categories but the schema stores no split portions, so each is counted once under its first category.", diagnostics.MultipleCategories))
+	}
+	if diagnostics.SplitAmountMismatches > 0 {
+		diagnostics.Notes = append(diagnostics.Notes, fmt.Sprintf("%d split transactions have portions that do not add up to the transaction amount, so category totals and transaction totals differ.", diagnostics.SplitAmountMismatches))
+	}
+	if diagnostics.OrphanAssignments > 0 {
+		diagnostics.Notes = append(diagnostics.Notes, fmt.Sprintf("%d category assignments point at transactions that do not exist; they are ignored.", diagnostics.OrphanAssignments))
+	}
+
+	return diagnostics, nil
+}
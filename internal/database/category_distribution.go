@@ -0,0 +1,116 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// AmountDistribution summarizes the sizes of individual transactions
+type AmountDistribution struct {
+	TransactionCount int     `json:"transaction_count"`
+	Total            float64 `json:"total" money:"amount"`
+	Min              float64 `json:"min" money:"amount"`
+	Median           float64 `json:"median" money:"amount"`
+	Mean             float64 `json:"mean" money:"amount"`
+	Max              float64 `json:"max" money:"amount"`
+	P90              float64 `json:"p90" money:"amount"` // 90% of transactions are at most this amount
+}
+
+// CategoryDistribution represents the distribution of a category's individual expense amounts,
+// e.g. whether "Dining" is many small meals or a few big dinners
+type CategoryDistribution struct {
+	Category
+	Months               int  `json:"months"`
+	IncludeSubcategories bool `json:"include_subcategories"`
+	AmountDistribution
+	ByCurrency      map[string]AmountDistribution `json:"by_currency" money:"by_currency"`
+	MixedCurrencies bool                          `json:"mixed_currencies"`
+	Currencies      []string                      `json:"currencies"`
+	CurrencyWarning string                        `json:"currency_warning,omitempty"`
+}
+
+// GetCategoryDistribution computes min, median, mean, max and 90th percentile of the individual
+// expense amounts of one category over the last months (0 = all historical data)
+// The category is resolved as in GetSpendingForCategory; includeSubcategories also counts the
+// expenses of its subcategories
+// Internal transfers and future-dated scheduled transactions are left out, as in the analyses
+func (db *DB) GetCategoryDistribution(ctx context.Context, categoryID int64, categoryName string, months int, includeSubcategories bool) (*CategoryDistribution, error) {
+	category, err := db.resolveCategory(ctx, categoryID, categoryName)
+	if err != nil {
+		return nil, err
+	}
+	categoryIDs, err := db.categoryIDsWithin(ctx, category.ID, includeSubcategories)
+	if err != nil {
+		return nil, err
+	}
+
+	spending, err := db.GetSpendingData(ctx, months, false, categoryIDs, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get spending data: %w", err)
+	}
+
+	var amounts []float64
+	byCurrency := make(map[string][]float64)
+	for _, s := range spending {
+		amounts = append(amounts, s.Amount)
+		if s.Currency != "" {
+			byCurrency[s.Currency] = append(byCurrency[s.Currency], s.Amount)
+		}
+	}
+
+	distribution := &CategoryDistribution{
+		Category:             category,
+		Months:               months,
+		IncludeSubcategories: includeSubcategories,
+		AmountDistribution:   newAmountDistribution(amounts),
+		ByCurrency:           make(map[string]AmountDistribution, len(byCurrency)),
+	}
+	for currency, currencyAmounts := range byCurrency {
+		distribution.ByCurrency[currency] = newAmountDistribution(currencyAmounts)
+	}
+
+	distribution.Currencies = sortedCurrencyKeys(byCurrency)
+	distribution.MixedCurrencies = len(distribution.Currencies) > 1
+	if distribution.MixedCurrencies {
+		distribution.CurrencyWarning = "The overall statistics mix currencies; use by_currency for per-currency statistics."
+	}
+
+	return distribution, nil
+}
+
+// newAmountDistribution summarizes amounts; all statistics are zero when there are none
+func newAmountDistribution(amounts []float64) AmountDistribution {
+	if len(amounts) == 0 {
+		return AmountDistribution{}
+	}
+
+	sorted := append([]float64(nil), amounts...)
+	sort.Float64s(sorted)
+
+	distribution := AmountDistribution{
+		TransactionCount: len(sorted),
+		Min:              sorted[0],
+		Max:              sorted[len(sorted)-1],
+		Median:           percentile(sorted, 50),
+		P90:              percentile(sorted, 90),
+	}
+	for _, amount := range sorted {
+		distribution.Total += amount
+	}
+	distribution.Mean = distribution.Total / float64(len(sorted))
+	return distribution
+}
+
+// percentile returns the p-th percentile of sorted values, interpolating linearly between the
+// two closest ranks, so the 50th percentile of an even count is the mean of the middle two
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := p / 100 * float64(len(sorted)-1)
+	lower := int(math.Floor(rank))
+	upper := int(math.Ceil(rank))
+	return sorted[lower] + (sorted[upper]-sorted[lower])*(rank-float64(lower))
+}
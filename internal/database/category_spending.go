@@ -0,0 +1,148 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// CategorySpendingDetail represents the individual expenses of one category with their total
+type CategorySpendingDetail struct {
+	Category
+	Months                int                `json:"months"`
+	IncludeSubcategories  bool               `json:"include_subcategories"`
+	TotalSpending         float64            `json:"total_spending" money:"amount"`
+	TransactionCount      int                `json:"transaction_count"`
+	ByCurrency            map[string]float64 `json:"by_currency" money:"by_currency"`
+	MixedCurrencies       bool               `json:"mixed_currencies"`
+	Currencies            []string           `json:"currencies"`
+	CurrencyWarning       string             `json:"currency_warning,omitempty"`
+	FirstTransactionDate  string             `json:"first_transaction_date,omitempty"`
+	LatestTransactionDate string             `json:"latest_transaction_date,omitempty"`
+	Transactions          []SpendingData     `json:"transactions"` // Most recent first
+}
+
+// GetSpendingForCategory returns every expense of one category over the last months (0 = all
+// historical data), most recent first, with the total
+// The category is given by categoryID, or when that is 0 by categoryName, matched without regard
+// to case against the category name or its full path (e.g. "Food > Groceries")
+// includeSubcategories also returns the expenses of the category's subcategories
+// Internal transfers and future-dated scheduled transactions are left out, as in the analyses
+func (db *DB) GetSpendingForCategory(ctx context.Context, categoryID int64, categoryName string, months int, includeSubcategories bool) (*CategorySpendingDetail, error) {
+	category, err := db.resolveCategory(ctx, categoryID, categoryName)
+	if err != nil {
+		return nil, err
+	}
+
+	categoryIDs, err := db.categoryIDsWithin(ctx, category.ID, includeSubcategories)
+	if err != nil {
+		return nil, err
+	}
+
+	spending, err := db.GetSpendingData(ctx, months, false, categoryIDs, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get spending data: %w", err)
+	}
+
+	detail := &CategorySpendingDetail{
+		Category:             category,
+		Months:               months,
+		IncludeSubcategories: includeSubcategories,
+		ByCurrency:           make(map[string]float64),
+		Transactions:         []SpendingData{},
+	}
+	for _, s := range spending {
+		detail.TotalSpending += s.Amount
+		detail.TransactionCount++
+		if s.Currency != "" {
+			detail.ByCurrency[s.Currency] += s.Amount
+		}
+		if s.Date != "" && (detail.FirstTransactionDate == "" || s.Date < detail.FirstTransactionDate) {
+			detail.FirstTransactionDate = s.Date
+		}
+		if s.Date > detail.LatestTransactionDate {
+			detail.LatestTransactionDate = s.Date
+		}
+		detail.Transactions = append(detail.Transactions, s)
+	}
+
+	detail.Currencies = sortedCurrencyKeys(detail.ByCurrency)
+	detail.MixedCurrencies = len(detail.Currencies) > 1
+	if detail.MixedCurrencies {
+		detail.CurrencyWarning = "total_spending combines multiple currencies; use by_currency for per-currency totals."
+	}
+
+	return detail, nil
+}
+
+// categoryIDsWithin returns the category ID, followed by the IDs of all its subcategories at
+// any depth when includeSubcategories is set
+func (db *DB) categoryIDsWithin(ctx context.Context, categoryID int64, includeSubcategories bool) ([]int64, error) {
+	categoryIDs := []int64{categoryID}
+	if !includeSubcategories {
+		return categoryIDs, nil
+	}
+
+	tree, err := db.loadCategoryTree(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for id := range tree {
+		if id == categoryID {
+			continue
+		}
+		for _, ancestor := range tree.ancestors(id) {
+			if ancestor == categoryID {
+				categoryIDs = append(categoryIDs, id)
+				break
+			}
+		}
+	}
+	return categoryIDs, nil
+}
+
+// resolveCategory finds a category by ID, or by name or full path when the ID is 0
+// A name shared by several categories (e.g. "Other" under two parents) is rejected with the
+// matching full paths so the caller can pick one
+func (db *DB) resolveCategory(ctx context.Context, categoryID int64, categoryName string) (Category, error) {
+	categoryName = strings.TrimSpace(categoryName)
+	if categoryID <= 0 && categoryName == "" {
+		return Category{}, invalidArgumentf("a category ID or name is required")
+	}
+
+	categories, err := db.GetCategories(ctx, "")
+	if err != nil {
+		return Category{}, err
+	}
+
+	if categoryID > 0 {
+		for _, cat := range categories {
+			if cat.ID == categoryID {
+				return cat, nil
+			}
+		}
+		return Category{}, notFoundf("category with ID %d not found", categoryID)
+	}
+
+	var matches []Category
+	for _, cat := range categories {
+		if strings.EqualFold(cat.FullPath, categoryName) {
+			return cat, nil
+		}
+		if strings.EqualFold(cat.Name, categoryName) {
+			matches = append(matches, cat)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return Category{}, notFoundf("category %q not found", categoryName)
+	case 1:
+		return matches[0], nil
+	default:
+		paths := make([]string, len(matches))
+		for i, cat := range matches {
+			paths[i] = fmt.Sprintf("%s (ID %d)", cat.FullPath, cat.ID)
+		}
+		return Category{}, invalidArgumentf("category name %q is ambiguous: %s", categoryName, strings.Join(paths, ", "))
+	}
+}
@@ -0,0 +1,166 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+// CategoryHierarchyNode represents a category with its spending and the spending of its
+// subcategories rolled up into it
+type CategoryHierarchyNode struct {
+	ID                  int64                   `json:"id"`
+	Name                string                  `json:"name"`
+	FullPath            string                  `json:"full_path"`
+	Orphan              bool                    `json:"orphan,omitempty"`              // Its parent is missing (or part of a cycle), so it is placed at the root
+	OwnSpending         float64                 `json:"own_spending" money:"amount"`   // Booked to this category itself
+	TotalSpending       float64                 `json:"total_spending" money:"amount"` // Own spending plus that of every descendant
+	SharePercent        float64                 `json:"share_percent"`                 // Share of all categorized spending, by total
+	OwnTransactionCount int                     `json:"own_transaction_count"`
+	TransactionCount    int                     `json:"transaction_count"` // Including descendants
+	Children            []CategoryHierarchyNode `json:"children"`          // Largest total first
+}
+
+// CategoryHierarchy represents the whole category tree with rolled-up spending
+type CategoryHierarchy struct {
+	Months                int                     `json:"months"`
+	TotalSpending         float64                 `json:"total_spending" money:"amount"`         // Spending booked to a category
+	UncategorizedSpending float64                 `json:"uncategorized_spending" money:"amount"` // Spending without a category, outside the tree
+	CategoryCount         int                     `json:"category_count"`
+	OrphanCount           int                     `json:"orphan_count"`
+	Roots                 []CategoryHierarchyNode `json:"roots"` // Largest total first
+	MixedCurrencies       bool                    `json:"mixed_currencies"`
+	Currencies            []string                `json:"currencies"`
+	CurrencyWarning       string                  `json:"currency_warning,omitempty"`
+}
+
+// GetCategoryTree returns every category nested under its parent, each with the spending booked
+// to it and the total including its descendants, to explore spending top-down
+// A category whose parent does not exist is placed at the root and marked as an orphan, as is
+// one category of every parent cycle, so no category goes missing
+// months: number of months to look back (0 = all historical data)
+// Internal transfers and future-dated scheduled transactions are left out, as in the analyses
+func (db *DB) GetCategoryTree(ctx context.Context, months int) (*CategoryHierarchy, error) {
+	tree, err := db.loadCategoryTree(ctx)
+	if err != nil {
+		return nil, err
+	}
+	spending, err := db.GetSpendingData(ctx, months, false, nil, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get spending data: %w", err)
+	}
+
+	hierarchy := &CategoryHierarchy{
+		Months:        months,
+		CategoryCount: len(tree),
+		Roots:         []CategoryHierarchyNode{},
+	}
+	own := make(map[int64]float64)
+	ownCount := make(map[int64]int)
+	currencySet := make(map[string]struct{})
+	for _, s := range spending {
+		if s.Currency != "" {
+			currencySet[s.Currency] = struct{}{}
+		}
+		if _, ok := tree[s.CategoryID]; !ok {
+			hierarchy.UncategorizedSpending += s.Amount
+			continue
+		}
+		own[s.CategoryID] += s.Amount
+		ownCount[s.CategoryID]++
+		hierarchy.TotalSpending += s.Amount
+	}
+
+	ids := make([]int64, 0, len(tree))
+	children := make(map[int64][]int64)
+	for id, node := range tree {
+		ids = append(ids, id)
+		if _, ok := tree[node.parentID]; ok {
+			children[node.parentID] = append(children[node.parentID], id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	placed := make(map[int64]bool, len(tree))
+	var build func(id int64) CategoryHierarchyNode
+	build = func(id int64) CategoryHierarchyNode {
+		placed[id] = true
+		node := CategoryHierarchyNode{
+			ID:                  id,
+			Name:                tree[id].name,
+			FullPath:            tree.fullPath(id),
+			OwnSpending:         own[id],
+			TotalSpending:       own[id],
+			OwnTransactionCount: ownCount[id],
+			TransactionCount:    ownCount[id],
+			Children:            []CategoryHierarchyNode{},
+		}
+		for _, childID := range children[id] {
+			if placed[childID] {
+				continue
+			}
+			child := build(childID)
+			node.TotalSpending += child.TotalSpending
+			node.TransactionCount += child.TransactionCount
+			node.Children = append(node.Children, child)
+		}
+		sortCategoryHierarchyNodes(node.Children)
+		return node
+	}
+
+	addRoot := func(id int64, orphan bool) {
+		root := build(id)
+		root.Orphan = orphan
+		if orphan {
+			hierarchy.OrphanCount++
+		}
+		hierarchy.Roots = append(hierarchy.Roots, root)
+	}
+	for _, id := range ids {
+		parentID := tree[id].parentID
+		if parentID == 0 {
+			addRoot(id, false)
+		} else if _, ok := tree[parentID]; !ok {
+			addRoot(id, true)
+		}
+	}
+	// Whatever is left hangs off a parent cycle
+	for _, id := range ids {
+		if !placed[id] {
+			addRoot(id, true)
+		}
+	}
+	sortCategoryHierarchyNodes(hierarchy.Roots)
+	setCategoryHierarchyShares(hierarchy.Roots, hierarchy.TotalSpending)
+
+	hierarchy.Currencies = sortedCurrencyKeys(currencySet)
+	hierarchy.MixedCurrencies = len(hierarchy.Currencies) > 1
+	if hierarchy.MixedCurrencies {
+		hierarchy.CurrencyWarning = "Totals combine multiple currencies without conversion."
+	}
+
+	return hierarchy, nil
+}
+
+// sortCategoryHierarchyNodes orders nodes by total spending, largest first, then by name
+func sortCategoryHierarchyNodes(nodes []CategoryHierarchyNode) {
+	sort.Slice(nodes, func(i, j int) bool {
+		if nodes[i].TotalSpending != nodes[j].TotalSpending {
+			return nodes[i].TotalSpending > nodes[j].TotalSpending
+		}
+		if nodes[i].Name != nodes[j].Name {
+			return nodes[i].Name < nodes[j].Name
+		}
+		return nodes[i].ID < nodes[j].ID
+	})
+}
+
+// setCategoryHierarchyShares fills the share of total spending of every node
+func setCategoryHierarchyShares(nodes []CategoryHierarchyNode, total float64) {
+	for i := range nodes {
+		if total > 0 {
+			nodes[i].SharePercent = nodes[i].TotalSpending / total * 100
+		}
+		setCategoryHierarchyShares(nodes[i].Children, total)
+	}
+}
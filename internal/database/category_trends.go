@@ -0,0 +1,125 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+// CategoryChange represents how a category's spending moved between two halves of a window
+type CategoryChange struct {
+	CategoryName      string  `json:"category_name"`
+	FirstHalfAverage  float64 `json:"first_half_average" money:"amount"`  // Average monthly spending in the first half
+	SecondHalfAverage float64 `json:"second_half_average" money:"amount"` // Average monthly spending in the second half
+	Change            float64 `json:"change" money:"amount"`              // Second half minus first half (negative = spending cut)
+	ChangePercent     float64 `json:"change_percent"`                     // Relative to the first half (0 when the first half is empty)
+}
+
+// CategoryTrendDirections represents the most improved and most worsened spending categories
+type CategoryTrendDirections struct {
+	FirstPeriod  string           `json:"first_period"`  // "YYYY-MM to YYYY-MM"
+	SecondPeriod string           `json:"second_period"` // "YYYY-MM to YYYY-MM"
+	MostImproved []CategoryChange `json:"most_improved"`
+	MostWorsened []CategoryChange `json:"most_worsened"`
+}
+
+const categoryTrendDirectionsTopN = 5
+
+// GetCategoryTrendDirections splits the analysis window into two halves and compares
+// average monthly spending per category between them
+// months: number of months to analyze (0 = all historical data)
+func (db *DB) GetCategoryTrendDirections(ctx context.Context, months int) (*CategoryTrendDirections, error) {
+	spendingData, err := db.GetSpendingData(ctx, months, false, nil, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get spending data: %w", err)
+	}
+
+	result := &CategoryTrendDirections{
+		MostImproved: []CategoryChange{},
+		MostWorsened: []CategoryChange{},
+	}
+
+	var firstMonth, lastMonth string
+	for _, s := range spendingData {
+		if s.Month == "" {
+			continue
+		}
+		if firstMonth == "" || s.Month < firstMonth {
+			firstMonth = s.Month
+		}
+		if lastMonth == "" || s.Month > lastMonth {
+			lastMonth = s.Month
+		}
+	}
+
+	window := monthSpan(firstMonth, lastMonth)
+	if len(window) < 2 {
+		return result, nil
+	}
+
+	// The first half gets the smaller share when the window has an odd number of months
+	firstHalf := window[:len(window)/2]
+	secondHalf := window[len(window)/2:]
+	secondHalfStart := secondHalf[0]
+	result.FirstPeriod = fmt.Sprintf("%s to %s", firstHalf[0], firstHalf[len(firstHalf)-1])
+	result.SecondPeriod = fmt.Sprintf("%s to %s", secondHalf[0], secondHalf[len(secondHalf)-1])
+
+	firstTotals := make(map[string]float64)
+	secondTotals := make(map[string]float64)
+	for _, s := range spendingData {
+		if s.Month == "" {
+			continue
+		}
+		if s.Month < secondHalfStart {
+			firstTotals[s.CategoryName] += s.Amount
+		} else {
+			secondTotals[s.CategoryName] += s.Amount
+		}
+	}
+
+	names := make(map[string]struct{})
+	for name := range firstTotals {
+		names[name] = struct{}{}
+	}
+	for name := range secondTotals {
+		names[name] = struct{}{}
+	}
+
+	var changes []CategoryChange
+	for name := range names {
+		first := firstTotals[name] / float64(len(firstHalf))
+		second := secondTotals[name] / float64(len(secondHalf))
+		change := CategoryChange{
+			CategoryName:      name,
+			FirstHalfAverage:  first,
+			SecondHalfAverage: second,
+			Change:            second - first,
+		}
+		if first > 0 {
+			change.ChangePercent = (change.Change / first) * 100
+		}
+		changes = append(changes, change)
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].Change != changes[j].Change {
+			return changes[i].Change < changes[j].Change
+		}
+		return changes[i].CategoryName < changes[j].CategoryName
+	})
+
+	for i := 0; i < len(changes) && len(result.MostImproved) < categoryTrendDirectionsTopN; i++ {
+		if changes[i].Change >= 0 {
+			break
+		}
+		result.MostImproved = append(result.MostImproved, changes[i])
+	}
+	for i := len(changes) - 1; i >= 0 && len(result.MostWorsened) < categoryTrendDirectionsTopN; i-- {
+		if changes[i].Change <= 0 {
+			break
+		}
+		result.MostWorsened = append(result.MostWorsened, changes[i])
+	}
+
+	return result, nil
+}
@@ -0,0 +1,280 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	ComparisonModeMonthOverMonth = "month_over_month"
+	ComparisonModeCustom         = "custom"
+	ComparisonModeYearOverYear   = "year_over_year"
+)
+
+// PeriodSummary represents income and spending within one compared period
+type PeriodSummary struct {
+	Period      string  `json:"period"`     // As requested, e.g. "2024-02"
+	StartDate   string  `json:"start_date"` // YYYY-MM-DD
+	EndDate     string  `json:"end_date"`   // YYYY-MM-DD, inclusive
+	Income      float64 `json:"income" money:"amount"`
+	Spending    float64 `json:"spending" money:"amount"`
+	NetSavings  float64 `json:"net_savings" money:"amount"`
+	Incomplete  bool    `json:"incomplete"`             // The data ends before the period does
+	DaysCovered int     `json:"days_covered,omitempty"` // Days with data when incomplete
+	Note        string  `json:"note,omitempty"`
+}
+
+// CategoryDelta represents how one income or spending category moved between the periods
+type CategoryDelta struct {
+	CategoryName  string   `json:"category_name"`
+	Type          string   `json:"type"` // "income" or "spending"
+	Base          float64  `json:"base" money:"amount"`
+	Compare       float64  `json:"compare" money:"amount"`
+	Delta         float64  `json:"delta" money:"amount"`     // Compare minus base
+	ChangePercent *float64 `json:"change_percent,omitempty"` // Relative to base; omitted when base is 0
+}
+
+// PeriodComparison represents income, spending and savings of two periods side by side
+type PeriodComparison struct {
+	Mode                  string          `json:"mode"` // "month_over_month", "custom", or "year_over_year"
+	Base                  PeriodSummary   `json:"base"`
+	Compare               PeriodSummary   `json:"compare"`
+	IncomeDelta           float64         `json:"income_delta" money:"amount"`
+	SpendingDelta         float64         `json:"spending_delta" money:"amount"`
+	NetSavingsDelta       float64         `json:"net_savings_delta" money:"amount"`
+	IncomeChangePercent   *float64        `json:"income_change_percent,omitempty"`
+	SpendingChangePercent *float64        `json:"spending_change_percent,omitempty"`
+	CategoryDeltas        []CategoryDelta `json:"category_deltas"` // Largest absolute delta first
+	MixedCurrencies       bool            `json:"mixed_currencies"`
+	Currencies            []string        `json:"currencies"`
+	CurrencyWarning       string          `json:"currency_warning,omitempty"`
+}
+
+// comparedPeriod is a half-open [start, end) range with its per-category totals
+type comparedPeriod struct {
+	label    string
+	start    time.Time
+	end      time.Time
+	income   map[string]float64
+	spending map[string]float64
+}
+
+// ComparePeriods compares income, spending and net savings of two periods, with per-category deltas
+// basePeriod, comparePeriod: YYYY-MM-DD, YYYY-MM or YYYY; when both are empty the month of the
+// latest transaction is compared with the month before it
+// A period the data ends partway through (typically the current month) is marked incomplete
+func (db *DB) ComparePeriods(ctx context.Context, basePeriod, comparePeriod string, includeTransfers bool) (*PeriodComparison, error) {
+	latest, hasData, err := db.latestTransactionTime(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+
+	comparison := &PeriodComparison{Mode: ComparisonModeCustom, CategoryDeltas: []CategoryDelta{}}
+	var base, compare comparedPeriod
+	switch {
+	case strings.TrimSpace(basePeriod) == "" && strings.TrimSpace(comparePeriod) == "":
+		if !hasData {
+			return nil, notFoundf("no transactions to compare")
+		}
+		comparison.Mode = ComparisonModeMonthOverMonth
+		currentMonth := time.Date(latest.Year(), latest.Month(), 1, 0, 0, 0, 0, time.UTC)
+		previousMonth := currentMonth.AddDate(0, -1, 0)
+		base = comparedPeriod{label: previousMonth.Format(monthLayout), start: previousMonth, end: currentMonth}
+		compare = comparedPeriod{label: currentMonth.Format(monthLayout), start: currentMonth, end: currentMonth.AddDate(0, 1, 0)}
+	case strings.TrimSpace(basePeriod) == "" || strings.TrimSpace(comparePeriod) == "":
+		return nil, invalidArgumentf("base_period and compare_period must be given together")
+	default:
+		base.label = strings.TrimSpace(basePeriod)
+		if base.start, base.end, err = parseDatePeriod(base.label); err != nil {
+			return nil, invalidArgumentf("invalid base_period: %w", err)
+		}
+		compare.label = strings.TrimSpace(comparePeriod)
+		if compare.start, compare.end, err = parseDatePeriod(compare.label); err != nil {
+			return nil, invalidArgumentf("invalid compare_period: %w", err)
+		}
+	}
+
+	return db.comparePeriods(ctx, comparison, base, compare, latest, hasData, includeTransfers)
+}
+
+// CompareSamePeriodLastYear compares income, spending and net savings of a period with the same
+// calendar period one year earlier (e.g. December 2024 with December 2023), with per-category
+// deltas, which controls for seasonality better than month over month
+// period: YYYY-MM-DD, YYYY-MM or YYYY ("" = the month of the latest transaction)
+// A period the data ends partway through (typically the current month) is marked incomplete
+func (db *DB) CompareSamePeriodLastYear(ctx context.Context, period string, includeTransfers bool) (*PeriodComparison, error) {
+	latest, hasData, err := db.latestTransactionTime(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+
+	var compare comparedPeriod
+	layout := monthLayout
+	if period = strings.TrimSpace(period); period == "" {
+		if !hasData {
+			return nil, notFoundf("no transactions to compare")
+		}
+		compare.start = time.Date(latest.Year(), latest.Month(), 1, 0, 0, 0, 0, time.UTC)
+		compare.end = compare.start.AddDate(0, 1, 0)
+		compare.label = compare.start.Format(monthLayout)
+	} else {
+		if compare.start, compare.end, err = parseDatePeriod(period); err != nil {
+			return nil, invalidArgumentf("invalid period: %w", err)
+		}
+		compare.label = period
+		switch len(period) {
+		case len(dayLayout):
+			layout = dayLayout
+		case len(yearLayout):
+			layout = yearLayout
+		}
+	}
+
+	// 29 February falls back to 28 February rather than rolling into March
+	base := comparedPeriod{start: addMonthsClamped(compare.start, -12), end: addMonthsClamped(compare.end, -12)}
+	base.label = base.start.Format(layout)
+
+	comparison := &PeriodComparison{Mode: ComparisonModeYearOverYear, CategoryDeltas: []CategoryDelta{}}
+	return db.comparePeriods(ctx, comparison, base, compare, latest, hasData, includeTransfers)
+}
+
+// comparePeriods totals income and spending of the base and compare periods into comparison
+func (db *DB) comparePeriods(ctx context.Context, comparison *PeriodComparison, base, compare comparedPeriod, latest time.Time, hasData, includeTransfers bool) (*PeriodComparison, error) {
+	for _, period := range []*comparedPeriod{&base, &compare} {
+		period.income = make(map[string]float64)
+		period.spending = make(map[string]float64)
+	}
+
+	incomeData, err := db.GetIncomeData(ctx, 0, includeTransfers, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get income data: %w", err)
+	}
+	spendingData, err := db.GetSpendingData(ctx, 0, includeTransfers, nil, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get spending data: %w", err)
+	}
+
+	currencySet := make(map[string]struct{})
+	for _, i := range incomeData {
+		for _, period := range []*comparedPeriod{&base, &compare} {
+			if period.contains(i.Date) {
+				period.income[i.CategoryName] += i.Amount
+				if i.Currency != "" {
+					currencySet[i.Currency] = struct{}{}
+				}
+			}
+		}
+	}
+	for _, s := range spendingData {
+		for _, period := range []*comparedPeriod{&base, &compare} {
+			if period.contains(s.Date) {
+				period.spending[s.CategoryName] += s.Amount
+				if s.Currency != "" {
+					currencySet[s.Currency] = struct{}{}
+				}
+			}
+		}
+	}
+
+	comparison.Base = base.summary(latest, hasData)
+	comparison.Compare = compare.summary(latest, hasData)
+	comparison.IncomeDelta = comparison.Compare.Income - comparison.Base.Income
+	comparison.SpendingDelta = comparison.Compare.Spending - comparison.Base.Spending
+	comparison.NetSavingsDelta = comparison.Compare.NetSavings - comparison.Base.NetSavings
+	comparison.IncomeChangePercent = changePercent(comparison.Base.Income, comparison.Compare.Income)
+	comparison.SpendingChangePercent = changePercent(comparison.Base.Spending, comparison.Compare.Spending)
+
+	comparison.CategoryDeltas = append(comparison.CategoryDeltas, categoryDeltas("income", base.income, compare.income)...)
+	comparison.CategoryDeltas = append(comparison.CategoryDeltas, categoryDeltas("spending", base.spending, compare.spending)...)
+	sort.Slice(comparison.CategoryDeltas, func(i, j int) bool {
+		a, b := comparison.CategoryDeltas[i], comparison.CategoryDeltas[j]
+		if math.Abs(a.Delta) != math.Abs(b.Delta) {
+			return math.Abs(a.Delta) > math.Abs(b.Delta)
+		}
+		if a.Type != b.Type {
+			return a.Type < b.Type
+		}
+		return a.CategoryName < b.CategoryName
+	})
+
+	comparison.Currencies = sortedCurrencyKeys(currencySet)
+	comparison.MixedCurrencies = len(comparison.Currencies) > 1
+	if comparison.MixedCurrencies {
+		comparison.CurrencyWarning = "Totals combine multiple currencies. Compare periods per currency for accurate interpretation."
+	}
+
+	return comparison, nil
+}
+
+// contains reports whether a dateTimeLayout date falls within the period
+func (p comparedPeriod) contains(date string) bool {
+	ts, err := time.Parse(dateTimeLayout, date)
+	if err != nil {
+		return false
+	}
+	return !ts.Before(p.start) && ts.Before(p.end)
+}
+
+// summary totals the period and marks it incomplete when the data ends before the period does
+func (p comparedPeriod) summary(latest time.Time, hasData bool) PeriodSummary {
+	summary := PeriodSummary{
+		Period:    p.label,
+		StartDate: p.start.Format(dayLayout),
+		EndDate:   p.end.AddDate(0, 0, -1).Format(dayLayout),
+	}
+	for _, amount := range p.income {
+		summary.Income += amount
+	}
+	for _, amount := range p.spending {
+		summary.Spending += amount
+	}
+	summary.NetSavings = summary.Income - summary.Spending
+
+	dataEnd := time.Date(latest.Year(), latest.Month(), latest.Day(), 0, 0, 0, 0, time.UTC).AddDate(0, 0, 1)
+	if hasData && dataEnd.Before(p.end) {
+		summary.Incomplete = true
+		totalDays := int(p.end.Sub(p.start).Hours() / 24)
+		if dataEnd.After(p.start) {
+			summary.DaysCovered = int(dataEnd.Sub(p.start).Hours() / 24)
+		}
+		summary.Note = fmt.Sprintf("Data ends on %s, so only %d of %d days are covered; totals will rise as the period completes", latest.Format(dayLayout), summary.DaysCovered, totalDays)
+	}
+	return summary
+}
+
+// categoryDeltas compares the per-category totals of two periods
+func categoryDeltas(kind string, base, compare map[string]float64) []CategoryDelta {
+	names := make(map[string]struct{}, len(base)+len(compare))
+	for name := range base {
+		names[name] = struct{}{}
+	}
+	for name := range compare {
+		names[name] = struct{}{}
+	}
+
+	deltas := make([]CategoryDelta, 0, len(names))
+	for _, name := range sortedCurrencyKeys(names) {
+		deltas = append(deltas, CategoryDelta{
+			CategoryName:  name,
+			Type:          kind,
+			Base:          base[name],
+			Compare:       compare[name],
+			Delta:         compare[name] - base[name],
+			ChangePercent: changePercent(base[name], compare[name]),
+		})
+	}
+	return deltas
+}
+
+// changePercent returns the change from base to compare in percent, or nil when base is 0
+func changePercent(base, compare float64) *float64 {
+	if base == 0 {
+		return nil
+	}
+	percent := (compare - base) / base * 100
+	return &percent
+}
@@ -0,0 +1,81 @@
+package database
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// CurrencyConversion describes how amounts were converted into a single currency
+type CurrencyConversion struct {
+	TargetCurrency string             `json:"target_currency"`
+	ExchangeRates  map[string]float64 `json:"exchange_rates"` // Units of target currency per unit of each currency
+	Warnings       []string           `json:"warnings,omitempty"`
+}
+
+// currencyConverter converts amounts into a target currency with caller-supplied rates,
+// remembering the rates it applied and the currencies it had no rate for
+type currencyConverter struct {
+	target  string
+	rates   map[string]float64
+	applied map[string]float64
+	missing map[string]struct{}
+}
+
+// newCurrencyConverter validates the target currency and rates; currency codes are compared
+// without regard to case or surrounding spaces
+// rates: units of the target currency per unit of each currency (e.g. {"EUR": 1.08} for USD)
+func newCurrencyConverter(target string, rates map[string]float64) (*currencyConverter, error) {
+	target = strings.ToUpper(strings.TrimSpace(target))
+	if target == "" {
+		return nil, invalidArgumentf("target currency must not be empty")
+	}
+
+	normalizedRates := make(map[string]float64, len(rates))
+	for currency, rate := range rates {
+		if rate <= 0 || math.IsNaN(rate) || math.IsInf(rate, 0) {
+			return nil, invalidArgumentf("exchange rate for %s must be a positive number, got %v", currency, rate)
+		}
+		normalizedRates[strings.ToUpper(strings.TrimSpace(currency))] = rate
+	}
+
+	return &currencyConverter{
+		target:  target,
+		rates:   normalizedRates,
+		applied: make(map[string]float64),
+		missing: make(map[string]struct{}),
+	}, nil
+}
+
+// convert returns amount in the target currency; a currency without a rate is converted at 1.0
+func (c *currencyConverter) convert(amount float64, currency string) float64 {
+	currency = strings.ToUpper(currency)
+	if currency == c.target {
+		return amount
+	}
+	rate, ok := c.rates[currency]
+	if !ok {
+		rate = 1.0
+		c.missing[currency] = struct{}{}
+	}
+	if currency != "" {
+		c.applied[currency] = rate
+	}
+	return amount * rate
+}
+
+// conversion reports the rates applied so far, with a warning for every currency without a rate
+func (c *currencyConverter) conversion(unlabeled string) CurrencyConversion {
+	result := CurrencyConversion{
+		TargetCurrency: c.target,
+		ExchangeRates:  c.applied,
+	}
+	for _, currency := range sortedCurrencyKeys(c.missing) {
+		label := currency
+		if label == "" {
+			label = unlabeled
+		}
+		result.Warnings = append(result.Warnings, fmt.Sprintf("No exchange rate for %s to %s; converted at 1.0", label, c.target))
+	}
+	return result
+}
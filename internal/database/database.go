@@ -2,34 +2,246 @@ package database
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
-	_ "github.com/mattn/go-sqlite3"
+	"github.com/mattn/go-sqlite3"
 )
 
 type DB struct {
 	conn *sql.DB
+	// snapshot is the read transaction queries run in inside inReadSnapshot; nil otherwise
+	snapshot *sql.Tx
+	path     string
+	// uncategorizedLabel is the category name analyses give transactions without a category
+	uncategorizedLabel string
+	// fiscalYearStart is the month the year buckets of the analyses begin in (January = calendar years)
+	fiscalYearStart time.Month
+	// unknownCurrency is the currency given to accounts without one
+	unknownCurrency string
+	// slowQueryThreshold is the duration from which a query is logged as slow (0 = never)
+	slowQueryThreshold time.Duration
+	// logQueries logs the elapsed time of every query
+	logQueries bool
+}
+
+// DefaultBusyTimeout is how long a query waits for MoneyWiz to release a lock on the file
+const DefaultBusyTimeout = 5 * time.Second
+
+// DefaultSlowQueryThreshold is the duration above which a query is logged as slow
+const DefaultSlowQueryThreshold = 2 * time.Second
+
+// DefaultUncategorizedLabel is the category name of transactions without a category
+const DefaultUncategorizedLabel = "Uncategorized"
+
+// DefaultUnknownCurrency is the currency of accounts that have none
+const DefaultUnknownCurrency = "UNKNOWN"
+
+// Connection pool defaults for the read-only workload of concurrent tool calls
+const (
+	// DefaultMaxOpenConns caps the connections reading the file at once; more readers only
+	// contend for the same file and page cache
+	DefaultMaxOpenConns = 4
+	// DefaultConnMaxLifetime recycles connections so a long-running server does not keep the
+	// same file handles forever, e.g. after MoneyWiz replaced the file during a sync
+	DefaultConnMaxLifetime = 30 * time.Minute
+)
+
+// Options configures how the database is opened
+type Options struct {
+	// ReadWrite opens the file read-write. By default it is opened read-only, so the server
+	// never writes to the MoneyWiz file
+	ReadWrite bool
+	// BusyTimeout is how long SQLite retries when the file is locked by another process, such
+	// as MoneyWiz writing to it, before failing with "database is locked" (0 = DefaultBusyTimeout)
+	BusyTimeout time.Duration
+	// FiscalYearStart is the month a year begins in when analyses group by year. Any month but
+	// January labels the buckets by the calendar year they end in, e.g. "FY2025" for April 2024
+	// to March 2025 (0 = January, i.e. calendar years)
+	FiscalYearStart time.Month
+	// UncategorizedLabel names the category of transactions without one in analyses and
+	// transaction lists, e.g. "Sans catégorie" ("" = DefaultUncategorizedLabel). Transfers and cash
+	// withdrawals without a category keep their "Internal Transfer" and "Cash Withdrawal" labels
+	UncategorizedLabel string
+	// UnknownCurrency is the currency given to accounts whose ZCURRENCYNAME is NULL or blank, and to
+	// their transactions, so per-currency totals add up to the grand totals ("" = DefaultUnknownCurrency)
+	UnknownCurrency string
+	// MaxOpenConns caps the connections open at once (0 = DefaultMaxOpenConns read-only, and 1
+	// read-write so SQLite never has two writers competing for the file lock)
+	MaxOpenConns int
+	// MaxIdleConns is how many connections stay open between tool calls (0 = MaxOpenConns, so
+	// calls reuse warm connections instead of reopening the file)
+	MaxIdleConns int
+	// ConnMaxLifetime closes connections once they are this old (0 = DefaultConnMaxLifetime,
+	// negative = never)
+	ConnMaxLifetime time.Duration
+	// SlowQueryThreshold logs every query taking at least this long with its name and duration,
+	// so the query behind a slow tool call can be found (0 = never)
+	SlowQueryThreshold time.Duration
+	// LogQueries logs the name and duration of every query, for the debug log level
+	LogQueries bool
 }
 
 // NewDB creates a new database connection
-func NewDB(dbPath string) (*DB, error) {
+// A missing file, a file that is not SQLite, and a SQLite file without MoneyWiz's ZSYNCOBJECT
+// table fail straight away with ErrDatabaseMissing, ErrNotSQLite, and ErrNotMoneyWiz, rather than
+// on the first tool call
+func NewDB(dbPath string, opts Options) (*DB, error) {
+	if opts.FiscalYearStart < 0 || opts.FiscalYearStart > time.December {
+		return nil, invalidArgumentf("fiscal year start must be a month from 1 to 12, got %d", int(opts.FiscalYearStart))
+	}
+	if opts.FiscalYearStart == 0 {
+		opts.FiscalYearStart = time.January
+	}
+	if strings.TrimSpace(opts.UncategorizedLabel) == "" {
+		opts.UncategorizedLabel = DefaultUncategorizedLabel
+	}
+	if strings.TrimSpace(opts.UnknownCurrency) == "" {
+		opts.UnknownCurrency = DefaultUnknownCurrency
+	}
+	if opts.MaxOpenConns < 0 {
+		return nil, invalidArgumentf("max open connections must not be negative, got %d", opts.MaxOpenConns)
+	}
+	if opts.MaxIdleConns < 0 {
+		return nil, invalidArgumentf("max idle connections must not be negative, got %d", opts.MaxIdleConns)
+	}
+
 	// Resolve the database path
 	absPath, err := filepath.Abs(dbPath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to resolve database path: %w", err)
 	}
 
-	conn, err := sql.Open("sqlite3", absPath)
+	info, err := os.Stat(absPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, kindErrorf(ErrDatabaseMissing, "database file %s does not exist", absPath)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to access database file: %w", err)
+	}
+	if info.IsDir() {
+		return nil, kindErrorf(ErrNotSQLite, "%s is a directory; point to the MoneyWiz .sqlite file inside it", absPath)
+	}
+
+	conn, err := sql.Open("sqlite3", dataSourceName(absPath, opts))
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
+	configurePool(conn, opts)
 
 	if err := conn.Ping(); err != nil {
+		conn.Close()
+		if isNotADatabase(err) {
+			return nil, notSQLiteError(absPath)
+		}
 		return nil, fmt.Errorf("failed to ping database: %w", err)
 	}
 
-	return &DB{conn: conn}, nil
+	if err := checkSchema(conn, absPath); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return &DB{
+		conn:               conn,
+		path:               absPath,
+		uncategorizedLabel: strings.TrimSpace(opts.UncategorizedLabel),
+		fiscalYearStart:    opts.FiscalYearStart,
+		unknownCurrency:    strings.ToUpper(strings.TrimSpace(opts.UnknownCurrency)),
+		slowQueryThreshold: opts.SlowQueryThreshold,
+		logQueries:         opts.LogQueries,
+	}, nil
+}
+
+// configurePool applies the connection pool options, filling in the defaults
+func configurePool(conn *sql.DB, opts Options) {
+	maxOpen := opts.MaxOpenConns
+	if maxOpen == 0 {
+		maxOpen = DefaultMaxOpenConns
+		if opts.ReadWrite {
+			maxOpen = 1
+		}
+	}
+	maxIdle := opts.MaxIdleConns
+	if maxIdle == 0 || maxIdle > maxOpen {
+		maxIdle = maxOpen
+	}
+	lifetime := opts.ConnMaxLifetime
+	if lifetime == 0 {
+		lifetime = DefaultConnMaxLifetime
+	}
+	if lifetime < 0 {
+		lifetime = 0 // database/sql never expires connections with a zero lifetime
+	}
+
+	conn.SetMaxOpenConns(maxOpen)
+	conn.SetMaxIdleConns(maxIdle)
+	conn.SetConnMaxLifetime(lifetime)
+}
+
+// fiscalYearStartName returns the month fiscal years begin in, or "" for calendar years
+func (db *DB) fiscalYearStartName() string {
+	if db.fiscalYearStart <= time.January {
+		return ""
+	}
+	return db.fiscalYearStart.String()
+}
+
+// checkSchema verifies that the file is a SQLite database with MoneyWiz's ZSYNCOBJECT table
+func checkSchema(conn *sql.DB, absPath string) error {
+	var tables int
+	err := conn.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'ZSYNCOBJECT'`).Scan(&tables)
+	if isNotADatabase(err) {
+		return notSQLiteError(absPath)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read database schema: %w", err)
+	}
+	if tables == 0 {
+		return kindErrorf(ErrNotMoneyWiz, "%s has no ZSYNCOBJECT table, so it is not a MoneyWiz database", absPath)
+	}
+	return nil
+}
+
+// isNotADatabase reports whether SQLite rejected the file header, which happens on the first
+// statement run against the file
+func isNotADatabase(err error) bool {
+	var sqliteErr sqlite3.Error
+	return errors.As(err, &sqliteErr) && sqliteErr.Code == sqlite3.ErrNotADB
+}
+
+func notSQLiteError(absPath string) error {
+	return kindErrorf(ErrNotSQLite, "%s is not a SQLite database; a MoneyWiz backup must be extracted first (see scripts/import_db.sh)", absPath)
+}
+
+// dataSourceName builds a file: URI for absPath so special characters in the path are escaped
+// and the access mode and busy timeout are passed to SQLite
+// The file is not opened as immutable: SQLite then honours the locks and write-ahead log of a
+// MoneyWiz instance that has the database open, instead of reading a half-written file
+func dataSourceName(absPath string, opts Options) string {
+	timeout := opts.BusyTimeout
+	if timeout <= 0 {
+		timeout = DefaultBusyTimeout
+	}
+
+	query := "mode=ro"
+	if opts.ReadWrite {
+		query = "mode=rw"
+	}
+	query += fmt.Sprintf("&_busy_timeout=%d", timeout.Milliseconds())
+	dsn := &url.URL{Scheme: "file", Path: filepath.ToSlash(absPath), RawQuery: query}
+	return dsn.String()
+}
+
+// Path returns the absolute path of the database file
+func (db *DB) Path() string {
+	return db.path
 }
 
 // Close closes the database connection
@@ -0,0 +1,207 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	dateTimeLayout = "2006-01-02 15:04:05"
+	dayLayout      = "2006-01-02"
+	monthLayout    = "2006-01"
+	yearLayout     = "2006"
+
+	// fiscalYearPrefix marks a year bucket that does not start in January
+	fiscalYearPrefix = "FY"
+)
+
+// coreDataEpoch is the reference date for Core Data timestamps (seconds since 2001-01-01 UTC)
+var coreDataEpoch = time.Date(2001, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// coreDataToTime converts a Core Data timestamp to a UTC time
+// Fractional seconds are truncated, matching CAST(ZDATE1 AS INTEGER) in SQLite
+func coreDataToTime(v float64) time.Time {
+	return time.Unix(coreDataEpoch.Unix()+int64(v), 0).UTC()
+}
+
+// timeToCoreData converts a time to a Core Data timestamp
+func timeToCoreData(t time.Time) float64 {
+	return float64(t.Unix() - coreDataEpoch.Unix())
+}
+
+// parseDateToCoreData parses a YYYY-MM-DD, YYYY-MM or YYYY string and returns the
+// Core Data timestamp of the start of that day, month or year
+func parseDateToCoreData(s string) (float64, error) {
+	start, _, err := parseDatePeriod(s)
+	if err != nil {
+		return 0, err
+	}
+	return timeToCoreData(start), nil
+}
+
+// parseDatePeriod parses a YYYY-MM-DD, YYYY-MM or YYYY string and returns the start
+// of that period together with the start of the period that follows it (exclusive end)
+func parseDatePeriod(s string) (time.Time, time.Time, error) {
+	value := strings.TrimSpace(s)
+
+	layouts := []struct {
+		layout string
+		years  int
+		months int
+		days   int
+	}{
+		{layout: dayLayout, days: 1},
+		{layout: monthLayout, months: 1},
+		{layout: yearLayout, years: 1},
+	}
+
+	for _, l := range layouts {
+		if len(value) != len(l.layout) {
+			continue
+		}
+		start, err := time.ParseInLocation(l.layout, value, time.UTC)
+		if err != nil {
+			continue
+		}
+		return start, start.AddDate(l.years, l.months, l.days), nil
+	}
+
+	return time.Time{}, time.Time{}, invalidArgumentf("invalid date %q: expected YYYY-MM-DD, YYYY-MM, or YYYY", s)
+}
+
+// parseAsOf parses the YYYY-MM-DD day an analysis is anchored on, defaulting to today (UTC)
+// when s is empty; name is the argument reported in the error, e.g. "as_of date"
+func parseAsOf(name, s string) (time.Time, error) {
+	if strings.TrimSpace(s) == "" {
+		now := time.Now().UTC()
+		return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC), nil
+	}
+
+	start, end, err := parseDatePeriod(s)
+	if err != nil || !end.Equal(start.AddDate(0, 0, 1)) {
+		return time.Time{}, invalidArgumentf("invalid %s %q: expected YYYY-MM-DD", name, s)
+	}
+	return start, nil
+}
+
+// yearLabel returns the year bucket t falls in for a year starting in the start month: the
+// calendar year ("2024") when start is January, otherwise "FY" and the calendar year the fiscal
+// year ends in, so with an April start 2024-04-01 to 2025-03-31 is "FY2025"
+func yearLabel(t time.Time, start time.Month) string {
+	if start <= time.January {
+		return t.Format(yearLayout)
+	}
+	year := t.Year()
+	if t.Month() >= start {
+		year++
+	}
+	return fmt.Sprintf("%s%d", fiscalYearPrefix, year)
+}
+
+// parseYearLabel returns the year of a "2024" or "FY2024" label from yearLabel, and whether the
+// label was a fiscal year
+func parseYearLabel(label string) (year int, fiscal bool, err error) {
+	if rest, ok := strings.CutPrefix(label, fiscalYearPrefix); ok {
+		label, fiscal = rest, true
+	}
+	if len(label) != len(yearLayout) {
+		return 0, false, invalidArgumentf("invalid year %q", label)
+	}
+	year, err = strconv.Atoi(label)
+	if err != nil {
+		return 0, false, invalidArgumentf("invalid year %q", label)
+	}
+	return year, fiscal, nil
+}
+
+// formatYearLabel is the inverse of parseYearLabel
+func formatYearLabel(year int, fiscal bool) string {
+	if fiscal {
+		return fmt.Sprintf("%s%d", fiscalYearPrefix, year)
+	}
+	return fmt.Sprintf("%04d", year)
+}
+
+// monthSpan returns every YYYY-MM month from first to last inclusive
+func monthSpan(first, last string) []string {
+	start, err := time.Parse(monthLayout, first)
+	if err != nil {
+		return nil
+	}
+	end, err := time.Parse(monthLayout, last)
+	if err != nil {
+		return nil
+	}
+
+	var months []string
+	for current := start; !current.After(end); current = current.AddDate(0, 1, 0) {
+		months = append(months, current.Format(monthLayout))
+	}
+	return months
+}
+
+// addMonthsClamped moves t by n calendar months (negative n moves back), clamping the day to the
+// end of a shorter month (e.g. 31 March minus one month is 29 February in a leap year, not 2 March)
+func addMonthsClamped(t time.Time, n int) time.Time {
+	firstOfMonth := time.Date(t.Year(), t.Month(), 1, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+	target := firstOfMonth.AddDate(0, n, 0)
+	lastDay := target.AddDate(0, 1, -1).Day()
+	day := t.Day()
+	if day > lastDay {
+		day = lastDay
+	}
+	return target.AddDate(0, 0, day-1)
+}
+
+// monthsCutoff returns the Core Data timestamp lying months calendar months before the latest
+// transaction; months <= 0 or an empty database yield a cutoff that keeps every transaction
+// Unless includeScheduled is set, future-dated transactions do not count as the latest
+func (db *DB) monthsCutoff(ctx context.Context, months int, includeScheduled bool) (float64, error) {
+	noCutoff := math.Inf(-1)
+	if months <= 0 {
+		return noCutoff, nil
+	}
+
+	latest, ok, err := db.latestTransactionTime(ctx, includeScheduled)
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		return noCutoff, nil
+	}
+
+	return timeToCoreData(addMonthsClamped(latest, -months)), nil
+}
+
+// latestTransactionTime returns the date of the most recent transaction; ok is false when
+// there are no dated transactions
+// Unless includeScheduled is set, future-dated (scheduled) transactions are ignored
+func (db *DB) latestTransactionTime(ctx context.Context, includeScheduled bool) (time.Time, bool, error) {
+	var latest sql.NullFloat64
+	query := `SELECT MAX(ZDATE1) FROM ZSYNCOBJECT WHERE Z_ENT IN (37, 45, 46, 47, 43) AND ZDATE1 IS NOT NULL AND ZDATE1 <= ?`
+	err := db.runQuery(ctx, "latestTransactionTime", func() error {
+		return db.reader().QueryRowContext(ctx, query, scheduledCutoff(includeScheduled)).Scan(&latest)
+	})
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("failed to query latest transaction date: %w", err)
+	}
+	if !latest.Valid {
+		return time.Time{}, false, nil
+	}
+	return coreDataToTime(latest.Float64), true, nil
+}
+
+// scheduledCutoff returns the latest Core Data timestamp to read: now, so that scheduled
+// transactions dated in the future do not distort historical analysis, or no limit when
+// includeScheduled is set
+func scheduledCutoff(includeScheduled bool) float64 {
+	if includeScheduled {
+		return math.Inf(1)
+	}
+	return timeToCoreData(time.Now())
+}
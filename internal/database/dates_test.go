@@ -0,0 +1,182 @@
+package database
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseDateToCoreData(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  time.Time
+	}{
+		{name: "day", input: "2024-02-10", want: time.Date(2024, 2, 10, 0, 0, 0, 0, time.UTC)},
+		{name: "month", input: "2024-02", want: time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{name: "year", input: "2024", want: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{name: "surrounding whitespace", input: " 2024-02-10 ", want: time.Date(2024, 2, 10, 0, 0, 0, 0, time.UTC)},
+		{name: "core data epoch", input: "2001-01-01", want: coreDataEpoch},
+		{name: "before epoch", input: "1999-12-31", want: time.Date(1999, 12, 31, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := parseDateToCoreData(tc.input)
+			if err != nil {
+				t.Fatalf("parseDateToCoreData(%q): %v", tc.input, err)
+			}
+			want := tc.want.Sub(coreDataEpoch).Seconds()
+			if got != want {
+				t.Fatalf("parseDateToCoreData(%q) = %v, want %v", tc.input, got, want)
+			}
+		})
+	}
+}
+
+func TestParseDateToCoreDataRejectsInvalidInput(t *testing.T) {
+	inputs := []string{
+		"",
+		"   ",
+		"not-a-date",
+		"2024-13",
+		"2024-00",
+		"2024-02-30",
+		"2024-2-1",
+		"2024/02/10",
+		"24-02-10",
+		"20240",
+		"2024-02-10T10:00:00Z",
+		"10.02.2024",
+	}
+
+	for _, input := range inputs {
+		if _, err := parseDateToCoreData(input); err == nil {
+			t.Fatalf("parseDateToCoreData(%q) unexpectedly succeeded", input)
+		}
+	}
+}
+
+func TestParseDatePeriodReturnsExclusiveEnd(t *testing.T) {
+	tests := []struct {
+		input     string
+		wantStart string
+		wantEnd   string
+	}{
+		{input: "2024-02-29", wantStart: "2024-02-29", wantEnd: "2024-03-01"},
+		{input: "2024-12-31", wantStart: "2024-12-31", wantEnd: "2025-01-01"},
+		{input: "2024-02", wantStart: "2024-02-01", wantEnd: "2024-03-01"},
+		{input: "2024-12", wantStart: "2024-12-01", wantEnd: "2025-01-01"},
+		{input: "2024", wantStart: "2024-01-01", wantEnd: "2025-01-01"},
+	}
+
+	for _, tc := range tests {
+		start, end, err := parseDatePeriod(tc.input)
+		if err != nil {
+			t.Fatalf("parseDatePeriod(%q): %v", tc.input, err)
+		}
+		if got := start.Format(dayLayout); got != tc.wantStart {
+			t.Fatalf("parseDatePeriod(%q) start = %s, want %s", tc.input, got, tc.wantStart)
+		}
+		if got := end.Format(dayLayout); got != tc.wantEnd {
+			t.Fatalf("parseDatePeriod(%q) end = %s, want %s", tc.input, got, tc.wantEnd)
+		}
+	}
+}
+
+func TestParseAsOf(t *testing.T) {
+	got, err := parseAsOf("as_of date", " 2024-02-29 ")
+	if err != nil {
+		t.Fatalf("parseAsOf: %v", err)
+	}
+	if want := time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Fatalf("parseAsOf = %v, want %v", got, want)
+	}
+
+	now := time.Now().UTC()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	if got, err := parseAsOf("as_of date", ""); err != nil || !got.Equal(today) {
+		t.Fatalf("parseAsOf(\"\") = %v, %v, want %v", got, err, today)
+	}
+
+	for _, input := range []string{"2024-02", "2024", "2024-02-30", "10.02.2024"} {
+		_, err := parseAsOf("end_date", input)
+		if err == nil {
+			t.Fatalf("parseAsOf(%q) unexpectedly succeeded", input)
+		}
+		if want := `invalid end_date "` + input + `": expected YYYY-MM-DD`; err.Error() != want {
+			t.Fatalf("parseAsOf(%q) error = %q, want %q", input, err.Error(), want)
+		}
+	}
+}
+
+func TestCoreDataToTime(t *testing.T) {
+	tests := []struct {
+		name  string
+		input float64
+		want  string
+	}{
+		{name: "epoch", input: 0, want: "2001-01-01 00:00:00"},
+		{name: "fraction truncates", input: 59.9, want: "2001-01-01 00:00:59"},
+		{name: "known date", input: time.Date(2024, 2, 10, 13, 45, 0, 0, time.UTC).Sub(coreDataEpoch).Seconds(), want: "2024-02-10 13:45:00"},
+		{name: "before epoch", input: -86400, want: "2000-12-31 00:00:00"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := coreDataToTime(tc.input).Format(dateTimeLayout); got != tc.want {
+				t.Fatalf("coreDataToTime(%v) = %s, want %s", tc.input, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestCoreDataRoundTrip(t *testing.T) {
+	ts := time.Date(2023, 7, 4, 8, 30, 15, 0, time.UTC)
+	if got := coreDataToTime(timeToCoreData(ts)); !got.Equal(ts) {
+		t.Fatalf("round trip = %s, want %s", got, ts)
+	}
+}
+
+func TestMonthSpan(t *testing.T) {
+	got := monthSpan("2023-11", "2024-02")
+	want := []string{"2023-11", "2023-12", "2024-01", "2024-02"}
+	if len(got) != len(want) {
+		t.Fatalf("monthSpan len = %d, want %d (%v)", len(got), len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("monthSpan[%d] = %q, want %q", i, got[i], want[i])
+		}
+	}
+
+	if got := monthSpan("", "2024-02"); len(got) != 0 {
+		t.Fatalf("monthSpan with empty start = %v, want empty", got)
+	}
+	if got := monthSpan("2024-03", "2024-02"); len(got) != 0 {
+		t.Fatalf("monthSpan with reversed range = %v, want empty", got)
+	}
+}
+
+func TestAddMonthsClampedClampsToMonthEnd(t *testing.T) {
+	tests := []struct {
+		name   string
+		from   time.Time
+		months int
+		want   time.Time
+	}{
+		{name: "same day", from: time.Date(2024, 2, 10, 12, 0, 0, 0, time.UTC), months: -1, want: time.Date(2024, 1, 10, 12, 0, 0, 0, time.UTC)},
+		{name: "leap february", from: time.Date(2024, 3, 31, 0, 0, 0, 0, time.UTC), months: -1, want: time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{name: "short month", from: time.Date(2024, 5, 31, 0, 0, 0, 0, time.UTC), months: -1, want: time.Date(2024, 4, 30, 0, 0, 0, 0, time.UTC)},
+		{name: "across year", from: time.Date(2024, 2, 15, 0, 0, 0, 0, time.UTC), months: -3, want: time.Date(2023, 11, 15, 0, 0, 0, 0, time.UTC)},
+		{name: "forward to short month", from: time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC), months: 1, want: time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{name: "full year", from: time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC), months: -12, want: time.Date(2023, 2, 28, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := addMonthsClamped(tc.from, tc.months); !got.Equal(tc.want) {
+				t.Fatalf("addMonthsClamped(%v, %d) = %v, want %v", tc.from, tc.months, got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"time"
+)
+
+const (
+	// averageDaysPerMonth is the mean Gregorian month length, used to turn months into a date
+	averageDaysPerMonth = 365.2425 / 12
+	// maxProjectionMonths caps projections that would land more than a century out
+	maxProjectionMonths = 1200
+)
+
+// AccountDepletionProjection represents when an account is projected to reach a target balance
+type AccountDepletionProjection struct {
+	AccountID               int64    `json:"account_id"`
+	AccountName             string   `json:"account_name"`
+	Currency                string   `json:"currency"`
+	CurrentBalance          float64  `json:"current_balance" money:"amount"`
+	TargetBalance           float64  `json:"target_balance" money:"amount"`
+	HistoryMonths           int      `json:"history_months"`
+	AsOf                    string   `json:"as_of,omitempty"` // Date of the account's latest transaction, the projection start
+	AverageMonthlyNetChange float64  `json:"average_monthly_net_change" money:"amount"`
+	Status                  string   `json:"status"` // reached, depleting, accumulating, growing, declining, or flat
+	MonthsUntilTarget       *float64 `json:"months_until_target,omitempty"`
+	ProjectedDate           string   `json:"projected_date,omitempty"` // YYYY-MM-DD
+	Message                 string   `json:"message"`
+}
+
+// ProjectAccountDepletion projects when an account reaches targetBalance (usually 0) at its
+// average monthly net change over the last historyMonths calendar months of its activity
+func (db *DB) ProjectAccountDepletion(ctx context.Context, accountID int64, historyMonths int, targetBalance float64) (*AccountDepletionProjection, error) {
+	if historyMonths <= 0 {
+		historyMonths = 6
+	}
+
+	account, err := db.GetAccountBalance(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	movementsQuery, err := db.accountMovementsQuery(ctx)
+	if err != nil {
+		return nil, err
+	}
+	query := `SELECT amount, date FROM (` + movementsQuery + `) WHERE account_id = ? AND date IS NOT NULL`
+
+	type movement struct {
+		amount float64
+		date   time.Time
+	}
+	var movements []movement
+	var latest time.Time
+	err = db.queryEach(ctx, "ProjectAccountDepletion", query, []any{accountID}, func(rows *sql.Rows) error {
+		var amount float64
+		var date float64
+		if err := rows.Scan(&amount, &date); err != nil {
+			return fmt.Errorf("failed to scan account transaction: %w", err)
+		}
+		ts := coreDataToTime(date)
+		if ts.After(latest) {
+			latest = ts
+		}
+		movements = append(movements, movement{amount: amount, date: ts})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query account transactions: %w", err)
+	}
+
+	projection := &AccountDepletionProjection{
+		AccountID:      account.ID,
+		AccountName:    account.Name,
+		Currency:       account.Currency,
+		CurrentBalance: account.Balance,
+		TargetBalance:  targetBalance,
+		HistoryMonths:  historyMonths,
+	}
+
+	if !latest.IsZero() {
+		projection.AsOf = latest.Format(dayLayout)
+		windowStart := time.Date(latest.Year(), latest.Month(), 1, 0, 0, 0, 0, time.UTC).AddDate(0, -(historyMonths - 1), 0)
+		var net float64
+		for _, m := range movements {
+			if !m.date.Before(windowStart) {
+				net += m.amount
+			}
+		}
+		projection.AverageMonthlyNetChange = net / float64(historyMonths)
+	}
+
+	gap := targetBalance - account.Balance
+	change := projection.AverageMonthlyNetChange
+	switch {
+	case math.Abs(gap) < 0.005:
+		projection.Status = "reached"
+		projection.Message = "The account is already at the target balance."
+		return projection, nil
+	case math.Abs(change) < 0.005:
+		projection.Status = "flat"
+		projection.Message = "The balance has not changed on average, so it will not reach the target at the current rate."
+		return projection, nil
+	case gap < 0 && change > 0:
+		projection.Status = "growing"
+		projection.Message = "The balance is growing and will not deplete to the target at the current rate."
+		return projection, nil
+	case gap > 0 && change < 0:
+		projection.Status = "declining"
+		projection.Message = "The balance is declining and will not grow to the target at the current rate."
+		return projection, nil
+	case change < 0:
+		projection.Status = "depleting"
+	default:
+		projection.Status = "accumulating"
+	}
+
+	monthsUntil := gap / change
+	projection.MonthsUntilTarget = &monthsUntil
+	if monthsUntil > maxProjectionMonths {
+		projection.Message = "At the current rate the target is more than 100 years away."
+		return projection, nil
+	}
+
+	days := monthsUntil * averageDaysPerMonth
+	projectedDate := latest.Add(time.Duration(days * float64(24*time.Hour)))
+	projection.ProjectedDate = projectedDate.Format(dayLayout)
+	projection.Message = fmt.Sprintf("At %.2f per month the balance reaches %.2f in about %.1f months (%s).",
+		change, targetBalance, monthsUntil, projection.ProjectedDate)
+
+	return projection, nil
+}
@@ -0,0 +1,113 @@
+package database
+
+import (
+	"context"
+	"sort"
+	"time"
+)
+
+const defaultDormantDays = 180
+
+// DormantAccount represents an account without a transaction for a while
+type DormantAccount struct {
+	ID                       int64   `json:"id"`
+	Name                     string  `json:"name"`
+	Currency                 string  `json:"currency"`
+	AccountType              string  `json:"account_type"`
+	Group                    string  `json:"group,omitempty"`
+	Balance                  float64 `json:"balance" money:"amount"`
+	TransactionCount         int     `json:"transaction_count"`
+	LastTransactionDate      string  `json:"last_transaction_date,omitempty"`
+	DaysSinceLastTransaction *int    `json:"days_since_last_transaction"` // null when it never had one
+	NeverUsed                bool    `json:"never_used"`
+	ZeroBalance              bool    `json:"zero_balance"` // Within a cent of zero, so it can be closed without moving money
+}
+
+// DormantAccountsReport represents the accounts whose last transaction is older than a threshold
+type DormantAccountsReport struct {
+	AsOf              string             `json:"as_of"` // YYYY-MM-DD
+	Days              int                `json:"days"`
+	AccountCount      int                `json:"account_count"` // Accounts checked
+	DormantCount      int                `json:"dormant_count"`
+	NeverUsedCount    int                `json:"never_used_count"`
+	BalanceByCurrency map[string]float64 `json:"balance_by_currency" money:"by_currency"` // Money still held in the dormant accounts
+	Accounts          []DormantAccount   `json:"accounts"`                                // Never used first, then longest idle first
+}
+
+// GetDormantAccounts lists the accounts whose most recent transaction is more than days before
+// asOfDate, with that date and the current balance, to find accounts that are no longer used
+// Accounts that never had a transaction are listed as never used
+// days: how long an account must have been idle (0 = 180)
+// asOfDate: the YYYY-MM-DD day to count from ("" = today)
+func (db *DB) GetDormantAccounts(ctx context.Context, days int, asOfDate string) (*DormantAccountsReport, error) {
+	if days < 0 {
+		return nil, invalidArgumentf("days must not be negative, got %d", days)
+	}
+	if days == 0 {
+		days = defaultDormantDays
+	}
+	asOf, err := parseAsOf("as_of date", asOfDate)
+	if err != nil {
+		return nil, err
+	}
+
+	accounts, err := db.GetAccounts(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &DormantAccountsReport{
+		AsOf:              asOf.Format(dayLayout),
+		Days:              days,
+		AccountCount:      len(accounts),
+		BalanceByCurrency: make(map[string]float64),
+		Accounts:          []DormantAccount{},
+	}
+	for _, acc := range accounts {
+		dormant := DormantAccount{
+			ID:                  acc.ID,
+			Name:                acc.Name,
+			Currency:            acc.Currency,
+			AccountType:         acc.AccountType,
+			Group:               acc.Group,
+			Balance:             acc.Balance,
+			TransactionCount:    acc.TransactionCount,
+			LastTransactionDate: acc.LastTransactionDate,
+			ZeroBalance:         acc.Balance > -balanceMismatchTolerance && acc.Balance < balanceMismatchTolerance,
+		}
+		if acc.LastTransactionDate == "" {
+			dormant.NeverUsed = true
+			report.NeverUsedCount++
+		} else {
+			last, err := time.Parse(dateTimeLayout, acc.LastTransactionDate)
+			if err != nil {
+				continue
+			}
+			lastDay := time.Date(last.Year(), last.Month(), last.Day(), 0, 0, 0, 0, time.UTC)
+			idle := int(asOf.Sub(lastDay).Hours() / 24)
+			if idle <= days {
+				continue
+			}
+			dormant.DaysSinceLastTransaction = &idle
+		}
+
+		if dormant.Currency != "" {
+			report.BalanceByCurrency[dormant.Currency] += dormant.Balance
+		}
+		report.Accounts = append(report.Accounts, dormant)
+	}
+	report.DormantCount = len(report.Accounts)
+
+	sort.SliceStable(report.Accounts, func(i, j int) bool {
+		a, b := report.Accounts[i], report.Accounts[j]
+		if a.NeverUsed != b.NeverUsed {
+			return a.NeverUsed
+		}
+		if a.NeverUsed {
+			return false
+		}
+		return *a.DaysSinceLastTransaction > *b.DaysSinceLastTransaction
+	})
+
+	return report, nil
+}
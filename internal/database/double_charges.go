@@ -0,0 +1,164 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+const (
+	defaultDoubleChargeWindowHours  = 72
+	defaultDoubleChargeTolerancePct = 5.0
+)
+
+// ChargeDetail represents one side of a possible double charge
+type ChargeDetail struct {
+	TransactionID int64   `json:"transaction_id"`
+	AccountID     int64   `json:"account_id"`
+	Date          string  `json:"date"`
+	Amount        float64 `json:"amount" money:"amount"`
+	Description   string  `json:"description"`
+	CategoryName  string  `json:"category_name"`
+}
+
+// DoubleChargeCandidate represents two charges from the same merchant close together in time
+type DoubleChargeCandidate struct {
+	Payee                   string       `json:"payee"` // Normalized merchant name
+	Currency                string       `json:"currency"`
+	First                   ChargeDetail `json:"first"`
+	Second                  ChargeDetail `json:"second"`
+	HoursApart              float64      `json:"hours_apart"`
+	AmountDifference        float64      `json:"amount_difference" money:"amount"`
+	AmountDifferencePercent float64      `json:"amount_difference_percent"` // Relative to the larger charge
+}
+
+// DoubleChargeReport represents the result of a double-charge scan
+type DoubleChargeReport struct {
+	Months             int                     `json:"months"`
+	WindowHours        int                     `json:"window_hours"`
+	AmountTolerancePct float64                 `json:"amount_tolerance_pct"`
+	CandidateCount     int                     `json:"candidate_count"`
+	Candidates         []DoubleChargeCandidate `json:"candidates"`
+}
+
+// DetectPossibleDoubleCharges flags expenses from the same merchant that happened within
+// windowHours of each other with amounts differing by at most amountTolerancePct percent
+// months: number of months to look back (0 = all data)
+func (db *DB) DetectPossibleDoubleCharges(ctx context.Context, months int, windowHours int, amountTolerancePct float64) (*DoubleChargeReport, error) {
+	if windowHours <= 0 {
+		windowHours = defaultDoubleChargeWindowHours
+	}
+	if amountTolerancePct < 0 {
+		amountTolerancePct = defaultDoubleChargeTolerancePct
+	}
+
+	spendingData, err := db.GetSpendingData(ctx, months, false, nil, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get spending data: %w", err)
+	}
+
+	type charge struct {
+		data SpendingData
+		at   time.Time
+	}
+
+	// Group by merchant and currency so amounts are comparable
+	groups := make(map[string][]charge)
+	var groupKeys []string
+	for _, s := range spendingData {
+		payee := normalizePayee(payeeOrDescription(s))
+		if payee == "" || s.Date == "" {
+			continue
+		}
+		at, err := time.Parse(dateTimeLayout, s.Date)
+		if err != nil {
+			continue
+		}
+		key := payee + "\x00" + s.Currency
+		if _, exists := groups[key]; !exists {
+			groupKeys = append(groupKeys, key)
+		}
+		groups[key] = append(groups[key], charge{data: s, at: at})
+	}
+	sort.Strings(groupKeys)
+
+	window := time.Duration(windowHours) * time.Hour
+	candidates := []DoubleChargeCandidate{}
+	for _, key := range groupKeys {
+		charges := groups[key]
+		sort.Slice(charges, func(i, j int) bool {
+			if !charges[i].at.Equal(charges[j].at) {
+				return charges[i].at.Before(charges[j].at)
+			}
+			return charges[i].data.TransactionID < charges[j].data.TransactionID
+		})
+
+		payee := key[:strings.IndexByte(key, 0)]
+		for i := 0; i < len(charges); i++ {
+			for j := i + 1; j < len(charges); j++ {
+				gap := charges[j].at.Sub(charges[i].at)
+				if gap > window {
+					break
+				}
+
+				first, second := charges[i].data, charges[j].data
+				difference := math.Abs(first.Amount - second.Amount)
+				larger := math.Max(first.Amount, second.Amount)
+				differencePercent := 0.0
+				if larger > 0 {
+					differencePercent = (difference / larger) * 100
+				}
+				if differencePercent > amountTolerancePct {
+					continue
+				}
+
+				candidates = append(candidates, DoubleChargeCandidate{
+					Payee:                   payee,
+					Currency:                first.Currency,
+					First:                   newChargeDetail(first),
+					Second:                  newChargeDetail(second),
+					HoursApart:              gap.Hours(),
+					AmountDifference:        difference,
+					AmountDifferencePercent: differencePercent,
+				})
+			}
+		}
+	}
+
+	return &DoubleChargeReport{
+		Months:             months,
+		WindowHours:        windowHours,
+		AmountTolerancePct: amountTolerancePct,
+		CandidateCount:     len(candidates),
+		Candidates:         candidates,
+	}, nil
+}
+
+func newChargeDetail(s SpendingData) ChargeDetail {
+	return ChargeDetail{
+		TransactionID: s.TransactionID,
+		AccountID:     s.AccountID,
+		Date:          s.Date,
+		Amount:        s.Amount,
+		Description:   s.Description,
+		CategoryName:  s.CategoryName,
+	}
+}
+
+// normalizePayee reduces a merchant description to lowercase letters and single spaces
+// so that "AMAZON MKTPL*1234" and "Amazon Mktpl 5678" compare equal
+func normalizePayee(description string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(description) {
+		if unicode.IsLetter(r) {
+			b.WriteRune(r)
+		} else {
+			b.WriteRune(' ')
+		}
+	}
+	return strings.Join(strings.Fields(b.String()), " ")
+}
@@ -0,0 +1,143 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// maxDuplicateDateToleranceDays bounds how far apart two copies of an imported transaction may be
+const maxDuplicateDateToleranceDays = 31
+
+// DuplicateGroup represents transactions that look like copies of one another
+type DuplicateGroup struct {
+	AccountID    int64         `json:"account_id"`
+	AccountName  string        `json:"account_name"`
+	Amount       float64       `json:"amount" money:"amount"` // Amount of every copy; expenses are negative
+	Currency     string        `json:"currency"`
+	Description  string        `json:"description"`
+	Count        int           `json:"count"`
+	FirstDate    string        `json:"first_date"`
+	LastDate     string        `json:"last_date"`
+	DaysApart    int           `json:"days_apart"`                  // Calendar days from the first copy to the last
+	ExtraAmount  float64       `json:"extra_amount" money:"amount"` // Amount of the copies beyond the first
+	Transactions []Transaction `json:"transactions"`                // Oldest first
+}
+
+// DuplicateTransactionsReport represents the result of a duplicate-transaction scan
+type DuplicateTransactionsReport struct {
+	Months            int              `json:"months"`
+	DateToleranceDays int              `json:"date_tolerance_days"`
+	GroupCount        int              `json:"group_count"`
+	DuplicateCount    int              `json:"duplicate_count"` // Transactions beyond the first of each group
+	Groups            []DuplicateGroup `json:"groups"`          // Most recent first
+}
+
+// DetectDuplicateTransactions groups transactions with the same account, amount, and
+// description whose dates are at most dateToleranceDays apart, and reports every group with
+// more than one member, as left behind by importing the same statement twice
+// Descriptions match without regard to case or surrounding spaces; a chain of copies each within
+// the tolerance of the previous one forms one group
+// months: number of months to look back from the latest transaction (0 = all data)
+// dateToleranceDays: 0 matches the same calendar day only, at most 31
+// Transfers are scanned too, since an import can duplicate them like any other transaction
+func (db *DB) DetectDuplicateTransactions(ctx context.Context, months, dateToleranceDays int) (*DuplicateTransactionsReport, error) {
+	if dateToleranceDays < 0 || dateToleranceDays > maxDuplicateDateToleranceDays {
+		return nil, invalidArgumentf("date tolerance must be between 0 and %d days, got %d", maxDuplicateDateToleranceDays, dateToleranceDays)
+	}
+
+	cutoff, err := db.monthsCutoff(ctx, months, false)
+	if err != nil {
+		return nil, err
+	}
+	transactions, err := db.GetTransactions(ctx, TransactionFilter{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get transactions: %w", err)
+	}
+
+	type copyOf struct {
+		txn Transaction
+		day time.Time
+	}
+
+	groups := make(map[string][]copyOf)
+	seen := make(map[int64]bool, len(transactions))
+	for _, txn := range transactions {
+		// A transaction split over several categories is listed once per category
+		if seen[txn.ID] {
+			continue
+		}
+		seen[txn.ID] = true
+		at, err := time.Parse(dateTimeLayout, txn.Date)
+		if err != nil || timeToCoreData(at) < cutoff {
+			continue
+		}
+		// Amounts are compared in cents so float noise does not split a group
+		key := fmt.Sprintf("%d\x00%d\x00%s", txn.AccountID, int64(math.Round(txn.Amount*100)), strings.ToLower(strings.TrimSpace(txn.Description)))
+		day := time.Date(at.Year(), at.Month(), at.Day(), 0, 0, 0, 0, time.UTC)
+		groups[key] = append(groups[key], copyOf{txn: txn, day: day})
+	}
+
+	report := &DuplicateTransactionsReport{
+		Months:            months,
+		DateToleranceDays: dateToleranceDays,
+		Groups:            []DuplicateGroup{},
+	}
+	tolerance := time.Duration(dateToleranceDays) * 24 * time.Hour
+	flush := func(copies []copyOf) {
+		if len(copies) < 2 {
+			return
+		}
+		first, last := copies[0], copies[len(copies)-1]
+		group := DuplicateGroup{
+			AccountID:    first.txn.AccountID,
+			AccountName:  first.txn.AccountName,
+			Amount:       first.txn.Amount,
+			Currency:     first.txn.Currency,
+			Description:  first.txn.Description,
+			Count:        len(copies),
+			FirstDate:    first.txn.Date,
+			LastDate:     last.txn.Date,
+			DaysApart:    int(last.day.Sub(first.day).Hours() / 24),
+			ExtraAmount:  first.txn.Amount * float64(len(copies)-1),
+			Transactions: make([]Transaction, 0, len(copies)),
+		}
+		for _, c := range copies {
+			group.Transactions = append(group.Transactions, c.txn)
+		}
+		report.Groups = append(report.Groups, group)
+		report.DuplicateCount += len(copies) - 1
+	}
+
+	for _, copies := range groups {
+		sort.Slice(copies, func(i, j int) bool {
+			if !copies[i].day.Equal(copies[j].day) {
+				return copies[i].day.Before(copies[j].day)
+			}
+			return copies[i].txn.ID < copies[j].txn.ID
+		})
+
+		start := 0
+		for i := 1; i < len(copies); i++ {
+			if copies[i].day.Sub(copies[i-1].day) > tolerance {
+				flush(copies[start:i])
+				start = i
+			}
+		}
+		flush(copies[start:])
+	}
+
+	sort.Slice(report.Groups, func(i, j int) bool {
+		a, b := report.Groups[i], report.Groups[j]
+		if a.LastDate != b.LastDate {
+			return a.LastDate > b.LastDate
+		}
+		return a.Transactions[0].ID < b.Transactions[0].ID
+	})
+	report.GroupCount = len(report.Groups)
+
+	return report, nil
+}
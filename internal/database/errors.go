@@ -0,0 +1,48 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Sentinel errors that callers can test with errors.Is to tell bad input apart from
+// missing data and database failures
+var (
+	ErrNotFound        = errors.New("not found")
+	ErrInvalidArgument = errors.New("invalid argument")
+)
+
+// Sentinel errors returned by NewDB when the file cannot be served, so callers can tell a wrong
+// path apart from a file that is not a MoneyWiz database
+var (
+	ErrDatabaseMissing = errors.New("database file not found")
+	ErrNotSQLite       = errors.New("not a SQLite database")
+	ErrNotMoneyWiz     = errors.New("not a MoneyWiz database")
+)
+
+// kindError keeps the message of the wrapped error while matching one of the sentinel errors
+type kindError struct {
+	kind error
+	err  error
+}
+
+func (e *kindError) Error() string { return e.err.Error() }
+
+func (e *kindError) Unwrap() error { return e.err }
+
+func (e *kindError) Is(target error) bool { return target == e.kind }
+
+// notFoundf formats an error that matches ErrNotFound
+func notFoundf(format string, args ...any) error {
+	return &kindError{kind: ErrNotFound, err: fmt.Errorf(format, args...)}
+}
+
+// invalidArgumentf formats an error that matches ErrInvalidArgument
+func invalidArgumentf(format string, args ...any) error {
+	return &kindError{kind: ErrInvalidArgument, err: fmt.Errorf(format, args...)}
+}
+
+// kindErrorf formats an error that matches the given sentinel error
+func kindErrorf(kind error, format string, args ...any) error {
+	return &kindError{kind: kind, err: fmt.Errorf(format, args...)}
+}
@@ -0,0 +1,83 @@
+package database
+
+import (
+	"context"
+	"time"
+)
+
+// ExpenseRatioMonth represents the spending of one month as a share of its income
+type ExpenseRatioMonth struct {
+	Month   string   `json:"month"` // YYYY-MM
+	Income  float64  `json:"income" money:"amount"`
+	Expense float64  `json:"expense" money:"amount"`
+	Ratio   *float64 `json:"ratio"`   // Expense / income; null when there was no income
+	Partial bool     `json:"partial"` // The current month, still in progress
+}
+
+// ExpenseRatioTrend represents the expense-to-income ratio month by month
+type ExpenseRatioTrend struct {
+	Months          int                 `json:"months"`
+	AverageRatio    *float64            `json:"average_ratio"` // Total expense / total income of the complete months; null without income
+	LatestRatio     *float64            `json:"latest_ratio"`  // Ratio of the latest complete month with income
+	Periods         []ExpenseRatioMonth `json:"periods"`       // Oldest first, without gaps
+	MixedCurrencies bool                `json:"mixed_currencies"`
+	Currencies      []string            `json:"currencies"`
+	CurrencyWarning string              `json:"currency_warning,omitempty"`
+}
+
+// GetExpenseRatioTrend returns the expense-to-income ratio of every month, so a ratio creeping up
+// towards 1 shows even while income still covers spending
+// The month containing today is flagged as partial and left out of the average and latest ratio,
+// as its income and spending are not all in yet
+// months: number of months to analyze (0 = all historical data)
+// includeTransfers: count transfers between own accounts (excluded by default)
+// Future-dated scheduled transactions are left out, as in the analyses
+func (db *DB) GetExpenseRatioTrend(ctx context.Context, months int, includeTransfers bool) (*ExpenseRatioTrend, error) {
+	periods, err := db.AnalyzeNetSavingsTrend(ctx, "month", months, includeTransfers, false)
+	if err != nil {
+		return nil, err
+	}
+
+	trend := &ExpenseRatioTrend{
+		Months:  months,
+		Periods: make([]ExpenseRatioMonth, 0, len(periods)),
+	}
+	currentMonth := time.Now().UTC().Format(monthLayout)
+	currencySet := make(map[string]struct{})
+	var completeIncome, completeExpense float64
+	for _, period := range periods {
+		month := ExpenseRatioMonth{
+			Month:   period.Period,
+			Income:  period.Income,
+			Expense: period.Spending,
+			Partial: period.Period == currentMonth,
+		}
+		if period.Income > 0 {
+			ratio := period.Spending / period.Income
+			month.Ratio = &ratio
+		}
+		if !month.Partial {
+			completeIncome += period.Income
+			completeExpense += period.Spending
+			if month.Ratio != nil {
+				trend.LatestRatio = month.Ratio
+			}
+		}
+		for currency := range period.ByCurrency {
+			currencySet[currency] = struct{}{}
+		}
+		trend.Periods = append(trend.Periods, month)
+	}
+	if completeIncome > 0 {
+		average := completeExpense / completeIncome
+		trend.AverageRatio = &average
+	}
+
+	trend.Currencies = sortedCurrencyKeys(currencySet)
+	trend.MixedCurrencies = len(trend.Currencies) > 1
+	if trend.MixedCurrencies {
+		trend.CurrencyWarning = "Ratios combine income and spending in multiple currencies without conversion."
+	}
+
+	return trend, nil
+}
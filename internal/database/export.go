@@ -0,0 +1,70 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// transactionCSVHeader is the header row written by ExportTransactionsCSV
+var transactionCSVHeader = []string{"id", "date", "amount", "description", "payee", "category", "account"}
+
+// ExportTransactionsCSV returns every transaction matching the filter as CSV text, most recent
+// first, with a header row. Fields are quoted per RFC 4180 when they contain commas, quotes or
+// line breaks. filter.Limit 0 exports all matching transactions
+func (db *DB) ExportTransactionsCSV(ctx context.Context, filter TransactionFilter) (string, error) {
+	transactions, err := db.GetTransactions(ctx, filter)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+	writer.UseCRLF = true // RFC 4180 line endings
+
+	if err := writer.Write(transactionCSVHeader); err != nil {
+		return "", fmt.Errorf("failed to write CSV header: %w", err)
+	}
+	for _, txn := range transactions {
+		record := []string{
+			strconv.FormatInt(txn.ID, 10),
+			txn.Date,
+			strconv.FormatFloat(txn.Amount, 'f', -1, 64),
+			txn.Description,
+			txn.Payee,
+			txn.CategoryName,
+			txn.AccountName,
+		}
+		if err := writer.Write(record); err != nil {
+			return "", fmt.Errorf("failed to write CSV row for transaction %d: %w", txn.ID, err)
+		}
+	}
+
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return "", fmt.Errorf("failed to write CSV: %w", err)
+	}
+
+	return buf.String(), nil
+}
+
+// WriteTransactionsJSONL writes every transaction matching the filter to w as JSON Lines, one
+// compact object per line, most recent first, and returns how many were written
+// Each transaction is encoded as it is read, so a multi-year export never builds one large array;
+// filter.Limit 0 writes all matching transactions
+func (db *DB) WriteTransactionsJSONL(ctx context.Context, filter TransactionFilter, w io.Writer) (int, error) {
+	encoder := json.NewEncoder(w)
+	count := 0
+	err := db.eachTransaction(ctx, filter, func(txn Transaction) error {
+		if err := encoder.Encode(txn); err != nil {
+			return fmt.Errorf("failed to write transaction %d: %w", txn.ID, err)
+		}
+		count++
+		return nil
+	})
+	return count, err
+}
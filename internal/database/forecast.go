@@ -0,0 +1,274 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+const (
+	defaultForecastMonths        = 6
+	defaultForecastHistoryMonths = 6
+	// forecastRecurringLookbackMonths is the history scanned for recurring items, long enough
+	// to see three yearly renewals
+	forecastRecurringLookbackMonths = 36
+	// minForecastHistoryMonths is the number of months with activity below which the
+	// forecast is flagged as low confidence
+	minForecastHistoryMonths = 2
+	// recurringGraceDays lets a recurring item be a little late and still count as active
+	recurringGraceDays = 10
+)
+
+const (
+	// MaxForecastMonths is the furthest ForecastCashFlow projects (5 years); a larger months
+	// is clamped to it
+	MaxForecastMonths = 60
+	// MaxForecastHistoryMonths is the longest history ForecastCashFlow averages over (10 years);
+	// a larger historyMonths is clamped to it
+	MaxForecastHistoryMonths = 120
+)
+
+// ForecastMonth represents the projected cash flow of one future month
+// Recurring amounts come from detected recurring series; projected amounts are the historical
+// monthly average of everything else
+type ForecastMonth struct {
+	Month             string  `json:"month"` // YYYY-MM
+	RecurringIncome   float64 `json:"recurring_income" money:"amount"`
+	ProjectedIncome   float64 `json:"projected_income" money:"amount"`
+	TotalIncome       float64 `json:"total_income" money:"amount"`
+	RecurringSpending float64 `json:"recurring_spending" money:"amount"`
+	ProjectedSpending float64 `json:"projected_spending" money:"amount"`
+	TotalSpending     float64 `json:"total_spending" money:"amount"`
+	NetSavings        float64 `json:"net_savings" money:"amount"`
+	EndBalance        float64 `json:"end_balance" money:"amount"` // Starting balance plus cumulative net savings
+}
+
+// Forecast represents a cash-flow projection for the coming months
+type Forecast struct {
+	AsOf                     string            `json:"as_of"` // Date of the latest transaction
+	Months                   int               `json:"months"`
+	HistoryMonths            int               `json:"history_months"`
+	MonthsWithData           int               `json:"months_with_data"` // Months of history that had activity
+	LowConfidence            bool              `json:"low_confidence"`
+	ConfidenceNote           string            `json:"confidence_note,omitempty"`
+	StartingBalance          float64           `json:"starting_balance" money:"amount"` // Current net worth across all accounts
+	AverageVariableIncome    float64           `json:"average_variable_income" money:"amount"`
+	AverageVariableSpending  float64           `json:"average_variable_spending" money:"amount"`
+	RecurringIncome          []RecurringSeries `json:"recurring_income"`
+	RecurringSpending        []RecurringSeries `json:"recurring_spending"`
+	Projections              []ForecastMonth   `json:"projections"`
+	TotalProjectedIncome     float64           `json:"total_projected_income" money:"amount"`
+	TotalProjectedSpending   float64           `json:"total_projected_spending" money:"amount"`
+	TotalProjectedNetSavings float64           `json:"total_projected_net_savings" money:"amount"`
+	EndBalance               float64           `json:"end_balance" money:"amount"`
+	MixedCurrencies          bool              `json:"mixed_currencies"`
+	Currencies               []string          `json:"currencies"`
+	CurrencyWarning          string            `json:"currency_warning,omitempty"`
+}
+
+// ForecastCashFlow projects income, spending, net savings and balance for the next months
+// Each month adds the recurring items expected in it to the average monthly non-recurring
+// income and spending of the last historyMonths months
+// months: at most MaxForecastMonths; historyMonths: at most MaxForecastHistoryMonths
+func (db *DB) ForecastCashFlow(ctx context.Context, months int, historyMonths int) (*Forecast, error) {
+	if months <= 0 {
+		months = defaultForecastMonths
+	}
+	if months > MaxForecastMonths {
+		months = MaxForecastMonths
+	}
+	if historyMonths <= 0 {
+		historyMonths = defaultForecastHistoryMonths
+	}
+	if historyMonths > MaxForecastHistoryMonths {
+		historyMonths = MaxForecastHistoryMonths
+	}
+
+	lookback := historyMonths
+	if lookback < forecastRecurringLookbackMonths {
+		lookback = forecastRecurringLookbackMonths
+	}
+	incomeData, err := db.GetIncomeData(ctx, lookback, false, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get income data: %w", err)
+	}
+	spendingData, err := db.GetSpendingData(ctx, lookback, false, nil, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get spending data: %w", err)
+	}
+	netWorth, err := db.CalculateNetWorth(ctx, AccountFilter{})
+	if err != nil {
+		return nil, err
+	}
+
+	var incomeCharges, spendingCharges []recurringCharge
+	var asOf time.Time
+	currencySet := make(map[string]struct{})
+	for _, i := range incomeData {
+		if c, ok := newRecurringCharge(i.TransactionID, i.Description, i.Currency, i.CategoryName, i.Amount, i.Date); ok {
+			incomeCharges = append(incomeCharges, c)
+			if c.at.After(asOf) {
+				asOf = c.at
+			}
+		}
+	}
+	for _, s := range spendingData {
+		if c, ok := newRecurringCharge(s.TransactionID, payeeOrDescription(s), s.Currency, s.CategoryName, s.Amount, s.Date); ok {
+			spendingCharges = append(spendingCharges, c)
+			if c.at.After(asOf) {
+				asOf = c.at
+			}
+		}
+	}
+
+	forecast := &Forecast{
+		Months:            months,
+		HistoryMonths:     historyMonths,
+		StartingBalance:   netWorth.NetWorth,
+		RecurringIncome:   []RecurringSeries{},
+		RecurringSpending: []RecurringSeries{},
+		Projections:       []ForecastMonth{},
+	}
+	if asOf.IsZero() {
+		forecast.LowConfidence = true
+		forecast.ConfidenceNote = "No transaction history to base a forecast on"
+		forecast.EndBalance = forecast.StartingBalance
+		forecast.Currencies = []string{}
+		return forecast, nil
+	}
+	forecast.AsOf = asOf.Format(dayLayout)
+
+	forecast.RecurringIncome = activeRecurringSeries(detectRecurringSeries(incomeCharges, defaultRecurringMinOccurrences, defaultRecurringTolerancePct), asOf)
+	forecast.RecurringSpending = activeRecurringSeries(detectRecurringSeries(spendingCharges, defaultRecurringMinOccurrences, defaultRecurringTolerancePct), asOf)
+
+	// Averages cover the history window only, leaving out charges of active recurring series
+	historyStart := addMonthsClamped(asOf, -historyMonths)
+	activeMonths := make(map[string]struct{})
+	variableIncome := variableHistoryTotal(incomeCharges, forecast.RecurringIncome, historyStart, activeMonths, currencySet)
+	variableSpending := variableHistoryTotal(spendingCharges, forecast.RecurringSpending, historyStart, activeMonths, currencySet)
+	forecast.MonthsWithData = len(activeMonths)
+	if forecast.MonthsWithData > 0 {
+		forecast.AverageVariableIncome = variableIncome / float64(forecast.MonthsWithData)
+		forecast.AverageVariableSpending = variableSpending / float64(forecast.MonthsWithData)
+	}
+	if forecast.MonthsWithData < minForecastHistoryMonths {
+		forecast.LowConfidence = true
+		forecast.ConfidenceNote = fmt.Sprintf("Only %d month(s) of history; projections are rough estimates", forecast.MonthsWithData)
+	}
+
+	balance := forecast.StartingBalance
+	firstMonth := time.Date(asOf.Year(), asOf.Month(), 1, 0, 0, 0, 0, time.UTC)
+	for i := 1; i <= months; i++ {
+		month := firstMonth.AddDate(0, i, 0)
+		projection := ForecastMonth{
+			Month:             month.Format(monthLayout),
+			RecurringIncome:   recurringAmountInMonth(forecast.RecurringIncome, month),
+			ProjectedIncome:   forecast.AverageVariableIncome,
+			RecurringSpending: recurringAmountInMonth(forecast.RecurringSpending, month),
+			ProjectedSpending: forecast.AverageVariableSpending,
+		}
+		projection.TotalIncome = projection.RecurringIncome + projection.ProjectedIncome
+		projection.TotalSpending = projection.RecurringSpending + projection.ProjectedSpending
+		projection.NetSavings = projection.TotalIncome - projection.TotalSpending
+		balance += projection.NetSavings
+		projection.EndBalance = balance
+
+		forecast.TotalProjectedIncome += projection.TotalIncome
+		forecast.TotalProjectedSpending += projection.TotalSpending
+		forecast.TotalProjectedNetSavings += projection.NetSavings
+		forecast.Projections = append(forecast.Projections, projection)
+	}
+	forecast.EndBalance = balance
+
+	for currency := range netWorth.ByCurrency {
+		currencySet[currency] = struct{}{}
+	}
+	forecast.Currencies = sortedCurrencyKeys(currencySet)
+	forecast.MixedCurrencies = len(forecast.Currencies) > 1
+	if forecast.MixedCurrencies {
+		forecast.CurrencyWarning = "Projections combine multiple currencies without conversion."
+	}
+
+	return forecast, nil
+}
+
+// newRecurringCharge converts an income or spending row into a recurringCharge
+func newRecurringCharge(id int64, payee, currency, category string, amount float64, date string) (recurringCharge, bool) {
+	at, err := time.Parse(dateTimeLayout, date)
+	if err != nil {
+		return recurringCharge{}, false
+	}
+	return recurringCharge{
+		id:       id,
+		payee:    normalizePayee(payee),
+		currency: currency,
+		category: category,
+		amount:   amount,
+		at:       at,
+	}, true
+}
+
+// activeRecurringSeries keeps the series whose next expected date has not long passed asOf
+func activeRecurringSeries(series []RecurringSeries, asOf time.Time) []RecurringSeries {
+	cutoff := asOf.AddDate(0, 0, -recurringGraceDays)
+	active := []RecurringSeries{}
+	for _, s := range series {
+		next, err := time.Parse(dayLayout, s.NextExpectedDate)
+		if err != nil || next.Before(cutoff) {
+			continue
+		}
+		active = append(active, s)
+	}
+	return active
+}
+
+// variableHistoryTotal sums the charges on or after start that do not belong to a recurring
+// series, recording the months with activity and the currencies seen
+func variableHistoryTotal(charges []recurringCharge, series []RecurringSeries, start time.Time, activeMonths, currencies map[string]struct{}) float64 {
+	recurringIDs := make(map[int64]struct{})
+	for _, s := range series {
+		for _, id := range s.TransactionIDs {
+			recurringIDs[id] = struct{}{}
+		}
+	}
+
+	total := 0.0
+	for _, c := range charges {
+		if c.at.Before(start) {
+			continue
+		}
+		activeMonths[c.at.Format(monthLayout)] = struct{}{}
+		if c.currency != "" {
+			currencies[c.currency] = struct{}{}
+		}
+		if _, ok := recurringIDs[c.id]; ok {
+			continue
+		}
+		total += c.amount
+	}
+	return total
+}
+
+// recurringAmountInMonth sums the recurring items expected in the month starting at month:
+// every monthly series, and yearly series whose next renewal falls in that calendar month
+func recurringAmountInMonth(series []RecurringSeries, month time.Time) float64 {
+	total := 0.0
+	for _, s := range series {
+		if s.Interval == RecurringIntervalMonthly {
+			total += s.AverageAmount
+			continue
+		}
+		next, err := time.Parse(dayLayout, s.NextExpectedDate)
+		if err != nil {
+			continue
+		}
+		renewal := time.Date(next.Year(), next.Month(), 1, 0, 0, 0, 0, time.UTC)
+		for renewal.Before(month) {
+			renewal = renewal.AddDate(1, 0, 0)
+		}
+		if renewal.Equal(month) {
+			total += s.AverageAmount
+		}
+	}
+	return total
+}
@@ -0,0 +1,178 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+const (
+	defaultHistogramBucketSize = 25.0
+	defaultHistogramMaxBuckets = 20
+)
+
+// Transaction kinds a histogram covers
+const (
+	HistogramKindSpending = "spending"
+	HistogramKindIncome   = "income"
+	HistogramKindBoth     = "both"
+)
+
+// HistogramBucket represents the transactions whose amount falls in [Min, Max)
+type HistogramBucket struct {
+	Label              string   `json:"label"` // e.g. "25-50", or "475+" for the last open-ended bucket
+	Min                float64  `json:"min" money:"amount"`
+	Max                *float64 `json:"max" money:"amount"` // null for the open-ended bucket
+	Count              int      `json:"count"`
+	TotalAmount        float64  `json:"total_amount" money:"amount"`
+	CountSharePercent  float64  `json:"count_share_percent"`
+	AmountSharePercent float64  `json:"amount_share_percent"`
+}
+
+// HistogramSeries represents the amount distribution of one kind of transaction
+type HistogramSeries struct {
+	TransactionCount int               `json:"transaction_count"`
+	TotalAmount      float64           `json:"total_amount" money:"amount"`
+	Buckets          []HistogramBucket `json:"buckets"`
+}
+
+// TransactionHistogram represents how many transactions, and how much money, fall in each amount
+// range
+type TransactionHistogram struct {
+	Kind            string           `json:"kind"` // "spending", "income" or "both"
+	Months          int              `json:"months"`
+	BucketSize      float64          `json:"bucket_size" money:"amount"`
+	MaxBuckets      int              `json:"max_buckets"`
+	Spending        *HistogramSeries `json:"spending,omitempty"`
+	Income          *HistogramSeries `json:"income,omitempty"`
+	MixedCurrencies bool             `json:"mixed_currencies"`
+	Currencies      []string         `json:"currencies"`
+	CurrencyWarning string           `json:"currency_warning,omitempty"`
+}
+
+// GetTransactionHistogram groups transactions by amount into buckets of bucketSize, with the
+// count and total of each, showing whether many small or a few large transactions dominate
+// kind: "spending" (default), "income" or "both"
+// months: number of months to analyze (0 = all historical data)
+// bucketSize: width of each bucket (0 = 25)
+// maxBuckets: number of buckets (0 = 20); the last one is open-ended and takes every larger amount
+// A split transaction counts once with its whole amount; refunds, internal transfers and
+// future-dated scheduled transactions are left out
+func (db *DB) GetTransactionHistogram(ctx context.Context, kind string, months int, bucketSize float64, maxBuckets int) (*TransactionHistogram, error) {
+	switch kind {
+	case "":
+		kind = HistogramKindSpending
+	case HistogramKindSpending, HistogramKindIncome, HistogramKindBoth:
+	default:
+		return nil, invalidArgumentf("invalid kind %q: expected spending, income, or both", kind)
+	}
+	if bucketSize < 0 {
+		return nil, invalidArgumentf("bucket size must be positive, got %v", bucketSize)
+	}
+	if bucketSize == 0 {
+		bucketSize = defaultHistogramBucketSize
+	}
+	if maxBuckets < 0 {
+		return nil, invalidArgumentf("max buckets must not be negative, got %d", maxBuckets)
+	}
+	if maxBuckets == 0 {
+		maxBuckets = defaultHistogramMaxBuckets
+	}
+
+	histogram := &TransactionHistogram{
+		Kind:       kind,
+		Months:     months,
+		BucketSize: bucketSize,
+		MaxBuckets: maxBuckets,
+	}
+	currencySet := make(map[string]struct{})
+
+	if kind != HistogramKindIncome {
+		spending, err := db.GetSpendingData(ctx, months, false, nil, false)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get spending data: %w", err)
+		}
+		// Split transactions have one row per category
+		amounts := make(map[int64]float64)
+		for _, s := range spending {
+			amounts[s.TransactionID] += s.Amount
+			if s.Currency != "" {
+				currencySet[s.Currency] = struct{}{}
+			}
+		}
+		histogram.Spending = buildHistogramSeries(amounts, bucketSize, maxBuckets)
+	}
+
+	if kind != HistogramKindSpending {
+		income, err := db.GetIncomeData(ctx, months, false, false)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get income data: %w", err)
+		}
+		amounts := make(map[int64]float64)
+		for _, i := range income {
+			if i.IsRefund {
+				continue
+			}
+			amounts[i.TransactionID] += i.Amount
+			if i.Currency != "" {
+				currencySet[i.Currency] = struct{}{}
+			}
+		}
+		histogram.Income = buildHistogramSeries(amounts, bucketSize, maxBuckets)
+	}
+
+	histogram.Currencies = sortedCurrencyKeys(currencySet)
+	histogram.MixedCurrencies = len(histogram.Currencies) > 1
+	if histogram.MixedCurrencies {
+		histogram.CurrencyWarning = "Buckets combine amounts in multiple currencies without conversion."
+	}
+
+	return histogram, nil
+}
+
+// buildHistogramSeries sorts transaction amounts into maxBuckets buckets of bucketSize
+func buildHistogramSeries(amounts map[int64]float64, bucketSize float64, maxBuckets int) *HistogramSeries {
+	series := &HistogramSeries{Buckets: make([]HistogramBucket, maxBuckets)}
+	for i := range series.Buckets {
+		bucket := &series.Buckets[i]
+		bucket.Min = float64(i) * bucketSize
+		if i == maxBuckets-1 {
+			bucket.Label = formatHistogramBound(bucket.Min) + "+"
+			continue
+		}
+		upper := float64(i+1) * bucketSize
+		bucket.Max = &upper
+		bucket.Label = formatHistogramBound(bucket.Min) + "-" + formatHistogramBound(upper)
+	}
+
+	for _, amount := range amounts {
+		if amount <= 0 {
+			continue
+		}
+		index := int(amount / bucketSize)
+		if index >= maxBuckets {
+			index = maxBuckets - 1
+		}
+		series.Buckets[index].Count++
+		series.Buckets[index].TotalAmount += amount
+		series.TransactionCount++
+		series.TotalAmount += amount
+	}
+
+	for i := range series.Buckets {
+		bucket := &series.Buckets[i]
+		if series.TransactionCount > 0 {
+			bucket.CountSharePercent = float64(bucket.Count) / float64(series.TransactionCount) * 100
+		}
+		if series.TotalAmount > 0 {
+			bucket.AmountSharePercent = bucket.TotalAmount / series.TotalAmount * 100
+		}
+	}
+	return series
+}
+
+// formatHistogramBound writes a bucket bound to the cent without trailing zeros, e.g. 25 or 12.5
+func formatHistogramBound(value float64) string {
+	return strconv.FormatFloat(math.Round(value*100)/100, 'f', -1, 64)
+}
@@ -1,145 +1,146 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"sort"
 )
 
 // IncomeData represents income data for trend analysis
 type IncomeData struct {
-	CategoryID   int64   `json:"category_id"`
-	CategoryName string  `json:"category_name"`
-	Amount       float64 `json:"amount"`
-	Currency     string  `json:"currency"`
-	Date         string  `json:"date"`
-	Month        string  `json:"month"` // YYYY-MM format
-	Year         string  `json:"year"`  // YYYY format
+	TransactionID int64   `json:"transaction_id"`
+	AccountID     int64   `json:"account_id"`
+	CategoryID    int64   `json:"category_id"`
+	CategoryName  string  `json:"category_name"`
+	Description   string  `json:"description"`
+	Payee         string  `json:"payee,omitempty"`
+	Amount        float64 `json:"amount" money:"amount"`
+	Currency      string  `json:"currency"`
+	Date          string  `json:"date"`
+	Month         string  `json:"month"`               // YYYY-MM format
+	Year          string  `json:"year"`                // YYYY, or e.g. FY2025 with a fiscal year start
+	IsRefund      bool    `json:"is_refund,omitempty"` // Money back for a purchase: a positive amount in an expense category
 }
 
 // IncomeTrend represents aggregated income trend data
 type IncomeTrend struct {
-	Period           string             `json:"period"` // "YYYY-MM" or "YYYY"
-	TotalIncome      float64            `json:"total_income"`
+	Period           string             `json:"period"` // "YYYY-MM", "YYYY", or a fiscal year such as "FY2025"
+	TotalIncome      float64            `json:"total_income" money:"amount"`
 	TransactionCount int                `json:"transaction_count"`
-	ByCategory       map[string]float64 `json:"by_category"` // Category name -> total
-	ByCurrency       map[string]float64 `json:"by_currency"`
+	ByCategory       map[string]float64 `json:"by_category" money:"amount"` // Category name -> total
+	ByCurrency       map[string]float64 `json:"by_currency" money:"by_currency"`
 }
 
 // GetIncomeData retrieves income transactions with category information
-// Returns income (positive amounts) grouped by category and date
+// Returns income (positive amounts) grouped by category and date; a split transaction returns
+// one row per category carrying that category's portion
 // months: number of months to look back (0 = all data)
-func (db *DB) GetIncomeData(months int) ([]IncomeData, error) {
-	// Calculate date range: months back from now
-	// Core Data timestamp: seconds since 2001-01-01
-	// Get the latest transaction date to calculate the cutoff
-
-	var query string
-	if months > 0 {
-		// Calculate cutoff timestamp: months * average seconds per month (30.44 days)
-		// We'll use a subquery to get the max date and calculate backwards
-		query = `
-			SELECT 
-				COALESCE(c.Z_PK, 0) as category_id,
-				c.ZNAME2 as category_name,
-				t.ZAMOUNT1 as amount,
-				t.ZDESC2 as description,
-				a.ZCURRENCYNAME as currency,
-				CASE WHEN t.ZDATE1 IS NOT NULL THEN datetime('2001-01-01', '+' || CAST(t.ZDATE1 AS INTEGER) || ' seconds') ELSE NULL END as transaction_date,
-				CASE WHEN t.ZDATE1 IS NOT NULL THEN strftime('%Y-%m', datetime('2001-01-01', '+' || CAST(t.ZDATE1 AS INTEGER) || ' seconds')) ELSE NULL END as month,
-				CASE WHEN t.ZDATE1 IS NOT NULL THEN strftime('%Y', datetime('2001-01-01', '+' || CAST(t.ZDATE1 AS INTEGER) || ' seconds')) ELSE NULL END as year
-			FROM ZSYNCOBJECT t
-			LEFT JOIN ZSYNCOBJECT a ON a.Z_PK = t.ZACCOUNT2 AND a.Z_ENT IN (10, 11, 12, 13, 15, 16)
-			LEFT JOIN ZCATEGORYASSIGMENT ca ON ca.ZTRANSACTION = t.Z_PK
-			LEFT JOIN ZSYNCOBJECT c ON c.Z_PK = ca.ZCATEGORY AND c.Z_ENT = 19
-			WHERE t.Z_ENT IN (37, 45, 46, 47, 43)
-			AND t.ZAMOUNT1 > 0
-			AND t.ZDATE1 IS NOT NULL
-			AND t.ZDATE1 >= (SELECT MAX(ZDATE1) FROM ZSYNCOBJECT WHERE Z_ENT IN (37, 45, 46, 47, 43) AND ZDATE1 IS NOT NULL) - (? * 2629746)
-			ORDER BY t.ZDATE1 DESC
-		`
-	} else {
-		query = `
-			SELECT 
-				COALESCE(c.Z_PK, 0) as category_id,
-				c.ZNAME2 as category_name,
-				t.ZAMOUNT1 as amount,
-				t.ZDESC2 as description,
-				a.ZCURRENCYNAME as currency,
-				CASE WHEN t.ZDATE1 IS NOT NULL THEN datetime('2001-01-01', '+' || CAST(t.ZDATE1 AS INTEGER) || ' seconds') ELSE NULL END as transaction_date,
-				CASE WHEN t.ZDATE1 IS NOT NULL THEN strftime('%Y-%m', datetime('2001-01-01', '+' || CAST(t.ZDATE1 AS INTEGER) || ' seconds')) ELSE NULL END as month,
-				CASE WHEN t.ZDATE1 IS NOT NULL THEN strftime('%Y', datetime('2001-01-01', '+' || CAST(t.ZDATE1 AS INTEGER) || ' seconds')) ELSE NULL END as year
-			FROM ZSYNCOBJECT t
-			LEFT JOIN ZSYNCOBJECT a ON a.Z_PK = t.ZACCOUNT2 AND a.Z_ENT IN (10, 11, 12, 13, 15, 16)
-			LEFT JOIN ZCATEGORYASSIGMENT ca ON ca.ZTRANSACTION = t.Z_PK
-			LEFT JOIN ZSYNCOBJECT c ON c.Z_PK = ca.ZCATEGORY AND c.Z_ENT = 19
-			WHERE t.Z_ENT IN (37, 45, 46, 47, 43)
-			AND t.ZAMOUNT1 > 0
-			AND t.ZDATE1 IS NOT NULL
-			ORDER BY t.ZDATE1 DESC
-		`
+// includeTransfers: also return transfers between own accounts (excluded by default); cash
+// withdrawals are never returned
+// includeScheduled: also return future-dated (scheduled) transactions (excluded by default)
+func (db *DB) GetIncomeData(ctx context.Context, months int, includeTransfers bool, includeScheduled bool) ([]IncomeData, error) {
+	// Core Data timestamps are seconds since 2001-01-01; the cutoff is months calendar
+	// months before the latest transaction
+	cutoff, err := db.monthsCutoff(ctx, months, includeScheduled)
+	if err != nil {
+		return nil, err
 	}
-
-	var rows *sql.Rows
-	var err error
-	if months > 0 {
-		rows, err = db.conn.Query(query, months)
-	} else {
-		rows, err = db.conn.Query(query)
+	labels, err := db.categoryLabels(ctx)
+	if err != nil {
+		return nil, err
 	}
+	expenseCategories, err := db.expenseCategoryIDs(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to query income data: %w", err)
+		return nil, err
+	}
+	amountExpr, categoryJoin, err := db.categoryAssignmentSelect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	payeeExpr, payeeJoin, err := db.payeeSelect(ctx)
+	if err != nil {
+		return nil, err
 	}
-	defer rows.Close()
+
+	query := fmt.Sprintf(`
+		SELECT 
+			t.Z_PK as transaction_id,
+			t.ZACCOUNT2 as account_id,
+			COALESCE(c.Z_PK, 0) as category_id,
+			c.ZNAME2 as category_name,
+			ABS(%[2]s) as amount,
+			t.ZDESC2 as description,
+			%[4]s as payee,
+			a.ZCURRENCYNAME as currency,
+			t.ZDATE1 as transaction_date
+		FROM ZSYNCOBJECT t
+		LEFT JOIN ZSYNCOBJECT a ON a.Z_PK = t.ZACCOUNT2 AND a.Z_ENT IN (10, 11, 12, 13, 15, 16)
+		%[3]s
+		LEFT JOIN ZSYNCOBJECT c ON c.Z_PK = ca.ZCATEGORY AND c.Z_ENT = 19
+		%[5]s
+		WHERE t.Z_ENT IN (%[1]s)
+		AND t.ZAMOUNT1 > 0
+		AND t.ZDATE1 IS NOT NULL
+		AND t.ZDATE1 >= ?
+		AND t.ZDATE1 <= ?
+		ORDER BY t.ZDATE1 DESC
+	`, transactionEntities(includeTransfers), amountExpr, categoryJoin, payeeExpr, payeeJoin)
 
 	var income []IncomeData
-	for rows.Next() {
+	err = db.queryEach(ctx, "GetIncomeData", query, []any{cutoff, scheduledCutoff(includeScheduled)}, func(rows *sql.Rows) error {
 		var id IncomeData
+		var accountID sql.NullInt64
 		var categoryID sql.NullInt64
 		var categoryName sql.NullString
 		var description sql.NullString
+		var payee sql.NullString
 		var currency sql.NullString
-		var date sql.NullString
-		var month sql.NullString
-		var year sql.NullString
+		var date sql.NullFloat64
 
-		err := rows.Scan(&categoryID, &categoryName, &id.Amount, &description, &currency, &date, &month, &year)
+		err := rows.Scan(&id.TransactionID, &accountID, &categoryID, &categoryName, &id.Amount, &description, &payee, &currency, &date)
 		if err != nil {
-			return nil, fmt.Errorf("failed to scan income data: %w", err)
+			return fmt.Errorf("failed to scan income data: %w", err)
 		}
 
+		if accountID.Valid {
+			id.AccountID = accountID.Int64
+		}
 		if categoryID.Valid {
 			id.CategoryID = categoryID.Int64
+			id.IsRefund = expenseCategories[id.CategoryID]
 		}
 		if categoryName.Valid {
 			id.CategoryName = categoryName.String
 		}
-		if currency.Valid {
-			id.Currency = currency.String
-		}
-		if date.Valid {
-			id.Date = date.String
+		if label := labels[id.CategoryID]; label != "" {
+			id.CategoryName = label
 		}
-		if month.Valid {
-			id.Month = month.String
+		if payee.Valid {
+			id.Payee = payee.String
 		}
-		if year.Valid {
-			id.Year = year.String
+		id.Currency = db.accountCurrency(currency)
+		if date.Valid {
+			ts := coreDataToTime(date.Float64)
+			id.Date = ts.Format(dateTimeLayout)
+			id.Month = ts.Format(monthLayout)
+			id.Year = yearLabel(ts, db.fiscalYearStart)
 		}
 		desc := ""
 		if description.Valid {
 			desc = description.String
 		}
-		movementType := detectMovementType(desc)
-		if isInternalMovement(movementType) {
-			continue
+		if excludedMovement(detectMovementType(desc), includeTransfers) {
+			return nil
 		}
-		id.CategoryName = fallbackCategoryName(id.CategoryName, desc)
+		id.Description = desc
+		id.CategoryName = fallbackCategoryName(id.CategoryName, desc, db.uncategorizedLabel)
 
 		income = append(income, id)
-	}
-
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating income data: %w", err)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query income data: %w", err)
 	}
 
 	return income, nil
@@ -148,12 +149,14 @@ func (db *DB) GetIncomeData(months int) ([]IncomeData, error) {
 // AnalyzeIncomeTrends analyzes income trends grouped by time period and category
 // groupBy: "month" or "year"
 // months: number of months to analyze (0 = all historical data)
-func (db *DB) AnalyzeIncomeTrends(groupBy string, months int) ([]IncomeTrend, error) {
+// includeTransfers: count transfers between own accounts (excluded by default)
+// includeScheduled: count future-dated (scheduled) transactions (excluded by default)
+func (db *DB) AnalyzeIncomeTrends(ctx context.Context, groupBy string, months int, includeTransfers bool, includeScheduled bool) ([]IncomeTrend, error) {
 	if groupBy != "month" && groupBy != "year" {
 		groupBy = "month"
 	}
 
-	income, err := db.GetIncomeData(months)
+	income, err := db.GetIncomeData(ctx, months, includeTransfers, includeScheduled)
 	if err != nil {
 		return nil, err
 	}
@@ -196,14 +199,10 @@ func (db *DB) AnalyzeIncomeTrends(groupBy string, months int) ([]IncomeTrend, er
 		trends = append(trends, *trend)
 	}
 
-	// Simple sort by period string (works for YYYY-MM and YYYY)
-	for i := 0; i < len(trends)-1; i++ {
-		for j := i + 1; j < len(trends); j++ {
-			if trends[i].Period > trends[j].Period {
-				trends[i], trends[j] = trends[j], trends[i]
-			}
-		}
-	}
+	// Period strings sort chronologically (works for YYYY-MM and YYYY)
+	sort.Slice(trends, func(i, j int) bool {
+		return trends[i].Period < trends[j].Period
+	})
 
 	return trends, nil
 }
@@ -0,0 +1,151 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// DefaultIncomeConcentrationThreshold is the share of income above which a single source is
+// flagged as a concentration risk
+const DefaultIncomeConcentrationThreshold = 80.0
+
+// Income source groupings
+const (
+	IncomeSourceByCategory = "category"
+	IncomeSourceByPayee    = "payee"
+)
+
+// IncomeSource represents the income received from one source
+type IncomeSource struct {
+	Source           string             `json:"source"`
+	TotalIncome      float64            `json:"total_income" money:"amount"`
+	TransactionCount int                `json:"transaction_count"`
+	SharePercent     float64            `json:"share_percent"` // Percentage of all income
+	ByCurrency       map[string]float64 `json:"by_currency" money:"by_currency"`
+	LastDate         string             `json:"last_date"`
+}
+
+// IncomeSourceAnalysis represents income broken down by source, largest first
+type IncomeSourceAnalysis struct {
+	Months                   int            `json:"months"`
+	GroupBy                  string         `json:"group_by"` // "category" or "payee"
+	TotalIncome              float64        `json:"total_income" money:"amount"`
+	SourceCount              int            `json:"source_count"`
+	TopSource                string         `json:"top_source,omitempty"`
+	TopSharePercent          float64        `json:"top_share_percent"`
+	ThresholdPercent         float64        `json:"threshold_percent"`
+	ConcentrationRisk        bool           `json:"concentration_risk"` // The top source's share exceeds the threshold
+	ConcentrationWarning     string         `json:"concentration_warning,omitempty"`
+	HerfindahlIndex          float64        `json:"herfindahl_index"` // Sum of squared shares (0-1); 1 means a single source
+	EffectiveNumberOfSources float64        `json:"effective_number_of_sources"`
+	MixedCurrencies          bool           `json:"mixed_currencies"`
+	Currencies               []string       `json:"currencies"`
+	CurrencyWarning          string         `json:"currency_warning,omitempty"`
+	Sources                  []IncomeSource `json:"sources"`
+}
+
+// AnalyzeIncomeSources reports what share of income each source provides over the last months
+// (0 = all historical data), and flags a concentration risk when one source provides more than
+// thresholdPercent of it (0 = 80)
+// groupBy: "category" (default) or "payee"; income without a payee is grouped by its description
+// Refunds, internal transfers and future-dated scheduled transactions are left out
+func (db *DB) AnalyzeIncomeSources(ctx context.Context, months int, groupBy string, thresholdPercent float64) (*IncomeSourceAnalysis, error) {
+	switch groupBy {
+	case "":
+		groupBy = IncomeSourceByCategory
+	case IncomeSourceByCategory, IncomeSourceByPayee:
+	default:
+		return nil, invalidArgumentf("invalid group_by %q: expected category or payee", groupBy)
+	}
+	if thresholdPercent < 0 || thresholdPercent > 100 {
+		return nil, invalidArgumentf("threshold percent must be between 0 and 100, got %v", thresholdPercent)
+	}
+	if thresholdPercent == 0 {
+		thresholdPercent = DefaultIncomeConcentrationThreshold
+	}
+
+	income, err := db.GetIncomeData(ctx, months, false, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get income data: %w", err)
+	}
+
+	analysis := &IncomeSourceAnalysis{
+		Months:           months,
+		GroupBy:          groupBy,
+		ThresholdPercent: thresholdPercent,
+		Sources:          []IncomeSource{},
+	}
+	bySource := make(map[string]*IncomeSource)
+	currencySet := make(map[string]struct{})
+	for _, i := range income {
+		if i.IsRefund {
+			continue
+		}
+		name := i.CategoryName
+		if groupBy == IncomeSourceByPayee {
+			name = strings.TrimSpace(i.Payee)
+			if name == "" {
+				name = strings.TrimSpace(i.Description)
+			}
+		}
+		if name == "" {
+			name = "Unknown"
+		}
+
+		// Sources are matched without regard to case, keeping the first spelling seen
+		key := strings.ToLower(name)
+		if bySource[key] == nil {
+			bySource[key] = &IncomeSource{Source: name, ByCurrency: make(map[string]float64)}
+		}
+		source := bySource[key]
+		source.TotalIncome += i.Amount
+		source.TransactionCount++
+		if i.Currency != "" {
+			source.ByCurrency[i.Currency] += i.Amount
+			currencySet[i.Currency] = struct{}{}
+		}
+		if i.Date > source.LastDate {
+			source.LastDate = i.Date
+		}
+		analysis.TotalIncome += i.Amount
+	}
+
+	for _, source := range bySource {
+		if analysis.TotalIncome > 0 {
+			source.SharePercent = source.TotalIncome / analysis.TotalIncome * 100
+			share := source.TotalIncome / analysis.TotalIncome
+			analysis.HerfindahlIndex += share * share
+		}
+		analysis.Sources = append(analysis.Sources, *source)
+	}
+	sort.Slice(analysis.Sources, func(i, j int) bool {
+		if analysis.Sources[i].TotalIncome != analysis.Sources[j].TotalIncome {
+			return analysis.Sources[i].TotalIncome > analysis.Sources[j].TotalIncome
+		}
+		return analysis.Sources[i].Source < analysis.Sources[j].Source
+	})
+	analysis.SourceCount = len(analysis.Sources)
+
+	if analysis.SourceCount > 0 {
+		top := analysis.Sources[0]
+		analysis.TopSource = top.Source
+		analysis.TopSharePercent = top.SharePercent
+		analysis.ConcentrationRisk = top.SharePercent > thresholdPercent
+		if analysis.ConcentrationRisk {
+			analysis.ConcentrationWarning = fmt.Sprintf("%.1f%% of income comes from %s, above the %.0f%% concentration threshold.", top.SharePercent, top.Source, thresholdPercent)
+		}
+	}
+	if analysis.HerfindahlIndex > 0 {
+		analysis.EffectiveNumberOfSources = 1 / analysis.HerfindahlIndex
+	}
+
+	analysis.Currencies = sortedCurrencyKeys(currencySet)
+	analysis.MixedCurrencies = len(analysis.Currencies) > 1
+	if analysis.MixedCurrencies {
+		analysis.CurrencyWarning = "Shares combine multiple currencies without conversion. Prefer by_currency values for accurate interpretation."
+	}
+
+	return analysis, nil
+}
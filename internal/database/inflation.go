@@ -0,0 +1,116 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// CategoryInflationProjection represents projected spending for one category
+type CategoryInflationProjection struct {
+	CategoryName            string  `json:"category_name"`
+	CurrentAnnualSpending   float64 `json:"current_annual_spending" money:"amount"`
+	ProjectedAnnualSpending float64 `json:"projected_annual_spending" money:"amount"`
+	Increase                float64 `json:"increase" money:"amount"`
+}
+
+// InflationProjection represents category spending compounded under an assumed inflation rate
+type InflationProjection struct {
+	AnnualInflationPct   float64                       `json:"annual_inflation_pct"`
+	Years                int                           `json:"years"`
+	BaselineMonths       int                           `json:"baseline_months"` // Months of history used to annualize spending
+	Multiplier           float64                       `json:"multiplier"`      // (1 + rate)^years
+	CurrentAnnualTotal   float64                       `json:"current_annual_total" money:"amount"`
+	ProjectedAnnualTotal float64                       `json:"projected_annual_total" money:"amount"`
+	TotalIncrease        float64                       `json:"total_increase" money:"amount"`
+	MixedCurrencies      bool                          `json:"mixed_currencies"`
+	Currencies           []string                      `json:"currencies"`
+	CurrencyWarning      string                        `json:"currency_warning,omitempty"`
+	ByCategory           []CategoryInflationProjection `json:"by_category"`
+}
+
+// ProjectInflationImpact annualizes current category spending and compounds it at
+// annualInflationPct for the given number of years
+// months: number of months of history used as the baseline (0 = all historical data); spending
+// is annualized over the months of that window that have spending, so a short history is not
+// diluted by the months before it
+func (db *DB) ProjectInflationImpact(ctx context.Context, annualInflationPct float64, years int, months int) (*InflationProjection, error) {
+	if years < 0 {
+		return nil, invalidArgumentf("years must not be negative, got %d", years)
+	}
+	if months < 0 {
+		return nil, invalidArgumentf("months must not be negative, got %d", months)
+	}
+	if annualInflationPct <= -100 {
+		return nil, invalidArgumentf("annual inflation must be greater than -100%%, got %.2f%%", annualInflationPct)
+	}
+
+	spendingData, err := db.GetSpendingData(ctx, months, false, nil, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get spending data: %w", err)
+	}
+
+	totalsByCategory := make(map[string]float64)
+	currencySet := make(map[string]struct{})
+	uniqueMonths := make(map[string]bool)
+	for _, s := range spendingData {
+		totalsByCategory[s.CategoryName] += s.Amount
+		if s.Currency != "" {
+			currencySet[s.Currency] = struct{}{}
+		}
+		if s.Month != "" {
+			uniqueMonths[s.Month] = true
+		}
+	}
+
+	// The months with spending, at most the requested window
+	monthCount := len(uniqueMonths)
+	if months > 0 && months < monthCount {
+		monthCount = months
+	}
+	if monthCount == 0 {
+		monthCount = 1 // Avoid division by zero
+	}
+
+	multiplier := math.Pow(1+annualInflationPct/100, float64(years))
+	projection := &InflationProjection{
+		AnnualInflationPct: annualInflationPct,
+		Years:              years,
+		BaselineMonths:     monthCount,
+		Multiplier:         multiplier,
+		ByCategory:         []CategoryInflationProjection{},
+	}
+
+	for name, total := range totalsByCategory {
+		current := total / float64(monthCount) * 12
+		projected := current * multiplier
+		projection.ByCategory = append(projection.ByCategory, CategoryInflationProjection{
+			CategoryName:            name,
+			CurrentAnnualSpending:   current,
+			ProjectedAnnualSpending: projected,
+			Increase:                projected - current,
+		})
+	}
+
+	sort.Slice(projection.ByCategory, func(i, j int) bool {
+		if projection.ByCategory[i].CurrentAnnualSpending != projection.ByCategory[j].CurrentAnnualSpending {
+			return projection.ByCategory[i].CurrentAnnualSpending > projection.ByCategory[j].CurrentAnnualSpending
+		}
+		return projection.ByCategory[i].CategoryName < projection.ByCategory[j].CategoryName
+	})
+
+	for _, category := range projection.ByCategory {
+		projection.CurrentAnnualTotal += category.CurrentAnnualSpending
+		projection.ProjectedAnnualTotal += category.ProjectedAnnualSpending
+	}
+	projection.TotalIncrease = projection.ProjectedAnnualTotal - projection.CurrentAnnualTotal
+
+	projection.Currencies = sortedCurrencyKeys(currencySet)
+	projection.MixedCurrencies = len(projection.Currencies) > 1
+	if projection.MixedCurrencies {
+		projection.CurrencyWarning = "Totals combine multiple currencies. Category projections are not currency-normalized."
+	}
+
+	return projection, nil
+}
@@ -1,9 +1,14 @@
 package database
 
 import (
+	"bytes"
+	"context"
 	"database/sql"
+	"errors"
 	"math"
+	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -14,7 +19,7 @@ func TestAnalyzeSavingsWithFixtureDB(t *testing.T) {
 	db := newFixtureDB(t)
 	defer db.Close()
 
-	got, err := db.AnalyzeSavings(0)
+	got, err := db.AnalyzeSavings(context.Background(), SavingsOptions{TopCategories: 5})
 	if err != nil {
 		t.Fatalf("AnalyzeSavings: %v", err)
 	}
@@ -53,10 +58,19 @@ func TestAnalyzeSavingsWithFixtureDB(t *testing.T) {
 	}
 	assertFloatClose(t, "rent total", got.TopSpendingCategories[0].TotalAmount, 1200, 0.001)
 	assertFloatClose(t, "rent percentage", got.TopSpendingCategories[0].Percentage, 80, 0.001)
+	assertFloatClose(t, "rent average monthly", got.TopSpendingCategories[0].AverageMonthly, 600, 0.001)
+	assertFloatClose(t, "rent average per transaction", got.TopSpendingCategories[0].AveragePerTransaction, 1200, 0.001)
 	if got.TopSpendingCategories[1].CategoryName != "Groceries" {
 		t.Fatalf("top category[1] = %q, want %q", got.TopSpendingCategories[1].CategoryName, "Groceries")
 	}
 
+	if len(got.MonthlySavingsRate) != 2 || got.MonthlySavingsRate[0].Month != "2024-01" || got.MonthlySavingsRate[1].Month != "2024-02" {
+		t.Fatalf("monthly savings rate = %+v, want January and February", got.MonthlySavingsRate)
+	}
+	assertFloatClose(t, "january savings rate", got.MonthlySavingsRate[0].SavingsRate, 60, 0.001)
+	assertFloatClose(t, "february net savings", got.MonthlySavingsRate[1].NetSavings, 2200, 0.001)
+	assertFloatClose(t, "february savings rate", got.MonthlySavingsRate[1].SavingsRate, 88, 0.001)
+
 	assertRecommendationPresent(t, got.Recommendations, "Excellent Savings Rate")
 	assertRecommendationPresent(t, got.Recommendations, "Review Spending on Rent")
 	if len(got.Recommendations) != 2 {
@@ -64,11 +78,47 @@ func TestAnalyzeSavingsWithFixtureDB(t *testing.T) {
 	}
 }
 
+func TestAnalyzeSavingsExcludeMonthsWithFixtureDB(t *testing.T) {
+	db := newFixtureDB(t)
+	defer db.Close()
+
+	got, err := db.AnalyzeSavings(context.Background(), SavingsOptions{ExcludeMonths: []string{"2024-01"}, TopCategories: 5})
+	if err != nil {
+		t.Fatalf("AnalyzeSavings: %v", err)
+	}
+
+	if got.Period != "All data (1 months)" {
+		t.Fatalf("period = %q, want %q", got.Period, "All data (1 months)")
+	}
+	assertFloatClose(t, "adjusted income", got.TotalIncome, 2500, 0.001)
+	assertFloatClose(t, "adjusted spending", got.TotalSpending, 300, 0.001)
+	assertFloatClose(t, "adjusted savings rate", got.SavingsRate, 88, 0.001)
+	assertFloatClose(t, "raw savings rate", got.RawSavingsRate, 72.7272727, 0.001)
+
+	if len(got.ExcludedMonths) != 1 {
+		t.Fatalf("excluded months len = %d, want 1", len(got.ExcludedMonths))
+	}
+	excluded := got.ExcludedMonths[0]
+	if excluded.Month != "2024-01" {
+		t.Fatalf("excluded month = %q, want %q", excluded.Month, "2024-01")
+	}
+	assertFloatClose(t, "excluded income", excluded.Income, 3000, 0.001)
+	assertFloatClose(t, "excluded spending", excluded.Spending, 1200, 0.001)
+	assertFloatClose(t, "excluded net savings", excluded.NetSavings, 1800, 0.001)
+	if len(got.MonthlySavingsRate) != 1 || got.MonthlySavingsRate[0].Month != "2024-02" {
+		t.Fatalf("monthly savings rate = %+v, want only February", got.MonthlySavingsRate)
+	}
+
+	if _, err := db.AnalyzeSavings(context.Background(), SavingsOptions{ExcludeMonths: []string{"January"}, TopCategories: 5}); err == nil {
+		t.Fatal("AnalyzeSavings with invalid excluded month unexpectedly succeeded")
+	}
+}
+
 func TestGetAccountsAndAccountBalanceWithFixtureDB(t *testing.T) {
 	db := newFixtureDB(t)
 	defer db.Close()
 
-	accounts, err := db.GetAccounts()
+	accounts, err := db.GetAccounts(context.Background())
 	if err != nil {
 		t.Fatalf("GetAccounts: %v", err)
 	}
@@ -92,13 +142,13 @@ func TestGetAccountsAndAccountBalanceWithFixtureDB(t *testing.T) {
 	}
 	assertFloatClose(t, "account balance", account.Balance, 5000, 0.001)
 
-	single, err := db.GetAccountBalance(1)
+	single, err := db.GetAccountBalance(context.Background(), 1)
 	if err != nil {
 		t.Fatalf("GetAccountBalance: %v", err)
 	}
 	assertFloatClose(t, "single account balance", single.Balance, 5000, 0.001)
 
-	_, err = db.GetAccountBalance(999)
+	_, err = db.GetAccountBalance(context.Background(), 999)
 	if err == nil {
 		t.Fatal("GetAccountBalance for missing account unexpectedly succeeded")
 	}
@@ -108,7 +158,7 @@ func TestGetTransactionsAndCategoriesWithFixtureDB(t *testing.T) {
 	db := newFixtureDB(t)
 	defer db.Close()
 
-	transactions, err := db.GetTransactions(1, 2)
+	transactions, err := db.GetTransactions(context.Background(), TransactionFilter{AccountID: 1, Limit: 2})
 	if err != nil {
 		t.Fatalf("GetTransactions: %v", err)
 	}
@@ -134,7 +184,7 @@ func TestGetTransactionsAndCategoriesWithFixtureDB(t *testing.T) {
 		t.Fatalf("transaction movement_type = %q, want %q", transactions[0].MovementType, movementTypeRegular)
 	}
 
-	categories, err := db.GetCategories()
+	categories, err := db.GetCategories(context.Background(), "")
 	if err != nil {
 		t.Fatalf("GetCategories: %v", err)
 	}
@@ -146,11 +196,54 @@ func TestGetTransactionsAndCategoriesWithFixtureDB(t *testing.T) {
 	}
 }
 
+func TestGetCategoriesTypeWithFixtureDB(t *testing.T) {
+	db := newFixtureDB(t)
+	defer db.Close()
+
+	inferred, err := db.GetCategories(context.Background(), "")
+	if err != nil {
+		t.Fatalf("GetCategories without type column: %v", err)
+	}
+	if inferred[0].Type != CategoryTypeExpense || inferred[1].Type != CategoryTypeExpense || inferred[2].Type != CategoryTypeIncome {
+		t.Fatalf("inferred types = [%s %s %s], want [expense expense income]", inferred[0].Type, inferred[1].Type, inferred[2].Type)
+	}
+
+	db = newFixtureDBWithExtraRows(t, func(conn *sql.DB) {
+		mustExecSQL(t, conn, `
+			ALTER TABLE ZSYNCOBJECT ADD COLUMN ZTYPE2 INTEGER;
+			UPDATE ZSYNCOBJECT SET ZTYPE2 = 2 WHERE Z_PK = 100;
+			UPDATE ZSYNCOBJECT SET ZTYPE2 = 1 WHERE Z_PK = 101;
+		`)
+		insertTransaction(t, conn, 2000, 37, 20, "2024-02-11", "Groceries refund", 1, 0, 102)
+	})
+	defer db.Close()
+
+	categories, err := db.GetCategories(context.Background(), "")
+	if err != nil {
+		t.Fatalf("GetCategories: %v", err)
+	}
+	if categories[0].Type != CategoryTypeBoth || categories[1].Type != CategoryTypeExpense || categories[2].Type != CategoryTypeIncome {
+		t.Fatalf("types = [%s %s %s], want [both expense income]", categories[0].Type, categories[1].Type, categories[2].Type)
+	}
+
+	income, err := db.GetCategories(context.Background(), CategoryTypeIncome)
+	if err != nil {
+		t.Fatalf("GetCategories income: %v", err)
+	}
+	if len(income) != 2 || income[0].Name != "Groceries" || income[1].Name != "Salary" {
+		t.Fatalf("income categories = %#v, want [Groceries Salary]", income)
+	}
+
+	if _, err := db.GetCategories(context.Background(), "transfer"); err == nil {
+		t.Fatal("GetCategories with invalid type unexpectedly succeeded")
+	}
+}
+
 func TestAnalyzeIncomeAndSpendingTrendsWithFixtureDB(t *testing.T) {
 	db := newFixtureDB(t)
 	defer db.Close()
 
-	incomeMonthly, err := db.AnalyzeIncomeTrends("month", 0)
+	incomeMonthly, err := db.AnalyzeIncomeTrends(context.Background(), "month", 0, false, false)
 	if err != nil {
 		t.Fatalf("AnalyzeIncomeTrends month: %v", err)
 	}
@@ -165,7 +258,7 @@ func TestAnalyzeIncomeAndSpendingTrendsWithFixtureDB(t *testing.T) {
 	assertFloatClose(t, "salary jan breakdown", incomeMonthly[0].ByCategory["Salary"], 3000, 0.001)
 	assertFloatClose(t, "jan income usd breakdown", incomeMonthly[0].ByCurrency["USD"], 3000, 0.001)
 
-	spendingMonthly, err := db.AnalyzeSpendingTrends("month", 0)
+	spendingMonthly, err := db.AnalyzeSpendingTrends(context.Background(), SpendingTrendOptions{GroupBy: "month"})
 	if err != nil {
 		t.Fatalf("AnalyzeSpendingTrends month: %v", err)
 	}
@@ -178,7 +271,7 @@ func TestAnalyzeIncomeAndSpendingTrendsWithFixtureDB(t *testing.T) {
 	assertFloatClose(t, "groceries feb breakdown", spendingMonthly[1].ByCategory["Groceries"], 300, 0.001)
 	assertFloatClose(t, "jan spending usd breakdown", spendingMonthly[0].ByCurrency["USD"], 1200, 0.001)
 
-	incomeYearly, err := db.AnalyzeIncomeTrends("year", 0)
+	incomeYearly, err := db.AnalyzeIncomeTrends(context.Background(), "year", 0, false, false)
 	if err != nil {
 		t.Fatalf("AnalyzeIncomeTrends year: %v", err)
 	}
@@ -188,7 +281,7 @@ func TestAnalyzeIncomeAndSpendingTrendsWithFixtureDB(t *testing.T) {
 	assertFloatClose(t, "2024 yearly income", incomeYearly[0].TotalIncome, 5500, 0.001)
 	assertFloatClose(t, "2024 yearly salary breakdown", incomeYearly[0].ByCategory["Salary"], 5500, 0.001)
 
-	spendingYearly, err := db.AnalyzeSpendingTrends("invalid", 0)
+	spendingYearly, err := db.AnalyzeSpendingTrends(context.Background(), SpendingTrendOptions{GroupBy: "invalid"})
 	if err != nil {
 		t.Fatalf("AnalyzeSpendingTrends invalid groupBy: %v", err)
 	}
@@ -197,164 +290,3898 @@ func TestAnalyzeIncomeAndSpendingTrendsWithFixtureDB(t *testing.T) {
 	}
 }
 
-func TestGetFinancialStatsWithFixtureDB(t *testing.T) {
-	db := newFixtureDB(t)
+func TestAnalyzeNetSavingsTrendWithFixtureDB(t *testing.T) {
+	db := newFixtureDBWithExtraRows(t, func(conn *sql.DB) {
+		insertTransaction(t, conn, 2000, 37, -50, "2024-04-03", "Pharmacy", 1, 0, 0)
+	})
 	defer db.Close()
 
-	got, err := db.GetFinancialStats()
+	periods, err := db.AnalyzeNetSavingsTrend(context.Background(), "month", 0, false, false)
 	if err != nil {
-		t.Fatalf("GetFinancialStats: %v", err)
+		t.Fatalf("AnalyzeNetSavingsTrend month: %v", err)
+	}
+	var got []string
+	for _, period := range periods {
+		got = append(got, period.Period)
 	}
+	if want := []string{"2024-01", "2024-02", "2024-03", "2024-04"}; strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("periods = %v, want %v (gaps filled)", got, want)
+	}
+	assertFloatClose(t, "jan income", periods[0].Income, 3000, 0.001)
+	assertFloatClose(t, "jan spending", periods[0].Spending, 1200, 0.001)
+	assertFloatClose(t, "jan net", periods[0].Net, 1800, 0.001)
+	assertFloatClose(t, "jan savings rate", periods[0].SavingsRate, 60, 0.001)
+	assertFloatClose(t, "feb usd net", periods[1].ByCurrency["USD"].Net, 2200, 0.001)
+	assertFloatClose(t, "empty march net", periods[2].Net, 0, 0.001)
+	assertFloatClose(t, "april net", periods[3].Net, -50, 0.001)
+	assertFloatClose(t, "april savings rate without income", periods[3].SavingsRate, 0, 0.001)
 
-	if got.TotalTransactions != 4 {
-		t.Fatalf("total transactions = %d, want 4", got.TotalTransactions)
+	yearly, err := db.AnalyzeNetSavingsTrend(context.Background(), "year", 0, false, false)
+	if err != nil {
+		t.Fatalf("AnalyzeNetSavingsTrend year: %v", err)
 	}
-	if got.IncomeTransactions != 2 {
-		t.Fatalf("income transactions = %d, want 2", got.IncomeTransactions)
+	if len(yearly) != 1 || yearly[0].Period != "2024" {
+		t.Fatalf("yearly periods = %+v, want one 2024 period", yearly)
 	}
-	if got.ExpenseTransactions != 2 {
-		t.Fatalf("expense transactions = %d, want 2", got.ExpenseTransactions)
+	assertFloatClose(t, "2024 net", yearly[0].Net, 5500-1550, 0.001)
+}
+
+func TestGetExpenseRatioTrendWithFixtureDB(t *testing.T) {
+	today := time.Now().UTC().Format(dayLayout)
+	db := newFixtureDBWithExtraRows(t, func(conn *sql.DB) {
+		insertTransaction(t, conn, 2000, 37, -80, "2024-03-05", "Groceries", 1, 0, 102)
+		insertTransaction(t, conn, 2001, 37, -50, today, "Groceries", 1, 0, 102)
+	})
+	defer db.Close()
+
+	trend, err := db.GetExpenseRatioTrend(context.Background(), 0, false)
+	if err != nil {
+		t.Fatalf("GetExpenseRatioTrend: %v", err)
 	}
-	if got.AccountCount != 1 {
-		t.Fatalf("account count = %d, want 1", got.AccountCount)
+	if len(trend.Periods) < 4 || trend.Periods[0].Month != "2024-01" {
+		t.Fatalf("expected a gapless series from 2024-01, got %+v", trend.Periods)
 	}
-	if got.CategoryCount != 3 {
-		t.Fatalf("category count = %d, want 3", got.CategoryCount)
+	january, february, march := trend.Periods[0], trend.Periods[1], trend.Periods[2]
+	if january.Ratio == nil || february.Ratio == nil {
+		t.Fatalf("expected ratios for the months with income, got %+v and %+v", january, february)
+	}
+	assertFloatClose(t, "january ratio", *january.Ratio, 1200.0/3000, 0.0001)
+	assertFloatClose(t, "february ratio", *february.Ratio, 300.0/2500, 0.0001)
+	if march.Ratio != nil || march.Expense != 80 {
+		t.Fatalf("expected march spending without a ratio, got %+v", march)
 	}
 
-	assertFloatClose(t, "total income", got.TotalIncome, 5500, 0.001)
-	assertFloatClose(t, "total spending", got.TotalSpending, 1500, 0.001)
-	assertFloatClose(t, "net savings", got.NetSavings, 4000, 0.001)
-	assertFloatClose(t, "average transaction", got.AverageTransaction, 1750, 0.001)
-	assertFloatClose(t, "largest income", got.LargestIncome, 3000, 0.001)
-	assertFloatClose(t, "largest expense", got.LargestExpense, 1200, 0.001)
-	if got.MixedCurrencies {
-		t.Fatal("mixed currencies = true, want false")
+	current := trend.Periods[len(trend.Periods)-1]
+	if !current.Partial || current.Month != today[:7] || current.Expense != 50 {
+		t.Fatalf("expected the current month flagged as partial, got %+v", current)
 	}
-	if got.PrimaryCurrency != "USD" {
-		t.Fatalf("primary currency = %q, want %q", got.PrimaryCurrency, "USD")
+	for _, period := range trend.Periods[:len(trend.Periods)-1] {
+		if period.Partial {
+			t.Fatalf("only the current month should be partial, got %+v", period)
+		}
 	}
-	if len(got.Currencies) != 1 || got.Currencies[0] != "USD" {
-		t.Fatalf("currencies = %#v, want [USD]", got.Currencies)
+
+	// The partial month is left out of the summary ratios
+	if trend.LatestRatio == nil || trend.AverageRatio == nil {
+		t.Fatalf("expected latest and average ratios, got %+v", trend)
 	}
-	usd, ok := got.ByCurrency["USD"]
-	if !ok {
-		t.Fatal("missing USD by_currency stats")
+	assertFloatClose(t, "latest ratio", *trend.LatestRatio, 300.0/2500, 0.0001)
+	assertFloatClose(t, "average ratio", *trend.AverageRatio, 1580.0/5500, 0.0001)
+}
+
+func TestGetCategoryDistributionWithFixtureDB(t *testing.T) {
+	db := newFixtureDBWithExtraRows(t, func(conn *sql.DB) {
+		insertTransaction(t, conn, 2000, 37, -20, "2024-02-11", "Corner shop", 1, 0, 102)
+		insertTransaction(t, conn, 2001, 37, -40, "2024-02-12", "Bakery", 1, 0, 102)
+		insertTransaction(t, conn, 2002, 37, -100, "2024-02-13", "Market", 1, 0, 102)
+	})
+	defer db.Close()
+
+	got, err := db.GetCategoryDistribution(context.Background(), 0, "groceries", 0, false)
+	if err != nil {
+		t.Fatalf("GetCategoryDistribution: %v", err)
 	}
-	assertFloatClose(t, "usd average transaction", usd.AverageTransaction, 1750, 0.001)
-	assertFloatClose(t, "usd total income", usd.TotalIncome, 5500, 0.001)
-	assertFloatClose(t, "usd total spending", usd.TotalSpending, 1500, 0.001)
+	if got.ID != 102 || got.TransactionCount != 4 {
+		t.Fatalf("distribution = %+v, want 4 Groceries expenses", got)
+	}
+	assertFloatClose(t, "total", got.Total, 460, 0.001)
+	assertFloatClose(t, "min", got.Min, 20, 0.001)
+	assertFloatClose(t, "median", got.Median, 70, 0.001)
+	assertFloatClose(t, "mean", got.Mean, 115, 0.001)
+	assertFloatClose(t, "max", got.Max, 300, 0.001)
+	assertFloatClose(t, "p90", got.P90, 240, 0.001)
+	assertFloatClose(t, "usd median", got.ByCurrency["USD"].Median, 70, 0.001)
 
-	if got.FirstTransactionDate != "2024-01-15 00:00:00" {
-		t.Fatalf("first transaction date = %q", got.FirstTransactionDate)
+	got, err = db.GetCategoryDistribution(context.Background(), 100, "", 0, false)
+	if err != nil {
+		t.Fatalf("GetCategoryDistribution without expenses: %v", err)
 	}
-	if got.LastTransactionDate != "2024-02-10 00:00:00" {
-		t.Fatalf("last transaction date = %q", got.LastTransactionDate)
+	if got.TransactionCount != 0 || got.Median != 0 || len(got.ByCurrency) != 0 {
+		t.Fatalf("distribution without expenses = %+v, want zeros", got)
 	}
-	if got.DateRange != "2024-01-15 00:00:00 to 2024-02-10 00:00:00" {
-		t.Fatalf("date range = %q", got.DateRange)
+}
+
+func TestGetCategoryTreeWithFixtureDB(t *testing.T) {
+	db := newFixtureDBWithExtraRows(t, func(conn *sql.DB) {
+		mustExecSQL(t, conn, `
+			ALTER TABLE ZSYNCOBJECT ADD COLUMN ZPARENTCATEGORY INTEGER;
+			INSERT INTO ZSYNCOBJECT (Z_PK, Z_ENT, ZNAME2) VALUES (104, 19, 'Food');
+			INSERT INTO ZSYNCOBJECT (Z_PK, Z_ENT, ZNAME2, ZPARENTCATEGORY) VALUES (105, 19, 'Restaurants', 104);
+			INSERT INTO ZSYNCOBJECT (Z_PK, Z_ENT, ZNAME2, ZPARENTCATEGORY) VALUES (107, 19, 'Deposit', 101);
+			INSERT INTO ZSYNCOBJECT (Z_PK, Z_ENT, ZNAME2, ZPARENTCATEGORY) VALUES (108, 19, 'Lost', 999);
+			INSERT INTO ZSYNCOBJECT (Z_PK, Z_ENT, ZNAME2, ZPARENTCATEGORY) VALUES (109, 19, 'Loop A', 110);
+			INSERT INTO ZSYNCOBJECT (Z_PK, Z_ENT, ZNAME2, ZPARENTCATEGORY) VALUES (110, 19, 'Loop B', 109);
+			UPDATE ZSYNCOBJECT SET ZPARENTCATEGORY = 104 WHERE Z_PK = 102;
+		`)
+		insertTransaction(t, conn, 2000, 37, -60, "2024-02-14", "Bistro", 1, 0, 105)
+		insertTransaction(t, conn, 2001, 37, -40, "2024-01-25", "Market", 1, 0, 102)
+		insertTransaction(t, conn, 2002, 37, -10, "2024-01-26", "Snacks", 1, 0, 104)
+		insertTransaction(t, conn, 2003, 37, -5, "2024-01-27", "Found it", 1, 0, 108)
+		insertUncategorizedTransaction(t, conn, 2004, 37, -7, "2024-01-28", "Kiosk", 1, 0)
+	})
+	defer db.Close()
+
+	tree, err := db.GetCategoryTree(context.Background(), 0)
+	if err != nil {
+		t.Fatalf("GetCategoryTree: %v", err)
+	}
+	assertFloatClose(t, "total spending", tree.TotalSpending, 1615, 0.001)
+	assertFloatClose(t, "uncategorized spending", tree.UncategorizedSpending, 7, 0.001)
+	if tree.CategoryCount != 9 || tree.OrphanCount != 2 {
+		t.Fatalf("category count %d, orphan count %d; want 9 and 2", tree.CategoryCount, tree.OrphanCount)
 	}
 
-	year2024, ok := got.ByYear["2024"]
-	if !ok {
-		t.Fatalf("missing by_year entry for 2024")
+	var names []string
+	for _, root := range tree.Roots {
+		names = append(names, root.Name)
 	}
-	assertFloatClose(t, "2024 income", year2024.Income, 5500, 0.001)
-	assertFloatClose(t, "2024 spending", year2024.Spending, 1500, 0.001)
-	assertFloatClose(t, "2024 net savings", year2024.NetSavings, 4000, 0.001)
-	if year2024.TransactionCount != 4 {
-		t.Fatalf("2024 transaction count = %d, want 4", year2024.TransactionCount)
+	if got, want := strings.Join(names, ", "), "Rent, Food, Lost, Loop A, Salary"; got != want {
+		t.Fatalf("roots = %s, want %s", got, want)
+	}
+
+	food := tree.Roots[1]
+	assertFloatClose(t, "food own spending", food.OwnSpending, 10, 0.001)
+	assertFloatClose(t, "food total spending", food.TotalSpending, 410, 0.001)
+	assertFloatClose(t, "food share", food.SharePercent, 410.0/1615*100, 0.001)
+	if food.OwnTransactionCount != 1 || food.TransactionCount != 4 || len(food.Children) != 2 {
+		t.Fatalf("food = %+v, want its own expense, four in total, and two children", food)
+	}
+	if groceries := food.Children[0]; groceries.Name != "Groceries" || groceries.FullPath != "Food > Groceries" || groceries.TotalSpending != 340 {
+		t.Fatalf("first food child = %+v, want Groceries with 340", groceries)
+	}
+
+	if lost := tree.Roots[2]; !lost.Orphan || lost.TotalSpending != 5 {
+		t.Fatalf("lost = %+v, want an orphan root with 5", lost)
+	}
+	if loop := tree.Roots[3]; !loop.Orphan || len(loop.Children) != 1 || loop.Children[0].Name != "Loop B" || len(loop.Children[0].Children) != 0 {
+		t.Fatalf("loop = %+v, want the cycle broken below Loop A", loop)
+	}
+	if rent := tree.Roots[0]; rent.Orphan || len(rent.Children) != 1 || rent.Children[0].TotalSpending != 0 {
+		t.Fatalf("rent = %+v, want its unused Deposit child", rent)
 	}
 }
 
-func TestMixedCurrencyStatsAndInternalMovementsWithFixtureDB(t *testing.T) {
+func TestGetSpendingForCategoryWithFixtureDB(t *testing.T) {
 	db := newFixtureDBWithExtraRows(t, func(conn *sql.DB) {
 		mustExecSQL(t, conn, `
-			INSERT INTO ZSYNCOBJECT (Z_PK, Z_ENT, ZNAME, ZBALLANCE, ZOPENINGBALANCE, ZCURRENCYNAME, ZTYPE)
-			VALUES (2, 10, 'EUR Checking', 0, 500, 'EUR', 'bank');
+			ALTER TABLE ZSYNCOBJECT ADD COLUMN ZPARENTCATEGORY INTEGER;
+			INSERT INTO ZSYNCOBJECT (Z_PK, Z_ENT, ZNAME2) VALUES (104, 19, 'Food');
+			INSERT INTO ZSYNCOBJECT (Z_PK, Z_ENT, ZNAME2, ZPARENTCATEGORY) VALUES (105, 19, 'Restaurants', 104);
+			INSERT INTO ZSYNCOBJECT (Z_PK, Z_ENT, ZNAME2, ZPARENTCATEGORY) VALUES (106, 19, 'Other', 104);
+			INSERT INTO ZSYNCOBJECT (Z_PK, Z_ENT, ZNAME2, ZPARENTCATEGORY) VALUES (107, 19, 'Other', 101);
+			UPDATE ZSYNCOBJECT SET ZPARENTCATEGORY = 104 WHERE Z_PK = 102;
 		`)
-
-		insertTransaction(t, conn, 2000, 37, 2000, "2024-02-15", "Consulting invoice", 2, 0, 100)
-		insertTransaction(t, conn, 2001, 37, -500, "2024-02-16", "Groceries EU", 2, 0, 102)
-		insertUncategorizedTransaction(t, conn, 2002, 43, -100, "2024-02-17", "Transfer to Cash", 1, 0)
-		insertUncategorizedTransaction(t, conn, 2003, 43, 100, "2024-02-17", "Transfer from Checking", 2, 0)
-		insertUncategorizedTransaction(t, conn, 2004, 46, -50, "2024-02-18", "ATM Withdrawal", 1, 0)
-		insertUncategorizedTransaction(t, conn, 2005, 46, 50, "2024-02-18", "ATM Withdrawal", 2, 0)
+		insertTransaction(t, conn, 2000, 37, -60, "2024-02-14", "Bistro", 1, 0, 105)
+		insertTransaction(t, conn, 2001, 37, -40, "2024-01-25", "Market", 1, 0, 102)
 	})
 	defer db.Close()
 
-	savings, err := db.AnalyzeSavings(0)
+	detail, err := db.GetSpendingForCategory(context.Background(), 102, "", 0, false)
 	if err != nil {
-		t.Fatalf("AnalyzeSavings: %v", err)
+		t.Fatalf("GetSpendingForCategory by ID: %v", err)
 	}
-	if !savings.MixedCurrencies {
-		t.Fatal("mixed currencies = false, want true")
+	if detail.Name != "Groceries" || detail.FullPath != "Food > Groceries" || detail.TransactionCount != 2 {
+		t.Fatalf("detail = %+v, want the two Groceries expenses", detail)
 	}
-	if savings.CurrencyWarning == "" {
-		t.Fatal("currency warning is empty")
+	assertFloatClose(t, "groceries total", detail.TotalSpending, 340, 0.001)
+	assertFloatClose(t, "groceries usd", detail.ByCurrency["USD"], 340, 0.001)
+	if detail.Transactions[0].TransactionID != 1003 || detail.Transactions[1].TransactionID != 2001 {
+		t.Fatalf("transactions = %+v, want most recent first", detail.Transactions)
 	}
-	if savings.PrimaryCurrency != "" {
-		t.Fatalf("primary currency = %q, want empty", savings.PrimaryCurrency)
+	if detail.FirstTransactionDate[:10] != "2024-01-25" || detail.LatestTransactionDate[:10] != "2024-02-10" {
+		t.Fatalf("dates = %s to %s", detail.FirstTransactionDate, detail.LatestTransactionDate)
 	}
-	if len(savings.Currencies) != 2 || savings.Currencies[0] != "EUR" || savings.Currencies[1] != "USD" {
-		t.Fatalf("currencies = %#v, want [EUR USD]", savings.Currencies)
+
+	detail, err = db.GetSpendingForCategory(context.Background(), 0, "food", 0, true)
+	if err != nil {
+		t.Fatalf("GetSpendingForCategory by name with subcategories: %v", err)
 	}
-	assertFloatClose(t, "mixed total income", savings.TotalIncome, 7500, 0.001)
-	assertFloatClose(t, "mixed total spending", savings.TotalSpending, 2000, 0.001)
-	assertFloatClose(t, "usd income", savings.ByCurrency["USD"].TotalIncome, 5500, 0.001)
-	assertFloatClose(t, "usd spending", savings.ByCurrency["USD"].TotalSpending, 1500, 0.001)
-	assertFloatClose(t, "eur income", savings.ByCurrency["EUR"].TotalIncome, 2000, 0.001)
-	assertFloatClose(t, "eur spending", savings.ByCurrency["EUR"].TotalSpending, 500, 0.001)
+	if detail.ID != 104 || detail.TransactionCount != 3 {
+		t.Fatalf("detail = %+v, want Food with its three subcategory expenses", detail)
+	}
+	assertFloatClose(t, "food total", detail.TotalSpending, 400, 0.001)
+
+	detail, err = db.GetSpendingForCategory(context.Background(), 0, "Food > Other", 0, false)
+	if err != nil || detail.ID != 106 {
+		t.Fatalf("GetSpendingForCategory by full path = %+v, %v; want category 106", detail, err)
+	}
+	if _, err := db.GetSpendingForCategory(context.Background(), 0, "Other", 0, false); !errors.Is(err, ErrInvalidArgument) || !strings.Contains(err.Error(), "Rent > Other (ID 107)") {
+		t.Fatalf("ambiguous name: err = %v, want ErrInvalidArgument listing the matches", err)
+	}
+	if _, err := db.GetSpendingForCategory(context.Background(), 999, "", 0, false); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("unknown ID: err = %v, want ErrNotFound", err)
+	}
+	if _, err := db.GetSpendingForCategory(context.Background(), 0, " ", 0, false); !errors.Is(err, ErrInvalidArgument) {
+		t.Fatalf("no category: err = %v, want ErrInvalidArgument", err)
+	}
+}
+
+func TestAnalyzeSavingsTopCategoriesWithFixtureDB(t *testing.T) {
+	db := newFixtureDB(t)
+	defer db.Close()
+
+	for _, tc := range []struct {
+		topCategories int
+		wantNames     []string
+	}{
+		{topCategories: 1, wantNames: []string{"Rent"}},
+		{topCategories: 0, wantNames: []string{"Rent", "Groceries"}},
+	} {
+		got, err := db.AnalyzeSavings(context.Background(), SavingsOptions{TopCategories: tc.topCategories})
+		if err != nil {
+			t.Fatalf("AnalyzeSavings(top %d): %v", tc.topCategories, err)
+		}
+		if len(got.TopSpendingCategories) != len(tc.wantNames) || len(got.ByCurrency["USD"].TopSpendingCategories) != len(tc.wantNames) {
+			t.Fatalf("top %d: categories = %+v, want %v", tc.topCategories, got.TopSpendingCategories, tc.wantNames)
+		}
+		for i, name := range tc.wantNames {
+			if got.TopSpendingCategories[i].CategoryName != name {
+				t.Fatalf("top %d: category[%d] = %q, want %q", tc.topCategories, i, got.TopSpendingCategories[i].CategoryName, name)
+			}
+		}
+	}
+
+	if _, err := db.AnalyzeSavings(context.Background(), SavingsOptions{TopCategories: -1}); !errors.Is(err, ErrInvalidArgument) {
+		t.Fatalf("negative top categories: err = %v, want ErrInvalidArgument", err)
+	}
+}
+
+func TestAnalyzeSavingsRefundsWithFixtureDB(t *testing.T) {
+	db := newFixtureDBWithExtraRows(t, func(conn *sql.DB) {
+		insertTransaction(t, conn, 2000, 37, 50, "2024-02-12", "Returned groceries", 1, 0, 102)
+	})
+	defer db.Close()
 
-	stats, err := db.GetFinancialStats()
+	income, err := db.GetIncomeData(context.Background(), 0, false, false)
 	if err != nil {
-		t.Fatalf("GetFinancialStats: %v", err)
+		t.Fatalf("GetIncomeData: %v", err)
 	}
-	if !stats.MixedCurrencies {
-		t.Fatal("mixed currencies = false, want true")
+	for _, i := range income {
+		if i.IsRefund != (i.TransactionID == 2000) {
+			t.Fatalf("income %d is_refund = %v", i.TransactionID, i.IsRefund)
+		}
 	}
-	if stats.CurrencyWarning == "" {
-		t.Fatal("currency warning is empty")
+
+	asIncome, err := db.AnalyzeSavings(context.Background(), SavingsOptions{TopCategories: 5})
+	if err != nil {
+		t.Fatalf("AnalyzeSavings: %v", err)
 	}
-	if stats.TotalTransactions != 6 {
-		t.Fatalf("total transactions = %d, want 6", stats.TotalTransactions)
+	assertFloatClose(t, "income with refund", asIncome.TotalIncome, 5550, 0.001)
+	assertFloatClose(t, "spending without netting", asIncome.TotalSpending, 1500, 0.001)
+	assertFloatClose(t, "refunds", asIncome.Refunds, 50, 0.001)
+	if asIncome.RefundCount != 1 || asIncome.RefundsNetted {
+		t.Fatalf("refund count = %d, netted = %v; want 1, false", asIncome.RefundCount, asIncome.RefundsNetted)
 	}
-	assertFloatClose(t, "stats total income", stats.TotalIncome, 7500, 0.001)
-	assertFloatClose(t, "stats total spending", stats.TotalSpending, 2000, 0.001)
-	assertFloatClose(t, "stats eur income", stats.ByCurrency["EUR"].TotalIncome, 2000, 0.001)
-	assertFloatClose(t, "stats eur spending", stats.ByCurrency["EUR"].TotalSpending, 500, 0.001)
 
-	transactions, err := db.GetTransactions(0, 10)
+	netted, err := db.AnalyzeSavings(context.Background(), SavingsOptions{NetRefunds: true, TopCategories: 5})
 	if err != nil {
-		t.Fatalf("GetTransactions: %v", err)
+		t.Fatalf("AnalyzeSavings net refunds: %v", err)
 	}
-	foundTransfer := false
-	foundATM := false
-	for _, txn := range transactions {
-		if txn.Description == "Transfer to Cash" {
-			foundTransfer = true
-			if txn.MovementType != movementTypeTransfer {
-				t.Fatalf("transfer movement type = %q, want %q", txn.MovementType, movementTypeTransfer)
-			}
-			if txn.CategoryName != "Internal Transfer" {
-				t.Fatalf("transfer category = %q, want %q", txn.CategoryName, "Internal Transfer")
-			}
+	assertFloatClose(t, "income without refund", netted.TotalIncome, 5500, 0.001)
+	assertFloatClose(t, "spending net of refund", netted.TotalSpending, 1450, 0.001)
+	assertFloatClose(t, "net savings unchanged", netted.NetSavings, asIncome.NetSavings, 0.001)
+	assertFloatClose(t, "netted refunds", netted.Refunds, 50, 0.001)
+	for _, category := range netted.TopSpendingCategories {
+		if category.CategoryName == "Groceries" {
+			assertFloatClose(t, "groceries net of refund", category.TotalAmount, 250, 0.001)
 		}
-		if txn.Description == "ATM Withdrawal" && txn.Amount < 0 {
-			foundATM = true
-			if txn.MovementType != movementTypeCashWithdrawal {
-				t.Fatalf("atm movement type = %q, want %q", txn.MovementType, movementTypeCashWithdrawal)
-			}
-			if txn.CategoryName != "Cash Withdrawal" {
-				t.Fatalf("atm category = %q, want %q", txn.CategoryName, "Cash Withdrawal")
-			}
+	}
+}
+
+func TestSameNamedCategoriesStaySeparateWithFixtureDB(t *testing.T) {
+	db := newFixtureDBWithExtraRows(t, func(conn *sql.DB) {
+		mustExecSQL(t, conn, `
+			ALTER TABLE ZSYNCOBJECT ADD COLUMN ZPARENTCATEGORY INTEGER;
+			INSERT INTO ZSYNCOBJECT (Z_PK, Z_ENT, ZNAME2) VALUES (104, 19, 'Dining'), (105, 19, 'Dining'), (106, 19, 'Travel');
+			INSERT INTO ZSYNCOBJECT (Z_PK, Z_ENT, ZNAME2, ZPARENTCATEGORY) VALUES (107, 19, 'Dining', 106);
+		`)
+		insertTransaction(t, conn, 2000, 37, -60, "2024-02-14", "Bistro", 1, 0, 104)
+		insertTransaction(t, conn, 2001, 37, -40, "2024-02-15", "Cafe", 1, 0, 105)
+		insertTransaction(t, conn, 2002, 37, -25, "2024-02-16", "Airport lunch", 1, 0, 107)
+	})
+	defer db.Close()
+
+	trends, err := db.AnalyzeSpendingTrends(context.Background(), SpendingTrendOptions{GroupBy: "month"})
+	if err != nil {
+		t.Fatalf("AnalyzeSpendingTrends: %v", err)
+	}
+	february := trends[len(trends)-1].ByCategory
+	assertFloatClose(t, "first Dining", february["Dining (ID 104)"], 60, 0.001)
+	assertFloatClose(t, "second Dining", february["Dining (ID 105)"], 40, 0.001)
+	assertFloatClose(t, "Travel > Dining", february["Travel > Dining"], 25, 0.001)
+	if _, ok := february["Dining"]; ok {
+		t.Fatalf("by_category = %v, want same-named categories kept apart", february)
+	}
+	assertFloatClose(t, "unique name unchanged", february["Groceries"], 300, 0.001)
+
+	analysis, err := db.AnalyzeSavings(context.Background(), SavingsOptions{TopCategories: 5})
+	if err != nil {
+		t.Fatalf("AnalyzeSavings: %v", err)
+	}
+	for _, category := range analysis.TopSpendingCategories {
+		if category.CategoryName == "Dining" {
+			t.Fatalf("top categories = %+v, want same-named categories kept apart", analysis.TopSpendingCategories)
 		}
 	}
-	if !foundTransfer {
-		t.Fatal("did not find transfer transaction")
+}
+
+func TestCategoryAliasesWithFixtureDB(t *testing.T) {
+	db := newFixtureDBWithExtraRows(t, func(conn *sql.DB) {
+		mustExecSQL(t, conn, `INSERT INTO ZSYNCOBJECT (Z_PK, Z_ENT, ZNAME2) VALUES (103, 19, 'Groceries - old');`)
+		insertTransaction(t, conn, 2000, 37, -50, "2024-02-12", "Corner shop", 1, 0, 103)
+	})
+	defer db.Close()
+	ctx := context.Background()
+	aliases := CategoryAliases{"groceries - OLD": "Groceries", "101": "Housing"}
+
+	trends, err := db.AnalyzeSpendingTrends(ctx, SpendingTrendOptions{GroupBy: "month", Aliases: aliases})
+	if err != nil {
+		t.Fatalf("AnalyzeSpendingTrends with aliases: %v", err)
 	}
-	if !foundATM {
-		t.Fatal("did not find atm withdrawal transaction")
+	jan, feb := trends[0].ByCategory, trends[1].ByCategory
+	if len(feb) != 1 {
+		t.Fatalf("february categories = %v, want only Groceries", feb)
+	}
+	assertFloatClose(t, "merged groceries", feb["Groceries"], 350, 0.001)
+	assertFloatClose(t, "housing by ID", jan["Housing"], 1200, 0.001)
+
+	savings, err := db.AnalyzeSavings(ctx, SavingsOptions{Aliases: aliases})
+	if err != nil {
+		t.Fatalf("AnalyzeSavings with aliases: %v", err)
+	}
+	names := make([]string, len(savings.TopSpendingCategories))
+	for i, category := range savings.TopSpendingCategories {
+		names[i] = category.CategoryName
+	}
+	if len(names) != 2 || names[0] != "Housing" || names[1] != "Groceries" {
+		t.Fatalf("top spending categories = %v, want [Housing Groceries]", names)
+	}
+
+	if _, err := db.AnalyzeSpendingTrends(ctx, SpendingTrendOptions{GroupBy: "month", Aliases: CategoryAliases{"Rent": " "}}); !errors.Is(err, ErrInvalidArgument) {
+		t.Fatalf("blank alias error = %v, want ErrInvalidArgument", err)
+	}
+}
+
+func TestGetTransactionHistogramWithFixtureDB(t *testing.T) {
+	db := newFixtureDBWithExtraRows(t, func(conn *sql.DB) {
+		insertTransaction(t, conn, 2000, 37, -20, "2024-02-11", "Coffee beans", 1, 0, 102)
+		insertTransaction(t, conn, 2001, 37, -30, "2024-02-12", "Bakery", 1, 0, 102)
+	})
+	defer db.Close()
+	ctx := context.Background()
+
+	got, err := db.GetTransactionHistogram(ctx, "", 0, 100, 5)
+	if err != nil {
+		t.Fatalf("GetTransactionHistogram: %v", err)
+	}
+	if got.Kind != HistogramKindSpending || got.Income != nil || got.Spending == nil {
+		t.Fatalf("kind = %q with income %v, want spending only", got.Kind, got.Income)
+	}
+	buckets := got.Spending.Buckets
+	if len(buckets) != 5 || buckets[0].Label != "0-100" || buckets[4].Label != "400+" || buckets[4].Max != nil {
+		t.Fatalf("buckets = %+v, want five ending with an open 400+", buckets)
+	}
+	if buckets[0].Count != 2 || buckets[3].Count != 1 || buckets[4].Count != 1 {
+		t.Fatalf("bucket counts = %d/%d/%d, want 2 small, groceries, and rent in the open bucket", buckets[0].Count, buckets[3].Count, buckets[4].Count)
+	}
+	assertFloatClose(t, "small total", buckets[0].TotalAmount, 50, 0.001)
+	assertFloatClose(t, "small count share", buckets[0].CountSharePercent, 50, 0.001)
+	assertFloatClose(t, "rent amount share", buckets[4].AmountSharePercent, 1200.0/1550*100, 0.001)
+
+	both, err := db.GetTransactionHistogram(ctx, HistogramKindBoth, 0, 0, 0)
+	if err != nil {
+		t.Fatalf("GetTransactionHistogram for both: %v", err)
+	}
+	if both.Income == nil || both.Income.TransactionCount != 2 || len(both.Income.Buckets) != 20 {
+		t.Fatalf("income = %+v, want two salaries in the default 20 buckets", both.Income)
+	}
+	if both.Income.Buckets[19].Count != 2 || both.Income.Buckets[19].Label != "475+" {
+		t.Fatalf("last income bucket = %+v, want both salaries in 475+", both.Income.Buckets[19])
+	}
+
+	if _, err := db.GetTransactionHistogram(ctx, "transfers", 0, 0, 0); !errors.Is(err, ErrInvalidArgument) {
+		t.Fatalf("invalid kind error = %v, want ErrInvalidArgument", err)
+	}
+}
+
+func TestGetBurnRateWithFixtureDB(t *testing.T) {
+	db := newFixtureDB(t)
+	defer db.Close()
+
+	rate, err := db.GetBurnRate(context.Background(), 30, "2024-02-10")
+	if err != nil {
+		t.Fatalf("GetBurnRate: %v", err)
+	}
+	if rate.TrailingStart != "2024-01-12" || rate.Month != "2024-02" {
+		t.Fatalf("window = %s to %s in %s", rate.TrailingStart, rate.AsOf, rate.Month)
+	}
+	if rate.DaysInMonth != 29 || rate.DaysElapsed != 10 || rate.DaysRemaining != 19 {
+		t.Fatalf("days = %d in month, %d elapsed, %d remaining; want 29, 10, 19", rate.DaysInMonth, rate.DaysElapsed, rate.DaysRemaining)
+	}
+	// Rent on 2024-01-20 and groceries on 2024-02-10 fall in the window
+	assertFloatClose(t, "trailing spending", rate.TrailingSpending, 1500, 0.001)
+	assertFloatClose(t, "average daily spend", rate.AverageDailySpend, 50, 0.001)
+	assertFloatClose(t, "month to date", rate.MonthToDateSpending, 300, 0.001)
+	assertFloatClose(t, "month to date usd", rate.MonthToDateByCurrency["USD"], 300, 0.001)
+	assertFloatClose(t, "projected month end", rate.ProjectedMonthEnd, 300+50*19, 0.001)
+	assertFloatClose(t, "projected at current pace", rate.ProjectedAtCurrentPace, 30*29, 0.001)
+
+	before, err := db.GetBurnRate(context.Background(), 0, "2024-02-09")
+	if err != nil {
+		t.Fatalf("GetBurnRate before groceries: %v", err)
+	}
+	if before.TrailingDays != 30 {
+		t.Fatalf("trailing days = %d, want the default 30", before.TrailingDays)
+	}
+	assertFloatClose(t, "month to date before groceries", before.MonthToDateSpending, 0, 0.001)
+
+	if _, err := db.GetBurnRate(context.Background(), 30, "02/10/2024"); !errors.Is(err, ErrInvalidArgument) {
+		t.Fatalf("invalid as_of: err = %v, want ErrInvalidArgument", err)
+	}
+	if _, err := db.GetBurnRate(context.Background(), -1, ""); !errors.Is(err, ErrInvalidArgument) {
+		t.Fatalf("negative trailing days: err = %v, want ErrInvalidArgument", err)
+	}
+}
+
+func TestGetSpendingVelocityWithFixtureDB(t *testing.T) {
+	db := newFixtureDBWithExtraRows(t, func(conn *sql.DB) {
+		insertTransaction(t, conn, 2000, 37, -500, "2024-03-03", "Weekend trip", 1, 0, 102)
+		insertTransaction(t, conn, 2001, 37, -80, "2024-03-20", "After as_of", 1, 0, 102)
+	})
+	defer db.Close()
+
+	got, err := db.GetSpendingVelocity(context.Background(), 0, "2024-03-15")
+	if err != nil {
+		t.Fatalf("GetSpendingVelocity: %v", err)
+	}
+
+	if len(got.ComparedMonths) != 2 || got.ComparedMonths[0] != "2024-01" || got.ComparedMonths[1] != "2024-02" {
+		t.Fatalf("compared months = %v, want [2024-01 2024-02]", got.ComparedMonths)
+	}
+	if got.DaysInMonth != 31 || len(got.Days) != 31 {
+		t.Fatalf("days in month = %d with %d points, want 31", got.DaysInMonth, len(got.Days))
+	}
+	assertFloatClose(t, "current to date", got.CurrentToDate, 500, 0.001)
+	assertFloatClose(t, "average to date", got.AverageToDate, 150, 0.001)
+	assertFloatClose(t, "difference", got.Difference, 350, 0.001)
+	assertFloatClose(t, "average month total", got.AverageMonthTotal, 750, 0.001)
+	if got.Pace != SpendingPaceAhead {
+		t.Fatalf("pace = %q, want %q", got.Pace, SpendingPaceAhead)
+	}
+	assertFloatClose(t, "day 20 average", got.Days[19].AverageCumulative, 750, 0.001)
+	if got.Days[14].CurrentCumulative == nil || got.Days[15].CurrentCumulative != nil {
+		t.Fatalf("current cumulative should stop at day 15, got %v and %v", got.Days[14].CurrentCumulative, got.Days[15].CurrentCumulative)
+	}
+
+	if _, err := db.GetSpendingVelocity(context.Background(), -1, ""); !errors.Is(err, ErrInvalidArgument) {
+		t.Fatalf("negative months error = %v, want ErrInvalidArgument", err)
+	}
+}
+
+func TestGetLargestTransactionsWithFixtureDB(t *testing.T) {
+	db := newFixtureDBWithExtraRows(t, func(conn *sql.DB) {
+		insertTransaction(t, conn, 2000, 37, -5000, "2024-02-11", "Transfer to Savings", 1, 0, 0)
+	})
+	defer db.Close()
+
+	tests := []struct {
+		transactionType string
+		limit           int
+		wantIDs         []int64
+	}{
+		{transactionType: "", limit: 3, wantIDs: []int64{1000, 1002, 1001}},
+		{transactionType: "expense", limit: 10, wantIDs: []int64{1001, 1003}},
+		{transactionType: "income", limit: 1, wantIDs: []int64{1000}},
+	}
+	for _, tc := range tests {
+		got, err := db.GetLargestTransactions(context.Background(), 0, tc.limit, tc.transactionType)
+		if err != nil {
+			t.Fatalf("GetLargestTransactions(%q): %v", tc.transactionType, err)
+		}
+		if len(got.Transactions) != len(tc.wantIDs) {
+			t.Fatalf("type %q: transactions = %+v, want ids %v", tc.transactionType, got.Transactions, tc.wantIDs)
+		}
+		for i, want := range tc.wantIDs {
+			if got.Transactions[i].ID != want {
+				t.Fatalf("type %q: transactions[%d].ID = %d, want %d", tc.transactionType, i, got.Transactions[i].ID, want)
+			}
+		}
+	}
+
+	expenses, err := db.GetLargestTransactions(context.Background(), 0, 1, "expense")
+	if err != nil {
+		t.Fatalf("GetLargestTransactions expense: %v", err)
+	}
+	rent := expenses.Transactions[0]
+	if rent.Amount != -1200 || rent.CategoryName != "Rent" || rent.AccountName != "Checking" || rent.Currency != "USD" {
+		t.Fatalf("largest expense = %+v, want the Rent payment from Checking", rent)
+	}
+
+	if _, err := db.GetLargestTransactions(context.Background(), 0, 10, "transfers"); !errors.Is(err, ErrInvalidArgument) {
+		t.Fatalf("invalid type: err = %v, want ErrInvalidArgument", err)
+	}
+}
+
+func TestCategoryHierarchyAndRollupWithFixtureDB(t *testing.T) {
+	db := newFixtureDBWithExtraRows(t, func(conn *sql.DB) {
+		mustExecSQL(t, conn, `
+			ALTER TABLE ZSYNCOBJECT ADD COLUMN ZPARENTCATEGORY INTEGER;
+			INSERT INTO ZSYNCOBJECT (Z_PK, Z_ENT, ZNAME2) VALUES (104, 19, 'Food');
+			INSERT INTO ZSYNCOBJECT (Z_PK, Z_ENT, ZNAME2, ZPARENTCATEGORY) VALUES (105, 19, 'Restaurants', 104);
+			UPDATE ZSYNCOBJECT SET ZPARENTCATEGORY = 104 WHERE Z_PK = 102;
+		`)
+		insertTransaction(t, conn, 2000, 37, -60, "2024-02-14", "Bistro", 1, 0, 105)
+	})
+	defer db.Close()
+
+	categories, err := db.GetCategories(context.Background(), "")
+	if err != nil {
+		t.Fatalf("GetCategories: %v", err)
+	}
+	byName := make(map[string]Category)
+	for _, cat := range categories {
+		byName[cat.Name] = cat
+	}
+	if groceries := byName["Groceries"]; groceries.ParentID != 104 || groceries.FullPath != "Food > Groceries" {
+		t.Fatalf("groceries = %+v, want parent 104 and path Food > Groceries", groceries)
+	}
+	if food := byName["Food"]; food.ParentID != 0 || food.FullPath != "Food" {
+		t.Fatalf("food = %+v, want a top-level category", food)
+	}
+
+	trends, err := db.AnalyzeSpendingTrends(context.Background(), SpendingTrendOptions{GroupBy: "month", Rollup: true})
+	if err != nil {
+		t.Fatalf("AnalyzeSpendingTrends with rollup: %v", err)
+	}
+	feb := trends[len(trends)-1].ByCategory
+	if len(feb) != 1 {
+		t.Fatalf("february categories = %v, want only Food", feb)
+	}
+	assertFloatClose(t, "food rollup", feb["Food"], 360, 0.001)
+
+	savings, err := db.AnalyzeSavings(context.Background(), SavingsOptions{Rollup: true, TopCategories: 5})
+	if err != nil {
+		t.Fatalf("AnalyzeSavings with rollup: %v", err)
+	}
+	if len(savings.TopSpendingCategories) != 2 || savings.TopSpendingCategories[1].CategoryName != "Food" {
+		t.Fatalf("top categories = %+v, want [Rent Food]", savings.TopSpendingCategories)
+	}
+}
+
+func TestGetCategoriesWithTotalsWithFixtureDB(t *testing.T) {
+	db := newFixtureDBWithExtraRows(t, func(conn *sql.DB) {
+		mustExecSQL(t, conn, `INSERT INTO ZSYNCOBJECT (Z_PK, Z_ENT, ZNAME2) VALUES (103, 19, 'Hobbies');`)
+		insertTransaction(t, conn, 2000, 37, -80, "2024-02-12", "Groceries", 1, 0, 102)
+	})
+	defer db.Close()
+
+	got, err := db.GetCategoriesWithTotals(context.Background(), 0, "")
+	if err != nil {
+		t.Fatalf("GetCategoriesWithTotals: %v", err)
+	}
+	if got.CategoryCount != 4 || got.UnusedCount != 1 {
+		t.Fatalf("category count = %d, unused = %d; want 4 and 1", got.CategoryCount, got.UnusedCount)
+	}
+
+	byName := make(map[string]CategoryTotals)
+	for _, totals := range got.Categories {
+		byName[totals.Name] = totals
+	}
+	groceries := byName["Groceries"]
+	assertFloatClose(t, "groceries spending", groceries.TotalSpending, 380, 0.001)
+	if groceries.TransactionCount != 2 || !strings.HasPrefix(groceries.LastUsed, "2024-02-12") {
+		t.Fatalf("groceries = %+v, want 2 transactions last used 2024-02-12", groceries)
+	}
+	assertFloatClose(t, "salary income", byName["Salary"].TotalIncome, 5500, 0.001)
+	if hobbies := byName["Hobbies"]; hobbies.TransactionCount != 0 || hobbies.TotalSpending != 0 || hobbies.LastUsed != "" {
+		t.Fatalf("unused category = %+v, want zero totals", hobbies)
+	}
+}
+
+func TestSpendingTrendCategoryDeltasWithFixtureDB(t *testing.T) {
+	db := newFixtureDBWithExtraRows(t, func(conn *sql.DB) {
+		insertTransaction(t, conn, 2000, 37, -450, "2024-02-20", "Groceries", 1, 0, 102)
+		insertTransaction(t, conn, 2001, 37, -1300, "2024-02-01", "Rent payment", 1, 0, 101)
+		insertTransaction(t, conn, 2002, 37, -100, "2024-04-02", "Rent adjustment", 1, 0, 101)
+	})
+	defer db.Close()
+
+	trends, err := db.AnalyzeSpendingTrends(context.Background(), SpendingTrendOptions{GroupBy: "month"})
+	if err != nil {
+		t.Fatalf("AnalyzeSpendingTrends: %v", err)
+	}
+	if len(trends) != 3 {
+		t.Fatalf("trends len = %d, want 3", len(trends))
+	}
+	if trends[0].CategoryDeltas != nil {
+		t.Fatalf("first period deltas = %+v, want none", trends[0].CategoryDeltas)
+	}
+
+	deltas := make(map[string]CategoryDelta)
+	for _, delta := range trends[1].CategoryDeltas {
+		deltas[delta.CategoryName] = delta
+	}
+	rent := deltas["Rent"]
+	assertFloatClose(t, "rent delta", rent.Delta, 100, 0.001)
+	assertFloatClose(t, "rent change percent", *rent.ChangePercent, 100.0/12, 0.001)
+	groceries := deltas["Groceries"]
+	if groceries.Base != 0 || groceries.ChangePercent != nil {
+		t.Fatalf("new category delta = %+v, want no base and no percentage", groceries)
+	}
+
+	// March had no spending, so April is compared against an empty month
+	april := trends[2].CategoryDeltas
+	if len(april) != 1 || april[0].CategoryName != "Rent" || april[0].Base != 0 || april[0].Delta != 100 {
+		t.Fatalf("april deltas = %+v, want rent compared with an empty march", april)
+	}
+
+	growing := FastestGrowingCategories(trends[:2], 5)
+	if len(growing) != 2 || growing[0].CategoryName != "Groceries" || growing[1].CategoryName != "Rent" {
+		t.Fatalf("fastest growing = %+v, want [Groceries Rent]", growing)
+	}
+}
+
+func TestGetFinancialStatsWithFixtureDB(t *testing.T) {
+	db := newFixtureDB(t)
+	defer db.Close()
+
+	got, err := db.GetFinancialStats(context.Background(), false)
+	if err != nil {
+		t.Fatalf("GetFinancialStats: %v", err)
+	}
+
+	if got.TotalTransactions != 4 {
+		t.Fatalf("total transactions = %d, want 4", got.TotalTransactions)
+	}
+	if got.IncomeTransactions != 2 {
+		t.Fatalf("income transactions = %d, want 2", got.IncomeTransactions)
+	}
+	if got.ExpenseTransactions != 2 {
+		t.Fatalf("expense transactions = %d, want 2", got.ExpenseTransactions)
+	}
+	if got.AccountCount != 1 {
+		t.Fatalf("account count = %d, want 1", got.AccountCount)
+	}
+	if got.CategoryCount != 3 {
+		t.Fatalf("category count = %d, want 3", got.CategoryCount)
+	}
+
+	assertFloatClose(t, "total income", got.TotalIncome, 5500, 0.001)
+	assertFloatClose(t, "total spending", got.TotalSpending, 1500, 0.001)
+	assertFloatClose(t, "net savings", got.NetSavings, 4000, 0.001)
+	assertFloatClose(t, "average transaction", got.AverageTransaction, 1750, 0.001)
+	assertFloatClose(t, "largest income", got.LargestIncome, 3000, 0.001)
+	assertFloatClose(t, "largest expense", got.LargestExpense, 1200, 0.001)
+	if got.MixedCurrencies {
+		t.Fatal("mixed currencies = true, want false")
+	}
+	if got.PrimaryCurrency != "USD" {
+		t.Fatalf("primary currency = %q, want %q", got.PrimaryCurrency, "USD")
+	}
+	if len(got.Currencies) != 1 || got.Currencies[0] != "USD" {
+		t.Fatalf("currencies = %#v, want [USD]", got.Currencies)
+	}
+	usd, ok := got.ByCurrency["USD"]
+	if !ok {
+		t.Fatal("missing USD by_currency stats")
+	}
+	assertFloatClose(t, "usd average transaction", usd.AverageTransaction, 1750, 0.001)
+	assertFloatClose(t, "usd total income", usd.TotalIncome, 5500, 0.001)
+	assertFloatClose(t, "usd total spending", usd.TotalSpending, 1500, 0.001)
+
+	if got.FirstTransactionDate != "2024-01-15 00:00:00" {
+		t.Fatalf("first transaction date = %q", got.FirstTransactionDate)
+	}
+	if got.LastTransactionDate != "2024-02-10 00:00:00" {
+		t.Fatalf("last transaction date = %q", got.LastTransactionDate)
+	}
+	if got.DateRange != "2024-01-15 00:00:00 to 2024-02-10 00:00:00" {
+		t.Fatalf("date range = %q", got.DateRange)
+	}
+
+	year2024, ok := got.ByYear["2024"]
+	if !ok {
+		t.Fatalf("missing by_year entry for 2024")
+	}
+	assertFloatClose(t, "2024 income", year2024.Income, 5500, 0.001)
+	assertFloatClose(t, "2024 spending", year2024.Spending, 1500, 0.001)
+	assertFloatClose(t, "2024 net savings", year2024.NetSavings, 4000, 0.001)
+	if year2024.TransactionCount != 4 {
+		t.Fatalf("2024 transaction count = %d, want 4", year2024.TransactionCount)
+	}
+}
+
+func TestGetFinancialStatsReportsMedianAndOutliersWithFixtureDB(t *testing.T) {
+	db := newFixtureDBWithExtraRows(t, func(conn *sql.DB) {
+		insertUncategorizedTransaction(t, conn, 2000, 37, -250000, "2024-02-12", "House purchase", 1, 0)
+	})
+	defer db.Close()
+
+	stats, err := db.GetFinancialStats(context.Background(), false)
+	if err != nil {
+		t.Fatalf("GetFinancialStats: %v", err)
+	}
+	// Amounts 300, 1200, 2500, 3000, 250000: the house drags the average far above the median
+	assertFloatClose(t, "average", stats.AverageTransaction, 257000.0/5, 0.001)
+	assertFloatClose(t, "median", stats.MedianTransaction, 2500, 0.001)
+	assertFloatClose(t, "outlier threshold", stats.OutlierThreshold, 3000+3*1800, 0.001)
+	if stats.OutlierCount != 1 {
+		t.Fatalf("outlier count = %d, want only the house purchase", stats.OutlierCount)
+	}
+	assertFloatClose(t, "average without outliers", stats.AverageWithoutOutliers, 7000.0/4, 0.001)
+}
+
+func TestFinancialStatsMatchTransactionDataWithFixtureDB(t *testing.T) {
+	db := newFixtureDBWithExtraRows(t, func(conn *sql.DB) {
+		mustExecSQL(t, conn, `
+			ALTER TABLE ZCATEGORYASSIGMENT ADD COLUMN ZAMOUNT REAL;
+			INSERT INTO ZSYNCOBJECT (Z_PK, Z_ENT, ZNAME, ZBALLANCE, ZOPENINGBALANCE, ZCURRENCYNAME, ZTYPE)
+			VALUES (2, 10, 'EUR Checking', 0, 0, 'EUR', 'bank');
+		`)
+		insertUncategorizedTransaction(t, conn, 2000, 37, -150, "2024-03-12", "Store run", 1, 0)
+		mustExecSQL(t, conn, `INSERT INTO ZCATEGORYASSIGMENT (ZTRANSACTION, ZCATEGORY, ZAMOUNT) VALUES (2000, 102, -100), (2000, 101, -50)`)
+		insertUncategorizedTransaction(t, conn, 2001, 37, -200, "2024-03-15", "  TRANSFER to Savings ", 1, 0)
+		insertUncategorizedTransaction(t, conn, 2002, 43, 200, "2024-03-15", "Transfer from Checking", 2, 0)
+		insertTransaction(t, conn, 2003, 37, -12, "2024-04-01", "Wire transfer fee", 1, 0, 102)
+		insertTransaction(t, conn, 2004, 37, -8.5, "2024-04-02", "Café Central", 2, 0, 102)
+		insertUncategorizedTransaction(t, conn, 2005, 46, -60, "2024-04-03", "СНЯТИЕ НАЛИЧНЫХ В БАНКОМАТE", 2, 0)
+		insertTransaction(t, conn, 2006, 37, 900, "2024-05-01", "Freelance EU", 2, 0, 100)
+		// Just before midnight, so the month must come from the truncated date
+		mustExecSQL(t, conn, `UPDATE ZSYNCOBJECT SET ZDATE1 = ZDATE1 - 0.25 WHERE Z_PK = 2006`)
+	})
+	defer db.Close()
+	db.fiscalYearStart = time.April
+	ctx := context.Background()
+
+	for _, includeTransfers := range []bool{false, true} {
+		income, err := db.GetIncomeData(ctx, 0, includeTransfers, false)
+		if err != nil {
+			t.Fatalf("GetIncomeData: %v", err)
+		}
+		spending, err := db.GetSpendingData(ctx, 0, includeTransfers, nil, false)
+		if err != nil {
+			t.Fatalf("GetSpendingData: %v", err)
+		}
+		stats, err := db.GetFinancialStats(ctx, includeTransfers)
+		if err != nil {
+			t.Fatalf("GetFinancialStats: %v", err)
+		}
+
+		// The cash withdrawal is left out whether or not transfers are included
+		for _, s := range spending {
+			if s.TransactionID == 2005 {
+				t.Fatalf("transfers %v: cash withdrawal counted as spending", includeTransfers)
+			}
+		}
+
+		// The same totals, built from the rows the data queries return
+		var totalIncome, totalSpending float64
+		var amounts []float64
+		var firstDate, lastDate string
+		byYear := make(map[string]float64)
+		byCurrency := make(map[string]int)
+		for _, i := range income {
+			totalIncome += i.Amount
+			amounts = append(amounts, i.Amount)
+			byYear[i.Year] += i.Amount
+			byCurrency[i.Currency]++
+			if firstDate == "" || i.Date < firstDate {
+				firstDate = i.Date
+			}
+			if i.Date > lastDate {
+				lastDate = i.Date
+			}
+		}
+		for _, s := range spending {
+			totalSpending += s.Amount
+			amounts = append(amounts, s.Amount)
+			byYear[s.Year] -= s.Amount
+			byCurrency[s.Currency]++
+			if firstDate == "" || s.Date < firstDate {
+				firstDate = s.Date
+			}
+			if s.Date > lastDate {
+				lastDate = s.Date
+			}
+		}
+		outliers := findOutliers(amounts)
+
+		if stats.IncomeTransactions != len(income) || stats.ExpenseTransactions != len(spending) {
+			t.Fatalf("transfers %v: counts = %d income, %d expenses, want %d and %d", includeTransfers, stats.IncomeTransactions, stats.ExpenseTransactions, len(income), len(spending))
+		}
+		assertFloatClose(t, "total income", stats.TotalIncome, totalIncome, 0.001)
+		assertFloatClose(t, "total spending", stats.TotalSpending, totalSpending, 0.001)
+		assertFloatClose(t, "median", stats.MedianTransaction, outliers.median, 0.001)
+		assertFloatClose(t, "outlier threshold", stats.OutlierThreshold, outliers.threshold, 0.001)
+		assertFloatClose(t, "average without outliers", stats.AverageWithoutOutliers, outliers.averageWithout, 0.001)
+		if stats.OutlierCount != outliers.count {
+			t.Fatalf("transfers %v: outlier count = %d, want %d", includeTransfers, stats.OutlierCount, outliers.count)
+		}
+		if stats.FirstTransactionDate != firstDate || stats.LastTransactionDate != lastDate {
+			t.Fatalf("transfers %v: dates = %s to %s, want %s to %s", includeTransfers, stats.FirstTransactionDate, stats.LastTransactionDate, firstDate, lastDate)
+		}
+		if len(stats.ByYear) != len(byYear) {
+			t.Fatalf("transfers %v: by_year = %+v, want %v", includeTransfers, stats.ByYear, byYear)
+		}
+		for year, net := range byYear {
+			assertFloatClose(t, year+" net savings", stats.ByYear[year].NetSavings, net, 0.001)
+		}
+		for currency, count := range byCurrency {
+			if stats.ByCurrency[currency].TotalTransactions != count {
+				t.Fatalf("transfers %v: %s transactions = %d, want %d", includeTransfers, currency, stats.ByCurrency[currency].TotalTransactions, count)
+			}
+		}
+	}
+}
+
+func TestFinancialStatsPrimaryCurrencyWithFixtureDB(t *testing.T) {
+	db := newFixtureDBWithExtraRows(t, func(conn *sql.DB) {
+		mustExecSQL(t, conn, `
+			INSERT INTO ZSYNCOBJECT (Z_PK, Z_ENT, ZNAME, ZBALLANCE, ZOPENINGBALANCE, ZCURRENCYNAME, ZTYPE)
+			VALUES
+				(2, 10, 'EUR Checking', 0, 0, 'EUR', 'bank'),
+				(3, 12, 'USD Savings', 0, 0, 'USD', 'savings');
+		`)
+		insertTransaction(t, conn, 2000, 37, 800, "2024-02-15", "Freelance EU", 2, 0, 100)
+		insertTransaction(t, conn, 2001, 37, -200, "2024-02-16", "Groceries EU", 2, 0, 102)
+	})
+	defer db.Close()
+
+	stats, err := db.GetFinancialStats(context.Background(), false)
+	if err != nil {
+		t.Fatalf("GetFinancialStats: %v", err)
+	}
+	if stats.PrimaryCurrency != "USD" {
+		t.Fatalf("primary currency = %q, want USD", stats.PrimaryCurrency)
+	}
+	eur := stats.ByCurrency["EUR"]
+	assertFloatClose(t, "eur income", eur.TotalIncome, 800, 0.001)
+	assertFloatClose(t, "eur spending", eur.TotalSpending, 200, 0.001)
+	assertFloatClose(t, "eur net savings", eur.NetSavings, 600, 0.001)
+	assertFloatClose(t, "usd net savings", stats.ByCurrency["USD"].NetSavings, 4000, 0.001)
+
+	if got := primaryAccountCurrency([]Account{{Currency: "USD"}, {Currency: "EUR"}, {}}); got != "EUR" {
+		t.Fatalf("tied primary currency = %q, want EUR", got)
+	}
+}
+
+func TestGetInfoWithFixtureDB(t *testing.T) {
+	db := newFixtureDB(t)
+	defer db.Close()
+
+	got, err := db.GetInfo(context.Background())
+	if err != nil {
+		t.Fatalf("GetInfo: %v", err)
+	}
+	if got.BaseCurrency != "USD" || got.BaseCurrencySource != BaseCurrencySourceAccounts {
+		t.Fatalf("base currency = %q from %q, want USD from accounts", got.BaseCurrency, got.BaseCurrencySource)
+	}
+	if got.AccountCount != 1 || got.TransactionCount != 4 {
+		t.Fatalf("counts = %d accounts, %d transactions, want 1 and 4", got.AccountCount, got.TransactionCount)
+	}
+	if got.FirstTransactionDate != "2024-01-15" || got.LastTransactionDate != "2024-02-10" {
+		t.Fatalf("transaction range = %s to %s, want 2024-01-15 to 2024-02-10", got.FirstTransactionDate, got.LastTransactionDate)
+	}
+}
+
+func TestSettingsBaseCurrencyWithFixtureDB(t *testing.T) {
+	db := newFixtureDBWithExtraRows(t, func(conn *sql.DB) {
+		mustExecSQL(t, conn, `
+			CREATE TABLE Z_PRIMARYKEY (Z_ENT INTEGER, Z_NAME TEXT, Z_SUPER INTEGER, Z_MAX INTEGER);
+			INSERT INTO Z_PRIMARYKEY (Z_ENT, Z_NAME) VALUES (10, 'BankChequeAccount'), (70, 'AppSettings');
+			ALTER TABLE ZSYNCOBJECT ADD COLUMN ZBASECURRENCY TEXT;
+			INSERT INTO ZSYNCOBJECT (Z_PK, Z_ENT, ZBASECURRENCY) VALUES (500, 70, ' eur ');
+		`)
+	})
+	defer db.Close()
+	ctx := context.Background()
+
+	currency, err := db.SettingsBaseCurrency(ctx)
+	if err != nil {
+		t.Fatalf("SettingsBaseCurrency: %v", err)
+	}
+	if currency != "EUR" {
+		t.Fatalf("settings base currency = %q, want EUR", currency)
+	}
+
+	info, err := db.GetInfo(ctx)
+	if err != nil {
+		t.Fatalf("GetInfo: %v", err)
+	}
+	if info.BaseCurrency != "EUR" || info.BaseCurrencySource != BaseCurrencySourceSettings {
+		t.Fatalf("base currency = %q from %q, want EUR from settings", info.BaseCurrency, info.BaseCurrencySource)
+	}
+
+	stats, err := db.GetFinancialStats(ctx, false)
+	if err != nil {
+		t.Fatalf("GetFinancialStats: %v", err)
+	}
+	if stats.PrimaryCurrency != "EUR" {
+		t.Fatalf("primary currency = %q, want the EUR base currency", stats.PrimaryCurrency)
+	}
+}
+
+func TestFinancialStatsReturnsPartialResultsWithFixtureDB(t *testing.T) {
+	db := newFixtureDBWithExtraRows(t, func(conn *sql.DB) {
+		// An unreadable entity table breaks the account group lookup, and with it the accounts
+		mustExecSQL(t, conn, `
+			CREATE TABLE Z_PRIMARYKEY (Z_ENT TEXT, Z_NAME TEXT, Z_SUPER INTEGER, Z_MAX INTEGER);
+			INSERT INTO Z_PRIMARYKEY (Z_ENT, Z_NAME) VALUES ('broken', 'AccountGroup');
+		`)
+	})
+	defer db.Close()
+
+	if _, err := db.GetAccounts(context.Background()); err == nil {
+		t.Fatal("GetAccounts unexpectedly succeeded with a broken entity table")
+	}
+
+	stats, err := db.GetFinancialStats(context.Background(), false)
+	if err != nil {
+		t.Fatalf("GetFinancialStats: %v", err)
+	}
+	if len(stats.Warnings) != 1 || !strings.Contains(stats.Warnings[0], "accounts") {
+		t.Fatalf("warnings = %v, want one naming the accounts", stats.Warnings)
+	}
+	if stats.AccountCount != 0 || stats.CategoryCount != 3 {
+		t.Fatalf("counts = %d accounts, %d categories, want 0 and 3", stats.AccountCount, stats.CategoryCount)
+	}
+	assertFloatClose(t, "total income", stats.TotalIncome, 5500, 0.001)
+	assertFloatClose(t, "total spending", stats.TotalSpending, 1500, 0.001)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := db.GetFinancialStats(ctx, false); !errors.Is(err, context.Canceled) {
+		t.Fatalf("canceled stats error = %v, want context.Canceled", err)
+	}
+}
+
+func TestMixedCurrencyStatsAndInternalMovementsWithFixtureDB(t *testing.T) {
+	db := newFixtureDBWithExtraRows(t, func(conn *sql.DB) {
+		mustExecSQL(t, conn, `
+			INSERT INTO ZSYNCOBJECT (Z_PK, Z_ENT, ZNAME, ZBALLANCE, ZOPENINGBALANCE, ZCURRENCYNAME, ZTYPE)
+			VALUES (2, 10, 'EUR Checking', 0, 500, 'EUR', 'bank');
+		`)
+
+		insertTransaction(t, conn, 2000, 37, 2000, "2024-02-15", "Consulting invoice", 2, 0, 100)
+		insertTransaction(t, conn, 2001, 37, -500, "2024-02-16", "Groceries EU", 2, 0, 102)
+		insertUncategorizedTransaction(t, conn, 2002, 43, -100, "2024-02-17", "Transfer to Cash", 1, 0)
+		insertUncategorizedTransaction(t, conn, 2003, 43, 100, "2024-02-17", "Transfer from Checking", 2, 0)
+		insertUncategorizedTransaction(t, conn, 2004, 46, -50, "2024-02-18", "ATM Withdrawal", 1, 0)
+		insertUncategorizedTransaction(t, conn, 2005, 46, 50, "2024-02-18", "ATM Withdrawal", 2, 0)
+	})
+	defer db.Close()
+
+	savings, err := db.AnalyzeSavings(context.Background(), SavingsOptions{TopCategories: 5})
+	if err != nil {
+		t.Fatalf("AnalyzeSavings: %v", err)
+	}
+	if !savings.MixedCurrencies {
+		t.Fatal("mixed currencies = false, want true")
+	}
+	if savings.CurrencyWarning == "" {
+		t.Fatal("currency warning is empty")
+	}
+	if savings.PrimaryCurrency != "" {
+		t.Fatalf("primary currency = %q, want empty", savings.PrimaryCurrency)
+	}
+	if len(savings.Currencies) != 2 || savings.Currencies[0] != "EUR" || savings.Currencies[1] != "USD" {
+		t.Fatalf("currencies = %#v, want [EUR USD]", savings.Currencies)
+	}
+	assertFloatClose(t, "mixed total income", savings.TotalIncome, 7500, 0.001)
+	assertFloatClose(t, "mixed total spending", savings.TotalSpending, 2000, 0.001)
+	assertFloatClose(t, "usd income", savings.ByCurrency["USD"].TotalIncome, 5500, 0.001)
+	assertFloatClose(t, "usd spending", savings.ByCurrency["USD"].TotalSpending, 1500, 0.001)
+	assertFloatClose(t, "eur income", savings.ByCurrency["EUR"].TotalIncome, 2000, 0.001)
+	assertFloatClose(t, "eur spending", savings.ByCurrency["EUR"].TotalSpending, 500, 0.001)
+
+	stats, err := db.GetFinancialStats(context.Background(), false)
+	if err != nil {
+		t.Fatalf("GetFinancialStats: %v", err)
+	}
+	if !stats.MixedCurrencies {
+		t.Fatal("mixed currencies = false, want true")
+	}
+	if stats.CurrencyWarning == "" {
+		t.Fatal("currency warning is empty")
+	}
+	if stats.TotalTransactions != 6 {
+		t.Fatalf("total transactions = %d, want 6", stats.TotalTransactions)
+	}
+	assertFloatClose(t, "stats total income", stats.TotalIncome, 7500, 0.001)
+	assertFloatClose(t, "stats total spending", stats.TotalSpending, 2000, 0.001)
+	assertFloatClose(t, "stats eur income", stats.ByCurrency["EUR"].TotalIncome, 2000, 0.001)
+	assertFloatClose(t, "stats eur spending", stats.ByCurrency["EUR"].TotalSpending, 500, 0.001)
+
+	transactions, err := db.GetTransactions(context.Background(), TransactionFilter{Limit: 10})
+	if err != nil {
+		t.Fatalf("GetTransactions: %v", err)
+	}
+	foundTransfer := false
+	foundATM := false
+	for _, txn := range transactions {
+		if txn.Description == "Transfer to Cash" {
+			foundTransfer = true
+			if txn.MovementType != movementTypeTransfer {
+				t.Fatalf("transfer movement type = %q, want %q", txn.MovementType, movementTypeTransfer)
+			}
+			if txn.CategoryName != "Internal Transfer" {
+				t.Fatalf("transfer category = %q, want %q", txn.CategoryName, "Internal Transfer")
+			}
+		}
+		if txn.Description == "ATM Withdrawal" && txn.Amount < 0 {
+			foundATM = true
+			if txn.MovementType != movementTypeCashWithdrawal {
+				t.Fatalf("atm movement type = %q, want %q", txn.MovementType, movementTypeCashWithdrawal)
+			}
+			if txn.CategoryName != "Cash Withdrawal" {
+				t.Fatalf("atm category = %q, want %q", txn.CategoryName, "Cash Withdrawal")
+			}
+		}
+	}
+	if !foundTransfer {
+		t.Fatal("did not find transfer transaction")
+	}
+	if !foundATM {
+		t.Fatal("did not find atm withdrawal transaction")
+	}
+}
+
+func TestGetCategoryTrendDirectionsWithFixtureDB(t *testing.T) {
+	db := newFixtureDBWithExtraRows(t, func(conn *sql.DB) {
+		insertTransaction(t, conn, 2000, 37, -100, "2024-03-05", "Groceries", 1, 0, 102)
+		insertTransaction(t, conn, 2001, 37, -900, "2024-04-01", "Rent payment", 1, 0, 101)
+	})
+	defer db.Close()
+
+	got, err := db.GetCategoryTrendDirections(context.Background(), 0)
+	if err != nil {
+		t.Fatalf("GetCategoryTrendDirections: %v", err)
+	}
+
+	if got.FirstPeriod != "2024-01 to 2024-02" {
+		t.Fatalf("first period = %q, want %q", got.FirstPeriod, "2024-01 to 2024-02")
+	}
+	if got.SecondPeriod != "2024-03 to 2024-04" {
+		t.Fatalf("second period = %q, want %q", got.SecondPeriod, "2024-03 to 2024-04")
+	}
+
+	if len(got.MostImproved) != 2 {
+		t.Fatalf("most improved len = %d, want 2", len(got.MostImproved))
+	}
+	rent := got.MostImproved[0]
+	if rent.CategoryName != "Rent" {
+		t.Fatalf("most improved[0] = %q, want %q", rent.CategoryName, "Rent")
+	}
+	assertFloatClose(t, "rent first half average", rent.FirstHalfAverage, 600, 0.001)
+	assertFloatClose(t, "rent second half average", rent.SecondHalfAverage, 450, 0.001)
+	assertFloatClose(t, "rent change", rent.Change, -150, 0.001)
+	assertFloatClose(t, "rent change percent", rent.ChangePercent, -25, 0.001)
+
+	groceries := got.MostImproved[1]
+	if groceries.CategoryName != "Groceries" {
+		t.Fatalf("most improved[1] = %q, want %q", groceries.CategoryName, "Groceries")
+	}
+	assertFloatClose(t, "groceries change", groceries.Change, -100, 0.001)
+	if len(got.MostWorsened) != 0 {
+		t.Fatalf("most worsened len = %d, want 0", len(got.MostWorsened))
+	}
+}
+
+func TestDetectPossibleDoubleChargesWithFixtureDB(t *testing.T) {
+	db := newFixtureDBWithExtraRows(t, func(conn *sql.DB) {
+		insertTransaction(t, conn, 2000, 37, -25, "2024-02-11", "COFFEE ROASTERS #12", 1, 0, 102)
+		insertTransaction(t, conn, 2001, 37, -25.5, "2024-02-12", "Coffee Roasters 13", 1, 0, 102)
+		insertTransaction(t, conn, 2002, 37, -25, "2024-02-20", "Coffee Roasters", 1, 0, 102)
+		insertTransaction(t, conn, 2003, 37, -60, "2024-02-12", "Coffee Roasters", 1, 0, 102)
+	})
+	defer db.Close()
+
+	got, err := db.DetectPossibleDoubleCharges(context.Background(), 0, 48, 5)
+	if err != nil {
+		t.Fatalf("DetectPossibleDoubleCharges: %v", err)
+	}
+
+	if got.CandidateCount != 1 || len(got.Candidates) != 1 {
+		t.Fatalf("candidates = %d (%+v), want 1", got.CandidateCount, got.Candidates)
+	}
+	candidate := got.Candidates[0]
+	if candidate.Payee != "coffee roasters" {
+		t.Fatalf("payee = %q, want %q", candidate.Payee, "coffee roasters")
+	}
+	if candidate.First.TransactionID != 2000 || candidate.Second.TransactionID != 2001 {
+		t.Fatalf("candidate pair = [%d %d], want [2000 2001]", candidate.First.TransactionID, candidate.Second.TransactionID)
+	}
+	assertFloatClose(t, "hours apart", candidate.HoursApart, 24, 0.001)
+	assertFloatClose(t, "amount difference", candidate.AmountDifference, 0.5, 0.001)
+	assertFloatClose(t, "amount difference percent", candidate.AmountDifferencePercent, 1.9607843, 0.001)
+
+	wide, err := db.DetectPossibleDoubleCharges(context.Background(), 0, 24*10, 5)
+	if err != nil {
+		t.Fatalf("DetectPossibleDoubleCharges wide window: %v", err)
+	}
+	if wide.CandidateCount != 3 {
+		t.Fatalf("wide window candidates = %d, want 3", wide.CandidateCount)
+	}
+}
+
+func TestCalculateNetWorthSeriesByCurrencyWithFixtureDB(t *testing.T) {
+	db := newFixtureDBWithExtraRows(t, func(conn *sql.DB) {
+		mustExecSQL(t, conn, `
+			INSERT INTO ZSYNCOBJECT (Z_PK, Z_ENT, ZNAME, ZBALLANCE, ZOPENINGBALANCE, ZCURRENCYNAME, ZTYPE)
+			VALUES (2, 10, 'EUR Checking', 0, 500, 'EUR', 'bank');
+		`)
+		insertTransaction(t, conn, 2000, 37, 2000, "2024-02-15", "Consulting invoice", 2, 0, 100)
+	})
+	defer db.Close()
+
+	got, err := db.CalculateNetWorthSeries(context.Background(), 3, true)
+	if err != nil {
+		t.Fatalf("CalculateNetWorthSeries: %v", err)
+	}
+
+	if len(got.Points) != 3 {
+		t.Fatalf("points len = %d, want 3", len(got.Points))
+	}
+	if got.Points[0].Month != "2023-12" || got.Points[1].Month != "2024-01" || got.Points[2].Month != "2024-02" {
+		t.Fatalf("point months = [%s %s %s]", got.Points[0].Month, got.Points[1].Month, got.Points[2].Month)
+	}
+	if !got.MixedCurrencies {
+		t.Fatal("mixed currencies = false, want true")
+	}
+
+	assertFloatClose(t, "dec net worth", got.Points[0].NetWorth, 1500, 0.001)
+	assertFloatClose(t, "dec usd", got.Points[0].ByCurrency["USD"], 1000, 0.001)
+	assertFloatClose(t, "dec eur", got.Points[0].ByCurrency["EUR"], 500, 0.001)
+	assertFloatClose(t, "jan net worth", got.Points[1].NetWorth, 3300, 0.001)
+	assertFloatClose(t, "jan usd", got.Points[1].ByCurrency["USD"], 2800, 0.001)
+	assertFloatClose(t, "jan eur", got.Points[1].ByCurrency["EUR"], 500, 0.001)
+	assertFloatClose(t, "feb net worth", got.Points[2].NetWorth, 7500, 0.001)
+	assertFloatClose(t, "feb usd", got.Points[2].ByCurrency["USD"], 5000, 0.001)
+	assertFloatClose(t, "feb eur", got.Points[2].ByCurrency["EUR"], 2500, 0.001)
+	assertFloatClose(t, "feb assets", got.Points[2].TotalAssets, 7500, 0.001)
+	assertFloatClose(t, "feb liabilities", got.Points[2].TotalLiabilities, 0, 0.001)
+
+	totalsOnly, err := db.CalculateNetWorthSeries(context.Background(), 3, false)
+	if err != nil {
+		t.Fatalf("CalculateNetWorthSeries without currency split: %v", err)
+	}
+	if totalsOnly.Points[2].ByCurrency != nil {
+		t.Fatalf("by_currency = %#v, want nil when not requested", totalsOnly.Points[2].ByCurrency)
+	}
+}
+
+func TestAttributeNetWorthChangeWithFixtureDB(t *testing.T) {
+	db := newFixtureDBWithExtraRows(t, func(conn *sql.DB) {
+		mustExecSQL(t, conn, `
+			INSERT INTO ZSYNCOBJECT (Z_PK, Z_ENT, ZNAME, ZBALLANCE, ZOPENINGBALANCE, ZCURRENCYNAME, ZTYPE)
+			VALUES (2, 15, 'Brokerage', 6300, 5000, 'USD', 'investment');
+		`)
+		insertUncategorizedTransaction(t, conn, 2000, 43, -1000, "2024-02-20", "Transfer to Brokerage", 1, 2)
+		insertUncategorizedTransaction(t, conn, 2001, 43, 1000, "2024-02-20", "Transfer from Checking", 2, 1)
+		insertUncategorizedTransaction(t, conn, 2002, 37, 50, "2024-02-25", "Dividend", 2, 0)
+	})
+	defer db.Close()
+
+	got, err := db.AttributeNetWorthChange(context.Background(), "2024-02-01", "2024-02-29")
+	if err != nil {
+		t.Fatalf("AttributeNetWorthChange: %v", err)
+	}
+
+	assertFloatClose(t, "start net worth", got.StartNetWorth, 7800, 0.001)
+	assertFloatClose(t, "end net worth", got.EndNetWorth, 10050, 0.001)
+	assertFloatClose(t, "change", got.Change, 2250, 0.001)
+	assertFloatClose(t, "income", got.Income, 2500, 0.001)
+	assertFloatClose(t, "spending", got.Spending, 300, 0.001)
+	assertFloatClose(t, "savings", got.Savings, 2200, 0.001)
+	assertFloatClose(t, "investment returns", got.InvestmentReturns, 50, 0.001)
+	assertFloatClose(t, "net transfers", got.NetTransfers, 0, 0.001)
+	assertFloatClose(t, "investment contributions", got.InvestmentContributions, 1000, 0.001)
+	assertFloatClose(t, "usd change", got.ByCurrency["USD"].Change, 2250, 0.001)
+	if got.CurrentValuationGap == nil {
+		t.Fatal("current valuation gap = nil, want the stored balance difference")
+	}
+	assertFloatClose(t, "current valuation gap", *got.CurrentValuationGap, 250, 0.001)
+	if len(got.InvestmentAccounts) != 1 || got.InvestmentAccounts[0] != "Brokerage" {
+		t.Fatalf("investment accounts = %v, want [Brokerage]", got.InvestmentAccounts)
+	}
+
+	january, err := db.AttributeNetWorthChange(context.Background(), "2024-01-01", "2024-01-31")
+	if err != nil {
+		t.Fatalf("AttributeNetWorthChange for January: %v", err)
+	}
+	assertFloatClose(t, "january start", january.StartNetWorth, 6000, 0.001)
+	assertFloatClose(t, "january end", january.EndNetWorth, 7800, 0.001)
+	assertFloatClose(t, "january savings", january.Savings, 1800, 0.001)
+
+	if _, err := db.AttributeNetWorthChange(context.Background(), "2024-03-01", "2024-02-01"); !errors.Is(err, ErrInvalidArgument) {
+		t.Fatalf("start after end error = %v, want ErrInvalidArgument", err)
+	}
+}
+
+func TestProjectInflationImpactWithFixtureDB(t *testing.T) {
+	db := newFixtureDB(t)
+	defer db.Close()
+
+	got, err := db.ProjectInflationImpact(context.Background(), 10, 2, 0)
+	if err != nil {
+		t.Fatalf("ProjectInflationImpact: %v", err)
+	}
+
+	if got.BaselineMonths != 2 {
+		t.Fatalf("baseline months = %d, want 2", got.BaselineMonths)
+	}
+	assertFloatClose(t, "multiplier", got.Multiplier, 1.21, 0.0001)
+	assertFloatClose(t, "current annual total", got.CurrentAnnualTotal, 9000, 0.001)
+	assertFloatClose(t, "projected annual total", got.ProjectedAnnualTotal, 10890, 0.001)
+	assertFloatClose(t, "total increase", got.TotalIncrease, 1890, 0.001)
+
+	if len(got.ByCategory) != 2 {
+		t.Fatalf("by category len = %d, want 2", len(got.ByCategory))
+	}
+	if got.ByCategory[0].CategoryName != "Rent" {
+		t.Fatalf("by category[0] = %q, want %q", got.ByCategory[0].CategoryName, "Rent")
+	}
+	assertFloatClose(t, "rent current", got.ByCategory[0].CurrentAnnualSpending, 7200, 0.001)
+	assertFloatClose(t, "rent projected", got.ByCategory[0].ProjectedAnnualSpending, 8712, 0.001)
+
+	// A window longer than the history annualizes over the months that have spending
+	windowed, err := db.ProjectInflationImpact(context.Background(), 10, 2, 12)
+	if err != nil {
+		t.Fatalf("ProjectInflationImpact over 12 months: %v", err)
+	}
+	if windowed.BaselineMonths != 2 {
+		t.Fatalf("12-month baseline months = %d, want 2", windowed.BaselineMonths)
+	}
+	assertFloatClose(t, "12-month current annual total", windowed.CurrentAnnualTotal, 9000, 0.001)
+
+	if _, err := db.ProjectInflationImpact(context.Background(), 3, -1, 0); err == nil {
+		t.Fatal("ProjectInflationImpact with negative years unexpectedly succeeded")
+	}
+	if _, err := db.ProjectInflationImpact(context.Background(), 3, 10, -1); err == nil {
+		t.Fatal("ProjectInflationImpact with negative months unexpectedly succeeded")
+	}
+}
+
+func TestCalculateNetWorthByInstitutionWithFixtureDB(t *testing.T) {
+	db := newFixtureDB(t)
+	defer db.Close()
+
+	got, err := db.CalculateNetWorthByInstitution(context.Background())
+	if err != nil {
+		t.Fatalf("CalculateNetWorthByInstitution without institution column: %v", err)
+	}
+	if got.InstitutionColumn != "" || got.Note == "" {
+		t.Fatalf("institution column = %q, note = %q; want no column and a note", got.InstitutionColumn, got.Note)
+	}
+	if len(got.Institutions) != 1 || got.Institutions[0].Institution != "Unknown" {
+		t.Fatalf("institutions = %#v, want a single Unknown group", got.Institutions)
+	}
+
+	db = newFixtureDBWithExtraRows(t, func(conn *sql.DB) {
+		mustExecSQL(t, conn, `
+			ALTER TABLE ZSYNCOBJECT ADD COLUMN ZBANKNAME TEXT;
+			UPDATE ZSYNCOBJECT SET ZBANKNAME = ' First Bank ' WHERE Z_PK = 1;
+			INSERT INTO ZSYNCOBJECT (Z_PK, Z_ENT, ZNAME, ZBALLANCE, ZOPENINGBALANCE, ZCURRENCYNAME, ZTYPE, ZBANKNAME)
+			VALUES (2, 10, 'Savings', 0, 800, 'USD', 'bank', 'First Bank');
+			INSERT INTO ZSYNCOBJECT (Z_PK, Z_ENT, ZNAME, ZBALLANCE, ZOPENINGBALANCE, ZCURRENCYNAME, ZTYPE)
+			VALUES (3, 10, 'Cash', 0, 50, 'EUR', 'cash');
+		`)
+	})
+	defer db.Close()
+
+	got, err = db.CalculateNetWorthByInstitution(context.Background())
+	if err != nil {
+		t.Fatalf("CalculateNetWorthByInstitution: %v", err)
+	}
+	if got.InstitutionColumn != "ZBANKNAME" {
+		t.Fatalf("institution column = %q, want ZBANKNAME", got.InstitutionColumn)
+	}
+	if len(got.Institutions) != 2 {
+		t.Fatalf("institutions len = %d, want 2", len(got.Institutions))
+	}
+
+	bank := got.Institutions[0]
+	if bank.Institution != "First Bank" || bank.AccountCount != 2 {
+		t.Fatalf("institutions[0] = %q with %d accounts, want First Bank with 2", bank.Institution, bank.AccountCount)
+	}
+	assertFloatClose(t, "first bank net worth", bank.NetWorth, 5800, 0.001)
+	assertFloatClose(t, "first bank usd", bank.ByCurrency["USD"], 5800, 0.001)
+
+	unknown := got.Institutions[1]
+	if unknown.Institution != "Unknown" || unknown.AccountCount != 1 {
+		t.Fatalf("institutions[1] = %q with %d accounts, want Unknown with 1", unknown.Institution, unknown.AccountCount)
+	}
+	assertFloatClose(t, "unknown eur", unknown.ByCurrency["EUR"], 50, 0.001)
+}
+
+func TestProjectAccountDepletionWithFixtureDB(t *testing.T) {
+	db := newFixtureDBWithExtraRows(t, func(conn *sql.DB) {
+		mustExecSQL(t, conn, `
+			INSERT INTO ZSYNCOBJECT (Z_PK, Z_ENT, ZNAME, ZBALLANCE, ZOPENINGBALANCE, ZCURRENCYNAME, ZTYPE)
+			VALUES (2, 10, 'Savings', 0, 1000, 'USD', 'bank');
+		`)
+		insertTransaction(t, conn, 2000, 37, -200, "2024-01-10", "Tuition", 2, 0, 102)
+		insertTransaction(t, conn, 2001, 37, -300, "2024-02-12", "Tuition", 2, 0, 102)
+	})
+	defer db.Close()
+
+	got, err := db.ProjectAccountDepletion(context.Background(), 2, 2, 0)
+	if err != nil {
+		t.Fatalf("ProjectAccountDepletion: %v", err)
+	}
+	if got.Status != "depleting" {
+		t.Fatalf("status = %q, want depleting", got.Status)
+	}
+	assertFloatClose(t, "current balance", got.CurrentBalance, 500, 0.001)
+	assertFloatClose(t, "average monthly net change", got.AverageMonthlyNetChange, -250, 0.001)
+	if got.MonthsUntilTarget == nil {
+		t.Fatal("months until target = nil, want 2")
+	}
+	assertFloatClose(t, "months until target", *got.MonthsUntilTarget, 2, 0.001)
+	if got.AsOf != "2024-02-12" || got.ProjectedDate != "2024-04-12" {
+		t.Fatalf("as of = %q, projected date = %q; want 2024-02-12 and 2024-04-12", got.AsOf, got.ProjectedDate)
+	}
+
+	growing, err := db.ProjectAccountDepletion(context.Background(), 1, 2, 0)
+	if err != nil {
+		t.Fatalf("ProjectAccountDepletion growing account: %v", err)
+	}
+	if growing.Status != "growing" || growing.MonthsUntilTarget != nil {
+		t.Fatalf("status = %q, months until target = %v; want growing with no projection", growing.Status, growing.MonthsUntilTarget)
+	}
+
+	if _, err := db.ProjectAccountDepletion(context.Background(), 999, 2, 0); err == nil {
+		t.Fatal("ProjectAccountDepletion for unknown account unexpectedly succeeded")
+	}
+}
+
+func TestTransfersExcludedFromAggregationsByDefaultWithFixtureDB(t *testing.T) {
+	db := newFixtureDBWithExtraRows(t, func(conn *sql.DB) {
+		mustExecSQL(t, conn, `
+			INSERT INTO ZSYNCOBJECT (Z_PK, Z_ENT, ZNAME, ZBALLANCE, ZOPENINGBALANCE, ZCURRENCYNAME, ZTYPE)
+			VALUES (2, 10, 'Savings', 0, 0, 'USD', 'bank');
+		`)
+		insertUncategorizedTransaction(t, conn, 2000, 43, -500, "2024-02-20", "Move to savings", 1, 2)
+		insertUncategorizedTransaction(t, conn, 2001, 43, 500, "2024-02-20", "Move to savings", 2, 1)
+	})
+	defer db.Close()
+
+	stats, err := db.GetFinancialStats(context.Background(), false)
+	if err != nil {
+		t.Fatalf("GetFinancialStats: %v", err)
+	}
+	assertFloatClose(t, "stats income without transfers", stats.TotalIncome, 5500, 0.001)
+	assertFloatClose(t, "stats spending without transfers", stats.TotalSpending, 1500, 0.001)
+
+	savings, err := db.AnalyzeSavings(context.Background(), SavingsOptions{TopCategories: 5})
+	if err != nil {
+		t.Fatalf("AnalyzeSavings: %v", err)
+	}
+	assertFloatClose(t, "savings income without transfers", savings.TotalIncome, 5500, 0.001)
+	assertFloatClose(t, "savings spending without transfers", savings.TotalSpending, 1500, 0.001)
+
+	spending, err := db.AnalyzeSpendingTrends(context.Background(), SpendingTrendOptions{GroupBy: "month"})
+	if err != nil {
+		t.Fatalf("AnalyzeSpendingTrends: %v", err)
+	}
+	if spending[1].Period != "2024-02" || spending[1].TransactionCount != 1 {
+		t.Fatalf("february spending = %s with %d transactions, want 2024-02 with 1", spending[1].Period, spending[1].TransactionCount)
+	}
+
+	withTransfers, err := db.GetFinancialStats(context.Background(), true)
+	if err != nil {
+		t.Fatalf("GetFinancialStats with transfers: %v", err)
+	}
+	assertFloatClose(t, "stats income with transfers", withTransfers.TotalIncome, 6000, 0.001)
+	assertFloatClose(t, "stats spending with transfers", withTransfers.TotalSpending, 2000, 0.001)
+}
+
+func TestGetTransactionsDateRangeWithFixtureDB(t *testing.T) {
+	db := newFixtureDB(t)
+	defer db.Close()
+
+	tests := []struct {
+		name    string
+		filter  TransactionFilter
+		wantIDs []int64
+	}{
+		{name: "start only", filter: TransactionFilter{Limit: 10, StartDate: "2024-01-20"}, wantIDs: []int64{1003, 1002, 1001}},
+		{name: "end only", filter: TransactionFilter{Limit: 10, EndDate: "2024-01-20"}, wantIDs: []int64{1001, 1000}},
+		{name: "month range", filter: TransactionFilter{Limit: 10, StartDate: "2024-02", EndDate: "2024-02"}, wantIDs: []int64{1003, 1002}},
+		{name: "range with account", filter: TransactionFilter{AccountID: 1, Limit: 10, StartDate: "2024-01-16", EndDate: "2024-02-09"}, wantIDs: []int64{1002, 1001}},
+		{name: "min amount matches income and expenses", filter: TransactionFilter{Limit: 10, MinAmount: 1000}, wantIDs: []int64{1002, 1001, 1000}},
+		{name: "max amount", filter: TransactionFilter{Limit: 10, MaxAmount: 1500}, wantIDs: []int64{1003, 1001}},
+		{name: "amount range", filter: TransactionFilter{Limit: 10, MinAmount: 1000, MaxAmount: 2600}, wantIDs: []int64{1002, 1001}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			transactions, err := db.GetTransactions(context.Background(), tc.filter)
+			if err != nil {
+				t.Fatalf("GetTransactions: %v", err)
+			}
+			if len(transactions) != len(tc.wantIDs) {
+				t.Fatalf("transactions len = %d, want %d", len(transactions), len(tc.wantIDs))
+			}
+			for i, want := range tc.wantIDs {
+				if transactions[i].ID != want {
+					t.Fatalf("transactions[%d].ID = %d, want %d", i, transactions[i].ID, want)
+				}
+			}
+		})
+	}
+
+	if _, err := db.GetTransactions(context.Background(), TransactionFilter{Limit: 10, StartDate: "01/20/2024"}); err == nil {
+		t.Fatal("GetTransactions with unparseable start_date unexpectedly succeeded")
+	}
+	if _, err := db.GetTransactions(context.Background(), TransactionFilter{Limit: 10, StartDate: "2024-03-01", EndDate: "2024-02-01"}); err == nil {
+		t.Fatal("GetTransactions with start_date after end_date unexpectedly succeeded")
+	}
+	if _, err := db.GetTransactions(context.Background(), TransactionFilter{Limit: 10, MinAmount: 500, MaxAmount: 100}); err == nil {
+		t.Fatal("GetTransactions with min_amount above max_amount unexpectedly succeeded")
+	}
+}
+
+func TestSearchTransactionsWithFixtureDB(t *testing.T) {
+	db := newFixtureDBWithExtraRows(t, func(conn *sql.DB) {
+		insertTransaction(t, conn, 2000, 37, -45, "2024-02-12", "Оплата ПРОДУКТЫ", 1, 0, 102)
+		mustExecSQL(t, conn, `
+			INSERT INTO ZSYNCOBJECT (Z_PK, Z_ENT, ZAMOUNT1, ZDATE1, ZACCOUNT2)
+			VALUES (2001, 37, -10, 729000000, 1);
+		`)
+	})
+	defer db.Close()
+
+	got, err := db.SearchTransactions(context.Background(), "SALARY", 0, 10)
+	if err != nil {
+		t.Fatalf("SearchTransactions: %v", err)
+	}
+	if len(got) != 2 || got[0].ID != 1002 || got[1].ID != 1000 {
+		t.Fatalf("salary matches = %#v, want [1002 1000]", got)
+	}
+
+	limited, err := db.SearchTransactions(context.Background(), "salary", 1, 1)
+	if err != nil {
+		t.Fatalf("SearchTransactions with limit: %v", err)
+	}
+	if len(limited) != 1 || limited[0].ID != 1002 {
+		t.Fatalf("limited matches = %#v, want [1002]", limited)
+	}
+
+	cyrillic, err := db.SearchTransactions(context.Background(), "продукты", 0, 10)
+	if err != nil {
+		t.Fatalf("SearchTransactions non-ASCII: %v", err)
+	}
+	if len(cyrillic) != 1 || cyrillic[0].ID != 2000 {
+		t.Fatalf("non-ASCII matches = %#v, want [2000]", cyrillic)
+	}
+
+	count, err := db.CountTransactions(context.Background(), TransactionFilter{Search: "salary"})
+	if err != nil {
+		t.Fatalf("CountTransactions with search: %v", err)
+	}
+	if count != 2 {
+		t.Fatalf("salary match count = %d, want 2", count)
+	}
+	page, err := db.GetTransactions(context.Background(), TransactionFilter{Limit: 10, Offset: 1, Search: "salary"})
+	if err != nil {
+		t.Fatalf("GetTransactions with search offset: %v", err)
+	}
+	if len(page) != 1 || page[0].ID != 1000 {
+		t.Fatalf("salary matches after offset = %#v, want [1000]", page)
+	}
+
+	if _, err := db.SearchTransactions(context.Background(), "  ", 0, 10); err == nil {
+		t.Fatal("SearchTransactions with blank query unexpectedly succeeded")
+	}
+}
+
+func TestSearchTransactionsMatchesNotesWithFixtureDB(t *testing.T) {
+	db := newFixtureDBWithExtraRows(t, func(conn *sql.DB) {
+		mustExecSQL(t, conn, `
+			ALTER TABLE ZSYNCOBJECT ADD COLUMN ZNOTES1 TEXT;
+			UPDATE ZSYNCOBJECT SET ZNOTES1 = 'Split rent with roommate' WHERE Z_PK = 1001;
+			INSERT INTO ZSYNCOBJECT (Z_PK, Z_ENT, ZAMOUNT1, ZDATE1, ZACCOUNT2, ZNOTES1)
+			VALUES (2000, 37, -10, 729000000, 1, 'Roommate paid back half');
+		`)
+	})
+	defer db.Close()
+	ctx := context.Background()
+
+	got, err := db.SearchTransactions(ctx, "ROOMMATE", 0, 10)
+	if err != nil {
+		t.Fatalf("SearchTransactions: %v", err)
+	}
+	if len(got) != 2 || got[0].ID != 2000 || got[1].ID != 1001 {
+		t.Fatalf("roommate matches = %#v, want [2000 1001]", got)
+	}
+	if got[1].Notes != "Split rent with roommate" || got[1].Description != "Rent payment" {
+		t.Fatalf("expected the note apart from the description, got %+v", got[1])
+	}
+	if got[0].Description != "" {
+		t.Fatalf("expected a transaction with only a note to match, got %+v", got[0])
+	}
+
+	count, err := db.CountTransactions(ctx, TransactionFilter{Search: "roommate"})
+	if err != nil {
+		t.Fatalf("CountTransactions: %v", err)
+	}
+	if count != 2 {
+		t.Fatalf("roommate match count = %d, want 2", count)
+	}
+
+	salary, err := db.SearchTransactions(ctx, "salary", 0, 10)
+	if err != nil {
+		t.Fatalf("SearchTransactions: %v", err)
+	}
+	if len(salary) != 2 || salary[0].Notes != "" {
+		t.Fatalf("salary matches = %#v, want two without notes", salary)
+	}
+}
+
+func TestCalculateNetWorthInCurrencyWithFixtureDB(t *testing.T) {
+	db := newFixtureDBWithExtraRows(t, func(conn *sql.DB) {
+		mustExecSQL(t, conn, `
+			INSERT INTO ZSYNCOBJECT (Z_PK, Z_ENT, ZNAME, ZBALLANCE, ZOPENINGBALANCE, ZCURRENCYNAME, ZTYPE)
+			VALUES (2, 10, 'EUR Checking', 0, 1000, 'EUR', 'bank');
+			INSERT INTO ZSYNCOBJECT (Z_PK, Z_ENT, ZNAME, ZBALLANCE, ZOPENINGBALANCE, ZCURRENCYNAME, ZTYPE)
+			VALUES (3, 10, 'UAH Card', 0, -4000, 'UAH', 'credit');
+		`)
+	})
+	defer db.Close()
+
+	got, err := db.CalculateNetWorthInCurrency(context.Background(), "usd", map[string]float64{"eur": 1.1}, AccountFilter{})
+	if err != nil {
+		t.Fatalf("CalculateNetWorthInCurrency: %v", err)
+	}
+
+	if got.TargetCurrency != "USD" {
+		t.Fatalf("target currency = %q, want USD", got.TargetCurrency)
+	}
+	assertFloatClose(t, "total assets", got.TotalAssets, 6100, 0.001)
+	assertFloatClose(t, "total liabilities", got.TotalLiabilities, 4000, 0.001)
+	assertFloatClose(t, "net worth", got.NetWorth, 2100, 0.001)
+	assertFloatClose(t, "raw eur", got.ByCurrency["EUR"], 1000, 0.001)
+	assertFloatClose(t, "applied eur rate", got.ExchangeRates["EUR"], 1.1, 0.0001)
+	if len(got.Warnings) != 1 || !strings.Contains(got.Warnings[0], "UAH") {
+		t.Fatalf("warnings = %#v, want one warning about UAH", got.Warnings)
+	}
+
+	if _, err := db.CalculateNetWorthInCurrency(context.Background(), "USD", map[string]float64{"EUR": 0}, AccountFilter{}); err == nil {
+		t.Fatal("CalculateNetWorthInCurrency with zero rate unexpectedly succeeded")
+	}
+}
+
+func TestAnalyzeSpendingTrendsInCurrencyWithFixtureDB(t *testing.T) {
+	db := newFixtureDBWithExtraRows(t, func(conn *sql.DB) {
+		mustExecSQL(t, conn, `
+			INSERT INTO ZSYNCOBJECT (Z_PK, Z_ENT, ZNAME, ZBALLANCE, ZOPENINGBALANCE, ZCURRENCYNAME, ZTYPE)
+			VALUES (2, 10, 'EUR Checking', 0, 1000, 'EUR', 'bank');
+		`)
+		insertTransaction(t, conn, 2000, 37, -100, "2024-02-12", "Bakery", 2, 0, 102)
+	})
+	defer db.Close()
+	ctx := context.Background()
+
+	raw, err := db.AnalyzeSpendingTrends(ctx, SpendingTrendOptions{GroupBy: "month"})
+	if err != nil {
+		t.Fatalf("AnalyzeSpendingTrends: %v", err)
+	}
+	if len(raw) != 2 {
+		t.Fatalf("raw trends len = %d, want 2", len(raw))
+	}
+	assertFloatClose(t, "raw february total", raw[1].TotalSpending, 400, 0.001)
+
+	trends, conversion, err := db.AnalyzeSpendingTrendsInCurrency(ctx, "usd", map[string]float64{"EUR": 1.1}, SpendingTrendOptions{GroupBy: "month"})
+	if err != nil {
+		t.Fatalf("AnalyzeSpendingTrendsInCurrency: %v", err)
+	}
+	if len(trends) != 2 {
+		t.Fatalf("trends len = %d, want 2", len(trends))
+	}
+	assertFloatClose(t, "january total", trends[0].TotalSpending, 1200, 0.001)
+	assertFloatClose(t, "february total", trends[1].TotalSpending, 410, 0.001)
+	assertFloatClose(t, "february groceries", trends[1].ByCategory["Groceries"], 410, 0.001)
+	assertFloatClose(t, "february raw eur", trends[1].ByCurrency["EUR"], 100, 0.001)
+	if conversion.TargetCurrency != "USD" || len(conversion.Warnings) != 0 {
+		t.Fatalf("conversion = %#v, want USD without warnings", conversion)
+	}
+	assertFloatClose(t, "applied eur rate", conversion.ExchangeRates["EUR"], 1.1, 0.0001)
+
+	_, conversion, err = db.AnalyzeSpendingTrendsInCurrency(ctx, "USD", nil, SpendingTrendOptions{GroupBy: "month"})
+	if err != nil {
+		t.Fatalf("AnalyzeSpendingTrendsInCurrency without rates: %v", err)
+	}
+	if len(conversion.Warnings) != 1 || !strings.Contains(conversion.Warnings[0], "EUR") {
+		t.Fatalf("warnings = %#v, want one warning about EUR", conversion.Warnings)
+	}
+
+	_, _, err = db.AnalyzeSpendingTrendsInCurrency(ctx, "USD", map[string]float64{"EUR": -1}, SpendingTrendOptions{GroupBy: "month"})
+	if !errors.Is(err, ErrInvalidArgument) {
+		t.Fatalf("negative rate error = %v, want ErrInvalidArgument", err)
+	}
+}
+
+func TestAnalyzeSpendingByPayeeWithFixtureDB(t *testing.T) {
+	db := newFixtureDBWithExtraRows(t, func(conn *sql.DB) {
+		mustExecSQL(t, conn, `
+			ALTER TABLE ZSYNCOBJECT ADD COLUMN ZPAYEE2 INTEGER;
+			ALTER TABLE ZSYNCOBJECT ADD COLUMN ZNAME5 TEXT;
+			INSERT INTO ZSYNCOBJECT (Z_PK, Z_ENT, ZNAME5) VALUES (500, 28, 'Whole Foods');
+			UPDATE ZSYNCOBJECT SET ZPAYEE2 = 500 WHERE Z_PK = 1003;
+		`)
+		insertTransaction(t, conn, 2000, 37, -50, "2024-02-14", "WHOLE FOODS MKT #12", 1, 0, 102)
+		mustExecSQL(t, conn, `UPDATE ZSYNCOBJECT SET ZPAYEE2 = 500 WHERE Z_PK = 2000;`)
+	})
+	defer db.Close()
+
+	got, err := db.AnalyzeSpendingByPayee(context.Background(), 0)
+	if err != nil {
+		t.Fatalf("AnalyzeSpendingByPayee: %v", err)
+	}
+
+	if got.PayeeCount != 2 {
+		t.Fatalf("payee count = %d, want 2", got.PayeeCount)
+	}
+	if got.Payees[0].Payee != "Rent payment" || got.Payees[1].Payee != "Whole Foods" {
+		t.Fatalf("payees = [%s %s], want [Rent payment Whole Foods]", got.Payees[0].Payee, got.Payees[1].Payee)
+	}
+	assertFloatClose(t, "whole foods total", got.Payees[1].TotalSpending, 350, 0.001)
+	assertFloatClose(t, "whole foods average", got.Payees[1].AverageAmount, 175, 0.001)
+	if got.Payees[1].TransactionCount != 2 {
+		t.Fatalf("whole foods transactions = %d, want 2", got.Payees[1].TransactionCount)
+	}
+	assertFloatClose(t, "total spending", got.TotalSpending, 1550, 0.001)
+
+	transactions, err := db.GetTransactions(context.Background(), TransactionFilter{Limit: 1})
+	if err != nil {
+		t.Fatalf("GetTransactions: %v", err)
+	}
+	if transactions[0].Payee != "Whole Foods" {
+		t.Fatalf("transaction payee = %q, want Whole Foods", transactions[0].Payee)
+	}
+}
+
+func TestGetSinceLastPaycheckWithFixtureDB(t *testing.T) {
+	db := newFixtureDB(t)
+	got, err := db.GetSinceLastPaycheck(context.Background(), 0, "2024-02-28")
+	if err != nil {
+		t.Fatalf("GetSinceLastPaycheck without a pattern: %v", err)
+	}
+	if got.PaycheckFound || !strings.Contains(got.Message, "No regular income pattern") {
+		t.Fatalf("period without a pattern = %+v, want a not-found message", got)
+	}
+	db.Close()
+
+	db = newFixtureDBWithExtraRows(t, func(conn *sql.DB) {
+		insertTransaction(t, conn, 2000, 37, 3000, "2024-03-15", "ACME Payroll", 1, 0, 100)
+		insertTransaction(t, conn, 2001, 37, 3000, "2024-04-15", "ACME Payroll", 1, 0, 100)
+		insertTransaction(t, conn, 2002, 37, 3050, "2024-05-15", "ACME Payroll", 1, 0, 100)
+		insertTransaction(t, conn, 2003, 37, -50, "2024-05-10", "Before payday", 1, 0, 102)
+		insertTransaction(t, conn, 2004, 37, -200, "2024-05-20", "Groceries", 1, 0, 102)
+		insertTransaction(t, conn, 2005, 37, 40, "2024-05-22", "Cashback", 1, 0, 0)
+		insertTransaction(t, conn, 2006, 37, -100, "2024-05-25", "Utilities", 1, 0, 101)
+		insertTransaction(t, conn, 2007, 37, -500, "2024-06-02", "After as_of", 1, 0, 101)
+	})
+	defer db.Close()
+
+	got, err = db.GetSinceLastPaycheck(context.Background(), 0, "2024-05-31")
+	if err != nil {
+		t.Fatalf("GetSinceLastPaycheck: %v", err)
+	}
+	if !got.PaycheckFound || got.PaycheckTransactionID != 2002 || got.PaycheckDate != "2024-05-15" {
+		t.Fatalf("period = %+v, want the 2024-05-15 paycheck", got)
+	}
+	if got.PaycheckCount != 3 || got.NextExpectedDate != "2024-06-15" || got.DaysSincePaycheck != 16 || got.DaysUntilNextPaycheck != 15 {
+		t.Fatalf("period = %+v, want 3 paychecks, next on 2024-06-15 in 15 days", got)
+	}
+	assertFloatClose(t, "paycheck amount", got.PaycheckAmount, 3050, 0.001)
+	assertFloatClose(t, "income since paycheck", got.IncomeSincePaycheck, 3090, 0.001)
+	assertFloatClose(t, "spending since paycheck", got.SpendingSincePaycheck, 300, 0.001)
+	assertFloatClose(t, "remaining", got.Remaining, 2790, 0.001)
+	assertFloatClose(t, "groceries since paycheck", got.SpendingByCategory["Groceries"], 200, 0.001)
+	if got.DailyAllowance == nil {
+		t.Fatal("daily allowance = nil, want remaining over 15 days")
+	}
+	assertFloatClose(t, "daily allowance", *got.DailyAllowance, 186, 0.001)
+
+	if _, err := db.GetSinceLastPaycheck(context.Background(), 0, "May 31"); !errors.Is(err, ErrInvalidArgument) {
+		t.Fatalf("invalid as_of error = %v, want ErrInvalidArgument", err)
+	}
+}
+
+func TestAnalyzeSpendingByTagWithFixtureDB(t *testing.T) {
+	db := newFixtureDB(t)
+	got, err := db.AnalyzeSpendingByTag(context.Background(), 0)
+	if err != nil {
+		t.Fatalf("AnalyzeSpendingByTag without tags: %v", err)
+	}
+	if len(got.Tags) != 0 || got.Note == "" {
+		t.Fatalf("analysis without tags = %+v, want an empty list with a note", got)
+	}
+	db.Close()
+
+	db = newFixtureDBWithExtraRows(t, func(conn *sql.DB) {
+		mustExecSQL(t, conn, `
+			CREATE TABLE Z_36TAGS (Z_36TRANSACTIONS INTEGER, Z_35TAGS INTEGER);
+			INSERT INTO ZSYNCOBJECT (Z_PK, Z_ENT, ZNAME) VALUES (900, 35, 'Vacation');
+			INSERT INTO ZSYNCOBJECT (Z_PK, Z_ENT, ZNAME) VALUES (901, 35, 'Reimbursable');
+			INSERT INTO Z_36TAGS (Z_36TRANSACTIONS, Z_35TAGS) VALUES (1003, 900), (1003, 901), (1001, 901);
+		`)
+		insertTransaction(t, conn, 2000, 37, -50, "2024-02-14", "Coffee", 1, 0, 102)
+	})
+	defer db.Close()
+
+	got, err = db.AnalyzeSpendingByTag(context.Background(), 0)
+	if err != nil {
+		t.Fatalf("AnalyzeSpendingByTag: %v", err)
+	}
+	if got.TagTable != "Z_36TAGS" || got.TagCount != 2 {
+		t.Fatalf("analysis = %+v, want 2 tags from Z_36TAGS", got)
+	}
+	if got.Tags[0].Tag != "Reimbursable" || got.Tags[1].Tag != "Vacation" {
+		t.Fatalf("tags = [%s %s], want [Reimbursable Vacation]", got.Tags[0].Tag, got.Tags[1].Tag)
+	}
+	assertFloatClose(t, "reimbursable total", got.Tags[0].TotalSpending, 1500, 0.001)
+	assertFloatClose(t, "reimbursable rent", got.Tags[0].ByCategory["Rent"], 1200, 0.001)
+	assertFloatClose(t, "vacation total", got.Tags[1].TotalSpending, 300, 0.001)
+	if got.Tags[0].TransactionCount != 2 || got.Tags[0].FirstDate != "2024-01-20 00:00:00" || got.Tags[0].LastDate != "2024-02-10 00:00:00" {
+		t.Fatalf("reimbursable = %+v, want 2 transactions from 2024-01-20 to 2024-02-10", got.Tags[0])
+	}
+	assertFloatClose(t, "tagged spending", got.TaggedSpending, 1500, 0.001)
+	assertFloatClose(t, "untagged spending", got.UntaggedSpending, 50, 0.001)
+
+	transactions, err := db.GetTransactions(context.Background(), TransactionFilter{AccountID: 1})
+	if err != nil {
+		t.Fatalf("GetTransactions: %v", err)
+	}
+	for _, txn := range transactions {
+		switch txn.ID {
+		case 1003:
+			if len(txn.Tags) != 2 || txn.Tags[0] != "Reimbursable" || txn.Tags[1] != "Vacation" {
+				t.Fatalf("transaction 1003 tags = %#v, want [Reimbursable Vacation]", txn.Tags)
+			}
+		case 2000:
+			if len(txn.Tags) != 0 {
+				t.Fatalf("transaction 2000 tags = %#v, want none", txn.Tags)
+			}
+		}
+	}
+}
+
+func TestAccountGroupsWithFixtureDB(t *testing.T) {
+	db := newFixtureDB(t)
+	report, err := db.GetAccountGroups(context.Background())
+	if err != nil {
+		t.Fatalf("GetAccountGroups without groups: %v", err)
+	}
+	if len(report.Groups) != 0 || report.Note == "" {
+		t.Fatalf("report without groups = %+v, want an empty list with a note", report)
+	}
+	db.Close()
+
+	db = newFixtureDBWithExtraRows(t, func(conn *sql.DB) {
+		mustExecSQL(t, conn, `
+			CREATE TABLE Z_PRIMARYKEY (Z_ENT INTEGER, Z_NAME TEXT, Z_SUPER INTEGER, Z_MAX INTEGER);
+			INSERT INTO Z_PRIMARYKEY (Z_ENT, Z_NAME) VALUES (10, 'BankChequeAccount'), (80, 'AccountGroup');
+			ALTER TABLE ZSYNCOBJECT ADD COLUMN ZGROUPID INTEGER;
+			INSERT INTO ZSYNCOBJECT (Z_PK, Z_ENT, ZNAME) VALUES (800, 80, 'Cash');
+			INSERT INTO ZSYNCOBJECT (Z_PK, Z_ENT, ZNAME) VALUES (801, 80, 'Retirement');
+			INSERT INTO ZSYNCOBJECT (Z_PK, Z_ENT, ZNAME, ZBALLANCE, ZOPENINGBALANCE, ZCURRENCYNAME, ZTYPE)
+			VALUES (2, 10, 'EUR Savings', 0, 1000, 'EUR', 'bank');
+			UPDATE ZSYNCOBJECT SET ZGROUPID = 800 WHERE Z_PK = 1;
+		`)
+	})
+	defer db.Close()
+	ctx := context.Background()
+
+	report, err = db.GetAccountGroups(ctx)
+	if err != nil {
+		t.Fatalf("GetAccountGroups: %v", err)
+	}
+	if report.GroupEntity != "AccountGroup" || report.GroupColumn != "ZGROUPID" || len(report.Groups) != 2 {
+		t.Fatalf("report = %+v, want 2 groups from AccountGroup.ZGROUPID", report)
+	}
+	if cash := report.Groups[0]; cash.Name != "Cash" || cash.AccountCount != 1 || cash.AccountIDs[0] != 1 {
+		t.Fatalf("first group = %+v, want Cash with account 1", cash)
+	}
+	if retirement := report.Groups[1]; retirement.Name != "Retirement" || retirement.AccountCount != 0 {
+		t.Fatalf("second group = %+v, want an empty Retirement group", retirement)
+	}
+
+	account, err := db.GetAccountBalance(ctx, 1)
+	if err != nil {
+		t.Fatalf("GetAccountBalance: %v", err)
+	}
+	if account.Group != "Cash" {
+		t.Fatalf("account group = %q, want Cash", account.Group)
+	}
+
+	netWorth, err := db.CalculateNetWorthInCurrency(ctx, "USD", map[string]float64{"EUR": 1.1}, AccountFilter{})
+	if err != nil {
+		t.Fatalf("CalculateNetWorthInCurrency: %v", err)
+	}
+	if err := db.BreakDownNetWorthByGroup(ctx, netWorth, nil); err != nil {
+		t.Fatalf("BreakDownNetWorthByGroup: %v", err)
+	}
+	if netWorth.GroupSource != AccountGroupSourceDatabase || len(netWorth.ByGroup) != 2 {
+		t.Fatalf("by group = %+v from %q, want Cash and Ungrouped from the database", netWorth.ByGroup, netWorth.GroupSource)
+	}
+	assertFloatClose(t, "cash net worth", netWorth.ByGroup[0].NetWorth, 5000, 0.001)
+	if netWorth.ByGroup[1].Group != "Ungrouped" {
+		t.Fatalf("second group = %q, want Ungrouped", netWorth.ByGroup[1].Group)
+	}
+	assertFloatClose(t, "converted ungrouped net worth", netWorth.ByGroup[1].NetWorth, 1100, 0.001)
+	assertFloatClose(t, "raw ungrouped eur", netWorth.ByGroup[1].ByCurrency["EUR"], 1000, 0.001)
+
+	netWorth, err = db.CalculateNetWorth(ctx, AccountFilter{})
+	if err != nil {
+		t.Fatalf("CalculateNetWorth: %v", err)
+	}
+	if err := db.BreakDownNetWorthByGroup(ctx, netWorth, map[string][]int64{"joint": {1, 2}}); err != nil {
+		t.Fatalf("BreakDownNetWorthByGroup with client groups: %v", err)
+	}
+	if netWorth.GroupSource != AccountGroupSourceClient || len(netWorth.ByGroup) != 1 || netWorth.ByGroup[0].Group != "joint" {
+		t.Fatalf("by group = %+v from %q, want only joint from the client", netWorth.ByGroup, netWorth.GroupSource)
+	}
+	assertFloatClose(t, "joint net worth", netWorth.ByGroup[0].NetWorth, 6000, 0.001)
+
+	err = db.BreakDownNetWorthByGroup(ctx, netWorth, map[string][]int64{"cash": {1}, "joint": {1, 2}})
+	if !errors.Is(err, ErrInvalidArgument) {
+		t.Fatalf("overlapping groups error = %v, want ErrInvalidArgument", err)
+	}
+}
+
+func TestGetSavingsGoalsWithFixtureDB(t *testing.T) {
+	db := newFixtureDB(t)
+	report, err := db.GetSavingsGoals(context.Background(), 6)
+	if err != nil {
+		t.Fatalf("GetSavingsGoals without goals: %v", err)
+	}
+	if len(report.Goals) != 0 || report.Note == "" {
+		t.Fatalf("report without goals = %+v, want an empty list with a note", report)
+	}
+	db.Close()
+
+	deadline := timeToCoreData(time.Now().UTC().AddDate(2, 0, 0))
+	db = newFixtureDBWithExtraRows(t, func(conn *sql.DB) {
+		mustExecSQL(t, conn, `
+			CREATE TABLE Z_PRIMARYKEY (Z_ENT INTEGER, Z_NAME TEXT, Z_SUPER INTEGER, Z_MAX INTEGER);
+			INSERT INTO Z_PRIMARYKEY (Z_ENT, Z_NAME) VALUES (10, 'BankChequeAccount'), (60, 'Goal');
+			ALTER TABLE ZSYNCOBJECT ADD COLUMN ZGOALAMOUNT REAL;
+			ALTER TABLE ZSYNCOBJECT ADD COLUMN ZGOALDATE REAL;
+			INSERT INTO ZSYNCOBJECT (Z_PK, Z_ENT, ZNAME, ZGOALAMOUNT, ZACCOUNT) VALUES (600, 60, 'Rainy day', 4000, 1);
+			INSERT INTO ZSYNCOBJECT (Z_PK, Z_ENT, ZNAME, ZGOALAMOUNT) VALUES (602, 60, 'Someday', 500);
+		`)
+		mustExecSQL(t, conn, `INSERT INTO ZSYNCOBJECT (Z_PK, Z_ENT, ZNAME, ZGOALAMOUNT, ZACCOUNT, ZGOALDATE) VALUES (601, 60, 'House', 10000, 1, ?);`, deadline)
+	})
+	defer db.Close()
+
+	report, err = db.GetSavingsGoals(context.Background(), 2)
+	if err != nil {
+		t.Fatalf("GetSavingsGoals: %v", err)
+	}
+	if report.GoalEntity != "Goal" || len(report.Goals) != 3 {
+		t.Fatalf("report = %+v, want 3 goals from the Goal entity", report)
+	}
+
+	rainyDay, house, someday := report.Goals[0], report.Goals[1], report.Goals[2]
+	if rainyDay.Status != "reached" || rainyDay.ProgressPercent != 100 || rainyDay.AmountRemaining != 0 {
+		t.Fatalf("rainy day goal = %+v, want reached", rainyDay)
+	}
+
+	// Checking holds 5000 and grew by 4000 over the last two months of activity
+	assertFloatClose(t, "house current amount", house.CurrentAmount, 5000, 0.001)
+	assertFloatClose(t, "house remaining", house.AmountRemaining, 5000, 0.001)
+	assertFloatClose(t, "house progress", house.ProgressPercent, 50, 0.001)
+	assertFloatClose(t, "house contribution", house.AverageMonthlyContribution, 2000, 0.001)
+	if house.Status != "on_pace" || house.RequiredMonthlyContribution == nil {
+		t.Fatalf("house goal = %+v, want on_pace with a required contribution", house)
+	}
+	assertFloatClose(t, "house required contribution", *house.RequiredMonthlyContribution, 5000.0/24, 1)
+
+	if someday.Status != "unlinked" || someday.AmountRemaining != 500 {
+		t.Fatalf("unlinked goal = %+v, want unlinked with the full target remaining", someday)
+	}
+}
+
+func TestGetInvestmentHoldingsWithFixtureDB(t *testing.T) {
+	db := newFixtureDBWithExtraRows(t, func(conn *sql.DB) {
+		mustExecSQL(t, conn, `
+			CREATE TABLE Z_PRIMARYKEY (Z_ENT INTEGER, Z_NAME TEXT, Z_SUPER INTEGER, Z_MAX INTEGER);
+			INSERT INTO Z_PRIMARYKEY (Z_ENT, Z_NAME) VALUES (15, 'InvestmentAccount'), (70, 'InvestmentHolding');
+			ALTER TABLE ZSYNCOBJECT ADD COLUMN ZSYMBOL TEXT;
+			ALTER TABLE ZSYNCOBJECT ADD COLUMN ZNUMBEROFSHARES REAL;
+			ALTER TABLE ZSYNCOBJECT ADD COLUMN ZPRICEPERSHARE REAL;
+			ALTER TABLE ZSYNCOBJECT ADD COLUMN ZCOSTBASIS REAL;
+			INSERT INTO ZSYNCOBJECT (Z_PK, Z_ENT, ZNAME, ZBALLANCE, ZOPENINGBALANCE, ZCURRENCYNAME, ZTYPE)
+			VALUES (5, 15, 'Brokerage', 0, 0, 'USD', 'investment');
+			INSERT INTO ZSYNCOBJECT (Z_PK, Z_ENT, ZSYMBOL, ZNAME, ZNUMBEROFSHARES, ZPRICEPERSHARE, ZCOSTBASIS, ZACCOUNT)
+			VALUES
+				(700, 70, 'VTI', 'Total Stock Market', 10, 250, 2000, 5),
+				(701, 70, 'BND', 'Total Bond Market', 20, 25, 600, 5),
+				(702, 70, 'AAPL', 'Apple', 1, 200, 100, 1);
+		`)
+	})
+	defer db.Close()
+
+	got, err := db.GetInvestmentHoldings(context.Background(), 5)
+	if err != nil {
+		t.Fatalf("GetInvestmentHoldings: %v", err)
+	}
+	if got.HoldingEntity != "InvestmentHolding" || len(got.Holdings) != 2 {
+		t.Fatalf("holdings = %+v, want 2 holdings from InvestmentHolding", got)
+	}
+
+	vti, bnd := got.Holdings[0], got.Holdings[1]
+	if vti.Symbol != "VTI" || bnd.Symbol != "BND" {
+		t.Fatalf("holding order = [%s %s], want [VTI BND]", vti.Symbol, bnd.Symbol)
+	}
+	assertFloatClose(t, "vti value", *vti.CurrentValue, 2500, 0.001)
+	assertFloatClose(t, "vti gain", *vti.GainLoss, 500, 0.001)
+	assertFloatClose(t, "vti gain percent", *vti.GainLossPercent, 25, 0.001)
+	assertFloatClose(t, "bnd gain", *bnd.GainLoss, -100, 0.001)
+	assertFloatClose(t, "vti portfolio share", *vti.PortfolioPercent, 2500.0/3000*100, 0.001)
+	assertFloatClose(t, "total value", *got.TotalValue, 3000, 0.001)
+	assertFloatClose(t, "total gain", *got.TotalGainLoss, 400, 0.001)
+
+	checking, err := db.GetInvestmentHoldings(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("GetInvestmentHoldings for checking: %v", err)
+	}
+	if len(checking.Holdings) != 1 || checking.Holdings[0].Symbol != "AAPL" {
+		t.Fatalf("checking holdings = %+v, want only AAPL", checking.Holdings)
+	}
+
+	if _, err := db.GetInvestmentHoldings(context.Background(), 999); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("unknown account error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestGetTopMerchantsRanksByCountAndAmountWithFixtureDB(t *testing.T) {
+	db := newFixtureDBWithExtraRows(t, func(conn *sql.DB) {
+		insertTransaction(t, conn, 2000, 37, -4, "2024-02-11", "Coffee", 1, 0, 102)
+		insertTransaction(t, conn, 2001, 37, -4, "2024-02-12", "coffee", 1, 0, 102)
+		insertTransaction(t, conn, 2002, 37, -5, "2024-02-13", "Coffee", 1, 0, 102)
+	})
+	defer db.Close()
+
+	got, err := db.GetTopMerchants(context.Background(), 0, 2)
+	if err != nil {
+		t.Fatalf("GetTopMerchants: %v", err)
+	}
+
+	if got.MerchantCount != 3 {
+		t.Fatalf("merchant count = %d, want 3", got.MerchantCount)
+	}
+	if len(got.ByCount) != 2 || len(got.ByAmount) != 2 {
+		t.Fatalf("ranking lengths = %d/%d, want 2/2", len(got.ByCount), len(got.ByAmount))
+	}
+	if got.ByCount[0].Payee != "Coffee" || got.ByCount[0].TransactionCount != 3 {
+		t.Fatalf("most frequent = %+v, want Coffee with 3 transactions", got.ByCount[0])
+	}
+	assertFloatClose(t, "coffee total", got.ByCount[0].TotalSpending, 13, 0.001)
+	if got.ByAmount[0].Payee != "Rent payment" || got.ByAmount[1].Payee != "Groceries" {
+		t.Fatalf("by amount = [%s %s], want [Rent payment Groceries]", got.ByAmount[0].Payee, got.ByAmount[1].Payee)
+	}
+}
+
+func TestCalculateNetWorthClassifiesLiabilitiesByAccountTypeWithFixtureDB(t *testing.T) {
+	db := newFixtureDBWithExtraRows(t, func(conn *sql.DB) {
+		mustExecSQL(t, conn, `
+			INSERT INTO ZSYNCOBJECT (Z_PK, Z_ENT, ZNAME, ZBALLANCE, ZOPENINGBALANCE, ZCURRENCYNAME, ZTYPE) VALUES
+				(2, 13, 'Visa', 0, 100, 'USD', 'Credit Card'),
+				(3, 16, 'Car Loan', 0, -10000, 'USD', 'loan'),
+				(4, 13, 'Old Wallet', 0, -50, 'USD', NULL);
+		`)
+	})
+	defer db.Close()
+
+	got, err := db.CalculateNetWorth(context.Background(), AccountFilter{})
+	if err != nil {
+		t.Fatalf("CalculateNetWorth: %v", err)
+	}
+
+	assertFloatClose(t, "total assets", got.TotalAssets, 5000, 0.001)
+	assertFloatClose(t, "total liabilities", got.TotalLiabilities, 9950, 0.001)
+	assertFloatClose(t, "net worth", got.NetWorth, -4950, 0.001)
+
+	want := map[string]string{
+		"Checking":   AccountClassificationAsset,
+		"Visa":       AccountClassificationLiability,
+		"Car Loan":   AccountClassificationLiability,
+		"Old Wallet": AccountClassificationLiability,
+	}
+	for _, account := range got.Accounts {
+		if account.Classification != want[account.Name] {
+			t.Fatalf("%s classification = %q, want %q", account.Name, account.Classification, want[account.Name])
+		}
+	}
+}
+
+func TestNullCurrencyAccountsWithFixtureDB(t *testing.T) {
+	db := newFixtureDBWithExtraRows(t, func(conn *sql.DB) {
+		mustExecSQL(t, conn, `
+			INSERT INTO ZSYNCOBJECT (Z_PK, Z_ENT, ZNAME, ZBALLANCE, ZOPENINGBALANCE, ZCURRENCYNAME, ZTYPE) VALUES
+				(2, 12, 'Wallet', 0, 200, NULL, 'cash'),
+				(3, 12, 'Jar', 0, 50, '  ', 'cash');
+		`)
+		insertTransaction(t, conn, 2000, 37, -40, "2024-02-12", "Groceries cash", 2, 0, 102)
+	})
+	defer db.Close()
+	ctx := context.Background()
+
+	got, err := db.CalculateNetWorth(ctx, AccountFilter{})
+	if err != nil {
+		t.Fatalf("CalculateNetWorth: %v", err)
+	}
+	assertFloatClose(t, "net worth", got.NetWorth, 5210, 0.001)
+	assertFloatClose(t, "unknown currency", got.ByCurrency[DefaultUnknownCurrency], 210, 0.001)
+	var byCurrencyTotal float64
+	for _, balance := range got.ByCurrency {
+		byCurrencyTotal += balance
+	}
+	assertFloatClose(t, "by currency total", byCurrencyTotal, got.NetWorth, 0.001)
+
+	spending, err := db.GetSpendingData(ctx, 0, false, nil, false)
+	if err != nil {
+		t.Fatalf("GetSpendingData: %v", err)
+	}
+	for _, s := range spending {
+		if s.TransactionID == 2000 && s.Currency != DefaultUnknownCurrency {
+			t.Fatalf("cash spending currency = %q, want %q", s.Currency, DefaultUnknownCurrency)
+		}
+	}
+
+	db.unknownCurrency = "USD"
+	accounts, err := db.GetAccounts(ctx)
+	if err != nil {
+		t.Fatalf("GetAccounts: %v", err)
+	}
+	for _, acc := range accounts {
+		if acc.Currency != "USD" {
+			t.Fatalf("account %s currency = %q, want the configured USD", acc.Name, acc.Currency)
+		}
+	}
+}
+
+func TestCalculateNetWorthFiltersAccountsWithFixtureDB(t *testing.T) {
+	db := newFixtureDBWithExtraRows(t, func(conn *sql.DB) {
+		mustExecSQL(t, conn, `
+			INSERT INTO ZSYNCOBJECT (Z_PK, Z_ENT, ZNAME, ZBALLANCE, ZOPENINGBALANCE, ZCURRENCYNAME, ZTYPE) VALUES
+				(2, 10, 'Joint', 0, 2000, 'USD', 'bank'),
+				(3, 12, 'Brokerage', 0, 10000, 'USD', 'Investment'),
+				(4, 13, 'Visa', 0, -500, 'USD', 'credit card');
+		`)
+	})
+	defer db.Close()
+	ctx := context.Background()
+
+	all, err := db.CalculateNetWorth(ctx, AccountFilter{})
+	if err != nil {
+		t.Fatalf("CalculateNetWorth: %v", err)
+	}
+	if all.AccountCount != 4 || all.ExcludedAccounts != 0 {
+		t.Fatalf("expected all four accounts by default, got %d (%d excluded)", all.AccountCount, all.ExcludedAccounts)
+	}
+	assertFloatClose(t, "net worth", all.NetWorth, 16500, 0.001)
+
+	withoutInvestments, err := db.CalculateNetWorth(ctx, AccountFilter{ExcludeIDs: []int64{2}, ExcludeTypes: []string{"investment"}})
+	if err != nil {
+		t.Fatalf("CalculateNetWorth excluding: %v", err)
+	}
+	if withoutInvestments.AccountCount != 2 || withoutInvestments.ExcludedAccounts != 2 || len(withoutInvestments.Accounts) != 2 {
+		t.Fatalf("expected checking and the card, got %+v", withoutInvestments.Accounts)
+	}
+	assertFloatClose(t, "net worth without joint and investments", withoutInvestments.NetWorth, 4500, 0.001)
+	assertFloatClose(t, "usd without joint and investments", withoutInvestments.ByCurrency["USD"], 4500, 0.001)
+
+	banks, err := db.CalculateNetWorthInCurrency(ctx, "USD", nil, AccountFilter{IncludeTypes: []string{"BANK"}, ExcludeIDs: []int64{2}})
+	if err != nil {
+		t.Fatalf("CalculateNetWorthInCurrency including: %v", err)
+	}
+	if banks.AccountCount != 1 || banks.Accounts[0].Name != "Checking" {
+		t.Fatalf("expected only checking, got %+v", banks.Accounts)
+	}
+
+	if _, err := db.CalculateNetWorth(ctx, AccountFilter{IncludeIDs: []int64{99}}); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("unknown account ID = %v, want ErrNotFound", err)
+	}
+	if _, err := db.CalculateNetWorth(ctx, AccountFilter{ExcludeTypes: []string{"crypto"}}); !errors.Is(err, ErrInvalidArgument) || !strings.Contains(err.Error(), "Investment") {
+		t.Fatalf("unknown account type = %v, want ErrInvalidArgument listing the types", err)
+	}
+}
+
+func TestGetFilteredAccountsByCurrencyAndTypeWithFixtureDB(t *testing.T) {
+	db := newFixtureDBWithExtraRows(t, func(conn *sql.DB) {
+		mustExecSQL(t, conn, `
+			INSERT INTO ZSYNCOBJECT (Z_PK, Z_ENT, ZNAME, ZBALLANCE, ZOPENINGBALANCE, ZCURRENCYNAME, ZTYPE) VALUES
+				(2, 10, 'Girokonto', 0, 500, 'EUR', 'bank'),
+				(3, 13, 'Visa', 0, -200, 'USD', 'credit card');
+		`)
+	})
+	defer db.Close()
+	ctx := context.Background()
+
+	all, err := db.GetFilteredAccounts(ctx, AccountFilter{})
+	if err != nil {
+		t.Fatalf("GetFilteredAccounts: %v", err)
+	}
+	if len(all) != 3 {
+		t.Fatalf("expected every account without filters, got %+v", all)
+	}
+
+	usd, err := db.GetFilteredAccounts(ctx, AccountFilter{Currencies: []string{"usd"}})
+	if err != nil {
+		t.Fatalf("GetFilteredAccounts by currency: %v", err)
+	}
+	if len(usd) != 2 || usd[0].Name != "Checking" || usd[1].Name != "Visa" {
+		t.Fatalf("expected the USD accounts, got %+v", usd)
+	}
+
+	usdBanks, err := db.GetFilteredAccounts(ctx, AccountFilter{Currencies: []string{"USD"}, IncludeTypes: []string{"bank"}})
+	if err != nil {
+		t.Fatalf("GetFilteredAccounts by currency and type: %v", err)
+	}
+	if len(usdBanks) != 1 || usdBanks[0].Name != "Checking" {
+		t.Fatalf("expected only checking, got %+v", usdBanks)
+	}
+
+	if _, err := db.GetFilteredAccounts(ctx, AccountFilter{Currencies: []string{"GBP"}}); !errors.Is(err, ErrInvalidArgument) || !strings.Contains(err.Error(), "EUR, USD") {
+		t.Fatalf("unknown currency = %v, want ErrInvalidArgument listing the currencies", err)
+	}
+}
+
+func TestMonthCutoffUsesCalendarMonthsWithFixtureDB(t *testing.T) {
+	db := newFixtureDBWithExtraRows(t, func(conn *sql.DB) {
+		insertTransaction(t, conn, 1004, 37, -40, "2024-01-09", "Coffee beans", 1, 0, 102)
+	})
+
+	// Latest transaction is 2024-02-10, so one month back starts on 2024-01-10
+	spending, err := db.GetSpendingData(context.Background(), 1, false, nil, false)
+	if err != nil {
+		t.Fatalf("GetSpendingData: %v", err)
+	}
+	for _, s := range spending {
+		if s.TransactionID == 1004 {
+			t.Fatalf("expected 2024-01-09 to fall outside a one month window, got %+v", s)
+		}
+	}
+	if len(spending) != 2 {
+		t.Fatalf("expected rent and groceries in a one month window, got %d rows", len(spending))
+	}
+
+	income, err := db.GetIncomeData(context.Background(), 1, false, false)
+	if err != nil {
+		t.Fatalf("GetIncomeData: %v", err)
+	}
+	if len(income) != 2 {
+		t.Fatalf("expected both salaries in a one month window, got %d rows", len(income))
+	}
+
+	all, err := db.GetSpendingData(context.Background(), 0, false, nil, false)
+	if err != nil {
+		t.Fatalf("GetSpendingData(0): %v", err)
+	}
+	if len(all) != 3 {
+		t.Fatalf("expected every expense with months=0, got %d rows", len(all))
+	}
+}
+
+func TestScheduledTransactionsAreExcludedByDefaultWithFixtureDB(t *testing.T) {
+	db := newFixtureDBWithExtraRows(t, func(conn *sql.DB) {
+		insertTransaction(t, conn, 1004, 37, -900, "2099-06-01", "Scheduled rent", 1, 0, 101)
+		insertTransaction(t, conn, 1005, 37, 800, "2099-06-01", "Scheduled salary", 1, 0, 100)
+	})
+	defer db.Close()
+	ctx := context.Background()
+
+	// Without the scheduled rows the latest transaction is 2024-02-10, so one month back keeps February
+	spending, err := db.GetSpendingData(ctx, 1, false, nil, false)
+	if err != nil {
+		t.Fatalf("GetSpendingData: %v", err)
+	}
+	if len(spending) != 2 {
+		t.Fatalf("expected rent and groceries in a one month window, got %+v", spending)
+	}
+	income, err := db.GetIncomeData(ctx, 0, false, false)
+	if err != nil {
+		t.Fatalf("GetIncomeData: %v", err)
+	}
+	if len(income) != 2 {
+		t.Fatalf("expected the scheduled salary to be left out, got %+v", income)
+	}
+
+	scheduled, err := db.GetSpendingData(ctx, 1, false, nil, true)
+	if err != nil {
+		t.Fatalf("GetSpendingData with scheduled: %v", err)
+	}
+	if len(scheduled) != 1 || scheduled[0].TransactionID != 1004 {
+		t.Fatalf("expected only the scheduled rent within a month of it, got %+v", scheduled)
+	}
+}
+
+func TestExportTransactionsCSVWithFixtureDB(t *testing.T) {
+	db := newFixtureDBWithExtraRows(t, func(conn *sql.DB) {
+		insertTransaction(t, conn, 1004, 37, -45.5, "2024-02-12", "Dinner, drinks and \"tips\"", 1, 0, 102)
+	})
+
+	csvText, err := db.ExportTransactionsCSV(context.Background(), TransactionFilter{StartDate: "2024-02"})
+	if err != nil {
+		t.Fatalf("ExportTransactionsCSV: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(csvText, "\r\n"), "\r\n")
+	if len(lines) != 4 {
+		t.Fatalf("expected header plus 3 February rows, got %d lines: %q", len(lines), csvText)
+	}
+	if lines[0] != "id,date,amount,description,payee,category,account" {
+		t.Fatalf("unexpected header %q", lines[0])
+	}
+	if !strings.HasPrefix(lines[1], "1004,2024-02-12") || !strings.Contains(lines[1], `,-45.5,"Dinner, drinks and ""tips""",,Groceries,Checking`) {
+		t.Fatalf("expected quoted description row first, got %q", lines[1])
+	}
+	if !strings.HasPrefix(lines[3], "1002,") {
+		t.Fatalf("expected oldest February transaction last, got %q", lines[3])
+	}
+
+	if _, err := db.ExportTransactionsCSV(context.Background(), TransactionFilter{StartDate: "2024-03", EndDate: "2024-01"}); err == nil {
+		t.Fatal("expected an error for an inverted date range")
+	}
+}
+
+func TestQueriesRespectCanceledContextWithFixtureDB(t *testing.T) {
+	db := newFixtureDB(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := db.GetAccounts(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("GetAccounts with canceled context: expected context.Canceled, got %v", err)
+	}
+	if _, err := db.GetTransactions(ctx, TransactionFilter{Limit: 10}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("GetTransactions with canceled context: expected context.Canceled, got %v", err)
+	}
+	if _, err := db.AnalyzeSpendingTrends(ctx, SpendingTrendOptions{GroupBy: "month"}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("AnalyzeSpendingTrends with canceled context: expected context.Canceled, got %v", err)
+	}
+}
+
+func TestDetectRecurringTransactionsWithFixtureDB(t *testing.T) {
+	db := newFixtureDBWithExtraRows(t, func(conn *sql.DB) {
+		insertTransaction(t, conn, 2000, 37, -15.99, "2023-11-03", "NETFLIX.COM 1234", 1, 0, 0)
+		insertTransaction(t, conn, 2001, 37, -15.99, "2023-12-03", "Netflix.com 5678", 1, 0, 0)
+		insertTransaction(t, conn, 2002, 37, -16.49, "2024-01-04", "NETFLIX.COM 9012", 1, 0, 0)
+		// Same payee but far off in amount: not part of the series
+		insertTransaction(t, conn, 2003, 37, -60, "2024-01-20", "Netflix.com gift card", 1, 0, 0)
+		// Only two yearly renewals
+		insertTransaction(t, conn, 2004, 37, -99, "2022-03-01", "Cloud storage", 1, 0, 0)
+		insertTransaction(t, conn, 2005, 37, -99, "2023-03-01", "Cloud storage", 1, 0, 0)
+	})
+
+	report, err := db.DetectRecurringTransactions(context.Background(), 0, 0, 0)
+	if err != nil {
+		t.Fatalf("DetectRecurringTransactions: %v", err)
+	}
+	if report.SeriesCount != 1 || len(report.Series) != 1 {
+		t.Fatalf("expected exactly one recurring series, got %+v", report.Series)
+	}
+
+	series := report.Series[0]
+	if series.Payee != "netflix com" || series.Interval != RecurringIntervalMonthly {
+		t.Fatalf("expected monthly netflix series, got %+v", series)
+	}
+	if series.Occurrences != 3 || len(series.TransactionIDs) != 3 || series.TransactionIDs[0] != 2000 {
+		t.Fatalf("expected the three subscription charges in date order, got %+v", series)
+	}
+	assertFloatClose(t, "average amount", series.AverageAmount, (15.99+15.99+16.49)/3, 0.001)
+	if series.LastDate != "2024-01-04" || series.NextExpectedDate != "2024-02-04" {
+		t.Fatalf("unexpected last/next dates %s/%s", series.LastDate, series.NextExpectedDate)
+	}
+	assertFloatClose(t, "USD monthly total", report.MonthlyTotal["USD"], series.MonthlyEquivalent, 0.001)
+	if report.MinOccurrences != 3 || report.AmountTolerancePct != 5 {
+		t.Fatalf("expected the default 3 occurrences and 5%% tolerance, got %d and %v", report.MinOccurrences, report.AmountTolerancePct)
+	}
+
+	// Four occurrences leave out the three netflix charges; two admit the yearly renewals
+	strict, err := db.DetectRecurringTransactions(context.Background(), 0, 4, 0)
+	if err != nil {
+		t.Fatalf("DetectRecurringTransactions(min 4): %v", err)
+	}
+	if strict.SeriesCount != 0 {
+		t.Fatalf("expected no series with 4 occurrences, got %+v", strict.Series)
+	}
+	loose, err := db.DetectRecurringTransactions(context.Background(), 0, 2, 0)
+	if err != nil {
+		t.Fatalf("DetectRecurringTransactions(min 2): %v", err)
+	}
+	if loose.SeriesCount != 2 {
+		t.Fatalf("expected the netflix and yearly series with 2 occurrences, got %+v", loose.Series)
+	}
+
+	// A 1% tolerance splits off the 16.49 charge, leaving two
+	tight, err := db.DetectRecurringTransactions(context.Background(), 0, 0, 1)
+	if err != nil {
+		t.Fatalf("DetectRecurringTransactions(tolerance 1%%): %v", err)
+	}
+	if tight.SeriesCount != 0 {
+		t.Fatalf("expected no series with a 1%% tolerance, got %+v", tight.Series)
+	}
+
+	if _, err := db.DetectRecurringTransactions(context.Background(), 0, 1, 0); !errors.Is(err, ErrInvalidArgument) {
+		t.Fatalf("expected ErrInvalidArgument for one occurrence, got %v", err)
+	}
+	if _, err := db.DetectRecurringTransactions(context.Background(), 0, 0, -5); !errors.Is(err, ErrInvalidArgument) {
+		t.Fatalf("expected ErrInvalidArgument for a negative tolerance, got %v", err)
+	}
+}
+
+func TestGetScheduledTransactionsWithFixtureDB(t *testing.T) {
+	ctx := context.Background()
+
+	// Without scheduled entities, transactions dated after as_of are listed
+	futureDated := newFixtureDBWithExtraRows(t, func(conn *sql.DB) {
+		insertTransaction(t, conn, 2000, 37, -1200, "2024-03-20", "Rent", 1, 0, 101)
+	})
+	defer futureDated.Close()
+
+	report, err := futureDated.GetScheduledTransactions(ctx, 0, "2024-02-10")
+	if err != nil {
+		t.Fatalf("GetScheduledTransactions future-dated: %v", err)
+	}
+	if report.Source != ScheduledSourceFutureDated || report.Count != 1 || report.Note == "" {
+		t.Fatalf("unexpected future-dated report %+v", report)
+	}
+	rent := report.Transactions[0]
+	if rent.ID != 2000 || rent.NextDate != "2024-03-20" || rent.DaysUntil != 39 || rent.CategoryName != "Rent" || rent.AccountName != "Checking" || rent.Currency != "USD" {
+		t.Fatalf("unexpected future-dated rent %+v", rent)
+	}
+	assertFloatClose(t, "future-dated USD spending", report.SpendingByCurrency["USD"], 1200, 0.001)
+
+	scheduled := newFixtureDBWithExtraRows(t, func(conn *sql.DB) {
+		mustExecSQL(t, conn, `
+			CREATE TABLE Z_PRIMARYKEY (Z_ENT INTEGER, Z_NAME TEXT, Z_SUPER INTEGER, Z_MAX INTEGER);
+			INSERT INTO Z_PRIMARYKEY (Z_ENT, Z_NAME) VALUES (10, 'BankChequeAccount'), (60, 'ScheduledTransactionHandler');
+			ALTER TABLE ZSYNCOBJECT ADD COLUMN ZNEXTDATE REAL;
+		`)
+		for _, row := range []struct {
+			id     int64
+			amount float64
+			date   string
+			desc   string
+		}{
+			{3000, -1200, "2024-03-01", "Rent"},
+			{3001, 3000, "2024-02-15", "Salary"},
+			{3002, -99, "2024-06-01", "Insurance"},
+		} {
+			mustExecSQL(t, conn, `
+				INSERT INTO ZSYNCOBJECT (Z_PK, Z_ENT, ZAMOUNT1, ZNEXTDATE, ZDESC2, ZACCOUNT2)
+				VALUES (?, 60, ?, ?, ?, 1);
+			`, row.id, row.amount, coreDataSeconds(t, row.date), row.desc)
+		}
+		mustExecSQL(t, conn, `INSERT INTO ZCATEGORYASSIGMENT (ZTRANSACTION, ZCATEGORY) VALUES (3000, 101)`)
+	})
+	defer scheduled.Close()
+
+	report, err = scheduled.GetScheduledTransactions(ctx, 30, "2024-02-20")
+	if err != nil {
+		t.Fatalf("GetScheduledTransactions: %v", err)
+	}
+	if report.Source != ScheduledSourceEntity || len(report.Entities) != 1 || report.Entities[0] != "ScheduledTransactionHandler" {
+		t.Fatalf("unexpected source %+v", report)
+	}
+	if report.Through != "2024-03-21" || report.Count != 2 || report.OverdueCount != 1 {
+		t.Fatalf("expected the overdue salary and the rent within 30 days, got %+v", report.Transactions)
+	}
+	salary, rent := report.Transactions[0], report.Transactions[1]
+	if salary.ID != 3001 || !salary.Overdue || salary.DaysUntil != -5 {
+		t.Fatalf("unexpected salary %+v", salary)
+	}
+	if rent.ID != 3000 || rent.Overdue || rent.DaysUntil != 10 || rent.CategoryName != "Rent" || rent.Entity != "ScheduledTransactionHandler" {
+		t.Fatalf("unexpected rent %+v", rent)
+	}
+	assertFloatClose(t, "USD income", report.IncomeByCurrency["USD"], 3000, 0.001)
+	assertFloatClose(t, "USD spending", report.SpendingByCurrency["USD"], 1200, 0.001)
+
+	all, err := scheduled.GetScheduledTransactions(ctx, 0, "2024-02-20")
+	if err != nil {
+		t.Fatalf("GetScheduledTransactions without a horizon: %v", err)
+	}
+	if all.Count != 3 || all.Through != "" {
+		t.Fatalf("expected every scheduled transaction without a horizon, got %+v", all)
+	}
+
+	if _, err := scheduled.GetScheduledTransactions(ctx, -1, ""); !errors.Is(err, ErrInvalidArgument) {
+		t.Fatalf("expected ErrInvalidArgument for negative days, got %v", err)
+	}
+}
+
+func TestGetUpcomingBillsWithFixtureDB(t *testing.T) {
+	db := newFixtureDBWithExtraRows(t, func(conn *sql.DB) {
+		insertTransaction(t, conn, 2000, 37, -15.99, "2023-11-04", "NETFLIX.COM", 1, 0, 0)
+		insertTransaction(t, conn, 2001, 37, -15.99, "2023-12-04", "NETFLIX.COM", 1, 0, 0)
+		insertTransaction(t, conn, 2002, 37, -15.99, "2024-01-04", "NETFLIX.COM", 1, 0, 0)
+		insertTransaction(t, conn, 2003, 37, -40, "2023-12-01", "City Gym", 1, 0, 0)
+		insertTransaction(t, conn, 2004, 37, -40, "2024-01-01", "City Gym", 1, 0, 0)
+		insertTransaction(t, conn, 2005, 37, -40, "2024-02-01", "City Gym", 1, 0, 0)
+	})
+	defer db.Close()
+	ctx := context.Background()
+
+	report, err := db.GetUpcomingBills(ctx, 30, 0, "2024-02-10")
+	if err != nil {
+		t.Fatalf("GetUpcomingBills: %v", err)
+	}
+	if report.Through != "2024-03-11" || report.BillCount != 3 || report.OverdueCount != 1 || report.LapsedCount != 0 {
+		t.Fatalf("expected the missed netflix charge and two upcoming bills, got %+v", report)
+	}
+	overdue := report.Bills[0]
+	if overdue.Payee != "netflix com" || !overdue.Overdue || overdue.DueDate != "2024-02-04" || overdue.DaysUntilDue != -6 {
+		t.Fatalf("expected the overdue netflix charge first, got %+v", overdue)
+	}
+	if gym := report.Bills[1]; gym.Payee != "city gym" || gym.Overdue || gym.DueDate != "2024-03-01" || gym.DaysUntilDue != 20 {
+		t.Fatalf("expected the gym due on 2024-03-01, got %+v", gym)
+	}
+	if next := report.Bills[2]; next.Payee != "netflix com" || next.DueDate != "2024-03-04" {
+		t.Fatalf("expected the next netflix charge, got %+v", next)
+	}
+	assertFloatClose(t, "USD total", report.TotalByCurrency["USD"], 15.99*2+40, 0.001)
+
+	// By mid-March netflix has missed two charges and is assumed cancelled
+	later, err := db.GetUpcomingBills(ctx, 10, 0, "2024-03-10")
+	if err != nil {
+		t.Fatalf("GetUpcomingBills later: %v", err)
+	}
+	if later.LapsedCount != 1 || later.BillCount != 1 || later.Bills[0].Payee != "city gym" || !later.Bills[0].Overdue {
+		t.Fatalf("expected only the overdue gym with netflix lapsed, got %+v", later)
+	}
+
+	if _, err := db.GetUpcomingBills(ctx, 30, 0, "10/02/2024"); !errors.Is(err, ErrInvalidArgument) {
+		t.Fatalf("invalid as_of = %v, want ErrInvalidArgument", err)
+	}
+}
+
+func TestForecastCashFlowWithFixtureDB(t *testing.T) {
+	db := newFixtureDBWithExtraRows(t, func(conn *sql.DB) {
+		insertTransaction(t, conn, 2000, 37, -10, "2023-12-03", "Music subscription", 1, 0, 0)
+		insertTransaction(t, conn, 2001, 37, -10, "2024-01-03", "Music subscription", 1, 0, 0)
+		insertTransaction(t, conn, 2002, 37, -10, "2024-02-03", "Music subscription", 1, 0, 0)
+	})
+	ctx := context.Background()
+
+	forecast, err := db.ForecastCashFlow(ctx, 3, 6)
+	if err != nil {
+		t.Fatalf("ForecastCashFlow: %v", err)
+	}
+	netWorth, err := db.CalculateNetWorth(ctx, AccountFilter{})
+	if err != nil {
+		t.Fatalf("CalculateNetWorth: %v", err)
+	}
+
+	if forecast.LowConfidence || forecast.MonthsWithData != 3 {
+		t.Fatalf("expected 3 months of history and normal confidence, got %+v", forecast)
+	}
+	if len(forecast.RecurringSpending) != 1 || forecast.RecurringSpending[0].Payee != "music subscription" {
+		t.Fatalf("expected the subscription as recurring spending, got %+v", forecast.RecurringSpending)
+	}
+	if len(forecast.Projections) != 3 || forecast.Projections[0].Month != "2024-03" {
+		t.Fatalf("expected projections for 2024-03..2024-05, got %+v", forecast.Projections)
+	}
+
+	first := forecast.Projections[0]
+	assertFloatClose(t, "recurring spending", first.RecurringSpending, 10, 0.001)
+	assertFloatClose(t, "projected spending", first.ProjectedSpending, 1500.0/3, 0.001)
+	assertFloatClose(t, "projected income", first.ProjectedIncome, 5500.0/3, 0.001)
+	assertFloatClose(t, "net savings", first.NetSavings, 5500.0/3-1500.0/3-10, 0.001)
+	assertFloatClose(t, "end balance", forecast.EndBalance, netWorth.NetWorth+3*first.NetSavings, 0.001)
+}
+
+func TestForecastCashFlowLowConfidenceWithFixtureDB(t *testing.T) {
+	db := newFixtureDBWithExtraRows(t, func(conn *sql.DB) {
+		mustExecSQL(t, conn, `DELETE FROM ZSYNCOBJECT WHERE Z_PK IN (1000, 1001)`)
+	})
+
+	forecast, err := db.ForecastCashFlow(context.Background(), 2, 6)
+	if err != nil {
+		t.Fatalf("ForecastCashFlow: %v", err)
+	}
+	if !forecast.LowConfidence || forecast.ConfidenceNote == "" {
+		t.Fatalf("expected a low-confidence forecast from a single month of history, got %+v", forecast)
+	}
+}
+
+func TestForecastCashFlowClampsMonthsWithFixtureDB(t *testing.T) {
+	db := newFixtureDB(t)
+	defer db.Close()
+
+	forecast, err := db.ForecastCashFlow(context.Background(), 100000, 100000)
+	if err != nil {
+		t.Fatalf("ForecastCashFlow: %v", err)
+	}
+	if forecast.Months != MaxForecastMonths || len(forecast.Projections) != MaxForecastMonths {
+		t.Fatalf("months = %d, projections = %d, want %d", forecast.Months, len(forecast.Projections), MaxForecastMonths)
+	}
+	if forecast.HistoryMonths != MaxForecastHistoryMonths {
+		t.Fatalf("history months = %d, want %d", forecast.HistoryMonths, MaxForecastHistoryMonths)
+	}
+}
+
+func TestFiscalYearStartShiftsYearBucketsWithFixtureDB(t *testing.T) {
+	db := newFixtureDBWithExtraRows(t, func(conn *sql.DB) {
+		insertTransaction(t, conn, 1004, 37, -50, "2024-04-10", "Groceries", 1, 0, 102)
+	})
+	ctx := context.Background()
+
+	calendar, err := db.AnalyzeSpendingTrends(ctx, SpendingTrendOptions{GroupBy: "year"})
+	if err != nil {
+		t.Fatalf("AnalyzeSpendingTrends: %v", err)
+	}
+	if len(calendar) != 1 || calendar[0].Period != "2024" {
+		t.Fatalf("expected one calendar year by default, got %+v", calendar)
+	}
+
+	db.fiscalYearStart = time.April
+	trends, err := db.AnalyzeSpendingTrends(ctx, SpendingTrendOptions{GroupBy: "year"})
+	if err != nil {
+		t.Fatalf("AnalyzeSpendingTrends: %v", err)
+	}
+	if len(trends) != 2 || trends[0].Period != "FY2024" || trends[1].Period != "FY2025" {
+		t.Fatalf("expected FY2024 and FY2025, got %+v", trends)
+	}
+	assertFloatClose(t, "FY2024 spending", trends[0].TotalSpending, 1500, 0.001)
+	assertFloatClose(t, "FY2025 spending", trends[1].TotalSpending, 50, 0.001)
+	if len(trends[1].CategoryDeltas) == 0 {
+		t.Fatalf("expected FY2025 to be compared with FY2024, got %+v", trends[1])
+	}
+
+	stats, err := db.GetFinancialStats(ctx, false)
+	if err != nil {
+		t.Fatalf("GetFinancialStats: %v", err)
+	}
+	if stats.FiscalYearStart != "April" {
+		t.Fatalf("fiscal year start = %q, want April", stats.FiscalYearStart)
+	}
+	if _, ok := stats.ByYear["FY2024"]; !ok || len(stats.ByYear) != 2 {
+		t.Fatalf("expected FY2024 and FY2025 in by_year, got %+v", stats.ByYear)
+	}
+	assertFloatClose(t, "FY2024 income", stats.ByYear["FY2024"].Income, 5500, 0.001)
+
+	savings, err := db.AnalyzeNetSavingsTrend(ctx, "year", 0, false, false)
+	if err != nil {
+		t.Fatalf("AnalyzeNetSavingsTrend: %v", err)
+	}
+	if len(savings) != 2 || savings[1].Period != "FY2025" {
+		t.Fatalf("expected FY2024 and FY2025 net savings, got %+v", savings)
+	}
+}
+
+func TestAnalyzeSpendingByWeekdayWithFixtureDB(t *testing.T) {
+	db := newFixtureDBWithExtraRows(t, func(conn *sql.DB) {
+		insertTransaction(t, conn, 2000, 37, -100, "2024-02-03", "Groceries", 1, 0, 102)
+		insertTransaction(t, conn, 2001, 37, -60, "2024-02-04", "Groceries", 1, 0, 102)
+	})
+	defer db.Close()
+
+	got, err := db.AnalyzeSpendingByWeekday(context.Background(), 0)
+	if err != nil {
+		t.Fatalf("AnalyzeSpendingByWeekday: %v", err)
+	}
+
+	// 2024-01-20 is a Saturday (rent), 2024-02-03 a Saturday, 2024-02-04 a Sunday, 2024-02-10 a Saturday
+	if len(got.Weekdays) != 7 || got.Weekdays[0].Weekday != "Monday" || got.Weekdays[6].Weekday != "Sunday" {
+		t.Fatalf("expected Monday to Sunday, got %+v", got.Weekdays)
+	}
+	saturday := got.Weekdays[5]
+	if saturday.TransactionCount != 3 || got.BusiestDay != "Saturday" {
+		t.Fatalf("expected three Saturday expenses as the busiest day, got %+v (busiest %q)", saturday, got.BusiestDay)
+	}
+	assertFloatClose(t, "saturday total", saturday.TotalSpending, 1600, 0.001)
+	if got.StartDate != "2024-01-20" || got.EndDate != "2024-02-10" || saturday.Days != 4 {
+		t.Fatalf("expected four Saturdays from 2024-01-20 to 2024-02-10, got %s..%s with %d", got.StartDate, got.EndDate, saturday.Days)
+	}
+	assertFloatClose(t, "saturday average daily", saturday.AverageDaily, 400, 0.001)
+	assertFloatClose(t, "weekend spending", got.WeekendSpending, 1660, 0.001)
+	assertFloatClose(t, "weekend share", got.WeekendSharePercent, 100, 0.001)
+	if got.Weekdays[0].TransactionCount != 0 || got.Weekdays[0].Days != 3 {
+		t.Fatalf("expected three quiet Mondays, got %+v", got.Weekdays[0])
+	}
+}
+
+func TestDetectDuplicateTransactionsWithFixtureDB(t *testing.T) {
+	db := newFixtureDBWithExtraRows(t, func(conn *sql.DB) {
+		mustExecSQL(t, conn, `
+			INSERT INTO ZSYNCOBJECT (Z_PK, Z_ENT, ZNAME, ZBALLANCE, ZOPENINGBALANCE, ZCURRENCYNAME, ZTYPE)
+			VALUES (2, 10, 'Savings', 0, 0, 'USD', 'bank');
+		`)
+		// Rent imported again the next day, and once more on the same day
+		insertTransaction(t, conn, 2000, 37, -1200, "2024-01-21", "rent payment ", 1, 0, 101)
+		insertTransaction(t, conn, 2001, 37, -1200, "2024-01-20", "Rent payment", 1, 0, 101)
+		// Same amount and description in another account, and a different amount
+		insertTransaction(t, conn, 2002, 37, -1200, "2024-01-20", "Rent payment", 2, 0, 101)
+		insertTransaction(t, conn, 2003, 37, -1199, "2024-01-20", "Rent payment", 1, 0, 101)
+		// Salary imported twice on the same day
+		insertTransaction(t, conn, 2004, 37, 2500, "2024-02-05", "February salary", 1, 0, 100)
+	})
+	defer db.Close()
+	ctx := context.Background()
+
+	sameDay, err := db.DetectDuplicateTransactions(ctx, 0, 0)
+	if err != nil {
+		t.Fatalf("DetectDuplicateTransactions: %v", err)
+	}
+	if sameDay.GroupCount != 2 || sameDay.DuplicateCount != 2 {
+		t.Fatalf("expected the same-day rent and salary pairs, got %+v", sameDay.Groups)
+	}
+	salary := sameDay.Groups[0]
+	if salary.Description != "February salary" || salary.Count != 2 || salary.Transactions[0].ID != 1002 || salary.Transactions[1].ID != 2004 {
+		t.Fatalf("expected the salary pair first, got %+v", salary)
+	}
+	assertFloatClose(t, "salary extra amount", salary.ExtraAmount, 2500, 0.001)
+	if rent := sameDay.Groups[1]; rent.Count != 2 || rent.DaysApart != 0 || rent.AccountName != "Checking" {
+		t.Fatalf("expected the same-day rent pair in checking, got %+v", rent)
+	}
+
+	nextDay, err := db.DetectDuplicateTransactions(ctx, 0, 1)
+	if err != nil {
+		t.Fatalf("DetectDuplicateTransactions with tolerance: %v", err)
+	}
+	rent := nextDay.Groups[1]
+	if rent.Count != 3 || rent.DaysApart != 1 || rent.LastDate != "2024-01-21 00:00:00" {
+		t.Fatalf("expected three rent copies over two days, got %+v", rent)
+	}
+	assertFloatClose(t, "rent extra amount", rent.ExtraAmount, -2400, 0.001)
+
+	if _, err := db.DetectDuplicateTransactions(ctx, 0, 32); !errors.Is(err, ErrInvalidArgument) {
+		t.Fatalf("tolerance of 32 days = %v, want ErrInvalidArgument", err)
+	}
+}
+
+func TestDiagnoseCategoryAssignmentsWithFixtureDB(t *testing.T) {
+	db := newFixtureDBWithExtraRows(t, func(conn *sql.DB) {
+		// A split without portions, an assignment to a missing category, an uncategorized
+		// withdrawal and an assignment left behind by a deleted transaction
+		insertTransaction(t, conn, 2000, 37, -150, "2024-02-12", "Store run", 1, 0, 102)
+		mustExecSQL(t, conn, `INSERT INTO ZCATEGORYASSIGMENT (ZTRANSACTION, ZCATEGORY) VALUES (2000, 101)`)
+		insertTransaction(t, conn, 2001, 37, -40, "2024-02-14", "Pharmacy", 1, 0, 999)
+		insertUncategorizedTransaction(t, conn, 2002, 37, -60, "2024-02-15", "ATM withdrawal", 1, 0)
+		mustExecSQL(t, conn, `INSERT INTO ZCATEGORYASSIGMENT (ZTRANSACTION, ZCATEGORY) VALUES (9999, 102)`)
+	})
+	ctx := context.Background()
+
+	diagnostics, err := db.DiagnoseCategoryAssignments(ctx, 0)
+	if err != nil {
+		t.Fatalf("DiagnoseCategoryAssignments: %v", err)
+	}
+	if diagnostics.TransactionCount != 7 || diagnostics.SingleCategory != 4 || diagnostics.MultipleCategories != 1 || diagnostics.Uncategorized != 2 {
+		t.Fatalf("unexpected counts %+v", diagnostics)
+	}
+	if diagnostics.UncategorizedMovements != 1 || diagnostics.MaxCategories != 2 {
+		t.Fatalf("unexpected movement and max counts %+v", diagnostics)
+	}
+	if diagnostics.BrokenAssignments != 1 || len(diagnostics.BrokenTransactionIDs) != 1 || diagnostics.BrokenTransactionIDs[0] != 2001 {
+		t.Fatalf("expected transaction 2001 to have a broken assignment, got %+v", diagnostics)
+	}
+	if diagnostics.OrphanAssignments != 1 {
+		t.Fatalf("orphan assignments = %d, want 1", diagnostics.OrphanAssignments)
+	}
+	if diagnostics.SplitAmountColumn != "" || diagnostics.SplitAmountMismatches != 0 {
+		t.Fatalf("expected no split portions without the column, got %+v", diagnostics)
+	}
+	if len(diagnostics.Notes) != 3 {
+		t.Fatalf("expected notes on the broken, unportioned and orphan assignments, got %q", diagnostics.Notes)
+	}
+
+	// Portions that add up pass; portions that do not are reported
+	split := newFixtureDBWithExtraRows(t, func(conn *sql.DB) {
+		mustExecSQL(t, conn, `ALTER TABLE ZCATEGORYASSIGMENT ADD COLUMN ZAMOUNT REAL`)
+		insertUncategorizedTransaction(t, conn, 2000, 37, -150, "2024-02-12", "Store run", 1, 0)
+		insertUncategorizedTransaction(t, conn, 2001, 37, -90, "2024-02-13", "Market", 1, 0)
+		mustExecSQL(t, conn, `
+			INSERT INTO ZCATEGORYASSIGMENT (ZTRANSACTION, ZCATEGORY, ZAMOUNT)
+			VALUES (2000, 102, -100), (2000, 101, -50), (2001, 102, -60), (2001, 101, -60);
+		`)
+	})
+	diagnostics, err = split.DiagnoseCategoryAssignments(ctx, 0)
+	if err != nil {
+		t.Fatalf("DiagnoseCategoryAssignments with portions: %v", err)
+	}
+	if diagnostics.SplitAmountColumn != "ZAMOUNT" || diagnostics.MultipleCategories != 2 || diagnostics.SplitAmountMismatches != 1 {
+		t.Fatalf("unexpected split diagnostics %+v", diagnostics)
+	}
+	if len(diagnostics.SplitMismatchTransactionIDs) != 1 || diagnostics.SplitMismatchTransactionIDs[0] != 2001 {
+		t.Fatalf("expected transaction 2001 to be reported, got %v", diagnostics.SplitMismatchTransactionIDs)
+	}
+}
+
+func TestSplitTransactionsAttributePortionsToCategoriesWithFixtureDB(t *testing.T) {
+	db := newFixtureDBWithExtraRows(t, func(conn *sql.DB) {
+		mustExecSQL(t, conn, `ALTER TABLE ZCATEGORYASSIGMENT ADD COLUMN ZAMOUNT REAL`)
+		// One store run split between groceries and rent
+		mustExecSQL(t, conn, `
+			INSERT INTO ZSYNCOBJECT (Z_PK, Z_ENT, ZAMOUNT1, ZDATE1, ZDESC2, ZACCOUNT2, ZACCOUNT)
+			VALUES (2000, 37, -150, ?, 'Store run', 1, 0);
+		`, coreDataSeconds(t, "2024-02-12"))
+		mustExecSQL(t, conn, `
+			INSERT INTO ZCATEGORYASSIGMENT (ZTRANSACTION, ZCATEGORY, ZAMOUNT)
+			VALUES (2000, 102, -100), (2000, 101, -50);
+		`)
+	})
+	defer db.Close()
+	ctx := context.Background()
+
+	spending, err := db.GetSpendingData(ctx, 0, false, nil, false)
+	if err != nil {
+		t.Fatalf("GetSpendingData: %v", err)
+	}
+	portions := make(map[string]float64)
+	total := 0.0
+	for _, s := range spending {
+		total += s.Amount
+		if s.TransactionID == 2000 {
+			portions[s.CategoryName] += s.Amount
+		}
+	}
+	assertFloatClose(t, "split groceries portion", portions["Groceries"], 100, 0.001)
+	assertFloatClose(t, "split rent portion", portions["Rent"], 50, 0.001)
+	// Unsplit transactions keep their full amount
+	assertFloatClose(t, "total spending", total, 1200+300+150, 0.001)
+
+	rent, err := db.GetSpendingData(ctx, 0, false, []int64{101}, false)
+	if err != nil {
+		t.Fatalf("GetSpendingData for rent: %v", err)
+	}
+	rentTotal := 0.0
+	for _, s := range rent {
+		rentTotal += s.Amount
+	}
+	assertFloatClose(t, "rent total", rentTotal, 1250, 0.001)
+}
+
+func TestSplitTransactionsWithoutPortionsCountOnceWithFixtureDB(t *testing.T) {
+	db := newFixtureDBWithExtraRows(t, func(conn *sql.DB) {
+		insertTransaction(t, conn, 2000, 37, -150, "2024-02-12", "Store run", 1, 0, 102)
+		mustExecSQL(t, conn, `INSERT INTO ZCATEGORYASSIGMENT (ZTRANSACTION, ZCATEGORY) VALUES (2000, 101)`)
+	})
+	defer db.Close()
+
+	spending, err := db.GetSpendingData(context.Background(), 0, false, nil, false)
+	if err != nil {
+		t.Fatalf("GetSpendingData: %v", err)
+	}
+	rows := 0
+	for _, s := range spending {
+		if s.TransactionID == 2000 {
+			rows++
+			if s.CategoryID != 101 || s.Amount != 150 {
+				t.Fatalf("expected the whole amount under the first category, got %+v", s)
+			}
+		}
+	}
+	if rows != 1 {
+		t.Fatalf("expected the split transaction once without stored portions, got %d rows", rows)
+	}
+
+	// Groceries is not the first category, but the transaction is still assigned to it
+	groceries, err := db.GetSpendingData(context.Background(), 0, false, []int64{102}, false)
+	if err != nil {
+		t.Fatalf("GetSpendingData for groceries: %v", err)
+	}
+	found := false
+	for _, s := range groceries {
+		if s.TransactionID == 2000 {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("expected the split transaction when filtering by its second category, got %+v", groceries)
+	}
+}
+
+func TestSplitTransactionsAreListedCountedAndExportedByPortionWithFixtureDB(t *testing.T) {
+	db := newFixtureDBWithExtraRows(t, func(conn *sql.DB) {
+		mustExecSQL(t, conn, `ALTER TABLE ZCATEGORYASSIGMENT ADD COLUMN ZAMOUNT REAL`)
+		mustExecSQL(t, conn, `
+			INSERT INTO ZSYNCOBJECT (Z_PK, Z_ENT, ZAMOUNT1, ZDATE1, ZDESC2, ZACCOUNT2, ZACCOUNT)
+			VALUES (2000, 37, -150, ?, 'Store run', 1, 0);
+		`, coreDataSeconds(t, "2024-02-12"))
+		mustExecSQL(t, conn, `
+			INSERT INTO ZCATEGORYASSIGMENT (ZTRANSACTION, ZCATEGORY, ZAMOUNT)
+			VALUES (2000, 102, -100), (2000, 101, -50);
+		`)
+	})
+	defer db.Close()
+	ctx := context.Background()
+	filter := TransactionFilter{StartDate: "2024-02"}
+
+	transactions, err := db.GetTransactions(ctx, filter)
+	if err != nil {
+		t.Fatalf("GetTransactions: %v", err)
+	}
+	portions := make(map[string]float64)
+	for _, txn := range transactions {
+		if txn.ID == 2000 {
+			portions[txn.CategoryName] += txn.Amount
+		}
+	}
+	if len(portions) != 2 {
+		t.Fatalf("expected the split transaction once per category, got %v", portions)
+	}
+	assertFloatClose(t, "listed groceries portion", portions["Groceries"], -100, 0.001)
+	assertFloatClose(t, "listed rent portion", portions["Rent"], -50, 0.001)
+
+	count, err := db.CountTransactions(ctx, filter)
+	if err != nil {
+		t.Fatalf("CountTransactions: %v", err)
+	}
+	if count != len(transactions) {
+		t.Fatalf("expected the count to match the %d listed rows, got %d", len(transactions), count)
+	}
+
+	csvText, err := db.ExportTransactionsCSV(ctx, filter)
+	if err != nil {
+		t.Fatalf("ExportTransactionsCSV: %v", err)
+	}
+	if !strings.Contains(csvText, ",-100,Store run,,Groceries,Checking") || !strings.Contains(csvText, ",-50,Store run,,Rent,Checking") {
+		t.Fatalf("expected one CSV row per split portion, got %q", csvText)
+	}
+	if strings.Contains(csvText, ",-150,") {
+		t.Fatalf("expected no CSV row with the whole split amount, got %q", csvText)
+	}
+
+	var buf bytes.Buffer
+	written, err := db.WriteTransactionsJSONL(ctx, filter, &buf)
+	if err != nil {
+		t.Fatalf("WriteTransactionsJSONL: %v", err)
+	}
+	if written != len(transactions) || strings.Contains(buf.String(), `"amount":-150`) {
+		t.Fatalf("expected %d JSON lines carrying the split portions, got %d: %s", len(transactions), written, buf.String())
+	}
+}
+
+func TestSplitTransactionsWithoutPortionsAreListedOnceWithFixtureDB(t *testing.T) {
+	db := newFixtureDBWithExtraRows(t, func(conn *sql.DB) {
+		insertTransaction(t, conn, 2000, 37, -150, "2024-02-12", "Store run", 1, 0, 102)
+		mustExecSQL(t, conn, `INSERT INTO ZCATEGORYASSIGMENT (ZTRANSACTION, ZCATEGORY) VALUES (2000, 101)`)
+	})
+	defer db.Close()
+	ctx := context.Background()
+	filter := TransactionFilter{StartDate: "2024-02"}
+
+	transactions, err := db.GetTransactions(ctx, filter)
+	if err != nil {
+		t.Fatalf("GetTransactions: %v", err)
+	}
+	rows := 0
+	for _, txn := range transactions {
+		if txn.ID == 2000 {
+			rows++
+			if txn.CategoryID != 101 || txn.Amount != -150 {
+				t.Fatalf("expected the whole amount under the first category, got %+v", txn)
+			}
+		}
+	}
+	if rows != 1 {
+		t.Fatalf("expected the split transaction once without stored portions, got %d rows", rows)
+	}
+
+	count, err := db.CountTransactions(ctx, filter)
+	if err != nil {
+		t.Fatalf("CountTransactions: %v", err)
+	}
+	if count != 3 {
+		t.Fatalf("expected 3 February transactions, got %d", count)
+	}
+}
+
+func TestAnalyzeIncomeSourcesWithFixtureDB(t *testing.T) {
+	db := newFixtureDBWithExtraRows(t, func(conn *sql.DB) {
+		insertUncategorizedTransaction(t, conn, 2000, 37, 500, "2024-02-08", "Freelance invoice", 1, 0)
+		// Money back for groceries is a refund, not an income source
+		insertTransaction(t, conn, 2001, 37, 20, "2024-02-11", "Groceries refund", 1, 0, 102)
+	})
+	defer db.Close()
+	ctx := context.Background()
+
+	byCategory, err := db.AnalyzeIncomeSources(ctx, 0, "", 0)
+	if err != nil {
+		t.Fatalf("AnalyzeIncomeSources: %v", err)
+	}
+	assertFloatClose(t, "total income", byCategory.TotalIncome, 6000, 0.001)
+	if byCategory.GroupBy != IncomeSourceByCategory || byCategory.SourceCount != 2 || byCategory.TopSource != "Salary" {
+		t.Fatalf("expected salary and uncategorized income, got %+v", byCategory.Sources)
+	}
+	assertFloatClose(t, "salary share", byCategory.TopSharePercent, 5500.0/6000*100, 0.001)
+	if !byCategory.ConcentrationRisk || !strings.Contains(byCategory.ConcentrationWarning, "Salary") {
+		t.Fatalf("expected a concentration risk above 80%%, got %+v", byCategory)
+	}
+	assertFloatClose(t, "effective sources", byCategory.EffectiveNumberOfSources, 1/(0.9166667*0.9166667+0.0833333*0.0833333), 0.001)
+
+	relaxed, err := db.AnalyzeIncomeSources(ctx, 0, IncomeSourceByCategory, 95)
+	if err != nil {
+		t.Fatalf("AnalyzeIncomeSources with threshold: %v", err)
+	}
+	if relaxed.ConcentrationRisk || relaxed.ConcentrationWarning != "" {
+		t.Fatalf("expected no risk below a 95%% threshold, got %+v", relaxed)
+	}
+
+	byPayee, err := db.AnalyzeIncomeSources(ctx, 0, IncomeSourceByPayee, 0)
+	if err != nil {
+		t.Fatalf("AnalyzeIncomeSources by payee: %v", err)
+	}
+	if byPayee.SourceCount != 3 || byPayee.TopSource != "January salary" || byPayee.ConcentrationRisk {
+		t.Fatalf("expected three payees led by the January salary, got %+v", byPayee.Sources)
+	}
+
+	if _, err := db.AnalyzeIncomeSources(ctx, 0, "account", 0); !errors.Is(err, ErrInvalidArgument) {
+		t.Fatalf("invalid group_by = %v, want ErrInvalidArgument", err)
+	}
+}
+
+func TestUncategorizedLabelWithFixtureDB(t *testing.T) {
+	db := newFixtureDBWithExtraRows(t, func(conn *sql.DB) {
+		insertUncategorizedTransaction(t, conn, 2000, 37, -50, "2024-02-12", "Coffee shop", 1, 0)
+		insertUncategorizedTransaction(t, conn, 2001, 37, -100, "2024-02-15", "Transfer to Savings", 1, 0)
+	})
+	defer db.Close()
+	db.uncategorizedLabel = "Sans catégorie"
+
+	spending, err := db.GetSpendingData(context.Background(), 0, true, nil, false)
+	if err != nil {
+		t.Fatalf("GetSpendingData: %v", err)
+	}
+	names := make(map[int64]string, len(spending))
+	for _, s := range spending {
+		names[s.TransactionID] = s.CategoryName
+	}
+	if names[2000] != "Sans catégorie" || names[2001] != "Internal Transfer" || names[1001] != "Rent" {
+		t.Fatalf("category names = %v, want the custom label only for the uncategorized expense", names)
+	}
+}
+
+func TestNewDBRejectsInvalidFiscalYearStart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "moneywiz.sqlite")
+	if _, err := NewDB(path, Options{FiscalYearStart: 13}); !errors.Is(err, ErrInvalidArgument) {
+		t.Fatalf("NewDB with fiscal year start 13 = %v, want ErrInvalidArgument", err)
+	}
+}
+
+func TestAnalyzeSpendingTrendsCategoryFilterWithFixtureDB(t *testing.T) {
+	db := newFixtureDB(t)
+	ctx := context.Background()
+
+	trends, err := db.AnalyzeSpendingTrends(ctx, SpendingTrendOptions{GroupBy: "month", CategoryIDs: []int64{102}})
+	if err != nil {
+		t.Fatalf("AnalyzeSpendingTrends: %v", err)
+	}
+	if len(trends) != 1 || trends[0].Period != "2024-02" {
+		t.Fatalf("expected only February groceries, got %+v", trends)
+	}
+	assertFloatClose(t, "groceries total", trends[0].TotalSpending, 300, 0.001)
+	if _, ok := trends[0].ByCategory["Rent"]; ok {
+		t.Fatalf("expected rent to be filtered out, got %+v", trends[0].ByCategory)
+	}
+
+	both, err := db.GetSpendingData(ctx, 0, false, []int64{101, 102}, false)
+	if err != nil {
+		t.Fatalf("GetSpendingData: %v", err)
+	}
+	all, err := db.GetSpendingData(ctx, 0, false, nil, false)
+	if err != nil {
+		t.Fatalf("GetSpendingData: %v", err)
+	}
+	if len(both) != 2 || len(all) != 2 {
+		t.Fatalf("expected rent and groceries with and without the filter, got %d and %d rows", len(both), len(all))
+	}
+}
+
+func TestGetAccountBalanceHistoryWithFixtureDB(t *testing.T) {
+	db := newFixtureDBWithExtraRows(t, func(conn *sql.DB) {
+		insertUncategorizedTransaction(t, conn, 2000, 43, -500, "2024-04-02", "Transfer to savings", 1, 0)
+	})
+	ctx := context.Background()
+
+	history, err := db.GetAccountBalanceHistory(ctx, 1, 0)
+	if err != nil {
+		t.Fatalf("GetAccountBalanceHistory: %v", err)
+	}
+	if history.AccountName != "Checking" || history.Currency != "USD" {
+		t.Fatalf("unexpected account details %+v", history)
+	}
+	assertFloatClose(t, "opening balance", history.OpeningBalance, 1000, 0.001)
+
+	want := []BalancePoint{
+		{Month: "2024-01", Balance: 2800, Change: 1800},
+		{Month: "2024-02", Balance: 5000, Change: 2200},
+		{Month: "2024-03", Balance: 5000, Change: 0},
+		{Month: "2024-04", Balance: 4500, Change: -500},
+	}
+	if len(history.Points) != len(want) {
+		t.Fatalf("expected %d points, got %+v", len(want), history.Points)
+	}
+	for i, point := range history.Points {
+		if point.Month != want[i].Month {
+			t.Fatalf("point %d month = %s, want %s", i, point.Month, want[i].Month)
+		}
+		assertFloatClose(t, point.Month+" balance", point.Balance, want[i].Balance, 0.001)
+		assertFloatClose(t, point.Month+" change", point.Change, want[i].Change, 0.001)
+	}
+
+	account, err := db.GetAccountBalance(ctx, 1)
+	if err != nil {
+		t.Fatalf("GetAccountBalance: %v", err)
+	}
+	assertFloatClose(t, "current balance", history.CurrentBalance, account.Balance, 0.001)
+
+	recent, err := db.GetAccountBalanceHistory(ctx, 1, 2)
+	if err != nil {
+		t.Fatalf("GetAccountBalanceHistory(months=2): %v", err)
+	}
+	if len(recent.Points) != 2 || recent.Points[0].Month != "2024-03" {
+		t.Fatalf("expected the last two month-ends, got %+v", recent.Points)
+	}
+
+	if _, err := db.GetAccountBalanceHistory(ctx, 999, 0); err == nil {
+		t.Fatal("expected an error for an unknown account")
+	}
+}
+
+func TestGetDormantAccountsWithFixtureDB(t *testing.T) {
+	db := newFixtureDBWithExtraRows(t, func(conn *sql.DB) {
+		mustExecSQL(t, conn, `
+			INSERT INTO ZSYNCOBJECT (Z_PK, Z_ENT, ZNAME, ZBALLANCE, ZOPENINGBALANCE, ZCURRENCYNAME, ZTYPE)
+			VALUES
+				(2, 12, 'Old Wallet', 0, 0, 'USD', 'cash'),
+				(3, 10, 'Travel Card', 0, 100, 'EUR', 'bank');
+		`)
+		insertTransaction(t, conn, 2000, 37, -40, "2024-06-01", "Museum", 3, 0, 102)
+	})
+	defer db.Close()
+	ctx := context.Background()
+
+	report, err := db.GetDormantAccounts(ctx, 90, "2024-07-01")
+	if err != nil {
+		t.Fatalf("GetDormantAccounts: %v", err)
+	}
+	if report.AccountCount != 3 || report.DormantCount != 2 || report.NeverUsedCount != 1 {
+		t.Fatalf("unexpected counts %+v", report)
+	}
+	wallet, checking := report.Accounts[0], report.Accounts[1]
+	if wallet.ID != 2 || !wallet.NeverUsed || wallet.DaysSinceLastTransaction != nil || !wallet.ZeroBalance {
+		t.Fatalf("expected the never used wallet first, got %+v", wallet)
+	}
+	if checking.ID != 1 || checking.DaysSinceLastTransaction == nil || *checking.DaysSinceLastTransaction != 142 {
+		t.Fatalf("expected checking idle for 142 days, got %+v", checking)
+	}
+	if checking.LastTransactionDate[:10] != "2024-02-10" || checking.ZeroBalance {
+		t.Fatalf("unexpected checking details %+v", checking)
+	}
+	assertFloatClose(t, "dormant usd balance", report.BalanceByCurrency["USD"], 5000, 0.001)
+	if _, ok := report.BalanceByCurrency["EUR"]; ok {
+		t.Fatalf("the recently used EUR account should not be dormant: %+v", report.BalanceByCurrency)
+	}
+
+	// The default threshold is 180 days
+	report, err = db.GetDormantAccounts(ctx, 0, "2024-07-01")
+	if err != nil {
+		t.Fatalf("GetDormantAccounts with the default days: %v", err)
+	}
+	if report.Days != 180 || report.DormantCount != 1 {
+		t.Fatalf("expected only the never used wallet after 180 days, got %+v", report)
+	}
+
+	if _, err := db.GetDormantAccounts(ctx, -1, ""); !errors.Is(err, ErrInvalidArgument) {
+		t.Fatalf("negative days error = %v, want ErrInvalidArgument", err)
+	}
+	if _, err := db.GetDormantAccounts(ctx, 30, "July"); !errors.Is(err, ErrInvalidArgument) {
+		t.Fatalf("invalid as_of error = %v, want ErrInvalidArgument", err)
+	}
+}
+
+func TestGetAccountBalanceAsOfWithFixtureDB(t *testing.T) {
+	db := newFixtureDBWithExtraRows(t, func(conn *sql.DB) {
+		insertUncategorizedTransaction(t, conn, 2000, 43, -500, "2024-04-02", "Transfer to savings", 1, 0)
+	})
+	ctx := context.Background()
+
+	tests := []struct {
+		date    string
+		balance float64
+		count   int
+	}{
+		{date: "2024-01-01", balance: 1000, count: 0},
+		{date: "2024-01-20", balance: 2800, count: 2}, // Rent on the day itself is included
+		{date: "2024-02-29", balance: 5000, count: 4},
+		{date: "2024-04-02", balance: 4500, count: 5},
+	}
+	for _, tt := range tests {
+		asOf, err := db.GetAccountBalanceAsOf(ctx, 1, tt.date)
+		if err != nil {
+			t.Fatalf("GetAccountBalanceAsOf(%s): %v", tt.date, err)
+		}
+		if asOf.Date != tt.date || asOf.AccountName != "Checking" || asOf.Currency != "USD" {
+			t.Fatalf("unexpected details %+v", asOf)
+		}
+		assertFloatClose(t, tt.date+" balance", asOf.Balance, tt.balance, 0.001)
+		if asOf.TransactionCount != tt.count {
+			t.Fatalf("%s transaction count = %d, want %d", tt.date, asOf.TransactionCount, tt.count)
+		}
+		assertFloatClose(t, tt.date+" current balance", asOf.CurrentBalance, 4500, 0.001)
+		assertFloatClose(t, tt.date+" change since", asOf.ChangeSince, 4500-tt.balance, 0.001)
+	}
+
+	asOf, err := db.GetAccountBalanceAsOf(ctx, 1, "2024-01-01")
+	if err != nil {
+		t.Fatalf("GetAccountBalanceAsOf: %v", err)
+	}
+	if asOf.LastTransactionDate != "" {
+		t.Fatalf("last transaction date = %q, want none before the first transaction", asOf.LastTransactionDate)
+	}
+
+	if _, err := db.GetAccountBalanceAsOf(ctx, 999, "2024-01-01"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound for an unknown account, got %v", err)
+	}
+	if _, err := db.GetAccountBalanceAsOf(ctx, 1, "2024-13-01"); !errors.Is(err, ErrInvalidArgument) {
+		t.Fatalf("expected ErrInvalidArgument for an invalid date, got %v", err)
+	}
+}
+
+func TestTransferBalancesWithFixtureDB(t *testing.T) {
+	addSavings := func(conn *sql.DB, currency string) {
+		mustExecSQL(t, conn, `
+			INSERT INTO ZSYNCOBJECT (Z_PK, Z_ENT, ZNAME, ZBALLANCE, ZOPENINGBALANCE, ZCURRENCYNAME, ZTYPE)
+			VALUES (2, 10, 'Savings', 0, 0, ?, 'bank');
+		`, currency)
+	}
+	balances := func(t *testing.T, db *DB) (float64, float64) {
+		t.Helper()
+		accounts, err := db.GetAccounts(context.Background())
+		if err != nil {
+			t.Fatalf("GetAccounts: %v", err)
+		}
+		byName := make(map[string]float64)
+		for _, acc := range accounts {
+			byName[acc.Name] = acc.Balance
+		}
+		return byName["Checking"], byName["Savings"]
+	}
+
+	t.Run("paired rows", func(t *testing.T) {
+		db := newFixtureDBWithExtraRows(t, func(conn *sql.DB) {
+			addSavings(conn, "USD")
+			insertUncategorizedTransaction(t, conn, 2000, 43, -500, "2024-02-20", "Move to savings", 1, 2)
+			insertUncategorizedTransaction(t, conn, 2001, 43, 500, "2024-02-20", "Move to savings", 2, 1)
+		})
+
+		checking, savings := balances(t, db)
+		assertFloatClose(t, "checking after paired transfer", checking, 4500, 0.001)
+		assertFloatClose(t, "savings after paired transfer", savings, 500, 0.001)
+
+		history, err := db.GetAccountBalanceHistory(context.Background(), 2, 0)
+		if err != nil {
+			t.Fatalf("GetAccountBalanceHistory: %v", err)
+		}
+		if len(history.Points) != 1 || history.Points[0].Balance != 500 {
+			t.Fatalf("expected savings history to end at 500, got %+v", history.Points)
+		}
+
+		series, err := db.CalculateNetWorthSeries(context.Background(), 2, false)
+		if err != nil {
+			t.Fatalf("CalculateNetWorthSeries: %v", err)
+		}
+		assertFloatClose(t, "net worth after transfer", series.Points[1].NetWorth, 5000, 0.001)
+		assertFloatClose(t, "net worth before transfer", series.Points[0].NetWorth, 2800, 0.001)
+	})
+
+	t.Run("single row", func(t *testing.T) {
+		db := newFixtureDBWithExtraRows(t, func(conn *sql.DB) {
+			addSavings(conn, "USD")
+			insertUncategorizedTransaction(t, conn, 2000, 43, -200, "2024-02-20", "Move to savings", 1, 2)
+		})
+
+		checking, savings := balances(t, db)
+		assertFloatClose(t, "checking after single-row transfer", checking, 4800, 0.001)
+		assertFloatClose(t, "savings after single-row transfer", savings, 200, 0.001)
+	})
+
+	t.Run("recipient amount", func(t *testing.T) {
+		db := newFixtureDBWithExtraRows(t, func(conn *sql.DB) {
+			mustExecSQL(t, conn, `ALTER TABLE ZSYNCOBJECT ADD COLUMN ZRECIPIENTAMOUNT1 REAL`)
+			addSavings(conn, "EUR")
+			insertUncategorizedTransaction(t, conn, 2000, 43, -200, "2024-02-20", "Move to euro savings", 1, 2)
+			mustExecSQL(t, conn, `UPDATE ZSYNCOBJECT SET ZRECIPIENTAMOUNT1 = 180 WHERE Z_PK = 2000`)
+		})
+
+		checking, savings := balances(t, db)
+		assertFloatClose(t, "checking after cross-currency transfer", checking, 4800, 0.001)
+		assertFloatClose(t, "savings after cross-currency transfer", savings, 180, 0.001)
+	})
+}
+
+func TestGetTransactionsLimitIsValidatedAndClampedWithFixtureDB(t *testing.T) {
+	db := newFixtureDBWithExtraRows(t, func(conn *sql.DB) {
+		mustExecSQL(t, conn, `
+			WITH RECURSIVE n(i) AS (SELECT 1 UNION ALL SELECT i + 1 FROM n WHERE i < 1100)
+			INSERT INTO ZSYNCOBJECT (Z_PK, Z_ENT, ZAMOUNT1, ZDATE1, ZDESC2, ZACCOUNT2)
+			SELECT 10000 + i, 37, -1, 725000000 + i, 'Coffee', 1 FROM n;
+		`)
+	})
+	defer db.Close()
+	ctx := context.Background()
+
+	transactions, err := db.GetTransactions(ctx, TransactionFilter{Limit: 5000})
+	if err != nil {
+		t.Fatalf("GetTransactions with a huge limit: %v", err)
+	}
+	if len(transactions) != MaxTransactionLimit {
+		t.Fatalf("transactions len = %d, want the %d maximum", len(transactions), MaxTransactionLimit)
+	}
+
+	transactions, err = db.GetTransactions(ctx, TransactionFilter{})
+	if err != nil {
+		t.Fatalf("GetTransactions without a limit: %v", err)
+	}
+	if len(transactions) != 1104 {
+		t.Fatalf("transactions without a limit = %d, want all 1104", len(transactions))
+	}
+
+	if _, err := db.GetTransactions(ctx, TransactionFilter{Limit: -1}); !errors.Is(err, ErrInvalidArgument) {
+		t.Fatalf("negative limit error = %v, want ErrInvalidArgument", err)
+	}
+}
+
+func TestGetTransactionsOriginalAmountWithFixtureDB(t *testing.T) {
+	db := newFixtureDBWithExtraRows(t, func(conn *sql.DB) {
+		mustExecSQL(t, conn, `ALTER TABLE ZSYNCOBJECT ADD COLUMN ZORIGINALAMOUNT REAL`)
+		mustExecSQL(t, conn, `ALTER TABLE ZSYNCOBJECT ADD COLUMN ZORIGINALCURRENCY TEXT`)
+		insertTransaction(t, conn, 1004, 37, -54.3, "2024-02-12", "Hotel in Paris", 1, 0, 0)
+		mustExecSQL(t, conn, `UPDATE ZSYNCOBJECT SET ZORIGINALAMOUNT = -50, ZORIGINALCURRENCY = 'EUR' WHERE Z_PK = 1004`)
+	})
+
+	transactions, err := db.GetTransactions(context.Background(), TransactionFilter{Limit: 2})
+	if err != nil {
+		t.Fatalf("GetTransactions: %v", err)
+	}
+	if len(transactions) != 2 {
+		t.Fatalf("expected 2 transactions, got %d", len(transactions))
+	}
+
+	foreign := transactions[0]
+	if foreign.ID != 1004 || foreign.Currency != "USD" || foreign.OriginalCurrency != "EUR" || foreign.OriginalAmount == nil {
+		t.Fatalf("expected the EUR purchase booked in USD first, got %+v", foreign)
+	}
+	assertFloatClose(t, "original amount", *foreign.OriginalAmount, -50, 0.001)
+
+	domestic := transactions[1]
+	if domestic.Currency != "USD" || domestic.OriginalAmount != nil || domestic.OriginalCurrency != "" {
+		t.Fatalf("expected no original amount on a domestic transaction, got %+v", domestic)
+	}
+}
+
+func TestGetTransactionsStatusWithFixtureDB(t *testing.T) {
+	db := newFixtureDB(t)
+	transactions, err := db.GetTransactions(context.Background(), TransactionFilter{Limit: 1})
+	if err != nil {
+		t.Fatalf("GetTransactions: %v", err)
+	}
+	if transactions[0].Status != "" {
+		t.Fatalf("status = %q, want none without a status column", transactions[0].Status)
+	}
+	if _, err := db.GetTransactions(context.Background(), TransactionFilter{Status: "pending"}); !errors.Is(err, ErrInvalidArgument) {
+		t.Fatalf("status filter without a status column: err = %v, want ErrInvalidArgument", err)
+	}
+	db.Close()
+
+	db = newFixtureDBWithExtraRows(t, func(conn *sql.DB) {
+		mustExecSQL(t, conn, `
+			ALTER TABLE ZSYNCOBJECT ADD COLUMN ZRECONCILED INTEGER;
+			UPDATE ZSYNCOBJECT SET ZRECONCILED = 1 WHERE Z_PK IN (1000, 1001);
+			UPDATE ZSYNCOBJECT SET ZRECONCILED = 0 WHERE Z_PK = 1002;
+		`)
+	})
+	defer db.Close()
+
+	tests := []struct {
+		status  string
+		wantIDs []int64
+	}{
+		{status: "", wantIDs: []int64{1003, 1002, 1001, 1000}},
+		{status: "pending", wantIDs: []int64{1003, 1002}},
+		{status: "cleared", wantIDs: []int64{1001, 1000}},
+	}
+	for _, tt := range tests {
+		filter := TransactionFilter{Limit: 10, Status: tt.status}
+		transactions, err := db.GetTransactions(context.Background(), filter)
+		if err != nil {
+			t.Fatalf("GetTransactions(status %q): %v", tt.status, err)
+		}
+		if len(transactions) != len(tt.wantIDs) {
+			t.Fatalf("status %q transactions len = %d, want %d", tt.status, len(transactions), len(tt.wantIDs))
+		}
+		for i, txn := range transactions {
+			if txn.ID != tt.wantIDs[i] {
+				t.Fatalf("status %q transactions[%d].ID = %d, want %d", tt.status, i, txn.ID, tt.wantIDs[i])
+			}
+			wantStatus := "pending"
+			if txn.ID == 1000 || txn.ID == 1001 {
+				wantStatus = "cleared"
+			}
+			if txn.Status != wantStatus {
+				t.Fatalf("transaction %d status = %q, want %q", txn.ID, txn.Status, wantStatus)
+			}
+		}
+		count, err := db.CountTransactions(context.Background(), filter)
+		if err != nil || count != len(tt.wantIDs) {
+			t.Fatalf("CountTransactions(status %q) = %d, %v; want %d", tt.status, count, err, len(tt.wantIDs))
+		}
+	}
+
+	if _, err := db.GetTransactions(context.Background(), TransactionFilter{Status: "settled"}); !errors.Is(err, ErrInvalidArgument) {
+		t.Fatalf("unknown status: err = %v, want ErrInvalidArgument", err)
+	}
+}
+
+func TestDetectSpendingAnomaliesWithFixtureDB(t *testing.T) {
+	db := newFixtureDBWithExtraRows(t, func(conn *sql.DB) {
+		insertTransaction(t, conn, 2000, 37, -100, "2023-09-10", "Groceries", 1, 0, 102)
+		insertTransaction(t, conn, 2001, 37, -100, "2023-10-10", "Groceries", 1, 0, 102)
+		insertTransaction(t, conn, 2002, 37, -100, "2023-11-10", "Groceries", 1, 0, 102)
+		insertTransaction(t, conn, 2003, 37, -100, "2023-12-10", "Groceries", 1, 0, 102)
+	})
+
+	report, err := db.DetectSpendingAnomalies(context.Background(), 0, 2, 50)
+	if err != nil {
+		t.Fatalf("DetectSpendingAnomalies: %v", err)
+	}
+	if report.Window != "2023-09 to 2024-02" {
+		t.Fatalf("window = %q", report.Window)
+	}
+
+	var groceries *SpendingAnomaly
+	for i, anomaly := range report.Anomalies {
+		if anomaly.CategoryName == "Groceries" {
+			if anomaly.Period != "2024-02" {
+				t.Fatalf("unexpected groceries anomaly %+v", anomaly)
+			}
+			groceries = &report.Anomalies[i]
+		}
+	}
+	if groceries == nil {
+		t.Fatalf("expected the February groceries spike, got %+v", report.Anomalies)
+	}
+	assertFloatClose(t, "expected groceries", groceries.Expected, 80, 0.001)
+	assertFloatClose(t, "actual groceries", groceries.Actual, 300, 0.001)
+	if groceries.StdDevs == nil || groceries.TrailingAverage == nil || len(groceries.Reasons) != 2 {
+		t.Fatalf("expected both baselines to flag groceries, got %+v", groceries)
+	}
+	assertFloatClose(t, "groceries std devs", *groceries.StdDevs, 5.5, 0.001)
+
+	if report.Anomalies[0].CategoryName != "Rent" || report.Anomalies[0].Reasons[0] != AnomalyReasonTrailing {
+		t.Fatalf("expected the first rent payment as the largest trailing-average anomaly, got %+v", report.Anomalies[0])
+	}
+}
+
+func TestReadSnapshotIgnoresConcurrentWritesWithFixtureDB(t *testing.T) {
+	db := newFixtureDBWithExtraRows(t, func(conn *sql.DB) {
+		// In WAL mode a writer can commit while the snapshot is open
+		mustExecSQL(t, conn, `PRAGMA journal_mode = WAL`)
+	})
+	defer db.Close()
+	ctx := context.Background()
+
+	writer, err := sql.Open("sqlite3", db.Path())
+	if err != nil {
+		t.Fatalf("open writer: %v", err)
+	}
+	defer writer.Close()
+
+	countTransactions := func(db *DB) int {
+		t.Helper()
+		var count int
+		if err := db.reader().QueryRowContext(ctx, `SELECT COUNT(*) FROM ZSYNCOBJECT WHERE Z_ENT = 37`).Scan(&count); err != nil {
+			t.Fatalf("count transactions: %v", err)
+		}
+		return count
+	}
+
+	before := countTransactions(db)
+	during, err := inReadSnapshot(ctx, db, func(snap *DB) (int, error) {
+		first := countTransactions(snap)
+		insertUncategorizedTransaction(t, writer, 2000, 37, -25, "2024-02-20", "Coffee", 1, 0)
+		// A nested snapshot reuses the open one
+		nested, err := inReadSnapshot(ctx, snap, func(nested *DB) (int, error) {
+			return countTransactions(nested), nil
+		})
+		if err != nil {
+			return 0, err
+		}
+		if second := countTransactions(snap); second != first || nested != first {
+			t.Fatalf("snapshot saw %d, then %d and %d nested; want the same count throughout", first, second, nested)
+		}
+		return first, nil
+	})
+	if err != nil {
+		t.Fatalf("inReadSnapshot: %v", err)
+	}
+	if during != before {
+		t.Fatalf("snapshot count = %d, want %d", during, before)
+	}
+	if after := countTransactions(db); after != before+1 {
+		t.Fatalf("count after the snapshot = %d, want %d", after, before+1)
+	}
+	if db.snapshot != nil {
+		t.Fatal("the snapshot leaked into the shared DB")
+	}
+}
+
+func TestNewDBOpensReadOnlyByDefault(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "MoneyWiz backup #1", "db.sqlite")
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	conn, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	mustExecSQL(t, conn, `CREATE TABLE ZSYNCOBJECT (Z_PK INTEGER PRIMARY KEY, Z_ENT INTEGER)`)
+	conn.Close()
+
+	readOnly, err := NewDB(path, Options{})
+	if err != nil {
+		t.Fatalf("NewDB read-only: %v", err)
+	}
+	defer readOnly.Close()
+	if _, err := readOnly.conn.ExecContext(context.Background(), `INSERT INTO ZSYNCOBJECT (Z_PK, Z_ENT) VALUES (1, 10)`); err == nil {
+		t.Fatalf("expected write to fail on a read-only connection")
+	}
+
+	readWrite, err := NewDB(path, Options{ReadWrite: true})
+	if err != nil {
+		t.Fatalf("NewDB read-write: %v", err)
+	}
+	defer readWrite.Close()
+	if _, err := readWrite.conn.ExecContext(context.Background(), `INSERT INTO ZSYNCOBJECT (Z_PK, Z_ENT) VALUES (1, 10)`); err != nil {
+		t.Fatalf("expected write to succeed with ReadWrite: %v", err)
+	}
+}
+
+func TestNewDBRejectsFilesThatAreNotMoneyWizDatabases(t *testing.T) {
+	dir := t.TempDir()
+
+	notSQLite := filepath.Join(dir, "backup.zip")
+	if err := os.WriteFile(notSQLite, []byte("PK\x03\x04 this is a zip archive, not a database file at all"), 0o644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	otherApp := filepath.Join(dir, "other.sqlite")
+	conn, err := sql.Open("sqlite3", otherApp)
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	mustExecSQL(t, conn, `CREATE TABLE notes (id INTEGER PRIMARY KEY)`)
+	conn.Close()
+
+	tests := []struct {
+		name string
+		path string
+		want error
+	}{
+		{name: "missing file", path: filepath.Join(dir, "missing.sqlite"), want: ErrDatabaseMissing},
+		{name: "directory", path: dir, want: ErrNotSQLite},
+		{name: "not SQLite", path: notSQLite, want: ErrNotSQLite},
+		{name: "SQLite without ZSYNCOBJECT", path: otherApp, want: ErrNotMoneyWiz},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			db, err := NewDB(tc.path, Options{})
+			if err == nil {
+				db.Close()
+				t.Fatalf("NewDB(%s) succeeded, want %v", tc.path, tc.want)
+			}
+			if !errors.Is(err, tc.want) || !strings.Contains(err.Error(), tc.path) {
+				t.Fatalf("NewDB(%s) = %v, want %v naming the path", tc.path, err, tc.want)
+			}
+		})
+	}
+}
+
+func TestNewDBConfiguresConnectionPool(t *testing.T) {
+	fixture := newFixtureDB(t)
+	path := fixture.Path()
+	defer fixture.Close()
+
+	if got := fixture.conn.Stats().MaxOpenConnections; got != DefaultMaxOpenConns {
+		t.Fatalf("read-only max open connections = %d, want %d", got, DefaultMaxOpenConns)
+	}
+
+	readWrite, err := NewDB(path, Options{ReadWrite: true})
+	if err != nil {
+		t.Fatalf("NewDB read-write: %v", err)
+	}
+	defer readWrite.Close()
+	if got := readWrite.conn.Stats().MaxOpenConnections; got != 1 {
+		t.Fatalf("read-write max open connections = %d, want a single writer", got)
+	}
+
+	custom, err := NewDB(path, Options{MaxOpenConns: 8})
+	if err != nil {
+		t.Fatalf("NewDB with pool size: %v", err)
+	}
+	defer custom.Close()
+	if got := custom.conn.Stats().MaxOpenConnections; got != 8 {
+		t.Fatalf("custom max open connections = %d, want 8", got)
+	}
+
+	if _, err := NewDB(path, Options{MaxOpenConns: -1}); !errors.Is(err, ErrInvalidArgument) {
+		t.Fatalf("negative pool size = %v, want ErrInvalidArgument", err)
+	}
+}
+
+func TestNewDBReadsWALDatabaseWhileAnotherConnectionWrites(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "live.sqlite")
+	writer, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	defer writer.Close()
+	writer.SetMaxOpenConns(1)
+	mustExecSQL(t, writer, `
+		PRAGMA journal_mode=WAL;
+		CREATE TABLE ZSYNCOBJECT (Z_PK INTEGER PRIMARY KEY, Z_ENT INTEGER);
+		INSERT INTO ZSYNCOBJECT (Z_PK, Z_ENT) VALUES (1, 10);
+	`)
+
+	if got := dataSourceName(path, Options{}); !strings.Contains(got, "_busy_timeout=5000") {
+		t.Fatalf("default DSN = %q, want a 5s busy timeout", got)
+	}
+	db, err := NewDB(path, Options{BusyTimeout: 200 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("NewDB: %v", err)
+	}
+	defer db.Close()
+
+	// An open write transaction, like MoneyWiz saving, must not block readers in WAL mode
+	mustExecSQL(t, writer, `BEGIN IMMEDIATE; INSERT INTO ZSYNCOBJECT (Z_PK, Z_ENT) VALUES (2, 10);`)
+	defer mustExecSQL(t, writer, `ROLLBACK`)
+
+	var count int
+	if err := db.conn.QueryRowContext(context.Background(), `SELECT COUNT(*) FROM ZSYNCOBJECT`).Scan(&count); err != nil {
+		t.Fatalf("read during write transaction: %v", err)
+	}
+	if count != 1 {
+		t.Fatalf("row count = %d, want the 1 committed row", count)
+	}
+}
+
+func TestQueriesRetryWhileDatabaseIsLocked(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "locked.sqlite")
+	writer, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	defer writer.Close()
+	writer.SetMaxOpenConns(1)
+	mustExecSQL(t, writer, `
+		CREATE TABLE ZSYNCOBJECT (Z_PK INTEGER PRIMARY KEY, Z_ENT INTEGER, ZDATE1 REAL);
+		INSERT INTO ZSYNCOBJECT (Z_PK, Z_ENT, ZDATE1) VALUES (1, 37, 700000000);
+	`)
+
+	db, err := NewDB(path, Options{BusyTimeout: time.Millisecond})
+	if err != nil {
+		t.Fatalf("NewDB: %v", err)
+	}
+	defer db.Close()
+
+	// Without WAL an exclusive lock keeps readers out until MoneyWiz commits, well past the
+	// 1ms busy timeout but before the first retry
+	mustExecSQL(t, writer, `BEGIN EXCLUSIVE; UPDATE ZSYNCOBJECT SET Z_ENT = 37;`)
+	released := make(chan struct{})
+	go func() {
+		defer close(released)
+		time.Sleep(busyRetryBackoff / 2)
+		if _, err := writer.Exec(`COMMIT`); err != nil {
+			t.Errorf("commit: %v", err)
+		}
+	}()
+
+	if _, ok, err := db.latestTransactionTime(context.Background(), true); err != nil || !ok {
+		t.Fatalf("latestTransactionTime while locked = %v, %v; want a retried success", ok, err)
+	}
+	<-released
+}
+
+func TestMultiRowQueriesRetryWhileDatabaseIsLocked(t *testing.T) {
+	fixture := newFixtureDBWithExtraRows(t, func(conn *sql.DB) {
+		mustExecSQL(t, conn, `
+			INSERT INTO ZSYNCOBJECT (Z_PK, Z_ENT, ZNAME, ZBALLANCE, ZOPENINGBALANCE, ZCURRENCYNAME, ZTYPE)
+			VALUES (2, 12, 'Wallet', 0, 50, 'USD', 'cash');
+		`)
+	})
+	path := fixture.Path()
+	fixture.Close()
+
+	db, err := NewDB(path, Options{BusyTimeout: time.Millisecond})
+	if err != nil {
+		t.Fatalf("NewDB: %v", err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+	// Reading once loads the schema, so the lock below is only met when the rows are stepped
+	// through, after the query itself has returned
+	if _, err := db.GetAccounts(ctx); err != nil {
+		t.Fatalf("GetAccounts: %v", err)
+	}
+
+	writer, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	defer writer.Close()
+	writer.SetMaxOpenConns(1)
+	mustExecSQL(t, writer, `BEGIN EXCLUSIVE; UPDATE ZSYNCOBJECT SET ZNAME = ZNAME WHERE Z_PK = 1;`)
+	released := make(chan struct{})
+	go func() {
+		defer close(released)
+		time.Sleep(busyRetryBackoff / 2)
+		if _, err := writer.Exec(`COMMIT`); err != nil {
+			t.Errorf("commit: %v", err)
+		}
+	}()
+
+	accounts, err := db.GetAccounts(ctx)
+	if err != nil {
+		t.Fatalf("GetAccounts while locked: %v; want a retried success", err)
+	}
+	if len(accounts) != 2 {
+		t.Fatalf("expected both accounts after the retry, got %+v", accounts)
+	}
+	<-released
+}
+
+func TestGetUncategorizedTransactionsWithFixtureDB(t *testing.T) {
+	db := newFixtureDBWithExtraRows(t, func(conn *sql.DB) {
+		insertUncategorizedTransaction(t, conn, 2000, 37, -50, "2024-02-12", "Coffee shop", 1, 0)
+		insertUncategorizedTransaction(t, conn, 2001, 37, 20, "2024-02-14", "Cashback", 1, 0)
+		insertUncategorizedTransaction(t, conn, 2002, 37, -100, "2024-02-15", "Transfer to Savings", 1, 0)
+		insertUncategorizedTransaction(t, conn, 2003, 46, -40, "2024-02-16", "ATM Withdrawal", 1, 0)
+		insertUncategorizedTransaction(t, conn, 2004, 43, -70, "2024-02-17", "Move to cash", 1, 0)
+	})
+	defer db.Close()
+
+	report, err := db.GetUncategorizedTransactions(context.Background(), 0, 1)
+	if err != nil {
+		t.Fatalf("GetUncategorizedTransactions: %v", err)
+	}
+
+	if report.TransactionCount != 2 {
+		t.Fatalf("expected only the coffee and cashback rows, got %d", report.TransactionCount)
+	}
+	assertFloatClose(t, "uncategorized spending", report.SpendingByCurrency["USD"], 50, 0.001)
+	assertFloatClose(t, "uncategorized income", report.IncomeByCurrency["USD"], 20, 0.001)
+	assertFloatClose(t, "total spending", report.TotalSpending["USD"], 1550, 0.001)
+	assertFloatClose(t, "uncategorized share", report.SpendingSharePercent["USD"], 50.0/1550*100, 0.001)
+	if len(report.Transactions) != 1 || report.Transactions[0].ID != 2001 || report.Transactions[0].CategoryName != "Uncategorized" {
+		t.Fatalf("expected the limit to keep the most recent row, got %+v", report.Transactions)
+	}
+}
+
+func TestCalculateNetWorthSeriesSplitsAssetsAndLiabilitiesWithFixtureDB(t *testing.T) {
+	db := newFixtureDBWithExtraRows(t, func(conn *sql.DB) {
+		mustExecSQL(t, conn, `
+			INSERT INTO ZSYNCOBJECT (Z_PK, Z_ENT, ZNAME, ZBALLANCE, ZOPENINGBALANCE, ZCURRENCYNAME, ZTYPE)
+			VALUES (2, 10, 'Visa', 0, 0, 'USD', 'credit card');
+		`)
+		insertTransaction(t, conn, 2000, 37, -400, "2024-01-25", "Flights", 2, 0, 102)
+		insertTransaction(t, conn, 2001, 37, 150, "2024-02-20", "Card payment", 2, 0, 0)
+	})
+	defer db.Close()
+
+	got, err := db.CalculateNetWorthSeries(context.Background(), 2, false)
+	if err != nil {
+		t.Fatalf("CalculateNetWorthSeries: %v", err)
+	}
+	if len(got.Points) != 2 {
+		t.Fatalf("points len = %d, want 2", len(got.Points))
+	}
+
+	jan, feb := got.Points[0], got.Points[1]
+	assertFloatClose(t, "jan assets", jan.TotalAssets, 2800, 0.001)
+	assertFloatClose(t, "jan liabilities", jan.TotalLiabilities, 400, 0.001)
+	assertFloatClose(t, "jan net worth", jan.NetWorth, 2400, 0.001)
+	assertFloatClose(t, "feb assets", feb.TotalAssets, 5000, 0.001)
+	assertFloatClose(t, "feb liabilities", feb.TotalLiabilities, 250, 0.001)
+	assertFloatClose(t, "feb net worth", feb.NetWorth, 4750, 0.001)
+}
+
+func TestCalculateNetWorthSeriesClampsMonthsWithFixtureDB(t *testing.T) {
+	db := newFixtureDB(t)
+	defer db.Close()
+
+	got, err := db.CalculateNetWorthSeries(context.Background(), 100000, false)
+	if err != nil {
+		t.Fatalf("CalculateNetWorthSeries: %v", err)
+	}
+	if got.Months != MaxNetWorthSeriesMonths || len(got.Points) != MaxNetWorthSeriesMonths {
+		t.Fatalf("months = %d, points = %d, want %d", got.Months, len(got.Points), MaxNetWorthSeriesMonths)
+	}
+	if first, last := got.Points[0].Month, got.Points[len(got.Points)-1].Month; first != "1974-03" || last != "2024-02" {
+		t.Fatalf("series = %s..%s, want 1974-03..2024-02", first, last)
+	}
+}
+
+func TestComparePeriodsWithFixtureDB(t *testing.T) {
+	db := newFixtureDB(t)
+	defer db.Close()
+
+	got, err := db.ComparePeriods(context.Background(), "", "", false)
+	if err != nil {
+		t.Fatalf("ComparePeriods: %v", err)
+	}
+
+	if got.Mode != ComparisonModeMonthOverMonth || got.Base.Period != "2024-01" || got.Compare.Period != "2024-02" {
+		t.Fatalf("expected January vs February, got %s: %s vs %s", got.Mode, got.Base.Period, got.Compare.Period)
+	}
+	assertFloatClose(t, "base net savings", got.Base.NetSavings, 1800, 0.001)
+	assertFloatClose(t, "compare net savings", got.Compare.NetSavings, 2200, 0.001)
+	assertFloatClose(t, "spending delta", got.SpendingDelta, -900, 0.001)
+	if got.SpendingChangePercent == nil {
+		t.Fatal("expected a spending change percent")
+	}
+	assertFloatClose(t, "spending change percent", *got.SpendingChangePercent, -75, 0.001)
+
+	if got.Base.Incomplete || !got.Compare.Incomplete || got.Compare.DaysCovered != 10 || got.Compare.Note == "" {
+		t.Fatalf("expected only February to be incomplete with 10 days covered, got %+v / %+v", got.Base, got.Compare)
+	}
+
+	if len(got.CategoryDeltas) != 3 || got.CategoryDeltas[0].CategoryName != "Rent" {
+		t.Fatalf("expected rent as the largest of three category deltas, got %+v", got.CategoryDeltas)
+	}
+	groceries := got.CategoryDeltas[2]
+	if groceries.CategoryName != "Groceries" || groceries.ChangePercent != nil {
+		t.Fatalf("expected groceries without a change percent (no base spending), got %+v", groceries)
+	}
+
+	custom, err := db.ComparePeriods(context.Background(), "2024-01-01", "2024-01-15", false)
+	if err != nil {
+		t.Fatalf("ComparePeriods custom: %v", err)
+	}
+	if custom.Mode != ComparisonModeCustom || custom.Base.Income != 0 || custom.Compare.Income != 3000 || custom.Compare.Incomplete {
+		t.Fatalf("unexpected custom comparison: %+v", custom)
+	}
+
+	if _, err := db.ComparePeriods(context.Background(), "2024-01", "", false); err == nil {
+		t.Fatal("expected an error when only one period is given")
+	}
+}
+
+func TestCompareSamePeriodLastYearWithFixtureDB(t *testing.T) {
+	db := newFixtureDBWithExtraRows(t, func(conn *sql.DB) {
+		insertTransaction(t, conn, 2000, 37, -100, "2023-02-15", "Groceries", 1, 0, 102)
+		insertTransaction(t, conn, 2001, 37, -40, "2023-03-01", "Groceries", 1, 0, 102)
+	})
+	defer db.Close()
+	ctx := context.Background()
+
+	got, err := db.CompareSamePeriodLastYear(ctx, "", false)
+	if err != nil {
+		t.Fatalf("CompareSamePeriodLastYear: %v", err)
+	}
+	if got.Mode != ComparisonModeYearOverYear || got.Base.Period != "2023-02" || got.Compare.Period != "2024-02" {
+		t.Fatalf("expected February 2023 vs February 2024, got %s: %s vs %s", got.Mode, got.Base.Period, got.Compare.Period)
+	}
+	assertFloatClose(t, "base spending", got.Base.Spending, 100, 0.001)
+	assertFloatClose(t, "compare spending", got.Compare.Spending, 300, 0.001)
+	assertFloatClose(t, "spending delta", got.SpendingDelta, 200, 0.001)
+	if got.Base.Incomplete || !got.Compare.Incomplete {
+		t.Fatalf("expected only February 2024 to be incomplete, got %+v / %+v", got.Base, got.Compare)
+	}
+
+	leapDay, err := db.CompareSamePeriodLastYear(ctx, "2024-02-29", false)
+	if err != nil {
+		t.Fatalf("CompareSamePeriodLastYear leap day: %v", err)
+	}
+	if leapDay.Base.Period != "2023-02-28" || leapDay.Base.EndDate != "2023-02-28" {
+		t.Fatalf("expected 29 February to compare with 28 February, got %+v", leapDay.Base)
+	}
+
+	year, err := db.CompareSamePeriodLastYear(ctx, "2024", false)
+	if err != nil {
+		t.Fatalf("CompareSamePeriodLastYear year: %v", err)
+	}
+	if year.Base.Period != "2023" || year.Base.EndDate != "2023-12-31" {
+		t.Fatalf("expected the whole of 2023 as the base, got %+v", year.Base)
+	}
+	assertFloatClose(t, "2023 spending", year.Base.Spending, 140, 0.001)
+
+	if _, err := db.CompareSamePeriodLastYear(ctx, "Feb 2024", false); !errors.Is(err, ErrInvalidArgument) {
+		t.Fatalf("expected an invalid period to be rejected, got %v", err)
+	}
+}
+
+func TestGetAccountsReportsTransactionActivityWithFixtureDB(t *testing.T) {
+	db := newFixtureDBWithExtraRows(t, func(conn *sql.DB) {
+		mustExecSQL(t, conn, `
+			INSERT INTO ZSYNCOBJECT (Z_PK, Z_ENT, ZNAME, ZBALLANCE, ZOPENINGBALANCE, ZCURRENCYNAME, ZTYPE) VALUES
+				(2, 10, 'Savings', 0, 0, 'USD', 'bank'),
+				(3, 10, 'Old wallet', 0, 20, 'USD', 'cash');
+		`)
+		// A transfer stored as a single row moves both accounts
+		insertUncategorizedTransaction(t, conn, 2000, 43, -500, "2024-02-15", "Transfer to savings", 1, 2)
+	})
+	defer db.Close()
+
+	accounts, err := db.GetAccounts(context.Background())
+	if err != nil {
+		t.Fatalf("GetAccounts: %v", err)
+	}
+	byName := make(map[string]Account, len(accounts))
+	for _, acc := range accounts {
+		byName[acc.Name] = acc
+	}
+	if checking := byName["Checking"]; checking.TransactionCount != 5 || checking.LastTransactionDate != "2024-02-15 00:00:00" {
+		t.Fatalf("expected checking's four transactions and the transfer, got %+v", checking)
+	}
+	if savings := byName["Savings"]; savings.TransactionCount != 1 || savings.LastTransactionDate != "2024-02-15 00:00:00" {
+		t.Fatalf("expected the transfer into savings, got %+v", savings)
+	}
+	if wallet := byName["Old wallet"]; wallet.TransactionCount != 0 || wallet.LastTransactionDate != "" {
+		t.Fatalf("expected no activity in the old wallet, got %+v", wallet)
+	}
+}
+
+func TestGetAccountsReportsStoredBalanceMismatchWithFixtureDB(t *testing.T) {
+	db := newFixtureDBWithExtraRows(t, func(conn *sql.DB) {
+		mustExecSQL(t, conn, `
+			INSERT INTO ZSYNCOBJECT (Z_PK, Z_ENT, ZNAME, ZBALLANCE, ZOPENINGBALANCE, ZCURRENCYNAME, ZTYPE) VALUES
+				(2, 10, 'Savings', 900, 1000, 'USD', 'bank'),
+				(3, 10, 'Wallet', 949.995, 1000, 'USD', 'cash');
+		`)
+		insertTransaction(t, conn, 2000, 37, -50, "2024-02-12", "Gift", 2, 0, 102)
+		insertTransaction(t, conn, 2001, 37, -50, "2024-02-12", "Gift", 3, 0, 102)
+	})
+	defer db.Close()
+
+	accounts, err := db.GetAccounts(context.Background())
+	if err != nil {
+		t.Fatalf("GetAccounts: %v", err)
+	}
+	byName := make(map[string]Account, len(accounts))
+	for _, acc := range accounts {
+		byName[acc.Name] = acc
+	}
+
+	checking := byName["Checking"]
+	if checking.StoredBalance == nil || *checking.StoredBalance != 0 || checking.BalanceMismatch {
+		t.Fatalf("expected an uncached zero stored balance without a mismatch, got %+v", checking)
+	}
+	assertFloatClose(t, "checking opening balance", checking.OpeningBalance, 1000, 0.001)
+
+	savings := byName["Savings"]
+	assertFloatClose(t, "savings balance", savings.Balance, 950, 0.001)
+	if savings.StoredBalance == nil || *savings.StoredBalance != 900 || !savings.BalanceMismatch {
+		t.Fatalf("expected the stale stored balance to be flagged, got %+v", savings)
+	}
+
+	if byName["Wallet"].BalanceMismatch {
+		t.Fatalf("expected a sub-cent difference to be treated as rounding, got %+v", byName["Wallet"])
 	}
 }
 
@@ -364,7 +4191,7 @@ func newFixtureDB(t *testing.T) *DB {
 	return newFixtureDBWithExtraRows(t, nil)
 }
 
-func newFixtureDBWithExtraRows(t *testing.T, extraRows func(conn *sql.DB)) *DB {
+func newFixtureDBWithExtraRows(t testing.TB, extraRows func(conn *sql.DB)) *DB {
 	t.Helper()
 
 	path := filepath.Join(t.TempDir(), "moneywiz-fixture.sqlite")
@@ -403,14 +4230,14 @@ func newFixtureDBWithExtraRows(t *testing.T, extraRows func(conn *sql.DB)) *DB {
 		extraRows(conn)
 	}
 
-	db, err := NewDB(path)
+	db, err := NewDB(path, Options{})
 	if err != nil {
 		t.Fatalf("NewDB: %v", err)
 	}
 	return db
 }
 
-func insertFixtureRows(t *testing.T, conn *sql.DB) {
+func insertFixtureRows(t testing.TB, conn *sql.DB) {
 	t.Helper()
 
 	mustExecSQL(t, conn, `
@@ -431,7 +4258,7 @@ func insertFixtureRows(t *testing.T, conn *sql.DB) {
 	insertTransaction(t, conn, 1003, 37, -300, "2024-02-10", "Groceries", 1, 0, 102)
 }
 
-func insertTransaction(t *testing.T, conn *sql.DB, id, ent int64, amount float64, date, description string, account2, account int64, category int64) {
+func insertTransaction(t testing.TB, conn *sql.DB, id, ent int64, amount float64, date, description string, account2, account int64, category int64) {
 	t.Helper()
 
 	mustExecSQL(t, conn, `
@@ -445,7 +4272,7 @@ func insertTransaction(t *testing.T, conn *sql.DB, id, ent int64, amount float64
 	`, id, category)
 }
 
-func insertUncategorizedTransaction(t *testing.T, conn *sql.DB, id, ent int64, amount float64, date, description string, account2, account int64) {
+func insertUncategorizedTransaction(t testing.TB, conn *sql.DB, id, ent int64, amount float64, date, description string, account2, account int64) {
 	t.Helper()
 
 	mustExecSQL(t, conn, `
@@ -454,7 +4281,7 @@ func insertUncategorizedTransaction(t *testing.T, conn *sql.DB, id, ent int64, a
 	`, id, ent, amount, coreDataSeconds(t, date), description, account2, account)
 }
 
-func coreDataSeconds(t *testing.T, date string) float64 {
+func coreDataSeconds(t testing.TB, date string) float64 {
 	t.Helper()
 
 	ts, err := time.Parse("2006-01-02", date)
@@ -465,7 +4292,7 @@ func coreDataSeconds(t *testing.T, date string) float64 {
 	return ts.Sub(coreDataEpoch).Seconds()
 }
 
-func mustExecSQL(t *testing.T, conn *sql.DB, query string, args ...any) {
+func mustExecSQL(t testing.TB, conn *sql.DB, query string, args ...any) {
 	t.Helper()
 
 	if _, err := conn.Exec(query, args...); err != nil {
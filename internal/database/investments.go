@@ -0,0 +1,209 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+)
+
+// MoneyWiz stores the holdings of investment accounts (entity 15) as rows of a holding entity in
+// ZSYNCOBJECT, linked to their account. The entity and column names are detected because schema
+// versions differ
+var (
+	holdingAccountColumnCandidates   = []string{"ZINVESTMENTACCOUNT", "ZACCOUNT", "ZACCOUNT2"}
+	holdingSymbolColumnCandidates    = []string{"ZSYMBOL", "ZTICKER"}
+	holdingNameColumnCandidates      = []string{"ZNAME", "ZDESC2"}
+	holdingSharesColumnCandidates    = []string{"ZNUMBEROFSHARES", "ZSHARES", "ZQUANTITY"}
+	holdingPriceColumnCandidates     = []string{"ZPRICEPERSHARE", "ZLASTPRICE", "ZPRICE"}
+	holdingCostBasisColumnCandidates = []string{"ZCOSTBASIS", "ZTOTALCOST"}
+)
+
+// InvestmentHolding represents one position held in an investment account
+// Value and gain fields are omitted when the database does not store what they need
+type InvestmentHolding struct {
+	ID               int64    `json:"id"`
+	Symbol           string   `json:"symbol,omitempty"`
+	Name             string   `json:"name,omitempty"`
+	Shares           float64  `json:"shares"`
+	PricePerShare    *float64 `json:"price_per_share,omitempty" money:"amount"`
+	CurrentValue     *float64 `json:"current_value,omitempty" money:"amount"` // shares * price per share
+	CostBasis        *float64 `json:"cost_basis,omitempty" money:"amount"`
+	GainLoss         *float64 `json:"gain_loss,omitempty" money:"amount"`
+	GainLossPercent  *float64 `json:"gain_loss_percent,omitempty"`
+	PortfolioPercent *float64 `json:"portfolio_percent,omitempty"` // Share of the total value of all holdings
+}
+
+// InvestmentHoldings represents the holdings of one investment account
+type InvestmentHoldings struct {
+	AccountID      int64               `json:"account_id"`
+	AccountName    string              `json:"account_name"`
+	AccountType    string              `json:"account_type"`
+	Currency       string              `json:"currency"`
+	AccountBalance float64             `json:"account_balance" money:"amount"`
+	HoldingEntity  string              `json:"holding_entity,omitempty"` // Core Data entity the holdings were read from
+	TotalValue     *float64            `json:"total_value,omitempty" money:"amount"`
+	TotalCostBasis *float64            `json:"total_cost_basis,omitempty" money:"amount"`
+	TotalGainLoss  *float64            `json:"total_gain_loss,omitempty" money:"amount"`
+	Note           string              `json:"note,omitempty"`
+	Holdings       []InvestmentHolding `json:"holdings"`
+}
+
+// GetInvestmentHoldings lists the holdings of an account with shares, current value and gain/loss,
+// largest value first
+// Databases without holdings return an empty list with a note rather than an error
+func (db *DB) GetInvestmentHoldings(ctx context.Context, accountID int64) (*InvestmentHoldings, error) {
+	account, err := db.GetAccountBalance(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &InvestmentHoldings{
+		AccountID:      account.ID,
+		AccountName:    account.Name,
+		AccountType:    account.AccountType,
+		Currency:       account.Currency,
+		AccountBalance: account.Balance,
+		Holdings:       []InvestmentHolding{},
+	}
+
+	entity, entityName, err := db.entityLike(ctx, "%Holding%")
+	if err != nil {
+		return nil, err
+	}
+	if entityName == "" {
+		result.Note = "This database does not store investment holdings."
+		return result, nil
+	}
+	result.HoldingEntity = entityName
+
+	holdings, err := db.holdingRows(ctx, entity, accountID)
+	if err != nil {
+		return nil, err
+	}
+	if holdings == nil {
+		result.Note = fmt.Sprintf("The %s entity has no account or share columns, so holdings cannot be read.", entityName)
+		return result, nil
+	}
+	if len(holdings) == 0 {
+		result.Note = "This account has no holdings."
+		return result, nil
+	}
+
+	summarizeHoldings(result, holdings)
+	return result, nil
+}
+
+// holdingRows reads the holdings of an account, or returns nil when the columns needed to link
+// holdings to accounts and count their shares are missing
+func (db *DB) holdingRows(ctx context.Context, entity int, accountID int64) ([]InvestmentHolding, error) {
+	columns, err := db.tableColumns(ctx, "ZSYNCOBJECT")
+	if err != nil {
+		return nil, err
+	}
+
+	accountColumn := firstColumn(columns, holdingAccountColumnCandidates...)
+	sharesColumn := firstColumn(columns, holdingSharesColumnCandidates...)
+	if accountColumn == "" || sharesColumn == "" {
+		return nil, nil
+	}
+	optional := func(candidates []string) string {
+		if column := firstColumn(columns, candidates...); column != "" {
+			return column
+		}
+		return "NULL"
+	}
+
+	query := fmt.Sprintf(`
+		SELECT Z_PK, %s, %s, %s, %s, %s
+		FROM ZSYNCOBJECT
+		WHERE Z_ENT = ? AND %s = ?
+	`, optional(holdingSymbolColumnCandidates), optional(holdingNameColumnCandidates), sharesColumn,
+		optional(holdingPriceColumnCandidates), optional(holdingCostBasisColumnCandidates), accountColumn)
+
+	holdings := []InvestmentHolding{}
+	err = db.queryEach(ctx, "holdingRows", query, []any{entity, accountID}, func(rows *sql.Rows) error {
+		var holding InvestmentHolding
+		var symbol, name sql.NullString
+		var shares, price, costBasis sql.NullFloat64
+		if err := rows.Scan(&holding.ID, &symbol, &name, &shares, &price, &costBasis); err != nil {
+			return fmt.Errorf("failed to scan investment holding: %w", err)
+		}
+		holding.Symbol = symbol.String
+		holding.Name = name.String
+		holding.Shares = shares.Float64
+		if price.Valid {
+			holding.PricePerShare = &price.Float64
+			value := shares.Float64 * price.Float64
+			holding.CurrentValue = &value
+		}
+		if costBasis.Valid {
+			holding.CostBasis = &costBasis.Float64
+		}
+		holdings = append(holdings, holding)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query investment holdings: %w", err)
+	}
+
+	return holdings, nil
+}
+
+// summarizeHoldings fills per-holding gains and portfolio shares and the account totals
+// Totals are only reported when every holding has the value (or cost basis) they need
+func summarizeHoldings(result *InvestmentHoldings, holdings []InvestmentHolding) {
+	var totalValue, totalCost float64
+	allValued, allCosted := true, true
+	for i := range holdings {
+		holding := &holdings[i]
+		if holding.CurrentValue == nil {
+			allValued = false
+		} else {
+			totalValue += *holding.CurrentValue
+		}
+		if holding.CostBasis == nil {
+			allCosted = false
+		} else {
+			totalCost += *holding.CostBasis
+		}
+		if holding.CurrentValue != nil && holding.CostBasis != nil {
+			gain := *holding.CurrentValue - *holding.CostBasis
+			holding.GainLoss = &gain
+			if *holding.CostBasis != 0 {
+				gainPercent := gain / *holding.CostBasis * 100
+				holding.GainLossPercent = &gainPercent
+			}
+		}
+	}
+
+	if allValued {
+		result.TotalValue = &totalValue
+		if totalValue != 0 {
+			for i := range holdings {
+				share := *holdings[i].CurrentValue / totalValue * 100
+				holdings[i].PortfolioPercent = &share
+			}
+		}
+	}
+	if allCosted {
+		result.TotalCostBasis = &totalCost
+	}
+	if allValued && allCosted {
+		gain := totalValue - totalCost
+		result.TotalGainLoss = &gain
+	}
+
+	sort.SliceStable(holdings, func(i, j int) bool {
+		return holdingValue(holdings[i]) > holdingValue(holdings[j])
+	})
+	result.Holdings = holdings
+}
+
+// holdingValue returns the current value of a holding, or 0 when its price is unknown
+func holdingValue(holding InvestmentHolding) float64 {
+	if holding.CurrentValue == nil {
+		return 0
+	}
+	return *holding.CurrentValue
+}
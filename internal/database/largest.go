@@ -0,0 +1,121 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// LargestTransactions represents the biggest transactions of a period by absolute amount
+type LargestTransactions struct {
+	Months          int           `json:"months"`
+	Type            string        `json:"type"` // "income", "expense", or "both"
+	MixedCurrencies bool          `json:"mixed_currencies"`
+	Currencies      []string      `json:"currencies"`
+	CurrencyWarning string        `json:"currency_warning,omitempty"`
+	Transactions    []Transaction `json:"transactions"` // Largest absolute amount first; expenses are negative
+}
+
+// GetLargestTransactions returns the limit transactions with the largest absolute amount over
+// the last months (0 = all historical data), so a large figure in the stats can be traced to
+// what it was
+// transactionType: "income", "expense", or "both" ("" = both)
+// Internal transfers and future-dated scheduled transactions are left out, as in the analyses
+func (db *DB) GetLargestTransactions(ctx context.Context, months, limit int, transactionType string) (*LargestTransactions, error) {
+	switch transactionType {
+	case "":
+		transactionType = CategoryTypeBoth
+	case CategoryTypeIncome, CategoryTypeExpense, CategoryTypeBoth:
+	default:
+		return nil, invalidArgumentf("invalid transaction type %q: expected income, expense, or both", transactionType)
+	}
+	if limit <= 0 {
+		limit = 10
+	}
+
+	var transactions []Transaction
+	if transactionType != CategoryTypeExpense {
+		income, err := db.GetIncomeData(ctx, months, false, false)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get income data: %w", err)
+		}
+		for _, i := range income {
+			transactions = append(transactions, Transaction{
+				ID:           i.TransactionID,
+				Amount:       i.Amount,
+				Date:         i.Date,
+				Description:  i.Description,
+				AccountID:    i.AccountID,
+				Currency:     i.Currency,
+				CategoryID:   i.CategoryID,
+				CategoryName: i.CategoryName,
+				MovementType: detectMovementType(i.Description),
+			})
+		}
+	}
+	if transactionType != CategoryTypeIncome {
+		spending, err := db.GetSpendingData(ctx, months, false, nil, false)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get spending data: %w", err)
+		}
+		for _, s := range spending {
+			transactions = append(transactions, Transaction{
+				ID:           s.TransactionID,
+				Amount:       -s.Amount,
+				Date:         s.Date,
+				Description:  s.Description,
+				Payee:        s.Payee,
+				AccountID:    s.AccountID,
+				Currency:     s.Currency,
+				CategoryID:   s.CategoryID,
+				CategoryName: s.CategoryName,
+				MovementType: detectMovementType(s.Description),
+			})
+		}
+	}
+
+	// Largest first; ties go to the most recent so the cut is deterministic
+	sort.SliceStable(transactions, func(i, j int) bool {
+		if a, b := math.Abs(transactions[i].Amount), math.Abs(transactions[j].Amount); a != b {
+			return a > b
+		}
+		if transactions[i].Date != transactions[j].Date {
+			return transactions[i].Date > transactions[j].Date
+		}
+		return transactions[i].ID > transactions[j].ID
+	})
+	if len(transactions) > limit {
+		transactions = transactions[:limit]
+	}
+
+	accounts, err := db.GetAccounts(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get accounts: %w", err)
+	}
+	accountNames := make(map[int64]string, len(accounts))
+	for _, account := range accounts {
+		accountNames[account.ID] = account.Name
+	}
+
+	result := &LargestTransactions{
+		Months:       months,
+		Type:         transactionType,
+		Transactions: make([]Transaction, 0, len(transactions)),
+	}
+	currencySet := make(map[string]struct{})
+	for _, txn := range transactions {
+		txn.AccountName = accountNames[txn.AccountID]
+		if txn.Currency != "" {
+			currencySet[txn.Currency] = struct{}{}
+		}
+		result.Transactions = append(result.Transactions, txn)
+	}
+	result.Currencies = sortedCurrencyKeys(currencySet)
+	result.MixedCurrencies = len(result.Currencies) > 1
+	if result.MixedCurrencies {
+		result.CurrencyWarning = "Transactions are in different currencies, so amounts are ranked without conversion."
+	}
+
+	return result, nil
+}
@@ -36,7 +36,33 @@ func isInternalMovement(movementType string) bool {
 	}
 }
 
-func fallbackCategoryName(categoryName, description string) string {
+// excludedMovement reports whether income and spending leave out a transaction of movementType:
+// cash withdrawals always, since the cash is still the user's until it is spent, and transfers
+// between own accounts unless includeTransfers is set
+func excludedMovement(movementType string, includeTransfers bool) bool {
+	switch movementType {
+	case movementTypeCashWithdrawal:
+		return true
+	case movementTypeTransfer:
+		return !includeTransfers
+	default:
+		return false
+	}
+}
+
+// transactionEntities returns the Z_ENT list read by income and spending queries
+// Entity 43 holds transfers between own accounts, which are neither income nor spending
+// unless explicitly requested
+func transactionEntities(includeTransfers bool) string {
+	if includeTransfers {
+		return "37, 45, 46, 47, 43"
+	}
+	return "37, 45, 46, 47"
+}
+
+// fallbackCategoryName labels a transaction without a category by its movement type, or with
+// uncategorizedLabel when it is neither a transfer nor a cash withdrawal
+func fallbackCategoryName(categoryName, description, uncategorizedLabel string) string {
 	if strings.TrimSpace(categoryName) != "" {
 		return categoryName
 	}
@@ -47,7 +73,7 @@ func fallbackCategoryName(categoryName, description string) string {
 	case movementTypeCashWithdrawal:
 		return "Cash Withdrawal"
 	default:
-		return "Uncategorized"
+		return uncategorizedLabel
 	}
 }
 
@@ -39,9 +39,30 @@ func TestFallbackCategoryName(t *testing.T) {
 
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
-			if got := fallbackCategoryName(tc.categoryName, tc.description); got != tc.want {
+			if got := fallbackCategoryName(tc.categoryName, tc.description, DefaultUncategorizedLabel); got != tc.want {
 				t.Fatalf("fallbackCategoryName(%q, %q) = %q, want %q", tc.categoryName, tc.description, got, tc.want)
 			}
 		})
 	}
 }
+
+func TestExcludedMovement(t *testing.T) {
+	tests := []struct {
+		movementType     string
+		includeTransfers bool
+		want             bool
+	}{
+		{movementType: movementTypeRegular, want: false},
+		{movementType: movementTypeUncategorized, want: false},
+		{movementType: movementTypeTransfer, want: true},
+		{movementType: movementTypeTransfer, includeTransfers: true, want: false},
+		{movementType: movementTypeCashWithdrawal, want: true},
+		{movementType: movementTypeCashWithdrawal, includeTransfers: true, want: true},
+	}
+
+	for _, tc := range tests {
+		if got := excludedMovement(tc.movementType, tc.includeTransfers); got != tc.want {
+			t.Fatalf("excludedMovement(%q, %v) = %v, want %v", tc.movementType, tc.includeTransfers, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+// NetSavingsPeriod represents income, spending, and what was left over in one period
+type NetSavingsPeriod struct {
+	Period      string                   `json:"period"` // "YYYY-MM", "YYYY", or a fiscal year such as "FY2025"
+	Income      float64                  `json:"income" money:"amount"`
+	Spending    float64                  `json:"spending" money:"amount"`
+	Net         float64                  `json:"net" money:"amount"` // Income minus spending
+	SavingsRate float64                  `json:"savings_rate"`       // Percentage of income kept (0 when there was no income)
+	ByCurrency  map[string]NetSavingsSum `json:"by_currency" money:"by_currency"`
+}
+
+// NetSavingsSum represents the income, spending, and net of one currency in a period
+type NetSavingsSum struct {
+	Income   float64 `json:"income" money:"amount"`
+	Spending float64 `json:"spending" money:"amount"`
+	Net      float64 `json:"net" money:"amount"`
+}
+
+// AnalyzeNetSavingsTrend combines income and spending into one net series by time period
+// Periods between the first and last with activity are included even when empty, so the series
+// has no gaps
+// groupBy: "month" or "year"
+// months: number of months to analyze (0 = all historical data)
+// includeTransfers: count transfers between own accounts (excluded by default)
+// includeScheduled: count future-dated (scheduled) transactions (excluded by default)
+func (db *DB) AnalyzeNetSavingsTrend(ctx context.Context, groupBy string, months int, includeTransfers bool, includeScheduled bool) ([]NetSavingsPeriod, error) {
+	if groupBy != "month" && groupBy != "year" {
+		groupBy = "month"
+	}
+
+	incomeData, err := db.GetIncomeData(ctx, months, includeTransfers, includeScheduled)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get income data: %w", err)
+	}
+	spendingData, err := db.GetSpendingData(ctx, months, includeTransfers, nil, includeScheduled)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get spending data: %w", err)
+	}
+
+	periodsMap := make(map[string]*NetSavingsPeriod)
+	periodFor := func(month, year string) *NetSavingsPeriod {
+		period := month
+		if groupBy == "year" {
+			period = year
+		}
+		if period == "" {
+			return nil
+		}
+		if periodsMap[period] == nil {
+			periodsMap[period] = &NetSavingsPeriod{Period: period, ByCurrency: make(map[string]NetSavingsSum)}
+		}
+		return periodsMap[period]
+	}
+
+	for _, i := range incomeData {
+		period := periodFor(i.Month, i.Year)
+		if period == nil {
+			continue
+		}
+		period.Income += i.Amount
+		if i.Currency != "" {
+			sum := period.ByCurrency[i.Currency]
+			sum.Income += i.Amount
+			period.ByCurrency[i.Currency] = sum
+		}
+	}
+	for _, s := range spendingData {
+		period := periodFor(s.Month, s.Year)
+		if period == nil {
+			continue
+		}
+		period.Spending += s.Amount
+		if s.Currency != "" {
+			sum := period.ByCurrency[s.Currency]
+			sum.Spending += s.Amount
+			period.ByCurrency[s.Currency] = sum
+		}
+	}
+
+	if len(periodsMap) == 0 {
+		return []NetSavingsPeriod{}, nil
+	}
+
+	keys := make([]string, 0, len(periodsMap))
+	for period := range periodsMap {
+		keys = append(keys, period)
+	}
+	sort.Strings(keys)
+
+	periods := make([]NetSavingsPeriod, 0, len(keys))
+	for _, key := range periodSpan(keys[0], keys[len(keys)-1], groupBy) {
+		period := periodsMap[key]
+		if period == nil {
+			period = &NetSavingsPeriod{Period: key, ByCurrency: make(map[string]NetSavingsSum)}
+		}
+		period.Net = period.Income - period.Spending
+		if period.Income > 0 {
+			period.SavingsRate = period.Net / period.Income * 100
+		}
+		for currency, sum := range period.ByCurrency {
+			sum.Net = sum.Income - sum.Spending
+			period.ByCurrency[currency] = sum
+		}
+		periods = append(periods, *period)
+	}
+
+	return periods, nil
+}
+
+// periodSpan returns every month or year period from first to last inclusive
+func periodSpan(first, last, groupBy string) []string {
+	if groupBy != "year" {
+		return monthSpan(first, last)
+	}
+	start, fiscal, err := parseYearLabel(first)
+	if err != nil {
+		return nil
+	}
+	end, _, err := parseYearLabel(last)
+	if err != nil {
+		return nil
+	}
+	var years []string
+	for year := start; year <= end; year++ {
+		years = append(years, formatYearLabel(year, fiscal))
+	}
+	return years
+}
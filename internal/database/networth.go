@@ -1,60 +1,210 @@
 package database
 
 import (
+	"context"
 	"fmt"
-	"math"
+	"sort"
+	"strings"
 )
 
 // NetWorth represents net worth calculation
 type NetWorth struct {
-	TotalAssets      float64            `json:"total_assets"`
-	TotalLiabilities float64            `json:"total_liabilities"`
-	NetWorth         float64            `json:"net_worth"`
+	TotalAssets      float64            `json:"total_assets" money:"amount"`
+	TotalLiabilities float64            `json:"total_liabilities" money:"amount"`
+	NetWorth         float64            `json:"net_worth" money:"amount"`
 	AccountCount     int                `json:"account_count"`
-	ByCurrency       map[string]float64 `json:"by_currency"` // Net worth by currency
-	Accounts         []AccountSummary   `json:"accounts"`    // Summary of all accounts
+	ExcludedAccounts int                `json:"excluded_accounts,omitempty"`     // Accounts left out by the AccountFilter
+	ByCurrency       map[string]float64 `json:"by_currency" money:"by_currency"` // Net worth by currency
+	Accounts         []AccountSummary   `json:"accounts"`                        // Summary of all accounts
+	TargetCurrency   string             `json:"target_currency,omitempty"`       // Currency the totals were converted to
+	ExchangeRates    map[string]float64 `json:"exchange_rates,omitempty"`        // Units of target currency per unit of each currency
+	Warnings         []string           `json:"warnings,omitempty"`
+	ByGroup          []GroupNetWorth    `json:"by_group,omitempty"`     // Set by BreakDownNetWorthByGroup
+	GroupSource      string             `json:"group_source,omitempty"` // "database" or "client"
 }
 
 // AccountSummary represents a summary of an account for net worth calculation
 type AccountSummary struct {
-	ID       int64   `json:"id"`
-	Name     string  `json:"name"`
-	Balance  float64 `json:"balance"`
-	Currency string  `json:"currency"`
-	Type     string  `json:"type"`
+	ID             int64   `json:"id"`
+	Name           string  `json:"name"`
+	Balance        float64 `json:"balance" money:"amount"`
+	Currency       string  `json:"currency"`
+	Type           string  `json:"type"`
+	Classification string  `json:"classification"` // "asset" or "liability"
 }
 
-// CalculateNetWorth calculates the total net worth from all accounts
-func (db *DB) CalculateNetWorth() (*NetWorth, error) {
-	accounts, err := db.GetAccounts()
+// AccountFilter selects the accounts a net worth counts; the zero value counts every account
+// An account is counted when it matches the includes (if any) and none of the excludes
+type AccountFilter struct {
+	IncludeIDs   []int64  // Only these accounts
+	ExcludeIDs   []int64  // Never these accounts, e.g. a shared account not fully owned
+	IncludeTypes []string // Only accounts of these types, matched without regard to case
+	ExcludeTypes []string // Never accounts of these types, e.g. "investment"
+	Currencies   []string // Only accounts in these currencies, matched without regard to case
+}
+
+// apply returns the accounts the filter selects
+// Account IDs and types that match no account are rejected, so a typo does not silently count
+// (or drop) everything
+func (f AccountFilter) apply(accounts []Account) ([]Account, error) {
+	if len(f.IncludeIDs) == 0 && len(f.ExcludeIDs) == 0 && len(f.IncludeTypes) == 0 && len(f.ExcludeTypes) == 0 && len(f.Currencies) == 0 {
+		return accounts, nil
+	}
+
+	knownIDs := make(map[int64]bool, len(accounts))
+	knownTypes := make(map[string]string)
+	knownCurrencies := make(map[string]struct{})
+	for _, acc := range accounts {
+		knownIDs[acc.ID] = true
+		knownTypes[strings.ToLower(acc.AccountType)] = acc.AccountType
+		if acc.Currency != "" {
+			knownCurrencies[strings.ToUpper(acc.Currency)] = struct{}{}
+		}
+	}
+	idSet := func(ids []int64) (map[int64]bool, error) {
+		set := make(map[int64]bool, len(ids))
+		for _, id := range ids {
+			if !knownIDs[id] {
+				return nil, notFoundf("account with ID %d not found", id)
+			}
+			set[id] = true
+		}
+		return set, nil
+	}
+	typeSet := func(types []string) (map[string]bool, error) {
+		set := make(map[string]bool, len(types))
+		for _, accountType := range types {
+			key := strings.ToLower(strings.TrimSpace(accountType))
+			if _, ok := knownTypes[key]; !ok {
+				available := make([]string, 0, len(knownTypes))
+				for _, name := range knownTypes {
+					available = append(available, name)
+				}
+				sort.Strings(available)
+				return nil, invalidArgumentf("no account has type %q; account types are %s", accountType, strings.Join(available, ", "))
+			}
+			set[key] = true
+		}
+		return set, nil
+	}
+
+	includeIDs, err := idSet(f.IncludeIDs)
+	if err != nil {
+		return nil, err
+	}
+	excludeIDs, err := idSet(f.ExcludeIDs)
+	if err != nil {
+		return nil, err
+	}
+	includeTypes, err := typeSet(f.IncludeTypes)
+	if err != nil {
+		return nil, err
+	}
+	excludeTypes, err := typeSet(f.ExcludeTypes)
+	if err != nil {
+		return nil, err
+	}
+	currencies := make(map[string]bool, len(f.Currencies))
+	for _, currency := range f.Currencies {
+		key := strings.ToUpper(strings.TrimSpace(currency))
+		if _, ok := knownCurrencies[key]; !ok {
+			return nil, invalidArgumentf("no account uses currency %q; account currencies are %s", currency, strings.Join(sortedCurrencyKeys(knownCurrencies), ", "))
+		}
+		currencies[key] = true
+	}
+
+	selected := make([]Account, 0, len(accounts))
+	for _, acc := range accounts {
+		accountType := strings.ToLower(acc.AccountType)
+		switch {
+		case len(includeIDs) > 0 && !includeIDs[acc.ID]:
+		case len(includeTypes) > 0 && !includeTypes[accountType]:
+		case len(currencies) > 0 && !currencies[strings.ToUpper(acc.Currency)]:
+		case excludeIDs[acc.ID], excludeTypes[accountType]:
+		default:
+			selected = append(selected, acc)
+		}
+	}
+	return selected, nil
+}
+
+// CalculateNetWorth calculates the total net worth from the accounts the filter selects (the
+// zero AccountFilter selects all of them)
+// Totals add raw balances, even when accounts use different currencies
+func (db *DB) CalculateNetWorth(ctx context.Context, filter AccountFilter) (*NetWorth, error) {
+	accounts, err := db.GetAccounts(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get accounts: %w", err)
 	}
+	selected, err := filter.apply(accounts)
+	if err != nil {
+		return nil, err
+	}
+
+	netWorth := summarizeNetWorth(selected, func(acc Account) float64 { return acc.Balance })
+	netWorth.ExcludedAccounts = len(accounts) - len(selected)
+	return netWorth, nil
+}
+
+// CalculateNetWorthInCurrency converts every account balance into the target currency before
+// summing the totals; ByCurrency keeps the unconverted per-currency balances
+// rates: units of the target currency per unit of each account currency (e.g. {"EUR": 1.08} for USD)
+// Currencies without a rate are converted at 1.0 and reported in Warnings
+// filter selects the accounts as in CalculateNetWorth
+func (db *DB) CalculateNetWorthInCurrency(ctx context.Context, target string, rates map[string]float64, filter AccountFilter) (*NetWorth, error) {
+	converter, err := newCurrencyConverter(target, rates)
+	if err != nil {
+		return nil, err
+	}
 
+	accounts, err := db.GetAccounts(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get accounts: %w", err)
+	}
+	selected, err := filter.apply(accounts)
+	if err != nil {
+		return nil, err
+	}
+
+	netWorth := summarizeNetWorth(selected, func(acc Account) float64 {
+		return converter.convert(acc.Balance, acc.Currency)
+	})
+	netWorth.ExcludedAccounts = len(accounts) - len(selected)
+
+	conversion := converter.conversion("accounts without a currency")
+	netWorth.TargetCurrency = conversion.TargetCurrency
+	netWorth.ExchangeRates = conversion.ExchangeRates
+	netWorth.Warnings = conversion.Warnings
+
+	return netWorth, nil
+}
+
+// summarizeNetWorth totals account balances, valuing each account with value
+func summarizeNetWorth(accounts []Account, value func(Account) float64) *NetWorth {
 	var totalAssets float64
 	var totalLiabilities float64
 	byCurrency := make(map[string]float64)
 	var accountSummaries []AccountSummary
 
 	for _, acc := range accounts {
+		classification := classifyAccount(acc.AccountType, acc.Balance)
 		accountSummary := AccountSummary{
-			ID:       acc.ID,
-			Name:     acc.Name,
-			Balance:  acc.Balance,
-			Currency: acc.Currency,
-			Type:     acc.AccountType,
+			ID:             acc.ID,
+			Name:           acc.Name,
+			Balance:        acc.Balance,
+			Currency:       acc.Currency,
+			Type:           acc.AccountType,
+			Classification: classification,
 		}
 		accountSummaries = append(accountSummaries, accountSummary)
 
-		// Categorize as asset or liability
-		// In MoneyWiz, positive balances are typically assets
-		// Negative balances or specific account types might be liabilities
-		// For simplicity, we'll treat all balances as assets (net worth = sum of all balances)
-		// If balance is negative, it reduces net worth
-		if acc.Balance >= 0 {
-			totalAssets += acc.Balance
+		// Liability accounts owe money when negative, so a positive (credit) balance on a card
+		// reduces total liabilities rather than adding to assets; net worth is unaffected
+		balance := value(acc)
+		if classification == AccountClassificationLiability {
+			totalLiabilities -= balance
 		} else {
-			totalLiabilities += math.Abs(acc.Balance)
+			totalAssets += balance
 		}
 
 		// Track by currency
@@ -72,5 +222,5 @@ func (db *DB) CalculateNetWorth() (*NetWorth, error) {
 		AccountCount:     len(accounts),
 		ByCurrency:       byCurrency,
 		Accounts:         accountSummaries,
-	}, nil
+	}
 }
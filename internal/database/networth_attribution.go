@@ -0,0 +1,219 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+const defaultAttributionMonths = 12
+
+// investmentAccountTypeKeywords mark investment accounts, matched against the lowercased ZTYPE value
+var investmentAccountTypeKeywords = []string{"invest", "brokerage"}
+
+// NetWorthChangeComponents splits a net worth change into what caused it
+// Change always equals Savings + InvestmentReturns + NetTransfers
+type NetWorthChangeComponents struct {
+	StartNetWorth     float64 `json:"start_net_worth" money:"amount"`
+	EndNetWorth       float64 `json:"end_net_worth" money:"amount"`
+	Change            float64 `json:"change" money:"amount"`
+	Income            float64 `json:"income" money:"amount"`             // Non-transfer inflows to non-investment accounts
+	Spending          float64 `json:"spending" money:"amount"`           // Non-transfer outflows from non-investment accounts, as a positive number
+	Savings           float64 `json:"savings" money:"amount"`            // Income - spending
+	InvestmentReturns float64 `json:"investment_returns" money:"amount"` // Non-transfer transactions booked on investment accounts (gains, dividends, fees)
+	NetTransfers      float64 `json:"net_transfers" money:"amount"`      // Transfers that did not cancel out between own accounts
+}
+
+// NetWorthAttribution represents the change in net worth between two days and its causes
+type NetWorthAttribution struct {
+	StartDate string `json:"start_date"` // YYYY-MM-DD; net worth at the start of this day
+	EndDate   string `json:"end_date"`   // YYYY-MM-DD; net worth at the end of this day
+	NetWorthChangeComponents
+	InvestmentContributions float64                             `json:"investment_contributions" money:"amount"` // Net transfers into investment accounts; moves money within net worth
+	InvestmentAccounts      []string                            `json:"investment_accounts"`
+	CurrentValuationGap     *float64                            `json:"current_valuation_gap,omitempty" money:"amount"` // Stored minus calculated balance of investment accounts today
+	ValuationNote           string                              `json:"valuation_note"`
+	ByCurrency              map[string]NetWorthChangeComponents `json:"by_currency" money:"by_currency"`
+	MixedCurrencies         bool                                `json:"mixed_currencies"`
+	Currencies              []string                            `json:"currencies"`
+	CurrencyWarning         string                              `json:"currency_warning,omitempty"`
+}
+
+// isInvestmentAccount reports whether an account type names an investment account
+func isInvestmentAccount(accountType string) bool {
+	normalized := strings.ToLower(accountType)
+	for _, keyword := range investmentAccountTypeKeywords {
+		if strings.Contains(normalized, keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+// add books one movement of an account into the matching component
+func (c *NetWorthChangeComponents) add(amount float64, transfer, investment bool) {
+	c.Change += amount
+	switch {
+	case transfer:
+		c.NetTransfers += amount
+	case investment:
+		c.InvestmentReturns += amount
+	case amount > 0:
+		c.Income += amount
+	default:
+		c.Spending -= amount
+	}
+	c.Savings = c.Income - c.Spending
+}
+
+// AttributeNetWorthChange explains the net worth change between startDate and endDate by
+// classifying every balance movement in the window:
+// - savings: income and spending on non-investment accounts
+// - investment returns: non-transfer transactions on investment accounts, which is how gains,
+// dividends and fees reach the balance of an investment account
+// - net transfers: transfers between own accounts cancel out, so what remains comes from transfers
+// to untracked accounts or across currencies
+// Net worth is reconstructed from transactions as in CalculateNetWorthSeries, so the components
+// add up to the change exactly. MoneyWiz keeps no price history, so unbooked market value changes
+// cannot be placed in a window; the current gap between the stored and calculated balances of
+// investment accounts is reported beside the components instead
+// startDate: YYYY-MM-DD ("" = 12 months before endDate)
+// endDate: YYYY-MM-DD ("" = today)
+func (db *DB) AttributeNetWorthChange(ctx context.Context, startDate, endDate string) (*NetWorthAttribution, error) {
+	return inReadSnapshot(ctx, db, func(snap *DB) (*NetWorthAttribution, error) {
+		return snap.attributeNetWorthChange(ctx, startDate, endDate)
+	})
+}
+
+// attributeNetWorthChange is AttributeNetWorthChange without the read snapshot
+func (db *DB) attributeNetWorthChange(ctx context.Context, startDate, endDate string) (*NetWorthAttribution, error) {
+	end, err := parseAsOf("end_date", endDate)
+	if err != nil {
+		return nil, err
+	}
+	start := addMonthsClamped(end, -defaultAttributionMonths)
+	if startDate != "" {
+		start, err = parseAsOf("start_date", startDate)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if start.After(end) {
+		return nil, invalidArgumentf("start_date %s is after end_date %s", start.Format(dayLayout), end.Format(dayLayout))
+	}
+	windowStart := timeToCoreData(start)
+	windowEnd := timeToCoreData(end.AddDate(0, 0, 1))
+
+	accounts, err := db.GetAccounts(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get accounts: %w", err)
+	}
+
+	movementsQuery, err := db.accountMovementsQuery(ctx)
+	if err != nil {
+		return nil, err
+	}
+	query := `
+		SELECT m.account_id, m.amount, m.date, t.Z_ENT, t.ZDESC2
+		FROM (` + movementsQuery + `) m
+		JOIN ZSYNCOBJECT t ON t.Z_PK = m.transaction_id
+		WHERE m.date IS NOT NULL AND m.date >= ?`
+
+	byAccount := make(map[int64]Account, len(accounts))
+	for _, acc := range accounts {
+		byAccount[acc.ID] = acc
+	}
+
+	attribution := &NetWorthAttribution{
+		StartDate:          start.Format(dayLayout),
+		EndDate:            end.Format(dayLayout),
+		InvestmentAccounts: []string{},
+		ByCurrency:         make(map[string]NetWorthChangeComponents),
+	}
+	// Balances at the end of the window and at its start, rolled back from today's
+	endBalances := make(map[int64]float64, len(accounts))
+	startBalances := make(map[int64]float64, len(accounts))
+	for _, acc := range accounts {
+		endBalances[acc.ID] = acc.Balance
+		startBalances[acc.ID] = acc.Balance
+	}
+
+	err = db.queryEach(ctx, "attributeNetWorthChange", query, []any{windowStart}, func(rows *sql.Rows) error {
+		var accountID int64
+		var amount, date float64
+		var entity int
+		var description sql.NullString
+		if err := rows.Scan(&accountID, &amount, &date, &entity, &description); err != nil {
+			return fmt.Errorf("failed to scan net worth movement: %w", err)
+		}
+
+		acc, known := byAccount[accountID]
+		if !known {
+			return nil
+		}
+		startBalances[accountID] -= amount
+		if date >= windowEnd {
+			endBalances[accountID] -= amount
+			return nil
+		}
+
+		transfer := entity == 43 || isInternalMovement(detectMovementType(description.String))
+		investment := isInvestmentAccount(acc.AccountType)
+		attribution.add(amount, transfer, investment)
+		if transfer && investment {
+			attribution.InvestmentContributions += amount
+		}
+		components := attribution.ByCurrency[acc.Currency]
+		components.add(amount, transfer, investment)
+		attribution.ByCurrency[acc.Currency] = components
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query net worth movements: %w", err)
+	}
+
+	currencySet := make(map[string]struct{})
+	var valuationGap float64
+	valued := false
+	for _, acc := range accounts {
+		attribution.StartNetWorth += startBalances[acc.ID]
+		attribution.EndNetWorth += endBalances[acc.ID]
+		components := attribution.ByCurrency[acc.Currency]
+		components.StartNetWorth += startBalances[acc.ID]
+		components.EndNetWorth += endBalances[acc.ID]
+		attribution.ByCurrency[acc.Currency] = components
+		if acc.Currency != "" {
+			currencySet[acc.Currency] = struct{}{}
+		}
+
+		if isInvestmentAccount(acc.AccountType) {
+			attribution.InvestmentAccounts = append(attribution.InvestmentAccounts, acc.Name)
+			// A stored balance of 0 usually means MoneyWiz has not cached one, as in setBalances
+			if acc.StoredBalance != nil && *acc.StoredBalance != 0 {
+				valuationGap += *acc.StoredBalance - acc.Balance
+				valued = true
+			}
+		}
+	}
+	// Accounts without a currency are only part of the totals
+	delete(attribution.ByCurrency, "")
+
+	switch {
+	case len(attribution.InvestmentAccounts) == 0:
+		attribution.ValuationNote = "No investment accounts found, so the change comes from cash flows only."
+	case valued:
+		attribution.CurrentValuationGap = &valuationGap
+		attribution.ValuationNote = "MoneyWiz keeps no price history, so market value changes that were not booked as transactions cannot be attributed to the window. current_valuation_gap is how far the stored balances of investment accounts are from their transactions today."
+	default:
+		attribution.ValuationNote = "MoneyWiz keeps no price history and no stored balances were found for investment accounts, so only booked investment returns are attributed."
+	}
+
+	attribution.Currencies = sortedCurrencyKeys(currencySet)
+	attribution.MixedCurrencies = len(attribution.Currencies) > 1
+	if attribution.MixedCurrencies {
+		attribution.CurrencyWarning = "Totals combine multiple currencies without conversion, and transfers across currencies leave a remainder in net_transfers. Prefer by_currency values for accurate interpretation."
+	}
+
+	return attribution, nil
+}
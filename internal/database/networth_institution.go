@@ -0,0 +1,127 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const unknownInstitution = "Unknown"
+
+// institutionColumnCandidates lists the account columns MoneyWiz versions use for the bank name
+var institutionColumnCandidates = []string{"ZBANKNAME", "ZINSTITUTIONNAME", "ZINSTITUTION"}
+
+// InstitutionNetWorth represents the combined balances held at one institution
+type InstitutionNetWorth struct {
+	Institution  string             `json:"institution"`
+	NetWorth     float64            `json:"net_worth" money:"amount"`
+	AccountCount int                `json:"account_count"`
+	ByCurrency   map[string]float64 `json:"by_currency" money:"by_currency"`
+	Accounts     []AccountSummary   `json:"accounts"`
+}
+
+// NetWorthByInstitution represents net worth grouped by bank/institution
+type NetWorthByInstitution struct {
+	InstitutionColumn string                `json:"institution_column,omitempty"` // Column the institution names were read from
+	Note              string                `json:"note,omitempty"`
+	Institutions      []InstitutionNetWorth `json:"institutions"`
+}
+
+// CalculateNetWorthByInstitution groups account balances by institution name
+// Accounts without an institution are grouped under "Unknown"
+func (db *DB) CalculateNetWorthByInstitution(ctx context.Context) (*NetWorthByInstitution, error) {
+	accounts, err := db.GetAccounts(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get accounts: %w", err)
+	}
+
+	column, err := db.firstExistingColumn(ctx, "ZSYNCOBJECT", institutionColumnCandidates...)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &NetWorthByInstitution{
+		InstitutionColumn: column,
+		Institutions:      []InstitutionNetWorth{},
+	}
+
+	institutions := make(map[int64]string)
+	if column == "" {
+		result.Note = "This database has no institution column on accounts; all accounts are grouped under \"Unknown\"."
+	} else {
+		institutions, err = db.accountInstitutions(ctx, column)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	groups := make(map[string]*InstitutionNetWorth)
+	for _, acc := range accounts {
+		institution := institutions[acc.ID]
+		if institution == "" {
+			institution = unknownInstitution
+		}
+		if groups[institution] == nil {
+			groups[institution] = &InstitutionNetWorth{
+				Institution: institution,
+				ByCurrency:  make(map[string]float64),
+			}
+		}
+
+		group := groups[institution]
+		group.NetWorth += acc.Balance
+		group.AccountCount++
+		if acc.Currency != "" {
+			group.ByCurrency[acc.Currency] += acc.Balance
+		}
+		group.Accounts = append(group.Accounts, AccountSummary{
+			ID:             acc.ID,
+			Name:           acc.Name,
+			Balance:        acc.Balance,
+			Currency:       acc.Currency,
+			Type:           acc.AccountType,
+			Classification: classifyAccount(acc.AccountType, acc.Balance),
+		})
+	}
+
+	for _, group := range groups {
+		result.Institutions = append(result.Institutions, *group)
+	}
+	sort.Slice(result.Institutions, func(i, j int) bool {
+		if result.Institutions[i].NetWorth != result.Institutions[j].NetWorth {
+			return result.Institutions[i].NetWorth > result.Institutions[j].NetWorth
+		}
+		return result.Institutions[i].Institution < result.Institutions[j].Institution
+	})
+
+	return result, nil
+}
+
+// accountInstitutions reads the trimmed institution name of every account from the given column
+func (db *DB) accountInstitutions(ctx context.Context, column string) (map[int64]string, error) {
+	query := fmt.Sprintf(`
+		SELECT Z_PK, %s
+		FROM ZSYNCOBJECT
+		WHERE Z_ENT IN (10, 11, 12, 13, 15, 16)
+	`, column)
+
+	institutions := make(map[int64]string)
+	err := db.queryEach(ctx, "accountInstitutions", query, nil, func(rows *sql.Rows) error {
+		var id int64
+		var institution sql.NullString
+		if err := rows.Scan(&id, &institution); err != nil {
+			return fmt.Errorf("failed to scan account institution: %w", err)
+		}
+		if institution.Valid {
+			institutions[id] = strings.TrimSpace(institution.String)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query account institutions: %w", err)
+	}
+
+	return institutions, nil
+}
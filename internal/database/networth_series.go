@@ -0,0 +1,149 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// MaxNetWorthSeriesMonths is the longest series CalculateNetWorthSeries returns (50 years); a
+// larger months is clamped to it
+const MaxNetWorthSeriesMonths = 600
+
+// NetWorthPoint represents net worth at the end of a month
+type NetWorthPoint struct {
+	Month            string             `json:"month"` // YYYY-MM format
+	NetWorth         float64            `json:"net_worth" money:"amount"`
+	TotalAssets      float64            `json:"total_assets" money:"amount"`
+	TotalLiabilities float64            `json:"total_liabilities" money:"amount"`          // Amount owed, as a positive number
+	ByCurrency       map[string]float64 `json:"by_currency,omitempty" money:"by_currency"` // Only populated when requested
+}
+
+// NetWorthSeries represents net worth over a range of month-ends
+type NetWorthSeries struct {
+	Months          int             `json:"months"`
+	Points          []NetWorthPoint `json:"points"`
+	MixedCurrencies bool            `json:"mixed_currencies"`
+	Currencies      []string        `json:"currencies"`
+	CurrencyWarning string          `json:"currency_warning,omitempty"`
+}
+
+// CalculateNetWorthSeries reconstructs net worth at each month-end by rolling the current
+// account balances backward through the transactions booked after that month
+// months: number of month-ends to return, ending at the month of the latest transaction, at most MaxNetWorthSeriesMonths
+// byCurrency: also return per-currency totals for each month-end
+func (db *DB) CalculateNetWorthSeries(ctx context.Context, months int, byCurrency bool) (*NetWorthSeries, error) {
+	return inReadSnapshot(ctx, db, func(snap *DB) (*NetWorthSeries, error) {
+		return snap.calculateNetWorthSeries(ctx, months, byCurrency)
+	})
+}
+
+// calculateNetWorthSeries is CalculateNetWorthSeries without the read snapshot
+func (db *DB) calculateNetWorthSeries(ctx context.Context, months int, byCurrency bool) (*NetWorthSeries, error) {
+	if months <= 0 {
+		months = 12
+	}
+	if months > MaxNetWorthSeriesMonths {
+		months = MaxNetWorthSeriesMonths
+	}
+
+	accounts, err := db.GetAccounts(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get accounts: %w", err)
+	}
+
+	balances := make(map[int64]float64, len(accounts))
+	currencySet := make(map[string]struct{})
+	for _, acc := range accounts {
+		balances[acc.ID] = acc.Balance
+		if acc.Currency != "" {
+			currencySet[acc.Currency] = struct{}{}
+		}
+	}
+
+	movementsQuery, err := db.accountMovementsQuery(ctx)
+	if err != nil {
+		return nil, err
+	}
+	query := `SELECT account_id, amount, date FROM (` + movementsQuery + `) WHERE date IS NOT NULL`
+
+	// Per-month change of each account, keyed by YYYY-MM
+	changes := make(map[string]map[int64]float64)
+	var latest time.Time
+	err = db.queryEach(ctx, "calculateNetWorthSeries", query, nil, func(rows *sql.Rows) error {
+		var accountID int64
+		var amount float64
+		var date float64
+		if err := rows.Scan(&accountID, &amount, &date); err != nil {
+			return fmt.Errorf("failed to scan net worth history: %w", err)
+		}
+
+		ts := coreDataToTime(date)
+		if ts.After(latest) {
+			latest = ts
+		}
+		if _, known := balances[accountID]; !known {
+			return nil
+		}
+		month := ts.Format(monthLayout)
+		if changes[month] == nil {
+			changes[month] = make(map[int64]float64)
+		}
+		changes[month][accountID] += amount
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query net worth history: %w", err)
+	}
+
+	currencies := sortedCurrencyKeys(currencySet)
+	series := &NetWorthSeries{
+		Months:          months,
+		Points:          []NetWorthPoint{},
+		MixedCurrencies: len(currencies) > 1,
+		Currencies:      currencies,
+	}
+	if len(currencies) > 1 {
+		series.CurrencyWarning = "Net worth combines multiple currencies. Request by_currency for per-currency balances."
+	}
+	if latest.IsZero() {
+		return series, nil
+	}
+
+	// Walk backward from the latest month: the balance at the end of a month equals the
+	// balance at the end of the following month minus that following month's changes
+	lastMonth := time.Date(latest.Year(), latest.Month(), 1, 0, 0, 0, 0, time.UTC)
+	points := make([]NetWorthPoint, months)
+	for i := 0; i < months; i++ {
+		month := lastMonth.AddDate(0, -i, 0).Format(monthLayout)
+		if i > 0 {
+			following := lastMonth.AddDate(0, -i+1, 0).Format(monthLayout)
+			for accountID, change := range changes[following] {
+				balances[accountID] -= change
+			}
+		}
+
+		point := NetWorthPoint{Month: month}
+		if byCurrency {
+			point.ByCurrency = make(map[string]float64)
+		}
+		for _, acc := range accounts {
+			balance := balances[acc.ID]
+			point.NetWorth += balance
+			// Classified with the month-end balance, as CalculateNetWorth does with the current one
+			if classifyAccount(acc.AccountType, balance) == AccountClassificationLiability {
+				point.TotalLiabilities -= balance
+			} else {
+				point.TotalAssets += balance
+			}
+			if byCurrency && acc.Currency != "" {
+				point.ByCurrency[acc.Currency] += balance
+			}
+		}
+		points[months-1-i] = point
+	}
+	series.Points = points
+
+	return series, nil
+}
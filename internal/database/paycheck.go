@@ -0,0 +1,180 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+)
+
+const defaultPaycheckHistoryMonths = 12
+
+// PaycheckPeriod represents income and spending since the most recent regular paycheck
+type PaycheckPeriod struct {
+	AsOf                  string             `json:"as_of"` // YYYY-MM-DD; later transactions are ignored
+	HistoryMonths         int                `json:"history_months"`
+	PaycheckFound         bool               `json:"paycheck_found"`
+	Message               string             `json:"message"`
+	Payee                 string             `json:"payee,omitempty"` // Normalized source of the paycheck series
+	PaycheckDate          string             `json:"paycheck_date,omitempty"`
+	PaycheckAmount        float64            `json:"paycheck_amount" money:"amount"`
+	PaycheckTransactionID int64              `json:"paycheck_transaction_id,omitempty"`
+	AveragePaycheck       float64            `json:"average_paycheck" money:"amount"`
+	PaycheckCount         int                `json:"paycheck_count"` // Paychecks in the detected series
+	NextExpectedDate      string             `json:"next_expected_date,omitempty"`
+	DaysSincePaycheck     int                `json:"days_since_paycheck"`
+	DaysUntilNextPaycheck int                `json:"days_until_next_paycheck"`             // Negative when the next paycheck is late
+	IncomeSincePaycheck   float64            `json:"income_since_paycheck" money:"amount"` // Includes the paycheck itself
+	SpendingSincePaycheck float64            `json:"spending_since_paycheck" money:"amount"`
+	Remaining             float64            `json:"remaining" money:"amount"`                      // Income minus spending since the paycheck
+	DailyAllowance        *float64           `json:"daily_allowance,omitempty" money:"amount"`      // Remaining spread over the days until the next paycheck
+	SpendingByCategory    map[string]float64 `json:"spending_by_category,omitempty" money:"amount"` // Spending since the paycheck
+	ByCurrency            map[string]float64 `json:"remaining_by_currency,omitempty" money:"by_currency"`
+	MixedCurrencies       bool               `json:"mixed_currencies"`
+	Currencies            []string           `json:"currencies"`
+	CurrencyWarning       string             `json:"currency_warning,omitempty"`
+}
+
+// GetSinceLastPaycheck finds the most recent paycheck of the regular income series with the
+// largest amount (at least 3 similar monthly deposits from the same source, as in recurring
+// detection) and reports the income and spending since that paycheck and what is left of it
+// historyMonths: months before asOf scanned for the paycheck series (0 = 12)
+// asOfDate: the YYYY-MM-DD day to measure up to ("" = today); later transactions are ignored
+// Without a regular income pattern the result says so in Message rather than failing
+// Internal transfers and future-dated scheduled transactions are left out, as in the analyses
+func (db *DB) GetSinceLastPaycheck(ctx context.Context, historyMonths int, asOfDate string) (*PaycheckPeriod, error) {
+	if historyMonths < 0 {
+		return nil, invalidArgumentf("history months must not be negative, got %d", historyMonths)
+	}
+	if historyMonths == 0 {
+		historyMonths = defaultPaycheckHistoryMonths
+	}
+	asOf, err := parseAsOf("as_of date", asOfDate)
+	if err != nil {
+		return nil, err
+	}
+	// Transactions dated on as_of count whatever their time of day
+	end := asOf.AddDate(0, 0, 1)
+	historyStart := addMonthsClamped(asOf, -historyMonths)
+
+	incomeData, err := db.GetIncomeData(ctx, 0, false, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get income data: %w", err)
+	}
+
+	period := &PaycheckPeriod{
+		AsOf:          asOf.Format(dayLayout),
+		HistoryMonths: historyMonths,
+		Currencies:    []string{},
+	}
+
+	var charges []recurringCharge
+	for _, i := range incomeData {
+		if i.IsRefund {
+			continue
+		}
+		c, ok := newRecurringCharge(i.TransactionID, i.Description, i.Currency, i.CategoryName, i.Amount, i.Date)
+		if !ok || c.at.Before(historyStart) || !c.at.Before(end) {
+			continue
+		}
+		charges = append(charges, c)
+	}
+
+	// The salary is the monthly series with the largest deposits; ties go to the latest
+	var salary *RecurringSeries
+	for _, s := range detectRecurringSeries(charges, defaultRecurringMinOccurrences, defaultRecurringTolerancePct) {
+		if s.Interval != RecurringIntervalMonthly {
+			continue
+		}
+		if salary == nil || s.AverageAmount > salary.AverageAmount ||
+			(s.AverageAmount == salary.AverageAmount && s.LastDate > salary.LastDate) {
+			candidate := s
+			salary = &candidate
+		}
+	}
+	if salary == nil {
+		period.Message = fmt.Sprintf("No regular income pattern found in the %d months up to %s: a paycheck needs at least %d similar monthly deposits from the same source.",
+			historyMonths, period.AsOf, defaultRecurringMinOccurrences)
+		return period, nil
+	}
+
+	paycheckID := salary.TransactionIDs[len(salary.TransactionIDs)-1]
+	var paycheck recurringCharge
+	for _, c := range charges {
+		if c.id == paycheckID {
+			paycheck = c
+			break
+		}
+	}
+	paycheckDay := time.Date(paycheck.at.Year(), paycheck.at.Month(), paycheck.at.Day(), 0, 0, 0, 0, time.UTC)
+	next, err := time.Parse(dayLayout, salary.NextExpectedDate)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse next paycheck date %q: %w", salary.NextExpectedDate, err)
+	}
+
+	period.PaycheckFound = true
+	period.Payee = salary.Payee
+	period.PaycheckDate = paycheckDay.Format(dayLayout)
+	period.PaycheckAmount = paycheck.amount
+	period.PaycheckTransactionID = paycheck.id
+	period.AveragePaycheck = salary.AverageAmount
+	period.PaycheckCount = salary.Occurrences
+	period.NextExpectedDate = salary.NextExpectedDate
+	period.DaysSincePaycheck = int(math.Round(asOf.Sub(paycheckDay).Hours() / 24))
+	period.DaysUntilNextPaycheck = int(math.Round(next.Sub(asOf).Hours() / 24))
+	period.SpendingByCategory = make(map[string]float64)
+	period.ByCurrency = make(map[string]float64)
+
+	currencySet := make(map[string]struct{})
+	for _, i := range incomeData {
+		at, err := time.Parse(dateTimeLayout, i.Date)
+		if err != nil || at.Before(paycheck.at) || !at.Before(end) {
+			continue
+		}
+		period.IncomeSincePaycheck += i.Amount
+		if i.Currency != "" {
+			period.ByCurrency[i.Currency] += i.Amount
+			currencySet[i.Currency] = struct{}{}
+		}
+	}
+
+	spendingData, err := db.GetSpendingData(ctx, 0, false, nil, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get spending data: %w", err)
+	}
+	for _, s := range spendingData {
+		at, err := time.Parse(dateTimeLayout, s.Date)
+		if err != nil || at.Before(paycheck.at) || !at.Before(end) {
+			continue
+		}
+		period.SpendingSincePaycheck += s.Amount
+		period.SpendingByCategory[s.CategoryName] += s.Amount
+		if s.Currency != "" {
+			period.ByCurrency[s.Currency] -= s.Amount
+			currencySet[s.Currency] = struct{}{}
+		}
+	}
+
+	period.Remaining = period.IncomeSincePaycheck - period.SpendingSincePaycheck
+	switch {
+	case period.DaysUntilNextPaycheck > 0:
+		allowance := period.Remaining / float64(period.DaysUntilNextPaycheck)
+		period.DailyAllowance = &allowance
+		period.Message = fmt.Sprintf("%.2f left from the %s paycheck, %.2f a day until the next one around %s.",
+			period.Remaining, period.PaycheckDate, allowance, period.NextExpectedDate)
+	case period.DaysUntilNextPaycheck > -recurringGraceDays:
+		period.Message = fmt.Sprintf("%.2f left from the %s paycheck; the next one is due around %s.",
+			period.Remaining, period.PaycheckDate, period.NextExpectedDate)
+	default:
+		period.Message = fmt.Sprintf("%.2f left since the %s paycheck, but the next one was expected around %s, so the pattern may have stopped.",
+			period.Remaining, period.PaycheckDate, period.NextExpectedDate)
+	}
+
+	period.Currencies = sortedCurrencyKeys(currencySet)
+	period.MixedCurrencies = len(period.Currencies) > 1
+	if period.MixedCurrencies {
+		period.CurrencyWarning = "Totals combine multiple currencies; remaining_by_currency splits what is left by currency."
+	}
+
+	return period, nil
+}
@@ -0,0 +1,216 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// MoneyWiz links transactions to payee rows through a relationship column; the payee row keeps
+// its name in a versioned name column. Both are detected because schema versions differ
+var (
+	payeeLinkColumnCandidates = []string{"ZPAYEE2", "ZPAYEE"}
+	payeeNameColumnCandidates = []string{"ZNAME5", "ZNAME"}
+)
+
+// PayeeSpending represents spending aggregated for one payee
+type PayeeSpending struct {
+	Payee            string             `json:"payee"`
+	TotalSpending    float64            `json:"total_spending" money:"amount"`
+	TransactionCount int                `json:"transaction_count"`
+	AverageAmount    float64            `json:"average_amount" money:"amount"`
+	ByCurrency       map[string]float64 `json:"by_currency" money:"by_currency"`
+	LastDate         string             `json:"last_date"`
+}
+
+// PayeeSpendingAnalysis represents spending grouped by payee
+type PayeeSpendingAnalysis struct {
+	Months          int             `json:"months"`
+	PayeeCount      int             `json:"payee_count"`
+	TotalSpending   float64         `json:"total_spending" money:"amount"`
+	MixedCurrencies bool            `json:"mixed_currencies"`
+	Currencies      []string        `json:"currencies"`
+	CurrencyWarning string          `json:"currency_warning,omitempty"`
+	Payees          []PayeeSpending `json:"payees"`
+}
+
+// payeeSelect returns the SELECT expression and JOIN clause that read the payee name of
+// transaction alias t, or "NULL" and no join when the database has no payee relationship
+func (db *DB) payeeSelect(ctx context.Context) (string, string, error) {
+	columns, err := db.tableColumns(ctx, "ZSYNCOBJECT")
+	if err != nil {
+		return "", "", err
+	}
+
+	link := ""
+	for _, candidate := range payeeLinkColumnCandidates {
+		if columns[candidate] {
+			link = candidate
+			break
+		}
+	}
+	var names []string
+	for _, candidate := range payeeNameColumnCandidates {
+		if columns[candidate] {
+			names = append(names, "p."+candidate)
+		}
+	}
+	if link == "" || len(names) == 0 {
+		return "NULL", "", nil
+	}
+
+	selectExpr := names[0]
+	if len(names) > 1 {
+		selectExpr = "COALESCE(" + strings.Join(names, ", ") + ")"
+	}
+	return selectExpr, fmt.Sprintf("LEFT JOIN ZSYNCOBJECT p ON p.Z_PK = t.%s", link), nil
+}
+
+// payeeOrDescription returns the payee of a spending row, falling back to its description
+func payeeOrDescription(s SpendingData) string {
+	if payee := strings.TrimSpace(s.Payee); payee != "" {
+		return payee
+	}
+	return strings.TrimSpace(s.Description)
+}
+
+// aggregatePayees groups spending rows per payee, case-insensitively and keeping the first
+// spelling seen, and returns the unsorted groups with the set of currencies involved
+// Rows without a payee are grouped by their description
+func aggregatePayees(spendingData []SpendingData) ([]PayeeSpending, map[string]struct{}) {
+	byPayee := make(map[string]*PayeeSpending)
+	currencySet := make(map[string]struct{})
+
+	for _, s := range spendingData {
+		name := payeeOrDescription(s)
+		if name == "" {
+			name = "Unknown"
+		}
+		key := strings.ToLower(name)
+		if byPayee[key] == nil {
+			byPayee[key] = &PayeeSpending{
+				Payee:      name,
+				ByCurrency: make(map[string]float64),
+			}
+		}
+
+		payee := byPayee[key]
+		payee.TotalSpending += s.Amount
+		payee.TransactionCount++
+		if s.Currency != "" {
+			payee.ByCurrency[s.Currency] += s.Amount
+			currencySet[s.Currency] = struct{}{}
+		}
+		if s.Date > payee.LastDate {
+			payee.LastDate = s.Date
+		}
+	}
+
+	payees := make([]PayeeSpending, 0, len(byPayee))
+	for _, payee := range byPayee {
+		payee.AverageAmount = payee.TotalSpending / float64(payee.TransactionCount)
+		payees = append(payees, *payee)
+	}
+	return payees, currencySet
+}
+
+// sortPayeesBySpending orders payees by total spending, largest first
+func sortPayeesBySpending(payees []PayeeSpending) {
+	sort.Slice(payees, func(i, j int) bool {
+		if payees[i].TotalSpending != payees[j].TotalSpending {
+			return payees[i].TotalSpending > payees[j].TotalSpending
+		}
+		return payees[i].Payee < payees[j].Payee
+	})
+}
+
+// sortPayeesByCount orders payees by number of transactions, most frequent first
+func sortPayeesByCount(payees []PayeeSpending) {
+	sort.Slice(payees, func(i, j int) bool {
+		if payees[i].TransactionCount != payees[j].TransactionCount {
+			return payees[i].TransactionCount > payees[j].TransactionCount
+		}
+		if payees[i].TotalSpending != payees[j].TotalSpending {
+			return payees[i].TotalSpending > payees[j].TotalSpending
+		}
+		return payees[i].Payee < payees[j].Payee
+	})
+}
+
+// AnalyzeSpendingByPayee aggregates spending totals and counts per payee, largest first
+// Transactions without a payee are grouped by their description
+// months: number of months to analyze (0 = all historical data)
+func (db *DB) AnalyzeSpendingByPayee(ctx context.Context, months int) (*PayeeSpendingAnalysis, error) {
+	spendingData, err := db.GetSpendingData(ctx, months, false, nil, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get spending data: %w", err)
+	}
+
+	payees, currencySet := aggregatePayees(spendingData)
+	sortPayeesBySpending(payees)
+
+	analysis := &PayeeSpendingAnalysis{
+		Months:     months,
+		PayeeCount: len(payees),
+		Payees:     payees,
+	}
+	for _, payee := range payees {
+		analysis.TotalSpending += payee.TotalSpending
+	}
+
+	analysis.Currencies = sortedCurrencyKeys(currencySet)
+	analysis.MixedCurrencies = len(analysis.Currencies) > 1
+	if analysis.MixedCurrencies {
+		analysis.CurrencyWarning = "Totals combine multiple currencies. Prefer by_currency values for accurate interpretation."
+	}
+
+	return analysis, nil
+}
+
+// TopMerchants ranks payees both by how often they are paid and by how much is spent with them
+type TopMerchants struct {
+	Months          int             `json:"months"`
+	MerchantCount   int             `json:"merchant_count"`
+	MixedCurrencies bool            `json:"mixed_currencies"`
+	Currencies      []string        `json:"currencies"`
+	CurrencyWarning string          `json:"currency_warning,omitempty"`
+	ByCount         []PayeeSpending `json:"by_count"`
+	ByAmount        []PayeeSpending `json:"by_amount"`
+}
+
+// GetTopMerchants returns the payees with the most transactions and the payees with the most
+// spending, so frequent small purchases show up even when they never top the spending chart
+// months: number of months to analyze (0 = all historical data)
+// limit: maximum number of payees in each ranking (0 = all)
+func (db *DB) GetTopMerchants(ctx context.Context, months, limit int) (*TopMerchants, error) {
+	spendingData, err := db.GetSpendingData(ctx, months, false, nil, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get spending data: %w", err)
+	}
+
+	payees, currencySet := aggregatePayees(spendingData)
+	result := &TopMerchants{
+		Months:        months,
+		MerchantCount: len(payees),
+	}
+
+	byAmount := make([]PayeeSpending, len(payees))
+	copy(byAmount, payees)
+	sortPayeesBySpending(byAmount)
+	sortPayeesByCount(payees)
+	if limit > 0 && len(payees) > limit {
+		payees = payees[:limit]
+		byAmount = byAmount[:limit]
+	}
+	result.ByCount = payees
+	result.ByAmount = byAmount
+
+	result.Currencies = sortedCurrencyKeys(currencySet)
+	result.MixedCurrencies = len(result.Currencies) > 1
+	if result.MixedCurrencies {
+		result.CurrencyWarning = "Totals combine multiple currencies. Prefer by_currency values for accurate interpretation."
+	}
+
+	return result, nil
+}
@@ -0,0 +1,250 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+const (
+	RecurringIntervalMonthly = "monthly"
+	RecurringIntervalYearly  = "yearly"
+
+	defaultRecurringMinOccurrences = 3
+	defaultRecurringTolerancePct   = 5.0
+)
+
+// recurringCadence is the accepted gap between consecutive charges of a series, in days
+type recurringCadence struct {
+	interval string
+	minDays  float64
+	maxDays  float64
+}
+
+// Monthly charges drift with month length and weekends; yearly ones with leap years and renewals
+var recurringCadences = []recurringCadence{
+	{interval: RecurringIntervalMonthly, minDays: 25, maxDays: 35},
+	{interval: RecurringIntervalYearly, minDays: 350, maxDays: 380},
+}
+
+// RecurringSeries represents a subscription or regular bill detected from transaction history
+type RecurringSeries struct {
+	Payee             string  `json:"payee"` // Normalized merchant name
+	Currency          string  `json:"currency"`
+	CategoryName      string  `json:"category_name"` // Category of the most recent charge
+	Interval          string  `json:"interval"`      // "monthly" or "yearly"
+	AverageDays       float64 `json:"average_days"`  // Mean gap between charges
+	Occurrences       int     `json:"occurrences"`
+	AverageAmount     float64 `json:"average_amount" money:"amount"`
+	MonthlyEquivalent float64 `json:"monthly_equivalent" money:"amount"` // Average amount spread per month
+	FirstDate         string  `json:"first_date"`
+	LastDate          string  `json:"last_date"`
+	NextExpectedDate  string  `json:"next_expected_date"`
+	TransactionIDs    []int64 `json:"transaction_ids"`
+}
+
+// RecurringTransactionsReport represents the result of a recurring-transaction scan
+type RecurringTransactionsReport struct {
+	Months             int                `json:"months"`
+	MinOccurrences     int                `json:"min_occurrences"`
+	AmountTolerancePct float64            `json:"amount_tolerance_pct"`
+	SeriesCount        int                `json:"series_count"`
+	MonthlyTotal       map[string]float64 `json:"monthly_total_by_currency" money:"by_currency"` // Sum of monthly equivalents
+	Series             []RecurringSeries  `json:"series"`
+}
+
+// recurringCharge is one dated amount considered for recurring detection
+type recurringCharge struct {
+	id       int64
+	payee    string
+	currency string
+	category string
+	amount   float64
+	at       time.Time
+}
+
+// DetectRecurringTransactions finds subscriptions and regular bills: expenses from the same payee
+// and currency whose amounts stay within a tolerance of each other and that occur a minimum
+// number of times roughly every month (25-35 days) or every year (350-380 days)
+// months: number of months to look back (0 = all data)
+// minOccurrences: charges a series needs (0 = 3); raise it to leave out irregular shopping
+// amountTolerancePct: how far, in percent, amounts of one series may drift (0 = 5)
+func (db *DB) DetectRecurringTransactions(ctx context.Context, months int, minOccurrences int, amountTolerancePct float64) (*RecurringTransactionsReport, error) {
+	if minOccurrences < 0 || minOccurrences == 1 {
+		return nil, invalidArgumentf("min occurrences must be at least 2, got %d", minOccurrences)
+	}
+	if minOccurrences == 0 {
+		minOccurrences = defaultRecurringMinOccurrences
+	}
+	if amountTolerancePct < 0 || amountTolerancePct > 100 {
+		return nil, invalidArgumentf("amount tolerance must be between 0 and 100 percent, got %v", amountTolerancePct)
+	}
+	if amountTolerancePct == 0 {
+		amountTolerancePct = defaultRecurringTolerancePct
+	}
+
+	charges, err := db.recurringCharges(ctx, months, time.Time{})
+	if err != nil {
+		return nil, err
+	}
+
+	series := detectRecurringSeries(charges, minOccurrences, amountTolerancePct)
+	report := &RecurringTransactionsReport{
+		Months:             months,
+		MinOccurrences:     minOccurrences,
+		AmountTolerancePct: amountTolerancePct,
+		SeriesCount:        len(series),
+		MonthlyTotal:       make(map[string]float64),
+		Series:             series,
+	}
+	for _, s := range series {
+		report.MonthlyTotal[s.Currency] += s.MonthlyEquivalent
+	}
+
+	return report, nil
+}
+
+// recurringCharges returns the expenses of the last months (0 = all data) as charges for
+// recurring detection, leaving out those after until unless it is the zero time
+func (db *DB) recurringCharges(ctx context.Context, months int, until time.Time) ([]recurringCharge, error) {
+	spendingData, err := db.GetSpendingData(ctx, months, false, nil, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get spending data: %w", err)
+	}
+
+	var charges []recurringCharge
+	for _, s := range spendingData {
+		at, err := time.Parse(dateTimeLayout, s.Date)
+		if err != nil {
+			continue
+		}
+		if !until.IsZero() && at.After(until) {
+			continue
+		}
+		charges = append(charges, recurringCharge{
+			id:       s.TransactionID,
+			payee:    normalizePayee(payeeOrDescription(s)),
+			currency: s.Currency,
+			category: s.CategoryName,
+			amount:   s.Amount,
+			at:       at,
+		})
+	}
+	return charges, nil
+}
+
+// detectRecurringSeries groups charges by payee and currency, splits each group into runs of
+// similar amounts and keeps the runs whose gaps all match one cadence
+// Series are returned with the largest monthly equivalent first
+func detectRecurringSeries(charges []recurringCharge, minOccurrences int, amountTolerancePct float64) []RecurringSeries {
+	groups := make(map[string][]recurringCharge)
+	for _, c := range charges {
+		if c.payee == "" {
+			continue
+		}
+		key := c.payee + "\x00" + c.currency
+		groups[key] = append(groups[key], c)
+	}
+
+	series := []RecurringSeries{}
+	for _, key := range sortedCurrencyKeys(groups) {
+		for _, cluster := range clusterByAmount(groups[key], amountTolerancePct) {
+			if len(cluster) < minOccurrences {
+				continue
+			}
+			if s, ok := newRecurringSeries(cluster); ok {
+				series = append(series, s)
+			}
+		}
+	}
+
+	sort.Slice(series, func(i, j int) bool {
+		if series[i].MonthlyEquivalent != series[j].MonthlyEquivalent {
+			return series[i].MonthlyEquivalent > series[j].MonthlyEquivalent
+		}
+		return series[i].Payee < series[j].Payee
+	})
+	return series
+}
+
+// clusterByAmount splits charges into runs whose amounts are within amountTolerancePct percent
+// of the smallest amount in the run
+func clusterByAmount(charges []recurringCharge, amountTolerancePct float64) [][]recurringCharge {
+	sorted := append([]recurringCharge(nil), charges...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].amount < sorted[j].amount
+	})
+
+	var clusters [][]recurringCharge
+	for _, c := range sorted {
+		last := len(clusters) - 1
+		if last >= 0 {
+			base := math.Abs(clusters[last][0].amount)
+			if math.Abs(c.amount)-base <= base*amountTolerancePct/100 {
+				clusters[last] = append(clusters[last], c)
+				continue
+			}
+		}
+		clusters = append(clusters, []recurringCharge{c})
+	}
+	return clusters
+}
+
+// newRecurringSeries reports whether every gap between the charges (in date order) fits one
+// cadence and, if so, summarizes them
+func newRecurringSeries(charges []recurringCharge) (RecurringSeries, bool) {
+	sort.Slice(charges, func(i, j int) bool {
+		if !charges[i].at.Equal(charges[j].at) {
+			return charges[i].at.Before(charges[j].at)
+		}
+		return charges[i].id < charges[j].id
+	})
+
+	for _, cadence := range recurringCadences {
+		totalDays := 0.0
+		matches := true
+		for i := 1; i < len(charges); i++ {
+			days := charges[i].at.Sub(charges[i-1].at).Hours() / 24
+			if days < cadence.minDays || days > cadence.maxDays {
+				matches = false
+				break
+			}
+			totalDays += days
+		}
+		if !matches {
+			continue
+		}
+
+		first, last := charges[0], charges[len(charges)-1]
+		s := RecurringSeries{
+			Payee:          last.payee,
+			Currency:       last.currency,
+			CategoryName:   last.category,
+			Interval:       cadence.interval,
+			AverageDays:    totalDays / float64(len(charges)-1),
+			Occurrences:    len(charges),
+			FirstDate:      first.at.Format(dayLayout),
+			LastDate:       last.at.Format(dayLayout),
+			TransactionIDs: make([]int64, 0, len(charges)),
+		}
+		total := 0.0
+		for _, c := range charges {
+			total += c.amount
+			s.TransactionIDs = append(s.TransactionIDs, c.id)
+		}
+		s.AverageAmount = total / float64(len(charges))
+
+		if cadence.interval == RecurringIntervalYearly {
+			s.MonthlyEquivalent = s.AverageAmount / 12
+			s.NextExpectedDate = last.at.AddDate(1, 0, 0).Format(dayLayout)
+		} else {
+			s.MonthlyEquivalent = s.AverageAmount
+			s.NextExpectedDate = addMonthsClamped(last.at, 1).Format(dayLayout)
+		}
+		return s, true
+	}
+
+	return RecurringSeries{}, false
+}
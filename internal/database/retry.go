@@ -0,0 +1,105 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// maxBusyAttempts is how many times a query runs before a busy or locked database is reported
+const maxBusyAttempts = 3
+
+// busyRetryBackoff is the wait before the second attempt; it doubles before each later one
+var busyRetryBackoff = 100 * time.Millisecond
+
+// retryOnBusy runs fn, running it again with backoff while it fails because MoneyWiz has the
+// database busy or locked (e.g. while syncing) past the busy timeout
+// Any other error, and the error of the last attempt, is returned as is
+func retryOnBusy(ctx context.Context, fn func() error) error {
+	backoff := busyRetryBackoff
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || !isBusy(err) || attempt == maxBusyAttempts {
+			return err
+		}
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+		backoff *= 2
+	}
+}
+
+// runQuery runs fn like retryOnBusy and logs how long it took, retries included, under label:
+// always when it took at least the slow query threshold, and every time when queries are logged
+// label names the query, usually the method running it, e.g. "GetAccounts"
+func (db *DB) runQuery(ctx context.Context, label string, fn func() error) error {
+	start := time.Now()
+	err := retryOnBusy(ctx, fn)
+	elapsed := time.Since(start)
+
+	switch {
+	case db.slowQueryThreshold > 0 && elapsed >= db.slowQueryThreshold:
+		log.Printf("Slow query: %s took %s", label, elapsed.Round(time.Millisecond))
+	case db.logQueries:
+		log.Printf("Query %s took %s", label, elapsed.Round(time.Millisecond))
+	}
+	return err
+}
+
+// errStopRows is returned by the function passed to queryEach to stop reading rows early
+var errStopRows = errors.New("stop reading rows")
+
+// queryEach runs a query and calls each for every row, retrying while the database is busy
+// SQLite only steps into the data when the first row is read, so a busy or locked database can
+// surface while iterating rather than from the query itself; the whole statement runs again as
+// long as no row has been handed to each yet. Once one has, an error is returned as is, since
+// the rows already seen cannot be taken back. The time spent, reading the rows included, is logged
+// under label as by runQuery
+func (db *DB) queryEach(ctx context.Context, label, query string, args []any, each func(rows *sql.Rows) error) error {
+	var eachErr error
+	err := db.runQuery(ctx, label, func() error {
+		rows, err := db.reader().QueryContext(ctx, query, args...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		delivered := false
+		for rows.Next() {
+			delivered = true
+			if err := each(rows); err != nil {
+				if !errors.Is(err, errStopRows) {
+					eachErr = err
+				}
+				return nil
+			}
+		}
+		if err := rows.Err(); err != nil {
+			if delivered {
+				eachErr = err
+				return nil
+			}
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return eachErr
+}
+
+// isBusy reports whether SQLite gave up on a query because another connection held a lock
+func isBusy(err error) bool {
+	var sqliteErr sqlite3.Error
+	return errors.As(err, &sqliteErr) && (sqliteErr.Code == sqlite3.ErrBusy || sqliteErr.Code == sqlite3.ErrLocked)
+}
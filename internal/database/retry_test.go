@@ -0,0 +1,98 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+func TestRetryOnBusy(t *testing.T) {
+	defer func(backoff time.Duration) { busyRetryBackoff = backoff }(busyRetryBackoff)
+	busyRetryBackoff = time.Millisecond
+
+	busy := sqlite3.Error{Code: sqlite3.ErrBusy}
+	other := errors.New("no such table: ZSYNCOBJECT")
+
+	tests := []struct {
+		name      string
+		errs      []error
+		wantCalls int
+		wantErr   error
+	}{
+		{name: "success", errs: []error{nil}, wantCalls: 1},
+		{name: "busy then success", errs: []error{busy, nil}, wantCalls: 2},
+		{name: "locked then success", errs: []error{sqlite3.Error{Code: sqlite3.ErrLocked}, nil}, wantCalls: 2},
+		{name: "busy every time", errs: []error{busy, busy, busy, busy}, wantCalls: maxBusyAttempts, wantErr: busy},
+		{name: "other errors are not retried", errs: []error{other, nil}, wantCalls: 1, wantErr: other},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			err := retryOnBusy(context.Background(), func() error {
+				calls++
+				return tt.errs[calls-1]
+			})
+			if calls != tt.wantCalls {
+				t.Fatalf("calls = %d, want %d", calls, tt.wantCalls)
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRetryOnBusyStopsWhenContextIsDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	calls := 0
+	err := retryOnBusy(ctx, func() error {
+		calls++
+		return sqlite3.Error{Code: sqlite3.ErrBusy}
+	})
+	if calls != 1 || !isBusy(err) {
+		t.Fatalf("calls = %d, err = %v; want one busy attempt", calls, err)
+	}
+}
+
+func TestRunQueryLogsQueryNameAndDuration(t *testing.T) {
+	var logs bytes.Buffer
+	defer func(w io.Writer) { log.SetOutput(w) }(log.Writer())
+	log.SetOutput(&logs)
+
+	db := newFixtureDB(t)
+	defer db.Close()
+
+	if _, err := db.GetAccounts(context.Background()); err != nil {
+		t.Fatalf("GetAccounts: %v", err)
+	}
+	if logs.Len() != 0 {
+		t.Fatalf("queries logged without logging enabled: %q", logs.String())
+	}
+
+	db.logQueries = true
+	if _, err := db.GetAccounts(context.Background()); err != nil {
+		t.Fatalf("GetAccounts: %v", err)
+	}
+	if !strings.Contains(logs.String(), "Query GetAccounts took ") {
+		t.Fatalf("logs = %q, want the GetAccounts query and its duration", logs.String())
+	}
+
+	logs.Reset()
+	db.logQueries = false
+	db.slowQueryThreshold = time.Nanosecond
+	if _, err := db.GetAccounts(context.Background()); err != nil {
+		t.Fatalf("GetAccounts: %v", err)
+	}
+	if !strings.Contains(logs.String(), "Slow query: GetAccounts took ") {
+		t.Fatalf("logs = %q, want GetAccounts reported as slow", logs.String())
+	}
+}
@@ -1,44 +1,161 @@
 package database
 
 import (
+	"context"
 	"fmt"
 	"math"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
 )
 
+// SavingsTargets are the goals savings recommendations measure against
+// Zero values fall back to defaultSavingsTargets: a 20% savings rate and 3 months of expenses
+type SavingsTargets struct {
+	SavingsRate         float64 `json:"savings_rate"`          // Percentage of income to save
+	EmergencyFundMonths float64 `json:"emergency_fund_months"` // Months of expenses to keep as an emergency fund
+	// Benchmarks maps tier names to the minimum savings rate of the tier, e.g.
+	// {"good": 15, "great": 25, "excellent": 40}; empty uses "moderate" at half the savings rate
+	// target and "excellent" at the target. A rate below every tier counts as "low"
+	Benchmarks map[string]float64 `json:"benchmarks,omitempty"`
+}
+
+var defaultSavingsTargets = SavingsTargets{SavingsRate: 20, EmergencyFundMonths: 3}
+
+// withDefaults fills unset targets and rejects out-of-range ones
+func (targets SavingsTargets) withDefaults() (SavingsTargets, error) {
+	if targets.SavingsRate < 0 || targets.SavingsRate > 100 {
+		return targets, invalidArgumentf("target savings rate must be between 0 and 100, got %v", targets.SavingsRate)
+	}
+	if targets.EmergencyFundMonths < 0 {
+		return targets, invalidArgumentf("target emergency fund months must not be negative, got %v", targets.EmergencyFundMonths)
+	}
+	if targets.SavingsRate == 0 {
+		targets.SavingsRate = defaultSavingsTargets.SavingsRate
+	}
+	if targets.EmergencyFundMonths == 0 {
+		targets.EmergencyFundMonths = defaultSavingsTargets.EmergencyFundMonths
+	}
+	rates := make(map[float64]string, len(targets.Benchmarks))
+	for name, rate := range targets.Benchmarks {
+		if strings.TrimSpace(name) == "" {
+			return targets, invalidArgumentf("savings benchmark names must not be empty")
+		}
+		if rate < 0 || rate > 100 {
+			return targets, invalidArgumentf("savings benchmark %q must be between 0 and 100, got %v", name, rate)
+		}
+		if other, ok := rates[rate]; ok {
+			return targets, invalidArgumentf("savings benchmarks %q and %q share the rate %v", other, name, rate)
+		}
+		rates[rate] = name
+	}
+	if len(targets.Benchmarks) == 0 {
+		targets.Benchmarks = defaultSavingsBenchmarks(targets.SavingsRate)
+	}
+	return targets, nil
+}
+
+// savingsTierBelowBenchmarks names the tier of a savings rate below every benchmark
+const savingsTierBelowBenchmarks = "low"
+
+// savingsBenchmark is one tier of a savings benchmark table
+type savingsBenchmark struct {
+	name    string
+	minRate float64
+}
+
+// defaultSavingsBenchmarks returns the tiers used when none are given, relative to the target
+func defaultSavingsBenchmarks(targetRate float64) map[string]float64 {
+	return map[string]float64{
+		"moderate":  targetRate / 2,
+		"excellent": targetRate,
+	}
+}
+
+// benchmarkTiers returns the benchmark tiers from the lowest minimum rate to the highest
+func (targets SavingsTargets) benchmarkTiers() []savingsBenchmark {
+	benchmarks := targets.Benchmarks
+	if len(benchmarks) == 0 {
+		benchmarks = defaultSavingsBenchmarks(targets.SavingsRate)
+	}
+	tiers := make([]savingsBenchmark, 0, len(benchmarks))
+	for name, rate := range benchmarks {
+		tiers = append(tiers, savingsBenchmark{name: strings.TrimSpace(name), minRate: rate})
+	}
+	sort.Slice(tiers, func(i, j int) bool {
+		return tiers[i].minRate < tiers[j].minRate
+	})
+	return tiers
+}
+
+// savingsTierTitle turns a tier name into a recommendation title, e.g. "great" into
+// "Great Savings Rate"
+func savingsTierTitle(name string) string {
+	runes := []rune(name)
+	runes[0] = unicode.ToUpper(runes[0])
+	return string(runes) + " Savings Rate"
+}
+
 // SavingsRecommendation represents a savings recommendation
 type SavingsRecommendation struct {
 	Type        string  `json:"type"` // "warning", "suggestion", "positive"
 	Title       string  `json:"title"`
 	Description string  `json:"description"`
-	Priority    string  `json:"priority"` // "high", "medium", "low"
-	Impact      float64 `json:"impact"`   // Potential savings amount
+	Priority    string  `json:"priority"`              // "high", "medium", "low"
+	Impact      float64 `json:"impact" money:"amount"` // Potential savings amount
+	Tier        string  `json:"tier,omitempty"`        // Savings rate benchmark tier, on the savings rate recommendation
 }
 
 // SavingsAnalysis represents comprehensive savings analysis
 type SavingsAnalysis struct {
 	Period                 string                  `json:"period"`
-	TotalIncome            float64                 `json:"total_income"`
-	TotalSpending          float64                 `json:"total_spending"`
-	NetSavings             float64                 `json:"net_savings"`
-	SavingsRate            float64                 `json:"savings_rate"` // Percentage
-	AverageMonthlyIncome   float64                 `json:"average_monthly_income"`
-	AverageMonthlySpending float64                 `json:"average_monthly_spending"`
+	TotalIncome            float64                 `json:"total_income" money:"amount"`
+	TotalSpending          float64                 `json:"total_spending" money:"amount"`
+	NetSavings             float64                 `json:"net_savings" money:"amount"`
+	SavingsRate            float64                 `json:"savings_rate"`     // Percentage, after excluded months are removed
+	RawSavingsRate         float64                 `json:"raw_savings_rate"` // Percentage, including excluded months
+	AverageMonthlyIncome   float64                 `json:"average_monthly_income" money:"amount"`
+	AverageMonthlySpending float64                 `json:"average_monthly_spending" money:"amount"`
 	MixedCurrencies        bool                    `json:"mixed_currencies"`
 	Currencies             []string                `json:"currencies"`
 	PrimaryCurrency        string                  `json:"primary_currency,omitempty"`
 	CurrencyWarning        string                  `json:"currency_warning,omitempty"`
-	ByCurrency             map[string]CurrencyFlow `json:"by_currency"`
+	ByCurrency             map[string]CurrencyFlow `json:"by_currency" money:"by_currency"`
+	ExcludedMonths         []ExcludedMonth         `json:"excluded_months,omitempty"`
+	MonthlySavingsRate     []MonthlySavingsRate    `json:"monthly_savings_rate"`   // Oldest first, so one great month cannot hide several bad ones
+	Refunds                float64                 `json:"refunds" money:"amount"` // Positive amounts in expense categories, counted as income unless RefundsNetted
+	RefundCount            int                     `json:"refund_count"`
+	RefundsNetted          bool                    `json:"refunds_netted"` // Refunds were subtracted from their category's spending instead
 	TopSpendingCategories  []CategorySpending      `json:"top_spending_categories"`
+	Targets                SavingsTargets          `json:"targets"` // Goals the recommendations were measured against
 	Recommendations        []SavingsRecommendation `json:"recommendations"`
 }
 
+// ExcludedMonth represents a month left out of a savings analysis and what it contained
+type ExcludedMonth struct {
+	Month      string  `json:"month"` // YYYY-MM format
+	Income     float64 `json:"income" money:"amount"`
+	Spending   float64 `json:"spending" money:"amount"`
+	NetSavings float64 `json:"net_savings" money:"amount"`
+}
+
+// MonthlySavingsRate represents the income, spending and savings rate of one analyzed month
+type MonthlySavingsRate struct {
+	Month       string  `json:"month"` // YYYY-MM format
+	Income      float64 `json:"income" money:"amount"`
+	Spending    float64 `json:"spending" money:"amount"`
+	NetSavings  float64 `json:"net_savings" money:"amount"`
+	SavingsRate float64 `json:"savings_rate"` // Percentage of income kept (0 when there was no income)
+}
+
 type CurrencyFlow struct {
 	Currency               string             `json:"currency"`
-	TotalIncome            float64            `json:"total_income"`
-	TotalSpending          float64            `json:"total_spending"`
-	NetSavings             float64            `json:"net_savings"`
-	AverageMonthlyIncome   float64            `json:"average_monthly_income"`
-	AverageMonthlySpending float64            `json:"average_monthly_spending"`
+	TotalIncome            float64            `json:"total_income" money:"amount"`
+	TotalSpending          float64            `json:"total_spending" money:"amount"`
+	NetSavings             float64            `json:"net_savings" money:"amount"`
+	AverageMonthlyIncome   float64            `json:"average_monthly_income" money:"amount"`
+	AverageMonthlySpending float64            `json:"average_monthly_spending" money:"amount"`
 	IncomeTransactions     int                `json:"income_transactions"`
 	ExpenseTransactions    int                `json:"expense_transactions"`
 	TopSpendingCategories  []CategorySpending `json:"top_spending_categories"`
@@ -46,26 +163,84 @@ type CurrencyFlow struct {
 
 // CategorySpending represents spending by category
 type CategorySpending struct {
-	CategoryName     string  `json:"category_name"`
-	TotalAmount      float64 `json:"total_amount"`
-	Percentage       float64 `json:"percentage"` // Percentage of total spending
-	TransactionCount int     `json:"transaction_count"`
+	CategoryName          string  `json:"category_name"`
+	TotalAmount           float64 `json:"total_amount" money:"amount"`
+	Percentage            float64 `json:"percentage"` // Percentage of total spending
+	TransactionCount      int     `json:"transaction_count"`
+	AverageMonthly        float64 `json:"average_monthly" money:"amount"`         // Total spread over the months analyzed
+	AveragePerTransaction float64 `json:"average_per_transaction" money:"amount"` // A high value with few transactions points to one big purchase
+}
+
+// SavingsOptions configures AnalyzeSavings; the zero value analyzes all data with the default
+// targets and every spending category
+type SavingsOptions struct {
+	Months           int             // Number of months to analyze (0 = all historical data)
+	ExcludeMonths    []string        // YYYY-MM months (e.g. a one-off big purchase) left out of the totals and rates
+	IncludeTransfers bool            // Count transfers between own accounts as income and spending (excluded by default)
+	IncludeScheduled bool            // Count future-dated (scheduled) transactions (excluded by default)
+	Targets          SavingsTargets  // Savings-rate and emergency-fund goals for the recommendations (zero values use the defaults)
+	Rollup           bool            // Rank top spending categories with subcategories counted under their top-level category
+	NetRefunds       bool            // Subtract refunds from their category's spending instead of counting them as income
+	TopCategories    int             // Number of top spending categories to return (0 = all categories)
+	Aliases          CategoryAliases // Rank the categories they match under their display names, merging them (nil = none)
 }
 
 // AnalyzeSavings analyzes income vs spending and provides recommendations
-// months: number of months to analyze (0 = all historical data)
-func (db *DB) AnalyzeSavings(months int) (*SavingsAnalysis, error) {
+func (db *DB) AnalyzeSavings(ctx context.Context, opts SavingsOptions) (*SavingsAnalysis, error) {
+	return inReadSnapshot(ctx, db, func(snap *DB) (*SavingsAnalysis, error) {
+		return snap.analyzeSavings(ctx, opts)
+	})
+}
+
+// analyzeSavings is AnalyzeSavings without the read snapshot
+func (db *DB) analyzeSavings(ctx context.Context, opts SavingsOptions) (*SavingsAnalysis, error) {
+	targets, err := opts.Targets.withDefaults()
+	if err != nil {
+		return nil, err
+	}
+	if opts.TopCategories < 0 {
+		return nil, invalidArgumentf("top categories must not be negative, got %d", opts.TopCategories)
+	}
+
+	excluded := make(map[string]*ExcludedMonth, len(opts.ExcludeMonths))
+	for _, month := range opts.ExcludeMonths {
+		if _, err := time.Parse(monthLayout, month); err != nil {
+			return nil, invalidArgumentf("invalid excluded month %q: expected YYYY-MM", month)
+		}
+		excluded[month] = &ExcludedMonth{Month: month}
+	}
+
 	// Get income and spending data
-	incomeData, err := db.GetIncomeData(months)
+	incomeData, err := db.GetIncomeData(ctx, opts.Months, opts.IncludeTransfers, opts.IncludeScheduled)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get income data: %w", err)
 	}
 
-	spendingData, err := db.GetSpendingData(months)
+	spendingData, err := db.GetSpendingData(ctx, opts.Months, opts.IncludeTransfers, nil, opts.IncludeScheduled)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get spending data: %w", err)
 	}
 
+	var refunds float64
+	refundCount := 0
+	for _, i := range incomeData {
+		if i.IsRefund && excluded[i.Month] == nil {
+			refunds += i.Amount
+			refundCount++
+		}
+	}
+	if opts.NetRefunds {
+		incomeData, spendingData = netRefundsAgainstSpending(incomeData, spendingData)
+	}
+	if opts.Rollup {
+		if err := db.rollUpCategories(ctx, spendingData); err != nil {
+			return nil, err
+		}
+	}
+	if err := opts.Aliases.apply(spendingData); err != nil {
+		return nil, err
+	}
+
 	// Calculate totals
 	var totalIncome float64
 	var totalSpending float64
@@ -77,8 +252,24 @@ func (db *DB) AnalyzeSavings(months int) (*SavingsAnalysis, error) {
 
 	// Track unique months to calculate actual month span when months is 0
 	uniqueMonths := make(map[string]bool)
+	monthly := make(map[string]*MonthlySavingsRate)
+	monthFor := func(month string) *MonthlySavingsRate {
+		if monthly[month] == nil {
+			monthly[month] = &MonthlySavingsRate{Month: month}
+		}
+		return monthly[month]
+	}
+
+	// Raw totals include excluded months so both rates can be reported
+	var rawIncome float64
+	var rawSpending float64
 
 	for _, i := range incomeData {
+		rawIncome += i.Amount
+		if excludedMonth := excluded[i.Month]; excludedMonth != nil {
+			excludedMonth.Income += i.Amount
+			continue
+		}
 		totalIncome += i.Amount
 		if i.Currency != "" {
 			if byCurrency[i.Currency] == nil {
@@ -89,10 +280,16 @@ func (db *DB) AnalyzeSavings(months int) (*SavingsAnalysis, error) {
 		}
 		if i.Month != "" {
 			uniqueMonths[i.Month] = true
+			monthFor(i.Month).Income += i.Amount
 		}
 	}
 
 	for _, s := range spendingData {
+		rawSpending += s.Amount
+		if excludedMonth := excluded[s.Month]; excludedMonth != nil {
+			excludedMonth.Spending += s.Amount
+			continue
+		}
 		totalSpending += s.Amount
 		spendingByCategory[s.CategoryName]++
 		spendingAmountByCategory[s.CategoryName] += s.Amount
@@ -111,6 +308,7 @@ func (db *DB) AnalyzeSavings(months int) (*SavingsAnalysis, error) {
 		}
 		if s.Month != "" {
 			uniqueMonths[s.Month] = true
+			monthFor(s.Month).Spending += s.Amount
 		}
 	}
 
@@ -119,25 +317,57 @@ func (db *DB) AnalyzeSavings(months int) (*SavingsAnalysis, error) {
 	if totalIncome > 0 {
 		savingsRate = (netSavings / totalIncome) * 100
 	}
+	rawSavingsRate := 0.0
+	if rawIncome > 0 {
+		rawSavingsRate = ((rawIncome - rawSpending) / rawIncome) * 100
+	}
+
+	monthlySavingsRate := monthlySavingsRates(monthly, excluded)
+
+	var excludedMonths []ExcludedMonth
+	for _, excludedMonth := range excluded {
+		excludedMonth.NetSavings = excludedMonth.Income - excludedMonth.Spending
+		excludedMonths = append(excludedMonths, *excludedMonth)
+	}
+	sort.Slice(excludedMonths, func(i, j int) bool {
+		return excludedMonths[i].Month < excludedMonths[j].Month
+	})
 
 	// Calculate month count: use provided months, or calculate from data if months is 0
-	monthCount := float64(months)
-	if months == 0 {
+	monthCount := float64(opts.Months)
+	if opts.Months == 0 {
 		monthCount = float64(len(uniqueMonths))
 		if monthCount == 0 {
 			monthCount = 1 // Avoid division by zero
 		}
+	} else {
+		// Excluded months that had activity no longer count toward the averages
+		for _, excludedMonth := range excludedMonths {
+			if excludedMonth.Income != 0 || excludedMonth.Spending != 0 {
+				monthCount--
+			}
+		}
+		if monthCount < 1 {
+			monthCount = 1
+		}
 	}
 
 	// Calculate averages
 	averageMonthlyIncome := totalIncome / monthCount
 	averageMonthlySpending := totalSpending / monthCount
 
-	topSpendingCategories := buildTopSpendingCategories(
+	// Recommendations look at every category, whatever number is returned
+	rankedCategories := buildTopSpendingCategories(
 		spendingAmountByCategory,
 		spendingByCategory,
 		totalSpending,
+		monthCount,
+		0,
 	)
+	topSpendingCategories := rankedCategories
+	if opts.TopCategories > 0 && len(topSpendingCategories) > opts.TopCategories {
+		topSpendingCategories = topSpendingCategories[:opts.TopCategories]
+	}
 
 	currencies := sortedCurrencyKeys(byCurrency)
 	byCurrencyValues := make(map[string]CurrencyFlow, len(byCurrency))
@@ -150,6 +380,8 @@ func (db *DB) AnalyzeSavings(months int) (*SavingsAnalysis, error) {
 			spendingAmountByCurrencyAndCategory[currency],
 			spendingByCurrencyAndCategory[currency],
 			summary.TotalSpending,
+			monthCount,
+			opts.TopCategories,
 		)
 		byCurrencyValues[currency] = *summary
 	}
@@ -161,14 +393,15 @@ func (db *DB) AnalyzeSavings(months int) (*SavingsAnalysis, error) {
 		totalSpending,
 		averageMonthlyIncome,
 		averageMonthlySpending,
-		topSpendingCategories,
+		rankedCategories,
 		monthCount,
+		targets,
 	)
 
 	// Format period string
 	periodStr := "All historical data"
-	if months > 0 {
-		periodStr = fmt.Sprintf("Last %d months", months)
+	if opts.Months > 0 {
+		periodStr = fmt.Sprintf("Last %d months", opts.Months)
 	} else if monthCount > 0 {
 		periodStr = fmt.Sprintf("All data (%d months)", int(monthCount))
 	}
@@ -188,6 +421,7 @@ func (db *DB) AnalyzeSavings(months int) (*SavingsAnalysis, error) {
 		TotalSpending:          totalSpending,
 		NetSavings:             netSavings,
 		SavingsRate:            savingsRate,
+		RawSavingsRate:         rawSavingsRate,
 		AverageMonthlyIncome:   averageMonthlyIncome,
 		AverageMonthlySpending: averageMonthlySpending,
 		MixedCurrencies:        len(currencies) > 1,
@@ -195,11 +429,73 @@ func (db *DB) AnalyzeSavings(months int) (*SavingsAnalysis, error) {
 		PrimaryCurrency:        primaryCurrency,
 		CurrencyWarning:        currencyWarning,
 		ByCurrency:             byCurrencyValues,
+		ExcludedMonths:         excludedMonths,
+		MonthlySavingsRate:     monthlySavingsRate,
+		Refunds:                refunds,
+		RefundCount:            refundCount,
+		RefundsNetted:          opts.NetRefunds,
 		TopSpendingCategories:  topSpendingCategories,
+		Targets:                targets,
 		Recommendations:        recommendations,
 	}, nil
 }
 
+// netRefundsAgainstSpending moves refunds out of income and into spending as negative amounts,
+// so they reduce the total of the category they were refunded to
+func netRefundsAgainstSpending(income []IncomeData, spending []SpendingData) ([]IncomeData, []SpendingData) {
+	var kept []IncomeData
+	for _, i := range income {
+		if !i.IsRefund {
+			kept = append(kept, i)
+			continue
+		}
+		spending = append(spending, SpendingData{
+			TransactionID: i.TransactionID,
+			AccountID:     i.AccountID,
+			CategoryID:    i.CategoryID,
+			CategoryName:  i.CategoryName,
+			Description:   i.Description,
+			Amount:        -i.Amount,
+			Currency:      i.Currency,
+			Date:          i.Date,
+			Month:         i.Month,
+			Year:          i.Year,
+		})
+	}
+	return kept, spending
+}
+
+// monthlySavingsRates returns the savings rate of every month from the first to the last month
+// with activity, oldest first; months without activity in between count as zero and excluded
+// months are left out
+func monthlySavingsRates(monthly map[string]*MonthlySavingsRate, excluded map[string]*ExcludedMonth) []MonthlySavingsRate {
+	rates := []MonthlySavingsRate{}
+	if len(monthly) == 0 {
+		return rates
+	}
+	months := make([]string, 0, len(monthly))
+	for month := range monthly {
+		months = append(months, month)
+	}
+	sort.Strings(months)
+
+	for _, month := range monthSpan(months[0], months[len(months)-1]) {
+		if excluded[month] != nil {
+			continue
+		}
+		rate := MonthlySavingsRate{Month: month}
+		if m := monthly[month]; m != nil {
+			rate = *m
+		}
+		rate.NetSavings = rate.Income - rate.Spending
+		if rate.Income > 0 {
+			rate.SavingsRate = rate.NetSavings / rate.Income * 100
+		}
+		rates = append(rates, rate)
+	}
+	return rates
+}
+
 // generateSavingsRecommendations generates recommendations based on financial data
 func (db *DB) generateSavingsRecommendations(
 	savingsRate float64,
@@ -209,8 +505,10 @@ func (db *DB) generateSavingsRecommendations(
 	avgMonthlySpending float64,
 	topCategories []CategorySpending,
 	monthCount float64,
+	targets SavingsTargets,
 ) []SavingsRecommendation {
 	var recommendations []SavingsRecommendation
+	targetSavings := totalIncome * targets.SavingsRate / 100
 
 	// Savings rate recommendations
 	if savingsRate < 0 {
@@ -221,30 +519,8 @@ func (db *DB) generateSavingsRecommendations(
 			Priority:    "high",
 			Impact:      math.Abs(totalSpending - totalIncome),
 		})
-	} else if savingsRate < 10 {
-		recommendations = append(recommendations, SavingsRecommendation{
-			Type:        "warning",
-			Title:       "Low Savings Rate",
-			Description: fmt.Sprintf("Your savings rate is %.1f%%. Financial experts recommend saving at least 20%% of income. Consider reducing discretionary spending.", savingsRate),
-			Priority:    "high",
-			Impact:      (totalIncome * 0.20) - (totalIncome - totalSpending),
-		})
-	} else if savingsRate < 20 {
-		recommendations = append(recommendations, SavingsRecommendation{
-			Type:        "suggestion",
-			Title:       "Moderate Savings Rate",
-			Description: fmt.Sprintf("Your savings rate is %.1f%%. You're on the right track! Aim for 20%%+ for better financial security.", savingsRate),
-			Priority:    "medium",
-			Impact:      (totalIncome * 0.20) - (totalIncome - totalSpending),
-		})
 	} else {
-		recommendations = append(recommendations, SavingsRecommendation{
-			Type:        "positive",
-			Title:       "Excellent Savings Rate",
-			Description: fmt.Sprintf("Great job! Your savings rate is %.1f%%, which exceeds the recommended 20%%. Keep up the good work!", savingsRate),
-			Priority:    "low",
-			Impact:      0,
-		})
+		recommendations = append(recommendations, savingsRateRecommendation(savingsRate, totalIncome-totalSpending, targetSavings, targets))
 	}
 
 	// Top spending category recommendations
@@ -297,13 +573,13 @@ func (db *DB) generateSavingsRecommendations(
 	// Income stability recommendation
 	if avgMonthlyIncome > 0 && avgMonthlySpending > 0 {
 		monthsOfExpenses := (totalIncome - totalSpending) / avgMonthlySpending
-		if monthsOfExpenses < 3 {
+		if monthsOfExpenses < targets.EmergencyFundMonths {
 			recommendations = append(recommendations, SavingsRecommendation{
 				Type:        "suggestion",
 				Title:       "Build Emergency Fund",
-				Description: fmt.Sprintf("Aim to save 3-6 months of expenses (%.2f per month) as an emergency fund. You currently have about %.1f months saved.", avgMonthlySpending, monthsOfExpenses),
+				Description: fmt.Sprintf("Aim to save %g months of expenses (%.2f per month) as an emergency fund. You currently have about %.1f months saved.", targets.EmergencyFundMonths, avgMonthlySpending, monthsOfExpenses),
 				Priority:    "high",
-				Impact:      avgMonthlySpending * 3, // 3 months target
+				Impact:      avgMonthlySpending * targets.EmergencyFundMonths,
 			})
 		}
 	}
@@ -311,10 +587,62 @@ func (db *DB) generateSavingsRecommendations(
 	return recommendations
 }
 
+// savingsRateRecommendation places a non-negative savings rate in the highest benchmark tier whose
+// minimum it reaches; below every tier it is a warning, below the target a suggestion, and at or
+// above the target positive
+func savingsRateRecommendation(savingsRate, netSavings, targetSavings float64, targets SavingsTargets) SavingsRecommendation {
+	tiers := targets.benchmarkTiers()
+	tier := -1
+	for i, benchmark := range tiers {
+		if savingsRate >= benchmark.minRate {
+			tier = i
+		}
+	}
+
+	// The next tier up, if any, is what to aim for
+	next := ""
+	if tier+1 < len(tiers) {
+		next = fmt.Sprintf(" The %s tier starts at %.0f%%.", tiers[tier+1].name, tiers[tier+1].minRate)
+	}
+
+	if tier < 0 {
+		return SavingsRecommendation{
+			Type:        "warning",
+			Title:       savingsTierTitle(savingsTierBelowBenchmarks),
+			Description: fmt.Sprintf("Your savings rate is %.1f%%, well below your %.0f%% target.%s Consider reducing discretionary spending.", savingsRate, targets.SavingsRate, next),
+			Priority:    "high",
+			Impact:      targetSavings - netSavings,
+			Tier:        savingsTierBelowBenchmarks,
+		}
+	}
+
+	benchmark := tiers[tier]
+	if savingsRate < targets.SavingsRate {
+		return SavingsRecommendation{
+			Type:        "suggestion",
+			Title:       savingsTierTitle(benchmark.name),
+			Description: fmt.Sprintf("Your savings rate is %.1f%%, in the %s tier (%.0f%%+). You're on the right track! Aim for your %.0f%% target for better financial security.%s", savingsRate, benchmark.name, benchmark.minRate, targets.SavingsRate, next),
+			Priority:    "medium",
+			Impact:      targetSavings - netSavings,
+			Tier:        benchmark.name,
+		}
+	}
+	return SavingsRecommendation{
+		Type:        "positive",
+		Title:       savingsTierTitle(benchmark.name),
+		Description: fmt.Sprintf("Great job! Your savings rate is %.1f%%, in the %s tier (%.0f%%+), which meets your %.0f%% target. Keep up the good work!%s", savingsRate, benchmark.name, benchmark.minRate, targets.SavingsRate, next),
+		Priority:    "low",
+		Impact:      0,
+		Tier:        benchmark.name,
+	}
+}
+
 func buildTopSpendingCategories(
 	amountByCategory map[string]float64,
 	countByCategory map[string]int,
 	totalSpending float64,
+	monthCount float64,
+	limit int, // 0 = all categories
 ) []CategorySpending {
 	type catSpend struct {
 		name   string
@@ -331,16 +659,16 @@ func buildTopSpendingCategories(
 		})
 	}
 
-	for i := 0; i < len(topCategories)-1; i++ {
-		for j := i + 1; j < len(topCategories); j++ {
-			if topCategories[i].amount < topCategories[j].amount {
-				topCategories[i], topCategories[j] = topCategories[j], topCategories[i]
-			}
+	// Largest spend first; ties break on name so the top-N cut is deterministic
+	sort.Slice(topCategories, func(i, j int) bool {
+		if topCategories[i].amount != topCategories[j].amount {
+			return topCategories[i].amount > topCategories[j].amount
 		}
-	}
+		return topCategories[i].name < topCategories[j].name
+	})
 
-	topN := 5
-	if len(topCategories) < topN {
+	topN := limit
+	if topN == 0 || len(topCategories) < topN {
 		topN = len(topCategories)
 	}
 
@@ -350,11 +678,21 @@ func buildTopSpendingCategories(
 		if totalSpending > 0 {
 			percentage = (topCategories[i].amount / totalSpending) * 100
 		}
+		averagePerTransaction := 0.0
+		if topCategories[i].count > 0 {
+			averagePerTransaction = topCategories[i].amount / float64(topCategories[i].count)
+		}
+		averageMonthly := 0.0
+		if monthCount > 0 {
+			averageMonthly = topCategories[i].amount / monthCount
+		}
 		out = append(out, CategorySpending{
-			CategoryName:     topCategories[i].name,
-			TotalAmount:      topCategories[i].amount,
-			Percentage:       percentage,
-			TransactionCount: topCategories[i].count,
+			CategoryName:          topCategories[i].name,
+			TotalAmount:           topCategories[i].amount,
+			Percentage:            percentage,
+			TransactionCount:      topCategories[i].count,
+			AverageMonthly:        averageMonthly,
+			AveragePerTransaction: averagePerTransaction,
 		})
 	}
 
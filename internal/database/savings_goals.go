@@ -0,0 +1,227 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+// MoneyWiz versions that support goals register a goal entity in Core Data's Z_PRIMARYKEY table
+// and store goal rows in ZSYNCOBJECT. The column names are detected because schema versions differ
+var (
+	goalNameColumnCandidates     = []string{"ZNAME", "ZNAME2"}
+	goalTargetColumnCandidates   = []string{"ZGOALAMOUNT", "ZTARGETAMOUNT", "ZTARGET"}
+	goalAccountColumnCandidates  = []string{"ZACCOUNT", "ZACCOUNT2"}
+	goalDeadlineColumnCandidates = []string{"ZGOALDATE", "ZTARGETDATE", "ZENDDATE"}
+)
+
+// SavingsGoal represents the progress of one savings goal towards its target
+type SavingsGoal struct {
+	ID                          int64    `json:"id"`
+	Name                        string   `json:"name"`
+	TargetAmount                float64  `json:"target_amount" money:"amount"`
+	AccountID                   int64    `json:"account_id,omitempty"`
+	AccountName                 string   `json:"account_name,omitempty"`
+	Currency                    string   `json:"currency,omitempty"`
+	Deadline                    string   `json:"deadline,omitempty"` // YYYY-MM-DD
+	CurrentAmount               float64  `json:"current_amount" money:"amount"`
+	AmountRemaining             float64  `json:"amount_remaining" money:"amount"`
+	ProgressPercent             float64  `json:"progress_percent"`
+	AverageMonthlyContribution  float64  `json:"average_monthly_contribution" money:"amount"`
+	RequiredMonthlyContribution *float64 `json:"required_monthly_contribution,omitempty" money:"amount"`
+	ProjectedDate               string   `json:"projected_date,omitempty"` // YYYY-MM-DD at the average contribution
+	Status                      string   `json:"status"`                   // reached, on_pace, behind, no_deadline, or unlinked
+	Message                     string   `json:"message"`
+}
+
+// SavingsGoalsReport represents all savings goals found in the database
+type SavingsGoalsReport struct {
+	GoalEntity    string        `json:"goal_entity,omitempty"` // Core Data entity the goals were read from
+	HistoryMonths int           `json:"history_months"`
+	Note          string        `json:"note,omitempty"`
+	Goals         []SavingsGoal `json:"goals"`
+}
+
+// goalRow is a goal as stored, before its progress is calculated
+type goalRow struct {
+	id        int64
+	name      string
+	target    float64
+	accountID int64
+	deadline  time.Time
+}
+
+// GetSavingsGoals reports the progress of every savings goal: the linked account's balance against
+// the target, and whether the account's average monthly net change over the last historyMonths
+// months of activity reaches the target by the deadline
+// Databases without goals return an empty list with a note rather than an error
+func (db *DB) GetSavingsGoals(ctx context.Context, historyMonths int) (*SavingsGoalsReport, error) {
+	if historyMonths <= 0 {
+		historyMonths = 6
+	}
+
+	report := &SavingsGoalsReport{
+		HistoryMonths: historyMonths,
+		Goals:         []SavingsGoal{},
+	}
+
+	entity, entityName, err := db.entityLike(ctx, "%Goal%")
+	if err != nil {
+		return nil, err
+	}
+	if entityName == "" {
+		report.Note = "This database does not store savings goals."
+		return report, nil
+	}
+	report.GoalEntity = entityName
+
+	goals, err := db.goalRows(ctx, entity)
+	if err != nil {
+		return nil, err
+	}
+	if goals == nil {
+		report.Note = fmt.Sprintf("The %s entity has no target amount column, so goal progress cannot be read.", entityName)
+		return report, nil
+	}
+
+	now := time.Now().UTC()
+	for _, row := range goals {
+		goal, err := db.savingsGoalProgress(ctx, row, historyMonths, now)
+		if err != nil {
+			return nil, err
+		}
+		report.Goals = append(report.Goals, goal)
+	}
+
+	return report, nil
+}
+
+// goalRows reads the goals of the given entity, or returns nil when it has no target column
+func (db *DB) goalRows(ctx context.Context, entity int) ([]goalRow, error) {
+	columns, err := db.tableColumns(ctx, "ZSYNCOBJECT")
+	if err != nil {
+		return nil, err
+	}
+
+	targetColumn := firstColumn(columns, goalTargetColumnCandidates...)
+	if targetColumn == "" {
+		return nil, nil
+	}
+	nameExpr, accountExpr, deadlineExpr := "NULL", "NULL", "NULL"
+	if column := firstColumn(columns, goalNameColumnCandidates...); column != "" {
+		nameExpr = column
+	}
+	if column := firstColumn(columns, goalAccountColumnCandidates...); column != "" {
+		accountExpr = column
+	}
+	if column := firstColumn(columns, goalDeadlineColumnCandidates...); column != "" {
+		deadlineExpr = column
+	}
+
+	query := fmt.Sprintf(`
+		SELECT Z_PK, %s, %s, %s, %s
+		FROM ZSYNCOBJECT
+		WHERE Z_ENT = ?
+		ORDER BY Z_PK
+	`, nameExpr, targetColumn, accountExpr, deadlineExpr)
+
+	goals := []goalRow{}
+	err = db.queryEach(ctx, "goalRows", query, []any{entity}, func(rows *sql.Rows) error {
+		var row goalRow
+		var name sql.NullString
+		var target, deadline sql.NullFloat64
+		var accountID sql.NullInt64
+		if err := rows.Scan(&row.id, &name, &target, &accountID, &deadline); err != nil {
+			return fmt.Errorf("failed to scan savings goal: %w", err)
+		}
+		row.name = name.String
+		if row.name == "" {
+			row.name = fmt.Sprintf("Goal %d", row.id)
+		}
+		row.target = target.Float64
+		row.accountID = accountID.Int64
+		if deadline.Valid {
+			row.deadline = coreDataToTime(deadline.Float64)
+		}
+		goals = append(goals, row)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query savings goals: %w", err)
+	}
+
+	return goals, nil
+}
+
+// savingsGoalProgress measures a goal against its linked account, reusing the account
+// projection so goals and depletion forecasts agree on the average monthly change
+func (db *DB) savingsGoalProgress(ctx context.Context, row goalRow, historyMonths int, now time.Time) (SavingsGoal, error) {
+	goal := SavingsGoal{
+		ID:              row.id,
+		Name:            row.name,
+		TargetAmount:    row.target,
+		AccountID:       row.accountID,
+		AmountRemaining: row.target,
+	}
+	if !row.deadline.IsZero() {
+		goal.Deadline = row.deadline.Format(dayLayout)
+	}
+
+	if row.accountID == 0 {
+		goal.Status = "unlinked"
+		goal.Message = "The goal is not linked to an account, so its progress is unknown."
+		return goal, nil
+	}
+
+	projection, err := db.ProjectAccountDepletion(ctx, row.accountID, historyMonths, row.target)
+	if errors.Is(err, ErrNotFound) {
+		goal.Status = "unlinked"
+		goal.Message = "The goal's linked account no longer exists, so its progress is unknown."
+		return goal, nil
+	}
+	if err != nil {
+		return SavingsGoal{}, fmt.Errorf("failed to project savings goal %d: %w", row.id, err)
+	}
+
+	goal.AccountName = projection.AccountName
+	goal.Currency = projection.Currency
+	goal.CurrentAmount = projection.CurrentBalance
+	goal.AmountRemaining = math.Max(row.target-projection.CurrentBalance, 0)
+	if row.target > 0 {
+		goal.ProgressPercent = math.Min(projection.CurrentBalance/row.target*100, 100)
+	}
+	goal.AverageMonthlyContribution = projection.AverageMonthlyNetChange
+	if projection.Status == "accumulating" {
+		goal.ProjectedDate = projection.ProjectedDate
+	}
+
+	switch {
+	case goal.AmountRemaining < 0.005:
+		goal.Status = "reached"
+		goal.Message = "The goal has been reached."
+	case row.deadline.IsZero():
+		goal.Status = "no_deadline"
+		goal.Message = "The goal has no deadline; projected_date shows when it is reached at the average contribution."
+	case !row.deadline.After(now):
+		goal.Status = "behind"
+		goal.Message = fmt.Sprintf("The deadline has passed with %.2f still to save.", goal.AmountRemaining)
+	default:
+		monthsLeft := row.deadline.Sub(now).Hours() / 24 / averageDaysPerMonth
+		required := goal.AmountRemaining / monthsLeft
+		goal.RequiredMonthlyContribution = &required
+		if goal.AverageMonthlyContribution >= required {
+			goal.Status = "on_pace"
+			goal.Message = fmt.Sprintf("Contributing %.2f per month on average, above the %.2f needed to reach the goal by %s.",
+				goal.AverageMonthlyContribution, required, goal.Deadline)
+		} else {
+			goal.Status = "behind"
+			goal.Message = fmt.Sprintf("Contributing %.2f per month on average, but %.2f is needed to reach the goal by %s.",
+				goal.AverageMonthlyContribution, required, goal.Deadline)
+		}
+	}
+
+	return goal, nil
+}
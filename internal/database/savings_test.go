@@ -1,6 +1,9 @@
 package database
 
-import "testing"
+import (
+	"math"
+	"testing"
+)
 
 func TestGenerateSavingsRecommendationsNegativeSavingsRate(t *testing.T) {
 	db := &DB{}
@@ -13,6 +16,7 @@ func TestGenerateSavingsRecommendationsNegativeSavingsRate(t *testing.T) {
 		0,
 		nil,
 		1,
+		defaultSavingsTargets,
 	)
 
 	assertRecommendationTitles(t, got, []string{
@@ -38,6 +42,7 @@ func TestGenerateSavingsRecommendationsExcellentSavingsRate(t *testing.T) {
 		200,
 		nil,
 		1,
+		defaultSavingsTargets,
 	)
 
 	assertRecommendationTitles(t, got, []string{
@@ -69,6 +74,7 @@ func TestGenerateSavingsRecommendationsCategoryAndRatioSignals(t *testing.T) {
 		950,
 		topCategories,
 		2,
+		defaultSavingsTargets,
 	)
 
 	assertRecommendationTitles(t, got, []string{
@@ -94,6 +100,96 @@ func TestGenerateSavingsRecommendationsCategoryAndRatioSignals(t *testing.T) {
 	}
 }
 
+func TestGenerateSavingsRecommendationsUseCustomTargets(t *testing.T) {
+	db := &DB{}
+
+	targets, err := SavingsTargets{SavingsRate: 30, EmergencyFundMonths: 6}.withDefaults()
+	if err != nil {
+		t.Fatalf("withDefaults: %v", err)
+	}
+
+	got := db.generateSavingsRecommendations(
+		25,
+		10000,
+		7500,
+		1000,
+		500,
+		nil,
+		5,
+		targets,
+	)
+
+	assertRecommendationTitles(t, got, []string{
+		"Moderate Savings Rate",
+		"Build Emergency Fund",
+	})
+
+	moderate := findRecommendationByTitle(t, got, "Moderate Savings Rate")
+	if moderate.Impact != 500 {
+		t.Fatalf("moderate savings impact = %v, want 500", moderate.Impact)
+	}
+	emergency := findRecommendationByTitle(t, got, "Build Emergency Fund")
+	if emergency.Impact != 3000 {
+		t.Fatalf("emergency fund impact = %v, want 3000", emergency.Impact)
+	}
+
+	defaults, err := SavingsTargets{}.withDefaults()
+	if err != nil || defaults.SavingsRate != 20 || defaults.EmergencyFundMonths != 3 {
+		t.Fatalf("default targets = %+v, %v; want 20%% and 3 months", defaults, err)
+	}
+	if _, err := (SavingsTargets{SavingsRate: 120}).withDefaults(); err == nil {
+		t.Fatal("expected an error for a savings rate above 100")
+	}
+}
+
+func TestGenerateSavingsRecommendationsUseBenchmarkTiers(t *testing.T) {
+	db := &DB{}
+
+	targets, err := SavingsTargets{Benchmarks: map[string]float64{"good": 15, "great": 25, "excellent": 40}}.withDefaults()
+	if err != nil {
+		t.Fatalf("withDefaults: %v", err)
+	}
+
+	tests := []struct {
+		rate     float64
+		title    string
+		tier     string
+		kind     string
+		priority string
+	}{
+		{rate: 10, title: "Low Savings Rate", tier: "low", kind: "warning", priority: "high"},
+		{rate: 17, title: "Good Savings Rate", tier: "good", kind: "suggestion", priority: "medium"},
+		{rate: 30, title: "Great Savings Rate", tier: "great", kind: "positive", priority: "low"},
+		{rate: 45, title: "Excellent Savings Rate", tier: "excellent", kind: "positive", priority: "low"},
+	}
+	for _, tt := range tests {
+		income := 10000.0
+		spending := income * (1 - tt.rate/100)
+		got := db.generateSavingsRecommendations(tt.rate, income, spending, 0, 0, nil, 1, targets)
+		rate := findRecommendationByTitle(t, got, tt.title)
+		if rate.Tier != tt.tier || rate.Type != tt.kind || rate.Priority != tt.priority {
+			t.Fatalf("rate %v: recommendation = %+v, want tier %q, type %q and priority %q", tt.rate, rate, tt.tier, tt.kind, tt.priority)
+		}
+	}
+
+	// Below the 20% target the impact is the gap to it
+	got := db.generateSavingsRecommendations(17, 10000, 8300, 0, 0, nil, 1, targets)
+	if good := findRecommendationByTitle(t, got, "Good Savings Rate"); math.Abs(good.Impact-300) > 1e-9 {
+		t.Fatalf("good savings impact = %v, want 300", good.Impact)
+	}
+
+	defaults, err := SavingsTargets{}.withDefaults()
+	if err != nil || defaults.Benchmarks["moderate"] != 10 || defaults.Benchmarks["excellent"] != 20 {
+		t.Fatalf("default benchmarks = %+v, %v; want moderate 10 and excellent 20", defaults.Benchmarks, err)
+	}
+	if _, err := (SavingsTargets{Benchmarks: map[string]float64{"good": 15, "fine": 15}}).withDefaults(); err == nil {
+		t.Fatal("expected an error for benchmarks sharing a rate")
+	}
+	if _, err := (SavingsTargets{Benchmarks: map[string]float64{" ": 15}}).withDefaults(); err == nil {
+		t.Fatal("expected an error for an empty benchmark name")
+	}
+}
+
 func assertRecommendationTitles(t *testing.T, got []SavingsRecommendation, want []string) {
 	t.Helper()
 
@@ -0,0 +1,215 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Where scheduled transactions were read from
+const (
+	ScheduledSourceEntity      = "scheduled_entity" // MoneyWiz's scheduled transaction entities
+	ScheduledSourceFutureDated = "future_dated"     // Transactions dated after as_of, when there are none
+)
+
+// MoneyWiz keeps scheduled transactions in their own entities, registered in Z_PRIMARYKEY, with
+// the date of the next occurrence in a column whose name differs between versions
+var (
+	scheduledEntityPattern               = "%Schedule%"
+	scheduledNextDateColumnCandidates    = []string{"ZNEXTDATE", "ZNEXTOCCURRENCEDATE", "ZNEXTEXECUTIONDATE", "ZDATE1", "ZSTARTDATE"}
+	scheduledAmountColumnCandidates      = []string{"ZAMOUNT1", "ZAMOUNT"}
+	scheduledDescriptionColumnCandidates = []string{"ZDESC2", "ZDESC", "ZNAME"}
+	scheduledAccountColumnCandidates     = []string{"ZACCOUNT2", "ZACCOUNT"}
+)
+
+// ScheduledTransaction represents the next occurrence of a scheduled transaction
+type ScheduledTransaction struct {
+	ID           int64   `json:"id"`
+	NextDate     string  `json:"next_date"` // YYYY-MM-DD
+	DaysUntil    int     `json:"days_until"`
+	Overdue      bool    `json:"overdue"`               // The next occurrence is before as_of and has not been entered yet
+	Amount       float64 `json:"amount" money:"amount"` // Negative for expenses
+	Description  string  `json:"description,omitempty"`
+	Payee        string  `json:"payee,omitempty"`
+	CategoryName string  `json:"category_name,omitempty"`
+	AccountID    int64   `json:"account_id,omitempty"`
+	AccountName  string  `json:"account_name,omitempty"`
+	Currency     string  `json:"currency,omitempty"`
+	Entity       string  `json:"entity,omitempty"` // Core Data entity, for scheduled entities
+}
+
+// ScheduledTransactionsReport represents the scheduled transactions due up to a horizon
+type ScheduledTransactionsReport struct {
+	AsOf               string                 `json:"as_of"`   // YYYY-MM-DD
+	Through            string                 `json:"through"` // YYYY-MM-DD; empty without a horizon
+	Source             string                 `json:"source"`  // "scheduled_entity" or "future_dated"
+	Entities           []string               `json:"entities,omitempty"`
+	Count              int                    `json:"count"`
+	OverdueCount       int                    `json:"overdue_count"`
+	IncomeByCurrency   map[string]float64     `json:"income_by_currency" money:"by_currency"`
+	SpendingByCurrency map[string]float64     `json:"spending_by_currency" money:"by_currency"` // Positive amounts
+	Note               string                 `json:"note,omitempty"`
+	Transactions       []ScheduledTransaction `json:"transactions"` // Soonest first
+}
+
+// GetScheduledTransactions lists the scheduled transactions due by days after asOfDate, each with
+// its next occurrence, amount, payee and account, as actually planned rather than inferred from
+// history like the recurring series of ForecastCashFlow
+// They are read from MoneyWiz's scheduled transaction entities; occurrences before asOfDate are
+// flagged as overdue. Databases without those entities fall back to the transactions dated
+// after asOfDate, which is how the analyses recognize scheduled transactions
+// days: how far ahead to list (0 = every scheduled transaction)
+// asOfDate: the YYYY-MM-DD day to count from ("" = today)
+func (db *DB) GetScheduledTransactions(ctx context.Context, days int, asOfDate string) (*ScheduledTransactionsReport, error) {
+	if days < 0 {
+		return nil, invalidArgumentf("days must not be negative, got %d", days)
+	}
+	asOf, err := parseAsOf("as_of date", asOfDate)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &ScheduledTransactionsReport{
+		AsOf:               asOf.Format(dayLayout),
+		IncomeByCurrency:   make(map[string]float64),
+		SpendingByCurrency: make(map[string]float64),
+		Transactions:       []ScheduledTransaction{},
+	}
+	// through is the first moment past the horizon
+	through := time.Time{}
+	if days > 0 {
+		through = asOf.AddDate(0, 0, days+1)
+		report.Through = asOf.AddDate(0, 0, days).Format(dayLayout)
+	}
+
+	entities, err := db.entitiesLike(ctx, scheduledEntityPattern)
+	if err != nil {
+		return nil, err
+	}
+	columns, err := db.tableColumns(ctx, "ZSYNCOBJECT")
+	if err != nil {
+		return nil, err
+	}
+
+	var transactions []ScheduledTransaction
+	if len(entities) > 0 {
+		report.Source = ScheduledSourceEntity
+		ids := make([]int, 0, len(entities))
+		for entity, name := range entities {
+			ids = append(ids, entity)
+			report.Entities = append(report.Entities, name)
+		}
+		sort.Ints(ids)
+		sort.Strings(report.Entities)
+
+		dateColumn := firstColumn(columns, scheduledNextDateColumnCandidates...)
+		amountColumn := firstColumn(columns, scheduledAmountColumnCandidates...)
+		if dateColumn == "" || amountColumn == "" {
+			report.Note = fmt.Sprintf("The %s entities have no next date or amount column, so scheduled transactions cannot be read.", strings.Join(report.Entities, ", "))
+			return report, nil
+		}
+		entityList := make([]string, len(ids))
+		for i, id := range ids {
+			entityList[i] = fmt.Sprint(id)
+		}
+		condition := fmt.Sprintf("t.Z_ENT IN (%s) AND t.%s IS NOT NULL", strings.Join(entityList, ", "), dateColumn)
+		transactions, err = db.scheduledRows(ctx, columns, dateColumn, amountColumn, condition, through, entities)
+	} else {
+		report.Source = ScheduledSourceFutureDated
+		report.Note = "This database has no scheduled transaction entities, so transactions dated after as_of are listed instead."
+		condition := fmt.Sprintf("t.Z_ENT IN (%s) AND t.ZAMOUNT1 IS NOT NULL AND t.ZDATE1 >= ?", transactionEntities(true))
+		transactions, err = db.scheduledRows(ctx, columns, "ZDATE1", "ZAMOUNT1", condition, through, nil, timeToCoreData(asOf.AddDate(0, 0, 1)))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, txn := range transactions {
+		next, err := time.Parse(dayLayout, txn.NextDate)
+		if err != nil {
+			continue
+		}
+		txn.DaysUntil = int(next.Sub(asOf).Hours() / 24)
+		txn.Overdue = next.Before(asOf)
+		if txn.Overdue {
+			report.OverdueCount++
+		}
+		switch {
+		case txn.Currency == "":
+		case txn.Amount >= 0:
+			report.IncomeByCurrency[txn.Currency] += txn.Amount
+		default:
+			report.SpendingByCurrency[txn.Currency] -= txn.Amount
+		}
+		report.Transactions = append(report.Transactions, txn)
+	}
+	report.Count = len(report.Transactions)
+
+	return report, nil
+}
+
+// scheduledRows reads the rows matching condition (with its args) whose dateColumn is before
+// through (the zero time = no limit), soonest first, with their payee, first category and account
+func (db *DB) scheduledRows(ctx context.Context, columns map[string]bool, dateColumn, amountColumn, condition string, through time.Time, entities map[int]string, args ...any) ([]ScheduledTransaction, error) {
+	payeeExpr, payeeJoin, err := db.payeeSelect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	descriptionExpr, accountExpr := "NULL", "NULL"
+	if column := firstColumn(columns, scheduledDescriptionColumnCandidates...); column != "" {
+		descriptionExpr = "t." + column
+	}
+	if column := firstColumn(columns, scheduledAccountColumnCandidates...); column != "" {
+		accountExpr = "t." + column
+	}
+
+	if !through.IsZero() {
+		condition += fmt.Sprintf(" AND t.%s < ?", dateColumn)
+		args = append(args, timeToCoreData(through))
+	}
+	query := fmt.Sprintf(`
+		SELECT t.Z_PK, t.Z_ENT, t.%[1]s, t.%[2]s, %[3]s, %[4]s, a.Z_PK, a.ZNAME, a.ZCURRENCYNAME,
+			(SELECT c.ZNAME2 FROM ZCATEGORYASSIGMENT ca
+				JOIN ZSYNCOBJECT c ON c.Z_PK = ca.ZCATEGORY AND c.Z_ENT = 19
+				WHERE ca.ZTRANSACTION = t.Z_PK ORDER BY ca.ZCATEGORY LIMIT 1)
+		FROM ZSYNCOBJECT t
+		LEFT JOIN ZSYNCOBJECT a ON a.Z_PK = %[5]s AND a.Z_ENT IN (10, 11, 12, 13, 15, 16)
+		%[6]s
+		WHERE %[7]s
+		ORDER BY t.%[1]s, t.Z_PK
+	`, dateColumn, amountColumn, descriptionExpr, payeeExpr, accountExpr, payeeJoin, condition)
+
+	var transactions []ScheduledTransaction
+	err = db.queryEach(ctx, "scheduledRows", query, args, func(rows *sql.Rows) error {
+		var txn ScheduledTransaction
+		var entity int
+		var date float64
+		var amount sql.NullFloat64
+		var description, payee, accountName, currency, category sql.NullString
+		var accountID sql.NullInt64
+		if err := rows.Scan(&txn.ID, &entity, &date, &amount, &description, &payee, &accountID, &accountName, &currency, &category); err != nil {
+			return fmt.Errorf("failed to scan scheduled transaction: %w", err)
+		}
+		txn.NextDate = coreDataToTime(date).Format(dayLayout)
+		txn.Amount = amount.Float64
+		txn.Description = strings.TrimSpace(description.String)
+		txn.Payee = strings.TrimSpace(payee.String)
+		txn.CategoryName = category.String
+		txn.Entity = entities[entity]
+		if accountID.Valid {
+			txn.AccountID = accountID.Int64
+			txn.AccountName = accountName.String
+			txn.Currency = db.accountCurrency(currency)
+		}
+		transactions = append(transactions, txn)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query scheduled transactions: %w", err)
+	}
+
+	return transactions, nil
+}
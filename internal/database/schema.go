@@ -0,0 +1,105 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// tableColumns returns the set of column names of a table (empty if the table does not exist)
+// MoneyWiz schema versions differ, so optional columns are detected before they are queried
+func (db *DB) tableColumns(ctx context.Context, table string) (map[string]bool, error) {
+	columns := make(map[string]bool)
+	err := db.queryEach(ctx, "tableColumns", fmt.Sprintf("PRAGMA table_info(%q)", table), nil, func(rows *sql.Rows) error {
+		var cid int
+		var name string
+		var columnType string
+		var notNull int
+		var defaultValue any
+		var primaryKey int
+		if err := rows.Scan(&cid, &name, &columnType, &notNull, &defaultValue, &primaryKey); err != nil {
+			return fmt.Errorf("failed to scan columns of %s: %w", table, err)
+		}
+		columns[name] = true
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read columns of %s: %w", table, err)
+	}
+
+	return columns, nil
+}
+
+// firstExistingColumn returns the first candidate column present on the table, or "" if none is
+func (db *DB) firstExistingColumn(ctx context.Context, table string, candidates ...string) (string, error) {
+	columns, err := db.tableColumns(ctx, table)
+	if err != nil {
+		return "", err
+	}
+	return firstColumn(columns, candidates...), nil
+}
+
+// firstColumn returns the first candidate present in a column set, or "" if none is
+func firstColumn(columns map[string]bool, candidates ...string) string {
+	for _, candidate := range candidates {
+		if columns[candidate] {
+			return candidate
+		}
+	}
+	return ""
+}
+
+// entityLike returns the first Core Data entity whose name matches a LIKE pattern, read from
+// Z_PRIMARYKEY; the name is empty when the database has no such entity
+func (db *DB) entityLike(ctx context.Context, pattern string) (int, string, error) {
+	columns, err := db.tableColumns(ctx, "Z_PRIMARYKEY")
+	if err != nil {
+		return 0, "", err
+	}
+	if !columns["Z_ENT"] || !columns["Z_NAME"] {
+		return 0, "", nil
+	}
+
+	var entity int
+	var name string
+	err = db.runQuery(ctx, "entityLike", func() error {
+		return db.reader().QueryRowContext(ctx,
+			`SELECT Z_ENT, Z_NAME FROM Z_PRIMARYKEY WHERE Z_NAME LIKE ? ORDER BY Z_ENT LIMIT 1`, pattern,
+		).Scan(&entity, &name)
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, "", nil
+	}
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to look up %s entity: %w", pattern, err)
+	}
+	return entity, name, nil
+}
+
+// entitiesLike returns every Core Data entity whose name matches a LIKE pattern, by entity number,
+// read from Z_PRIMARYKEY; empty when the database has none
+func (db *DB) entitiesLike(ctx context.Context, pattern string) (map[int]string, error) {
+	columns, err := db.tableColumns(ctx, "Z_PRIMARYKEY")
+	if err != nil {
+		return nil, err
+	}
+	entities := make(map[int]string)
+	if !columns["Z_ENT"] || !columns["Z_NAME"] {
+		return entities, nil
+	}
+
+	err = db.queryEach(ctx, "entitiesLike", `SELECT Z_ENT, Z_NAME FROM Z_PRIMARYKEY WHERE Z_NAME LIKE ? ORDER BY Z_ENT`, []any{pattern}, func(rows *sql.Rows) error {
+		var entity int
+		var name string
+		if err := rows.Scan(&entity, &name); err != nil {
+			return fmt.Errorf("failed to scan %s entity: %w", pattern, err)
+		}
+		entities[entity] = name
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up %s entities: %w", pattern, err)
+	}
+	return entities, nil
+}
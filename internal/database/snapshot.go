@@ -0,0 +1,44 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// queryer runs the queries of a DB: the connection pool, or the read transaction of a snapshot
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// reader returns where queries run: the read transaction inside inReadSnapshot, the pool otherwise
+func (db *DB) reader() queryer {
+	if db.snapshot != nil {
+		return db.snapshot
+	}
+	return db.conn
+}
+
+// inReadSnapshot runs fn against a copy of db whose queries all go through one read transaction
+// Each query on the pool may run on a different connection and see a different state of the
+// file, e.g. when MoneyWiz syncs between them; inside the transaction SQLite reads every query from
+// the same snapshot, so balances match the transactions counted alongside them. Calls made from
+// within a snapshot reuse it
+func inReadSnapshot[T any](ctx context.Context, db *DB, fn func(snap *DB) (T, error)) (T, error) {
+	if db.snapshot != nil {
+		return fn(db)
+	}
+
+	var zero T
+	tx, err := db.conn.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return zero, fmt.Errorf("failed to begin read transaction: %w", err)
+	}
+	// Nothing was written, so the transaction is always rolled back
+	defer tx.Rollback()
+
+	snap := *db
+	snap.snapshot = tx
+	return fn(&snap)
+}
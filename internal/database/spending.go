@@ -1,162 +1,214 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"sort"
+	"time"
 )
 
 // SpendingData represents spending data for trend analysis
 type SpendingData struct {
-	CategoryID   int64   `json:"category_id"`
-	CategoryName string  `json:"category_name"`
-	Amount       float64 `json:"amount"`
-	Currency     string  `json:"currency"`
-	Date         string  `json:"date"`
-	Month        string  `json:"month"` // YYYY-MM format
-	Year         string  `json:"year"`  // YYYY format
+	TransactionID int64   `json:"transaction_id"`
+	AccountID     int64   `json:"account_id"`
+	CategoryID    int64   `json:"category_id"`
+	CategoryName  string  `json:"category_name"`
+	Description   string  `json:"description"`
+	Payee         string  `json:"payee,omitempty"`
+	Amount        float64 `json:"amount" money:"amount"`
+	Currency      string  `json:"currency"`
+	Date          string  `json:"date"`
+	Month         string  `json:"month"` // YYYY-MM format
+	Year          string  `json:"year"`  // YYYY, or e.g. FY2025 with a fiscal year start
 }
 
 // SpendingTrend represents aggregated spending trend data
 type SpendingTrend struct {
-	Period           string             `json:"period"` // "YYYY-MM" or "YYYY"
-	TotalSpending    float64            `json:"total_spending"`
+	Period           string             `json:"period"` // "YYYY-MM", "YYYY", or a fiscal year such as "FY2025"
+	TotalSpending    float64            `json:"total_spending" money:"amount"`
 	TransactionCount int                `json:"transaction_count"`
-	ByCategory       map[string]float64 `json:"by_category"` // Category name -> total
-	ByCurrency       map[string]float64 `json:"by_currency"`
+	ByCategory       map[string]float64 `json:"by_category" money:"amount"` // Category name -> total
+	ByCurrency       map[string]float64 `json:"by_currency" money:"by_currency"`
+	CategoryDeltas   []CategoryDelta    `json:"category_deltas,omitempty"` // Change per category vs the previous period (base)
 }
 
 // GetSpendingData retrieves spending transactions with category information
-// Returns expenses (negative amounts) grouped by category and date
+// Returns expenses (negative amounts) grouped by category and date; a split transaction returns
+// one row per category carrying that category's portion
 // months: number of months to look back (0 = all data)
-func (db *DB) GetSpendingData(months int) ([]SpendingData, error) {
-	// Calculate date range: months back from now
-	// Core Data timestamp: seconds since 2001-01-01
-	// Get the latest transaction date to calculate the cutoff
-
-	var query string
-	if months > 0 {
-		// Calculate cutoff timestamp: months * average seconds per month (30.44 days)
-		// We'll use a subquery to get the max date and calculate backwards
-		query = `
-			SELECT 
-				COALESCE(c.Z_PK, 0) as category_id,
-				c.ZNAME2 as category_name,
-				ABS(t.ZAMOUNT1) as amount,
-				t.ZDESC2 as description,
-				a.ZCURRENCYNAME as currency,
-				CASE WHEN t.ZDATE1 IS NOT NULL THEN datetime('2001-01-01', '+' || CAST(t.ZDATE1 AS INTEGER) || ' seconds') ELSE NULL END as transaction_date,
-				CASE WHEN t.ZDATE1 IS NOT NULL THEN strftime('%Y-%m', datetime('2001-01-01', '+' || CAST(t.ZDATE1 AS INTEGER) || ' seconds')) ELSE NULL END as month,
-				CASE WHEN t.ZDATE1 IS NOT NULL THEN strftime('%Y', datetime('2001-01-01', '+' || CAST(t.ZDATE1 AS INTEGER) || ' seconds')) ELSE NULL END as year
-			FROM ZSYNCOBJECT t
-			LEFT JOIN ZSYNCOBJECT a ON a.Z_PK = t.ZACCOUNT2 AND a.Z_ENT IN (10, 11, 12, 13, 15, 16)
-			LEFT JOIN ZCATEGORYASSIGMENT ca ON ca.ZTRANSACTION = t.Z_PK
-			LEFT JOIN ZSYNCOBJECT c ON c.Z_PK = ca.ZCATEGORY AND c.Z_ENT = 19
-			WHERE t.Z_ENT IN (37, 45, 46, 47, 43)
-			AND t.ZAMOUNT1 < 0
-			AND t.ZDATE1 IS NOT NULL
-			AND t.ZDATE1 >= (SELECT MAX(ZDATE1) FROM ZSYNCOBJECT WHERE Z_ENT IN (37, 45, 46, 47, 43) AND ZDATE1 IS NOT NULL) - (? * 2629746)
-			ORDER BY t.ZDATE1 DESC
-		`
-	} else {
-		query = `
-			SELECT 
-				COALESCE(c.Z_PK, 0) as category_id,
-				c.ZNAME2 as category_name,
-				ABS(t.ZAMOUNT1) as amount,
-				t.ZDESC2 as description,
-				a.ZCURRENCYNAME as currency,
-				CASE WHEN t.ZDATE1 IS NOT NULL THEN datetime('2001-01-01', '+' || CAST(t.ZDATE1 AS INTEGER) || ' seconds') ELSE NULL END as transaction_date,
-				CASE WHEN t.ZDATE1 IS NOT NULL THEN strftime('%Y-%m', datetime('2001-01-01', '+' || CAST(t.ZDATE1 AS INTEGER) || ' seconds')) ELSE NULL END as month,
-				CASE WHEN t.ZDATE1 IS NOT NULL THEN strftime('%Y', datetime('2001-01-01', '+' || CAST(t.ZDATE1 AS INTEGER) || ' seconds')) ELSE NULL END as year
-			FROM ZSYNCOBJECT t
-			LEFT JOIN ZSYNCOBJECT a ON a.Z_PK = t.ZACCOUNT2 AND a.Z_ENT IN (10, 11, 12, 13, 15, 16)
-			LEFT JOIN ZCATEGORYASSIGMENT ca ON ca.ZTRANSACTION = t.Z_PK
-			LEFT JOIN ZSYNCOBJECT c ON c.Z_PK = ca.ZCATEGORY AND c.Z_ENT = 19
-			WHERE t.Z_ENT IN (37, 45, 46, 47, 43)
-			AND t.ZAMOUNT1 < 0
-			AND t.ZDATE1 IS NOT NULL
-			ORDER BY t.ZDATE1 DESC
-		`
-	}
-
-	var rows *sql.Rows
-	var err error
-	if months > 0 {
-		rows, err = db.conn.Query(query, months)
-	} else {
-		rows, err = db.conn.Query(query)
+// includeTransfers: also return transfers between own accounts (excluded by default); cash
+// withdrawals are never returned
+// categoryIDs: only return expenses assigned to one of these categories (empty = all categories);
+// without stored portions a split transaction matches any of its categories with its whole amount
+// includeScheduled: also return future-dated (scheduled) transactions (excluded by default)
+func (db *DB) GetSpendingData(ctx context.Context, months int, includeTransfers bool, categoryIDs []int64, includeScheduled bool) ([]SpendingData, error) {
+	payeeExpr, payeeJoin, err := db.payeeSelect(ctx)
+	if err != nil {
+		return nil, err
 	}
+	amountExpr, categoryJoin, err := db.categoryAssignmentSelect(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to query spending data: %w", err)
+		return nil, err
+	}
+
+	// Core Data timestamps are seconds since 2001-01-01; the cutoff is months calendar
+	// months before the latest transaction
+	cutoff, err := db.monthsCutoff(ctx, months, includeScheduled)
+	if err != nil {
+		return nil, err
+	}
+	labels, err := db.categoryLabels(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	categoryFilter, categoryArgs, err := db.categoryAssignmentFilter(ctx, categoryIDs)
+	if err != nil {
+		return nil, err
 	}
-	defer rows.Close()
+	args := append([]interface{}{cutoff, scheduledCutoff(includeScheduled)}, categoryArgs...)
+
+	query := fmt.Sprintf(`
+		SELECT 
+			t.Z_PK as transaction_id,
+			t.ZACCOUNT2 as account_id,
+			COALESCE(c.Z_PK, 0) as category_id,
+			c.ZNAME2 as category_name,
+			ABS(%[5]s) as amount,
+			t.ZDESC2 as description,
+			%[1]s as payee,
+			a.ZCURRENCYNAME as currency,
+			t.ZDATE1 as transaction_date
+		FROM ZSYNCOBJECT t
+		LEFT JOIN ZSYNCOBJECT a ON a.Z_PK = t.ZACCOUNT2 AND a.Z_ENT IN (10, 11, 12, 13, 15, 16)
+		%[6]s
+		LEFT JOIN ZSYNCOBJECT c ON c.Z_PK = ca.ZCATEGORY AND c.Z_ENT = 19
+		%[2]s
+		WHERE t.Z_ENT IN (%[3]s)
+		AND t.ZAMOUNT1 < 0
+		AND t.ZDATE1 IS NOT NULL
+		AND t.ZDATE1 >= ?
+		AND t.ZDATE1 <= ?
+		%[4]s
+		ORDER BY t.ZDATE1 DESC
+	`, payeeExpr, payeeJoin, transactionEntities(includeTransfers), categoryFilter, amountExpr, categoryJoin)
 
 	var spending []SpendingData
-	for rows.Next() {
+	err = db.queryEach(ctx, "GetSpendingData", query, args, func(rows *sql.Rows) error {
 		var sd SpendingData
+		var accountID sql.NullInt64
 		var categoryID sql.NullInt64
 		var categoryName sql.NullString
 		var description sql.NullString
+		var payee sql.NullString
 		var currency sql.NullString
-		var date sql.NullString
-		var month sql.NullString
-		var year sql.NullString
+		var date sql.NullFloat64
 
-		err := rows.Scan(&categoryID, &categoryName, &sd.Amount, &description, &currency, &date, &month, &year)
+		err := rows.Scan(&sd.TransactionID, &accountID, &categoryID, &categoryName, &sd.Amount, &description, &payee, &currency, &date)
 		if err != nil {
-			return nil, fmt.Errorf("failed to scan spending data: %w", err)
+			return fmt.Errorf("failed to scan spending data: %w", err)
 		}
 
+		if accountID.Valid {
+			sd.AccountID = accountID.Int64
+		}
 		if categoryID.Valid {
 			sd.CategoryID = categoryID.Int64
 		}
 		if categoryName.Valid {
 			sd.CategoryName = categoryName.String
 		}
-		if currency.Valid {
-			sd.Currency = currency.String
+		if label := labels[sd.CategoryID]; label != "" {
+			sd.CategoryName = label
 		}
-		if date.Valid {
-			sd.Date = date.String
+		if payee.Valid {
+			sd.Payee = payee.String
 		}
-		if month.Valid {
-			sd.Month = month.String
-		}
-		if year.Valid {
-			sd.Year = year.String
+		sd.Currency = db.accountCurrency(currency)
+		if date.Valid {
+			ts := coreDataToTime(date.Float64)
+			sd.Date = ts.Format(dateTimeLayout)
+			sd.Month = ts.Format(monthLayout)
+			sd.Year = yearLabel(ts, db.fiscalYearStart)
 		}
 		desc := ""
 		if description.Valid {
 			desc = description.String
 		}
-		movementType := detectMovementType(desc)
-		if isInternalMovement(movementType) {
-			continue
+		if excludedMovement(detectMovementType(desc), includeTransfers) {
+			return nil
 		}
-		sd.CategoryName = fallbackCategoryName(sd.CategoryName, desc)
+		sd.Description = desc
+		sd.CategoryName = fallbackCategoryName(sd.CategoryName, desc, db.uncategorizedLabel)
 
 		spending = append(spending, sd)
-	}
-
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating spending data: %w", err)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query spending data: %w", err)
 	}
 
 	return spending, nil
 }
 
+// SpendingTrendOptions configures AnalyzeSpendingTrends; the zero value analyzes all data by month
+type SpendingTrendOptions struct {
+	GroupBy          string          // "month" or "year" (anything else = "month")
+	Months           int             // Number of months to analyze (0 = all historical data)
+	IncludeTransfers bool            // Count transfers between own accounts (excluded by default)
+	CategoryIDs      []int64         // Restrict the analysis to these categories (empty = all categories)
+	IncludeScheduled bool            // Count future-dated (scheduled) transactions (excluded by default)
+	Rollup           bool            // Report subcategory spending under its top-level category
+	Aliases          CategoryAliases // Report the categories they match under their display names, merging them (nil = none)
+}
+
 // AnalyzeSpendingTrends analyzes spending trends grouped by time period and category
-// groupBy: "month" or "year"
-// months: number of months to analyze (0 = all historical data)
-func (db *DB) AnalyzeSpendingTrends(groupBy string, months int) ([]SpendingTrend, error) {
+func (db *DB) AnalyzeSpendingTrends(ctx context.Context, opts SpendingTrendOptions) ([]SpendingTrend, error) {
+	return db.spendingTrends(ctx, opts, func(s SpendingData) float64 { return s.Amount })
+}
+
+// AnalyzeSpendingTrendsInCurrency analyzes spending trends like AnalyzeSpendingTrends, but
+// converts every expense from its account currency into the target currency before it is
+// aggregated; ByCurrency keeps the unconverted per-currency spending
+// rates: units of the target currency per unit of each account currency (e.g. {"EUR": 1.08} for USD)
+// Currencies without a rate are converted at 1.0 and reported in the conversion warnings
+func (db *DB) AnalyzeSpendingTrendsInCurrency(ctx context.Context, target string, rates map[string]float64, opts SpendingTrendOptions) ([]SpendingTrend, *CurrencyConversion, error) {
+	converter, err := newCurrencyConverter(target, rates)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	trends, err := db.spendingTrends(ctx, opts, func(s SpendingData) float64 {
+		return converter.convert(s.Amount, s.Currency)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	conversion := converter.conversion("accounts without a currency")
+	return trends, &conversion, nil
+}
+
+// spendingTrends groups spending by period, valuing each expense with value
+func (db *DB) spendingTrends(ctx context.Context, opts SpendingTrendOptions, value func(SpendingData) float64) ([]SpendingTrend, error) {
+	groupBy := opts.GroupBy
 	if groupBy != "month" && groupBy != "year" {
 		groupBy = "month"
 	}
 
-	spending, err := db.GetSpendingData(months)
+	spending, err := db.GetSpendingData(ctx, opts.Months, opts.IncludeTransfers, opts.CategoryIDs, opts.IncludeScheduled)
 	if err != nil {
 		return nil, err
 	}
+	if opts.Rollup {
+		if err := db.rollUpCategories(ctx, spending); err != nil {
+			return nil, err
+		}
+	}
+	if err := opts.Aliases.apply(spending); err != nil {
+		return nil, err
+	}
 
 	// Group by period
 	trendsMap := make(map[string]*SpendingTrend)
@@ -181,10 +233,11 @@ func (db *DB) AnalyzeSpendingTrends(groupBy string, months int) ([]SpendingTrend
 			}
 		}
 
+		amount := value(s)
 		trend := trendsMap[period]
-		trend.TotalSpending += s.Amount
+		trend.TotalSpending += amount
 		trend.TransactionCount++
-		trend.ByCategory[s.CategoryName] += s.Amount
+		trend.ByCategory[s.CategoryName] += amount
 		if s.Currency != "" {
 			trend.ByCurrency[s.Currency] += s.Amount
 		}
@@ -196,14 +249,57 @@ func (db *DB) AnalyzeSpendingTrends(groupBy string, months int) ([]SpendingTrend
 		trends = append(trends, *trend)
 	}
 
-	// Simple sort by period string (works for YYYY-MM and YYYY)
-	for i := 0; i < len(trends)-1; i++ {
-		for j := i + 1; j < len(trends); j++ {
-			if trends[i].Period > trends[j].Period {
-				trends[i], trends[j] = trends[j], trends[i]
-			}
+	// Period strings sort chronologically (works for YYYY-MM and YYYY)
+	sort.Slice(trends, func(i, j int) bool {
+		return trends[i].Period < trends[j].Period
+	})
+
+	// Compare each period's categories with the period just before it; a period missing from
+	// the data had no spending
+	for i := 1; i < len(trends); i++ {
+		base := map[string]float64{}
+		if trends[i-1].Period == previousPeriod(trends[i].Period, groupBy) {
+			base = trends[i-1].ByCategory
 		}
+		trends[i].CategoryDeltas = categoryDeltas("spending", base, trends[i].ByCategory)
 	}
 
 	return trends, nil
 }
+
+// previousPeriod returns the YYYY-MM month, or the year ("2024" or "FY2024"), before period
+func previousPeriod(period, groupBy string) string {
+	if groupBy == "year" {
+		year, fiscal, err := parseYearLabel(period)
+		if err != nil {
+			return ""
+		}
+		return formatYearLabel(year-1, fiscal)
+	}
+	t, err := time.Parse(monthLayout, period)
+	if err != nil {
+		return ""
+	}
+	return t.AddDate(0, -1, 0).Format(monthLayout)
+}
+
+// FastestGrowingCategories returns up to limit categories whose spending grew the most in the
+// latest period compared with the period before it, largest increase first
+func FastestGrowingCategories(trends []SpendingTrend, limit int) []CategoryDelta {
+	growing := []CategoryDelta{}
+	if len(trends) == 0 {
+		return growing
+	}
+	for _, delta := range trends[len(trends)-1].CategoryDeltas {
+		if delta.Delta > 0 {
+			growing = append(growing, delta)
+		}
+	}
+	sort.SliceStable(growing, func(i, j int) bool {
+		return growing[i].Delta > growing[j].Delta
+	})
+	if limit > 0 && len(growing) > limit {
+		growing = growing[:limit]
+	}
+	return growing
+}
@@ -0,0 +1,195 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+const (
+	defaultSpendingVelocityMonths = 6
+	// spendingVelocityTolerancePercent is how far month-to-date spending may be from the usual
+	// trajectory and still count as on track
+	spendingVelocityTolerancePercent = 10.0
+)
+
+// Spending velocity paces
+const (
+	SpendingPaceAhead   = "ahead"
+	SpendingPaceOnTrack = "on_track"
+	SpendingPaceBehind  = "behind"
+)
+
+// SpendingVelocityDay represents cumulative spending by one day of the month
+type SpendingVelocityDay struct {
+	Day               int      `json:"day"`
+	CurrentCumulative *float64 `json:"current_cumulative,omitempty" money:"amount"` // This month; omitted after as_of
+	AverageCumulative float64  `json:"average_cumulative" money:"amount"`           // Average of the compared months by the same day
+}
+
+// SpendingVelocity compares month-to-date spending with the usual trajectory by the same day
+type SpendingVelocity struct {
+	AsOf                    string                `json:"as_of"` // YYYY-MM-DD; the day counts as elapsed
+	Month                   string                `json:"month"` // YYYY-MM containing as_of
+	DaysElapsed             int                   `json:"days_elapsed"`
+	DaysInMonth             int                   `json:"days_in_month"`
+	ComparedMonths          []string              `json:"compared_months"` // YYYY-MM, oldest first
+	CurrentToDate           float64               `json:"current_to_date" money:"amount"`
+	AverageToDate           float64               `json:"average_to_date" money:"amount"` // Usual spending by the same day
+	Difference              float64               `json:"difference" money:"amount"`      // Current - average
+	DifferencePercent       float64               `json:"difference_percent"`
+	AverageMonthTotal       float64               `json:"average_month_total" money:"amount"`
+	Pace                    string                `json:"pace"` // "ahead", "on_track" or "behind"
+	Message                 string                `json:"message"`
+	Days                    []SpendingVelocityDay `json:"days"`
+	CurrentToDateByCurrency map[string]float64    `json:"current_to_date_by_currency" money:"by_currency"`
+	MixedCurrencies         bool                  `json:"mixed_currencies"`
+	Currencies              []string              `json:"currencies"`
+	CurrencyWarning         string                `json:"currency_warning,omitempty"`
+}
+
+// GetSpendingVelocity accumulates spending by day of month for the month containing asOfDate and
+// overlays the average trajectory of the months before it, so a month running hot shows up
+// before it ends
+// A compared month shorter than the current one keeps its total for the days it lacks
+// months: number of full months before the current one to average (0 = 6); months before the
+// first recorded expense are left out
+// asOfDate: the YYYY-MM-DD day to measure to ("" = today); later transactions are ignored
+// Internal transfers and future-dated scheduled transactions are left out, as in the analyses
+func (db *DB) GetSpendingVelocity(ctx context.Context, months int, asOfDate string) (*SpendingVelocity, error) {
+	if months < 0 {
+		return nil, invalidArgumentf("months must not be negative, got %d", months)
+	}
+	if months == 0 {
+		months = defaultSpendingVelocityMonths
+	}
+	asOf, err := parseAsOf("as_of date", asOfDate)
+	if err != nil {
+		return nil, err
+	}
+	monthStart := time.Date(asOf.Year(), asOf.Month(), 1, 0, 0, 0, 0, time.UTC)
+	daysInMonth := monthStart.AddDate(0, 1, -1).Day()
+
+	spending, err := db.GetSpendingData(ctx, 0, false, nil, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get spending data: %w", err)
+	}
+
+	velocity := &SpendingVelocity{
+		AsOf:                    asOf.Format(dayLayout),
+		Month:                   asOf.Format(monthLayout),
+		DaysElapsed:             asOf.Day(),
+		DaysInMonth:             daysInMonth,
+		ComparedMonths:          []string{},
+		Days:                    make([]SpendingVelocityDay, daysInMonth),
+		CurrentToDateByCurrency: make(map[string]float64),
+	}
+
+	// Spending per day of month, for the current month and each compared month
+	current := make([]float64, daysInMonth+1)
+	byMonth := make(map[string][]float64)
+	firstMonth := velocity.Month
+	for _, s := range spending {
+		if len(s.Date) < len(dayLayout) || s.Date[:len(dayLayout)] > velocity.AsOf {
+			continue
+		}
+		day, err := time.Parse(dayLayout, s.Date[:len(dayLayout)])
+		if err != nil {
+			continue
+		}
+		if s.Month < firstMonth {
+			firstMonth = s.Month
+		}
+		if s.Month == velocity.Month {
+			current[day.Day()] += s.Amount
+			velocity.CurrentToDate += s.Amount
+			if s.Currency != "" {
+				velocity.CurrentToDateByCurrency[s.Currency] += s.Amount
+			}
+			continue
+		}
+		if byMonth[s.Month] == nil {
+			byMonth[s.Month] = make([]float64, 32)
+		}
+		byMonth[s.Month][day.Day()] += s.Amount
+	}
+
+	averages := make([]float64, daysInMonth+1)
+	for i := months; i >= 1; i-- {
+		start := monthStart.AddDate(0, -i, 0)
+		month := start.Format(monthLayout)
+		if month < firstMonth {
+			continue
+		}
+		velocity.ComparedMonths = append(velocity.ComparedMonths, month)
+
+		daily := byMonth[month]
+		if daily == nil {
+			daily = make([]float64, 32)
+		}
+		length := start.AddDate(0, 1, -1).Day()
+		var cumulative float64
+		for day := 1; day <= daysInMonth; day++ {
+			if day <= length {
+				cumulative += daily[day]
+			}
+			averages[day] += cumulative
+		}
+		for day := daysInMonth + 1; day <= length; day++ {
+			cumulative += daily[day]
+		}
+		velocity.AverageMonthTotal += cumulative
+	}
+	if compared := float64(len(velocity.ComparedMonths)); compared > 0 {
+		for day := range averages {
+			averages[day] /= compared
+		}
+		velocity.AverageMonthTotal /= compared
+	}
+
+	var cumulative float64
+	for day := 1; day <= daysInMonth; day++ {
+		point := SpendingVelocityDay{Day: day, AverageCumulative: averages[day]}
+		if day <= velocity.DaysElapsed {
+			cumulative += current[day]
+			value := cumulative
+			point.CurrentCumulative = &value
+		}
+		velocity.Days[day-1] = point
+	}
+
+	velocity.AverageToDate = averages[velocity.DaysElapsed]
+	velocity.Difference = velocity.CurrentToDate - velocity.AverageToDate
+	if velocity.AverageToDate > 0 {
+		velocity.DifferencePercent = velocity.Difference / velocity.AverageToDate * 100
+	}
+	switch {
+	case len(velocity.ComparedMonths) == 0:
+		velocity.Pace = SpendingPaceOnTrack
+		velocity.Message = fmt.Sprintf("No earlier months to compare with; %.2f spent by day %d.", velocity.CurrentToDate, velocity.DaysElapsed)
+	case velocity.AverageToDate == 0:
+		velocity.Pace = SpendingPaceOnTrack
+		if velocity.CurrentToDate > 0 {
+			velocity.Pace = SpendingPaceAhead
+		}
+		velocity.Message = fmt.Sprintf("By day %d you usually have spent nothing; this month you're at %.2f.", velocity.DaysElapsed, velocity.CurrentToDate)
+	default:
+		switch {
+		case velocity.DifferencePercent > spendingVelocityTolerancePercent:
+			velocity.Pace = SpendingPaceAhead
+		case velocity.DifferencePercent < -spendingVelocityTolerancePercent:
+			velocity.Pace = SpendingPaceBehind
+		default:
+			velocity.Pace = SpendingPaceOnTrack
+		}
+		velocity.Message = fmt.Sprintf("By day %d you've usually spent %.2f; this month you're at %.2f (%+.1f%%).", velocity.DaysElapsed, velocity.AverageToDate, velocity.CurrentToDate, velocity.DifferencePercent)
+	}
+
+	velocity.Currencies = sortedCurrencyKeys(velocity.CurrentToDateByCurrency)
+	velocity.MixedCurrencies = len(velocity.Currencies) > 1
+	if velocity.MixedCurrencies {
+		velocity.CurrencyWarning = "Totals combine multiple currencies; current_to_date_by_currency splits the month so far by currency."
+	}
+
+	return velocity, nil
+}
@@ -0,0 +1,60 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// A split transaction has one ZCATEGORYASSIGMENT row per category, each holding the portion of
+// the amount booked to that category. The portion column is detected because schema versions differ
+var categoryAssignmentAmountColumnCandidates = []string{"ZAMOUNT", "ZAMOUNT1"}
+
+// categoryAssignmentSelect returns the amount expression and the JOIN clause that attribute
+// transaction alias t to its categories, as category assignment alias ca
+// With split amounts stored, each assignment row carries its own portion; an assignment without
+// one (a transaction with a single category) falls back to the transaction amount. Without the
+// column the portions cannot be told apart, so only the first category of a transaction is
+// joined and the whole amount is counted once instead of once per category
+func (db *DB) categoryAssignmentSelect(ctx context.Context) (string, string, error) {
+	column, err := db.firstExistingColumn(ctx, "ZCATEGORYASSIGMENT", categoryAssignmentAmountColumnCandidates...)
+	if err != nil {
+		return "", "", err
+	}
+	if column == "" {
+		return "t.ZAMOUNT1", `LEFT JOIN ZCATEGORYASSIGMENT ca ON ca.ZTRANSACTION = t.Z_PK
+			AND ca.ZCATEGORY = (SELECT MIN(fa.ZCATEGORY) FROM ZCATEGORYASSIGMENT fa WHERE fa.ZTRANSACTION = t.Z_PK)`, nil
+	}
+
+	amountExpr := fmt.Sprintf("CASE WHEN ca.%[1]s IS NOT NULL AND ca.%[1]s != 0 THEN ca.%[1]s ELSE t.ZAMOUNT1 END", column)
+	return amountExpr, "LEFT JOIN ZCATEGORYASSIGMENT ca ON ca.ZTRANSACTION = t.Z_PK", nil
+}
+
+// categoryAssignmentFilter returns the condition that keeps transaction alias t only when it is
+// assigned to one of categoryIDs, with its arguments, for use with categoryAssignmentSelect
+// The assignment is looked up on its own, so a split transaction matches through any of its
+// categories even when the join attributes it to another one. With split amounts stored, only
+// the rows of the requested categories are kept, so the other portions are not counted
+func (db *DB) categoryAssignmentFilter(ctx context.Context, categoryIDs []int64) (string, []interface{}, error) {
+	if len(categoryIDs) == 0 {
+		return "", nil, nil
+	}
+	column, err := db.firstExistingColumn(ctx, "ZCATEGORYASSIGMENT", categoryAssignmentAmountColumnCandidates...)
+	if err != nil {
+		return "", nil, err
+	}
+
+	placeholders := make([]string, len(categoryIDs))
+	args := make([]interface{}, 0, 2*len(categoryIDs))
+	for i, id := range categoryIDs {
+		placeholders[i] = "?"
+		args = append(args, id)
+	}
+	in := "(" + strings.Join(placeholders, ", ") + ")"
+	filter := "AND EXISTS (SELECT 1 FROM ZCATEGORYASSIGMENT f WHERE f.ZTRANSACTION = t.Z_PK AND f.ZCATEGORY IN " + in + ")"
+	if column != "" {
+		filter += " AND ca.ZCATEGORY IN " + in
+		args = append(args, args...)
+	}
+	return filter, args, nil
+}
@@ -1,42 +1,51 @@
 package database
 
 import (
+	"context"
 	"fmt"
+	"math"
+	"sort"
 )
 
 // FinancialStats represents comprehensive financial statistics
 type FinancialStats struct {
-	TotalTransactions    int                      `json:"total_transactions"`
-	TotalIncome          float64                  `json:"total_income"`
-	TotalSpending        float64                  `json:"total_spending"`
-	NetSavings           float64                  `json:"net_savings"`
-	AverageTransaction   float64                  `json:"average_transaction"`
-	LargestIncome        float64                  `json:"largest_income"`
-	LargestExpense       float64                  `json:"largest_expense"`
-	AccountCount         int                      `json:"account_count"`
-	CategoryCount        int                      `json:"category_count"`
-	FirstTransactionDate string                   `json:"first_transaction_date"`
-	LastTransactionDate  string                   `json:"last_transaction_date"`
-	DateRange            string                   `json:"date_range"`
-	IncomeTransactions   int                      `json:"income_transactions"`
-	ExpenseTransactions  int                      `json:"expense_transactions"`
-	MixedCurrencies      bool                     `json:"mixed_currencies"`
-	Currencies           []string                 `json:"currencies"`
-	PrimaryCurrency      string                   `json:"primary_currency,omitempty"`
-	CurrencyWarning      string                   `json:"currency_warning,omitempty"`
-	ByCurrency           map[string]CurrencyStats `json:"by_currency"`
-	ByYear               map[string]YearStats     `json:"by_year"`
+	TotalTransactions      int                      `json:"total_transactions"`
+	TotalIncome            float64                  `json:"total_income" money:"amount"`
+	TotalSpending          float64                  `json:"total_spending" money:"amount"`
+	NetSavings             float64                  `json:"net_savings" money:"amount"`
+	AverageTransaction     float64                  `json:"average_transaction" money:"amount"`
+	MedianTransaction      float64                  `json:"median_transaction" money:"amount"`       // Middle amount of income and expenses combined, unmoved by a few huge ones
+	OutlierThreshold       float64                  `json:"outlier_threshold" money:"amount"`        // Amounts above this are outliers: the 75th percentile + 3 x the interquartile range
+	OutlierCount           int                      `json:"outlier_count"`                           // Transactions above outlier_threshold, e.g. a house purchase
+	AverageWithoutOutliers float64                  `json:"average_without_outliers" money:"amount"` // average_transaction leaving the outliers out
+	LargestIncome          float64                  `json:"largest_income" money:"amount"`
+	LargestExpense         float64                  `json:"largest_expense" money:"amount"`
+	AccountCount           int                      `json:"account_count"`
+	CategoryCount          int                      `json:"category_count"`
+	FirstTransactionDate   string                   `json:"first_transaction_date"`
+	LastTransactionDate    string                   `json:"last_transaction_date"`
+	DateRange              string                   `json:"date_range"`
+	IncomeTransactions     int                      `json:"income_transactions"`
+	ExpenseTransactions    int                      `json:"expense_transactions"`
+	MixedCurrencies        bool                     `json:"mixed_currencies"`
+	Currencies             []string                 `json:"currencies"`
+	PrimaryCurrency        string                   `json:"primary_currency,omitempty"`
+	CurrencyWarning        string                   `json:"currency_warning,omitempty"`
+	ByCurrency             map[string]CurrencyStats `json:"by_currency" money:"by_currency"`
+	ByYear                 map[string]YearStats     `json:"by_year"`
+	FiscalYearStart        string                   `json:"fiscal_year_start,omitempty"` // Month by_year begins in when not January
+	Warnings               []string                 `json:"warnings,omitempty"`          // Sections that could not be read; the rest is still valid
 }
 
 type CurrencyStats struct {
 	Currency            string  `json:"currency"`
 	TotalTransactions   int     `json:"total_transactions"`
-	TotalIncome         float64 `json:"total_income"`
-	TotalSpending       float64 `json:"total_spending"`
-	NetSavings          float64 `json:"net_savings"`
-	AverageTransaction  float64 `json:"average_transaction"`
-	LargestIncome       float64 `json:"largest_income"`
-	LargestExpense      float64 `json:"largest_expense"`
+	TotalIncome         float64 `json:"total_income" money:"amount"`
+	TotalSpending       float64 `json:"total_spending" money:"amount"`
+	NetSavings          float64 `json:"net_savings" money:"amount"`
+	AverageTransaction  float64 `json:"average_transaction" money:"amount"`
+	LargestIncome       float64 `json:"largest_income" money:"amount"`
+	LargestExpense      float64 `json:"largest_expense" money:"amount"`
 	IncomeTransactions  int     `json:"income_transactions"`
 	ExpenseTransactions int     `json:"expense_transactions"`
 }
@@ -44,34 +53,70 @@ type CurrencyStats struct {
 // YearStats represents statistics for a specific year
 type YearStats struct {
 	Year             string  `json:"year"`
-	Income           float64 `json:"income"`
-	Spending         float64 `json:"spending"`
-	NetSavings       float64 `json:"net_savings"`
+	Income           float64 `json:"income" money:"amount"`
+	Spending         float64 `json:"spending" money:"amount"`
+	NetSavings       float64 `json:"net_savings" money:"amount"`
 	TransactionCount int     `json:"transaction_count"`
 }
 
 // GetFinancialStats calculates comprehensive financial statistics from all historical data
-func (db *DB) GetFinancialStats() (*FinancialStats, error) {
-	// Get all transactions (no date limit)
-	incomeData, err := db.GetIncomeData(0) // 0 = all data
-	if err != nil {
-		return nil, fmt.Errorf("failed to get income data: %w", err)
+// includeTransfers: count transfers between own accounts as income and spending (excluded by
+// default); cash withdrawals are never counted
+// The totals are summed by the database, by month, currency and income or expense, so years of
+// transactions are never loaded at once; the median and outliers are read from the sorted amounts
+// A section that cannot be read (transaction totals, accounts, or categories) is left empty and
+// named in Warnings, so one broken table does not hide the rest; only a canceled call or a
+// failure of every section returns an error
+// Every section is read from the same snapshot of the file, so the totals agree with each other
+func (db *DB) GetFinancialStats(ctx context.Context, includeTransfers bool) (*FinancialStats, error) {
+	return inReadSnapshot(ctx, db, func(snap *DB) (*FinancialStats, error) {
+		return snap.getFinancialStats(ctx, includeTransfers)
+	})
+}
+
+// getFinancialStats is GetFinancialStats without the read snapshot
+func (db *DB) getFinancialStats(ctx context.Context, includeTransfers bool) (*FinancialStats, error) {
+	var warnings []string
+	var firstErr error
+	failed := 0
+	section := func(name string, err error) error {
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return err
+		}
+		failed++
+		if firstErr == nil {
+			firstErr = fmt.Errorf("failed to get %s: %w", name, err)
+		}
+		warnings = append(warnings, fmt.Sprintf("Could not read %s, so the statistics built from it are missing: %v", name, err))
+		return nil
 	}
 
-	spendingData, err := db.GetSpendingData(0) // 0 = all data
-	if err != nil {
-		return nil, fmt.Errorf("failed to get spending data: %w", err)
+	// Aggregate all transactions (no date limit) in the database rather than loading them
+	cutoff := scheduledCutoff(false)
+	var groups []statsGroup
+	amounts, err := db.statsAmounts(ctx, includeTransfers)
+	if err == nil {
+		groups, err = db.aggregateStats(ctx, amounts, cutoff)
+	}
+	if err := section("transaction totals", err); err != nil {
+		return nil, err
 	}
 
 	// Get accounts and categories count
-	accounts, err := db.GetAccounts()
-	if err != nil {
-		return nil, fmt.Errorf("failed to get accounts: %w", err)
+	accounts, err := db.GetAccounts(ctx)
+	if err := section("accounts", err); err != nil {
+		return nil, err
 	}
 
-	categories, err := db.GetCategories()
-	if err != nil {
-		return nil, fmt.Errorf("failed to get categories: %w", err)
+	categories, err := db.GetCategories(ctx, "")
+	if err := section("categories", err); err != nil {
+		return nil, err
+	}
+	if failed == 3 {
+		return nil, firstErr
 	}
 
 	// Calculate totals
@@ -79,96 +124,86 @@ func (db *DB) GetFinancialStats() (*FinancialStats, error) {
 	var totalSpending float64
 	var largestIncome float64
 	var largestExpense float64
+	var incomeTransactions int
+	var expenseTransactions int
 	var firstDate string
 	var lastDate string
+	var excluded []string
 	byYear := make(map[string]*YearStats)
 	byCurrency := make(map[string]*CurrencyStats)
 
-	// Process income transactions
-	for _, i := range incomeData {
-		totalIncome += i.Amount
-		if i.Amount > largestIncome {
-			largestIncome = i.Amount
+	for _, g := range groups {
+		// Transfers and cash withdrawals are told apart by description, as in the data queries
+		if g.movement.Valid && excludedMovement(detectMovementType(g.movement.String), includeTransfers) {
+			excluded = append(excluded, g.movement.String)
+			continue
 		}
 
 		// Track dates
-		if i.Date != "" {
-			if firstDate == "" || i.Date < firstDate {
-				firstDate = i.Date
-			}
-			if lastDate == "" || i.Date > lastDate {
-				lastDate = i.Date
-			}
+		if first := statsDate(g.firstDate); firstDate == "" || first < firstDate {
+			firstDate = first
+		}
+		if last := statsDate(g.lastDate); lastDate == "" || last > lastDate {
+			lastDate = last
 		}
 
 		// Group by year
-		if i.Year != "" {
-			if byYear[i.Year] == nil {
-				byYear[i.Year] = &YearStats{Year: i.Year}
-			}
-			byYear[i.Year].Income += i.Amount
-			byYear[i.Year].TransactionCount++
+		year := db.statsYear(g.month)
+		if year != "" && byYear[year] == nil {
+			byYear[year] = &YearStats{Year: year}
+		}
+		currency := db.accountCurrency(g.currency)
+		if currency != "" && byCurrency[currency] == nil {
+			byCurrency[currency] = &CurrencyStats{Currency: currency}
 		}
 
-		if i.Currency != "" {
-			if byCurrency[i.Currency] == nil {
-				byCurrency[i.Currency] = &CurrencyStats{Currency: i.Currency}
+		if g.income {
+			totalIncome += g.total
+			incomeTransactions += g.count
+			largestIncome = math.Max(largestIncome, g.largest)
+			if year != "" {
+				byYear[year].Income += g.total
 			}
-			byCurrency[i.Currency].TotalIncome += i.Amount
-			byCurrency[i.Currency].IncomeTransactions++
-			byCurrency[i.Currency].TotalTransactions++
-			if i.Amount > byCurrency[i.Currency].LargestIncome {
-				byCurrency[i.Currency].LargestIncome = i.Amount
+			if currency != "" {
+				byCurrency[currency].TotalIncome += g.total
+				byCurrency[currency].IncomeTransactions += g.count
+				byCurrency[currency].LargestIncome = math.Max(byCurrency[currency].LargestIncome, g.largest)
 			}
-		}
-	}
-
-	// Process spending transactions
-	for _, s := range spendingData {
-		totalSpending += s.Amount
-		if s.Amount > largestExpense {
-			largestExpense = s.Amount
-		}
-
-		// Track dates
-		if s.Date != "" {
-			if firstDate == "" || s.Date < firstDate {
-				firstDate = s.Date
+		} else {
+			totalSpending += g.total
+			expenseTransactions += g.count
+			largestExpense = math.Max(largestExpense, g.largest)
+			if year != "" {
+				byYear[year].Spending += g.total
 			}
-			if lastDate == "" || s.Date > lastDate {
-				lastDate = s.Date
+			if currency != "" {
+				byCurrency[currency].TotalSpending += g.total
+				byCurrency[currency].ExpenseTransactions += g.count
+				byCurrency[currency].LargestExpense = math.Max(byCurrency[currency].LargestExpense, g.largest)
 			}
 		}
-
-		// Group by year
-		if s.Year != "" {
-			if byYear[s.Year] == nil {
-				byYear[s.Year] = &YearStats{Year: s.Year}
-			}
-			byYear[s.Year].Spending += s.Amount
-			byYear[s.Year].TransactionCount++
+		if year != "" {
+			byYear[year].TransactionCount += g.count
 		}
-
-		if s.Currency != "" {
-			if byCurrency[s.Currency] == nil {
-				byCurrency[s.Currency] = &CurrencyStats{Currency: s.Currency}
-			}
-			byCurrency[s.Currency].TotalSpending += s.Amount
-			byCurrency[s.Currency].ExpenseTransactions++
-			byCurrency[s.Currency].TotalTransactions++
-			if s.Amount > byCurrency[s.Currency].LargestExpense {
-				byCurrency[s.Currency].LargestExpense = s.Amount
-			}
+		if currency != "" {
+			byCurrency[currency].TotalTransactions += g.count
 		}
 	}
 
 	// Calculate net savings and finalize year stats
 	netSavings := totalIncome - totalSpending
-	totalTransactions := len(incomeData) + len(spendingData)
+	totalTransactions := incomeTransactions + expenseTransactions
 	averageTransaction := 0.0
 	if totalTransactions > 0 {
 		averageTransaction = (totalIncome + totalSpending) / float64(totalTransactions)
 	}
+	var outliers outlierSummary
+	if totalTransactions > 0 {
+		outliers, err = db.statsOutliers(ctx, amounts, cutoff, excluded, totalTransactions)
+		if err := section("transaction amounts", err); err != nil {
+			return nil, err
+		}
+	}
 
 	// Finalize year stats
 	yearStatsMap := make(map[string]YearStats)
@@ -200,31 +235,80 @@ func (db *DB) GetFinancialStats() (*FinancialStats, error) {
 	if len(currencies) > 1 {
 		currencyWarning = "Totals combine multiple currencies. Prefer by_currency values for accurate interpretation."
 	}
-	primaryCurrency := ""
-	if len(currencies) == 1 {
+	primaryCurrency, _, err := db.baseCurrency(ctx, accounts)
+	if err := section("base currency", err); err != nil {
+		return nil, err
+	}
+	if primaryCurrency == "" && len(currencies) == 1 {
 		primaryCurrency = currencies[0]
 	}
 
 	return &FinancialStats{
-		TotalTransactions:    totalTransactions,
-		TotalIncome:          totalIncome,
-		TotalSpending:        totalSpending,
-		NetSavings:           netSavings,
-		AverageTransaction:   averageTransaction,
-		LargestIncome:        largestIncome,
-		LargestExpense:       largestExpense,
-		AccountCount:         len(accounts),
-		CategoryCount:        len(categories),
-		FirstTransactionDate: firstDate,
-		LastTransactionDate:  lastDate,
-		DateRange:            dateRange,
-		IncomeTransactions:   len(incomeData),
-		ExpenseTransactions:  len(spendingData),
-		MixedCurrencies:      len(currencies) > 1,
-		Currencies:           currencies,
-		PrimaryCurrency:      primaryCurrency,
-		CurrencyWarning:      currencyWarning,
-		ByCurrency:           byCurrencyStats,
-		ByYear:               yearStatsMap,
+		TotalTransactions:      totalTransactions,
+		TotalIncome:            totalIncome,
+		TotalSpending:          totalSpending,
+		NetSavings:             netSavings,
+		AverageTransaction:     averageTransaction,
+		MedianTransaction:      outliers.median,
+		OutlierThreshold:       outliers.threshold,
+		OutlierCount:           outliers.count,
+		AverageWithoutOutliers: outliers.averageWithout,
+		LargestIncome:          largestIncome,
+		LargestExpense:         largestExpense,
+		AccountCount:           len(accounts),
+		CategoryCount:          len(categories),
+		FirstTransactionDate:   firstDate,
+		LastTransactionDate:    lastDate,
+		DateRange:              dateRange,
+		IncomeTransactions:     incomeTransactions,
+		ExpenseTransactions:    expenseTransactions,
+		MixedCurrencies:        len(currencies) > 1,
+		Currencies:             currencies,
+		PrimaryCurrency:        primaryCurrency,
+		CurrencyWarning:        currencyWarning,
+		ByCurrency:             byCurrencyStats,
+		ByYear:                 yearStatsMap,
+		FiscalYearStart:        db.fiscalYearStartName(),
+		Warnings:               warnings,
 	}, nil
 }
+
+// outlierIQRMultiplier sets how far above the 75th percentile an amount must be, in interquartile
+// ranges, to count as an outlier; 3 keeps only extreme amounts rather than every big purchase
+const outlierIQRMultiplier = 3.0
+
+// outlierSummary describes the spread of a set of transaction amounts
+type outlierSummary struct {
+	median         float64
+	threshold      float64
+	count          int
+	averageWithout float64
+}
+
+// findOutliers sorts the amounts to find their median and the amounts beyond the upper
+// interquartile fence, which skew an average
+func findOutliers(amounts []float64) outlierSummary {
+	if len(amounts) == 0 {
+		return outlierSummary{}
+	}
+	sorted := append([]float64(nil), amounts...)
+	sort.Float64s(sorted)
+
+	q1, q3 := percentile(sorted, 25), percentile(sorted, 75)
+	summary := outlierSummary{
+		median:    percentile(sorted, 50),
+		threshold: q3 + outlierIQRMultiplier*(q3-q1),
+	}
+	total := 0.0
+	for _, amount := range sorted {
+		if amount > summary.threshold {
+			summary.count++
+			continue
+		}
+		total += amount
+	}
+	if kept := len(sorted) - summary.count; kept > 0 {
+		summary.averageWithout = total / float64(kept)
+	}
+	return summary
+}
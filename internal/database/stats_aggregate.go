@@ -0,0 +1,184 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+)
+
+// statsMovementCandidate matches, in SQL, every description detectMovementType could take for a
+// transfer or cash withdrawal: any containing "transfer" or "withdrawal" (LIKE ignores ASCII
+// case), and any with a character outside printable ASCII, which covers the Cyrillic withdrawal
+// label and whitespace SQLite would not trim. Only those rows keep their description when
+// aggregating, so the exact check can still run in Go
+const statsMovementCandidate = `(t.ZDESC2 LIKE '%transfer%' OR t.ZDESC2 LIKE '%withdrawal%' OR t.ZDESC2 GLOB '*[^ -~]*')`
+
+// statsGroup is one row of the aggregated transactions: the income or the expenses of one
+// month in one account currency, and, for transfer and withdrawal candidates, one description
+type statsGroup struct {
+	income    bool
+	month     string // YYYY-MM
+	currency  sql.NullString
+	movement  sql.NullString // The description of a transfer or withdrawal candidate
+	count     int
+	total     float64
+	largest   float64
+	firstDate int64 // Core Data seconds
+	lastDate  int64
+}
+
+// statsAmounts builds the amounts CTE the statistics are aggregated from: one row per income or
+// expense (per category for split transactions), as GetIncomeData and GetSpendingData return
+// them over all data, with the positive amount, the date truncated to the second as
+// coreDataToTime does, the account currency, and the movement candidate description
+// Its single argument is the scheduled cutoff
+func (db *DB) statsAmounts(ctx context.Context, includeTransfers bool) (string, error) {
+	amountExpr, categoryJoin, err := db.categoryAssignmentSelect(ctx)
+	if err != nil {
+		return "", err
+	}
+	// Cash withdrawals are left out even when transfers are included, so candidates are always kept
+	movement := fmt.Sprintf("CASE WHEN %s THEN t.ZDESC2 END", statsMovementCandidate)
+
+	return fmt.Sprintf(`
+		WITH amounts AS (
+			SELECT
+				t.ZAMOUNT1 > 0 AS income,
+				ABS(%[2]s) AS amount,
+				CAST(t.ZDATE1 AS INTEGER) AS date,
+				a.ZCURRENCYNAME AS currency,
+				%[4]s AS movement
+			FROM ZSYNCOBJECT t
+			LEFT JOIN ZSYNCOBJECT a ON a.Z_PK = t.ZACCOUNT2 AND a.Z_ENT IN (10, 11, 12, 13, 15, 16)
+			%[3]s
+			WHERE t.Z_ENT IN (%[1]s)
+			AND t.ZAMOUNT1 != 0
+			AND t.ZDATE1 IS NOT NULL
+			AND t.ZDATE1 <= ?
+		)`, transactionEntities(includeTransfers), amountExpr, categoryJoin, movement), nil
+}
+
+// aggregateStats sums the amounts CTE by income or expense, month, currency and movement
+// candidate, so only one row per group reaches Go
+func (db *DB) aggregateStats(ctx context.Context, amounts string, cutoff float64) ([]statsGroup, error) {
+	query := fmt.Sprintf(`%s
+		SELECT income, strftime('%%Y-%%m', date + %d, 'unixepoch'), currency, movement,
+			COUNT(*), SUM(amount), MAX(amount), MIN(date), MAX(date)
+		FROM amounts
+		GROUP BY 1, 2, 3, 4
+	`, amounts, coreDataEpoch.Unix())
+
+	var groups []statsGroup
+	err := db.queryEach(ctx, "aggregateStats", query, []any{cutoff}, func(rows *sql.Rows) error {
+		var g statsGroup
+		if err := rows.Scan(&g.income, &g.month, &g.currency, &g.movement, &g.count, &g.total, &g.largest, &g.firstDate, &g.lastDate); err != nil {
+			return fmt.Errorf("failed to scan transaction totals: %w", err)
+		}
+		groups = append(groups, g)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate transactions: %w", err)
+	}
+
+	return groups, nil
+}
+
+// statsOutliers finds the median and outliers of the count amounts left in the amounts CTE once
+// the excluded movement descriptions are dropped, as findOutliers would: the database sorts
+// them, and only the ranks the percentiles need, then the total above the threshold, are read
+func (db *DB) statsOutliers(ctx context.Context, amounts string, cutoff float64, excluded []string, count int) (outlierSummary, error) {
+	if count == 0 {
+		return outlierSummary{}, nil
+	}
+	if excluded == nil {
+		excluded = []string{}
+	}
+	excludedJSON, err := json.Marshal(excluded)
+	if err != nil {
+		return outlierSummary{}, fmt.Errorf("failed to encode excluded descriptions: %w", err)
+	}
+	kept := fmt.Sprintf(`%s,
+		kept AS (
+			SELECT amount FROM amounts
+			WHERE movement IS NULL OR movement NOT IN (SELECT value FROM json_each(?))
+		)`, amounts)
+
+	// The ranks either side of each percentile, as percentile interpolates between them
+	ranks := make(map[int]float64)
+	for _, p := range []float64{25, 50, 75} {
+		rank := p / 100 * float64(count-1)
+		ranks[int(math.Floor(rank))] = 0
+		ranks[int(math.Ceil(rank))] = 0
+	}
+	args := []any{cutoff, string(excludedJSON)}
+	placeholders := ""
+	for rank := range ranks {
+		if placeholders != "" {
+			placeholders += ", "
+		}
+		placeholders += "?"
+		args = append(args, rank)
+	}
+
+	query := fmt.Sprintf(`%s
+		SELECT position, amount FROM (
+			SELECT amount, ROW_NUMBER() OVER (ORDER BY amount) - 1 AS position FROM kept
+		)
+		WHERE position IN (%s)
+	`, kept, placeholders)
+	err = db.queryEach(ctx, "statsOutliers percentiles", query, args, func(rows *sql.Rows) error {
+		var rank int
+		var amount float64
+		if err := rows.Scan(&rank, &amount); err != nil {
+			return fmt.Errorf("failed to scan amount percentile: %w", err)
+		}
+		ranks[rank] = amount
+		return nil
+	})
+	if err != nil {
+		return outlierSummary{}, fmt.Errorf("failed to query amount percentiles: %w", err)
+	}
+
+	at := func(p float64) float64 {
+		rank := p / 100 * float64(count-1)
+		lower, upper := ranks[int(math.Floor(rank))], ranks[int(math.Ceil(rank))]
+		return lower + (upper-lower)*(rank-math.Floor(rank))
+	}
+	q1, q3 := at(25), at(75)
+	summary := outlierSummary{
+		median:    at(50),
+		threshold: q3 + outlierIQRMultiplier*(q3-q1),
+	}
+
+	var keptTotal sql.NullFloat64
+	err = db.runQuery(ctx, "statsOutliers totals", func() error {
+		return db.reader().QueryRowContext(ctx, kept+`
+			SELECT COUNT(*) FILTER (WHERE amount > ?), SUM(amount) FILTER (WHERE amount <= ?) FROM kept
+		`, cutoff, string(excludedJSON), summary.threshold, summary.threshold).Scan(&summary.count, &keptTotal)
+	})
+	if err != nil {
+		return outlierSummary{}, fmt.Errorf("failed to count outliers: %w", err)
+	}
+	if kept := count - summary.count; kept > 0 {
+		summary.averageWithout = keptTotal.Float64 / float64(kept)
+	}
+	return summary, nil
+}
+
+// statsDate formats a truncated Core Data date like the Date of income and spending data
+func statsDate(seconds int64) string {
+	return coreDataToTime(float64(seconds)).Format(dateTimeLayout)
+}
+
+// statsYear returns the year label of a YYYY-MM month, as yearLabel gives for any day in it
+func (db *DB) statsYear(month string) string {
+	t, err := time.Parse(monthLayout, month)
+	if err != nil {
+		return ""
+	}
+	return yearLabel(t, db.fiscalYearStart)
+}
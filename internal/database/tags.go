@@ -0,0 +1,259 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// MoneyWiz links transactions to tags through a Core Data many-to-many join table named after
+// the entity numbers, e.g. Z_36TAGS with Z_36TRANSACTIONS and Z_35TAGS columns. The table, its
+// columns, and the tag name column are detected because schema versions differ
+var tagNameColumnCandidates = []string{"ZNAME", "ZNAME2"}
+
+// TagSpending represents spending aggregated for one tag
+type TagSpending struct {
+	Tag              string             `json:"tag"`
+	TotalSpending    float64            `json:"total_spending" money:"amount"`
+	TransactionCount int                `json:"transaction_count"`
+	AverageAmount    float64            `json:"average_amount" money:"amount"`
+	ByCurrency       map[string]float64 `json:"by_currency" money:"by_currency"`
+	ByCategory       map[string]float64 `json:"by_category" money:"amount"`
+	FirstDate        string             `json:"first_date"`
+	LastDate         string             `json:"last_date"`
+}
+
+// TagSpendingAnalysis represents spending grouped by tag
+type TagSpendingAnalysis struct {
+	Months           int           `json:"months"`
+	TagTable         string        `json:"tag_table,omitempty"` // Join table the tags were read from
+	Note             string        `json:"note,omitempty"`
+	TagCount         int           `json:"tag_count"`
+	TaggedSpending   float64       `json:"tagged_spending" money:"amount"` // Each transaction counted once, however many tags it has
+	UntaggedSpending float64       `json:"untagged_spending" money:"amount"`
+	MixedCurrencies  bool          `json:"mixed_currencies"`
+	Currencies       []string      `json:"currencies"`
+	CurrencyWarning  string        `json:"currency_warning,omitempty"`
+	Tags             []TagSpending `json:"tags"`
+}
+
+// tagJoinTable finds the join table between transactions and tags, returning the table with
+// its transaction and tag columns; all are empty when the database does not store tags
+func (db *DB) tagJoinTable(ctx context.Context) (string, string, string, error) {
+	var tables []string
+	err := db.queryEach(ctx, "tagJoinTable", `SELECT name FROM sqlite_master WHERE type = 'table' AND name LIKE 'Z\_%TAGS' ESCAPE '\' ORDER BY name`, nil, func(rows *sql.Rows) error {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return fmt.Errorf("failed to scan tag table: %w", err)
+		}
+		tables = append(tables, name)
+		return nil
+	})
+	if err != nil {
+		return "", "", "", fmt.Errorf("failed to look up tag table: %w", err)
+	}
+
+	for _, table := range tables {
+		columns, err := db.tableColumns(ctx, table)
+		if err != nil {
+			return "", "", "", err
+		}
+		transactionColumn, tagColumn := "", ""
+		for column := range columns {
+			upper := strings.ToUpper(column)
+			switch {
+			case strings.HasSuffix(upper, "TRANSACTIONS"):
+				transactionColumn = column
+			case strings.HasSuffix(upper, "TAGS"):
+				tagColumn = column
+			}
+		}
+		if transactionColumn != "" && tagColumn != "" {
+			return table, transactionColumn, tagColumn, nil
+		}
+	}
+	return "", "", "", nil
+}
+
+// tagSource returns the tag join table with its transaction and tag columns, and the name
+// expression of tag alias g; the table is empty when the database does not store tags
+func (db *DB) tagSource(ctx context.Context) (string, string, string, string, error) {
+	table, transactionColumn, tagColumn, err := db.tagJoinTable(ctx)
+	if err != nil || table == "" {
+		return "", "", "", "", err
+	}
+
+	columns, err := db.tableColumns(ctx, "ZSYNCOBJECT")
+	if err != nil {
+		return "", "", "", "", err
+	}
+	var names []string
+	for _, candidate := range tagNameColumnCandidates {
+		if columns[candidate] {
+			names = append(names, "g."+candidate)
+		}
+	}
+	if len(names) == 0 {
+		return "", "", "", "", nil
+	}
+	nameExpr := names[0]
+	if len(names) > 1 {
+		nameExpr = "COALESCE(" + strings.Join(names, ", ") + ")"
+	}
+	return table, transactionColumn, tagColumn, nameExpr, nil
+}
+
+// transactionTags maps transaction IDs to their tag names, sorted; the table name is empty
+// when the database does not store tags
+func (db *DB) transactionTags(ctx context.Context) (map[int64][]string, string, error) {
+	table, transactionColumn, tagColumn, nameExpr, err := db.tagSource(ctx)
+	if err != nil || table == "" {
+		return nil, "", err
+	}
+
+	query := fmt.Sprintf(`
+		SELECT j.%s, %s
+		FROM %q j
+		JOIN ZSYNCOBJECT g ON g.Z_PK = j.%s
+	`, transactionColumn, nameExpr, table, tagColumn)
+
+	tags := make(map[int64][]string)
+	err = db.queryEach(ctx, "transactionTags", query, nil, func(rows *sql.Rows) error {
+		var transactionID int64
+		var name sql.NullString
+		if err := rows.Scan(&transactionID, &name); err != nil {
+			return fmt.Errorf("failed to scan transaction tag: %w", err)
+		}
+		if tag := strings.TrimSpace(name.String); tag != "" {
+			tags[transactionID] = append(tags[transactionID], tag)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to query transaction tags: %w", err)
+	}
+
+	for _, names := range tags {
+		sort.Strings(names)
+	}
+	return tags, table, nil
+}
+
+// tagListSeparator joins the tag names of one transaction in transactionTagsSelect; the ASCII
+// unit separator never occurs in a tag name
+const tagListSeparator = "\x1f"
+
+// transactionTagsSelect returns a SELECT expression listing the tags of transaction alias t,
+// joined by tagListSeparator, so a page of transactions reads only its own tags; it is NULL when
+// the database does not store tags. parseTagList turns the result into tag names
+func (db *DB) transactionTagsSelect(ctx context.Context) (string, error) {
+	table, transactionColumn, tagColumn, nameExpr, err := db.tagSource(ctx)
+	if err != nil || table == "" {
+		return "NULL", err
+	}
+	return fmt.Sprintf(`(SELECT GROUP_CONCAT(%s, char(31)) FROM %q j JOIN ZSYNCOBJECT g ON g.Z_PK = j.%s WHERE j.%s = t.Z_PK)`,
+		nameExpr, table, tagColumn, transactionColumn), nil
+}
+
+// parseTagList splits a tag list read through transactionTagsSelect into its names, sorted
+func parseTagList(list sql.NullString) []string {
+	var tags []string
+	for _, name := range strings.Split(list.String, tagListSeparator) {
+		if tag := strings.TrimSpace(name); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+// AnalyzeSpendingByTag totals spending per tag, largest first, to follow trips or projects whose
+// spending cuts across categories
+// A transaction with several tags counts towards each of them, so tag totals can add up to more
+// than tagged_spending
+// months: number of months to analyze (0 = all historical data)
+// Databases without tags return an empty list with a note rather than an error
+func (db *DB) AnalyzeSpendingByTag(ctx context.Context, months int) (*TagSpendingAnalysis, error) {
+	tags, table, err := db.transactionTags(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	analysis := &TagSpendingAnalysis{
+		Months:   months,
+		TagTable: table,
+		Tags:     []TagSpending{},
+	}
+	if table == "" {
+		analysis.Note = "This database does not store transaction tags."
+		analysis.Currencies = []string{}
+		return analysis, nil
+	}
+
+	spendingData, err := db.GetSpendingData(ctx, months, false, nil, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get spending data: %w", err)
+	}
+
+	byTag := make(map[string]*TagSpending)
+	currencySet := make(map[string]struct{})
+	for _, s := range spendingData {
+		transactionTags := tags[s.TransactionID]
+		if len(transactionTags) == 0 {
+			analysis.UntaggedSpending += s.Amount
+			continue
+		}
+		analysis.TaggedSpending += s.Amount
+		if s.Currency != "" {
+			currencySet[s.Currency] = struct{}{}
+		}
+
+		for _, name := range transactionTags {
+			// Tags are matched without regard to case, keeping the first spelling seen
+			key := strings.ToLower(name)
+			if byTag[key] == nil {
+				byTag[key] = &TagSpending{
+					Tag:        name,
+					ByCurrency: make(map[string]float64),
+					ByCategory: make(map[string]float64),
+				}
+			}
+
+			tag := byTag[key]
+			tag.TotalSpending += s.Amount
+			tag.TransactionCount++
+			tag.ByCategory[s.CategoryName] += s.Amount
+			if s.Currency != "" {
+				tag.ByCurrency[s.Currency] += s.Amount
+			}
+			if s.Date != "" && (tag.FirstDate == "" || s.Date < tag.FirstDate) {
+				tag.FirstDate = s.Date
+			}
+			if s.Date > tag.LastDate {
+				tag.LastDate = s.Date
+			}
+		}
+	}
+
+	for _, tag := range byTag {
+		tag.AverageAmount = tag.TotalSpending / float64(tag.TransactionCount)
+		analysis.Tags = append(analysis.Tags, *tag)
+	}
+	sort.Slice(analysis.Tags, func(i, j int) bool {
+		if analysis.Tags[i].TotalSpending != analysis.Tags[j].TotalSpending {
+			return analysis.Tags[i].TotalSpending > analysis.Tags[j].TotalSpending
+		}
+		return analysis.Tags[i].Tag < analysis.Tags[j].Tag
+	})
+	analysis.TagCount = len(analysis.Tags)
+
+	analysis.Currencies = sortedCurrencyKeys(currencySet)
+	analysis.MixedCurrencies = len(analysis.Currencies) > 1
+	if analysis.MixedCurrencies {
+		analysis.CurrencyWarning = "Totals combine multiple currencies. Prefer by_currency values for accurate interpretation."
+	}
+
+	return analysis, nil
+}
@@ -1,106 +1,431 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"strings"
+	"time"
 )
 
 // Transaction represents a MoneyWiz transaction
 type Transaction struct {
-	ID           int64   `json:"id"`
-	Amount       float64 `json:"amount"`
-	Date         string  `json:"date"`
-	Description  string  `json:"description"`
-	AccountID    int64   `json:"account_id"`
-	AccountName  string  `json:"account_name"`
-	Currency     string  `json:"currency"`
-	CategoryID   int64   `json:"category_id"`
-	CategoryName string  `json:"category_name"`
-	MovementType string  `json:"movement_type"`
+	ID           int64    `json:"id"`
+	Amount       float64  `json:"amount" money:"amount"`
+	Date         string   `json:"date"`
+	Description  string   `json:"description"`
+	Notes        string   `json:"notes,omitempty"` // Longer free-text note, when the transaction has one
+	Payee        string   `json:"payee,omitempty"`
+	AccountID    int64    `json:"account_id"`
+	AccountName  string   `json:"account_name"`
+	Currency     string   `json:"currency"` // Currency of Amount (the account's currency)
+	CategoryID   int64    `json:"category_id"`
+	CategoryName string   `json:"category_name"`
+	MovementType string   `json:"movement_type"`
+	Status       string   `json:"status,omitempty"` // "cleared" (cleared or reconciled) or "pending"; omitted when the database does not store it
+	Tags         []string `json:"tags,omitempty"`
+	// Amount and currency as entered, when MoneyWiz stores them (e.g. a foreign-currency purchase)
+	OriginalAmount   *float64 `json:"original_amount,omitempty" money:"amount" currency:"original_currency"`
+	OriginalCurrency string   `json:"original_currency,omitempty"`
 }
 
-// GetTransactions retrieves transactions for an account (or all transactions if accountID is 0)
-// Transactions are entity types 37, 45, 46, 47, 43 (transfers), linked via ZACCOUNT2, using ZAMOUNT1
-// Dates are Core Data timestamps (seconds since 2001-01-01), converted to ISO format
-func (db *DB) GetTransactions(accountID int64, limit int) ([]Transaction, error) {
-	var query string
+// Columns holding a transaction's amount and currency as entered, before conversion into the
+// account's currency; both are detected because schema versions differ
+var (
+	originalAmountColumnCandidates   = []string{"ZORIGINALAMOUNT", "ZORIGINALAMOUNT1"}
+	originalCurrencyColumnCandidates = []string{"ZORIGINALCURRENCY", "ZORIGINALCURRENCYNAME"}
+)
+
+// transactionStatusColumnCandidates hold whether a transaction is cleared or reconciled; any
+// non-zero value counts as cleared, zero or NULL as pending
+var transactionStatusColumnCandidates = []string{"ZRECONCILED", "ZSTATUS1", "ZCLEARED"}
+
+// transactionNotesColumnCandidates hold the free-text note MoneyWiz keeps apart from the
+// description
+var transactionNotesColumnCandidates = []string{"ZNOTES1", "ZNOTES", "ZNOTE"}
+
+// notesColumn returns the notes column of transaction alias t, or "" when the database does not
+// store notes
+func (db *DB) notesColumn(ctx context.Context) (string, error) {
+	column, err := db.firstExistingColumn(ctx, "ZSYNCOBJECT", transactionNotesColumnCandidates...)
+	if err != nil || column == "" {
+		return "", err
+	}
+	return "t." + column, nil
+}
+
+// statusColumn returns the status column of transaction alias t, or "" when the database does
+// not store transaction status
+func (db *DB) statusColumn(ctx context.Context) (string, error) {
+	column, err := db.firstExistingColumn(ctx, "ZSYNCOBJECT", transactionStatusColumnCandidates...)
+	if err != nil || column == "" {
+		return "", err
+	}
+	return "t." + column, nil
+}
+
+// originalAmountSelect returns the SELECT expressions for the original amount and currency of
+// transaction alias t, using NULL for columns the database does not have
+func (db *DB) originalAmountSelect(ctx context.Context) (string, error) {
+	amountColumn, err := db.firstExistingColumn(ctx, "ZSYNCOBJECT", originalAmountColumnCandidates...)
+	if err != nil {
+		return "", err
+	}
+	currencyColumn, err := db.firstExistingColumn(ctx, "ZSYNCOBJECT", originalCurrencyColumnCandidates...)
+	if err != nil {
+		return "", err
+	}
+
+	amountExpr, currencyExpr := "NULL", "NULL"
+	if amountColumn != "" {
+		amountExpr = "t." + amountColumn
+	}
+	if currencyColumn != "" {
+		currencyExpr = "t." + currencyColumn
+	}
+	return amountExpr + ", " + currencyExpr, nil
+}
+
+// MaxTransactionLimit is the largest page of transactions GetTransactions returns; a larger
+// Limit is clamped to it. Exports that need every transaction pass Limit 0 instead
+const MaxTransactionLimit = 1000
+
+// TransactionFilter narrows the transactions returned by GetTransactions
+type TransactionFilter struct {
+	AccountID int64   // 0 = all accounts
+	Limit     int     // Maximum number of transactions to return, at most MaxTransactionLimit (0 = no limit)
+	StartDate string  // Optional YYYY-MM-DD, YYYY-MM or YYYY; transactions on or after its start
+	EndDate   string  // Optional YYYY-MM-DD, YYYY-MM or YYYY; transactions up to the end of that period
+	Offset    int     // Number of matching transactions to skip (default 0)
+	Search    string  // Optional case-insensitive substring of the description or notes; NULL text never matches
+	MinAmount float64 // Optional minimum of ABS(amount), so income and expenses filter alike (0 = no minimum)
+	MaxAmount float64 // Optional maximum of ABS(amount) (0 = no maximum)
+	Status    string  // Optional "pending" or "cleared" (cleared or reconciled); "" = any status
+}
+
+// whereClause builds the SQL conditions shared by GetTransactions and CountTransactions
+// The lowercased search text is returned separately because it is matched in Go
+// statusColumn and notesColumn come from statusColumn and notesColumn ("" when the database has none)
+func (filter TransactionFilter) whereClause(statusColumn, notesColumn string) (string, []interface{}, string, error) {
+	conditions := []string{"t.Z_ENT IN (37, 45, 46, 47, 43)", "t.ZAMOUNT1 IS NOT NULL"}
 	var args []interface{}
 
-	if accountID > 0 {
-		query = `
-			SELECT t.Z_PK, t.ZAMOUNT1, 
-				CASE WHEN t.ZDATE1 IS NOT NULL THEN datetime('2001-01-01', '+' || CAST(t.ZDATE1 AS INTEGER) || ' seconds') ELSE NULL END as transaction_date, 
-				t.ZDESC2, t.ZACCOUNT2, a.ZNAME, a.ZCURRENCYNAME, c.Z_PK, c.ZNAME2
-			FROM ZSYNCOBJECT t
-			LEFT JOIN ZSYNCOBJECT a ON a.Z_PK = t.ZACCOUNT2 AND a.Z_ENT IN (10, 11, 12, 13, 15, 16)
-			LEFT JOIN ZCATEGORYASSIGMENT ca ON ca.ZTRANSACTION = t.Z_PK
-			LEFT JOIN ZSYNCOBJECT c ON c.Z_PK = ca.ZCATEGORY AND c.Z_ENT = 19
-			WHERE t.Z_ENT IN (37, 45, 46, 47, 43) AND t.ZAMOUNT1 IS NOT NULL AND (t.ZACCOUNT2 = ? OR t.ZACCOUNT = ?)
-			ORDER BY t.ZDATE1 DESC
-			LIMIT ?
-		`
-		args = []interface{}{accountID, accountID, limit}
-	} else {
-		query = `
-			SELECT t.Z_PK, t.ZAMOUNT1, 
-				CASE WHEN t.ZDATE1 IS NOT NULL THEN datetime('2001-01-01', '+' || CAST(t.ZDATE1 AS INTEGER) || ' seconds') ELSE NULL END as transaction_date, 
-				t.ZDESC2, t.ZACCOUNT2, a.ZNAME, a.ZCURRENCYNAME, c.Z_PK, c.ZNAME2
-			FROM ZSYNCOBJECT t
-			LEFT JOIN ZSYNCOBJECT a ON a.Z_PK = t.ZACCOUNT2 AND a.Z_ENT IN (10, 11, 12, 13, 15, 16)
-			LEFT JOIN ZCATEGORYASSIGMENT ca ON ca.ZTRANSACTION = t.Z_PK
-			LEFT JOIN ZSYNCOBJECT c ON c.Z_PK = ca.ZCATEGORY AND c.Z_ENT = 19
-			WHERE t.Z_ENT IN (37, 45, 46, 47, 43) AND t.ZAMOUNT1 IS NOT NULL
-			ORDER BY t.ZDATE1 DESC
-			LIMIT ?
-		`
-		args = []interface{}{limit}
-	}
-
-	rows, err := db.conn.Query(query, args...)
-	if err != nil {
-		return nil, fmt.Errorf("failed to query transactions: %w", err)
-	}
-	defer rows.Close()
+	if filter.AccountID > 0 {
+		conditions = append(conditions, "(t.ZACCOUNT2 = ? OR t.ZACCOUNT = ?)")
+		args = append(args, filter.AccountID, filter.AccountID)
+	}
+
+	var start, end time.Time
+	if filter.StartDate != "" {
+		var err error
+		start, _, err = parseDatePeriod(filter.StartDate)
+		if err != nil {
+			return "", nil, "", invalidArgumentf("invalid start_date: %w", err)
+		}
+		conditions = append(conditions, "t.ZDATE1 >= ?")
+		args = append(args, timeToCoreData(start))
+	}
+	if filter.EndDate != "" {
+		var err error
+		_, end, err = parseDatePeriod(filter.EndDate)
+		if err != nil {
+			return "", nil, "", invalidArgumentf("invalid end_date: %w", err)
+		}
+		conditions = append(conditions, "t.ZDATE1 < ?")
+		args = append(args, timeToCoreData(end))
+	}
+	if filter.MinAmount < 0 || filter.MaxAmount < 0 {
+		return "", nil, "", invalidArgumentf("min_amount and max_amount must not be negative")
+	}
+	if filter.MaxAmount > 0 && filter.MinAmount > filter.MaxAmount {
+		return "", nil, "", invalidArgumentf("min_amount %g is greater than max_amount %g", filter.MinAmount, filter.MaxAmount)
+	}
+	if filter.MinAmount > 0 {
+		conditions = append(conditions, "ABS(t.ZAMOUNT1) >= ?")
+		args = append(args, filter.MinAmount)
+	}
+	if filter.MaxAmount > 0 {
+		conditions = append(conditions, "ABS(t.ZAMOUNT1) <= ?")
+		args = append(args, filter.MaxAmount)
+	}
+	switch filter.Status {
+	case "":
+	case "pending", "cleared":
+		if statusColumn == "" {
+			return "", nil, "", invalidArgumentf("this database does not store transaction status, so status %q cannot be filtered", filter.Status)
+		}
+		operator := "="
+		if filter.Status == "cleared" {
+			operator = "<>"
+		}
+		conditions = append(conditions, fmt.Sprintf("COALESCE(%s, 0) %s 0", statusColumn, operator))
+	default:
+		return "", nil, "", invalidArgumentf("invalid status %q: expected pending or cleared", filter.Status)
+	}
+	search := strings.ToLower(strings.TrimSpace(filter.Search))
+	if search != "" {
+		if notesColumn != "" {
+			conditions = append(conditions, fmt.Sprintf("(t.ZDESC2 IS NOT NULL OR %s IS NOT NULL)", notesColumn))
+		} else {
+			conditions = append(conditions, "t.ZDESC2 IS NOT NULL")
+		}
+	}
+	if !start.IsZero() && !end.IsZero() && !start.Before(end) {
+		return "", nil, "", invalidArgumentf("start_date %s is after end_date %s", filter.StartDate, filter.EndDate)
+	}
+
+	return strings.Join(conditions, " AND "), args, search, nil
+}
 
+// GetTransactions retrieves transactions matching the filter, most recent first
+// Transactions are entity types 37, 45, 46, 47, 43 (transfers), linked via ZACCOUNT2, using ZAMOUNT1
+// Dates are Core Data timestamps (seconds since 2001-01-01), converted to ISO format via coreDataToTime
+// A split transaction is listed once per category, each row with the portion booked to that
+// category, as the spending and income analyses attribute it
+// An offset past the last match returns an empty list
+func (db *DB) GetTransactions(ctx context.Context, filter TransactionFilter) ([]Transaction, error) {
 	var transactions []Transaction
-	for rows.Next() {
+	err := db.eachTransaction(ctx, filter, func(txn Transaction) error {
+		transactions = append(transactions, txn)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return transactions, nil
+}
+
+// eachTransaction calls fn with every transaction matching the filter, most recent first, as it
+// is read, so large exports never hold the whole result in memory; an error from fn stops the scan
+func (db *DB) eachTransaction(ctx context.Context, filter TransactionFilter, fn func(Transaction) error) error {
+	if filter.Limit < 0 {
+		return invalidArgumentf("limit must not be negative, got %d", filter.Limit)
+	}
+	if filter.Limit > MaxTransactionLimit {
+		filter.Limit = MaxTransactionLimit
+	}
+
+	statusColumn, err := db.statusColumn(ctx)
+	if err != nil {
+		return err
+	}
+	notesColumn, err := db.notesColumn(ctx)
+	if err != nil {
+		return err
+	}
+	where, args, search, err := filter.whereClause(statusColumn, notesColumn)
+	if err != nil {
+		return err
+	}
+	offset := filter.Offset
+	if offset < 0 {
+		offset = 0
+	}
+
+	payeeExpr, payeeJoin, err := db.payeeSelect(ctx)
+	if err != nil {
+		return err
+	}
+	originalExpr, err := db.originalAmountSelect(ctx)
+	if err != nil {
+		return err
+	}
+	amountExpr, categoryJoin, err := db.categoryAssignmentSelect(ctx)
+	if err != nil {
+		return err
+	}
+	tagsExpr, err := db.transactionTagsSelect(ctx)
+	if err != nil {
+		return err
+	}
+
+	statusExpr := statusColumn
+	if statusExpr == "" {
+		statusExpr = "NULL"
+	}
+	notesExpr := notesColumn
+	if notesExpr == "" {
+		notesExpr = "NULL"
+	}
+
+	query := fmt.Sprintf(`
+		SELECT t.Z_PK, %s, 
+			t.ZDATE1,
+			t.ZDESC2, %s, %s, t.ZACCOUNT2, a.ZNAME, a.ZCURRENCYNAME, c.Z_PK, c.ZNAME2, %s, %s, %s
+		FROM ZSYNCOBJECT t
+		LEFT JOIN ZSYNCOBJECT a ON a.Z_PK = t.ZACCOUNT2 AND a.Z_ENT IN (10, 11, 12, 13, 15, 16)
+		%s
+		LEFT JOIN ZSYNCOBJECT c ON c.Z_PK = ca.ZCATEGORY AND c.Z_ENT = 19
+		%s
+		WHERE %s
+		ORDER BY t.ZDATE1 DESC, t.Z_PK DESC, c.Z_PK
+	`, amountExpr, notesExpr, payeeExpr, originalExpr, statusExpr, tagsExpr, categoryJoin, payeeJoin, where)
+	// SQLite LIKE only folds ASCII case, so searches match in Go and page there
+	if search == "" {
+		limit := filter.Limit
+		if limit == 0 {
+			limit = -1 // SQLite treats a negative LIMIT as unbounded
+		}
+		query += " LIMIT ? OFFSET ?"
+		args = append(args, limit, offset)
+	}
+
+	emitted := 0
+	err = db.queryEach(ctx, "eachTransaction", query, args, func(rows *sql.Rows) error {
 		var txn Transaction
-		var date sql.NullString
+		var date sql.NullFloat64
 		var desc sql.NullString
+		var notes sql.NullString
+		var payee sql.NullString
 		var accountName sql.NullString
 		var currency sql.NullString
 		var categoryID sql.NullInt64
 		var categoryName sql.NullString
-		err := rows.Scan(&txn.ID, &txn.Amount, &date, &desc, &txn.AccountID, &accountName, &currency, &categoryID, &categoryName)
+		var originalAmount sql.NullFloat64
+		var originalCurrency sql.NullString
+		var status sql.NullInt64
+		var tags sql.NullString
+		err := rows.Scan(&txn.ID, &txn.Amount, &date, &desc, &notes, &payee, &txn.AccountID, &accountName, &currency, &categoryID, &categoryName, &originalAmount, &originalCurrency, &status, &tags)
 		if err != nil {
-			return nil, fmt.Errorf("failed to scan transaction: %w", err)
+			return fmt.Errorf("failed to scan transaction: %w", err)
 		}
 		if date.Valid {
-			txn.Date = date.String
+			txn.Date = coreDataToTime(date.Float64).Format(dateTimeLayout)
 		}
 		if desc.Valid {
 			txn.Description = desc.String
 		}
+		if notes.Valid {
+			txn.Notes = notes.String
+		}
+		if search != "" {
+			if !matchesSearch(search, txn.Description, txn.Notes) {
+				return nil
+			}
+			if offset > 0 {
+				offset--
+				return nil
+			}
+		}
+		if payee.Valid {
+			txn.Payee = payee.String
+		}
 		if accountName.Valid {
 			txn.AccountName = accountName.String
 		}
-		if currency.Valid {
-			txn.Currency = currency.String
-		}
+		txn.Currency = db.accountCurrency(currency)
 		if categoryID.Valid {
 			txn.CategoryID = categoryID.Int64
 		}
 		if categoryName.Valid {
 			txn.CategoryName = categoryName.String
 		}
+		if originalAmount.Valid {
+			txn.OriginalAmount = &originalAmount.Float64
+		}
+		if originalCurrency.Valid {
+			txn.OriginalCurrency = originalCurrency.String
+		}
+		if statusColumn != "" {
+			txn.Status = "pending"
+			if status.Int64 != 0 {
+				txn.Status = "cleared"
+			}
+		}
+		txn.Tags = parseTagList(tags)
 		txn.MovementType = detectMovementType(txn.Description)
-		txn.CategoryName = fallbackCategoryName(txn.CategoryName, txn.Description)
-		transactions = append(transactions, txn)
+		txn.CategoryName = fallbackCategoryName(txn.CategoryName, txn.Description, db.uncategorizedLabel)
+		if err := fn(txn); err != nil {
+			return err
+		}
+		emitted++
+		if search != "" && filter.Limit > 0 && emitted >= filter.Limit {
+			return errStopRows
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to query transactions: %w", err)
 	}
 
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating transactions: %w", err)
+	return nil
+}
+
+// SearchTransactions returns the most recent transactions whose description or notes contain
+// query, ignoring case, optionally restricted to one account (accountID 0 = all accounts)
+func (db *DB) SearchTransactions(ctx context.Context, query string, accountID int64, limit int) ([]Transaction, error) {
+	if strings.TrimSpace(query) == "" {
+		return nil, invalidArgumentf("search query must not be empty")
 	}
 
-	return transactions, nil
+	return db.GetTransactions(ctx, TransactionFilter{
+		AccountID: accountID,
+		Limit:     limit,
+		Search:    query,
+	})
+}
+
+// CountTransactions returns how many transactions match the filter, ignoring Limit and Offset
+func (db *DB) CountTransactions(ctx context.Context, filter TransactionFilter) (int, error) {
+	statusColumn, err := db.statusColumn(ctx)
+	if err != nil {
+		return 0, err
+	}
+	notesColumn, err := db.notesColumn(ctx)
+	if err != nil {
+		return 0, err
+	}
+	where, args, search, err := filter.whereClause(statusColumn, notesColumn)
+	if err != nil {
+		return 0, err
+	}
+
+	_, categoryJoin, err := db.categoryAssignmentSelect(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	// Same category join as GetTransactions so a split transaction counts once per listed row
+	from := `
+		FROM ZSYNCOBJECT t
+		` + categoryJoin + `
+		WHERE ` + where
+
+	if search == "" {
+		var count int
+		err := db.runQuery(ctx, "CountTransactions", func() error {
+			return db.reader().QueryRowContext(ctx, "SELECT COUNT(*) "+from, args...).Scan(&count)
+		})
+		if err != nil {
+			return 0, fmt.Errorf("failed to count transactions: %w", err)
+		}
+		return count, nil
+	}
+
+	notesExpr := notesColumn
+	if notesExpr == "" {
+		notesExpr = "NULL"
+	}
+	count := 0
+	err = db.queryEach(ctx, "CountTransactions search", "SELECT t.ZDESC2, "+notesExpr+" "+from, args, func(rows *sql.Rows) error {
+		var desc, notes sql.NullString
+		if err := rows.Scan(&desc, &notes); err != nil {
+			return fmt.Errorf("failed to scan transaction description: %w", err)
+		}
+		if matchesSearch(search, desc.String, notes.String) {
+			count++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to count transactions: %w", err)
+	}
+
+	return count, nil
+}
+
+// matchesSearch reports whether the lowercased search text occurs in the description or notes,
+// ignoring case
+func matchesSearch(search, description, notes string) bool {
+	return strings.Contains(strings.ToLower(description), search) || strings.Contains(strings.ToLower(notes), search)
 }
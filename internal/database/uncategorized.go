@@ -0,0 +1,117 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// UncategorizedReport summarizes transactions without a category, which analyses lump
+// together under the uncategorized label ("Uncategorized" unless Options.UncategorizedLabel is set)
+type UncategorizedReport struct {
+	Months               int                `json:"months"`
+	TransactionCount     int                `json:"transaction_count"`
+	SpendingByCurrency   map[string]float64 `json:"uncategorized_spending_by_currency" money:"by_currency"`
+	IncomeByCurrency     map[string]float64 `json:"uncategorized_income_by_currency" money:"by_currency"`
+	TotalSpending        map[string]float64 `json:"total_spending_by_currency" money:"by_currency"` // All spending in the period, for scale
+	SpendingSharePercent map[string]float64 `json:"uncategorized_spending_pct_by_currency"`         // Share of spending that is uncategorized
+	Transactions         []Transaction      `json:"transactions"`                                   // Most recent first, up to the limit
+}
+
+// GetUncategorizedTransactions lists income and expense transactions that have no category
+// assignment, most recent first, and totals them per currency
+// Transfers and cash withdrawals between own accounts are skipped: they need no category, and
+// neither do scheduled transactions dated in the future
+// months: number of months to look back (0 = all data)
+// limit: maximum number of transactions to list (0 = no limit); totals always cover the whole period
+func (db *DB) GetUncategorizedTransactions(ctx context.Context, months int, limit int) (*UncategorizedReport, error) {
+	payeeExpr, payeeJoin, err := db.payeeSelect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	cutoff, err := db.monthsCutoff(ctx, months, false)
+	if err != nil {
+		return nil, err
+	}
+
+	query := fmt.Sprintf(`
+		SELECT t.Z_PK, t.ZAMOUNT1, t.ZDATE1, t.ZDESC2, %s, t.ZACCOUNT2, a.ZNAME, a.ZCURRENCYNAME
+		FROM ZSYNCOBJECT t
+		LEFT JOIN ZSYNCOBJECT a ON a.Z_PK = t.ZACCOUNT2 AND a.Z_ENT IN (10, 11, 12, 13, 15, 16)
+		%s
+		WHERE t.Z_ENT IN (%s)
+		AND t.ZAMOUNT1 IS NOT NULL
+		AND t.ZDATE1 IS NOT NULL
+		AND t.ZDATE1 >= ?
+		AND t.ZDATE1 <= ?
+		AND NOT EXISTS (
+			SELECT 1
+			FROM ZCATEGORYASSIGMENT ca
+			JOIN ZSYNCOBJECT c ON c.Z_PK = ca.ZCATEGORY AND c.Z_ENT = 19
+			WHERE ca.ZTRANSACTION = t.Z_PK
+		)
+		ORDER BY t.ZDATE1 DESC, t.Z_PK DESC
+	`, payeeExpr, payeeJoin, transactionEntities(false))
+
+	report := &UncategorizedReport{
+		Months:               months,
+		SpendingByCurrency:   make(map[string]float64),
+		IncomeByCurrency:     make(map[string]float64),
+		TotalSpending:        make(map[string]float64),
+		SpendingSharePercent: make(map[string]float64),
+		Transactions:         []Transaction{},
+	}
+
+	err = db.queryEach(ctx, "GetUncategorizedTransactions", query, []any{cutoff, scheduledCutoff(false)}, func(rows *sql.Rows) error {
+		var txn Transaction
+		var date float64
+		var desc sql.NullString
+		var payee sql.NullString
+		var accountID sql.NullInt64
+		var accountName sql.NullString
+		var currency sql.NullString
+		if err := rows.Scan(&txn.ID, &txn.Amount, &date, &desc, &payee, &accountID, &accountName, &currency); err != nil {
+			return fmt.Errorf("failed to scan uncategorized transaction: %w", err)
+		}
+		txn.Description = desc.String
+		txn.MovementType = detectMovementType(txn.Description)
+		if isInternalMovement(txn.MovementType) {
+			return nil
+		}
+		txn.Date = coreDataToTime(date).Format(dateTimeLayout)
+		txn.Payee = payee.String
+		txn.AccountID = accountID.Int64
+		txn.AccountName = accountName.String
+		txn.Currency = db.accountCurrency(currency)
+		txn.CategoryName = fallbackCategoryName("", txn.Description, db.uncategorizedLabel)
+
+		report.TransactionCount++
+		if txn.Amount < 0 {
+			report.SpendingByCurrency[txn.Currency] += -txn.Amount
+		} else {
+			report.IncomeByCurrency[txn.Currency] += txn.Amount
+		}
+		if limit <= 0 || len(report.Transactions) < limit {
+			report.Transactions = append(report.Transactions, txn)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query uncategorized transactions: %w", err)
+	}
+
+	spendingData, err := db.GetSpendingData(ctx, months, false, nil, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get spending data: %w", err)
+	}
+	for _, s := range spendingData {
+		report.TotalSpending[s.Currency] += s.Amount
+	}
+	for currency, uncategorized := range report.SpendingByCurrency {
+		if total := report.TotalSpending[currency]; total > 0 {
+			report.SpendingSharePercent[currency] = (uncategorized / total) * 100
+		}
+	}
+
+	return report, nil
+}
@@ -0,0 +1,126 @@
+package database
+
+import (
+	"context"
+	"sort"
+	"time"
+)
+
+const defaultUpcomingBillsDays = 30
+
+// UpcomingBill represents one expected charge of a recurring bill
+type UpcomingBill struct {
+	Payee          string  `json:"payee"`
+	Currency       string  `json:"currency"`
+	CategoryName   string  `json:"category_name"`
+	Interval       string  `json:"interval"`                       // "monthly" or "yearly"
+	DueDate        string  `json:"due_date"`                       // YYYY-MM-DD
+	DaysUntilDue   int     `json:"days_until_due"`                 // Negative when overdue
+	ExpectedAmount float64 `json:"expected_amount" money:"amount"` // Average amount of the series
+	LastDate       string  `json:"last_date"`
+	Overdue        bool    `json:"overdue"` // Due before as_of but not charged yet
+}
+
+// UpcomingBillsReport represents the recurring bills due in the coming days
+type UpcomingBillsReport struct {
+	AsOf            string             `json:"as_of"`   // YYYY-MM-DD
+	Days            int                `json:"days"`    // Length of the look-ahead window
+	Through         string             `json:"through"` // Last day of the window, YYYY-MM-DD
+	BillCount       int                `json:"bill_count"`
+	OverdueCount    int                `json:"overdue_count"`
+	TotalByCurrency map[string]float64 `json:"total_by_currency" money:"by_currency"` // Expected amounts of every listed bill
+	LapsedCount     int                `json:"lapsed_count"`                          // Series missed for more than a whole interval, assumed cancelled
+	Bills           []UpcomingBill     `json:"bills"`                                 // Overdue first, then by due date
+}
+
+// GetUpcomingBills projects the recurring expenses found by DetectRecurringTransactions forward
+// from their last charge and returns those due within days days after asOfDate, with the
+// expected amount
+// A bill whose next charge should already have happened is listed as overdue; one that has
+// missed a second charge as well is assumed cancelled and only counted in LapsedCount
+// days: length of the window (0 = 30)
+// months: months of history to detect the bills in (0 = all data)
+// asOfDate: the YYYY-MM-DD day to look ahead from ("" = today); later transactions are ignored
+func (db *DB) GetUpcomingBills(ctx context.Context, days, months int, asOfDate string) (*UpcomingBillsReport, error) {
+	if days < 0 {
+		return nil, invalidArgumentf("days must not be negative, got %d", days)
+	}
+	if days == 0 {
+		days = defaultUpcomingBillsDays
+	}
+	asOf, err := parseAsOf("as_of date", asOfDate)
+	if err != nil {
+		return nil, err
+	}
+	through := asOf.AddDate(0, 0, days)
+
+	// Charges later on the as_of day itself count as already paid
+	charges, err := db.recurringCharges(ctx, months, asOf.AddDate(0, 0, 1).Add(-time.Nanosecond))
+	if err != nil {
+		return nil, err
+	}
+	series := detectRecurringSeries(charges, defaultRecurringMinOccurrences, defaultRecurringTolerancePct)
+
+	report := &UpcomingBillsReport{
+		AsOf:            asOf.Format(dayLayout),
+		Days:            days,
+		Through:         through.Format(dayLayout),
+		TotalByCurrency: make(map[string]float64),
+		Bills:           []UpcomingBill{},
+	}
+	for _, s := range series {
+		last, err := time.Parse(dayLayout, s.LastDate)
+		if err != nil {
+			continue
+		}
+
+		// A second missed charge means the bill has most likely been cancelled
+		if nextRecurringDate(last, s.Interval, 2).Before(asOf) {
+			report.LapsedCount++
+			continue
+		}
+
+		// Occurrences are counted from the last charge so month-end clamping does not drift
+		for n := 1; ; n++ {
+			due := nextRecurringDate(last, s.Interval, n)
+			if due.After(through) {
+				break
+			}
+
+			bill := UpcomingBill{
+				Payee:          s.Payee,
+				Currency:       s.Currency,
+				CategoryName:   s.CategoryName,
+				Interval:       s.Interval,
+				DueDate:        due.Format(dayLayout),
+				DaysUntilDue:   int(due.Sub(asOf).Hours() / 24),
+				ExpectedAmount: s.AverageAmount,
+				LastDate:       s.LastDate,
+				Overdue:        due.Before(asOf),
+			}
+			if bill.Overdue {
+				report.OverdueCount++
+			}
+			report.TotalByCurrency[s.Currency] += s.AverageAmount
+			report.Bills = append(report.Bills, bill)
+		}
+	}
+
+	sort.SliceStable(report.Bills, func(i, j int) bool {
+		if report.Bills[i].DueDate != report.Bills[j].DueDate {
+			return report.Bills[i].DueDate < report.Bills[j].DueDate
+		}
+		return report.Bills[i].Payee < report.Bills[j].Payee
+	})
+	report.BillCount = len(report.Bills)
+
+	return report, nil
+}
+
+// nextRecurringDate returns the date n intervals after a charge
+func nextRecurringDate(last time.Time, interval string, n int) time.Time {
+	if interval == RecurringIntervalYearly {
+		return addMonthsClamped(last, 12*n)
+	}
+	return addMonthsClamped(last, n)
+}
@@ -0,0 +1,124 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// WeekdaySpending represents the spending that fell on one day of the week
+type WeekdaySpending struct {
+	Weekday            string             `json:"weekday"` // "Monday" to "Sunday"
+	TotalSpending      float64            `json:"total_spending" money:"amount"`
+	TransactionCount   int                `json:"transaction_count"`
+	Days               int                `json:"days"`                               // Times this weekday occurs in the analyzed range
+	AverageDaily       float64            `json:"average_daily" money:"amount"`       // Total spending / days, counting days without spending
+	AverageTransaction float64            `json:"average_transaction" money:"amount"` // Total spending / transaction count
+	SharePercent       float64            `json:"share_percent"`                      // Percentage of all spending
+	ByCurrency         map[string]float64 `json:"by_currency" money:"by_currency"`
+}
+
+// WeekdaySpendingAnalysis represents spending bucketed by day of the week
+type WeekdaySpendingAnalysis struct {
+	Months              int               `json:"months"`
+	StartDate           string            `json:"start_date,omitempty"` // First day with spending, YYYY-MM-DD
+	EndDate             string            `json:"end_date,omitempty"`   // Last day with spending, YYYY-MM-DD
+	TotalSpending       float64           `json:"total_spending" money:"amount"`
+	Weekdays            []WeekdaySpending `json:"weekdays"` // Monday first
+	BusiestDay          string            `json:"busiest_day,omitempty"`
+	WeekendSpending     float64           `json:"weekend_spending" money:"amount"` // Saturday and Sunday
+	WeekendSharePercent float64           `json:"weekend_share_percent"`           // About 28.6% when every day costs the same
+	MixedCurrencies     bool              `json:"mixed_currencies"`
+	Currencies          []string          `json:"currencies"`
+	CurrencyWarning     string            `json:"currency_warning,omitempty"`
+}
+
+// mondayFirst lists the days of the week in the order they are reported
+var mondayFirst = []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday, time.Saturday, time.Sunday}
+
+// AnalyzeSpendingByWeekday totals spending per day of the week, Monday first, to show habits
+// such as weekend overspending that month and year groupings hide
+// The busiest day is the one with the highest total; average_daily spreads each total over
+// every occurrence of that weekday from the first to the last day with spending
+// months: number of months to analyze (0 = all historical data)
+// Internal transfers and future-dated scheduled transactions are left out, as in the analyses
+func (db *DB) AnalyzeSpendingByWeekday(ctx context.Context, months int) (*WeekdaySpendingAnalysis, error) {
+	spendingData, err := db.GetSpendingData(ctx, months, false, nil, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get spending data: %w", err)
+	}
+
+	byWeekday := make(map[time.Weekday]*WeekdaySpending, len(mondayFirst))
+	for _, weekday := range mondayFirst {
+		byWeekday[weekday] = &WeekdaySpending{Weekday: weekday.String(), ByCurrency: make(map[string]float64)}
+	}
+
+	analysis := &WeekdaySpendingAnalysis{Months: months, Weekdays: make([]WeekdaySpending, 0, len(mondayFirst))}
+	var first, last time.Time
+	currencySet := make(map[string]struct{})
+	for _, s := range spendingData {
+		ts, err := time.Parse(dateTimeLayout, s.Date)
+		if err != nil {
+			continue
+		}
+		day := time.Date(ts.Year(), ts.Month(), ts.Day(), 0, 0, 0, 0, time.UTC)
+		if first.IsZero() || day.Before(first) {
+			first = day
+		}
+		if day.After(last) {
+			last = day
+		}
+
+		bucket := byWeekday[day.Weekday()]
+		bucket.TotalSpending += s.Amount
+		bucket.TransactionCount++
+		if s.Currency != "" {
+			bucket.ByCurrency[s.Currency] += s.Amount
+			currencySet[s.Currency] = struct{}{}
+		}
+		analysis.TotalSpending += s.Amount
+	}
+
+	if !first.IsZero() {
+		analysis.StartDate = first.Format(dayLayout)
+		analysis.EndDate = last.Format(dayLayout)
+		for day := first; !day.After(last); day = day.AddDate(0, 0, 1) {
+			byWeekday[day.Weekday()].Days++
+		}
+	}
+
+	var busiest *WeekdaySpending
+	for _, weekday := range mondayFirst {
+		bucket := byWeekday[weekday]
+		if bucket.Days > 0 {
+			bucket.AverageDaily = bucket.TotalSpending / float64(bucket.Days)
+		}
+		if bucket.TransactionCount > 0 {
+			bucket.AverageTransaction = bucket.TotalSpending / float64(bucket.TransactionCount)
+		}
+		if analysis.TotalSpending > 0 {
+			bucket.SharePercent = bucket.TotalSpending / analysis.TotalSpending * 100
+		}
+		if weekday == time.Saturday || weekday == time.Sunday {
+			analysis.WeekendSpending += bucket.TotalSpending
+		}
+		if bucket.TransactionCount > 0 && (busiest == nil || bucket.TotalSpending > busiest.TotalSpending) {
+			busiest = bucket
+		}
+		analysis.Weekdays = append(analysis.Weekdays, *bucket)
+	}
+	if busiest != nil {
+		analysis.BusiestDay = busiest.Weekday
+	}
+	if analysis.TotalSpending > 0 {
+		analysis.WeekendSharePercent = analysis.WeekendSpending / analysis.TotalSpending * 100
+	}
+
+	analysis.Currencies = sortedCurrencyKeys(currencySet)
+	analysis.MixedCurrencies = len(analysis.Currencies) > 1
+	if analysis.MixedCurrencies {
+		analysis.CurrencyWarning = "Totals combine multiple currencies. Prefer by_currency values for accurate interpretation."
+	}
+
+	return analysis, nil
+}
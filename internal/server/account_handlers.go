@@ -2,24 +2,29 @@ package server
 
 import (
 	"context"
-	"encoding/json"
-	"fmt"
+	"strings"
 
 	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/moneywiz-mcp/internal/database"
 )
 
 func (s *Server) handleListAccounts(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-	accounts, err := s.db.GetAccounts()
-	if err != nil {
-		return &mcp.CallToolResult{
-			Content: []mcp.Content{
-				mcp.TextContent{
-					Type: "text",
-					Text: fmt.Sprintf("Error: %v", err),
-				},
-			},
-			IsError: true,
-		}, nil
+	db, err := s.databaseFor(request)
+	if err != nil {
+		return errorResult(ErrorCodeInvalidArgument, err), nil
+	}
+
+	var filter database.AccountFilter
+	if currency := strings.TrimSpace(request.GetString("currency", "")); currency != "" {
+		filter.Currencies = []string{currency}
+	}
+	if accountType := strings.TrimSpace(request.GetString("account_type", "")); accountType != "" {
+		filter.IncludeTypes = []string{accountType}
+	}
+
+	accounts, err := db.GetFilteredAccounts(ctx, filter)
+	if err != nil {
+		return errorResult(errorCodeFor(err), err), nil
 	}
 
 	currencies, mixedCurrencies, currencyWarning := currencyMetaFromAccounts(accounts)
@@ -30,17 +35,9 @@ func (s *Server) handleListAccounts(ctx context.Context, request mcp.CallToolReq
 		"currency_warning": currencyWarning,
 	}
 
-	jsonData, err := json.MarshalIndent(response, "", "  ")
+	jsonData, err := textContentJSON(request, response)
 	if err != nil {
-		return &mcp.CallToolResult{
-			Content: []mcp.Content{
-				mcp.TextContent{
-					Type: "text",
-					Text: fmt.Sprintf("Error marshaling accounts: %v", err),
-				},
-			},
-			IsError: true,
-		}, nil
+		return marshalErrorResult("accounts", err), nil
 	}
 
 	return &mcp.CallToolResult{
@@ -57,42 +54,90 @@ func (s *Server) handleListAccounts(ctx context.Context, request mcp.CallToolReq
 func (s *Server) handleGetAccountBalance(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	accountIDFloat, err := request.RequireFloat("account_id")
 	if err != nil {
-		return &mcp.CallToolResult{
-			Content: []mcp.Content{
-				mcp.TextContent{
-					Type: "text",
-					Text: fmt.Sprintf("Error: %v", err),
-				},
-			},
-			IsError: true,
-		}, nil
+		return errorResult(ErrorCodeInvalidArgument, err), nil
 	}
 	accountID := int64(accountIDFloat)
 
-	account, err := s.db.GetAccountBalance(accountID)
+	db, err := s.databaseFor(request)
 	if err != nil {
-		return &mcp.CallToolResult{
-			Content: []mcp.Content{
-				mcp.TextContent{
-					Type: "text",
-					Text: fmt.Sprintf("Error: %v", err),
-				},
+		return errorResult(ErrorCodeInvalidArgument, err), nil
+	}
+
+	account, err := db.GetAccountBalance(ctx, accountID)
+	if err != nil {
+		return errorResult(errorCodeFor(err), err), nil
+	}
+
+	jsonData, err := textContentJSON(request, account)
+	if err != nil {
+		return marshalErrorResult("account", err), nil
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: string(jsonData),
 			},
-			IsError: true,
-		}, nil
+		},
+		StructuredContent: account,
+	}, nil
+}
+
+func (s *Server) handleProjectAccountDepletion(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	accountIDFloat, err := request.RequireFloat("account_id")
+	if err != nil {
+		return errorResult(ErrorCodeInvalidArgument, err), nil
+	}
+	historyMonths := request.GetInt("history_months", 6)
+	targetBalance := request.GetFloat("target_balance", 0)
+
+	db, err := s.databaseFor(request)
+	if err != nil {
+		return errorResult(ErrorCodeInvalidArgument, err), nil
 	}
 
-	jsonData, err := json.MarshalIndent(account, "", "  ")
+	projection, err := db.ProjectAccountDepletion(ctx, int64(accountIDFloat), historyMonths, targetBalance)
 	if err != nil {
-		return &mcp.CallToolResult{
-			Content: []mcp.Content{
-				mcp.TextContent{
-					Type: "text",
-					Text: fmt.Sprintf("Error marshaling account: %v", err),
-				},
+		return errorResult(errorCodeFor(err), err), nil
+	}
+
+	jsonData, err := textContentJSON(request, projection)
+	if err != nil {
+		return marshalErrorResult("depletion projection", err), nil
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: string(jsonData),
 			},
-			IsError: true,
-		}, nil
+		},
+		StructuredContent: projection,
+	}, nil
+}
+
+func (s *Server) handleGetBalanceHistory(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	accountIDFloat, err := request.RequireFloat("account_id")
+	if err != nil {
+		return errorResult(ErrorCodeInvalidArgument, err), nil
+	}
+	months := request.GetInt("months", 12)
+
+	db, err := s.databaseFor(request)
+	if err != nil {
+		return errorResult(ErrorCodeInvalidArgument, err), nil
+	}
+
+	history, err := db.GetAccountBalanceHistory(ctx, int64(accountIDFloat), months)
+	if err != nil {
+		return errorResult(errorCodeFor(err), err), nil
+	}
+
+	jsonData, err := textContentJSON(request, history)
+	if err != nil {
+		return marshalErrorResult("balance history", err), nil
 	}
 
 	return &mcp.CallToolResult{
@@ -102,6 +147,131 @@ func (s *Server) handleGetAccountBalance(ctx context.Context, request mcp.CallTo
 				Text: string(jsonData),
 			},
 		},
-		StructuredContent: account,
+		StructuredContent: history,
+	}, nil
+}
+
+func (s *Server) handleGetInvestmentHoldings(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	accountIDFloat, err := request.RequireFloat("account_id")
+	if err != nil {
+		return errorResult(ErrorCodeInvalidArgument, err), nil
+	}
+
+	db, err := s.databaseFor(request)
+	if err != nil {
+		return errorResult(ErrorCodeInvalidArgument, err), nil
+	}
+
+	holdings, err := db.GetInvestmentHoldings(ctx, int64(accountIDFloat))
+	if err != nil {
+		return errorResult(errorCodeFor(err), err), nil
+	}
+
+	jsonData, err := textContentJSON(request, holdings)
+	if err != nil {
+		return marshalErrorResult("investment holdings", err), nil
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: string(jsonData),
+			},
+		},
+		StructuredContent: holdings,
+	}, nil
+}
+
+func (s *Server) handleListAccountGroups(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	db, err := s.databaseFor(request)
+	if err != nil {
+		return errorResult(ErrorCodeInvalidArgument, err), nil
+	}
+
+	groups, err := db.GetAccountGroups(ctx)
+	if err != nil {
+		return errorResult(errorCodeFor(err), err), nil
+	}
+
+	jsonData, err := textContentJSON(request, groups)
+	if err != nil {
+		return marshalErrorResult("account groups", err), nil
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: string(jsonData),
+			},
+		},
+		StructuredContent: groups,
+	}, nil
+}
+
+func (s *Server) handleGetBalanceAsOf(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	accountIDFloat, err := request.RequireFloat("account_id")
+	if err != nil {
+		return errorResult(ErrorCodeInvalidArgument, err), nil
+	}
+	date, err := request.RequireString("date")
+	if err != nil {
+		return errorResult(ErrorCodeInvalidArgument, err), nil
+	}
+
+	db, err := s.databaseFor(request)
+	if err != nil {
+		return errorResult(ErrorCodeInvalidArgument, err), nil
+	}
+
+	balance, err := db.GetAccountBalanceAsOf(ctx, int64(accountIDFloat), date)
+	if err != nil {
+		return errorResult(errorCodeFor(err), err), nil
+	}
+
+	jsonData, err := textContentJSON(request, balance)
+	if err != nil {
+		return marshalErrorResult("balance", err), nil
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: string(jsonData),
+			},
+		},
+		StructuredContent: balance,
+	}, nil
+}
+
+func (s *Server) handleListDormantAccounts(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	days := request.GetInt("days", 0)
+	asOf := request.GetString("as_of", "")
+
+	db, err := s.databaseFor(request)
+	if err != nil {
+		return errorResult(ErrorCodeInvalidArgument, err), nil
+	}
+
+	report, err := db.GetDormantAccounts(ctx, days, asOf)
+	if err != nil {
+		return errorResult(errorCodeFor(err), err), nil
+	}
+
+	jsonData, err := textContentJSON(request, report)
+	if err != nil {
+		return marshalErrorResult("dormant accounts", err), nil
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: string(jsonData),
+			},
+		},
+		StructuredContent: report,
 	}, nil
 }
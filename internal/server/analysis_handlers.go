@@ -2,30 +2,118 @@ package server
 
 import (
 	"context"
-	"encoding/json"
-	"fmt"
 
 	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/moneywiz-mcp/internal/database"
 )
 
 func (s *Server) handleAnalyzeSpendingTrends(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	groupBy := normalizeGroupBy(request.GetString("group_by", "month"))
 	months := request.GetInt("months", 0)
+	includeTransfers := request.GetBool("include_transfers", false)
+	includeScheduled := request.GetBool("include_scheduled", false)
+	rollup := request.GetBool("rollup", false)
+	categoryIDs, err := parseCategoryIDs(request.GetArguments()["category_ids"])
+	if err != nil {
+		return errorResult(ErrorCodeInvalidArgument, err), nil
+	}
+	aliases, err := parseCategoryAliases(request.GetArguments()["category_aliases"])
+	if err != nil {
+		return errorResult(ErrorCodeInvalidArgument, err), nil
+	}
+	rates, err := parseExchangeRates(request.GetArguments()["exchange_rates"])
+	if err != nil {
+		return errorResult(ErrorCodeInvalidArgument, err), nil
+	}
 
-	trends, err := s.db.AnalyzeSpendingTrends(groupBy, months)
+	db, err := s.databaseFor(request)
 	if err != nil {
-		return &mcp.CallToolResult{
-			Content: []mcp.Content{
-				mcp.TextContent{
-					Type: "text",
-					Text: fmt.Sprintf("Error: %v", err),
-				},
-			},
-			IsError: true,
-		}, nil
+		return errorResult(ErrorCodeInvalidArgument, err), nil
+	}
+
+	targetCurrency, err := s.targetCurrencyFor(ctx, request, db, rates)
+	if err != nil {
+		return errorResult(errorCodeFor(err), err), nil
+	}
+
+	var trends []database.SpendingTrend
+	var conversion *database.CurrencyConversion
+	opts := database.SpendingTrendOptions{
+		GroupBy:          groupBy,
+		Months:           months,
+		IncludeTransfers: includeTransfers,
+		CategoryIDs:      categoryIDs,
+		IncludeScheduled: includeScheduled,
+		Rollup:           rollup,
+		Aliases:          aliases,
+	}
+	if targetCurrency != "" {
+		trends, conversion, err = db.AnalyzeSpendingTrendsInCurrency(ctx, targetCurrency, rates, opts)
+	} else {
+		trends, err = db.AnalyzeSpendingTrends(ctx, opts)
+	}
+	if err != nil {
+		return errorResult(errorCodeFor(err), err), nil
 	}
 
 	currencies, mixedCurrencies, currencyWarning := currencyMetaFromSpendingTrends(trends)
+	response := map[string]interface{}{
+		"trends":                     trends,
+		"fastest_growing_categories": database.FastestGrowingCategories(trends, 5),
+		"group_by":                   groupBy,
+		"months":                     months,
+		"currencies":                 currencies,
+		"mixed_currencies":           mixedCurrencies,
+		"currency_warning":           currencyWarning,
+	}
+	// Converted totals are all in the target currency, unless a currency had no rate and was
+	// summed at 1.0; by_currency keeps the original amounts
+	if conversion != nil {
+		response["target_currency"] = conversion.TargetCurrency
+		response["exchange_rates"] = conversion.ExchangeRates
+		if len(conversion.Warnings) > 0 {
+			response["mixed_currencies"] = true
+			response["currency_warning"] = "Totals still mix currencies: some had no exchange rate and were counted at 1.0 (see warnings)."
+			response["warnings"] = conversion.Warnings
+		} else {
+			response["mixed_currencies"] = false
+			response["currency_warning"] = ""
+		}
+	}
+
+	jsonData, err := textContentJSON(request, response)
+	if err != nil {
+		return marshalErrorResult("trends", err), nil
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: string(jsonData),
+			},
+		},
+		StructuredContent: response,
+	}, nil
+}
+
+func (s *Server) handleAnalyzeIncomeTrends(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	groupBy := normalizeGroupBy(request.GetString("group_by", "month"))
+	months := request.GetInt("months", 0)
+	includeTransfers := request.GetBool("include_transfers", false)
+	includeScheduled := request.GetBool("include_scheduled", false)
+
+	db, err := s.databaseFor(request)
+	if err != nil {
+		return errorResult(ErrorCodeInvalidArgument, err), nil
+	}
+
+	trends, err := db.AnalyzeIncomeTrends(ctx, groupBy, months, includeTransfers, includeScheduled)
+	if err != nil {
+		return errorResult(errorCodeFor(err), err), nil
+	}
+
+	currencies, mixedCurrencies, currencyWarning := currencyMetaFromIncomeTrends(trends)
 	response := map[string]interface{}{
 		"trends":           trends,
 		"group_by":         groupBy,
@@ -35,17 +123,9 @@ func (s *Server) handleAnalyzeSpendingTrends(ctx context.Context, request mcp.Ca
 		"currency_warning": currencyWarning,
 	}
 
-	jsonData, err := json.MarshalIndent(response, "", "  ")
+	jsonData, err := textContentJSON(request, response)
 	if err != nil {
-		return &mcp.CallToolResult{
-			Content: []mcp.Content{
-				mcp.TextContent{
-					Type: "text",
-					Text: fmt.Sprintf("Error marshaling trends: %v", err),
-				},
-			},
-			IsError: true,
-		}, nil
+		return marshalErrorResult("trends", err), nil
 	}
 
 	return &mcp.CallToolResult{
@@ -59,26 +139,25 @@ func (s *Server) handleAnalyzeSpendingTrends(ctx context.Context, request mcp.Ca
 	}, nil
 }
 
-func (s *Server) handleAnalyzeIncomeTrends(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+func (s *Server) handleAnalyzeNetSavingsTrend(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	groupBy := normalizeGroupBy(request.GetString("group_by", "month"))
 	months := request.GetInt("months", 0)
+	includeTransfers := request.GetBool("include_transfers", false)
+	includeScheduled := request.GetBool("include_scheduled", false)
 
-	trends, err := s.db.AnalyzeIncomeTrends(groupBy, months)
+	db, err := s.databaseFor(request)
 	if err != nil {
-		return &mcp.CallToolResult{
-			Content: []mcp.Content{
-				mcp.TextContent{
-					Type: "text",
-					Text: fmt.Sprintf("Error: %v", err),
-				},
-			},
-			IsError: true,
-		}, nil
+		return errorResult(ErrorCodeInvalidArgument, err), nil
 	}
 
-	currencies, mixedCurrencies, currencyWarning := currencyMetaFromIncomeTrends(trends)
+	periods, err := db.AnalyzeNetSavingsTrend(ctx, groupBy, months, includeTransfers, includeScheduled)
+	if err != nil {
+		return errorResult(errorCodeFor(err), err), nil
+	}
+
+	currencies, mixedCurrencies, currencyWarning := currencyMetaFromNetSavingsTrend(periods)
 	response := map[string]interface{}{
-		"trends":           trends,
+		"periods":          periods,
 		"group_by":         groupBy,
 		"months":           months,
 		"currencies":       currencies,
@@ -86,17 +165,9 @@ func (s *Server) handleAnalyzeIncomeTrends(ctx context.Context, request mcp.Call
 		"currency_warning": currencyWarning,
 	}
 
-	jsonData, err := json.MarshalIndent(response, "", "  ")
+	jsonData, err := textContentJSON(request, response)
 	if err != nil {
-		return &mcp.CallToolResult{
-			Content: []mcp.Content{
-				mcp.TextContent{
-					Type: "text",
-					Text: fmt.Sprintf("Error marshaling trends: %v", err),
-				},
-			},
-			IsError: true,
-		}, nil
+		return marshalErrorResult("net savings trend", err), nil
 	}
 
 	return &mcp.CallToolResult{
@@ -112,31 +183,560 @@ func (s *Server) handleAnalyzeIncomeTrends(ctx context.Context, request mcp.Call
 
 func (s *Server) handleGetSavingsRecommendations(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	months := request.GetInt("months", 0)
+	excludeMonths := request.GetStringSlice("exclude_months", nil)
+	includeTransfers := request.GetBool("include_transfers", false)
+	includeScheduled := request.GetBool("include_scheduled", false)
+	rollup := request.GetBool("rollup", false)
+	netRefunds := request.GetBool("net_refunds", false)
+	topCategories := request.GetInt("top_categories", 5)
+	targets := database.SavingsTargets{
+		SavingsRate:         request.GetFloat("target_savings_rate", 0),
+		EmergencyFundMonths: request.GetFloat("target_emergency_fund_months", 0),
+	}
+	benchmarks, err := parseSavingsBenchmarks(request.GetArguments()["savings_benchmarks"])
+	if err != nil {
+		return errorResult(ErrorCodeInvalidArgument, err), nil
+	}
+	targets.Benchmarks = benchmarks
+	aliases, err := parseCategoryAliases(request.GetArguments()["category_aliases"])
+	if err != nil {
+		return errorResult(ErrorCodeInvalidArgument, err), nil
+	}
+
+	db, err := s.databaseFor(request)
+	if err != nil {
+		return errorResult(ErrorCodeInvalidArgument, err), nil
+	}
+
+	analysis, err := db.AnalyzeSavings(ctx, database.SavingsOptions{
+		Months:           months,
+		ExcludeMonths:    excludeMonths,
+		IncludeTransfers: includeTransfers,
+		IncludeScheduled: includeScheduled,
+		Targets:          targets,
+		Rollup:           rollup,
+		NetRefunds:       netRefunds,
+		TopCategories:    topCategories,
+		Aliases:          aliases,
+	})
+	if err != nil {
+		return errorResult(errorCodeFor(err), err), nil
+	}
+
+	jsonData, err := textContentJSON(request, analysis)
+	if err != nil {
+		return marshalErrorResult("analysis", err), nil
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: string(jsonData),
+			},
+		},
+		StructuredContent: analysis,
+	}, nil
+}
+
+func (s *Server) handleGetCategoryTrendDirections(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	months := request.GetInt("months", 12)
+
+	db, err := s.databaseFor(request)
+	if err != nil {
+		return errorResult(ErrorCodeInvalidArgument, err), nil
+	}
+
+	directions, err := db.GetCategoryTrendDirections(ctx, months)
+	if err != nil {
+		return errorResult(errorCodeFor(err), err), nil
+	}
+
+	jsonData, err := textContentJSON(request, directions)
+	if err != nil {
+		return marshalErrorResult("category trend directions", err), nil
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: string(jsonData),
+			},
+		},
+		StructuredContent: directions,
+	}, nil
+}
+
+func (s *Server) handleDetectPossibleDoubleCharges(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	months := request.GetInt("months", 3)
+	windowHours := request.GetInt("window_hours", 72)
+	amountTolerancePct := request.GetFloat("amount_tolerance_pct", 5)
+
+	db, err := s.databaseFor(request)
+	if err != nil {
+		return errorResult(ErrorCodeInvalidArgument, err), nil
+	}
+
+	report, err := db.DetectPossibleDoubleCharges(ctx, months, windowHours, amountTolerancePct)
+	if err != nil {
+		return errorResult(errorCodeFor(err), err), nil
+	}
+
+	jsonData, err := textContentJSON(request, report)
+	if err != nil {
+		return marshalErrorResult("double charges", err), nil
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: string(jsonData),
+			},
+		},
+		StructuredContent: report,
+	}, nil
+}
+
+func (s *Server) handleProjectInflationImpact(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	annualInflationPct := request.GetFloat("annual_inflation_pct", 3)
+	years := request.GetInt("years", 10)
+	months := request.GetInt("months", 12)
+
+	db, err := s.databaseFor(request)
+	if err != nil {
+		return errorResult(ErrorCodeInvalidArgument, err), nil
+	}
+
+	projection, err := db.ProjectInflationImpact(ctx, annualInflationPct, years, months)
+	if err != nil {
+		return errorResult(errorCodeFor(err), err), nil
+	}
+
+	jsonData, err := textContentJSON(request, projection)
+	if err != nil {
+		return marshalErrorResult("inflation projection", err), nil
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: string(jsonData),
+			},
+		},
+		StructuredContent: projection,
+	}, nil
+}
+
+func (s *Server) handleAnalyzeSpendingByPayee(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	months := request.GetInt("months", 12)
+
+	db, err := s.databaseFor(request)
+	if err != nil {
+		return errorResult(ErrorCodeInvalidArgument, err), nil
+	}
+
+	analysis, err := db.AnalyzeSpendingByPayee(ctx, months)
+	if err != nil {
+		return errorResult(errorCodeFor(err), err), nil
+	}
+
+	jsonData, err := textContentJSON(request, analysis)
+	if err != nil {
+		return marshalErrorResult("spending by payee", err), nil
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: string(jsonData),
+			},
+		},
+		StructuredContent: analysis,
+	}, nil
+}
+
+func (s *Server) handleDetectRecurringTransactions(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	months := request.GetInt("months", 24)
+	minOccurrences := request.GetInt("min_occurrences", 0)
+	amountTolerancePct := request.GetFloat("amount_tolerance_pct", 0)
+
+	db, err := s.databaseFor(request)
+	if err != nil {
+		return errorResult(ErrorCodeInvalidArgument, err), nil
+	}
+
+	report, err := db.DetectRecurringTransactions(ctx, months, minOccurrences, amountTolerancePct)
+	if err != nil {
+		return errorResult(errorCodeFor(err), err), nil
+	}
+
+	jsonData, err := textContentJSON(request, report)
+	if err != nil {
+		return marshalErrorResult("recurring transactions", err), nil
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: string(jsonData),
+			},
+		},
+		StructuredContent: report,
+	}, nil
+}
+
+func (s *Server) handleForecastCashFlow(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	months := request.GetInt("months", 6)
+	historyMonths := request.GetInt("history_months", 6)
+
+	db, err := s.databaseFor(request)
+	if err != nil {
+		return errorResult(ErrorCodeInvalidArgument, err), nil
+	}
+
+	forecast, err := db.ForecastCashFlow(ctx, months, historyMonths)
+	if err != nil {
+		return errorResult(errorCodeFor(err), err), nil
+	}
+
+	jsonData, err := textContentJSON(request, forecast)
+	if err != nil {
+		return marshalErrorResult("cash flow forecast", err), nil
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: string(jsonData),
+			},
+		},
+		StructuredContent: forecast,
+	}, nil
+}
+
+func (s *Server) handleDetectSpendingAnomalies(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	months := request.GetInt("months", 12)
+	stdDevThreshold := request.GetFloat("std_dev_threshold", 2)
+	trailingThresholdPct := request.GetFloat("trailing_threshold_pct", 50)
+
+	db, err := s.databaseFor(request)
+	if err != nil {
+		return errorResult(ErrorCodeInvalidArgument, err), nil
+	}
+
+	report, err := db.DetectSpendingAnomalies(ctx, months, stdDevThreshold, trailingThresholdPct)
+	if err != nil {
+		return errorResult(errorCodeFor(err), err), nil
+	}
+
+	jsonData, err := textContentJSON(request, report)
+	if err != nil {
+		return marshalErrorResult("spending anomalies", err), nil
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: string(jsonData),
+			},
+		},
+		StructuredContent: report,
+	}, nil
+}
+
+func (s *Server) handleComparePeriods(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	basePeriod := request.GetString("base_period", "")
+	comparePeriod := request.GetString("compare_period", "")
+	includeTransfers := request.GetBool("include_transfers", false)
+
+	db, err := s.databaseFor(request)
+	if err != nil {
+		return errorResult(ErrorCodeInvalidArgument, err), nil
+	}
+
+	comparison, err := db.ComparePeriods(ctx, basePeriod, comparePeriod, includeTransfers)
+	if err != nil {
+		return errorResult(errorCodeFor(err), err), nil
+	}
+
+	jsonData, err := textContentJSON(request, comparison)
+	if err != nil {
+		return marshalErrorResult("period comparison", err), nil
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: string(jsonData),
+			},
+		},
+		StructuredContent: comparison,
+	}, nil
+}
+
+func (s *Server) handleCompareSamePeriodLastYear(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	period := request.GetString("period", "")
+	includeTransfers := request.GetBool("include_transfers", false)
+
+	db, err := s.databaseFor(request)
+	if err != nil {
+		return errorResult(ErrorCodeInvalidArgument, err), nil
+	}
+
+	comparison, err := db.CompareSamePeriodLastYear(ctx, period, includeTransfers)
+	if err != nil {
+		return errorResult(errorCodeFor(err), err), nil
+	}
+
+	jsonData, err := textContentJSON(request, comparison)
+	if err != nil {
+		return marshalErrorResult("period comparison", err), nil
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: string(jsonData),
+			},
+		},
+		StructuredContent: comparison,
+	}, nil
+}
+
+func (s *Server) handleGetTopMerchants(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	months := request.GetInt("months", 12)
+	limit := request.GetInt("limit", 10)
+
+	db, err := s.databaseFor(request)
+	if err != nil {
+		return errorResult(ErrorCodeInvalidArgument, err), nil
+	}
+
+	merchants, err := db.GetTopMerchants(ctx, months, limit)
+	if err != nil {
+		return errorResult(errorCodeFor(err), err), nil
+	}
+
+	jsonData, err := textContentJSON(request, merchants)
+	if err != nil {
+		return marshalErrorResult("top merchants", err), nil
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: string(jsonData),
+			},
+		},
+		StructuredContent: merchants,
+	}, nil
+}
+
+func (s *Server) handleGetSavingsGoals(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	historyMonths := request.GetInt("history_months", 6)
+
+	db, err := s.databaseFor(request)
+	if err != nil {
+		return errorResult(ErrorCodeInvalidArgument, err), nil
+	}
+
+	goals, err := db.GetSavingsGoals(ctx, historyMonths)
+	if err != nil {
+		return errorResult(errorCodeFor(err), err), nil
+	}
+
+	jsonData, err := textContentJSON(request, goals)
+	if err != nil {
+		return marshalErrorResult("savings goals", err), nil
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: string(jsonData),
+			},
+		},
+		StructuredContent: goals,
+	}, nil
+}
+
+func (s *Server) handleGetBurnRate(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	trailingDays := request.GetInt("trailing_days", 30)
+	asOf := request.GetString("as_of", "")
+
+	db, err := s.databaseFor(request)
+	if err != nil {
+		return errorResult(ErrorCodeInvalidArgument, err), nil
+	}
+
+	burnRate, err := db.GetBurnRate(ctx, trailingDays, asOf)
+	if err != nil {
+		return errorResult(errorCodeFor(err), err), nil
+	}
+
+	jsonData, err := textContentJSON(request, burnRate)
+	if err != nil {
+		return marshalErrorResult("burn rate", err), nil
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: string(jsonData),
+			},
+		},
+		StructuredContent: burnRate,
+	}, nil
+}
+
+func (s *Server) handleAnalyzeSpendingByTag(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	months := request.GetInt("months", 12)
+
+	db, err := s.databaseFor(request)
+	if err != nil {
+		return errorResult(ErrorCodeInvalidArgument, err), nil
+	}
+
+	analysis, err := db.AnalyzeSpendingByTag(ctx, months)
+	if err != nil {
+		return errorResult(errorCodeFor(err), err), nil
+	}
+
+	jsonData, err := textContentJSON(request, analysis)
+	if err != nil {
+		return marshalErrorResult("spending by tag", err), nil
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: string(jsonData),
+			},
+		},
+		StructuredContent: analysis,
+	}, nil
+}
+
+func (s *Server) handleGetSinceLastPaycheck(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	historyMonths := request.GetInt("history_months", 12)
+	asOf := request.GetString("as_of", "")
+
+	db, err := s.databaseFor(request)
+	if err != nil {
+		return errorResult(ErrorCodeInvalidArgument, err), nil
+	}
+
+	period, err := db.GetSinceLastPaycheck(ctx, historyMonths, asOf)
+	if err != nil {
+		return errorResult(errorCodeFor(err), err), nil
+	}
+
+	jsonData, err := textContentJSON(request, period)
+	if err != nil {
+		return marshalErrorResult("paycheck period", err), nil
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: string(jsonData),
+			},
+		},
+		StructuredContent: period,
+	}, nil
+}
+
+func (s *Server) handleAnalyzeSpendingByWeekday(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	months := request.GetInt("months", 12)
+
+	db, err := s.databaseFor(request)
+	if err != nil {
+		return errorResult(ErrorCodeInvalidArgument, err), nil
+	}
+
+	analysis, err := db.AnalyzeSpendingByWeekday(ctx, months)
+	if err != nil {
+		return errorResult(errorCodeFor(err), err), nil
+	}
 
-	analysis, err := s.db.AnalyzeSavings(months)
+	jsonData, err := textContentJSON(request, analysis)
 	if err != nil {
-		return &mcp.CallToolResult{
-			Content: []mcp.Content{
-				mcp.TextContent{
-					Type: "text",
-					Text: fmt.Sprintf("Error: %v", err),
-				},
+		return marshalErrorResult("weekday spending analysis", err), nil
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: string(jsonData),
 			},
-			IsError: true,
-		}, nil
+		},
+		StructuredContent: analysis,
+	}, nil
+}
+
+func (s *Server) handleDetectDuplicateTransactions(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	months := request.GetInt("months", 12)
+	dateToleranceDays := request.GetInt("date_tolerance_days", 0)
+
+	db, err := s.databaseFor(request)
+	if err != nil {
+		return errorResult(ErrorCodeInvalidArgument, err), nil
+	}
+
+	report, err := db.DetectDuplicateTransactions(ctx, months, dateToleranceDays)
+	if err != nil {
+		return errorResult(errorCodeFor(err), err), nil
 	}
 
-	jsonData, err := json.MarshalIndent(analysis, "", "  ")
+	jsonData, err := textContentJSON(request, report)
 	if err != nil {
-		return &mcp.CallToolResult{
-			Content: []mcp.Content{
-				mcp.TextContent{
-					Type: "text",
-					Text: fmt.Sprintf("Error marshaling analysis: %v", err),
-				},
+		return marshalErrorResult("duplicate transactions", err), nil
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: string(jsonData),
 			},
-			IsError: true,
-		}, nil
+		},
+		StructuredContent: report,
+	}, nil
+}
+
+func (s *Server) handleAnalyzeIncomeSources(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	months := request.GetInt("months", 12)
+	groupBy := request.GetString("group_by", database.IncomeSourceByCategory)
+	thresholdPercent := request.GetFloat("threshold_percent", database.DefaultIncomeConcentrationThreshold)
+
+	db, err := s.databaseFor(request)
+	if err != nil {
+		return errorResult(ErrorCodeInvalidArgument, err), nil
+	}
+
+	analysis, err := db.AnalyzeIncomeSources(ctx, months, groupBy, thresholdPercent)
+	if err != nil {
+		return errorResult(errorCodeFor(err), err), nil
+	}
+
+	jsonData, err := textContentJSON(request, analysis)
+	if err != nil {
+		return marshalErrorResult("income source analysis", err), nil
 	}
 
 	return &mcp.CallToolResult{
@@ -149,3 +749,124 @@ func (s *Server) handleGetSavingsRecommendations(ctx context.Context, request mc
 		StructuredContent: analysis,
 	}, nil
 }
+
+func (s *Server) handleGetUpcomingBills(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	days := request.GetInt("days", 30)
+	months := request.GetInt("months", 24)
+	asOf := request.GetString("as_of", "")
+
+	db, err := s.databaseFor(request)
+	if err != nil {
+		return errorResult(ErrorCodeInvalidArgument, err), nil
+	}
+
+	report, err := db.GetUpcomingBills(ctx, days, months, asOf)
+	if err != nil {
+		return errorResult(errorCodeFor(err), err), nil
+	}
+
+	jsonData, err := textContentJSON(request, report)
+	if err != nil {
+		return marshalErrorResult("upcoming bills", err), nil
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: string(jsonData),
+			},
+		},
+		StructuredContent: report,
+	}, nil
+}
+
+func (s *Server) handleGetSpendingVelocity(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	months := request.GetInt("months", 6)
+	asOf := request.GetString("as_of", "")
+
+	db, err := s.databaseFor(request)
+	if err != nil {
+		return errorResult(ErrorCodeInvalidArgument, err), nil
+	}
+
+	velocity, err := db.GetSpendingVelocity(ctx, months, asOf)
+	if err != nil {
+		return errorResult(errorCodeFor(err), err), nil
+	}
+
+	jsonData, err := textContentJSON(request, velocity)
+	if err != nil {
+		return marshalErrorResult("spending velocity", err), nil
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: string(jsonData),
+			},
+		},
+		StructuredContent: velocity,
+	}, nil
+}
+
+func (s *Server) handleListScheduledTransactions(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	days := request.GetInt("days", 0)
+	asOf := request.GetString("as_of", "")
+
+	db, err := s.databaseFor(request)
+	if err != nil {
+		return errorResult(ErrorCodeInvalidArgument, err), nil
+	}
+
+	report, err := db.GetScheduledTransactions(ctx, days, asOf)
+	if err != nil {
+		return errorResult(errorCodeFor(err), err), nil
+	}
+
+	jsonData, err := textContentJSON(request, report)
+	if err != nil {
+		return marshalErrorResult("scheduled transactions", err), nil
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: string(jsonData),
+			},
+		},
+		StructuredContent: report,
+	}, nil
+}
+
+func (s *Server) handleGetExpenseRatioTrend(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	months := request.GetInt("months", 0)
+	includeTransfers := request.GetBool("include_transfers", false)
+
+	db, err := s.databaseFor(request)
+	if err != nil {
+		return errorResult(ErrorCodeInvalidArgument, err), nil
+	}
+
+	trend, err := db.GetExpenseRatioTrend(ctx, months, includeTransfers)
+	if err != nil {
+		return errorResult(errorCodeFor(err), err), nil
+	}
+
+	jsonData, err := textContentJSON(request, trend)
+	if err != nil {
+		return marshalErrorResult("expense ratio trend", err), nil
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: string(jsonData),
+			},
+		},
+		StructuredContent: trend,
+	}, nil
+}
@@ -2,37 +2,24 @@ package server
 
 import (
 	"context"
-	"encoding/json"
-	"fmt"
 
 	"github.com/mark3labs/mcp-go/mcp"
 )
 
 func (s *Server) handleListCategories(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-	categories, err := s.db.GetCategories()
-	if err != nil {
-		return &mcp.CallToolResult{
-			Content: []mcp.Content{
-				mcp.TextContent{
-					Type: "text",
-					Text: fmt.Sprintf("Error: %v", err),
-				},
-			},
-			IsError: true,
-		}, nil
+	db, err := s.databaseFor(request)
+	if err != nil {
+		return errorResult(ErrorCodeInvalidArgument, err), nil
 	}
 
-	jsonData, err := json.MarshalIndent(categories, "", "  ")
+	categories, err := db.GetCategories(ctx, request.GetString("type", ""))
 	if err != nil {
-		return &mcp.CallToolResult{
-			Content: []mcp.Content{
-				mcp.TextContent{
-					Type: "text",
-					Text: fmt.Sprintf("Error marshaling categories: %v", err),
-				},
-			},
-			IsError: true,
-		}, nil
+		return errorResult(errorCodeFor(err), err), nil
+	}
+
+	jsonData, err := textContentJSON(request, categories)
+	if err != nil {
+		return marshalErrorResult("categories", err), nil
 	}
 
 	return &mcp.CallToolResult{
@@ -47,3 +34,126 @@ func (s *Server) handleListCategories(ctx context.Context, request mcp.CallToolR
 		},
 	}, nil
 }
+
+func (s *Server) handleListCategoriesWithTotals(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	months := request.GetInt("months", 12)
+	categoryType := request.GetString("type", "")
+
+	db, err := s.databaseFor(request)
+	if err != nil {
+		return errorResult(ErrorCodeInvalidArgument, err), nil
+	}
+
+	categories, err := db.GetCategoriesWithTotals(ctx, months, categoryType)
+	if err != nil {
+		return errorResult(errorCodeFor(err), err), nil
+	}
+
+	jsonData, err := textContentJSON(request, categories)
+	if err != nil {
+		return marshalErrorResult("categories with totals", err), nil
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: string(jsonData),
+			},
+		},
+		StructuredContent: categories,
+	}, nil
+}
+
+func (s *Server) handleGetSpendingForCategory(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	categoryID := int64(request.GetInt("category_id", 0))
+	categoryName := request.GetString("category", "")
+	months := request.GetInt("months", 12)
+	includeSubcategories := request.GetBool("include_subcategories", false)
+
+	db, err := s.databaseFor(request)
+	if err != nil {
+		return errorResult(ErrorCodeInvalidArgument, err), nil
+	}
+
+	detail, err := db.GetSpendingForCategory(ctx, categoryID, categoryName, months, includeSubcategories)
+	if err != nil {
+		return errorResult(errorCodeFor(err), err), nil
+	}
+
+	jsonData, err := textContentJSON(request, detail)
+	if err != nil {
+		return marshalErrorResult("category spending", err), nil
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: string(jsonData),
+			},
+		},
+		StructuredContent: detail,
+	}, nil
+}
+
+func (s *Server) handleGetCategoryDistribution(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	categoryID := int64(request.GetInt("category_id", 0))
+	categoryName := request.GetString("category", "")
+	months := request.GetInt("months", 12)
+	includeSubcategories := request.GetBool("include_subcategories", false)
+
+	db, err := s.databaseFor(request)
+	if err != nil {
+		return errorResult(ErrorCodeInvalidArgument, err), nil
+	}
+
+	distribution, err := db.GetCategoryDistribution(ctx, categoryID, categoryName, months, includeSubcategories)
+	if err != nil {
+		return errorResult(errorCodeFor(err), err), nil
+	}
+
+	jsonData, err := textContentJSON(request, distribution)
+	if err != nil {
+		return marshalErrorResult("category distribution", err), nil
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: string(jsonData),
+			},
+		},
+		StructuredContent: distribution,
+	}, nil
+}
+
+func (s *Server) handleGetCategoryTree(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	months := request.GetInt("months", 12)
+
+	db, err := s.databaseFor(request)
+	if err != nil {
+		return errorResult(ErrorCodeInvalidArgument, err), nil
+	}
+
+	tree, err := db.GetCategoryTree(ctx, months)
+	if err != nil {
+		return errorResult(errorCodeFor(err), err), nil
+	}
+
+	jsonData, err := textContentJSON(request, tree)
+	if err != nil {
+		return marshalErrorResult("category tree", err), nil
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: string(jsonData),
+			},
+		},
+		StructuredContent: tree,
+	}, nil
+}
@@ -50,6 +50,18 @@ func currencyMetaFromSpendingTrends(trends []database.SpendingTrend) ([]string,
 	return currencyMetaFromSet(set)
 }
 
+func currencyMetaFromNetSavingsTrend(periods []database.NetSavingsPeriod) ([]string, bool, string) {
+	set := make(map[string]struct{})
+	for _, period := range periods {
+		for currency := range period.ByCurrency {
+			if currency != "" {
+				set[currency] = struct{}{}
+			}
+		}
+	}
+	return currencyMetaFromSet(set)
+}
+
 func currencyMetaFromSet(set map[string]struct{}) ([]string, bool, string) {
 	currencies := make([]string, 0, len(set))
 	for currency := range set {
@@ -0,0 +1,120 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/moneywiz-mcp/internal/database"
+)
+
+// DefaultDatabaseName is used for a database given without a name
+const DefaultDatabaseName = "default"
+
+// NamedDB is a MoneyWiz database registered under a name (e.g. "personal", "business")
+type NamedDB struct {
+	Name string
+	DB   *database.DB
+}
+
+// DatabaseInfo describes a registered database in list_databases
+type DatabaseInfo struct {
+	Name    string `json:"name"`
+	Path    string `json:"path"`
+	Default bool   `json:"default"`
+}
+
+// withDatabaseOption adds the database selector every tool accepts
+func withDatabaseOption(properties map[string]any) map[string]any {
+	properties["database"] = map[string]any{
+		"type":        "string",
+		"description": "Optional name of the database to query (see list_databases). Defaults to the first registered database",
+	}
+	return properties
+}
+
+// databaseFor returns the database named by the request's database argument, or the
+// default database when none is given
+func (s *Server) databaseFor(request mcp.CallToolRequest) (*database.DB, error) {
+	if len(s.databaseNames) == 0 {
+		return nil, fmt.Errorf("no database configured")
+	}
+	name := strings.TrimSpace(request.GetString("database", ""))
+	if name == "" {
+		name = s.databaseNames[0]
+	}
+	db, ok := s.databases[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown database %q (available: %s)", name, strings.Join(s.databaseNames, ", "))
+	}
+	return db, nil
+}
+
+func (s *Server) handleListDatabases(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	databases := make([]DatabaseInfo, 0, len(s.databaseNames))
+	for i, name := range s.databaseNames {
+		databases = append(databases, DatabaseInfo{
+			Name:    name,
+			Path:    s.databases[name].Path(),
+			Default: i == 0,
+		})
+	}
+	response := map[string]interface{}{
+		"databases": databases,
+	}
+
+	jsonData, err := textContentJSON(request, response)
+	if err != nil {
+		return marshalErrorResult("databases", err), nil
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: string(jsonData),
+			},
+		},
+		StructuredContent: response,
+	}, nil
+}
+
+// DatabaseDetails describes one database in database_info
+type DatabaseDetails struct {
+	Name    string `json:"name"`
+	Default bool   `json:"default"`
+	*database.Info
+}
+
+func (s *Server) handleDatabaseInfo(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	db, err := s.databaseFor(request)
+	if err != nil {
+		return errorResult(ErrorCodeInvalidArgument, err), nil
+	}
+
+	info, err := db.GetInfo(ctx)
+	if err != nil {
+		return errorResult(errorCodeFor(err), err), nil
+	}
+	name := strings.TrimSpace(request.GetString("database", ""))
+	if name == "" {
+		name = s.databaseNames[0]
+	}
+	details := DatabaseDetails{Name: name, Default: name == s.databaseNames[0], Info: info}
+
+	jsonData, err := textContentJSON(request, details)
+	if err != nil {
+		return marshalErrorResult("database info", err), nil
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: string(jsonData),
+			},
+		},
+		StructuredContent: details,
+	}, nil
+}
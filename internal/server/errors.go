@@ -0,0 +1,86 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/moneywiz-mcp/internal/database"
+)
+
+// Error codes returned in the structured content of failed tool calls, so clients can react
+// to a failure without matching on its message
+const (
+	ErrorCodeInvalidArgument = "INVALID_ARGUMENT"
+	ErrorCodeNotFound        = "NOT_FOUND"
+	ErrorCodeDBError         = "DB_ERROR"
+	ErrorCodeCanceled        = "CANCELED"
+	ErrorCodeTimeout         = "TIMEOUT"
+	ErrorCodeInternal        = "INTERNAL"
+)
+
+// errUnsupportedLocale is returned by textContentJSON for a locale it cannot format
+var errUnsupportedLocale = errors.New("unsupported locale")
+
+// ToolError is the structured content of a failed tool call
+type ToolError struct {
+	Error ToolErrorDetail `json:"error"`
+}
+
+// ToolErrorDetail carries the machine-readable code and the message of a failed tool call
+type ToolErrorDetail struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// errorCodeFor classifies an error returned by the database layer
+func errorCodeFor(err error) string {
+	switch {
+	case errors.Is(err, database.ErrNotFound):
+		return ErrorCodeNotFound
+	case errors.Is(err, database.ErrInvalidArgument):
+		return ErrorCodeInvalidArgument
+	case errors.Is(err, context.DeadlineExceeded):
+		return ErrorCodeTimeout
+	case errors.Is(err, context.Canceled):
+		return ErrorCodeCanceled
+	default:
+		return ErrorCodeDBError
+	}
+}
+
+// errorResult builds a failed tool result: the message as text, prefixed with "Error: " as
+// before, and the code and message as structured content
+func errorResult(code string, err error) *mcp.CallToolResult {
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: fmt.Sprintf("Error: %v", err),
+			},
+		},
+		StructuredContent: ToolError{Error: ToolErrorDetail{Code: code, Message: err.Error()}},
+		IsError:           true,
+	}
+}
+
+// marshalErrorResult builds a failed tool result for a response that could not be serialized
+// An unsupported locale is the caller's mistake; anything else is an internal error
+func marshalErrorResult(what string, err error) *mcp.CallToolResult {
+	code := ErrorCodeInternal
+	if errors.Is(err, errUnsupportedLocale) {
+		code = ErrorCodeInvalidArgument
+	}
+	message := fmt.Sprintf("marshaling %s: %v", what, err)
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: "Error " + message,
+			},
+		},
+		StructuredContent: ToolError{Error: ToolErrorDetail{Code: code, Message: message}},
+		IsError:           true,
+	}
+}
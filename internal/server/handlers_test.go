@@ -3,7 +3,9 @@ package server
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -108,6 +110,102 @@ func TestHandleListTransactionsReturnsStructuredTransactions(t *testing.T) {
 	assertSingleTextContains(t, result, "Groceries")
 }
 
+func TestHandleListTransactionsPaginatesWithTotalCount(t *testing.T) {
+	srv := newTestServer(t)
+
+	result, err := srv.handleListTransactions(context.Background(), newCallToolRequest("list_transactions", map[string]any{
+		"limit":  2,
+		"offset": 2,
+	}))
+	if err != nil {
+		t.Fatalf("handleListTransactions returned protocol error: %v", err)
+	}
+	if result.IsError {
+		t.Fatal("expected successful result")
+	}
+
+	structured := result.StructuredContent.(map[string]interface{})
+	transactions := structured["transactions"].([]database.Transaction)
+	if len(transactions) != 2 || transactions[0].ID != 1001 || transactions[1].ID != 1000 {
+		t.Fatalf("second page = %#v, want [1001 1000]", transactions)
+	}
+	if structured["total_count"] != 4 {
+		t.Fatalf("total_count = %v, want 4", structured["total_count"])
+	}
+
+	result, err = srv.handleListTransactions(context.Background(), newCallToolRequest("list_transactions", map[string]any{
+		"offset": 10,
+	}))
+	if err != nil {
+		t.Fatalf("handleListTransactions past the end returned protocol error: %v", err)
+	}
+	if result.IsError {
+		t.Fatal("expected offset past the end to succeed")
+	}
+	structured = result.StructuredContent.(map[string]interface{})
+	if transactions := structured["transactions"].([]database.Transaction); len(transactions) != 0 {
+		t.Fatalf("transactions past the end = %#v, want empty", transactions)
+	}
+	if structured["total_count"] != 4 {
+		t.Fatalf("total_count past the end = %v, want 4", structured["total_count"])
+	}
+}
+
+func TestHandleListTransactionsStreamsJSONLines(t *testing.T) {
+	srv := newTestServer(t)
+
+	result, err := srv.handleListTransactions(context.Background(), newCallToolRequest("list_transactions", map[string]any{
+		"limit":  3,
+		"format": "jsonl",
+	}))
+	if err != nil {
+		t.Fatalf("handleListTransactions returned protocol error: %v", err)
+	}
+	if result.IsError {
+		t.Fatal("expected successful result")
+	}
+	if result.StructuredContent != nil {
+		t.Fatalf("structured content = %#v, want none for jsonl", result.StructuredContent)
+	}
+
+	text := result.Content[0].(mcp.TextContent).Text
+	lines := strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("jsonl lines = %d, want 3:\n%s", len(lines), text)
+	}
+	var first database.Transaction
+	if err := json.Unmarshal([]byte(lines[0]), &first); err != nil {
+		t.Fatalf("first line is not a transaction: %v", err)
+	}
+	if first.ID != 1003 || first.CategoryName != "Groceries" {
+		t.Fatalf("first transaction = %#v, want 1003 Groceries", first)
+	}
+
+	result, err = srv.handleExportTransactionsCSV(context.Background(), newCallToolRequest("export_transactions_csv", map[string]any{
+		"start_date": "2024-02",
+		"format":     "jsonl",
+	}))
+	if err != nil {
+		t.Fatalf("handleExportTransactionsCSV returned protocol error: %v", err)
+	}
+	if result.IsError {
+		t.Fatal("expected successful export")
+	}
+	if text := result.Content[0].(mcp.TextContent).Text; strings.Count(text, "\n") != 2 || strings.Contains(text, "id,date") {
+		t.Fatalf("jsonl export = %q, want two JSON lines", text)
+	}
+
+	result, err = srv.handleListTransactions(context.Background(), newCallToolRequest("list_transactions", map[string]any{
+		"format": "xml",
+	}))
+	if err != nil {
+		t.Fatalf("handleListTransactions with bad format returned protocol error: %v", err)
+	}
+	if !result.IsError {
+		t.Fatal("expected an unknown format to be rejected")
+	}
+}
+
 func TestHandleAnalyzeSpendingTrendsInvalidGroupByFallsBackToMonth(t *testing.T) {
 	srv := newTestServer(t)
 
@@ -152,6 +250,214 @@ func TestHandleAnalyzeSpendingTrendsInvalidGroupByFallsBackToMonth(t *testing.T)
 	}
 }
 
+func TestDefaultCurrencyAppliesWhenTargetCurrencyIsOmitted(t *testing.T) {
+	srv := newTestServer(t)
+	srv.SetDefaultCurrency(" eur ")
+
+	result, err := srv.handleAnalyzeSpendingTrends(context.Background(), newCallToolRequest("analyze_spending_trends", map[string]any{
+		"months":         0,
+		"exchange_rates": map[string]any{"USD": 0.5},
+	}))
+	if err != nil {
+		t.Fatalf("handleAnalyzeSpendingTrends returned protocol error: %v", err)
+	}
+	if result.IsError {
+		t.Fatal("expected successful result")
+	}
+	structured := result.StructuredContent.(map[string]interface{})
+	if structured["target_currency"] != "EUR" {
+		t.Fatalf("target_currency = %v, want the default EUR", structured["target_currency"])
+	}
+	trends := structured["trends"].([]database.SpendingTrend)
+	if len(trends) != 2 || trends[0].TotalSpending != 600 {
+		t.Fatalf("expected spending converted at 0.5, got %+v", trends)
+	}
+
+	// An explicit target_currency still wins
+	result, err = srv.handleAnalyzeSpendingTrends(context.Background(), newCallToolRequest("analyze_spending_trends", map[string]any{
+		"months":          0,
+		"target_currency": "USD",
+	}))
+	if err != nil || result.IsError {
+		t.Fatalf("handleAnalyzeSpendingTrends with target_currency failed: %v", err)
+	}
+	if target := result.StructuredContent.(map[string]interface{})["target_currency"]; target != "USD" {
+		t.Fatalf("target_currency = %v, want USD", target)
+	}
+
+	// Without exchange_rates the default would only relabel amounts, so nothing is converted
+	result, err = srv.handleAnalyzeSpendingTrends(context.Background(), newCallToolRequest("analyze_spending_trends", map[string]any{
+		"months": 0,
+	}))
+	if err != nil || result.IsError {
+		t.Fatalf("handleAnalyzeSpendingTrends without exchange_rates failed: %v", err)
+	}
+	if target, ok := result.StructuredContent.(map[string]interface{})["target_currency"]; ok {
+		t.Fatalf("target_currency = %v, want no conversion without exchange_rates", target)
+	}
+}
+
+func TestConvertedSpendingTrendsStayMixedWithoutEveryRate(t *testing.T) {
+	srv := newTestServer(t)
+
+	result, err := srv.handleAnalyzeSpendingTrends(context.Background(), newCallToolRequest("analyze_spending_trends", map[string]any{
+		"months":          0,
+		"target_currency": "EUR",
+	}))
+	if err != nil || result.IsError {
+		t.Fatalf("handleAnalyzeSpendingTrends failed: %v", err)
+	}
+	structured := result.StructuredContent.(map[string]interface{})
+	if structured["mixed_currencies"] != true || structured["currency_warning"] == "" {
+		t.Fatalf("expected mixed currencies with a warning when USD has no rate, got %v / %q", structured["mixed_currencies"], structured["currency_warning"])
+	}
+	if warnings, _ := structured["warnings"].([]string); len(warnings) != 1 || !strings.Contains(warnings[0], "USD") {
+		t.Fatalf("warnings = %v, want one for USD", structured["warnings"])
+	}
+
+	result, err = srv.handleAnalyzeSpendingTrends(context.Background(), newCallToolRequest("analyze_spending_trends", map[string]any{
+		"months":          0,
+		"target_currency": "EUR",
+		"exchange_rates":  map[string]any{"USD": 0.9},
+	}))
+	if err != nil || result.IsError {
+		t.Fatalf("handleAnalyzeSpendingTrends with exchange_rates failed: %v", err)
+	}
+	structured = result.StructuredContent.(map[string]interface{})
+	if structured["mixed_currencies"] != false || structured["currency_warning"] != "" {
+		t.Fatalf("expected unmixed totals once every currency has a rate, got %v / %q", structured["mixed_currencies"], structured["currency_warning"])
+	}
+}
+
+func TestCategoryAliasesMustBeAnObjectOfStrings(t *testing.T) {
+	srv := newTestServer(t)
+
+	for _, aliases := range []any{"Food2=Food", map[string]any{"Food2": 3}} {
+		result, err := srv.handleAnalyzeSpendingTrends(context.Background(), newCallToolRequest("analyze_spending_trends", map[string]any{
+			"category_aliases": aliases,
+		}))
+		if err != nil {
+			t.Fatalf("handleAnalyzeSpendingTrends returned protocol error: %v", err)
+		}
+		if !result.IsError {
+			t.Fatalf("category_aliases %v: expected an error result", aliases)
+		}
+	}
+}
+
+func TestDatabaseArgumentSelectsNamedDatabase(t *testing.T) {
+	personal := newServerFixtureDB(t)
+	business := newServerFixtureDB(t)
+	t.Cleanup(func() {
+		personal.Close()
+		business.Close()
+	})
+
+	srv, err := NewServer(NamedDB{Name: "personal", DB: personal}, NamedDB{Name: "business", DB: business})
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	if _, err := NewServer(NamedDB{Name: "personal", DB: personal}, NamedDB{Name: "personal", DB: business}); err == nil {
+		t.Fatal("expected duplicate database names to be rejected")
+	}
+
+	db, err := srv.databaseFor(newCallToolRequest("list_accounts", map[string]any{}))
+	if err != nil || db != personal {
+		t.Fatalf("default database = %p, %v; want the first registered", db, err)
+	}
+	db, err = srv.databaseFor(newCallToolRequest("list_accounts", map[string]any{"database": "business"}))
+	if err != nil || db != business {
+		t.Fatalf("named database = %p, %v; want business", db, err)
+	}
+
+	result, err := srv.handleListAccounts(context.Background(), newCallToolRequest("list_accounts", map[string]any{"database": "savings"}))
+	if err != nil {
+		t.Fatalf("handleListAccounts returned protocol error: %v", err)
+	}
+	if !result.IsError {
+		t.Fatal("expected tool error for an unknown database")
+	}
+	assertSingleTextContains(t, result, "personal, business")
+
+	result, err = srv.handleListDatabases(context.Background(), newCallToolRequest("list_databases", map[string]any{}))
+	if err != nil || result.IsError {
+		t.Fatalf("handleListDatabases = %+v, %v", result, err)
+	}
+	databases := result.StructuredContent.(map[string]interface{})["databases"].([]DatabaseInfo)
+	if len(databases) != 2 || databases[0].Name != "personal" || !databases[0].Default || databases[1].Default || databases[1].Path != business.Path() {
+		t.Fatalf("databases = %+v", databases)
+	}
+
+	result, err = srv.handleDatabaseInfo(context.Background(), newCallToolRequest("database_info", map[string]any{"database": "business"}))
+	if err != nil || result.IsError {
+		t.Fatalf("handleDatabaseInfo = %+v, %v", result, err)
+	}
+	details := result.StructuredContent.(DatabaseDetails)
+	if details.Name != "business" || details.Default || details.Path != business.Path() || details.BaseCurrency != "USD" {
+		t.Fatalf("database info = %+v", details)
+	}
+}
+
+func TestToolErrorsCarryStructuredCodes(t *testing.T) {
+	srv := newTestServer(t)
+	ctx := context.Background()
+
+	tests := []struct {
+		name     string
+		call     func() (*mcp.CallToolResult, error)
+		wantCode string
+	}{
+		{
+			name: "missing account",
+			call: func() (*mcp.CallToolResult, error) {
+				return srv.handleGetAccountBalance(ctx, newCallToolRequest("get_account_balance", map[string]any{"account_id": 999}))
+			},
+			wantCode: ErrorCodeNotFound,
+		},
+		{
+			name: "invalid date",
+			call: func() (*mcp.CallToolResult, error) {
+				return srv.handleListTransactions(ctx, newCallToolRequest("list_transactions", map[string]any{"start_date": "01/20/2024"}))
+			},
+			wantCode: ErrorCodeInvalidArgument,
+		},
+		{
+			name: "missing required argument",
+			call: func() (*mcp.CallToolResult, error) {
+				return srv.handleGetAccountBalance(ctx, newCallToolRequest("get_account_balance", map[string]any{}))
+			},
+			wantCode: ErrorCodeInvalidArgument,
+		},
+		{
+			name: "unsupported locale",
+			call: func() (*mcp.CallToolResult, error) {
+				return srv.handleListAccounts(ctx, newCallToolRequest("list_accounts", map[string]any{"locale": "xx-XX"}))
+			},
+			wantCode: ErrorCodeInvalidArgument,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			result, err := tc.call()
+			if err != nil {
+				t.Fatalf("handler returned protocol error: %v", err)
+			}
+			if !result.IsError {
+				t.Fatal("expected tool error result")
+			}
+			toolErr, ok := result.StructuredContent.(ToolError)
+			if !ok {
+				t.Fatalf("structured content type = %T, want ToolError", result.StructuredContent)
+			}
+			if toolErr.Error.Code != tc.wantCode {
+				t.Fatalf("error code = %q, want %q (message %q)", toolErr.Error.Code, tc.wantCode, toolErr.Error.Message)
+			}
+			assertSingleTextContains(t, result, toolErr.Error.Message)
+		})
+	}
+}
+
 func newTestServer(t *testing.T) *Server {
 	t.Helper()
 
@@ -162,7 +468,11 @@ func newTestServer(t *testing.T) *Server {
 		}
 	})
 
-	return NewServer(db)
+	srv, err := NewServer(NamedDB{Name: DefaultDatabaseName, DB: db})
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	return srv
 }
 
 func newCallToolRequest(name string, arguments map[string]any) mcp.CallToolRequest {
@@ -252,7 +562,7 @@ func newServerFixtureDB(t *testing.T) *database.DB {
 	insertServerFixtureTransaction(t, conn, 1002, 37, 2500, "2024-02-05", "February salary", 1, 0, 100)
 	insertServerFixtureTransaction(t, conn, 1003, 37, -300, "2024-02-10", "Groceries", 1, 0, 102)
 
-	db, err := database.NewDB(path)
+	db, err := database.NewDB(path, database.Options{})
 	if err != nil {
 		t.Fatalf("NewDB: %v", err)
 	}
@@ -0,0 +1,322 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// numberFormat describes how monetary values are written in text content
+type numberFormat struct {
+	thousands   string
+	decimal     string
+	symbolAfter bool // "1.234,56 €" rather than "€1,234.56"
+}
+
+// localeNumberFormats maps a locale (or its language) to its separators
+// Region-specific entries take precedence over the language entry
+var localeNumberFormats = map[string]numberFormat{
+	"en":    {thousands: ",", decimal: "."},
+	"ja":    {thousands: ",", decimal: "."},
+	"zh":    {thousands: ",", decimal: "."},
+	"ko":    {thousands: ",", decimal: "."},
+	"de":    {thousands: ".", decimal: ",", symbolAfter: true},
+	"es":    {thousands: ".", decimal: ",", symbolAfter: true},
+	"it":    {thousands: ".", decimal: ",", symbolAfter: true},
+	"nl":    {thousands: ".", decimal: ",", symbolAfter: true},
+	"pt":    {thousands: ".", decimal: ",", symbolAfter: true},
+	"tr":    {thousands: ".", decimal: ",", symbolAfter: true},
+	"da":    {thousands: ".", decimal: ",", symbolAfter: true},
+	"fr":    {thousands: " ", decimal: ",", symbolAfter: true},
+	"uk":    {thousands: " ", decimal: ",", symbolAfter: true},
+	"ru":    {thousands: " ", decimal: ",", symbolAfter: true},
+	"pl":    {thousands: " ", decimal: ",", symbolAfter: true},
+	"cs":    {thousands: " ", decimal: ",", symbolAfter: true},
+	"sv":    {thousands: " ", decimal: ",", symbolAfter: true},
+	"nb":    {thousands: " ", decimal: ",", symbolAfter: true},
+	"fi":    {thousands: " ", decimal: ",", symbolAfter: true},
+	"de-ch": {thousands: "'", decimal: "."},
+	"fr-ch": {thousands: "'", decimal: "."},
+	"it-ch": {thousands: "'", decimal: "."},
+}
+
+var currencySymbols = map[string]string{
+	"USD": "$",
+	"EUR": "€",
+	"GBP": "£",
+	"JPY": "¥",
+	"CNY": "¥",
+	"INR": "₹",
+	"KRW": "₩",
+	"UAH": "₴",
+	"PLN": "zł",
+	"RUB": "₽",
+	"TRY": "₺",
+	"ILS": "₪",
+	"BRL": "R$",
+	"CAD": "CA$",
+	"AUD": "A$",
+}
+
+// Response structs mark the fields text output formats as money with a money tag:
+// `money:"amount"` for an amount, or a map of amounts keyed by something other than currency
+// (e.g. by_category), and `money:"by_currency"` for a map keyed by currency code, whose values
+// are amounts or objects in that currency. An amount in another currency than its object's names
+// the sibling key holding it with a currency tag, e.g. `currency:"original_currency"`
+const (
+	moneyAmount     = "amount"
+	moneyByCurrency = "by_currency"
+)
+
+// withNumberFormatOptions adds the text formatting options every tool accepts
+func withNumberFormatOptions(properties map[string]any) map[string]any {
+	properties["locale"] = map[string]any{
+		"type":        "string",
+		"description": "Optional locale (e.g. 'en-US', 'de-DE', 'fr-FR', 'de-CH') used to format monetary values in the text output. Structured content is unaffected",
+	}
+	properties["currency_symbol"] = map[string]any{
+		"type":        "boolean",
+		"description": "Write monetary values in the text output with their currency symbol and two decimals (e.g. '$1,234.50'), in the locale's format when one is given (default: false)",
+		"default":     false,
+	}
+	return properties
+}
+
+// defaultCurrencyLocale formats amounts when currency_symbol is requested without a locale
+const defaultCurrencyLocale = "en"
+
+// textContentJSON serializes a tool result for TextContent
+// When the request sets a locale or asks for currency symbols, monetary values are rendered as
+// formatted strings; StructuredContent keeps the raw numbers
+func textContentJSON(request mcp.CallToolRequest, v any) ([]byte, error) {
+	locale := request.GetString("locale", "")
+	withSymbol := request.GetBool("currency_symbol", false)
+	if strings.TrimSpace(locale) == "" {
+		if !withSymbol {
+			return json.MarshalIndent(v, "", "  ")
+		}
+		locale = defaultCurrencyLocale
+	}
+
+	format, err := lookupNumberFormat(locale)
+	if err != nil {
+		return nil, err
+	}
+
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	var tree any
+	if err := decoder.Decode(&tree); err != nil {
+		return nil, err
+	}
+
+	return json.MarshalIndent(format.apply(tree, reflect.ValueOf(v), "", "", withSymbol), "", "  ")
+}
+
+// lookupNumberFormat resolves a locale such as "de-DE" or "de_CH" to its number format
+func lookupNumberFormat(locale string) (numberFormat, error) {
+	normalized := strings.ToLower(strings.ReplaceAll(strings.TrimSpace(locale), "_", "-"))
+	if format, ok := localeNumberFormats[normalized]; ok {
+		return format, nil
+	}
+	language, _, _ := strings.Cut(normalized, "-")
+	if format, ok := localeNumberFormats[language]; ok {
+		return format, nil
+	}
+	return numberFormat{}, fmt.Errorf("%w %q", errUnsupportedLocale, locale)
+}
+
+// apply replaces monetary numbers in a decoded JSON tree with formatted strings, following the
+// Go value it was encoded from to read the money tags (see moneyAmount); money is the tag of the
+// field holding node, "" when it is not money
+// currency is inherited from the nearest enclosing object that names one (see objectCurrency)
+func (f numberFormat) apply(node any, value reflect.Value, currency, money string, withSymbol bool) any {
+	value = indirect(value)
+	switch typed := node.(type) {
+	case json.Number:
+		if money == "" {
+			return typed
+		}
+		return f.formatNumber(typed, currency, withSymbol)
+	case []any:
+		for i, child := range typed {
+			var elem reflect.Value
+			if (value.Kind() == reflect.Slice || value.Kind() == reflect.Array) && i < value.Len() {
+				elem = value.Index(i)
+			}
+			typed[i] = f.apply(child, elem, currency, money, withSymbol)
+		}
+		return typed
+	case map[string]any:
+		if value.Kind() == reflect.Struct {
+			return f.applyStruct(typed, value, currency, withSymbol)
+		}
+		if money == "" {
+			if c := objectCurrency(typed); c != "" {
+				currency = c
+			}
+		}
+		for key, child := range typed {
+			var elem reflect.Value
+			if value.Kind() == reflect.Map && value.Type().Key().Kind() == reflect.String {
+				elem = value.MapIndex(reflect.ValueOf(key).Convert(value.Type().Key()))
+			}
+			childCurrency, childMoney := currency, money
+			if money == moneyByCurrency {
+				childCurrency, childMoney = key, moneyAmount
+			}
+			typed[key] = f.apply(child, elem, childCurrency, childMoney, withSymbol)
+		}
+		return typed
+	default:
+		return node
+	}
+}
+
+// applyStruct formats the fields of a decoded struct whose money tag says they hold money
+func (f numberFormat) applyStruct(node map[string]any, value reflect.Value, currency string, withSymbol bool) any {
+	if c := objectCurrency(node); c != "" {
+		currency = c
+	}
+	fields := jsonFields(value.Type())
+	for key, child := range node {
+		field, ok := fields[key]
+		if !ok {
+			continue
+		}
+		fieldValue, err := value.FieldByIndexErr(field.Index)
+		if err != nil {
+			continue
+		}
+		fieldCurrency := currency
+		if sibling := field.Tag.Get("currency"); sibling != "" {
+			c, _ := node[sibling].(string)
+			fieldCurrency = c
+		}
+		node[key] = f.apply(child, fieldValue, fieldCurrency, field.Tag.Get("money"), withSymbol)
+	}
+	return node
+}
+
+// jsonFields maps the JSON keys of a struct type to their fields, including the fields promoted
+// from embedded structs
+func jsonFields(t reflect.Type) map[string]reflect.StructField {
+	fields := make(map[string]reflect.StructField)
+	for _, field := range reflect.VisibleFields(t) {
+		if !field.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			if field.Anonymous && indirectType(field.Type).Kind() == reflect.Struct {
+				continue // Its fields are promoted
+			}
+			name = field.Name
+		}
+		fields[name] = field
+	}
+	return fields
+}
+
+// indirectType returns the type a pointer type points to, or t itself
+func indirectType(t reflect.Type) reflect.Type {
+	if t.Kind() == reflect.Pointer {
+		return t.Elem()
+	}
+	return t
+}
+
+// indirect follows interfaces and pointers to the value they hold
+func indirect(value reflect.Value) reflect.Value {
+	for value.IsValid() && (value.Kind() == reflect.Interface || value.Kind() == reflect.Pointer) {
+		if value.IsNil() {
+			return reflect.Value{}
+		}
+		value = value.Elem()
+	}
+	return value
+}
+
+// objectCurrency returns the currency of a decoded JSON object's amounts, or "" when it names
+// none: an account or transaction "currency", or for an analysis whose totals are in a single
+// currency its "target_currency", "primary_currency", or only entry in "currencies"
+// Totals flagged with mixed_currencies get no symbol, since no single currency is right for them
+func objectCurrency(node map[string]any) string {
+	if c, ok := node["currency"].(string); ok && c != "" {
+		return c
+	}
+	if mixed, _ := node["mixed_currencies"].(bool); mixed {
+		return ""
+	}
+	for _, key := range []string{"target_currency", "primary_currency"} {
+		if c, ok := node[key].(string); ok && c != "" {
+			return c
+		}
+	}
+	if currencies, ok := node["currencies"].([]any); ok && len(currencies) == 1 {
+		if c, ok := currencies[0].(string); ok {
+			return c
+		}
+	}
+	return ""
+}
+
+func (f numberFormat) formatNumber(number json.Number, currency string, withSymbol bool) any {
+	value, err := number.Float64()
+	if err != nil {
+		return number
+	}
+	formatted := f.formatAmount(value)
+	if !withSymbol || currency == "" {
+		return formatted
+	}
+
+	symbol, ok := currencySymbols[currency]
+	if !ok {
+		symbol = currency
+	}
+	if f.symbolAfter {
+		return formatted + " " + symbol
+	}
+	sign := ""
+	if strings.HasPrefix(formatted, "-") {
+		sign, formatted = "-", formatted[1:]
+	}
+	if symbol == currency {
+		return sign + symbol + " " + formatted
+	}
+	return sign + symbol + formatted
+}
+
+// formatAmount writes value with two decimals and the format's separators
+func (f numberFormat) formatAmount(value float64) string {
+	sign := ""
+	if value < 0 && math.Round(value*100) != 0 {
+		sign = "-"
+	}
+	fixed := strconv.FormatFloat(math.Abs(value), 'f', 2, 64)
+	integer, fraction, _ := strings.Cut(fixed, ".")
+
+	var grouped strings.Builder
+	for i, digit := range integer {
+		if i > 0 && (len(integer)-i)%3 == 0 {
+			grouped.WriteString(f.thousands)
+		}
+		grouped.WriteRune(digit)
+	}
+
+	return sign + grouped.String() + f.decimal + fraction
+}
@@ -0,0 +1,272 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/moneywiz-mcp/internal/database"
+)
+
+func TestNumberFormatFormatAmount(t *testing.T) {
+	tests := []struct {
+		locale string
+		value  float64
+		want   string
+	}{
+		{locale: "en-US", value: 1234.56, want: "1,234.56"},
+		{locale: "de-DE", value: 1234.56, want: "1.234,56"},
+		{locale: "fr_FR", value: -1234567.891, want: "-1 234 567,89"},
+		{locale: "de-CH", value: 1234.5, want: "1'234.50"},
+		{locale: "en", value: 999, want: "999.00"},
+		{locale: "en", value: -0.001, want: "0.00"},
+	}
+
+	for _, tc := range tests {
+		format, err := lookupNumberFormat(tc.locale)
+		if err != nil {
+			t.Fatalf("lookupNumberFormat(%q): %v", tc.locale, err)
+		}
+		if got := format.formatAmount(tc.value); got != tc.want {
+			t.Fatalf("formatAmount(%v) in %s = %q, want %q", tc.value, tc.locale, got, tc.want)
+		}
+	}
+
+	if _, err := lookupNumberFormat("xx-YY"); err == nil {
+		t.Fatal("lookupNumberFormat for unknown locale unexpectedly succeeded")
+	}
+}
+
+// sampleAmount fills every number of the sample responses; formatted for de-DE it reads
+// sampleAmountText
+const (
+	sampleAmount     = 1234.5
+	sampleAmountText = "1.234,50"
+)
+
+// nonMoneyFields are the JSON keys of the numbers in responses that are not money, such as
+// percentages, ratios and counts of days; any other number must carry a money tag
+var nonMoneyFields = map[string]bool{
+	"amount_difference_percent": true, "amount_share_percent": true, "amount_tolerance_pct": true,
+	"annual_inflation_pct": true, "average_days": true, "average_ratio": true, "benchmarks": true,
+	"change_percent": true, "count_share_percent": true, "deviation_percent": true,
+	"difference_percent": true, "effective_number_of_sources": true, "emergency_fund_months": true,
+	"exchange_rates": true, "gain_loss_percent": true, "herfindahl_index": true, "hours_apart": true,
+	"income_change_percent": true, "latest_ratio": true, "months_until_target": true,
+	"multiplier": true, "percentage": true, "portfolio_percent": true, "progress_percent": true,
+	"ratio": true, "raw_savings_rate": true, "savings_rate": true, "share_percent": true,
+	"shares": true, "spending_change_percent": true, "std_dev_threshold": true, "std_devs": true,
+	"threshold_percent": true, "top_share_percent": true, "trailing_threshold_pct": true,
+	"uncategorized_spending_pct_by_currency": true, "weekend_share_percent": true,
+}
+
+func TestTextContentJSONFormatsMoneyFieldsOfEveryResponseType(t *testing.T) {
+	responses := []any{
+		&database.Account{}, &database.AccountBalanceAsOf{}, &database.AccountBalanceHistory{},
+		&database.AccountDepletionProjection{}, &database.AccountGroupsReport{}, &database.BurnRate{},
+		&database.CategoriesWithTotals{}, &database.Category{}, &database.CategoryAssignmentDiagnostics{},
+		&database.CategoryDistribution{}, &database.CategoryHierarchy{}, &database.CategorySpendingDetail{},
+		&database.CategoryTrendDirections{}, &database.DormantAccountsReport{}, &database.DoubleChargeReport{},
+		&database.DuplicateTransactionsReport{}, &database.ExpenseRatioTrend{}, &database.FinancialStats{},
+		&database.Forecast{}, &database.IncomeData{}, &database.IncomeSourceAnalysis{},
+		&database.IncomeTrend{}, &database.InflationProjection{}, &database.Info{},
+		&database.InvestmentHoldings{}, &database.LargestTransactions{}, &database.NetSavingsPeriod{},
+		&database.NetWorth{}, &database.NetWorthAttribution{}, &database.NetWorthByInstitution{},
+		&database.NetWorthSeries{}, &database.PaycheckPeriod{}, &database.PayeeSpendingAnalysis{},
+		&database.PeriodComparison{}, &database.RecurringTransactionsReport{}, &database.SavingsAnalysis{},
+		&database.SavingsGoalsReport{}, &database.ScheduledTransactionsReport{}, &database.SpendingAnomalyReport{},
+		&database.SpendingData{}, &database.SpendingTrend{}, &database.SpendingVelocity{},
+		&database.TagSpendingAnalysis{}, &database.TopMerchants{}, &database.Transaction{},
+		&database.TransactionHistogram{}, &database.UncategorizedReport{}, &database.UpcomingBillsReport{},
+		&database.WeekdaySpendingAnalysis{},
+	}
+	request := newCallToolRequest("any_tool", map[string]any{"locale": "de-DE"})
+
+	for _, response := range responses {
+		value := reflect.ValueOf(response).Elem()
+		t.Run(value.Type().Name(), func(t *testing.T) {
+			fillSample(value, 3)
+			text, err := textContentJSON(request, response)
+			if err != nil {
+				t.Fatalf("textContentJSON: %v", err)
+			}
+			decoder := json.NewDecoder(bytes.NewReader(text))
+			decoder.UseNumber()
+			var tree any
+			if err := decoder.Decode(&tree); err != nil {
+				t.Fatalf("decode text content: %v", err)
+			}
+			assertMoneyFormatted(t, value.Type().Name(), "", tree, value, "")
+		})
+	}
+}
+
+// fillSample sets every number in value to sampleAmount, and gives every pointer, slice and map
+// one element, down to depth nested structs
+func fillSample(value reflect.Value, depth int) {
+	switch value.Kind() {
+	case reflect.Float32, reflect.Float64:
+		value.SetFloat(sampleAmount)
+	case reflect.Int, reflect.Int64:
+		value.SetInt(7)
+	case reflect.Pointer:
+		if depth > 0 {
+			value.Set(reflect.New(value.Type().Elem()))
+			fillSample(value.Elem(), depth)
+		}
+	case reflect.Slice:
+		if depth > 0 {
+			value.Set(reflect.MakeSlice(value.Type(), 1, 1))
+			fillSample(value.Index(0), depth-1)
+		}
+	case reflect.Map:
+		if depth > 0 && value.Type().Key().Kind() == reflect.String {
+			elem := reflect.New(value.Type().Elem()).Elem()
+			fillSample(elem, depth-1)
+			value.Set(reflect.MakeMap(value.Type()))
+			value.SetMapIndex(reflect.ValueOf("EUR").Convert(value.Type().Key()), elem)
+		}
+	case reflect.Struct:
+		for i := 0; i < value.NumField(); i++ {
+			if value.Type().Field(i).IsExported() {
+				fillSample(value.Field(i), depth)
+			}
+		}
+	}
+}
+
+// assertMoneyFormatted checks that the numbers of fields tagged as money were written as
+// de-DE amounts in the decoded text, and every other number was left as it was; a fractional
+// number outside nonMoneyFields is reported as a money field without a tag
+// field is the JSON key of the struct field holding node
+func assertMoneyFormatted(t *testing.T, path, field string, node any, value reflect.Value, money string) {
+	t.Helper()
+	value = indirect(value)
+	switch typed := node.(type) {
+	case json.Number:
+		if money != "" {
+			t.Errorf("%s = %s, want the money amount formatted as %q", path, typed, sampleAmountText)
+		} else if value.CanFloat() && !nonMoneyFields[field] {
+			t.Errorf("%s = %s: tag %s as money, or list it in nonMoneyFields", path, typed, field)
+		}
+	case string:
+		if money == "" && value.CanFloat() {
+			t.Errorf("%s = %q, want the raw number %v as it is not money", path, typed, sampleAmount)
+		}
+		if money != "" && typed != sampleAmountText {
+			t.Errorf("%s = %q, want %q", path, typed, sampleAmountText)
+		}
+	case []any:
+		for i, child := range typed {
+			assertMoneyFormatted(t, fmt.Sprintf("%s[%d]", path, i), field, child, value.Index(i), money)
+		}
+	case map[string]any:
+		if value.Kind() == reflect.Map {
+			if money == moneyByCurrency {
+				money = moneyAmount
+			}
+			for key, child := range typed {
+				elem := value.MapIndex(reflect.ValueOf(key).Convert(value.Type().Key()))
+				assertMoneyFormatted(t, path+"."+key, field, child, elem, money)
+			}
+			return
+		}
+		fields := jsonFields(value.Type())
+		for key, child := range typed {
+			structField := fields[key]
+			assertMoneyFormatted(t, path+"."+key, key, child, value.FieldByIndex(structField.Index), structField.Tag.Get("money"))
+		}
+	}
+}
+
+func TestHandleListAccountsFormatsTextForLocale(t *testing.T) {
+	srv := newTestServer(t)
+
+	result, err := srv.handleListAccounts(context.Background(), newCallToolRequest("list_accounts", map[string]any{
+		"locale":          "de-DE",
+		"currency_symbol": true,
+	}))
+	if err != nil {
+		t.Fatalf("handleListAccounts returned protocol error: %v", err)
+	}
+	if result.IsError {
+		t.Fatal("expected successful result")
+	}
+
+	assertSingleTextContains(t, result, `"balance": "5.000,00 $"`)
+	assertSingleTextContains(t, result, `"id": 1`)
+
+	structured := result.StructuredContent.(map[string]interface{})
+	accounts := structured["accounts"].([]database.Account)
+	if accounts[0].Balance != 5000 {
+		t.Fatalf("structured balance = %v, want raw 5000", accounts[0].Balance)
+	}
+}
+
+func TestHandleAnalyzeSpendingTrendsFormatsCategoryTotalsForLocale(t *testing.T) {
+	srv := newTestServer(t)
+
+	result, err := srv.handleAnalyzeSpendingTrends(context.Background(), newCallToolRequest("analyze_spending_trends", map[string]any{
+		"locale": "de-DE",
+	}))
+	if err != nil {
+		t.Fatalf("handleAnalyzeSpendingTrends returned protocol error: %v", err)
+	}
+	if result.IsError {
+		t.Fatal("expected successful result")
+	}
+
+	// Totals keyed by category are money like the period total
+	assertSingleTextContains(t, result, `"total_spending": "1.200,00"`)
+	assertSingleTextContains(t, result, `"Rent": "1.200,00"`)
+	assertSingleTextContains(t, result, `"USD": "1.200,00"`)
+	assertSingleTextContains(t, result, `"transaction_count": 1`)
+}
+
+func TestHandleGetFinancialStatsAddsCurrencySymbolWithoutLocale(t *testing.T) {
+	srv := newTestServer(t)
+
+	result, err := srv.handleGetFinancialStats(context.Background(), newCallToolRequest("get_financial_stats", map[string]any{
+		"currency_symbol": true,
+	}))
+	if err != nil {
+		t.Fatalf("handleGetFinancialStats returned protocol error: %v", err)
+	}
+	if result.IsError {
+		t.Fatal("expected successful result")
+	}
+
+	// The analysis is all in USD, so its totals take the symbol from primary_currency
+	assertSingleTextContains(t, result, `"total_income": "$5,500.00"`)
+	assertSingleTextContains(t, result, `"income_transactions": 2`)
+
+	stats := result.StructuredContent.(*database.FinancialStats)
+	if stats.TotalIncome != 5500 {
+		t.Fatalf("structured total income = %v, want raw 5500", stats.TotalIncome)
+	}
+}
+
+func TestObjectCurrency(t *testing.T) {
+	tests := []struct {
+		name string
+		node map[string]any
+		want string
+	}{
+		{name: "account currency", node: map[string]any{"currency": "EUR", "primary_currency": "USD"}, want: "EUR"},
+		{name: "converted analysis", node: map[string]any{"target_currency": "GBP", "currencies": []any{"EUR", "USD"}}, want: "GBP"},
+		{name: "single currency", node: map[string]any{"currencies": []any{"UAH"}}, want: "UAH"},
+		{name: "mixed currencies", node: map[string]any{"mixed_currencies": true, "primary_currency": "USD"}, want: ""},
+		{name: "no currency", node: map[string]any{"total": 1}, want: ""},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := objectCurrency(tc.node); got != tc.want {
+				t.Fatalf("objectCurrency(%v) = %q, want %q", tc.node, got, tc.want)
+			}
+		})
+	}
+}
@@ -1,12 +1,43 @@
 package server
 
+import (
+	"fmt"
+	"strings"
+
+	"github.com/moneywiz-mcp/internal/database"
+)
+
 const defaultTransactionLimit = 50
 
+// Output formats accepted by the transaction listing and export tools
+const (
+	outputFormatJSON  = "json"
+	outputFormatJSONL = "jsonl"
+	outputFormatCSV   = "csv"
+)
+
+// normalizeTransactionParams truncates the account ID and normalizes limit as normalizeLimit does
 func normalizeTransactionParams(accountID float64, limit int) (int64, int) {
+	return int64(accountID), normalizeLimit(limit)
+}
+
+// normalizeLimit keeps a transaction limit within 1 to database.MaxTransactionLimit, using the
+// default for a missing, zero, or negative limit
+func normalizeLimit(limit int) int {
 	if limit <= 0 {
-		limit = defaultTransactionLimit
+		return defaultTransactionLimit
+	}
+	if limit > database.MaxTransactionLimit {
+		return database.MaxTransactionLimit
+	}
+	return limit
+}
+
+func normalizeOffset(offset int) int {
+	if offset < 0 {
+		return 0
 	}
-	return int64(accountID), limit
+	return offset
 }
 
 func normalizeGroupBy(groupBy string) string {
@@ -15,3 +46,175 @@ func normalizeGroupBy(groupBy string) string {
 	}
 	return groupBy
 }
+
+// normalizeOutputFormat lowercases a format argument and checks it is one of allowed
+func normalizeOutputFormat(format string, allowed ...string) (string, error) {
+	format = strings.ToLower(strings.TrimSpace(format))
+	for _, candidate := range allowed {
+		if format == candidate {
+			return format, nil
+		}
+	}
+	return "", fmt.Errorf("invalid format %q: expected %s", format, strings.Join(allowed, " or "))
+}
+
+// parseExchangeRates converts an exchange_rates argument ({"EUR": 1.08, ...}) into a rate map
+func parseExchangeRates(raw any) (map[string]float64, error) {
+	if raw == nil {
+		return nil, nil
+	}
+	values, ok := raw.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("exchange_rates must be an object mapping currency codes to rates")
+	}
+
+	rates := make(map[string]float64, len(values))
+	for currency, value := range values {
+		rate, ok := value.(float64)
+		if !ok {
+			return nil, fmt.Errorf("exchange rate for %s must be a number", currency)
+		}
+		rates[currency] = rate
+	}
+	return rates, nil
+}
+
+// parseSavingsBenchmarks converts a savings_benchmarks argument ({"good": 15, "great": 25, ...})
+// into tier names and minimum savings rates
+func parseSavingsBenchmarks(raw any) (map[string]float64, error) {
+	if raw == nil {
+		return nil, nil
+	}
+	values, ok := raw.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("savings_benchmarks must be an object mapping tier names to minimum savings rates")
+	}
+
+	benchmarks := make(map[string]float64, len(values))
+	for name, value := range values {
+		rate, ok := value.(float64)
+		if !ok {
+			return nil, fmt.Errorf("savings benchmark for %s must be a number", name)
+		}
+		benchmarks[name] = rate
+	}
+	return benchmarks, nil
+}
+
+// parseCategoryAliases converts a category_aliases argument ({"104": "Food", "Groceries - old":
+// "Groceries", ...}) into category aliases
+func parseCategoryAliases(raw any) (database.CategoryAliases, error) {
+	if raw == nil {
+		return nil, nil
+	}
+	values, ok := raw.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("category_aliases must be an object mapping category IDs or names to display names")
+	}
+
+	aliases := make(database.CategoryAliases, len(values))
+	for category, value := range values {
+		name, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("category alias for %s must be a string", category)
+		}
+		aliases[category] = name
+	}
+	return aliases, nil
+}
+
+// parseAccountGroups converts an account_groups argument ({"cash": [1, 2], ...}) into a map of
+// group names to account IDs
+func parseAccountGroups(raw any) (map[string][]int64, error) {
+	if raw == nil {
+		return nil, nil
+	}
+	values, ok := raw.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("account_groups must be an object mapping group names to arrays of account IDs")
+	}
+
+	groups := make(map[string][]int64, len(values))
+	for name, value := range values {
+		ids, ok := value.([]any)
+		if !ok {
+			return nil, fmt.Errorf("account group %s must be an array of account IDs", name)
+		}
+		groups[name] = make([]int64, 0, len(ids))
+		for _, id := range ids {
+			accountID, ok := id.(float64)
+			if !ok || accountID != float64(int64(accountID)) || accountID <= 0 {
+				return nil, fmt.Errorf("account ID %v in group %s must be a positive integer", id, name)
+			}
+			groups[name] = append(groups[name], int64(accountID))
+		}
+	}
+	return groups, nil
+}
+
+// parseCategoryIDs converts a category_ids argument ([12, 34]) into category IDs
+func parseCategoryIDs(raw any) ([]int64, error) {
+	return parseIDs(raw, "category_ids", "category")
+}
+
+// parseIDs converts an array argument of positive integer IDs, such as category_ids, into IDs
+// noun names what the IDs identify in error messages
+func parseIDs(raw any, param, noun string) ([]int64, error) {
+	if raw == nil {
+		return nil, nil
+	}
+	values, ok := raw.([]any)
+	if !ok {
+		return nil, fmt.Errorf("%s must be an array of %s IDs", param, noun)
+	}
+
+	ids := make([]int64, 0, len(values))
+	for _, value := range values {
+		id, ok := value.(float64)
+		if !ok || id != float64(int64(id)) || id <= 0 {
+			return nil, fmt.Errorf("%s ID %v must be a positive integer", noun, value)
+		}
+		ids = append(ids, int64(id))
+	}
+	return ids, nil
+}
+
+// parseStrings converts an array argument of non-empty strings, such as account types
+func parseStrings(raw any, param string) ([]string, error) {
+	if raw == nil {
+		return nil, nil
+	}
+	values, ok := raw.([]any)
+	if !ok {
+		return nil, fmt.Errorf("%s must be an array of strings", param)
+	}
+
+	items := make([]string, 0, len(values))
+	for _, value := range values {
+		item, ok := value.(string)
+		if !ok || strings.TrimSpace(item) == "" {
+			return nil, fmt.Errorf("%s entry %v must be a non-empty string", param, value)
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// parseAccountFilter reads the include/exclude account options of calculate_net_worth
+func parseAccountFilter(args map[string]any) (database.AccountFilter, error) {
+	var filter database.AccountFilter
+	var err error
+	if filter.IncludeIDs, err = parseIDs(args["include_account_ids"], "include_account_ids", "account"); err != nil {
+		return filter, err
+	}
+	if filter.ExcludeIDs, err = parseIDs(args["exclude_account_ids"], "exclude_account_ids", "account"); err != nil {
+		return filter, err
+	}
+	if filter.IncludeTypes, err = parseStrings(args["include_account_types"], "include_account_types"); err != nil {
+		return filter, err
+	}
+	if filter.ExcludeTypes, err = parseStrings(args["exclude_account_types"], "exclude_account_types"); err != nil {
+		return filter, err
+	}
+	return filter, nil
+}
@@ -1,6 +1,10 @@
 package server
 
-import "testing"
+import (
+	"testing"
+
+	"github.com/moneywiz-mcp/internal/database"
+)
 
 func TestNormalizeTransactionParamsDefaultsLimitWhenMissingOrInvalid(t *testing.T) {
 	tests := []struct {
@@ -13,6 +17,8 @@ func TestNormalizeTransactionParamsDefaultsLimitWhenMissingOrInvalid(t *testing.
 		{name: "explicit values", accountID: 249, limit: 20, wantAccount: 249, wantLimit: 20},
 		{name: "zero limit", accountID: 12, limit: 0, wantAccount: 12, wantLimit: defaultTransactionLimit},
 		{name: "negative limit", accountID: 0, limit: -5, wantAccount: 0, wantLimit: defaultTransactionLimit},
+		{name: "limit above the maximum", accountID: 0, limit: 1000000, wantAccount: 0, wantLimit: database.MaxTransactionLimit},
+		{name: "limit at the maximum", accountID: 0, limit: database.MaxTransactionLimit, wantAccount: 0, wantLimit: database.MaxTransactionLimit},
 		{name: "fractional account id truncates", accountID: 42.9, limit: 10, wantAccount: 42, wantLimit: 10},
 	}
 
@@ -29,6 +35,14 @@ func TestNormalizeTransactionParamsDefaultsLimitWhenMissingOrInvalid(t *testing.
 	}
 }
 
+func TestNormalizeOffset(t *testing.T) {
+	for input, want := range map[int]int{0: 0, 25: 25, -3: 0} {
+		if got := normalizeOffset(input); got != want {
+			t.Fatalf("normalizeOffset(%d) = %d, want %d", input, got, want)
+		}
+	}
+}
+
 func TestNormalizeGroupBy(t *testing.T) {
 	tests := []struct {
 		input string
@@ -46,3 +60,93 @@ func TestNormalizeGroupBy(t *testing.T) {
 		}
 	}
 }
+
+func TestParseExchangeRates(t *testing.T) {
+	rates, err := parseExchangeRates(map[string]any{"EUR": 1.08, "GBP": 1.27})
+	if err != nil {
+		t.Fatalf("parseExchangeRates: %v", err)
+	}
+	if len(rates) != 2 || rates["EUR"] != 1.08 || rates["GBP"] != 1.27 {
+		t.Fatalf("rates = %#v", rates)
+	}
+
+	if rates, err := parseExchangeRates(nil); err != nil || rates != nil {
+		t.Fatalf("parseExchangeRates(nil) = %#v, %v; want nil, nil", rates, err)
+	}
+	if _, err := parseExchangeRates([]any{1.08}); err == nil {
+		t.Fatal("parseExchangeRates with array unexpectedly succeeded")
+	}
+	if _, err := parseExchangeRates(map[string]any{"EUR": "1.08"}); err == nil {
+		t.Fatal("parseExchangeRates with string rate unexpectedly succeeded")
+	}
+}
+
+func TestParseAccountGroups(t *testing.T) {
+	groups, err := parseAccountGroups(map[string]any{"cash": []any{float64(1), float64(2)}, "retirement": []any{}})
+	if err != nil {
+		t.Fatalf("parseAccountGroups: %v", err)
+	}
+	if len(groups) != 2 || len(groups["cash"]) != 2 || groups["cash"][1] != 2 || len(groups["retirement"]) != 0 {
+		t.Fatalf("groups = %#v", groups)
+	}
+
+	if groups, err := parseAccountGroups(nil); err != nil || groups != nil {
+		t.Fatalf("parseAccountGroups(nil) = %#v, %v; want nil, nil", groups, err)
+	}
+	if _, err := parseAccountGroups([]any{float64(1)}); err == nil {
+		t.Fatal("parseAccountGroups with array unexpectedly succeeded")
+	}
+	if _, err := parseAccountGroups(map[string]any{"cash": float64(1)}); err == nil {
+		t.Fatal("parseAccountGroups with a bare account ID unexpectedly succeeded")
+	}
+	if _, err := parseAccountGroups(map[string]any{"cash": []any{"1"}}); err == nil {
+		t.Fatal("parseAccountGroups with a string account ID unexpectedly succeeded")
+	}
+}
+
+func TestParseCategoryIDs(t *testing.T) {
+	ids, err := parseCategoryIDs([]any{float64(12), float64(34)})
+	if err != nil {
+		t.Fatalf("parseCategoryIDs: %v", err)
+	}
+	if len(ids) != 2 || ids[0] != 12 || ids[1] != 34 {
+		t.Fatalf("ids = %#v", ids)
+	}
+
+	if ids, err := parseCategoryIDs(nil); err != nil || ids != nil {
+		t.Fatalf("parseCategoryIDs(nil) = %#v, %v; want nil, nil", ids, err)
+	}
+	if _, err := parseCategoryIDs(float64(12)); err == nil {
+		t.Fatal("parseCategoryIDs with a bare number unexpectedly succeeded")
+	}
+	if _, err := parseCategoryIDs([]any{1.5}); err == nil {
+		t.Fatal("parseCategoryIDs with a fractional ID unexpectedly succeeded")
+	}
+	if _, err := parseCategoryIDs([]any{"12"}); err == nil {
+		t.Fatal("parseCategoryIDs with a string ID unexpectedly succeeded")
+	}
+}
+
+func TestParseAccountFilter(t *testing.T) {
+	filter, err := parseAccountFilter(map[string]any{
+		"include_account_ids":   []any{float64(1), float64(2)},
+		"exclude_account_ids":   []any{float64(2)},
+		"exclude_account_types": []any{"investment"},
+	})
+	if err != nil {
+		t.Fatalf("parseAccountFilter: %v", err)
+	}
+	if len(filter.IncludeIDs) != 2 || len(filter.ExcludeIDs) != 1 || filter.ExcludeTypes[0] != "investment" || filter.IncludeTypes != nil {
+		t.Fatalf("filter = %#v", filter)
+	}
+
+	if _, err := parseAccountFilter(map[string]any{"exclude_account_ids": []any{float64(0)}}); err == nil {
+		t.Fatal("parseAccountFilter with account ID 0 unexpectedly succeeded")
+	}
+	if _, err := parseAccountFilter(map[string]any{"include_account_types": "bank"}); err == nil {
+		t.Fatal("parseAccountFilter with a bare type unexpectedly succeeded")
+	}
+	if _, err := parseAccountFilter(map[string]any{"include_account_types": []any{" "}}); err == nil {
+		t.Fatal("parseAccountFilter with a blank type unexpectedly succeeded")
+	}
+}
@@ -0,0 +1,44 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	mcpserver "github.com/mark3labs/mcp-go/server"
+)
+
+// DefaultQueryTimeout bounds how long one tool call may query the database
+const DefaultQueryTimeout = 30 * time.Second
+
+// QueryLimits bounds the time tool calls spend in the database
+// The time of each query is logged by the database itself (see database.Options)
+type QueryLimits struct {
+	Timeout time.Duration // Per-call deadline passed to the database through the context (0 = none)
+}
+
+// Middleware applies the limits to every tool handler of an MCP server
+// A call stopped by the timeout fails with the TIMEOUT error code instead of a database error
+func (l QueryLimits) Middleware() mcpserver.ToolHandlerMiddleware {
+	return func(next mcpserver.ToolHandlerFunc) mcpserver.ToolHandlerFunc {
+		return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			callCtx := ctx
+			if l.Timeout > 0 {
+				var cancel context.CancelFunc
+				callCtx, cancel = context.WithTimeout(ctx, l.Timeout)
+				defer cancel()
+			}
+
+			result, err := next(callCtx, request)
+
+			// Only the timeout set here is reported as such; a cancelled request stays as it is
+			timedOut := errors.Is(callCtx.Err(), context.DeadlineExceeded) && ctx.Err() == nil
+			if timedOut && (err != nil || (result != nil && result.IsError)) {
+				return errorResult(ErrorCodeTimeout, fmt.Errorf("%s did not finish within %s", request.Params.Name, l.Timeout)), nil
+			}
+			return result, err
+		}
+	}
+}
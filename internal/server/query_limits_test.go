@@ -0,0 +1,48 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+func TestQueryLimitsMiddlewareReportsTimeouts(t *testing.T) {
+	blocking := func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		<-ctx.Done()
+		return errorResult(ErrorCodeDBError, ctx.Err()), nil
+	}
+	handler := QueryLimits{Timeout: 10 * time.Millisecond}.Middleware()(blocking)
+
+	result, err := handler(context.Background(), newCallToolRequest("get_financial_stats", map[string]any{}))
+	if err != nil {
+		t.Fatalf("handler returned protocol error: %v", err)
+	}
+	toolErr, ok := result.StructuredContent.(ToolError)
+	if !result.IsError || !ok || toolErr.Error.Code != ErrorCodeTimeout {
+		t.Fatalf("result = %+v, want a TIMEOUT error", result)
+	}
+	assertSingleTextContains(t, result, "get_financial_stats did not finish within 10ms")
+
+	// A request cancelled by the client is not a timeout
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	result, err = handler(ctx, newCallToolRequest("get_financial_stats", map[string]any{}))
+	if err != nil {
+		t.Fatalf("handler returned protocol error: %v", err)
+	}
+	if toolErr := result.StructuredContent.(ToolError); toolErr.Error.Code == ErrorCodeTimeout {
+		t.Fatalf("cancelled call reported as %s", toolErr.Error.Code)
+	}
+
+	quick := QueryLimits{Timeout: time.Second}.Middleware()(func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		if _, ok := ctx.Deadline(); !ok {
+			t.Fatal("handler context has no deadline")
+		}
+		return mcp.NewToolResultText("ok"), nil
+	})
+	if result, err := quick(context.Background(), newCallToolRequest("list_accounts", map[string]any{})); err != nil || result.IsError {
+		t.Fatalf("quick call = %+v, %v; want success", result, err)
+	}
+}
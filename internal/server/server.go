@@ -1,7 +1,10 @@
 package server
 
 import (
+	"context"
+	"fmt"
 	"log"
+	"strings"
 
 	"github.com/mark3labs/mcp-go/mcp"
 	mcpserver "github.com/mark3labs/mcp-go/server"
@@ -9,27 +12,134 @@ import (
 )
 
 type Server struct {
-	db *database.DB
+	databases       map[string]*database.DB
+	databaseNames   []string // Registration order; the first is the default
+	defaultCurrency string   // target_currency of calls that pass exchange_rates but none ("" = the file's base currency)
 }
 
-func NewServer(db *database.DB) *Server {
-	return &Server{db: db}
+// NewServer creates a server over one or more named databases
+// Tools query the first database unless their database argument names another
+func NewServer(databases ...NamedDB) (*Server, error) {
+	if len(databases) == 0 {
+		return nil, fmt.Errorf("at least one database is required")
+	}
+
+	s := &Server{databases: make(map[string]*database.DB, len(databases))}
+	for _, d := range databases {
+		name := strings.TrimSpace(d.Name)
+		if name == "" {
+			name = DefaultDatabaseName
+		}
+		if _, exists := s.databases[name]; exists {
+			return nil, fmt.Errorf("database %q registered more than once", name)
+		}
+		s.databases[name] = d.DB
+		s.databaseNames = append(s.databaseNames, name)
+	}
+	return s, nil
+}
+
+// SetDefaultCurrency makes tools that can convert currencies convert into code when a call
+// passes exchange_rates but no target_currency; "" falls back to the base currency set in the
+// MoneyWiz file, and keeps amounts in their own currencies when the file sets none
+func (s *Server) SetDefaultCurrency(code string) {
+	s.defaultCurrency = strings.ToUpper(strings.TrimSpace(code))
+}
+
+// targetCurrencyFor returns the currency a tool converts into: the target_currency argument, else,
+// when the call passes exchange rates, the configured default currency or the base currency from
+// the database's settings. Without rates a default would only relabel every amount at 1.0, so ""
+// keeps amounts in their own currencies
+func (s *Server) targetCurrencyFor(ctx context.Context, request mcp.CallToolRequest, db *database.DB, rates map[string]float64) (string, error) {
+	if target := request.GetString("target_currency", ""); target != "" {
+		return target, nil
+	}
+	if len(rates) == 0 {
+		return "", nil
+	}
+	if s.defaultCurrency != "" {
+		return s.defaultCurrency, nil
+	}
+	return db.SettingsBaseCurrency(ctx)
 }
 
 func (s *Server) RegisterHandlers(mcpServer *mcpserver.MCPServer) {
 	log.Println("🔧 Registering MCP tools...")
 
+	// List databases tool
+	log.Println("  ✓ Registering tool: list_databases")
+	mcpServer.AddTool(mcp.Tool{
+		Name:        "list_databases",
+		Description: "List the MoneyWiz databases this server was started with; pass a name as the database argument of other tools to query it",
+		InputSchema: mcp.ToolInputSchema{
+			Type:       "object",
+			Properties: withNumberFormatOptions(map[string]any{}),
+		},
+	}, s.handleListDatabases)
+
+	// Database info tool
+	log.Println("  ✓ Registering tool: database_info")
+	mcpServer.AddTool(mcp.Tool{
+		Name:        "database_info",
+		Description: "Describe a MoneyWiz database: its base currency (read from the file's settings, or guessed from the accounts), account currencies, account count, and the number and date range of its transactions; converting tools given exchange_rates default to the base currency set in the file",
+		InputSchema: mcp.ToolInputSchema{
+			Type:       "object",
+			Properties: withDatabaseOption(withNumberFormatOptions(map[string]any{})),
+		},
+	}, s.handleDatabaseInfo)
+
 	// List accounts tool
 	log.Println("  ✓ Registering tool: list_accounts")
 	mcpServer.AddTool(mcp.Tool{
 		Name:        "list_accounts",
-		Description: "List all MoneyWiz accounts with balances and explicit account currencies",
+		Description: "List MoneyWiz accounts with balances and explicit account currencies; pass currency or account_type to list only some of them",
 		InputSchema: mcp.ToolInputSchema{
-			Type:       "object",
-			Properties: map[string]any{},
+			Type: "object",
+			Properties: withDatabaseOption(withNumberFormatOptions(map[string]any{
+				"currency": map[string]any{
+					"type":        "string",
+					"description": "Optional currency code (e.g. \"USD\") to list only accounts in that currency",
+				},
+				"account_type": map[string]any{
+					"type":        "string",
+					"description": "Optional account type (e.g. \"bank\", \"credit\"), as shown in account_type, to list only accounts of that type",
+				},
+			})),
 		},
 	}, s.handleListAccounts)
 
+	// List account groups tool
+	log.Println("  ✓ Registering tool: list_account_groups")
+	mcpServer.AddTool(mcp.Tool{
+		Name:        "list_account_groups",
+		Description: "List the account groups stored in MoneyWiz (e.g. cash, retirement, joint) with the IDs of their accounts; databases without groups return an empty list with a note",
+		InputSchema: mcp.ToolInputSchema{
+			Type:       "object",
+			Properties: withDatabaseOption(withNumberFormatOptions(map[string]any{})),
+		},
+	}, s.handleListAccountGroups)
+
+	// Dormant accounts tool
+	log.Println("  ✓ Registering tool: list_dormant_accounts")
+	mcpServer.AddTool(mcp.Tool{
+		Name:        "list_dormant_accounts",
+		Description: "List the accounts whose most recent transaction is older than a number of days, with that date, the days since, and the current balance, to find accounts that are no longer used; accounts without any transaction are listed as never used",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: withDatabaseOption(withNumberFormatOptions(map[string]any{
+				"days": map[string]any{
+					"type":        "integer",
+					"description": "Number of days without a transaction for an account to count as dormant (default: 180)",
+					"default":     180,
+				},
+				"as_of": map[string]any{
+					"type":        "string",
+					"description": "Optional YYYY-MM-DD day to count from (default: today)",
+				},
+			})),
+		},
+	}, s.handleListDormantAccounts)
+
 	// Get account balance tool
 	log.Println("  ✓ Registering tool: get_account_balance")
 	mcpServer.AddTool(mcp.Tool{
@@ -37,56 +147,375 @@ func (s *Server) RegisterHandlers(mcpServer *mcpserver.MCPServer) {
 		Description: "Get the balance for a specific account by ID",
 		InputSchema: mcp.ToolInputSchema{
 			Type: "object",
-			Properties: map[string]any{
+			Properties: withDatabaseOption(withNumberFormatOptions(map[string]any{
 				"account_id": map[string]any{
 					"type":        "integer",
 					"description": "The ID of the account",
 				},
-			},
+			})),
 			Required: []string{"account_id"},
 		},
 	}, s.handleGetAccountBalance)
 
+	// Balance history tool
+	log.Println("  ✓ Registering tool: get_balance_history")
+	mcpServer.AddTool(mcp.Tool{
+		Name:        "get_balance_history",
+		Description: "Get an account's running balance at each month-end, starting from its opening balance and including transfers; useful for charting savings growth",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: withDatabaseOption(withNumberFormatOptions(map[string]any{
+				"account_id": map[string]any{
+					"type":        "integer",
+					"description": "The ID of the account",
+				},
+				"months": map[string]any{
+					"type":        "integer",
+					"description": "Number of month-ends to return, ending at the latest transaction (default: 12, 0 = full history)",
+					"default":     12,
+				},
+			})),
+			Required: []string{"account_id"},
+		},
+	}, s.handleGetBalanceHistory)
+
+	// Balance as of date tool
+	log.Println("  ✓ Registering tool: get_balance_as_of")
+	mcpServer.AddTool(mcp.Tool{
+		Name:        "get_balance_as_of",
+		Description: "Get an account's balance at the end of a given day: its opening balance plus every transaction, including transfers, dated on or before it. Use it to reconcile against a bank statement",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: withDatabaseOption(withNumberFormatOptions(map[string]any{
+				"account_id": map[string]any{
+					"type":        "integer",
+					"description": "The ID of the account",
+				},
+				"date": map[string]any{
+					"type":        "string",
+					"description": "Day to take the balance at, in YYYY-MM-DD format; transactions on this day are included",
+				},
+			})),
+			Required: []string{"account_id", "date"},
+		},
+	}, s.handleGetBalanceAsOf)
+
+	// Get investment holdings tool
+	log.Println("  ✓ Registering tool: get_investment_holdings")
+	mcpServer.AddTool(mcp.Tool{
+		Name:        "get_investment_holdings",
+		Description: "List the holdings of an investment account with symbol, shares, current value, cost basis, gain/loss, and share of the portfolio when MoneyWiz stores them; returns an empty list with a note when it does not",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: withDatabaseOption(withNumberFormatOptions(map[string]any{
+				"account_id": map[string]any{
+					"type":        "integer",
+					"description": "The ID of the investment account",
+				},
+			})),
+			Required: []string{"account_id"},
+		},
+	}, s.handleGetInvestmentHoldings)
+
+	// Project account depletion tool
+	log.Println("  ✓ Registering tool: project_account_depletion")
+	mcpServer.AddTool(mcp.Tool{
+		Name:        "project_account_depletion",
+		Description: "Project when an account will reach zero (or a target balance) at its average monthly net change; reports when the balance is growing or flat instead",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: withDatabaseOption(withNumberFormatOptions(map[string]any{
+				"account_id": map[string]any{
+					"type":        "integer",
+					"description": "The ID of the account",
+				},
+				"history_months": map[string]any{
+					"type":        "integer",
+					"description": "Number of recent months of account activity used for the average monthly net change (default: 6)",
+					"default":     6,
+				},
+				"target_balance": map[string]any{
+					"type":        "number",
+					"description": "Balance to project towards (default: 0)",
+					"default":     0,
+				},
+			})),
+			Required: []string{"account_id"},
+		},
+	}, s.handleProjectAccountDepletion)
+
 	// List transactions tool
 	log.Println("  ✓ Registering tool: list_transactions")
 	mcpServer.AddTool(mcp.Tool{
 		Name:        "list_transactions",
-		Description: "List recent transactions with account name, currency, category, and movement type; transfer-like rows are labeled explicitly",
+		Description: "List recent transactions with account name, currency, category, and movement type, paged with limit/offset and a total_count; transfer-like rows are labeled explicitly",
 		InputSchema: mcp.ToolInputSchema{
 			Type: "object",
-			Properties: map[string]any{
+			Properties: withDatabaseOption(withNumberFormatOptions(map[string]any{
 				"account_id": map[string]any{
 					"type":        "integer",
 					"description": "Optional account ID to filter transactions. If not provided, returns all transactions",
 				},
 				"limit": map[string]any{
 					"type":        "integer",
-					"description": "Maximum number of transactions to return (default: 50)",
+					"description": "Maximum number of transactions to return (default: 50, max: 1000); larger values are clamped to 1000",
 					"default":     50,
 				},
-			},
+				"offset": map[string]any{
+					"type":        "integer",
+					"description": "Number of transactions to skip for pagination (default: 0); use with total_count from the response",
+					"default":     0,
+				},
+				"start_date": map[string]any{
+					"type":        "string",
+					"description": "Optional ISO 8601 date (YYYY-MM-DD, YYYY-MM, or YYYY); only transactions on or after it are returned",
+				},
+				"end_date": map[string]any{
+					"type":        "string",
+					"description": "Optional ISO 8601 date (YYYY-MM-DD, YYYY-MM, or YYYY); only transactions up to the end of that day, month, or year are returned",
+				},
+				"min_amount": map[string]any{
+					"type":        "number",
+					"description": "Optional minimum transaction size; compared with the absolute amount so income and expenses both match (e.g. 500 for anything over 500)",
+				},
+				"max_amount": map[string]any{
+					"type":        "number",
+					"description": "Optional maximum transaction size, compared with the absolute amount",
+				},
+				"status": map[string]any{
+					"type":        "string",
+					"description": "Optional status filter: 'pending' for transactions not yet cleared, 'cleared' for cleared or reconciled ones",
+					"enum":        []string{"pending", "cleared"},
+				},
+				"format": map[string]any{
+					"type":        "string",
+					"description": "Output format: 'json' for one object with paging details (default), or 'jsonl' for one compact transaction object per line, streamed for large result sets (no total_count or structured content)",
+					"enum":        []string{"json", "jsonl"},
+					"default":     "json",
+				},
+			})),
 		},
 	}, s.handleListTransactions)
 
+	// Search transactions tool
+	log.Println("  ✓ Registering tool: search_transactions")
+	mcpServer.AddTool(mcp.Tool{
+		Name:        "search_transactions",
+		Description: "Find transactions whose description or notes contain the given text (case-insensitive), most recent first",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: withDatabaseOption(withNumberFormatOptions(map[string]any{
+				"query": map[string]any{
+					"type":        "string",
+					"description": "Text to look for in transaction descriptions and notes, e.g. 'amazon' or 'roommate'",
+				},
+				"account_id": map[string]any{
+					"type":        "integer",
+					"description": "Optional account ID to search within. If not provided, searches all transactions",
+				},
+				"limit": map[string]any{
+					"type":        "integer",
+					"description": "Maximum number of transactions to return (default: 50, max: 1000); larger values are clamped to 1000",
+					"default":     50,
+				},
+			})),
+			Required: []string{"query"},
+		},
+	}, s.handleSearchTransactions)
+
+	// Export transactions CSV tool
+	log.Println("  ✓ Registering tool: export_transactions_csv")
+	mcpServer.AddTool(mcp.Tool{
+		Name:        "export_transactions_csv",
+		Description: "Export transactions as CSV text (id, date, amount, description, payee, category, account) for a date range, e.g. for tax prep in a spreadsheet, or as JSON Lines with format 'jsonl'",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: withDatabaseOption(map[string]any{
+				"start_date": map[string]any{
+					"type":        "string",
+					"description": "Optional start date (YYYY-MM-DD, YYYY-MM, or YYYY); exports transactions on or after it",
+				},
+				"end_date": map[string]any{
+					"type":        "string",
+					"description": "Optional end date (YYYY-MM-DD, YYYY-MM, or YYYY); exports transactions up to the end of that day, month, or year",
+				},
+				"account_id": map[string]any{
+					"type":        "integer",
+					"description": "Optional account ID to export. If not provided, exports all accounts",
+				},
+				"format": map[string]any{
+					"type":        "string",
+					"description": "Output format: 'csv' (default) or 'jsonl' for one JSON transaction object per line with every field, streamed for multi-year exports",
+					"enum":        []string{"csv", "jsonl"},
+					"default":     "csv",
+				},
+			}),
+		},
+	}, s.handleExportTransactionsCSV)
+
+	// Get Uncategorized Transactions tool
+	log.Println("  ✓ Registering tool: get_uncategorized_transactions")
+	mcpServer.AddTool(mcp.Tool{
+		Name:        "get_uncategorized_transactions",
+		Description: "List transactions without a category, with uncategorized spending and income totals and the share of spending they represent; use it to find what to clean up",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: withDatabaseOption(withNumberFormatOptions(map[string]any{
+				"months": map[string]any{
+					"type":        "integer",
+					"description": "Number of months to look back (default: 12, 0 = all data)",
+					"default":     12,
+				},
+				"limit": map[string]any{
+					"type":        "integer",
+					"description": "Maximum number of transactions to list (default: 50, max: 1000); larger values are clamped to 1000, and totals always cover the whole period",
+					"default":     50,
+				},
+			})),
+		},
+	}, s.handleGetUncategorizedTransactions)
+
+	// Diagnose Category Assignments tool
+	log.Println("  ✓ Registering tool: diagnose_category_assignments")
+	mcpServer.AddTool(mcp.Tool{
+		Name:        "diagnose_category_assignments",
+		Description: "Count income and expense transactions with zero, one, or several category assignments, and find assignments the analyses cannot follow (pointing at missing categories or transactions, or split portions that do not add up), to check whether uncategorized totals can be trusted",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: withDatabaseOption(withNumberFormatOptions(map[string]any{
+				"months": map[string]any{
+					"type":        "integer",
+					"description": "Number of months to look back (default: 0 = all data)",
+					"default":     0,
+				},
+			})),
+		},
+	}, s.handleDiagnoseCategoryAssignments)
+
 	// List categories tool
 	log.Println("  ✓ Registering tool: list_categories")
 	mcpServer.AddTool(mcp.Tool{
 		Name:        "list_categories",
-		Description: "List all categories in MoneyWiz",
+		Description: "List all categories in MoneyWiz with their type (income, expense, or both)",
 		InputSchema: mcp.ToolInputSchema{
-			Type:       "object",
-			Properties: map[string]any{},
+			Type: "object",
+			Properties: withDatabaseOption(withNumberFormatOptions(map[string]any{
+				"type": map[string]any{
+					"type":        "string",
+					"description": "Optional filter: 'income' or 'expense' returns categories of that type plus those used for both",
+					"enum":        []string{"income", "expense", "both"},
+				},
+			})),
 		},
 	}, s.handleListCategories)
 
+	// List categories with totals tool
+	log.Println("  ✓ Registering tool: list_categories_with_totals")
+	mcpServer.AddTool(mcp.Tool{
+		Name:        "list_categories_with_totals",
+		Description: "List every category with its total spending, total income, transaction count, and last-used date over a period; unused categories appear with zero totals so they can be cleaned up",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: withDatabaseOption(withNumberFormatOptions(map[string]any{
+				"months": map[string]any{
+					"type":        "integer",
+					"description": "Number of months to total (default: 12, 0 = all historical data)",
+					"default":     12,
+				},
+				"type": map[string]any{
+					"type":        "string",
+					"description": "Optional filter: 'income' or 'expense' returns categories of that type plus those used for both",
+					"enum":        []string{"income", "expense", "both"},
+				},
+			})),
+		},
+	}, s.handleListCategoriesWithTotals)
+
+	// Category tree tool
+	log.Println("  ✓ Registering tool: get_category_tree")
+	mcpServer.AddTool(mcp.Tool{
+		Name:        "get_category_tree",
+		Description: "Get the full category hierarchy as nested JSON, each category with its own spending and its total including every subcategory, largest first; explore where money went top-down. Categories whose parent is missing are placed at the root",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: withDatabaseOption(withNumberFormatOptions(map[string]any{
+				"months": map[string]any{
+					"type":        "integer",
+					"description": "Number of months to total (default: 12, 0 = all historical data)",
+					"default":     12,
+				},
+			})),
+		},
+	}, s.handleGetCategoryTree)
+
+	// Get spending for category tool
+	log.Println("  ✓ Registering tool: get_spending_for_category")
+	mcpServer.AddTool(mcp.Tool{
+		Name:        "get_spending_for_category",
+		Description: "List every expense of one category with its total over a period, most recent first, to drill from category summaries into the individual transactions; internal transfers/cash withdrawals are excluded",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: withDatabaseOption(withNumberFormatOptions(map[string]any{
+				"category_id": map[string]any{
+					"type":        "integer",
+					"description": "ID of the category (from list_categories); either category_id or category is required",
+				},
+				"category": map[string]any{
+					"type":        "string",
+					"description": "Category name or full path (e.g. 'Groceries' or 'Food > Groceries'), matched without regard to case; used when category_id is omitted",
+				},
+				"months": map[string]any{
+					"type":        "integer",
+					"description": "Number of months to look back (default: 12, 0 = all historical data)",
+					"default":     12,
+				},
+				"include_subcategories": map[string]any{
+					"type":        "boolean",
+					"description": "Also include expenses of the category's subcategories (default: false)",
+					"default":     false,
+				},
+			})),
+		},
+	}, s.handleGetSpendingForCategory)
+
+	// Category distribution tool
+	log.Println("  ✓ Registering tool: get_category_distribution")
+	mcpServer.AddTool(mcp.Tool{
+		Name:        "get_category_distribution",
+		Description: "Distribution of the individual expense amounts of one category (min, median, mean, max, 90th percentile), e.g. to tell many small meals from a few big dinners; internal transfers/cash withdrawals are excluded",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: withDatabaseOption(withNumberFormatOptions(map[string]any{
+				"category_id": map[string]any{
+					"type":        "integer",
+					"description": "ID of the category (from list_categories); either category_id or category is required",
+				},
+				"category": map[string]any{
+					"type":        "string",
+					"description": "Category name or full path (e.g. 'Dining' or 'Food > Dining'), matched without regard to case; used when category_id is omitted",
+				},
+				"months": map[string]any{
+					"type":        "integer",
+					"description": "Number of months to look back (default: 12, 0 = all historical data)",
+					"default":     12,
+				},
+				"include_subcategories": map[string]any{
+					"type":        "boolean",
+					"description": "Also include expenses of the category's subcategories (default: false)",
+					"default":     false,
+				},
+			})),
+		},
+	}, s.handleGetCategoryDistribution)
+
 	// Analyze spending trends tool
 	log.Println("  ✓ Registering tool: analyze_spending_trends")
 	mcpServer.AddTool(mcp.Tool{
 		Name:        "analyze_spending_trends",
-		Description: "Analyze spending trends by category and time period (month or year), including by_currency totals and excluding internal transfers/cash withdrawals",
+		Description: "Analyze spending trends by category and time period (month or year), including by_currency totals, per-category changes versus the previous period, and the fastest-growing categories; internal transfers/cash withdrawals are excluded. Pass target_currency and exchange_rates to convert mixed-currency spending before aggregating",
 		InputSchema: mcp.ToolInputSchema{
 			Type: "object",
-			Properties: map[string]any{
+			Properties: withDatabaseOption(withNumberFormatOptions(map[string]any{
 				"group_by": map[string]any{
 					"type":        "string",
 					"description": "Group by 'month' or 'year' (default: 'month')",
@@ -98,7 +527,47 @@ func (s *Server) RegisterHandlers(mcpServer *mcpserver.MCPServer) {
 					"description": "Number of months to analyze (0 or omitted = all historical data)",
 					"default":     0,
 				},
-			},
+				"include_transfers": map[string]any{
+					"type":        "boolean",
+					"description": "Count transfers between your own accounts as income and spending (default: false); cash withdrawals stay excluded either way",
+					"default":     false,
+				},
+				"include_scheduled": map[string]any{
+					"type":        "boolean",
+					"description": "Count future-dated (scheduled) transactions; by default they are left out of the results and of the months-back cutoff (default: false)",
+					"default":     false,
+				},
+				"rollup": map[string]any{
+					"type":        "boolean",
+					"description": "Report subcategory spending under its top-level category, e.g. \"Food > Groceries\" as \"Food\" (default: false)",
+					"default":     false,
+				},
+				"category_aliases": map[string]any{
+					"type":        "object",
+					"description": "Optional map from category ID or name (case-insensitive) to a display name, merging the matching categories in the output without changing the database, e.g. {\"Food2\": \"Food\", \"104\": \"Food\"}. With rollup, aliases apply to the top-level categories",
+					"additionalProperties": map[string]any{
+						"type": "string",
+					},
+				},
+				"category_ids": map[string]any{
+					"type":        "array",
+					"description": "Optional category IDs (from list_categories) to restrict the analysis to, e.g. [12, 34] for Dining + Groceries. Omit for all categories",
+					"items": map[string]any{
+						"type": "integer",
+					},
+				},
+				"target_currency": map[string]any{
+					"type":        "string",
+					"description": "Optional currency code (e.g. \"USD\") to convert each expense into, by its account currency, before aggregating; requires exchange_rates for the other currencies. When exchange_rates are given, defaults to the server's default currency, else the base currency set in the file",
+				},
+				"exchange_rates": map[string]any{
+					"type":        "object",
+					"description": "Optional map of currency code to units of target_currency per unit of that currency, e.g. {\"EUR\": 1.08}. Missing currencies are converted at 1.0 with a warning",
+					"additionalProperties": map[string]any{
+						"type": "number",
+					},
+				},
+			})),
 		},
 	}, s.handleAnalyzeSpendingTrends)
 
@@ -109,7 +578,7 @@ func (s *Server) RegisterHandlers(mcpServer *mcpserver.MCPServer) {
 		Description: "Analyze income trends by category and time period (month or year), including by_currency totals and excluding internal transfers/cash withdrawals",
 		InputSchema: mcp.ToolInputSchema{
 			Type: "object",
-			Properties: map[string]any{
+			Properties: withDatabaseOption(withNumberFormatOptions(map[string]any{
 				"group_by": map[string]any{
 					"type":        "string",
 					"description": "Group by 'month' or 'year' (default: 'month')",
@@ -121,10 +590,176 @@ func (s *Server) RegisterHandlers(mcpServer *mcpserver.MCPServer) {
 					"description": "Number of months to analyze (0 or omitted = all historical data)",
 					"default":     0,
 				},
-			},
+				"include_transfers": map[string]any{
+					"type":        "boolean",
+					"description": "Count transfers between your own accounts as income and spending (default: false); cash withdrawals stay excluded either way",
+					"default":     false,
+				},
+				"include_scheduled": map[string]any{
+					"type":        "boolean",
+					"description": "Count future-dated (scheduled) transactions; by default they are left out of the results and of the months-back cutoff (default: false)",
+					"default":     false,
+				},
+			})),
 		},
 	}, s.handleAnalyzeIncomeTrends)
 
+	// Analyze income sources tool
+	log.Println("  ✓ Registering tool: analyze_income_sources")
+	mcpServer.AddTool(mcp.Tool{
+		Name:        "analyze_income_sources",
+		Description: "Break income down by source (category or payee) with each source's share of the total, and flag an income concentration risk when a single source exceeds the threshold (default 80%)",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: withDatabaseOption(withNumberFormatOptions(map[string]any{
+				"months": map[string]any{
+					"type":        "integer",
+					"description": "Number of months to analyze (default: 12, 0 = all historical data)",
+					"default":     12,
+				},
+				"group_by": map[string]any{
+					"type":        "string",
+					"description": "What counts as a source: \"category\" (default) or \"payee\" (income without a payee is grouped by its description)",
+					"enum":        []string{"category", "payee"},
+					"default":     "category",
+				},
+				"threshold_percent": map[string]any{
+					"type":        "number",
+					"description": "Share of total income (0-100) above which a single source is flagged as a concentration risk (default: 80)",
+					"default":     80,
+				},
+			})),
+		},
+	}, s.handleAnalyzeIncomeSources)
+
+	// Analyze net savings trend tool
+	log.Println("  ✓ Registering tool: analyze_net_savings_trend")
+	mcpServer.AddTool(mcp.Tool{
+		Name:        "analyze_net_savings_trend",
+		Description: "Analyze net savings (income minus spending) and savings rate per time period (month or year) in one series, including by_currency totals and excluding internal transfers/cash withdrawals",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: withDatabaseOption(withNumberFormatOptions(map[string]any{
+				"group_by": map[string]any{
+					"type":        "string",
+					"description": "Group by 'month' or 'year' (default: 'month')",
+					"enum":        []string{"month", "year"},
+					"default":     "month",
+				},
+				"months": map[string]any{
+					"type":        "integer",
+					"description": "Number of months to analyze (0 or omitted = all historical data)",
+					"default":     0,
+				},
+				"include_transfers": map[string]any{
+					"type":        "boolean",
+					"description": "Count transfers between your own accounts as income and spending (default: false); cash withdrawals stay excluded either way",
+					"default":     false,
+				},
+				"include_scheduled": map[string]any{
+					"type":        "boolean",
+					"description": "Count future-dated (scheduled) transactions; by default they are left out of the results and of the months-back cutoff (default: false)",
+					"default":     false,
+				},
+			})),
+		},
+	}, s.handleAnalyzeNetSavingsTrend)
+
+	// Expense ratio trend tool
+	log.Println("  ✓ Registering tool: get_expense_ratio_trend")
+	mcpServer.AddTool(mcp.Tool{
+		Name:        "get_expense_ratio_trend",
+		Description: "Get the expense-to-income ratio (expense / income) per month as one series, with each month's income and expense and the current, still partial month flagged; a rising ratio is an early warning even while income still covers spending",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: withDatabaseOption(withNumberFormatOptions(map[string]any{
+				"months": map[string]any{
+					"type":        "integer",
+					"description": "Number of months to analyze (0 or omitted = all historical data)",
+					"default":     0,
+				},
+				"include_transfers": map[string]any{
+					"type":        "boolean",
+					"description": "Count transfers between your own accounts as income and spending (default: false); cash withdrawals stay excluded either way",
+					"default":     false,
+				},
+			})),
+		},
+	}, s.handleGetExpenseRatioTrend)
+
+	// Spending by payee tool
+	log.Println("  ✓ Registering tool: analyze_spending_by_payee")
+	mcpServer.AddTool(mcp.Tool{
+		Name:        "analyze_spending_by_payee",
+		Description: "Aggregate spending totals and transaction counts per payee/merchant, largest first; transactions without a payee are grouped by description",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: withDatabaseOption(withNumberFormatOptions(map[string]any{
+				"months": map[string]any{
+					"type":        "integer",
+					"description": "Number of months to analyze (default: 12, 0 = all historical data)",
+					"default":     12,
+				},
+			})),
+		},
+	}, s.handleAnalyzeSpendingByPayee)
+
+	// Analyze spending by tag tool
+	log.Println("  ✓ Registering tool: analyze_spending_by_tag")
+	mcpServer.AddTool(mcp.Tool{
+		Name:        "analyze_spending_by_tag",
+		Description: "Total spending per MoneyWiz tag (e.g. a trip or project), largest first, with per-category and per-currency breakdowns; a transaction with several tags counts towards each",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: withDatabaseOption(withNumberFormatOptions(map[string]any{
+				"months": map[string]any{
+					"type":        "integer",
+					"description": "Number of months to analyze (default: 12, 0 = all historical data)",
+					"default":     12,
+				},
+			})),
+		},
+	}, s.handleAnalyzeSpendingByTag)
+
+	// Analyze spending by weekday tool
+	log.Println("  ✓ Registering tool: analyze_spending_by_weekday")
+	mcpServer.AddTool(mcp.Tool{
+		Name:        "analyze_spending_by_weekday",
+		Description: "Total spending per day of the week (Monday to Sunday) with counts, daily averages, the busiest day, and the weekend share, to spot habits such as weekend overspending",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: withDatabaseOption(withNumberFormatOptions(map[string]any{
+				"months": map[string]any{
+					"type":        "integer",
+					"description": "Number of months to analyze (default: 12, 0 = all historical data)",
+					"default":     12,
+				},
+			})),
+		},
+	}, s.handleAnalyzeSpendingByWeekday)
+
+	// Get top merchants tool
+	log.Println("  ✓ Registering tool: get_top_merchants")
+	mcpServer.AddTool(mcp.Tool{
+		Name:        "get_top_merchants",
+		Description: "Rank payees by how often you pay them and by how much you spend with them, surfacing habitual small purchases that never top the spending chart",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: withDatabaseOption(withNumberFormatOptions(map[string]any{
+				"months": map[string]any{
+					"type":        "integer",
+					"description": "Number of months to analyze (default: 12, 0 = all historical data)",
+					"default":     12,
+				},
+				"limit": map[string]any{
+					"type":        "integer",
+					"description": "Maximum number of payees in each ranking (default: 10, 0 = all)",
+					"default":     10,
+				},
+			})),
+		},
+	}, s.handleGetTopMerchants)
+
 	// Savings recommendations tool
 	log.Println("  ✓ Registering tool: get_savings_recommendations")
 	mcpServer.AddTool(mcp.Tool{
@@ -132,37 +767,610 @@ func (s *Server) RegisterHandlers(mcpServer *mcpserver.MCPServer) {
 		Description: "Analyze income vs spending with per-currency breakdowns and mixed-currency warnings, then return savings recommendations",
 		InputSchema: mcp.ToolInputSchema{
 			Type: "object",
-			Properties: map[string]any{
+			Properties: withDatabaseOption(withNumberFormatOptions(map[string]any{
 				"months": map[string]any{
 					"type":        "integer",
 					"description": "Number of months to analyze (0 or omitted = all historical data)",
 					"default":     0,
 				},
-			},
+				"exclude_months": map[string]any{
+					"type":        "array",
+					"description": "Optional YYYY-MM months to leave out (e.g. a one-off big purchase); both raw and adjusted savings rates are reported",
+					"items": map[string]any{
+						"type": "string",
+					},
+				},
+				"include_transfers": map[string]any{
+					"type":        "boolean",
+					"description": "Count transfers between your own accounts as income and spending (default: false); cash withdrawals stay excluded either way",
+					"default":     false,
+				},
+				"include_scheduled": map[string]any{
+					"type":        "boolean",
+					"description": "Count future-dated (scheduled) transactions; by default they are left out of the results and of the months-back cutoff (default: false)",
+					"default":     false,
+				},
+				"rollup": map[string]any{
+					"type":        "boolean",
+					"description": "Count subcategory spending under its top-level category when ranking top spending categories (default: false)",
+					"default":     false,
+				},
+				"category_aliases": map[string]any{
+					"type":        "object",
+					"description": "Optional map from category ID or name (case-insensitive) to a display name, merging the matching categories in the output without changing the database, e.g. {\"Food2\": \"Food\", \"104\": \"Food\"}. With rollup, aliases apply to the top-level categories",
+					"additionalProperties": map[string]any{
+						"type": "string",
+					},
+				},
+				"net_refunds": map[string]any{
+					"type":        "boolean",
+					"description": "Subtract refunds (positive amounts in expense categories) from their category's spending instead of counting them as income (default: false)",
+					"default":     false,
+				},
+				"top_categories": map[string]any{
+					"type":        "integer",
+					"description": "Number of top spending categories to return (default: 5, 0 = all categories ranked)",
+					"default":     5,
+				},
+				"target_savings_rate": map[string]any{
+					"type":        "number",
+					"description": "Savings rate goal in percent that recommendations measure against (default: 20)",
+					"default":     20,
+				},
+				"target_emergency_fund_months": map[string]any{
+					"type":        "number",
+					"description": "Months of expenses to keep as an emergency fund (default: 3)",
+					"default":     3,
+				},
+				"savings_benchmarks": map[string]any{
+					"type":        "object",
+					"description": "Optional map of tier name to the minimum savings rate in percent of the tier, e.g. {\"good\": 15, \"great\": 25, \"excellent\": 40}. The savings rate recommendation is titled after the highest tier reached (\"low\" below every tier). Default: moderate at half the target, excellent at the target",
+					"additionalProperties": map[string]any{
+						"type": "number",
+					},
+				},
+			})),
 		},
 	}, s.handleGetSavingsRecommendations)
 
+	// Get savings goals tool
+	log.Println("  ✓ Registering tool: get_savings_goals")
+	mcpServer.AddTool(mcp.Tool{
+		Name:        "get_savings_goals",
+		Description: "Report progress on MoneyWiz savings goals: linked account balance against the target, amount remaining, and whether the average monthly contribution is on pace for the deadline; returns an empty list with a note when the database stores no goals",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: withDatabaseOption(withNumberFormatOptions(map[string]any{
+				"history_months": map[string]any{
+					"type":        "integer",
+					"description": "Recent calendar months of account activity used for the average monthly contribution (default: 6)",
+					"default":     6,
+				},
+			})),
+		},
+	}, s.handleGetSavingsGoals)
+
+	// Compare periods tool
+	log.Println("  ✓ Registering tool: compare_periods")
+	mcpServer.AddTool(mcp.Tool{
+		Name:        "compare_periods",
+		Description: "Compare income, spending and net savings of two periods with per-category deltas; defaults to this month vs last month and flags a period the data only partly covers",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: withDatabaseOption(withNumberFormatOptions(map[string]any{
+				"base_period": map[string]any{
+					"type":        "string",
+					"description": "Earlier period to compare against (YYYY-MM-DD, YYYY-MM, or YYYY). Omit both periods for month over month",
+				},
+				"compare_period": map[string]any{
+					"type":        "string",
+					"description": "Period to compare (YYYY-MM-DD, YYYY-MM, or YYYY). Omit both periods for month over month",
+				},
+				"include_transfers": map[string]any{
+					"type":        "boolean",
+					"description": "Count transfers between your own accounts as income and spending (default: false); cash withdrawals stay excluded either way",
+					"default":     false,
+				},
+			})),
+		},
+	}, s.handleComparePeriods)
+
+	// Same period last year tool
+	log.Println("  ✓ Registering tool: compare_same_period_last_year")
+	mcpServer.AddTool(mcp.Tool{
+		Name:        "compare_same_period_last_year",
+		Description: "Compare income, spending and net savings of a period with the same calendar period one year earlier (e.g. this December vs last December), with per-category deltas; controls for seasonality better than month over month",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: withDatabaseOption(withNumberFormatOptions(map[string]any{
+				"period": map[string]any{
+					"type":        "string",
+					"description": "Period to compare with a year earlier (YYYY-MM-DD, YYYY-MM, or YYYY). Omit for the month of the latest transaction",
+				},
+				"include_transfers": map[string]any{
+					"type":        "boolean",
+					"description": "Count transfers between your own accounts as income and spending (default: false); cash withdrawals stay excluded either way",
+					"default":     false,
+				},
+			})),
+		},
+	}, s.handleCompareSamePeriodLastYear)
+
+	// Category trend directions tool
+	log.Println("  ✓ Registering tool: get_category_trend_directions")
+	mcpServer.AddTool(mcp.Tool{
+		Name:        "get_category_trend_directions",
+		Description: "Compare the first and second half of a period to list the spending categories that improved (cut) and worsened (grew) the most",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: withDatabaseOption(withNumberFormatOptions(map[string]any{
+				"months": map[string]any{
+					"type":        "integer",
+					"description": "Number of months to analyze, split into two halves (default: 12, 0 = all historical data)",
+					"default":     12,
+				},
+			})),
+		},
+	}, s.handleGetCategoryTrendDirections)
+
+	// Spending anomalies tool
+	log.Println("  ✓ Registering tool: detect_spending_anomalies")
+	mcpServer.AddTool(mcp.Tool{
+		Name:        "detect_spending_anomalies",
+		Description: "Flag months where a category's spending was far above normal: more than N standard deviations above its mean over the other months, or more than X% above its trailing 3-month average (e.g. a surprise annual charge or a billing error)",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: withDatabaseOption(withNumberFormatOptions(map[string]any{
+				"months": map[string]any{
+					"type":        "integer",
+					"description": "Number of months to scan (default: 12, 0 = all historical data)",
+					"default":     12,
+				},
+				"std_dev_threshold": map[string]any{
+					"type":        "number",
+					"description": "Standard deviations above the category mean that count as an anomaly (default: 2)",
+					"default":     2,
+				},
+				"trailing_threshold_pct": map[string]any{
+					"type":        "number",
+					"description": "Percent above the trailing 3-month average that counts as an anomaly (default: 50)",
+					"default":     50,
+				},
+			})),
+		},
+	}, s.handleDetectSpendingAnomalies)
+
+	// Possible double charges tool
+	log.Println("  ✓ Registering tool: detect_possible_double_charges")
+	mcpServer.AddTool(mcp.Tool{
+		Name:        "detect_possible_double_charges",
+		Description: "Flag expenses from the same merchant charged more than once within a short time window with near-equal amounts (e.g. pending + posted pairs or accidental re-swipes)",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: withDatabaseOption(withNumberFormatOptions(map[string]any{
+				"months": map[string]any{
+					"type":        "integer",
+					"description": "Number of months to scan (default: 3, 0 = all historical data)",
+					"default":     3,
+				},
+				"window_hours": map[string]any{
+					"type":        "integer",
+					"description": "Maximum hours between two charges to consider them a possible double charge (default: 72)",
+					"default":     72,
+				},
+				"amount_tolerance_pct": map[string]any{
+					"type":        "number",
+					"description": "Maximum difference between the two amounts, as a percentage of the larger one (default: 5)",
+					"default":     5,
+				},
+			})),
+		},
+	}, s.handleDetectPossibleDoubleCharges)
+
+	// Detect duplicate transactions tool
+	log.Println("  ✓ Registering tool: detect_duplicate_transactions")
+	mcpServer.AddTool(mcp.Tool{
+		Name:        "detect_duplicate_transactions",
+		Description: "Find transactions that look imported twice: the same account, amount, and description on the same day or within date_tolerance_days, grouped for review with the extra amount they add",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: withDatabaseOption(withNumberFormatOptions(map[string]any{
+				"months": map[string]any{
+					"type":        "integer",
+					"description": "Number of months to scan (default: 12, 0 = all historical data)",
+					"default":     12,
+				},
+				"date_tolerance_days": map[string]any{
+					"type":        "integer",
+					"description": "Maximum days between copies, since some duplicates land a day apart (default: 0 = same day only, max: 31)",
+					"default":     0,
+				},
+			})),
+		},
+	}, s.handleDetectDuplicateTransactions)
+
+	// Recurring transactions tool
+	log.Println("  ✓ Registering tool: detect_recurring_transactions")
+	mcpServer.AddTool(mcp.Tool{
+		Name:        "detect_recurring_transactions",
+		Description: "Find subscriptions and regular bills: charges from the same payee with amounts within a tolerance (default 5%) that repeat a minimum number of times (default 3) about monthly or yearly, with average amount, last date and next expected date",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: withDatabaseOption(withNumberFormatOptions(map[string]any{
+				"months": map[string]any{
+					"type":        "integer",
+					"description": "Number of months of history to scan (default: 24, 0 = all data). Yearly series need at least as many years as min_occurrences",
+					"default":     24,
+				},
+				"min_occurrences": map[string]any{
+					"type":        "integer",
+					"description": "Charges a series needs to count as recurring (default: 3, minimum 2). Raise it to leave out irregular shopping such as grocery runs",
+					"default":     3,
+				},
+				"amount_tolerance_pct": map[string]any{
+					"type":        "number",
+					"description": "How far, in percent of the smallest charge, the amounts of one series may drift (default: 5)",
+					"default":     5,
+				},
+			})),
+		},
+	}, s.handleDetectRecurringTransactions)
+
+	// Upcoming bills tool
+	log.Println("  ✓ Registering tool: get_upcoming_bills")
+	mcpServer.AddTool(mcp.Tool{
+		Name:        "get_upcoming_bills",
+		Description: "List the recurring bills and subscriptions due in the next days, projected from their cadence and last charge with the expected amount; bills whose charge is already late are flagged as overdue",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: withDatabaseOption(withNumberFormatOptions(map[string]any{
+				"days": map[string]any{
+					"type":        "integer",
+					"description": "Number of days to look ahead (default: 30)",
+					"default":     30,
+				},
+				"months": map[string]any{
+					"type":        "integer",
+					"description": "Number of months of history to detect recurring bills in (default: 24, 0 = all data)",
+					"default":     24,
+				},
+				"as_of": map[string]any{
+					"type":        "string",
+					"description": "Optional YYYY-MM-DD day to look ahead from (default: today); later transactions are ignored",
+				},
+			})),
+		},
+	}, s.handleGetUpcomingBills)
+
+	// Scheduled transactions tool
+	log.Println("  ✓ Registering tool: list_scheduled_transactions")
+	mcpServer.AddTool(mcp.Tool{
+		Name:        "list_scheduled_transactions",
+		Description: "List the transactions scheduled in MoneyWiz with their next occurrence, amount, payee, category, and account, soonest first; unlike get_upcoming_bills these are planned rather than inferred from history, and past-due occurrences are flagged as overdue",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: withDatabaseOption(withNumberFormatOptions(map[string]any{
+				"days": map[string]any{
+					"type":        "integer",
+					"description": "Number of days to look ahead (default: 0 = every scheduled transaction)",
+					"default":     0,
+				},
+				"as_of": map[string]any{
+					"type":        "string",
+					"description": "Optional YYYY-MM-DD day to count from (default: today)",
+				},
+			})),
+		},
+	}, s.handleListScheduledTransactions)
+
+	// Cash flow forecast tool
+	log.Println("  ✓ Registering tool: forecast_cash_flow")
+	mcpServer.AddTool(mcp.Tool{
+		Name:        "forecast_cash_flow",
+		Description: "Forecast income, spending, net savings and end balance for the next N months from detected recurring items plus the average of recent non-recurring activity",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: withDatabaseOption(withNumberFormatOptions(map[string]any{
+				"months": map[string]any{
+					"type":        "integer",
+					"description": "Number of future months to forecast (default: 6, max: 60); larger values are clamped to 60",
+					"default":     6,
+				},
+				"history_months": map[string]any{
+					"type":        "integer",
+					"description": "Months of history used for the non-recurring averages (default: 6, max: 120); larger values are clamped to 120",
+					"default":     6,
+				},
+			})),
+		},
+	}, s.handleForecastCashFlow)
+
+	// Get burn rate tool
+	log.Println("  ✓ Registering tool: get_burn_rate")
+	mcpServer.AddTool(mcp.Tool{
+		Name:        "get_burn_rate",
+		Description: "Average daily spend over a trailing window and a projected month-end total for the current month, with days elapsed and remaining; internal transfers/cash withdrawals are excluded",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: withDatabaseOption(withNumberFormatOptions(map[string]any{
+				"trailing_days": map[string]any{
+					"type":        "integer",
+					"description": "Number of days, up to and including as_of, to average daily spending over (default: 30)",
+					"default":     30,
+				},
+				"as_of": map[string]any{
+					"type":        "string",
+					"description": "Optional YYYY-MM-DD day to measure from (default: today); its month is the one projected",
+				},
+			})),
+		},
+	}, s.handleGetBurnRate)
+
+	// Spending velocity tool
+	log.Println("  ✓ Registering tool: get_spending_velocity")
+	mcpServer.AddTool(mcp.Tool{
+		Name:        "get_spending_velocity",
+		Description: "Compare cumulative spending by day of month for the current month with the average trajectory of prior months, e.g. by the 15th you usually spent 800 and this month 1,200; returns the day-by-day overlay and whether spending is ahead, on track or behind; internal transfers are excluded",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: withDatabaseOption(withNumberFormatOptions(map[string]any{
+				"months": map[string]any{
+					"type":        "integer",
+					"description": "Number of full months before the current one to average (default: 6)",
+					"default":     6,
+				},
+				"as_of": map[string]any{
+					"type":        "string",
+					"description": "Day to measure to, YYYY-MM-DD (default: today)",
+				},
+			})),
+		},
+	}, s.handleGetSpendingVelocity)
+
+	// Since last paycheck tool
+	log.Println("  ✓ Registering tool: get_since_last_paycheck")
+	mcpServer.AddTool(mcp.Tool{
+		Name:        "get_since_last_paycheck",
+		Description: "Detect the regular paycheck (the largest monthly recurring income) and report income, spending, and what is left since the most recent one, with a daily allowance until the next; reports clearly when no regular income pattern is found",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: withDatabaseOption(withNumberFormatOptions(map[string]any{
+				"history_months": map[string]any{
+					"type":        "integer",
+					"description": "Number of months before as_of to scan for the paycheck series (default: 12)",
+					"default":     12,
+				},
+				"as_of": map[string]any{
+					"type":        "string",
+					"description": "Optional YYYY-MM-DD day to measure up to (default: today); later transactions are ignored",
+				},
+			})),
+		},
+	}, s.handleGetSinceLastPaycheck)
+
+	// Inflation impact projection tool
+	log.Println("  ✓ Registering tool: project_inflation_impact")
+	mcpServer.AddTool(mcp.Tool{
+		Name:        "project_inflation_impact",
+		Description: "Project how current annualized spending per category would grow under an assumed annual inflation rate over a number of years",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: withDatabaseOption(withNumberFormatOptions(map[string]any{
+				"annual_inflation_pct": map[string]any{
+					"type":        "number",
+					"description": "Assumed annual inflation rate in percent (default: 3)",
+					"default":     3,
+				},
+				"years": map[string]any{
+					"type":        "integer",
+					"description": "Number of years to compound over (default: 10)",
+					"default":     10,
+				},
+				"months": map[string]any{
+					"type":        "integer",
+					"description": "Months of history used to annualize current spending (default: 12, 0 = all historical data); spending is averaged over the months in that window that have spending",
+					"default":     12,
+				},
+			})),
+		},
+	}, s.handleProjectInflationImpact)
+
 	// Calculate net worth tool
 	log.Println("  ✓ Registering tool: calculate_net_worth")
 	mcpServer.AddTool(mcp.Tool{
 		Name:        "calculate_net_worth",
-		Description: "Calculate total net worth from all accounts (assets minus liabilities)",
+		Description: "Calculate total net worth from all accounts (assets minus liabilities); pass target_currency and exchange_rates to convert mixed-currency balances before summing, by_group or account_groups to break it down by account group, and the include/exclude options to count only some accounts",
 		InputSchema: mcp.ToolInputSchema{
-			Type:       "object",
-			Properties: map[string]any{},
+			Type: "object",
+			Properties: withDatabaseOption(withNumberFormatOptions(map[string]any{
+				"target_currency": map[string]any{
+					"type":        "string",
+					"description": "Optional currency code (e.g. 'USD') to convert every balance into before summing the totals. When exchange_rates are given, defaults to the server's default currency, else the base currency set in the file",
+				},
+				"exchange_rates": map[string]any{
+					"type":        "object",
+					"description": "Optional map of currency code to units of target_currency per unit of that currency, e.g. {\"EUR\": 1.08}. Missing currencies are converted at 1.0 with a warning",
+					"additionalProperties": map[string]any{
+						"type": "number",
+					},
+				},
+				"by_group": map[string]any{
+					"type":        "boolean",
+					"description": "Break the net worth down by the account groups stored in MoneyWiz (default: false)",
+					"default":     false,
+				},
+				"account_groups": map[string]any{
+					"type":        "object",
+					"description": "Optional map of group name to account IDs, e.g. {\"cash\": [1, 2], \"retirement\": [7]}, to break the net worth down by instead of the stored groups. Implies by_group",
+					"additionalProperties": map[string]any{
+						"type": "array",
+						"items": map[string]any{
+							"type": "integer",
+						},
+					},
+				},
+				"include_account_ids": map[string]any{
+					"type":        "array",
+					"description": "Optional account IDs (from list_accounts) to count; other accounts are left out",
+					"items": map[string]any{
+						"type": "integer",
+					},
+				},
+				"exclude_account_ids": map[string]any{
+					"type":        "array",
+					"description": "Optional account IDs to leave out, e.g. a shared account you do not fully own",
+					"items": map[string]any{
+						"type": "integer",
+					},
+				},
+				"include_account_types": map[string]any{
+					"type":        "array",
+					"description": "Optional account types (as in list_accounts, case-insensitive) to count; accounts of other types are left out",
+					"items": map[string]any{
+						"type": "string",
+					},
+				},
+				"exclude_account_types": map[string]any{
+					"type":        "array",
+					"description": "Optional account types to leave out, e.g. [\"investment\"] to ignore market swings",
+					"items": map[string]any{
+						"type": "string",
+					},
+				},
+			})),
 		},
 	}, s.handleCalculateNetWorth)
 
+	// Net worth over time tool
+	log.Println("  ✓ Registering tool: net_worth_over_time")
+	mcpServer.AddTool(mcp.Tool{
+		Name:        "net_worth_over_time",
+		Description: "Reconstruct net worth, total assets and total liabilities at the end of each month by rolling current account balances backward through transactions, optionally split per currency; use it to chart the net worth trend",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: withDatabaseOption(withNumberFormatOptions(map[string]any{
+				"months": map[string]any{
+					"type":        "integer",
+					"description": "Number of month-ends to return, ending at the month of the latest transaction (default: 12, max: 600); larger values are clamped to 600",
+					"default":     12,
+				},
+				"by_currency": map[string]any{
+					"type":        "boolean",
+					"description": "Also return per-currency balances for each month-end (default: false)",
+					"default":     false,
+				},
+			})),
+		},
+	}, s.handleNetWorthOverTime)
+
+	// Net worth change attribution tool
+	log.Println("  ✓ Registering tool: attribute_net_worth_change")
+	mcpServer.AddTool(mcp.Tool{
+		Name:        "attribute_net_worth_change",
+		Description: "Explain why net worth changed between two days: splits the change into savings (income minus spending), investment returns booked on investment accounts, and transfers that did not cancel out between own accounts, with per-currency breakdowns; unbooked market value changes are reported separately because MoneyWiz keeps no price history",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: withDatabaseOption(withNumberFormatOptions(map[string]any{
+				"start_date": map[string]any{
+					"type":        "string",
+					"description": "First day of the window, YYYY-MM-DD (default: 12 months before end_date)",
+				},
+				"end_date": map[string]any{
+					"type":        "string",
+					"description": "Last day of the window, YYYY-MM-DD (default: today)",
+				},
+			})),
+		},
+	}, s.handleAttributeNetWorthChange)
+
+	// Net worth by institution tool
+	log.Println("  ✓ Registering tool: calculate_net_worth_by_institution")
+	mcpServer.AddTool(mcp.Tool{
+		Name:        "calculate_net_worth_by_institution",
+		Description: "Group account balances by bank/institution to show total exposure per institution, with per-currency totals; accounts without an institution are listed under 'Unknown'",
+		InputSchema: mcp.ToolInputSchema{
+			Type:       "object",
+			Properties: withDatabaseOption(withNumberFormatOptions(map[string]any{})),
+		},
+	}, s.handleCalculateNetWorthByInstitution)
+
 	// Get financial stats tool
 	log.Println("  ✓ Registering tool: get_financial_stats")
 	mcpServer.AddTool(mcp.Tool{
 		Name:        "get_financial_stats",
 		Description: "Get comprehensive financial statistics with explicit currency context, per-currency breakdowns, and totals excluding internal transfers/cash withdrawals",
 		InputSchema: mcp.ToolInputSchema{
-			Type:       "object",
-			Properties: map[string]any{},
+			Type: "object",
+			Properties: withDatabaseOption(withNumberFormatOptions(map[string]any{
+				"include_transfers": map[string]any{
+					"type":        "boolean",
+					"description": "Count transfers between your own accounts as income and spending (default: false); cash withdrawals stay excluded either way",
+					"default":     false,
+				},
+			})),
 		},
 	}, s.handleGetFinancialStats)
 
-	log.Println("✅ All 9 MCP tools registered successfully!")
+	// Get largest transactions tool
+	log.Println("  ✓ Registering tool: get_largest_transactions")
+	mcpServer.AddTool(mcp.Tool{
+		Name:        "get_largest_transactions",
+		Description: "List the largest transactions by absolute amount over a period with date, amount, description, category, and account, optionally only income or only expenses; internal transfers/cash withdrawals are excluded",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: withDatabaseOption(withNumberFormatOptions(map[string]any{
+				"months": map[string]any{
+					"type":        "integer",
+					"description": "Number of months to look back (default: 12, 0 = all historical data)",
+					"default":     12,
+				},
+				"limit": map[string]any{
+					"type":        "integer",
+					"description": "Number of transactions to return (default: 10)",
+					"default":     10,
+				},
+				"type": map[string]any{
+					"type":        "string",
+					"description": "'income', 'expense', or 'both' (default: 'both')",
+					"enum":        []string{"income", "expense", "both"},
+					"default":     "both",
+				},
+			})),
+		},
+	}, s.handleGetLargestTransactions)
+
+	// Transaction histogram tool
+	log.Println("  ✓ Registering tool: get_transaction_histogram")
+	mcpServer.AddTool(mcp.Tool{
+		Name:        "get_transaction_histogram",
+		Description: "Histogram of transaction amounts in buckets of a configurable size (e.g. 0-25, 25-50, ...) with the count, total and share of each bucket, for spending, income, or both; shows whether spending is dominated by many small or a few large transactions. Internal transfers and refunds are excluded",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: withDatabaseOption(withNumberFormatOptions(map[string]any{
+				"kind": map[string]any{
+					"type":        "string",
+					"description": "Transactions to bucket: spending, income, or both (default: spending)",
+					"enum":        []string{"spending", "income", "both"},
+					"default":     "spending",
+				},
+				"months": map[string]any{
+					"type":        "integer",
+					"description": "Number of months to analyze (default: 0 = all data)",
+					"default":     0,
+				},
+				"bucket_size": map[string]any{
+					"type":        "number",
+					"description": "Width of each amount bucket (default: 25)",
+					"default":     25,
+				},
+				"max_buckets": map[string]any{
+					"type":        "integer",
+					"description": "Number of buckets; the last one is open-ended and takes every larger amount (default: 20)",
+					"default":     20,
+				},
+			})),
+		},
+	}, s.handleGetTransactionHistogram)
+
+	log.Println("✅ All 52 MCP tools registered successfully!")
 }
@@ -2,37 +2,139 @@ package server
 
 import (
 	"context"
-	"encoding/json"
-	"fmt"
 
 	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/moneywiz-mcp/internal/database"
 )
 
 func (s *Server) handleCalculateNetWorth(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-	netWorth, err := s.db.CalculateNetWorth()
-	if err != nil {
-		return &mcp.CallToolResult{
-			Content: []mcp.Content{
-				mcp.TextContent{
-					Type: "text",
-					Text: fmt.Sprintf("Error: %v", err),
-				},
+	rates, err := parseExchangeRates(request.GetArguments()["exchange_rates"])
+	if err != nil {
+		return errorResult(ErrorCodeInvalidArgument, err), nil
+	}
+	grouping, err := parseAccountGroups(request.GetArguments()["account_groups"])
+	if err != nil {
+		return errorResult(ErrorCodeInvalidArgument, err), nil
+	}
+	filter, err := parseAccountFilter(request.GetArguments())
+	if err != nil {
+		return errorResult(ErrorCodeInvalidArgument, err), nil
+	}
+
+	db, err := s.databaseFor(request)
+	if err != nil {
+		return errorResult(ErrorCodeInvalidArgument, err), nil
+	}
+
+	targetCurrency, err := s.targetCurrencyFor(ctx, request, db, rates)
+	if err != nil {
+		return errorResult(errorCodeFor(err), err), nil
+	}
+
+	var netWorth *database.NetWorth
+	if targetCurrency != "" {
+		netWorth, err = db.CalculateNetWorthInCurrency(ctx, targetCurrency, rates, filter)
+	} else {
+		netWorth, err = db.CalculateNetWorth(ctx, filter)
+	}
+	if err != nil {
+		return errorResult(errorCodeFor(err), err), nil
+	}
+	if request.GetBool("by_group", false) || len(grouping) > 0 {
+		if err := db.BreakDownNetWorthByGroup(ctx, netWorth, grouping); err != nil {
+			return errorResult(errorCodeFor(err), err), nil
+		}
+	}
+
+	jsonData, err := textContentJSON(request, netWorth)
+	if err != nil {
+		return marshalErrorResult("net worth", err), nil
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: string(jsonData),
 			},
-			IsError: true,
-		}, nil
+		},
+		StructuredContent: netWorth,
+	}, nil
+}
+
+func (s *Server) handleGetFinancialStats(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	includeTransfers := request.GetBool("include_transfers", false)
+
+	db, err := s.databaseFor(request)
+	if err != nil {
+		return errorResult(ErrorCodeInvalidArgument, err), nil
+	}
+
+	stats, err := db.GetFinancialStats(ctx, includeTransfers)
+	if err != nil {
+		return errorResult(errorCodeFor(err), err), nil
 	}
 
-	jsonData, err := json.MarshalIndent(netWorth, "", "  ")
+	jsonData, err := textContentJSON(request, stats)
 	if err != nil {
-		return &mcp.CallToolResult{
-			Content: []mcp.Content{
-				mcp.TextContent{
-					Type: "text",
-					Text: fmt.Sprintf("Error marshaling net worth: %v", err),
-				},
+		return marshalErrorResult("stats", err), nil
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: string(jsonData),
 			},
-			IsError: true,
-		}, nil
+		},
+		StructuredContent: stats,
+	}, nil
+}
+
+func (s *Server) handleNetWorthOverTime(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	months := request.GetInt("months", 12)
+	byCurrency := request.GetBool("by_currency", false)
+
+	db, err := s.databaseFor(request)
+	if err != nil {
+		return errorResult(ErrorCodeInvalidArgument, err), nil
+	}
+
+	series, err := db.CalculateNetWorthSeries(ctx, months, byCurrency)
+	if err != nil {
+		return errorResult(errorCodeFor(err), err), nil
+	}
+
+	jsonData, err := textContentJSON(request, series)
+	if err != nil {
+		return marshalErrorResult("net worth series", err), nil
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: string(jsonData),
+			},
+		},
+		StructuredContent: series,
+	}, nil
+}
+
+func (s *Server) handleCalculateNetWorthByInstitution(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	db, err := s.databaseFor(request)
+	if err != nil {
+		return errorResult(ErrorCodeInvalidArgument, err), nil
+	}
+
+	netWorth, err := db.CalculateNetWorthByInstitution(ctx)
+	if err != nil {
+		return errorResult(errorCodeFor(err), err), nil
+	}
+
+	jsonData, err := textContentJSON(request, netWorth)
+	if err != nil {
+		return marshalErrorResult("net worth by institution", err), nil
 	}
 
 	return &mcp.CallToolResult{
@@ -46,31 +148,86 @@ func (s *Server) handleCalculateNetWorth(ctx context.Context, request mcp.CallTo
 	}, nil
 }
 
-func (s *Server) handleGetFinancialStats(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-	stats, err := s.db.GetFinancialStats()
-	if err != nil {
-		return &mcp.CallToolResult{
-			Content: []mcp.Content{
-				mcp.TextContent{
-					Type: "text",
-					Text: fmt.Sprintf("Error: %v", err),
-				},
+func (s *Server) handleGetLargestTransactions(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	months := request.GetInt("months", 12)
+	limit := request.GetInt("limit", 10)
+	transactionType := request.GetString("type", "both")
+
+	db, err := s.databaseFor(request)
+	if err != nil {
+		return errorResult(ErrorCodeInvalidArgument, err), nil
+	}
+
+	largest, err := db.GetLargestTransactions(ctx, months, limit, transactionType)
+	if err != nil {
+		return errorResult(errorCodeFor(err), err), nil
+	}
+
+	jsonData, err := textContentJSON(request, largest)
+	if err != nil {
+		return marshalErrorResult("largest transactions", err), nil
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: string(jsonData),
 			},
-			IsError: true,
-		}, nil
+		},
+		StructuredContent: largest,
+	}, nil
+}
+
+func (s *Server) handleAttributeNetWorthChange(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	startDate := request.GetString("start_date", "")
+	endDate := request.GetString("end_date", "")
+
+	db, err := s.databaseFor(request)
+	if err != nil {
+		return errorResult(ErrorCodeInvalidArgument, err), nil
 	}
 
-	jsonData, err := json.MarshalIndent(stats, "", "  ")
+	attribution, err := db.AttributeNetWorthChange(ctx, startDate, endDate)
 	if err != nil {
-		return &mcp.CallToolResult{
-			Content: []mcp.Content{
-				mcp.TextContent{
-					Type: "text",
-					Text: fmt.Sprintf("Error marshaling stats: %v", err),
-				},
+		return errorResult(errorCodeFor(err), err), nil
+	}
+
+	jsonData, err := textContentJSON(request, attribution)
+	if err != nil {
+		return marshalErrorResult("net worth attribution", err), nil
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: string(jsonData),
 			},
-			IsError: true,
-		}, nil
+		},
+		StructuredContent: attribution,
+	}, nil
+}
+
+func (s *Server) handleGetTransactionHistogram(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	kind := request.GetString("kind", database.HistogramKindSpending)
+	months := request.GetInt("months", 0)
+	bucketSize := request.GetFloat("bucket_size", 25)
+	maxBuckets := request.GetInt("max_buckets", 20)
+
+	db, err := s.databaseFor(request)
+	if err != nil {
+		return errorResult(ErrorCodeInvalidArgument, err), nil
+	}
+
+	histogram, err := db.GetTransactionHistogram(ctx, kind, months, bucketSize, maxBuckets)
+	if err != nil {
+		return errorResult(errorCodeFor(err), err), nil
+	}
+
+	jsonData, err := textContentJSON(request, histogram)
+	if err != nil {
+		return marshalErrorResult("histogram", err), nil
 	}
 
 	return &mcp.CallToolResult{
@@ -80,6 +237,6 @@ func (s *Server) handleGetFinancialStats(ctx context.Context, request mcp.CallTo
 				Text: string(jsonData),
 			},
 		},
-		StructuredContent: stats,
+		StructuredContent: histogram,
 	}, nil
 }
@@ -2,10 +2,10 @@ package server
 
 import (
 	"context"
-	"encoding/json"
-	"fmt"
+	"strings"
 
 	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/moneywiz-mcp/internal/database"
 )
 
 func (s *Server) handleListTransactions(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
@@ -14,38 +14,102 @@ func (s *Server) handleListTransactions(ctx context.Context, request mcp.CallToo
 		request.GetInt("limit", defaultTransactionLimit),
 	)
 
-	transactions, err := s.db.GetTransactions(accountID, limit)
+	filter := database.TransactionFilter{
+		AccountID: accountID,
+		Limit:     limit,
+		Offset:    normalizeOffset(request.GetInt("offset", 0)),
+		StartDate: request.GetString("start_date", ""),
+		EndDate:   request.GetString("end_date", ""),
+		MinAmount: request.GetFloat("min_amount", 0),
+		MaxAmount: request.GetFloat("max_amount", 0),
+		Status:    request.GetString("status", ""),
+	}
+	format, err := normalizeOutputFormat(request.GetString("format", outputFormatJSON), outputFormatJSON, outputFormatJSONL)
 	if err != nil {
-		return &mcp.CallToolResult{
-			Content: []mcp.Content{
-				mcp.TextContent{
-					Type: "text",
-					Text: fmt.Sprintf("Error: %v", err),
-				},
-			},
-			IsError: true,
-		}, nil
+		return errorResult(ErrorCodeInvalidArgument, err), nil
+	}
+
+	db, err := s.databaseFor(request)
+	if err != nil {
+		return errorResult(ErrorCodeInvalidArgument, err), nil
+	}
+
+	if format == outputFormatJSONL {
+		return transactionsJSONLResult(ctx, db, filter), nil
+	}
+
+	transactions, err := db.GetTransactions(ctx, filter)
+	if err != nil {
+		return errorResult(errorCodeFor(err), err), nil
+	}
+
+	totalCount, err := db.CountTransactions(ctx, filter)
+	if err != nil {
+		return errorResult(errorCodeFor(err), err), nil
+	}
+	if transactions == nil {
+		transactions = []database.Transaction{}
 	}
 
 	currencies, mixedCurrencies, currencyWarning := currencyMetaFromTransactions(transactions)
 	response := map[string]interface{}{
 		"transactions":     transactions,
+		"total_count":      totalCount,
+		"offset":           filter.Offset,
+		"limit":            filter.Limit,
 		"currencies":       currencies,
 		"mixed_currencies": mixedCurrencies,
 		"currency_warning": currencyWarning,
 	}
 
-	jsonData, err := json.MarshalIndent(response, "", "  ")
+	jsonData, err := textContentJSON(request, response)
 	if err != nil {
-		return &mcp.CallToolResult{
-			Content: []mcp.Content{
-				mcp.TextContent{
-					Type: "text",
-					Text: fmt.Sprintf("Error marshaling transactions: %v", err),
-				},
+		return marshalErrorResult("transactions", err), nil
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: string(jsonData),
 			},
-			IsError: true,
-		}, nil
+		},
+		StructuredContent: response,
+	}, nil
+}
+
+func (s *Server) handleSearchTransactions(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	query, err := request.RequireString("query")
+	if err != nil {
+		return errorResult(ErrorCodeInvalidArgument, err), nil
+	}
+	accountID, limit := normalizeTransactionParams(
+		request.GetFloat("account_id", 0),
+		request.GetInt("limit", defaultTransactionLimit),
+	)
+
+	db, err := s.databaseFor(request)
+	if err != nil {
+		return errorResult(ErrorCodeInvalidArgument, err), nil
+	}
+
+	transactions, err := db.SearchTransactions(ctx, query, accountID, limit)
+	if err != nil {
+		return errorResult(errorCodeFor(err), err), nil
+	}
+
+	currencies, mixedCurrencies, currencyWarning := currencyMetaFromTransactions(transactions)
+	response := map[string]interface{}{
+		"query":            query,
+		"transactions":     transactions,
+		"currencies":       currencies,
+		"mixed_currencies": mixedCurrencies,
+		"currency_warning": currencyWarning,
+	}
+
+	jsonData, err := textContentJSON(request, response)
+	if err != nil {
+		return marshalErrorResult("transactions", err), nil
 	}
 
 	return &mcp.CallToolResult{
@@ -58,3 +122,117 @@ func (s *Server) handleListTransactions(ctx context.Context, request mcp.CallToo
 		StructuredContent: response,
 	}, nil
 }
+
+func (s *Server) handleExportTransactionsCSV(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	format, err := normalizeOutputFormat(request.GetString("format", outputFormatCSV), outputFormatCSV, outputFormatJSONL)
+	if err != nil {
+		return errorResult(ErrorCodeInvalidArgument, err), nil
+	}
+
+	db, err := s.databaseFor(request)
+	if err != nil {
+		return errorResult(ErrorCodeInvalidArgument, err), nil
+	}
+
+	filter := database.TransactionFilter{
+		AccountID: int64(request.GetFloat("account_id", 0)),
+		StartDate: request.GetString("start_date", ""),
+		EndDate:   request.GetString("end_date", ""),
+	}
+	if format == outputFormatJSONL {
+		return transactionsJSONLResult(ctx, db, filter), nil
+	}
+
+	csvText, err := db.ExportTransactionsCSV(ctx, filter)
+	if err != nil {
+		return errorResult(errorCodeFor(err), err), nil
+	}
+
+	// Raw CSV so the client can save it as-is; number formatting options do not apply
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: csvText,
+			},
+		},
+	}, nil
+}
+
+func (s *Server) handleGetUncategorizedTransactions(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	months := request.GetInt("months", 12)
+	limit := normalizeLimit(request.GetInt("limit", defaultTransactionLimit))
+
+	db, err := s.databaseFor(request)
+	if err != nil {
+		return errorResult(ErrorCodeInvalidArgument, err), nil
+	}
+
+	report, err := db.GetUncategorizedTransactions(ctx, months, limit)
+	if err != nil {
+		return errorResult(errorCodeFor(err), err), nil
+	}
+
+	jsonData, err := textContentJSON(request, report)
+	if err != nil {
+		return marshalErrorResult("uncategorized transactions", err), nil
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: string(jsonData),
+			},
+		},
+		StructuredContent: report,
+	}, nil
+}
+
+// transactionsJSONLResult returns the transactions matching the filter as JSON Lines text
+// Lines are written as transactions are read rather than marshaled as one indented array, and
+// there is no structured copy; number formatting options do not apply
+func transactionsJSONLResult(ctx context.Context, db *database.DB, filter database.TransactionFilter) *mcp.CallToolResult {
+	var text strings.Builder
+	if _, err := db.WriteTransactionsJSONL(ctx, filter, &text); err != nil {
+		return errorResult(errorCodeFor(err), err)
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: text.String(),
+			},
+		},
+	}
+}
+
+func (s *Server) handleDiagnoseCategoryAssignments(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	months := request.GetInt("months", 0)
+
+	db, err := s.databaseFor(request)
+	if err != nil {
+		return errorResult(ErrorCodeInvalidArgument, err), nil
+	}
+
+	diagnostics, err := db.DiagnoseCategoryAssignments(ctx, months)
+	if err != nil {
+		return errorResult(errorCodeFor(err), err), nil
+	}
+
+	jsonData, err := textContentJSON(request, diagnostics)
+	if err != nil {
+		return marshalErrorResult("diagnostics", err), nil
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: string(jsonData),
+			},
+		},
+		StructuredContent: diagnostics,
+	}, nil
+}